@@ -9,12 +9,18 @@ import (
 type CreateApplicationRequestDto struct {
 	Name                  string   `json:"name" validate:"required,min=1,max=255"`
 	DisplayName           string   `json:"displayName" validate:"required,min=1,max=255"`
-	RedirectUris          []string `json:"redirectUris" validate:"required,dive,url,min=1"`
+	RedirectUris          []string `json:"redirectUris" validate:"required_unless=Type saml,dive,url,min=1"`
 	PostLogoutUris        []string `json:"postLogoutUris" validate:"dive,url"`
-	Type                  string   `json:"type" validate:"required,oneof=public confidential"`
+	Type                  string   `json:"type" validate:"required,oneof=public confidential saml"`
 	AccessTokenHeaderType *string  `json:"accessTokenHeaderType" validate:"omitempty,oneof=at+jwt JWT"`
 	DeviceFlowEnabled     bool     `json:"deviceFlowEnabled"`
-	SigningAlgorithm      *string  `json:"signingAlgorithm,omitempty" validate:"omitempty,oneof=RS256 EdDSA"`
+	SigningAlgorithm      *string  `json:"signingAlgorithm,omitempty" validate:"omitempty,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+	SamlEntityId          *string  `json:"samlEntityId,omitempty" validate:"required_if=Type saml,omitempty,min=1,max=1024"`
+	SamlAcsUrl            *string  `json:"samlAcsUrl,omitempty" validate:"required_if=Type saml,omitempty,url"`
+	SamlSloUrl            *string  `json:"samlSloUrl,omitempty" validate:"omitempty,url"`
+	// SamlCertificate is the pem encoded certificate the service provider
+	// signs its requests with, single logout is only accepted if it is set.
+	SamlCertificate *string `json:"samlCertificate,omitempty"`
 }
 
 type CreateApplicationResponseDto struct {
@@ -41,6 +47,14 @@ type GetApplicationResponseDto struct {
 
 	SigningAlgorithm *string `json:"signingAlgorithm,omitempty"`
 
+	SamlEntityId *string `json:"samlEntityId,omitempty"`
+	SamlAcsUrl   *string `json:"samlAcsUrl,omitempty"`
+	SamlSloUrl   *string `json:"samlSloUrl,omitempty"`
+
+	SamlCertificate *string `json:"samlCertificate,omitempty"`
+
+	AcrLevels []AcrLevelDto `json:"acrLevels"`
+
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -52,7 +66,22 @@ type PatchApplicationRequestDto struct {
 	RedirectUris          []string `json:"redirectUris,omitempty"`
 	PostLogoutUris        []string `json:"postLogoutUris,omitempty"`
 	AccessTokenHeaderType *string  `json:"accessTokenHeaderType,omitempty" validate:"omitempty,oneof=at+jwt JWT"`
-	SigningAlgorithm      *string  `json:"signingAlgorithm,omitempty" validate:"omitempty,oneof=RS256 EdDSA"`
+	SigningAlgorithm      *string  `json:"signingAlgorithm,omitempty" validate:"omitempty,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+	SamlAcsUrl            *string  `json:"samlAcsUrl,omitempty" validate:"omitempty,url"`
+	SamlSloUrl            *string  `json:"samlSloUrl,omitempty" validate:"omitempty,url"`
+	// SamlCertificate replaces the certificate of the service provider, an
+	// empty string removes it.
+	SamlCertificate *string `json:"samlCertificate,omitempty"`
+	// AcrLevels replaces all levels of the application if set.
+	AcrLevels *[]AcrLevelDto `json:"acrLevels,omitempty"`
+}
+
+// AcrLevelDto maps an acr value the application can request with
+// acr_values to the authentication methods (pwd, otp, email, sms, hwk,
+// swk, mfa) a login has to complete to reach it.
+type AcrLevelDto struct {
+	Acr     string   `json:"acr"`
+	Methods []string `json:"methods"`
 }
 
 type PagedApplicationsResponseDto = PagedResponseDto[ListApplicationsResponseDto]
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IdentityProviderClaimMappingDto struct {
+	Subject       string `json:"subject,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified string `json:"emailVerified,omitempty"`
+	DisplayName   string `json:"displayName,omitempty"`
+}
+
+type CreateIdentityProviderRequestDto struct {
+	Name             string                           `json:"name" validate:"required,min=1,max=255,excludesall=/?#%"`
+	DisplayName      string                           `json:"displayName" validate:"required,min=1,max=255"`
+	Issuer           string                           `json:"issuer" validate:"required,url"`
+	ClientId         string                           `json:"clientId" validate:"required"`
+	ClientSecret     string                           `json:"clientSecret"`
+	Scopes           []string                         `json:"scopes"`
+	AuthorizationUrl *string                          `json:"authorizationUrl,omitempty" validate:"omitempty,url"`
+	TokenUrl         *string                          `json:"tokenUrl,omitempty" validate:"omitempty,url"`
+	UserinfoUrl      *string                          `json:"userinfoUrl,omitempty" validate:"omitempty,url"`
+	ClaimMapping     *IdentityProviderClaimMappingDto `json:"claimMapping,omitempty"`
+	LinkByEmail      bool                             `json:"linkByEmail"`
+	TrustUpstreamMfa bool                             `json:"trustUpstreamMfa"`
+}
+
+type CreateIdentityProviderResponseDto struct {
+	Id          uuid.UUID `json:"id"`
+	RedirectUri string    `json:"redirectUri"`
+}
+
+type ListIdentityProvidersResponseDto struct {
+	Items []ListIdentityProvidersResponseItemDto `json:"items"`
+}
+
+type ListIdentityProvidersResponseItemDto struct {
+	Id          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+	Issuer      string    `json:"issuer"`
+}
+
+type GetIdentityProviderResponseDto struct {
+	Id               uuid.UUID                       `json:"id"`
+	Name             string                          `json:"name"`
+	DisplayName      string                          `json:"displayName"`
+	Issuer           string                          `json:"issuer"`
+	ClientId         string                          `json:"clientId"`
+	Scopes           []string                        `json:"scopes"`
+	AuthorizationUrl *string                         `json:"authorizationUrl,omitempty"`
+	TokenUrl         *string                         `json:"tokenUrl,omitempty"`
+	UserinfoUrl      *string                         `json:"userinfoUrl,omitempty"`
+	ClaimMapping     IdentityProviderClaimMappingDto `json:"claimMapping"`
+	LinkByEmail      bool                            `json:"linkByEmail"`
+	TrustUpstreamMfa bool                            `json:"trustUpstreamMfa"`
+	RedirectUri      string                          `json:"redirectUri"`
+	CreatedAt        time.Time                       `json:"createdAt"`
+	UpdatedAt        time.Time                       `json:"updatedAt"`
+}
+
+type PatchIdentityProviderRequestDto struct {
+	DisplayName      *string                          `json:"displayName,omitempty" validate:"omitempty,min=1,max=255"`
+	Issuer           *string                          `json:"issuer,omitempty" validate:"omitempty,url"`
+	ClientId         *string                          `json:"clientId,omitempty" validate:"omitempty,min=1"`
+	ClientSecret     *string                          `json:"clientSecret,omitempty"`
+	Scopes           []string                         `json:"scopes,omitempty"`
+	AuthorizationUrl *string                          `json:"authorizationUrl,omitempty" validate:"omitempty,url|len=0"`
+	TokenUrl         *string                          `json:"tokenUrl,omitempty" validate:"omitempty,url|len=0"`
+	UserinfoUrl      *string                          `json:"userinfoUrl,omitempty" validate:"omitempty,url|len=0"`
+	ClaimMapping     *IdentityProviderClaimMappingDto `json:"claimMapping,omitempty"`
+	LinkByEmail      *bool                            `json:"linkByEmail,omitempty"`
+	TrustUpstreamMfa *bool                            `json:"trustUpstreamMfa,omitempty"`
+}
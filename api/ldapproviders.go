@@ -0,0 +1,91 @@
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LdapAttributeMappingDto struct {
+	ExternalId  string `json:"externalId,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	GroupName   string `json:"groupName,omitempty"`
+	GroupMember string `json:"groupMember,omitempty"`
+}
+
+type CreateLdapProviderRequestDto struct {
+	Name                string                   `json:"name" validate:"required,min=1,max=255,excludesall=/?#%"`
+	Vendor              string                   `json:"vendor" validate:"required,oneof=generic activeDirectory"`
+	ConnectionUrl       string                   `json:"connectionUrl" validate:"required,url"`
+	StartTls            bool                     `json:"startTls"`
+	BindDn              string                   `json:"bindDn" validate:"required"`
+	BindPassword        string                   `json:"bindPassword" validate:"required"`
+	UsersDn             string                   `json:"usersDn" validate:"required"`
+	UserFilter          *string                  `json:"userFilter,omitempty" validate:"omitempty,min=1"`
+	GroupsDn            *string                  `json:"groupsDn,omitempty" validate:"omitempty,min=1"`
+	GroupFilter         *string                  `json:"groupFilter,omitempty" validate:"omitempty,min=1"`
+	AttributeMapping    *LdapAttributeMappingDto `json:"attributeMapping,omitempty"`
+	EditMode            string                   `json:"editMode" validate:"omitempty,oneof=readOnly writable"`
+	SyncIntervalSeconds int64                    `json:"syncIntervalSeconds" validate:"min=0"`
+}
+
+type CreateLdapProviderResponseDto struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type ListLdapProvidersResponseDto struct {
+	Items []ListLdapProvidersResponseItemDto `json:"items"`
+}
+
+type ListLdapProvidersResponseItemDto struct {
+	Id            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Vendor        string     `json:"vendor"`
+	ConnectionUrl string     `json:"connectionUrl"`
+	EditMode      string     `json:"editMode"`
+	LastSyncAt    *time.Time `json:"lastSyncAt,omitempty"`
+}
+
+type GetLdapProviderResponseDto struct {
+	Id                  uuid.UUID               `json:"id"`
+	Name                string                  `json:"name"`
+	Vendor              string                  `json:"vendor"`
+	ConnectionUrl       string                  `json:"connectionUrl"`
+	StartTls            bool                    `json:"startTls"`
+	BindDn              string                  `json:"bindDn"`
+	UsersDn             string                  `json:"usersDn"`
+	UserFilter          string                  `json:"userFilter"`
+	GroupsDn            *string                 `json:"groupsDn,omitempty"`
+	GroupFilter         string                  `json:"groupFilter"`
+	AttributeMapping    LdapAttributeMappingDto `json:"attributeMapping"`
+	EditMode            string                  `json:"editMode"`
+	SyncIntervalSeconds int64                   `json:"syncIntervalSeconds"`
+	LastSyncAt          *time.Time              `json:"lastSyncAt,omitempty"`
+	CreatedAt           time.Time               `json:"createdAt"`
+	UpdatedAt           time.Time               `json:"updatedAt"`
+}
+
+type PatchLdapProviderRequestDto struct {
+	ConnectionUrl       *string                  `json:"connectionUrl,omitempty" validate:"omitempty,url"`
+	StartTls            *bool                    `json:"startTls,omitempty"`
+	BindDn              *string                  `json:"bindDn,omitempty" validate:"omitempty,min=1"`
+	BindPassword        *string                  `json:"bindPassword,omitempty" validate:"omitempty,min=1"`
+	UsersDn             *string                  `json:"usersDn,omitempty" validate:"omitempty,min=1"`
+	UserFilter          *string                  `json:"userFilter,omitempty" validate:"omitempty,min=1"`
+	GroupsDn            *string                  `json:"groupsDn,omitempty"`
+	GroupFilter         *string                  `json:"groupFilter,omitempty" validate:"omitempty,min=1"`
+	AttributeMapping    *LdapAttributeMappingDto `json:"attributeMapping,omitempty"`
+	EditMode            *string                  `json:"editMode,omitempty" validate:"omitempty,oneof=readOnly writable"`
+	SyncIntervalSeconds *int64                   `json:"syncIntervalSeconds,omitempty" validate:"omitempty,min=0"`
+}
+
+type SyncLdapProviderResponseDto struct {
+	UsersCreated       int `json:"usersCreated"`
+	UsersUpdated       int `json:"usersUpdated"`
+	UsersSkipped       int `json:"usersSkipped"`
+	GroupsCreated      int `json:"groupsCreated"`
+	MembershipsAdded   int `json:"membershipsAdded"`
+	MembershipsRemoved int `json:"membershipsRemoved"`
+}
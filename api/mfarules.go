@@ -0,0 +1,19 @@
+package api
+
+import "github.com/google/uuid"
+
+// MfaRuleDto requires or skips the second factor for logins that match all
+// of its conditions, conditions that are left out match every login.
+type MfaRuleDto struct {
+	Action    string      `json:"action" validate:"required,oneof=require skip"`
+	Projects  []string    `json:"projects"`
+	Cidrs     []string    `json:"cidrs" validate:"dive,cidr"`
+	RoleIds   []uuid.UUID `json:"roleIds"`
+	NewDevice bool        `json:"newDevice"`
+}
+
+// MfaRulesDto lists the MFA rules of a virtual server in the order they are
+// evaluated, the first matching rule decides.
+type MfaRulesDto struct {
+	Rules []MfaRuleDto `json:"rules" validate:"dive"`
+}
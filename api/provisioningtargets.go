@@ -0,0 +1,57 @@
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SetProvisioningTargetRequestDto struct {
+	Url          string  `json:"url" validate:"required,url"`
+	AuthType     string  `json:"authType" validate:"required,oneof=bearer basic"`
+	AuthUsername *string `json:"authUsername,omitempty" validate:"omitempty,min=1"`
+	// AuthSecret is the bearer token or the basic auth password. It may be
+	// omitted when updating a target to keep the current one.
+	AuthSecret *string `json:"authSecret,omitempty" validate:"omitempty,min=1"`
+	// AttributeMapping maps SCIM attribute paths to the user attributes id,
+	// username, displayName, email, active and roles.
+	AttributeMapping map[string]string `json:"attributeMapping,omitempty"`
+	Enabled          bool              `json:"enabled"`
+}
+
+type SetProvisioningTargetResponseDto struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type GetProvisioningTargetResponseDto struct {
+	Id               uuid.UUID         `json:"id"`
+	Url              string            `json:"url"`
+	AuthType         string            `json:"authType"`
+	AuthUsername     *string           `json:"authUsername,omitempty"`
+	AttributeMapping map[string]string `json:"attributeMapping"`
+	Enabled          bool              `json:"enabled"`
+	LastSyncAt       *time.Time        `json:"lastSyncAt,omitempty"`
+	LastError        *string           `json:"lastError,omitempty"`
+	LastErrorAt      *time.Time        `json:"lastErrorAt,omitempty"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+}
+
+type ResyncProvisioningTargetResponseDto struct {
+	QueuedUsers int `json:"queuedUsers"`
+}
+
+type ListProvisioningTargetsResponseDto struct {
+	Items []ListProvisioningTargetsResponseItemDto `json:"items"`
+}
+
+type ListProvisioningTargetsResponseItemDto struct {
+	Id              uuid.UUID  `json:"id"`
+	ApplicationId   uuid.UUID  `json:"applicationId"`
+	ApplicationName string     `json:"applicationName"`
+	Url             string     `json:"url"`
+	Enabled         bool       `json:"enabled"`
+	LastSyncAt      *time.Time `json:"lastSyncAt,omitempty"`
+	LastError       *string    `json:"lastError,omitempty"`
+	LastErrorAt     *time.Time `json:"lastErrorAt,omitempty"`
+}
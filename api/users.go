@@ -13,6 +13,15 @@ type RegisterUserRequestDto struct {
 	Email       string `json:"email" validate:"required"`
 }
 
+type ForgotPasswordRequestDto struct {
+	UsernameOrEmail string `json:"usernameOrEmail" validate:"required,max=255"`
+}
+
+type ResetPasswordRequestDto struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
 type CreateUserRequestDto struct {
 	Username      string                       `json:"username" validate:"required"`
 	DisplayName   string                       `json:"displayName" validate:"required"`
@@ -99,10 +108,17 @@ type PasskeyCreateChallengeResponseDto struct {
 	UserId      uuid.UUID `json:"userId"`
 	Username    string    `json:"username"`
 	DisplayName string    `json:"displayName"`
+	// RpId, Attestation and UserVerification are passed on to
+	// navigator.credentials.create(), they follow the passkey policy of
+	// the virtual server.
+	RpId             string `json:"rpId"`
+	Attestation      string `json:"attestation"`
+	UserVerification string `json:"userVerification"`
 }
 
 type PasskeyValidateChallengeRequestDto struct {
 	Id               uuid.UUID `json:"id" validate:"required"`
+	Name             string    `json:"name" validate:"max=255"`
 	WebauthnResponse struct {
 		Id       string `json:"id"`
 		RawId    string `json:"rawId"`
@@ -120,9 +136,71 @@ type PasskeyValidateChallengeRequestDto struct {
 }
 
 type ListPasskeyResponseDto struct {
-	Id uuid.UUID `json:"id"`
+	Id         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	SignCount  uint32     `json:"signCount"`
+
+	Aaguid            uuid.UUID `json:"aaguid"`
+	AttestationFormat string    `json:"attestationFormat"`
+}
+
+type PatchPasskeyRequestDto struct {
+	Name *string `json:"name" validate:"omitempty,min=1,max=255"`
+}
+
+type StartPhoneRegistrationRequestDto struct {
+	// PhoneNumber is in E.164 format, e.g. +4915112345678
+	PhoneNumber string `json:"phoneNumber" validate:"required,e164"`
+}
+
+type FinishPhoneRegistrationRequestDto struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type ListTotpResponseDto struct {
+	Id        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PagedListTotpResponseDto struct {
+	Items []ListTotpResponseDto `json:"items"`
+}
+
+type ListTrustedDeviceResponseDto struct {
+	Id         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+type PagedListTrustedDeviceResponseDto struct {
+	Items []ListTrustedDeviceResponseDto `json:"items"`
+}
+
+type PatchTotpRequestDto struct {
+	Name *string `json:"name" validate:"omitempty,min=1,max=255"`
+}
+
+type RegenerateRecoveryCodesResponseDto struct {
+	// RecoveryCodes replace all previous codes, each works once in place
+	// of a TOTP code.
+	RecoveryCodes []string `json:"recoveryCodes"`
 }
 
 type PagedListPasskeyResponseDto struct {
 	Items []ListPasskeyResponseDto `json:"items"`
 }
+
+// GetUserLockoutResponseDto describes the failed password attempts of a
+// user. lockedUntil is only set for temporary lockouts.
+type GetUserLockoutResponseDto struct {
+	Locked         bool       `json:"locked"`
+	Permanent      bool       `json:"permanent"`
+	LockedUntil    *time.Time `json:"lockedUntil"`
+	FailedAttempts int        `json:"failedAttempts"`
+	Lockouts       int        `json:"lockouts"`
+}
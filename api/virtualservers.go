@@ -57,8 +57,8 @@ type CreateVirtualServerRequestDto struct {
 	Name                        string   `json:"name" validate:"required,min=1,max=255,alphanum"`
 	DisplayName                 string   `json:"displayName" validate:"required,min=1,max=255"`
 	EnableRegistration          bool     `json:"enableRegistration"`
-	PrimarySigningAlgorithm     *string  `json:"primarySigningAlgorithm" validate:"omitempty,oneof=RS256 EdDSA"`
-	AdditionalSigningAlgorithms []string `json:"additionalSigningAlgorithms" validate:"omitempty,dive,oneof=RS256 EdDSA"`
+	PrimarySigningAlgorithm     *string  `json:"primarySigningAlgorithm" validate:"omitempty,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+	AdditionalSigningAlgorithms []string `json:"additionalSigningAlgorithms" validate:"omitempty,dive,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
 	Require2fa                  bool     `json:"require2fa"`
 
 	Admin        *CreateVirtualServerRequestDtoAdminDto        `json:"admin"`
@@ -67,16 +67,51 @@ type CreateVirtualServerRequestDto struct {
 }
 
 type GetVirtualServerResponseDto struct {
-	Id                          uuid.UUID `json:"id"`
-	Name                        string    `json:"name"`
-	DisplayName                 string    `json:"displayName"`
-	RegistrationEnabled         bool      `json:"registrationEnabled"`
-	Require2fa                  bool      `json:"require2fa"`
-	RequireEmailVerification    bool      `json:"requireEmailVerification"`
-	PrimarySigningAlgorithm     string    `json:"primarySigningAlgorithm"`
-	AdditionalSigningAlgorithms []string  `json:"additionalSigningAlgorithms"`
-	CreatedAt                   time.Time `json:"createdAt"`
-	UpdatedAt                   time.Time `json:"updatedAt"`
+	Id                          uuid.UUID            `json:"id"`
+	Name                        string               `json:"name"`
+	DisplayName                 string               `json:"displayName"`
+	RegistrationEnabled         bool                 `json:"registrationEnabled"`
+	Require2fa                  bool                 `json:"require2fa"`
+	RequireEmailVerification    bool                 `json:"requireEmailVerification"`
+	EnableEmailLogin            bool                 `json:"enableEmailLogin"`
+	TrustedDeviceDays           int                  `json:"trustedDeviceDays"`
+	PrimarySigningAlgorithm     string               `json:"primarySigningAlgorithm"`
+	AdditionalSigningAlgorithms []string             `json:"additionalSigningAlgorithms"`
+	KeyRotation                 KeyRotationPolicyDto `json:"keyRotation"`
+	Passkeys                    PasskeyPolicyDto     `json:"passkeys"`
+	Lockout                     LockoutPolicyDto     `json:"lockout"`
+	CreatedAt                   time.Time            `json:"createdAt"`
+	UpdatedAt                   time.Time            `json:"updatedAt"`
+}
+
+// KeyRotationPolicyDto describes when signing keys are replaced. A key signs
+// tokens for rotateAfterSeconds and is published until expireAfterSeconds.
+// Its successor is published prePublishLeadSeconds before it takes over.
+type KeyRotationPolicyDto struct {
+	RotateAfterSeconds    int64 `json:"rotateAfterSeconds"`
+	ExpireAfterSeconds    int64 `json:"expireAfterSeconds"`
+	PrePublishLeadSeconds int64 `json:"prePublishLeadSeconds"`
+}
+
+// PasskeyPolicyDto restricts the authenticators passkeys can be registered
+// with. A non-empty allowedAaguids requires a verified attestation as well.
+type PasskeyPolicyDto struct {
+	AllowedAaguids     []uuid.UUID `json:"allowedAaguids"`
+	RequireAttestation bool        `json:"requireAttestation"`
+	UserVerification   string      `json:"userVerification"`
+}
+
+// LockoutPolicyDto describes when failed password attempts lock a user or
+// throttle a source IP, zero thresholds disable the respective limit. The
+// lockout duration doubles with every lockout up to maxLockoutDurationSeconds,
+// the permanentLockoutAfter-th lockout has to be lifted by an admin.
+type LockoutPolicyDto struct {
+	MaxFailedAttempts         int   `json:"maxFailedAttempts"`
+	MaxFailedAttemptsPerIp    int   `json:"maxFailedAttemptsPerIp"`
+	LockoutDurationSeconds    int64 `json:"lockoutDurationSeconds"`
+	MaxLockoutDurationSeconds int64 `json:"maxLockoutDurationSeconds"`
+	PermanentLockoutAfter     int   `json:"permanentLockoutAfter"`
+	NotifyUser                bool  `json:"notifyUser"`
 }
 
 type GetVirtualServerListResponseDto struct {
@@ -91,7 +126,88 @@ type PatchVirtualServerRequestDto struct {
 	EnableRegistration       *bool `json:"enableRegistration"`
 	Require2fa               *bool `json:"require2fa"`
 	RequireEmailVerification *bool `json:"requireEmailVerification"`
+	EnableEmailLogin         *bool `json:"enableEmailLogin"`
+	TrustedDeviceDays        *int  `json:"trustedDeviceDays" validate:"omitempty,min=0,max=365"`
+
+	PrimarySigningAlgorithm     *string   `json:"primarySigningAlgorithm" validate:"omitempty,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+	AdditionalSigningAlgorithms *[]string `json:"additionalSigningAlgorithms" validate:"omitempty,dive,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+
+	KeyRotation *PatchKeyRotationPolicyDto `json:"keyRotation"`
+	Passkeys    *PatchPasskeyPolicyDto     `json:"passkeys"`
+	Lockout     *PatchLockoutPolicyDto     `json:"lockout"`
+}
+
+type PatchKeyRotationPolicyDto struct {
+	RotateAfterSeconds    *int64 `json:"rotateAfterSeconds" validate:"omitempty,min=1"`
+	ExpireAfterSeconds    *int64 `json:"expireAfterSeconds" validate:"omitempty,min=1"`
+	PrePublishLeadSeconds *int64 `json:"prePublishLeadSeconds" validate:"omitempty,min=0"`
+}
+
+type PatchPasskeyPolicyDto struct {
+	AllowedAaguids     *[]uuid.UUID `json:"allowedAaguids"`
+	RequireAttestation *bool        `json:"requireAttestation"`
+	UserVerification   *string      `json:"userVerification" validate:"omitempty,oneof=required preferred discouraged"`
+}
+
+type PatchLockoutPolicyDto struct {
+	MaxFailedAttempts         *int   `json:"maxFailedAttempts" validate:"omitempty,min=0"`
+	MaxFailedAttemptsPerIp    *int   `json:"maxFailedAttemptsPerIp" validate:"omitempty,min=0"`
+	LockoutDurationSeconds    *int64 `json:"lockoutDurationSeconds" validate:"omitempty,min=1"`
+	MaxLockoutDurationSeconds *int64 `json:"maxLockoutDurationSeconds" validate:"omitempty,min=1"`
+	PermanentLockoutAfter     *int   `json:"permanentLockoutAfter" validate:"omitempty,min=0"`
+	NotifyUser                *bool  `json:"notifyUser"`
+}
+
+type ListSigningKeysResponseDto struct {
+	Items []ListSigningKeysResponseItemDto `json:"items"`
+}
+
+type ListSigningKeysResponseItemDto struct {
+	Kid       string `json:"kid"`
+	Algorithm string `json:"algorithm"`
+	// State is next for pre-published keys, active for the key that signs
+	// tokens and retired for keys that are only published for verification.
+	State       string    `json:"state"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ActivatesAt time.Time `json:"activatesAt"`
+	RotatesAt   time.Time `json:"rotatesAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	VerifyOnly  bool      `json:"verifyOnly"`
+}
+
+type RotateSigningKeysRequestDto struct {
+	// Algorithm restricts the rotation to one algorithm. All configured
+	// algorithms are rotated when it is omitted.
+	Algorithm *string `json:"algorithm,omitempty" validate:"omitempty,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+}
+
+type RotateSigningKeysResponseDto struct {
+	Keys []RotateSigningKeysResponseKeyDto `json:"keys"`
+}
+
+type RotateSigningKeysResponseKeyDto struct {
+	Algorithm string `json:"algorithm"`
+	Kid       string `json:"kid"`
+}
+
+type RevokeSigningKeyResponseDto struct {
+	ReplacementKid *string `json:"replacementKid,omitempty"`
+}
+
+type ImportSigningKeyRequestDto struct {
+	Kid       string `json:"kid" validate:"required"`
+	Algorithm string `json:"algorithm" validate:"required,oneof=RS256 RS384 RS512 PS256 ES256 ES384 ES512 EdDSA"`
+	// PrivateKey is a PEM encoded private key (PKCS #1, SEC 1 or PKCS #8)
+	// or a private JWK.
+	PrivateKey string `json:"privateKey" validate:"required"`
+	// NotBefore defaults to the time of the import.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  time.Time  `json:"notAfter" validate:"required"`
+	// VerifyOnly keys are published in the JWKS but never sign tokens.
+	VerifyOnly bool `json:"verifyOnly"`
+}
 
-	PrimarySigningAlgorithm     *string   `json:"primarySigningAlgorithm" validate:"omitempty,oneof=RS256 EdDSA"`
-	AdditionalSigningAlgorithms *[]string `json:"additionalSigningAlgorithms" validate:"omitempty,dive,oneof=RS256 EdDSA"`
+type ImportSigningKeyResponseDto struct {
+	Kid   string `json:"kid"`
+	State string `json:"state"`
 }
@@ -25,6 +25,11 @@ type UserClient interface {
 	CreateServiceUser(ctx context.Context, username string) (uuid.UUID, error)
 	AssociateServiceUserPublicKey(ctx context.Context, serviceUserID uuid.UUID, dto api.AssociateServiceUserPublicKeyRequestDto) (api.AssociateServiceUserPublicKeyResponseDto, error)
 	RemoveServiceUserPublicKey(ctx context.Context, serviceUserID uuid.UUID, kid string) error
+	ListPasskeys(ctx context.Context, userId uuid.UUID) (api.PagedListPasskeyResponseDto, error)
+	PatchPasskey(ctx context.Context, userId uuid.UUID, passkeyId uuid.UUID, dto api.PatchPasskeyRequestDto) error
+	DeletePasskey(ctx context.Context, userId uuid.UUID, passkeyId uuid.UUID) error
+	GetLockout(ctx context.Context, userId uuid.UUID) (api.GetUserLockoutResponseDto, error)
+	Unlock(ctx context.Context, userId uuid.UUID) error
 }
 
 func NewUserClient(transport *Transport) UserClient {
@@ -200,3 +205,95 @@ func (c *userClient) RemoveServiceUserPublicKey(ctx context.Context, serviceUser
 	defer response.Body.Close() //nolint:errcheck
 	return nil
 }
+
+func (c *userClient) ListPasskeys(ctx context.Context, userId uuid.UUID) (api.PagedListPasskeyResponseDto, error) {
+	endpoint := fmt.Sprintf("/users/%s/passkeys", userId)
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return api.PagedListPasskeyResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.PagedListPasskeyResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.PagedListPasskeyResponseDto
+	if err := json.NewDecoder(response.Body).Decode(&responseDto); err != nil {
+		return api.PagedListPasskeyResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
+
+func (c *userClient) PatchPasskey(ctx context.Context, userId uuid.UUID, passkeyId uuid.UUID, dto api.PatchPasskeyRequestDto) error {
+	jsonBytes, err := json.Marshal(dto)
+	if err != nil {
+		return fmt.Errorf("marshaling dto: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("/users/%s/passkeys/%s", userId, passkeyId)
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodPatch, endpoint, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+	return nil
+}
+
+func (c *userClient) DeletePasskey(ctx context.Context, userId uuid.UUID, passkeyId uuid.UUID) error {
+	endpoint := fmt.Sprintf("/users/%s/passkeys/%s", userId, passkeyId)
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+	return nil
+}
+
+func (c *userClient) GetLockout(ctx context.Context, userId uuid.UUID) (api.GetUserLockoutResponseDto, error) {
+	endpoint := fmt.Sprintf("/users/%s/lockout", userId)
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return api.GetUserLockoutResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.GetUserLockoutResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.GetUserLockoutResponseDto
+	if err := json.NewDecoder(response.Body).Decode(&responseDto); err != nil {
+		return api.GetUserLockoutResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
+
+func (c *userClient) Unlock(ctx context.Context, userId uuid.UUID) error {
+	endpoint := fmt.Sprintf("/users/%s/lockout", userId)
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
@@ -248,3 +249,136 @@ func (s *UserClientSuite) TestPatchUser_HappyPath() {
 	// assert
 	s.Require().NoError(err)
 }
+
+func (s *UserClientSuite) TestListPasskeys_HappyPath() {
+	// arrange
+	userId := uuid.New()
+	response := api.PagedListPasskeyResponseDto{
+		Items: []api.ListPasskeyResponseDto{
+			{Id: uuid.New(), Name: "Laptop", SignCount: 3},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodGet, r.Method)
+		s.Equal(fmt.Sprintf("/api/virtual-servers/test/users/%s/passkeys", userId), r.URL.Path)
+
+		err := json.NewEncoder(w).Encode(response)
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").User()
+
+	// act
+	responseDto, err := testee.ListPasskeys(s.T().Context(), userId)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(response.Items[0].Id, responseDto.Items[0].Id)
+	s.Equal("Laptop", responseDto.Items[0].Name)
+}
+
+func (s *UserClientSuite) TestPatchPasskey_HappyPath() {
+	// arrange
+	userId := uuid.New()
+	passkeyId := uuid.New()
+	request := api.PatchPasskeyRequestDto{
+		Name: utils.Ptr("Phone"),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPatch, r.Method)
+		s.Equal(fmt.Sprintf("/api/virtual-servers/test/users/%s/passkeys/%s", userId, passkeyId), r.URL.Path)
+
+		var requestDto api.PatchPasskeyRequestDto
+		err := json.NewDecoder(r.Body).Decode(&requestDto)
+		s.NoError(err)
+		s.Equal(request, requestDto)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").User()
+
+	// act
+	err := testee.PatchPasskey(s.T().Context(), userId, passkeyId, request)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *UserClientSuite) TestDeletePasskey_HappyPath() {
+	// arrange
+	userId := uuid.New()
+	passkeyId := uuid.New()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodDelete, r.Method)
+		s.Equal(fmt.Sprintf("/api/virtual-servers/test/users/%s/passkeys/%s", userId, passkeyId), r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").User()
+
+	// act
+	err := testee.DeletePasskey(s.T().Context(), userId, passkeyId)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *UserClientSuite) TestGetLockout_HappyPath() {
+	// arrange
+	userId := uuid.New()
+	lockedUntil := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+
+	response := api.GetUserLockoutResponseDto{
+		Locked:      true,
+		LockedUntil: &lockedUntil,
+		Lockouts:    1,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodGet, r.Method)
+		s.Equal(fmt.Sprintf("/api/virtual-servers/test/users/%s/lockout", userId), r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").User()
+
+	// act
+	responseDto, err := testee.GetLockout(s.T().Context(), userId)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(response.Locked, responseDto.Locked)
+	s.Equal(response.Lockouts, responseDto.Lockouts)
+	s.Require().NotNil(responseDto.LockedUntil)
+	s.True(lockedUntil.Equal(*responseDto.LockedUntil))
+}
+
+func (s *UserClientSuite) TestUnlock_HappyPath() {
+	// arrange
+	userId := uuid.New()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodDelete, r.Method)
+		s.Equal(fmt.Sprintf("/api/virtual-servers/test/users/%s/lockout", userId), r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").User()
+
+	// act
+	err := testee.Unlock(s.T().Context(), userId)
+
+	// assert
+	s.Require().NoError(err)
+}
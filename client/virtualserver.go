@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/The127/Keyline/api"
 	"net/http"
+	"net/url"
 )
 
 // PatchVirtualServerInput holds the fields that can be patched on a virtual server.
@@ -15,8 +16,14 @@ type PatchVirtualServerInput struct {
 	EnableRegistration          *bool     `json:"enableRegistration"`
 	Require2fa                  *bool     `json:"require2fa"`
 	RequireEmailVerification    *bool     `json:"requireEmailVerification"`
+	EnableEmailLogin            *bool     `json:"enableEmailLogin"`
+	TrustedDeviceDays           *int      `json:"trustedDeviceDays,omitempty"`
 	PrimarySigningAlgorithm     *string   `json:"primarySigningAlgorithm,omitempty"`
 	AdditionalSigningAlgorithms *[]string `json:"additionalSigningAlgorithms,omitempty"`
+
+	KeyRotation *api.PatchKeyRotationPolicyDto `json:"keyRotation,omitempty"`
+	Passkeys    *api.PatchPasskeyPolicyDto     `json:"passkeys,omitempty"`
+	Lockout     *api.PatchLockoutPolicyDto     `json:"lockout,omitempty"`
 }
 
 type VirtualServerClient interface {
@@ -24,6 +31,10 @@ type VirtualServerClient interface {
 	Get(ctx context.Context) (api.GetVirtualServerResponseDto, error)
 	GetPublicInfo(ctx context.Context) (api.GetVirtualServerListResponseDto, error)
 	Patch(ctx context.Context, input PatchVirtualServerInput) error
+	ListSigningKeys(ctx context.Context) (api.ListSigningKeysResponseDto, error)
+	RotateSigningKeys(ctx context.Context, dto api.RotateSigningKeysRequestDto) (api.RotateSigningKeysResponseDto, error)
+	RevokeSigningKey(ctx context.Context, kid string) (api.RevokeSigningKeyResponseDto, error)
+	ImportSigningKey(ctx context.Context, dto api.ImportSigningKeyRequestDto) (api.ImportSigningKeyResponseDto, error)
 }
 
 func NewVirtualServerClient(transport *Transport) VirtualServerClient {
@@ -122,3 +133,105 @@ func (c *virtualServerClient) Patch(ctx context.Context, dto PatchVirtualServerI
 
 	return nil
 }
+
+func (c *virtualServerClient) ListSigningKeys(ctx context.Context) (api.ListSigningKeysResponseDto, error) {
+	endpoint := "/keys"
+
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return api.ListSigningKeysResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.ListSigningKeysResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.ListSigningKeysResponseDto
+	err = json.NewDecoder(response.Body).Decode(&responseDto)
+	if err != nil {
+		return api.ListSigningKeysResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
+
+func (c *virtualServerClient) RotateSigningKeys(ctx context.Context, dto api.RotateSigningKeysRequestDto) (api.RotateSigningKeysResponseDto, error) {
+	endpoint := "/keys/rotate"
+
+	jsonBytes, err := json.Marshal(dto)
+	if err != nil {
+		return api.RotateSigningKeysResponseDto{}, fmt.Errorf("marshaling dto: %w", err)
+	}
+
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return api.RotateSigningKeysResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.RotateSigningKeysResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.RotateSigningKeysResponseDto
+	err = json.NewDecoder(response.Body).Decode(&responseDto)
+	if err != nil {
+		return api.RotateSigningKeysResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
+
+func (c *virtualServerClient) RevokeSigningKey(ctx context.Context, kid string) (api.RevokeSigningKeyResponseDto, error) {
+	endpoint := fmt.Sprintf("/keys/%s/revoke", url.PathEscape(kid))
+
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return api.RevokeSigningKeyResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.RevokeSigningKeyResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.RevokeSigningKeyResponseDto
+	err = json.NewDecoder(response.Body).Decode(&responseDto)
+	if err != nil {
+		return api.RevokeSigningKeyResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
+
+func (c *virtualServerClient) ImportSigningKey(ctx context.Context, dto api.ImportSigningKeyRequestDto) (api.ImportSigningKeyResponseDto, error) {
+	endpoint := "/keys/import"
+
+	jsonBytes, err := json.Marshal(dto)
+	if err != nil {
+		return api.ImportSigningKeyResponseDto{}, fmt.Errorf("marshaling dto: %w", err)
+	}
+
+	request, err := c.transport.NewTenantRequest(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return api.ImportSigningKeyResponseDto{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := c.transport.Do(request)
+	if err != nil {
+		return api.ImportSigningKeyResponseDto{}, fmt.Errorf("doing request: %w", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	var responseDto api.ImportSigningKeyResponseDto
+	err = json.NewDecoder(response.Body).Decode(&responseDto)
+	if err != nil {
+		return api.ImportSigningKeyResponseDto{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return responseDto, nil
+}
@@ -192,3 +192,124 @@ func (s *VirtualServerClientSuite) TestGet_ReturnsSigningAlgorithms() {
 	s.Equal("EdDSA", responseDto.PrimarySigningAlgorithm)
 	s.Equal([]string{"RS256"}, responseDto.AdditionalSigningAlgorithms)
 }
+
+func (s *VirtualServerClientSuite) TestListSigningKeys_HappyPath() {
+	// arrange
+	response := api.ListSigningKeysResponseDto{
+		Items: []api.ListSigningKeysResponseItemDto{
+			{Kid: "next", Algorithm: "EdDSA", State: "next"},
+			{Kid: "active", Algorithm: "EdDSA", State: "active"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodGet, r.Method)
+		s.Equal("/api/virtual-servers/test/keys", r.URL.Path)
+
+		err := json.NewEncoder(w).Encode(response)
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").VirtualServer()
+
+	// act
+	responseDto, err := testee.ListSigningKeys(s.T().Context())
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(response, responseDto)
+}
+
+func (s *VirtualServerClientSuite) TestRotateSigningKeys_HappyPath() {
+	// arrange
+	request := api.RotateSigningKeysRequestDto{
+		Algorithm: utils.Ptr("RS256"),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("/api/virtual-servers/test/keys/rotate", r.URL.Path)
+
+		var requestDto api.RotateSigningKeysRequestDto
+		err := json.NewDecoder(r.Body).Decode(&requestDto)
+		s.NoError(err)
+		s.Equal(request, requestDto)
+
+		err = json.NewEncoder(w).Encode(api.RotateSigningKeysResponseDto{
+			Keys: []api.RotateSigningKeysResponseKeyDto{{Algorithm: "RS256", Kid: "kid"}},
+		})
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").VirtualServer()
+
+	// act
+	responseDto, err := testee.RotateSigningKeys(s.T().Context(), request)
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(responseDto.Keys, 1)
+	s.Equal("kid", responseDto.Keys[0].Kid)
+}
+
+func (s *VirtualServerClientSuite) TestRevokeSigningKey_HappyPath() {
+	// arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("/api/virtual-servers/test/keys/kid/revoke", r.URL.Path)
+
+		err := json.NewEncoder(w).Encode(api.RevokeSigningKeyResponseDto{
+			ReplacementKid: utils.Ptr("replacement"),
+		})
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").VirtualServer()
+
+	// act
+	responseDto, err := testee.RevokeSigningKey(s.T().Context(), "kid")
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(utils.Ptr("replacement"), responseDto.ReplacementKid)
+}
+
+func (s *VirtualServerClientSuite) TestImportSigningKey_HappyPath() {
+	// arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("/api/virtual-servers/test/keys/import", r.URL.Path)
+
+		var dto api.ImportSigningKeyRequestDto
+		err := json.NewDecoder(r.Body).Decode(&dto)
+		s.NoError(err)
+		s.Equal("legacy", dto.Kid)
+		s.True(dto.VerifyOnly)
+
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(api.ImportSigningKeyResponseDto{
+			Kid:   dto.Kid,
+			State: "retired",
+		})
+		s.NoError(err)
+	}))
+	defer server.Close()
+
+	testee := NewClient(server.URL, "test").VirtualServer()
+
+	// act
+	responseDto, err := testee.ImportSigningKey(s.T().Context(), api.ImportSigningKeyRequestDto{
+		Kid:        "legacy",
+		Algorithm:  "RS256",
+		PrivateKey: "pem",
+		VerifyOnly: true,
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal("legacy", responseDto.Kid)
+	s.Equal("retired", responseDto.State)
+}
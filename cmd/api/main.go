@@ -11,6 +11,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/authentication"
@@ -70,11 +71,14 @@ func main() {
 	setup.OutboxDelivery(dc, config.QueueModeInProcess)
 	setup.KeyServices(dc, config.C.KeyStore)
 	setup.Caching(dc, config.C.Cache.Mode)
+	setup.Sms(dc, config.C.Sms)
+	setup.BreachedPasswords(dc, config.C.BreachedPasswords)
 	setup.Services(dc)
 	setup.Mediator(dc)
 	dp := dc.BuildProvider()
 
 	initApplication(dp)
+	importSigningKeys(dp)
 
 	var jobManager jobs.JobManager
 	leaderElection := quorum.NewLeaderElectionFactory().
@@ -98,6 +102,13 @@ func main() {
 					jobs.WithStartImmediate(),
 				)
 
+				jobManager.QueueJob(
+					jobs.LdapSyncJob(),
+					time.Minute,
+					jobs.WithName("ldap_sync"),
+					jobs.WithStartImmediate(),
+				)
+
 				logging.Logger.Info("Starting job manager")
 				jobManager.Start(middlewares.ContextWithScope(context.Background(), dp))
 			} else {
@@ -235,6 +246,49 @@ func initApplication(dp *ioc.DependencyProvider) {
 	}
 }
 
+// importSigningKeys imports the keys listed in the key store config. Keys
+// whose kid is already known were imported on an earlier startup.
+func importSigningKeys(dp *ioc.DependencyProvider) {
+	scope := dp.NewScope()
+	defer utils.PanicOnError(scope.Close, "failed creating scope to import signing keys")
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	now := ioc.GetDependency[clock.Service](scope).Now()
+
+	for _, key := range config.C.KeyStore.Import {
+		notBefore, notAfter, err := key.Validity()
+		if err != nil {
+			logging.Logger.Fatalf("invalid validity of imported key %s: %v", key.Kid, err)
+		}
+
+		if !notAfter.After(now) {
+			logging.Logger.Infof("skipping import of expired key %s", key.Kid)
+			continue
+		}
+
+		_, err = mediatr.Send[*commands.ImportSigningKeyResponse](ctx, m, commands.ImportSigningKey{
+			VirtualServerName: key.VirtualServer,
+			Kid:               key.Kid,
+			Algorithm:         key.Algorithm,
+			PrivateKey:        key.PrivateKey,
+			NotBefore:         notBefore,
+			NotAfter:          notAfter,
+			VerifyOnly:        key.VerifyOnly,
+		})
+		switch {
+		case errors.Is(err, utils.ErrSigningKeyExists):
+			continue
+
+		case err != nil:
+			logging.Logger.Fatalf("failed to import signing key %s: %v", key.Kid, err)
+		}
+
+		logging.Logger.Infof("imported signing key %s for virtual server %s", key.Kid, key.VirtualServer)
+	}
+}
+
 func configureSwaggerFromConfig() {
 	if config.C.Server.ExternalUrl != "" {
 		if u, err := url.Parse(config.C.Server.ExternalUrl); err == nil {
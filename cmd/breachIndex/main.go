@@ -0,0 +1,44 @@
+// breachIndex builds the index file of the offline breached password lookup
+// from a list of sha-1 hashes with their breach counts, one "HASH:COUNT" per
+// line and sorted by hash, like the downloads of Have I Been Pwned.
+//
+//	go run ./cmd/breachIndex pwnedpasswords.txt breached-passwords.idx
+package main
+
+import (
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/services"
+	"os"
+)
+
+func main() {
+	logging.Init()
+
+	if len(os.Args) != 3 {
+		logging.Logger.Fatalf("usage: %s <hash list> <index file>", os.Args[0])
+	}
+
+	hashList, err := os.Open(os.Args[1])
+	if err != nil {
+		logging.Logger.Fatalf("failed to open hash list: %v", err)
+	}
+	defer hashList.Close() //nolint:errcheck
+
+	index, err := os.Create(os.Args[2])
+	if err != nil {
+		logging.Logger.Fatalf("failed to create index file: %v", err)
+	}
+
+	records, err := services.WriteBreachIndex(hashList, index)
+	if err != nil {
+		_ = index.Close()
+		logging.Logger.Fatalf("failed to write index: %v", err)
+	}
+
+	err = index.Close()
+	if err != nil {
+		logging.Logger.Fatalf("failed to close index file: %v", err)
+	}
+
+	logging.Logger.Infof("wrote %d records to %s", records, os.Args[2])
+}
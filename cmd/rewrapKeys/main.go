@@ -0,0 +1,58 @@
+// rewrapKeys re-wraps the data keys of all signing keys in the database key
+// store with the current master key. Run it after rotating the master key,
+// with the old master key still listed in keyStore.database.previousMasterKeys.
+package main
+
+import (
+	"context"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/retry"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/internal/setup"
+
+	"github.com/The127/ioc"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+func main() {
+	config.Init()
+
+	sqlbuilder.DefaultFlavor = sqlbuilder.PostgreSQL
+
+	logging.Init()
+
+	if config.C.KeyStore.Mode != config.KeyStoreModeDatabase {
+		logging.Logger.Fatalf("key store mode is %s, re-wrapping keys requires %s", config.C.KeyStore.Mode, config.KeyStoreModeDatabase)
+	}
+
+	dc := ioc.NewDependencyCollection()
+
+	db, err := setup.Database(dc, config.C.Database)
+	if err != nil {
+		logging.Logger.Fatalf("failed to connect to database: %v", err)
+	}
+
+	retry.FiveTimes(func() error {
+		return db.Migrate(context.TODO())
+	}, "failed to migrate database")
+
+	dp := dc.BuildProvider()
+
+	encryption, err := services.NewKeyEncryption(
+		config.C.KeyStore.Database.MasterKey,
+		config.C.KeyStore.Database.PreviousMasterKeys,
+	)
+	if err != nil {
+		logging.Logger.Fatalf("failed to create key encryption: %v", err)
+	}
+
+	rewrapped, err := services.RewrapSigningKeys(context.Background(), ioc.GetDependency[database.Factory](dp), encryption)
+	if err != nil {
+		logging.Logger.Fatalf("failed to re-wrap signing keys: %v", err)
+	}
+
+	logging.Logger.Infof("re-wrapped %d signing keys with master key %s", rewrapped, encryption.CurrentMasterKeyId())
+}
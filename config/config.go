@@ -7,7 +7,9 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env/v2"
@@ -40,25 +42,62 @@ const (
 	CacheModeRedis  CacheMode = "redis"
 )
 
-// KeyStoreMode has the following constants: KeyStoreModeMemory (testing only), KeyStoreModeDirectory, KeyStoreModeOpenBao
+// KeyStoreMode has the following constants: KeyStoreModeMemory (testing only), KeyStoreModeDirectory, KeyStoreModeOpenBao, KeyStoreModeDatabase, KeyStoreModeVaultTransit, KeyStoreModePkcs11
 type KeyStoreMode string
 
 const (
 	KeyStoreModeMemory    KeyStoreMode = "memory"
 	KeyStoreModeDirectory KeyStoreMode = "directory"
 	KeyStoreModeVault     KeyStoreMode = "vault"
+	KeyStoreModeDatabase  KeyStoreMode = "database"
+	// KeyStoreModeVaultTransit signs with non-exportable Vault/OpenBao
+	// Transit keys. Only the key schedules are kept in the KV mount.
+	KeyStoreModeVaultTransit KeyStoreMode = "vault-transit"
+	// KeyStoreModePkcs11 generates and signs with non-extractable keys in
+	// an HSM through its PKCS #11 module.
+	KeyStoreModePkcs11 KeyStoreMode = "pkcs11"
+)
+
+// SmsMode has the following constants: SmsModeNone, SmsModeLog (testing only), SmsModeWebhook
+type SmsMode string
+
+const (
+	SmsModeNone    SmsMode = "none"
+	SmsModeLog     SmsMode = "log"
+	SmsModeWebhook SmsMode = "webhook"
+)
+
+// BreachedPasswordsMode has the following constants: BreachedPasswordsModeNone, BreachedPasswordsModeApi, BreachedPasswordsModeOffline
+type BreachedPasswordsMode string
+
+const (
+	BreachedPasswordsModeNone    BreachedPasswordsMode = "none"
+	BreachedPasswordsModeApi     BreachedPasswordsMode = "api"
+	BreachedPasswordsModeOffline BreachedPasswordsMode = "offline"
 )
 
 type SigningAlgorithm string
 
 const (
 	SigningAlgorithmRS256 SigningAlgorithm = "RS256"
+	SigningAlgorithmRS384 SigningAlgorithm = "RS384"
+	SigningAlgorithmRS512 SigningAlgorithm = "RS512"
+	SigningAlgorithmPS256 SigningAlgorithm = "PS256"
+	SigningAlgorithmES256 SigningAlgorithm = "ES256"
+	SigningAlgorithmES384 SigningAlgorithm = "ES384"
+	SigningAlgorithmES512 SigningAlgorithm = "ES512"
 	SigningAlgorithmEdDSA SigningAlgorithm = "EdDSA"
 )
 
 var SupportedSigningAlgorithms = []SigningAlgorithm{
 	SigningAlgorithmEdDSA,
 	SigningAlgorithmRS256,
+	SigningAlgorithmRS384,
+	SigningAlgorithmRS512,
+	SigningAlgorithmPS256,
+	SigningAlgorithmES256,
+	SigningAlgorithmES384,
+	SigningAlgorithmES512,
 }
 
 type Config struct {
@@ -80,6 +119,58 @@ type Config struct {
 		} `yaml:"redis"`
 	} `yaml:"cache"`
 	LeaderElection LeaderElectionConfig `yaml:"leaderElection"`
+	Webauthn       WebauthnConfig       `yaml:"webauthn"`
+	Sms            SmsConfig            `yaml:"sms"`
+	// BreachedPasswords is used by the breached password rule
+	BreachedPasswords BreachedPasswordsConfig `yaml:"breachedPasswords"`
+}
+
+// BreachedPasswordsConfig configures how the breached password rule looks
+// up passwords. Api queries a k-anonymity range api like the one of Have I
+// Been Pwned at Url, which can point to a local mirror. Offline looks them
+// up in IndexFile, built with cmd/breachIndex from a downloaded hash list,
+// nothing leaves the server then.
+type BreachedPasswordsConfig struct {
+	Mode BreachedPasswordsMode `yaml:"mode"`
+	Api  struct {
+		Url string `yaml:"url"`
+	} `yaml:"api"`
+	Offline struct {
+		IndexFile string `yaml:"indexFile"`
+	} `yaml:"offline"`
+}
+
+// SmsConfig configures how text messages, e.g. the codes of the SMS second
+// factor, are sent. The SMS second factor is only offered if a mode other
+// than none is configured.
+type SmsConfig struct {
+	Mode SmsMode `yaml:"mode"`
+	// Log appends the messages to File, or writes them to the log if no
+	// file is set.
+	Log struct {
+		File string `yaml:"file"`
+	} `yaml:"log"`
+	Webhook SmsWebhookConfig `yaml:"webhook"`
+}
+
+// SmsWebhookConfig posts every message as JSON with "to" and "body" to Url,
+// e.g. to a small adapter for the SMS gateway in use. Headers are added to
+// each request, e.g. for authentication.
+type SmsWebhookConfig struct {
+	Url     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// WebauthnConfig configures the trust anchors passkey attestations are
+// verified against. MdsBlobFile is a BLOB downloaded from the FIDO metadata
+// service, its signature is checked if MdsRootCertificateFile is set. Apple
+// does not publish its attestation root there, AppleRootCertificateFile
+// holds it instead. All files are optional, attestations of authenticators
+// without trust anchors are considered untrusted.
+type WebauthnConfig struct {
+	MdsBlobFile              string `yaml:"mdsBlobFile"`
+	MdsRootCertificateFile   string `yaml:"mdsRootCertificateFile"`
+	AppleRootCertificateFile string `yaml:"appleRootCertificateFile"`
 }
 
 type InitialVirtualServerConfig struct {
@@ -117,11 +208,49 @@ type ServiceUserConfig struct {
 }
 
 type KeyStoreConfig struct {
-	Mode      KeyStoreMode        `yaml:"mode"`
-	Vault     VaultKeyStoreConfig `yaml:"vault"`
+	Mode      KeyStoreMode           `yaml:"mode"`
+	Vault     VaultKeyStoreConfig    `yaml:"vault"`
+	Database  DatabaseKeyStoreConfig `yaml:"database"`
+	Pkcs11    Pkcs11KeyStoreConfig   `yaml:"pkcs11"`
 	Directory struct {
 		Path string `yaml:"path"`
 	} `yaml:"directory"`
+	// Import lists existing keys, e.g. of a previous identity provider,
+	// that are imported on startup unless their kid is already known.
+	Import []ImportedSigningKeyConfig `yaml:"import"`
+}
+
+type ImportedSigningKeyConfig struct {
+	// VirtualServer defaults to the initial virtual server.
+	VirtualServer string           `yaml:"virtualServer"`
+	Kid           string           `yaml:"kid"`
+	Algorithm     SigningAlgorithm `yaml:"algorithm"`
+	// PrivateKey is a PEM encoded private key or a private JWK.
+	PrivateKey string `yaml:"privateKey"`
+	// NotBefore and NotAfter are RFC 3339 timestamps. NotBefore defaults
+	// to the time of the import.
+	NotBefore  string `yaml:"notBefore"`
+	NotAfter   string `yaml:"notAfter"`
+	VerifyOnly bool   `yaml:"verifyOnly"`
+}
+
+// Validity parses the validity window of the key.
+func (c ImportedSigningKeyConfig) Validity() (*time.Time, time.Time, error) {
+	var notBefore *time.Time
+	if c.NotBefore != "" {
+		t, err := time.Parse(time.RFC3339, c.NotBefore)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("parsing notBefore: %w", err)
+		}
+		notBefore = &t
+	}
+
+	notAfter, err := time.Parse(time.RFC3339, c.NotAfter)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing notAfter: %w", err)
+	}
+
+	return notBefore, notAfter, nil
 }
 
 type VaultKeyStoreConfig struct {
@@ -129,6 +258,33 @@ type VaultKeyStoreConfig struct {
 	Token   string `yaml:"token"`
 	Mount   string `yaml:"mount"`
 	Prefix  string `yaml:"prefix,omitempty"`
+	// TransitMount is only used by KeyStoreModeVaultTransit, it defaults
+	// to "transit".
+	TransitMount string `yaml:"transitMount"`
+}
+
+// DatabaseKeyStoreConfig configures the master key that encrypts the
+// private keys stored in the database. Master keys are base64 encoded
+// 256-bit keys.
+type DatabaseKeyStoreConfig struct {
+	MasterKey string `yaml:"masterKey"`
+	// MasterKeyFile is read into MasterKey if MasterKey is empty.
+	MasterKeyFile string `yaml:"masterKeyFile"`
+	// PreviousMasterKeys can still decrypt keys that were not re-wrapped
+	// with the current master key yet.
+	PreviousMasterKeys []string `yaml:"previousMasterKeys"`
+}
+
+// Pkcs11KeyStoreConfig configures the token the keys are generated on.
+type Pkcs11KeyStoreConfig struct {
+	// ModulePath is the path of the PKCS #11 module of the HSM, e.g.
+	// /usr/lib/softhsm/libsofthsm2.so.
+	ModulePath string `yaml:"modulePath"`
+	Slot       uint   `yaml:"slot"`
+	Pin        string `yaml:"pin"`
+	// LabelPrefix is prepended to the labels of all objects Keyline
+	// creates on the token, it defaults to "keyline/".
+	LabelPrefix string `yaml:"labelPrefix"`
 }
 
 type LeaderElectionMode string
@@ -187,6 +343,9 @@ type ServerConfig struct {
 	Port           int      `yaml:"port"`
 	ApiPort        int      `yaml:"apiPort"`
 	AllowedOrigins []string `yaml:"allowedOrigins"`
+	// TrustedProxies are the addresses or CIDR prefixes of reverse proxies
+	// whose X-Forwarded-For header identifies the client.
+	TrustedProxies []string `yaml:"trustedProxies"`
 }
 
 type DatabaseConfig struct {
@@ -273,6 +432,54 @@ func setDefaultsOrPanic() {
 	setKeyStoreDefaultsOrPanic()
 	setCacheDefaultsOrPanic()
 	setLeaderElectionDefaultsOrPanic()
+	setSmsDefaultsOrPanic()
+	setBreachedPasswordsDefaultsOrPanic()
+}
+
+func setSmsDefaultsOrPanic() {
+	switch C.Sms.Mode {
+	case "":
+		C.Sms.Mode = SmsModeNone
+
+	case SmsModeNone:
+		// nothing to do
+
+	case SmsModeLog:
+		if IsProduction() {
+			panic("sms mode log is not supported in production")
+		}
+
+	case SmsModeWebhook:
+		if C.Sms.Webhook.Url == "" {
+			panic("missing sms webhook url")
+		}
+
+	default:
+		panic("sms mode not supported")
+	}
+}
+
+func setBreachedPasswordsDefaultsOrPanic() {
+	switch C.BreachedPasswords.Mode {
+	case "":
+		C.BreachedPasswords.Mode = BreachedPasswordsModeNone
+
+	case BreachedPasswordsModeNone:
+		// nothing to do
+
+	case BreachedPasswordsModeApi:
+		if C.BreachedPasswords.Api.Url == "" {
+			C.BreachedPasswords.Api.Url = "https://api.pwnedpasswords.com"
+		}
+
+	case BreachedPasswordsModeOffline:
+		if C.BreachedPasswords.Offline.IndexFile == "" {
+			panic("missing breached passwords index file")
+		}
+
+	default:
+		panic("breached passwords mode not supported")
+	}
 }
 
 func setLeaderElectionDefaultsOrPanic() {
@@ -368,9 +575,57 @@ func setKeyStoreDefaultsOrPanic() {
 	case KeyStoreModeDirectory:
 		setKeyStoreModeDirectoryDefaultsOrPanic()
 
+	case KeyStoreModeDatabase:
+		setKeyStoreModeDatabaseDefaultsOrPanic()
+
+	case KeyStoreModeVaultTransit:
+		setKeyStoreModeVaultDefaultsOrPanic()
+		if C.KeyStore.Vault.TransitMount == "" {
+			C.KeyStore.Vault.TransitMount = "transit"
+		}
+		if len(C.KeyStore.Import) > 0 {
+			panic("the vault-transit key store cannot import keys")
+		}
+
+	case KeyStoreModePkcs11:
+		setKeyStoreModePkcs11DefaultsOrPanic()
+
 	default:
 		panic("key store mode missing or not supported")
 	}
+
+	setImportedSigningKeysDefaultsOrPanic()
+}
+
+func setImportedSigningKeysDefaultsOrPanic() {
+	for i := range C.KeyStore.Import {
+		key := &C.KeyStore.Import[i]
+
+		if key.VirtualServer == "" {
+			key.VirtualServer = C.InitialVirtualServer.Name
+		}
+
+		if key.Kid == "" {
+			panic("missing imported key kid")
+		}
+
+		if !slices.Contains(SupportedSigningAlgorithms, key.Algorithm) {
+			panic(fmt.Sprintf("imported key %s has an unsupported algorithm", key.Kid))
+		}
+
+		if key.PrivateKey == "" {
+			panic(fmt.Sprintf("missing private key of imported key %s", key.Kid))
+		}
+
+		if key.NotAfter == "" {
+			panic(fmt.Sprintf("missing notAfter of imported key %s", key.Kid))
+		}
+
+		_, _, err := key.Validity()
+		if err != nil {
+			panic(fmt.Sprintf("invalid validity of imported key %s: %v", key.Kid, err))
+		}
+	}
 }
 
 func setKeyStoreModeVaultDefaultsOrPanic() {
@@ -393,6 +648,38 @@ func setKeyStoreModeDirectoryDefaultsOrPanic() {
 	}
 }
 
+func setKeyStoreModePkcs11DefaultsOrPanic() {
+	if C.KeyStore.Pkcs11.ModulePath == "" {
+		panic("missing pkcs11 module path")
+	}
+
+	if C.KeyStore.Pkcs11.Pin == "" {
+		panic("missing pkcs11 pin")
+	}
+
+	if C.KeyStore.Pkcs11.LabelPrefix == "" {
+		C.KeyStore.Pkcs11.LabelPrefix = "keyline/"
+	}
+
+	if len(C.KeyStore.Import) > 0 {
+		panic("the pkcs11 key store cannot import keys")
+	}
+}
+
+func setKeyStoreModeDatabaseDefaultsOrPanic() {
+	if C.KeyStore.Database.MasterKey == "" && C.KeyStore.Database.MasterKeyFile != "" {
+		masterKey, err := os.ReadFile(C.KeyStore.Database.MasterKeyFile)
+		if err != nil {
+			panic(fmt.Sprintf("reading key store master key file: %v", err))
+		}
+		C.KeyStore.Database.MasterKey = strings.TrimSpace(string(masterKey))
+	}
+
+	if C.KeyStore.Database.MasterKey == "" {
+		panic("missing key store database master key")
+	}
+}
+
 func setInitialVirtualServerDefaultsOrPanic() {
 	if C.InitialVirtualServer.Name == "" {
 		C.InitialVirtualServer.Name = "keyline"
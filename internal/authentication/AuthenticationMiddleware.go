@@ -196,7 +196,8 @@ func SystemUser() CurrentUser {
 
 func parseTokenWithVS(tokenString string, keyService services.KeyService, vsName string) (*jwt.Token, error) {
 	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		keyPair, err := keyService.GetKey(vsName, config.SigningAlgorithm(token.Header["alg"].(string)))
+		kid, _ := token.Header["kid"].(string)
+		keyPair, err := keyService.GetVerificationKey(vsName, config.SigningAlgorithm(token.Header["alg"].(string)), kid)
 		if err != nil {
 			return nil, err
 		}
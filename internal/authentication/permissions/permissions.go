@@ -14,6 +14,17 @@ const (
 
 	GroupView Permission = "group:view"
 
+	IdentityProviderCreate Permission = "identity_provider:create"
+	IdentityProviderDelete Permission = "identity_provider:delete"
+	IdentityProviderUpdate Permission = "identity_provider:update"
+	IdentityProviderView   Permission = "identity_provider:view"
+
+	LdapProviderCreate Permission = "ldap_provider:create"
+	LdapProviderDelete Permission = "ldap_provider:delete"
+	LdapProviderUpdate Permission = "ldap_provider:update"
+	LdapProviderSync   Permission = "ldap_provider:sync"
+	LdapProviderView   Permission = "ldap_provider:view"
+
 	RoleCreate Permission = "role:create"
 	RoleUpdate Permission = "role:update"
 	RoleDelete Permission = "role:delete"
@@ -23,8 +34,16 @@ const (
 	UserCreate        Permission = "user:create"
 	UserUpdate        Permission = "user:update"
 	UserResetPassword Permission = "user:reset_password"
+	UserReset2fa      Permission = "user:reset_2fa"
 	UserView          Permission = "user:view"
 
+	TotpDelete Permission = "totp:delete"
+	TotpUpdate Permission = "totp:update"
+	TotpView   Permission = "totp:view"
+
+	TrustedDeviceDelete Permission = "trusted_device:delete"
+	TrustedDeviceView   Permission = "trusted_device:view"
+
 	UserMetadataUpdate Permission = "user_metadata:update"
 	UserMetadataView   Permission = "user_metadata:view"
 
@@ -34,6 +53,8 @@ const (
 	ServiceUserAssociateKey Permission = "service_user:associate_key"
 	ServiceUserRemoveKey    Permission = "service_user:remove_key"
 
+	ScimProvision Permission = "scim:provision"
+
 	VirtualServerCreate Permission = "virtual_server:create"
 	VirtualServerUpdate Permission = "virtual_server:update"
 	VirtualServerView   Permission = "virtual_server:view"
@@ -10,6 +10,7 @@ const (
 	SystemUser         Role = "system-user"
 	SystemAdmin        Role = "system-admin"
 	VirtualServerAdmin Role = "admin"
+	ScimProvisioner    Role = "scim-provisioner"
 )
 
 var SystemUserPermissions = []permissions.Permission{
@@ -42,6 +43,17 @@ var SystemAdminPermissions = []permissions.Permission{
 
 	permissions.GroupView,
 
+	permissions.IdentityProviderCreate,
+	permissions.IdentityProviderDelete,
+	permissions.IdentityProviderUpdate,
+	permissions.IdentityProviderView,
+
+	permissions.LdapProviderCreate,
+	permissions.LdapProviderDelete,
+	permissions.LdapProviderUpdate,
+	permissions.LdapProviderSync,
+	permissions.LdapProviderView,
+
 	permissions.RoleCreate,
 	permissions.RoleUpdate,
 	permissions.RoleDelete,
@@ -51,8 +63,16 @@ var SystemAdminPermissions = []permissions.Permission{
 	permissions.UserCreate,
 	permissions.UserUpdate,
 	permissions.UserResetPassword,
+	permissions.UserReset2fa,
 	permissions.UserView,
 
+	permissions.TotpDelete,
+	permissions.TotpUpdate,
+	permissions.TotpView,
+
+	permissions.TrustedDeviceDelete,
+	permissions.TrustedDeviceView,
+
 	permissions.UserMetadataUpdate,
 	permissions.UserMetadataView,
 
@@ -90,6 +110,17 @@ var VirtualServerAdminPermissions = []permissions.Permission{
 
 	permissions.GroupView,
 
+	permissions.IdentityProviderCreate,
+	permissions.IdentityProviderDelete,
+	permissions.IdentityProviderUpdate,
+	permissions.IdentityProviderView,
+
+	permissions.LdapProviderCreate,
+	permissions.LdapProviderDelete,
+	permissions.LdapProviderUpdate,
+	permissions.LdapProviderSync,
+	permissions.LdapProviderView,
+
 	permissions.RoleCreate,
 	permissions.RoleUpdate,
 	permissions.RoleDelete,
@@ -99,8 +130,16 @@ var VirtualServerAdminPermissions = []permissions.Permission{
 	permissions.UserCreate,
 	permissions.UserUpdate,
 	permissions.UserResetPassword,
+	permissions.UserReset2fa,
 	permissions.UserView,
 
+	permissions.TotpDelete,
+	permissions.TotpUpdate,
+	permissions.TotpView,
+
+	permissions.TrustedDeviceDelete,
+	permissions.TrustedDeviceView,
+
 	permissions.UserMetadataUpdate,
 	permissions.UserMetadataView,
 
@@ -113,8 +152,16 @@ var VirtualServerAdminPermissions = []permissions.Permission{
 	permissions.TemplateView,
 }
 
+// ScimProvisionerPermissions are held by the service users of provisioning
+// systems. They are kept out of the admin roles so a SCIM token can be
+// scoped to provisioning only.
+var ScimProvisionerPermissions = []permissions.Permission{
+	permissions.ScimProvision,
+}
+
 var AllRoles = map[Role][]permissions.Permission{
 	SystemUser:         SystemUserPermissions,
 	SystemAdmin:        SystemAdminPermissions,
 	VirtualServerAdmin: VirtualServerAdminPermissions,
+	ScimProvisioner:    ScimProvisionerPermissions,
 }
@@ -48,9 +48,10 @@ func TestRolePermissionListsHaveNoDuplicates(t *testing.T) {
 	t.Parallel()
 
 	cases := map[string][]permissions.Permission{
-		"system-user":  SystemUserPermissions,
-		"system-admin": SystemAdminPermissions,
-		"admin":        VirtualServerAdminPermissions,
+		"system-user":      SystemUserPermissions,
+		"system-admin":     SystemAdminPermissions,
+		"admin":            VirtualServerAdminPermissions,
+		"scim-provisioner": ScimProvisionerPermissions,
 	}
 	for name, perms := range cases {
 		seen := make(map[permissions.Permission]struct{}, len(perms))
@@ -68,7 +69,7 @@ func TestRolePermissionListsHaveNoDuplicates(t *testing.T) {
 func TestAllRolesRegistersEveryRoleConstant(t *testing.T) {
 	t.Parallel()
 
-	for _, role := range []Role{SystemUser, SystemAdmin, VirtualServerAdmin} {
+	for _, role := range []Role{SystemUser, SystemAdmin, VirtualServerAdmin, ScimProvisioner} {
 		_, ok := AllRoles[role]
 		assert.Truef(t, ok, "role %q is defined as a constant but not registered in AllRoles", role)
 	}
@@ -214,7 +214,7 @@ func evaluatePolicy(ctx context.Context, request Policy) (PolicyResult, error) {
 	return request.IsAllowed(ctx)
 }
 
-func PermissionBasedPolicy(ctx context.Context, permission permissions.Permission) (PolicyResult, error) {
+func policyVirtualServerId(ctx context.Context) (uuid.UUID, error) {
 	virtualServerName, err := middlewares.GetVirtualServerName(ctx)
 	if err != nil {
 		virtualServerName = ""
@@ -229,12 +229,49 @@ func PermissionBasedPolicy(ctx context.Context, permission permissions.Permissio
 		virtualServerFilter := repositories.NewVirtualServerFilter().Name(virtualServerName)
 		virtualServer, err := dbContext.VirtualServers().FirstOrNil(ctx, virtualServerFilter)
 		if err != nil {
-			return PolicyResult{}, fmt.Errorf("getting virtual server: %w", err)
+			return uuid.Nil, fmt.Errorf("getting virtual server: %w", err)
 		}
 
 		virtualServerId = virtualServer.Id()
 	}
 
+	return virtualServerId, nil
+}
+
+// OwnershipOrPermissionBasedPolicy allows users to act on their own
+// resources, everyone else needs the permission.
+func OwnershipOrPermissionBasedPolicy(ctx context.Context, ownerId uuid.UUID, permission permissions.Permission) (PolicyResult, error) {
+	currentUser := authentication.GetCurrentUser(ctx)
+	if !currentUser.IsAuthenticated() || currentUser.UserId != ownerId {
+		return PermissionBasedPolicy(ctx, permission)
+	}
+
+	virtualServerId, err := policyVirtualServerId(ctx)
+	if err != nil {
+		return PolicyResult{}, err
+	}
+
+	return Allowed(currentUser.UserId, virtualServerId, NewAllowedByOwnership()), nil
+}
+
+// PreAuthenticationPolicy allows requests for users that are not signed in
+// yet, e.g. during the login. The handler has to prove who the user is, the
+// result only attributes the request to the user in the audit log.
+func PreAuthenticationPolicy(ctx context.Context, userId uuid.UUID) (PolicyResult, error) {
+	virtualServerId, err := policyVirtualServerId(ctx)
+	if err != nil {
+		return PolicyResult{}, err
+	}
+
+	return Allowed(userId, virtualServerId, NewAllowedByAnyone()), nil
+}
+
+func PermissionBasedPolicy(ctx context.Context, permission permissions.Permission) (PolicyResult, error) {
+	virtualServerId, err := policyVirtualServerId(ctx)
+	if err != nil {
+		return PolicyResult{}, err
+	}
+
 	currentUser := authentication.GetCurrentUser(ctx)
 	if !currentUser.IsAuthenticated() {
 		return Denied(currentUser.UserId, virtualServerId), nil
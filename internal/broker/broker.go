@@ -0,0 +1,316 @@
+// Package broker implements the relying-party side of identity brokering:
+// it sends users to an upstream OIDC/OAuth2 provider and turns the
+// authorization code it gets back into a set of claims.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrDiscovery        = errors.New("discovering provider metadata")
+	ErrInvalidIdToken   = errors.New("invalid id token")
+	ErrSubjectMismatch  = errors.New("userinfo subject does not match id token subject")
+	ErrNoClaims         = errors.New("upstream returned neither an id token nor a userinfo endpoint")
+	ErrInsecureTokenUrl = errors.New("token endpoint does not use https")
+)
+
+// Endpoints are the upstream URLs needed to run the authorization code flow.
+type Endpoints struct {
+	AuthorizationUrl string `json:"authorization_endpoint"`
+	TokenUrl         string `json:"token_endpoint"`
+	UserinfoUrl      string `json:"userinfo_endpoint"`
+}
+
+type discoveryDocument struct {
+	Issuer string `json:"issuer"`
+	Endpoints
+}
+
+// Discover fetches the OpenID provider metadata of the given issuer.
+func Discover(ctx context.Context, httpClient *http.Client, issuer string) (*Endpoints, error) {
+	discoveryUrl := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrDiscovery, response.StatusCode)
+	}
+
+	var document discoveryDocument
+	err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding metadata: %w", ErrDiscovery, err)
+	}
+
+	// OpenID Connect Discovery 1.0 §4.3: the issuer in the document must be
+	// identical to the one used to retrieve it.
+	if document.Issuer != issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch, expected %q got %q", ErrDiscovery, issuer, document.Issuer)
+	}
+
+	return &document.Endpoints, nil
+}
+
+// Provider is a configured upstream provider.
+type Provider struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	RedirectUrl  string
+	Endpoints    Endpoints
+	HttpClient   *http.Client
+	// AllowInsecureTokenUrl accepts a plain http token endpoint, which is
+	// only meant for local development.
+	AllowInsecureTokenUrl bool
+}
+
+func (p *Provider) oauth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientId,
+		ClientSecret: p.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.Endpoints.AuthorizationUrl,
+			TokenURL: p.Endpoints.TokenUrl,
+		},
+		RedirectURL: p.RedirectUrl,
+		Scopes:      p.Scopes,
+	}
+}
+
+func (p *Provider) httpContext(ctx context.Context) context.Context {
+	if p.HttpClient == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, p.HttpClient)
+}
+
+// NewVerifier returns a fresh PKCE code verifier.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeUrl builds the upstream authorization URL using PKCE (S256).
+func (p *Provider) AuthCodeUrl(state string, nonce string, verifier string) string {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+	}
+	if nonce != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+	return p.oauth2Config().AuthCodeURL(state, opts...)
+}
+
+type ExchangeParams struct {
+	Code     string
+	Verifier string
+	Nonce    string
+	Now      time.Time
+}
+
+// Exchange redeems the authorization code and returns the claims of the
+// upstream user, taken from the id token and the userinfo endpoint.
+func (p *Provider) Exchange(ctx context.Context, params ExchangeParams) (map[string]any, error) {
+	// the id token signature is not checked, which is only sound if the
+	// token endpoint is reached over TLS
+	tokenUrl, err := url.Parse(p.Endpoints.TokenUrl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing token endpoint: %w", err)
+	}
+	if tokenUrl.Scheme != "https" && !p.AllowInsecureTokenUrl {
+		return nil, ErrInsecureTokenUrl
+	}
+
+	ctx = p.httpContext(ctx)
+
+	token, err := p.oauth2Config().Exchange(ctx, params.Code, oauth2.VerifierOption(params.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+
+	claims := map[string]any{}
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken != "" {
+		idTokenClaims, err := p.validateIdToken(idToken, params.Nonce, params.Now)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range idTokenClaims {
+			claims[k] = v
+		}
+	}
+
+	if p.Endpoints.UserinfoUrl != "" {
+		userinfo, err := p.fetchUserinfo(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		// OpenID Connect Core 1.0 §5.3.2: the sub claim in the userinfo
+		// response must match the one in the id token.
+		if idToken != "" && userinfo["sub"] != claims["sub"] {
+			return nil, ErrSubjectMismatch
+		}
+
+		for k, v := range userinfo {
+			if slices.Contains(idTokenOnlyClaims, k) {
+				continue
+			}
+			claims[k] = v
+		}
+	} else if idToken == "" {
+		return nil, ErrNoClaims
+	}
+
+	return claims, nil
+}
+
+// validateIdToken checks the id token claims. The signature is not checked:
+// the token was received directly from the token endpoint over the TLS
+// back channel, which OpenID Connect Core 1.0 §3.1.3.7 (6) accepts in place
+// of signature validation. Exchange refuses token endpoints without TLS.
+func (p *Provider) validateIdToken(idToken string, nonce string, now time.Time) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(idToken, claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIdToken, err)
+	}
+
+	validator := jwt.NewValidator(
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithLeeway(30*time.Second),
+	)
+	err = validator.Validate(claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIdToken, err)
+	}
+
+	if nonce != "" {
+		tokenNonce, _ := claims["nonce"].(string)
+		if tokenNonce != nonce {
+			return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIdToken)
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) fetchUserinfo(ctx context.Context, token *oauth2.Token) (map[string]any, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Endpoints.UserinfoUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating userinfo request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.oauth2Config().Client(ctx, token).Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("requesting userinfo: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("requesting userinfo: unexpected status %d", response.StatusCode)
+	}
+
+	var userinfo map[string]any
+	err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&userinfo)
+	if err != nil {
+		return nil, fmt.Errorf("decoding userinfo: %w", err)
+	}
+
+	return userinfo, nil
+}
+
+// StringClaim reads a claim as a string. Numeric claims, which some OAuth2
+// providers use for their user ids, are formatted without exponent.
+func StringClaim(claims map[string]any, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
+
+// BoolClaim reads a claim as a boolean, accepting the string forms some
+// providers send.
+func BoolClaim(claims map[string]any, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return slices.Contains([]string{"true", "1"}, strings.ToLower(value))
+	default:
+		return false
+	}
+}
+
+// idTokenOnlyClaims describe the authentication itself. They are only taken
+// from the id token, the userinfo response is not bound to the login and
+// must not be able to claim a stronger one.
+var idTokenOnlyClaims = []string{"amr", "acr"}
+
+// multiFactorAcrValues are the "acr" values of the OpenID PAPE policies
+// that stand for a login with more than one factor.
+var multiFactorAcrValues = []string{
+	"http://schemas.openid.net/pape/policies/2007/06/multi-factor",
+	"http://schemas.openid.net/pape/policies/2007/06/multi-factor-physical",
+}
+
+// ProvesMultiFactor tells whether the upstream login used more than one
+// factor. The "amr" claim (RFC 8176) has to contain "mfa" or two distinct
+// methods, or the "acr" claim has to be a multi-factor PAPE policy.
+func ProvesMultiFactor(claims map[string]any) bool {
+	if slices.Contains(multiFactorAcrValues, StringClaim(claims, "acr")) {
+		return true
+	}
+
+	values, ok := claims["amr"].([]any)
+	if !ok {
+		return false
+	}
+
+	methods := make(map[string]bool, len(values))
+	for _, value := range values {
+		method, ok := value.(string)
+		if !ok || method == "" {
+			continue
+		}
+		if method == "mfa" {
+			return true
+		}
+		methods[method] = true
+	}
+	return len(methods) >= 2
+}
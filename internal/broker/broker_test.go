@@ -0,0 +1,282 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientId = "test-client"
+
+// newTestUpstream serves a minimal provider whose token endpoint returns an
+// id token with the claims built for the server's issuer.
+func newTestUpstream(t *testing.T, idTokenClaims func(issuer string) jwt.MapClaims, userinfo map[string]any) *Provider {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "the-verifier-the-verifier-the-verifier-the-verifier", r.PostForm.Get("code_verifier"))
+
+		idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, idTokenClaims(server.URL)).SignedString([]byte("unused"))
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(userinfo)
+	})
+
+	endpoints, err := Discover(context.Background(), server.Client(), server.URL)
+	require.NoError(t, err)
+
+	return &Provider{
+		Issuer:      server.URL,
+		ClientId:    testClientId,
+		RedirectUrl: "https://keyline.example.com/callback",
+		Scopes:      []string{"openid", "email"},
+		Endpoints:   *endpoints,
+		HttpClient:  server.Client(),
+	}
+}
+
+func exchangeParams(now time.Time) ExchangeParams {
+	return ExchangeParams{
+		Code:     "code",
+		Verifier: "the-verifier-the-verifier-the-verifier-the-verifier",
+		Nonce:    "the-nonce",
+		Now:      now,
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"issuer": "https://someone-else.example.com"})
+	}))
+	t.Cleanup(server.Close)
+
+	// act
+	_, err := Discover(context.Background(), server.Client(), server.URL)
+
+	// assert
+	require.ErrorIs(t, err, ErrDiscovery)
+}
+
+func TestAuthCodeUrl(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	provider := &Provider{
+		ClientId:    testClientId,
+		RedirectUrl: "https://keyline.example.com/callback",
+		Scopes:      []string{"openid"},
+		Endpoints:   Endpoints{AuthorizationUrl: "https://idp.example.com/authorize"},
+	}
+
+	// act
+	authCodeUrl, err := url.Parse(provider.AuthCodeUrl("the-state", "the-nonce", NewVerifier()))
+
+	// assert
+	require.NoError(t, err)
+	query := authCodeUrl.Query()
+	require.Equal(t, "code", query.Get("response_type"))
+	require.Equal(t, "the-state", query.Get("state"))
+	require.Equal(t, "the-nonce", query.Get("nonce"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.NotEmpty(t, query.Get("code_challenge"))
+}
+
+func testIdTokenClaims(now time.Time, nonce string) func(issuer string) jwt.MapClaims {
+	return func(issuer string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   issuer,
+			"sub":   "upstream-subject",
+			"aud":   testClientId,
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": nonce,
+		}
+	}
+}
+
+func TestExchangeMergesUserinfo(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	provider := newTestUpstream(t, testIdTokenClaims(now, "the-nonce"), map[string]any{
+		"sub":            "upstream-subject",
+		"email":          "user@example.com",
+		"email_verified": true,
+	})
+
+	// act
+	claims, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "upstream-subject", StringClaim(claims, "sub"))
+	require.Equal(t, "user@example.com", StringClaim(claims, "email"))
+	require.True(t, BoolClaim(claims, "email_verified"))
+}
+
+func TestExchangeTakesAuthenticationClaimsFromIdTokenOnly(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	idTokenClaims := func(issuer string) jwt.MapClaims {
+		claims := testIdTokenClaims(now, "the-nonce")(issuer)
+		claims["amr"] = []any{"pwd"}
+		return claims
+	}
+	provider := newTestUpstream(t, idTokenClaims, map[string]any{
+		"sub": "upstream-subject",
+		"amr": []any{"pwd", "otp"},
+		"acr": "http://schemas.openid.net/pape/policies/2007/06/multi-factor",
+	})
+
+	// act
+	claims, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, []any{"pwd"}, claims["amr"])
+	require.NotContains(t, claims, "acr")
+	require.False(t, ProvesMultiFactor(claims))
+}
+
+func TestExchangeRejectsInsecureTokenUrl(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	provider := newTestUpstream(t, testIdTokenClaims(now, "the-nonce"), map[string]any{
+		"sub": "upstream-subject",
+	})
+	provider.Endpoints.TokenUrl = "http://upstream.example.com/token"
+
+	// act
+	_, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.ErrorIs(t, err, ErrInsecureTokenUrl)
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	provider := newTestUpstream(t, testIdTokenClaims(now, "another-nonce"), map[string]any{
+		"sub": "upstream-subject",
+	})
+
+	// act
+	_, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.ErrorIs(t, err, ErrInvalidIdToken)
+}
+
+func TestExchangeRejectsExpiredIdToken(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	provider := newTestUpstream(t, testIdTokenClaims(now.Add(-time.Hour), "the-nonce"), map[string]any{
+		"sub": "upstream-subject",
+	})
+
+	// act
+	_, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.ErrorIs(t, err, ErrInvalidIdToken)
+}
+
+func TestExchangeRejectsUserinfoSubjectMismatch(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	now := time.Now()
+	provider := newTestUpstream(t, testIdTokenClaims(now, "the-nonce"), map[string]any{
+		"sub": "someone-else",
+	})
+
+	// act
+	_, err := provider.Exchange(context.Background(), exchangeParams(now))
+
+	// assert
+	require.ErrorIs(t, err, ErrSubjectMismatch)
+}
+
+func TestStringClaimFormatsNumbers(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	claims := map[string]any{"id": float64(12345678901)}
+
+	// act
+	value := StringClaim(claims, "id")
+
+	// assert
+	require.Equal(t, "12345678901", value)
+}
+
+func TestProvesMultiFactor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   bool
+	}{
+		{name: "no claims", claims: map[string]any{}, want: false},
+		{name: "single method", claims: map[string]any{"amr": []any{"pwd"}}, want: false},
+		{name: "repeated method", claims: map[string]any{"amr": []any{"pwd", "pwd"}}, want: false},
+		{name: "mfa", claims: map[string]any{"amr": []any{"mfa"}}, want: true},
+		{name: "two methods", claims: map[string]any{"amr": []any{"pwd", "otp"}}, want: true},
+		{name: "amr is not a list", claims: map[string]any{"amr": "pwd otp"}, want: false},
+		{name: "multi-factor acr", claims: map[string]any{"acr": "http://schemas.openid.net/pape/policies/2007/06/multi-factor"}, want: true},
+		{name: "other acr", claims: map[string]any{"acr": "urn:example:silver"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			got := ProvesMultiFactor(tt.claims)
+
+			// assert
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
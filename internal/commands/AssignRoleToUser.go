@@ -7,11 +7,13 @@ import (
 	"github.com/The127/Keyline/internal/authentication/permissions"
 	"github.com/The127/Keyline/internal/behaviours"
 	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/utils"
 
 	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
 
 	"github.com/google/uuid"
 )
@@ -83,7 +85,7 @@ func HandleAssignRoleToUser(ctx context.Context, command AssignRoleToUser) (*Ass
 		return nil, fmt.Errorf("getting role: %w", err)
 	}
 
-	_, err = dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Id(command.UserId).VirtualServerId(virtualServer.Id()))
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Id(command.UserId).VirtualServerId(virtualServer.Id()))
 	if err != nil {
 		return nil, fmt.Errorf("getting user: %w", err)
 	}
@@ -91,5 +93,13 @@ func HandleAssignRoleToUser(ctx context.Context, command AssignRoleToUser) (*Ass
 	userRoleAssignment := repositories.NewUserRoleAssignment(command.UserId, command.RoleId, nil)
 	dbContext.UserRoleAssignments().Insert(userRoleAssignment)
 
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserUpdatedEvent{
+		User: user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("raising event: %w", err)
+	}
+
 	return &AssignRoleToUserResponse{}, nil
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/The127/Keyline/internal/authentication"
 	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/mocks"
 	"github.com/The127/Keyline/internal/repositories"
@@ -14,6 +15,8 @@ import (
 	"time"
 
 	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	mediatrMocks "github.com/The127/mediatr/mocks"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
@@ -29,7 +32,7 @@ func TestAssignRoleToUserCommandSuite(t *testing.T) {
 	suite.Run(t, new(AssignRoleToUserCommandSuite))
 }
 
-func (s *AssignRoleToUserCommandSuite) createContext(ctrl *gomock.Controller, vsr repositories.VirtualServerRepository, pr repositories.ProjectRepository, rr repositories.RoleRepository, ur repositories.UserRepository, usr repositories.UserRoleAssignmentRepository) context.Context {
+func (s *AssignRoleToUserCommandSuite) createContext(ctrl *gomock.Controller, vsr repositories.VirtualServerRepository, pr repositories.ProjectRepository, rr repositories.RoleRepository, ur repositories.UserRepository, usr repositories.UserRoleAssignmentRepository, m *mediatrMocks.MockMediator) context.Context {
 	dc := ioc.NewDependencyCollection()
 
 	dbContext := mocks.NewMockContext(ctrl)
@@ -57,6 +60,12 @@ func (s *AssignRoleToUserCommandSuite) createContext(ctrl *gomock.Controller, vs
 		dbContext.EXPECT().UserRoleAssignments().Return(usr).AnyTimes()
 	}
 
+	if m != nil {
+		ioc.RegisterTransient(dc, func(_ *ioc.DependencyProvider) mediatr.Mediator {
+			return m
+		})
+	}
+
 	scope := dc.BuildProvider()
 	s.T().Cleanup(func() {
 		utils.PanicOnError(scope.Close, "closing scope")
@@ -74,7 +83,7 @@ func (s *AssignRoleToUserCommandSuite) TestVirtualServerError() {
 	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, nil, nil, nil, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, nil, nil, nil, nil, nil)
 	cmd := AssignRoleToUser{}
 
 	// act
@@ -100,7 +109,7 @@ func (s *AssignRoleToUserCommandSuite) TestProjectError() {
 	projectRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, nil, nil, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, nil, nil, nil, nil)
 	cmd := AssignRoleToUser{}
 
 	// act
@@ -131,7 +140,7 @@ func (s *AssignRoleToUserCommandSuite) TestRoleError() {
 	roleRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, nil, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, nil, nil, nil)
 	cmd := AssignRoleToUser{}
 
 	// act
@@ -167,7 +176,7 @@ func (s *AssignRoleToUserCommandSuite) TestUserError() {
 	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, nil, nil)
 	cmd := AssignRoleToUser{}
 
 	// act
@@ -203,7 +212,7 @@ func (s *AssignRoleToUserCommandSuite) TestSystemProjectRequiresSystemUser() {
 	// No Roles() / Users() / UserRoleAssignments() expectations: the
 	// guard must short-circuit before any of those repositories are
 	// touched.
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, nil, nil, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, nil, nil, nil, nil)
 	ctx = authentication.ContextWithCurrentUser(ctx, authentication.NewCurrentUser(uuid.New()))
 
 	cmd := AssignRoleToUser{
@@ -257,7 +266,10 @@ func (s *AssignRoleToUserCommandSuite) TestSystemProjectAllowedForSystemUser() {
 		return x.RoleId() == role.Id() && x.UserId() == user.Id()
 	}))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, userRoleAssignmentRepository)
+	m := mediatrMocks.NewMockMediator(ctrl)
+	m.EXPECT().SendEvent(gomock.Any(), gomock.AssignableToTypeOf(events.UserUpdatedEvent{}), gomock.Any())
+
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, userRoleAssignmentRepository, m)
 	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
 
 	cmd := AssignRoleToUser{
@@ -315,7 +327,10 @@ func (s *AssignRoleToUserCommandSuite) TestHappyPath() {
 		return x.RoleId() == role.Id() && x.UserId() == user.Id()
 	}))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, userRoleAssignmentRepository)
+	m := mediatrMocks.NewMockMediator(ctrl)
+	m.EXPECT().SendEvent(gomock.Any(), gomock.AssignableToTypeOf(events.UserUpdatedEvent{}), gomock.Any())
+
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, roleRepository, userRepository, userRoleAssignmentRepository, m)
 	cmd := AssignRoleToUser{
 		VirtualServerName: virtualServer.Name(),
 		ProjectSlug:       project.Slug(),
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// ConsumeRecoveryCode uses up a recovery code of a user in place of the
+// totp code. It is audited, so that admins can see when a user signed in
+// without their authenticator.
+type ConsumeRecoveryCode struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	RecoveryCode      string `json:"-"`
+}
+
+func (a ConsumeRecoveryCode) LogRequest() bool {
+	return true
+}
+
+func (a ConsumeRecoveryCode) LogResponse() bool {
+	return true
+}
+
+// IsAllowed lets the request through, the user is not signed in yet and
+// the recovery code is the proof.
+func (a ConsumeRecoveryCode) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PreAuthenticationPolicy(ctx, a.UserId)
+}
+
+func (a ConsumeRecoveryCode) GetRequestName() string {
+	return "ConsumeRecoveryCode"
+}
+
+type ConsumeRecoveryCodeResponse struct {
+	RemainingRecoveryCodes int
+}
+
+func HandleConsumeRecoveryCode(ctx context.Context, command ConsumeRecoveryCode) (*ConsumeRecoveryCodeResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	recoveryCodeFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeRecoveryCode)
+	recoveryCodeCredentials, err := dbContext.Credentials().List(ctx, recoveryCodeFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing recovery codes: %w", err)
+	}
+
+	recoveryCode := normalizeRecoveryCode(command.RecoveryCode)
+	for _, credential := range recoveryCodeCredentials {
+		details, err := credential.RecoveryCodeDetails()
+		if err != nil {
+			return nil, fmt.Errorf("getting recovery code details: %w", err)
+		}
+
+		if utils.CheapCompareHash(recoveryCode, details.HashedCode) {
+			dbContext.Credentials().Delete(credential.Id())
+			return &ConsumeRecoveryCodeResponse{
+				RemainingRecoveryCodes: len(recoveryCodeCredentials) - 1,
+			}, nil
+		}
+	}
+
+	return nil, utils.ErrInvalidRecoveryCode
+}
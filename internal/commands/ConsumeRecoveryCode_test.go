@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type ConsumeRecoveryCodeCommandSuite struct {
+	suite.Suite
+}
+
+func TestConsumeRecoveryCodeCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ConsumeRecoveryCodeCommandSuite))
+}
+
+func (s *ConsumeRecoveryCodeCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *ConsumeRecoveryCodeCommandSuite) arrange(ctrl *gomock.Controller) (context.Context, *repositories.User, []*repositories.Credential, *mocks.MockCredentialRepository) {
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	var recoveryCodes []*repositories.Credential
+	for _, code := range []string{"AAAABBBBCCCCDDDD", "EEEEFFFFGGGGHHHH"} {
+		credential := repositories.NewCredential(user.Id(), &repositories.CredentialRecoveryCodeDetails{
+			HashedCode: utils.CheapHash(code),
+		})
+		credential.Mock(now)
+		recoveryCodes = append(recoveryCodes, credential)
+	}
+
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetType() == repositories.CredentialTypeRecoveryCode
+	})).Return(recoveryCodes, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	return ctx, user, recoveryCodes, credentialRepository
+}
+
+func (s *ConsumeRecoveryCodeCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	ctx, user, recoveryCodes, credentialRepository := s.arrange(ctrl)
+	credentialRepository.EXPECT().Delete(recoveryCodes[1].Id())
+
+	cmd := ConsumeRecoveryCode{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+		RecoveryCode:      "eeee-ffff-gggg-hhhh",
+	}
+
+	// act
+	resp, err := HandleConsumeRecoveryCode(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(1, resp.RemainingRecoveryCodes)
+}
+
+func (s *ConsumeRecoveryCodeCommandSuite) TestWrongCode() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	ctx, user, _, _ := s.arrange(ctrl)
+
+	cmd := ConsumeRecoveryCode{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+		RecoveryCode:      "AAAA-BBBB-CCCC-EEEE",
+	}
+
+	// act
+	resp, err := HandleConsumeRecoveryCode(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrInvalidRecoveryCode)
+	s.Nil(resp)
+}
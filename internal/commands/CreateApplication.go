@@ -10,6 +10,7 @@ import (
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/saml"
 	"github.com/The127/Keyline/utils"
 
 	"github.com/The127/ioc"
@@ -30,6 +31,13 @@ type CreateApplication struct {
 	AccessTokenHeaderType string
 	DeviceFlowEnabled     bool
 	SigningAlgorithm      *config.SigningAlgorithm
+
+	SamlEntityId *string
+	SamlAcsUrl   *string
+	SamlSloUrl   *string
+	// SamlCertificate is the pem encoded certificate the service provider
+	// signs its requests with.
+	SamlCertificate *string
 }
 
 func (c CreateApplication) LogRequest() bool {
@@ -100,6 +108,40 @@ func HandleCreateApplication(ctx context.Context, command CreateApplication) (*C
 		application.SetSigningAlgorithm(command.SigningAlgorithm)
 	}
 
+	if command.Type == repositories.ApplicationTypeSaml {
+		if command.SamlEntityId == nil || *command.SamlEntityId == "" {
+			return nil, fmt.Errorf("saml applications require an entity id: %w", utils.ErrHttpBadRequest)
+		}
+		if command.SamlAcsUrl == nil || *command.SamlAcsUrl == "" {
+			return nil, fmt.Errorf("saml applications require an assertion consumer service url: %w", utils.ErrHttpBadRequest)
+		}
+		if !virtualServer.HasSigningAlgorithm(config.SigningAlgorithmRS256) {
+			return nil, fmt.Errorf("saml applications require RS256 to be configured on the virtual server: %w", utils.ErrHttpBadRequest)
+		}
+
+		existing, err := dbContext.Applications().FirstOrNil(ctx, repositories.NewApplicationFilter().
+			VirtualServerId(virtualServer.Id()).
+			SamlEntityId(*command.SamlEntityId))
+		if err != nil {
+			return nil, fmt.Errorf("checking saml entity id: %w", err)
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("saml entity id %s is already in use: %w", *command.SamlEntityId, utils.ErrHttpConflict)
+		}
+
+		application.SetSamlEntityId(command.SamlEntityId)
+		application.SetSamlAcsUrl(command.SamlAcsUrl)
+		application.SetSamlSloUrl(command.SamlSloUrl)
+
+		if command.SamlCertificate != nil && *command.SamlCertificate != "" {
+			_, err := saml.ParseCertificate(*command.SamlCertificate)
+			if err != nil {
+				return nil, fmt.Errorf("saml certificate: %v: %w", err, utils.ErrHttpBadRequest)
+			}
+			application.SetSamlCertificate(command.SamlCertificate)
+		}
+	}
+
 	dbContext.Applications().Insert(application)
 
 	return &CreateApplicationResponse{
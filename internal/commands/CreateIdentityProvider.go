@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type CreateIdentityProvider struct {
+	VirtualServerName string
+	Name              string
+	DisplayName       string
+	Issuer            string
+	ClientId          string
+	ClientSecret      string
+	Scopes            []string
+	AuthorizationUrl  *string
+	TokenUrl          *string
+	UserinfoUrl       *string
+	ClaimMapping      repositories.IdentityProviderClaimMapping
+	LinkByEmail       bool
+	TrustUpstreamMfa  bool
+}
+
+func (a CreateIdentityProvider) LogRequest() bool {
+	return false
+}
+
+func (a CreateIdentityProvider) LogResponse() bool {
+	return true
+}
+
+func (a CreateIdentityProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.IdentityProviderCreate)
+}
+
+func (a CreateIdentityProvider) GetRequestName() string {
+	return "CreateIdentityProvider"
+}
+
+type CreateIdentityProviderResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateIdentityProvider(ctx context.Context, command CreateIdentityProvider) (*CreateIdentityProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	existingFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Name(command.Name)
+	existing, err := dbContext.IdentityProviders().FirstOrNil(ctx, existingFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity provider: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("identity provider %q already exists: %w", command.Name, utils.ErrHttpConflict)
+	}
+
+	identityProvider := repositories.NewIdentityProvider(
+		virtualServer.Id(),
+		command.Name,
+		command.DisplayName,
+		command.Issuer,
+		command.ClientId,
+		command.ClientSecret,
+		command.Scopes,
+	)
+	identityProvider.SetAuthorizationUrl(command.AuthorizationUrl)
+	identityProvider.SetTokenUrl(command.TokenUrl)
+	identityProvider.SetUserinfoUrl(command.UserinfoUrl)
+	identityProvider.SetClaimMapping(command.ClaimMapping.WithDefaults())
+	identityProvider.SetLinkByEmail(command.LinkByEmail)
+	identityProvider.SetTrustUpstreamMfa(command.TrustUpstreamMfa)
+	dbContext.IdentityProviders().Insert(identityProvider)
+
+	return &CreateIdentityProviderResponse{
+		Id: identityProvider.Id(),
+	}, nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type CreateLdapProvider struct {
+	VirtualServerName string
+	Name              string
+	Vendor            repositories.LdapVendor
+	ConnectionUrl     string
+	StartTls          bool
+	BindDn            string
+	BindPassword      string
+	UsersDn           string
+	UserFilter        *string
+	GroupsDn          *string
+	GroupFilter       *string
+	AttributeMapping  repositories.LdapAttributeMapping
+	EditMode          repositories.LdapEditMode
+	SyncInterval      time.Duration
+}
+
+func (a CreateLdapProvider) LogRequest() bool {
+	return false
+}
+
+func (a CreateLdapProvider) LogResponse() bool {
+	return true
+}
+
+func (a CreateLdapProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderCreate)
+}
+
+func (a CreateLdapProvider) GetRequestName() string {
+	return "CreateLdapProvider"
+}
+
+type CreateLdapProviderResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateLdapProvider(ctx context.Context, command CreateLdapProvider) (*CreateLdapProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	existingFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Name(command.Name)
+	existing, err := dbContext.LdapProviders().FirstOrNil(ctx, existingFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("ldap provider %q already exists: %w", command.Name, utils.ErrHttpConflict)
+	}
+
+	ldapProvider := repositories.NewLdapProvider(
+		virtualServer.Id(),
+		command.Name,
+		command.Vendor,
+		command.ConnectionUrl,
+		command.BindDn,
+		command.BindPassword,
+		command.UsersDn,
+	)
+	ldapProvider.SetStartTls(command.StartTls)
+	if command.UserFilter != nil {
+		ldapProvider.SetUserFilter(*command.UserFilter)
+	}
+	ldapProvider.SetGroupsDn(command.GroupsDn)
+	if command.GroupFilter != nil {
+		ldapProvider.SetGroupFilter(*command.GroupFilter)
+	}
+	ldapProvider.SetAttributeMapping(command.AttributeMapping.WithDefaults(command.Vendor))
+	if command.EditMode != "" {
+		ldapProvider.SetEditMode(command.EditMode)
+	}
+	ldapProvider.SetSyncInterval(command.SyncInterval)
+	dbContext.LdapProviders().Insert(ldapProvider)
+
+	return &CreateLdapProviderResponse{
+		Id: ldapProvider.Id(),
+	}, nil
+}
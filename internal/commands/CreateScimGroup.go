@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// CreateScimGroup provisions a group and its members on behalf of a SCIM
+// client.
+type CreateScimGroup struct {
+	VirtualServerName string
+	DisplayName       string
+	Members           []uuid.UUID
+}
+
+func (a CreateScimGroup) LogRequest() bool {
+	return true
+}
+
+func (a CreateScimGroup) LogResponse() bool {
+	return true
+}
+
+func (a CreateScimGroup) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a CreateScimGroup) GetRequestName() string {
+	return "CreateScimGroup"
+}
+
+type CreateScimGroupResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateScimGroup(ctx context.Context, command CreateScimGroup) (*CreateScimGroupResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	err = ensureScimGroupNameIsFree(ctx, virtualServer, command.DisplayName, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	group := repositories.NewGroup(virtualServer.Id(), command.DisplayName, "Provisioned via SCIM")
+	dbContext.Groups().Insert(group)
+
+	err = setScimGroupMembers(ctx, virtualServer, group.Id(), nil, command.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateScimGroupResponse{
+		Id: group.Id(),
+	}, nil
+}
+
+func ensureScimGroupNameIsFree(ctx context.Context, virtualServer *repositories.VirtualServer, name string, groupId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Name(name)
+	existing, err := dbContext.Groups().FirstOrNil(ctx, groupFilter)
+	if err != nil {
+		return fmt.Errorf("getting group by name: %w", err)
+	}
+	if existing != nil && existing.Id() != groupId {
+		return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, fmt.Sprintf("displayName %q is already taken", name))
+	}
+
+	return nil
+}
+
+// setScimGroupMembers makes the members of a group match the given user ids.
+// Every member must be a regular user of the virtual server.
+func setScimGroupMembers(ctx context.Context, virtualServer *repositories.VirtualServer, groupId uuid.UUID, existing []*repositories.GroupMember, userIds []uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	desired := make(map[uuid.UUID]bool, len(userIds))
+	for _, userId := range userIds {
+		if desired[userId] {
+			continue
+		}
+
+		userFilter := repositories.NewUserFilter().
+			VirtualServerId(virtualServer.Id()).
+			ServiceUser(false).
+			Id(userId)
+		user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+		if err != nil {
+			return fmt.Errorf("getting member: %w", err)
+		}
+		if user == nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, fmt.Sprintf("member %s does not exist", userId))
+		}
+
+		desired[userId] = true
+	}
+
+	for _, member := range existing {
+		if desired[member.UserId()] {
+			delete(desired, member.UserId())
+			continue
+		}
+		dbContext.GroupMembers().Delete(member.Id())
+	}
+
+	for _, userId := range userIds {
+		if desired[userId] {
+			delete(desired, userId)
+			dbContext.GroupMembers().Insert(repositories.NewGroupMember(groupId, userId))
+		}
+	}
+
+	return nil
+}
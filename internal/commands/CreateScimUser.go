@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+)
+
+// CreateScimUser provisions a user on behalf of a SCIM client. Roles are
+// given as "projectSlug:roleName".
+type CreateScimUser struct {
+	VirtualServerName string
+	Username          string
+	DisplayName       string
+	Email             string
+	Active            bool
+	Roles             []string
+}
+
+func (a CreateScimUser) LogRequest() bool {
+	return true
+}
+
+func (a CreateScimUser) LogResponse() bool {
+	return true
+}
+
+func (a CreateScimUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a CreateScimUser) GetRequestName() string {
+	return "CreateScimUser"
+}
+
+type CreateScimUserResponse struct {
+	Id uuid.UUID
+}
+
+func HandleCreateScimUser(ctx context.Context, command CreateScimUser) (*CreateScimUserResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	existingFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Username(command.Username)
+	existing, err := dbContext.Users().FirstOrNil(ctx, existingFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user by username: %w", err)
+	}
+	if existing != nil {
+		return nil, scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, fmt.Sprintf("userName %q is already taken", command.Username))
+	}
+
+	roleIds, err := resolveScimRoles(ctx, virtualServer, command.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	user := repositories.NewUser(
+		command.Username,
+		command.DisplayName,
+		command.Email,
+		virtualServer.Id(),
+	)
+	// the provisioning system is authoritative for the address
+	user.SetEmailVerified(true)
+	user.SetDisabled(!command.Active)
+	dbContext.Users().Insert(user)
+
+	for _, roleId := range roleIds {
+		dbContext.UserRoleAssignments().Insert(repositories.NewUserRoleAssignment(user.Id(), roleId, nil))
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserCreatedEvent{
+		User: user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("raising event: %w", err)
+	}
+
+	return &CreateScimUserResponse{
+		Id: user.Id(),
+	}, nil
+}
+
+// resolveScimRoles looks up the ids of roles given as "projectSlug:roleName".
+// Roles of the system project are rejected, granting those is reserved to
+// system users.
+func resolveScimRoles(ctx context.Context, virtualServer *repositories.VirtualServer, values []string) ([]uuid.UUID, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	roleIds := make([]uuid.UUID, 0, len(values))
+	seen := make(map[uuid.UUID]bool)
+	for _, value := range values {
+		projectSlug, roleName, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, fmt.Sprintf("role %q is not of the form projectSlug:roleName", value))
+		}
+
+		projectFilter := repositories.NewProjectFilter().
+			VirtualServerId(virtualServer.Id()).
+			Slug(projectSlug)
+		project, err := dbContext.Projects().FirstOrNil(ctx, projectFilter)
+		if err != nil {
+			return nil, fmt.Errorf("getting project: %w", err)
+		}
+		if project == nil || project.SystemProject() {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, fmt.Sprintf("role %q does not exist", value))
+		}
+
+		roleFilter := repositories.NewRoleFilter().
+			VirtualServerId(virtualServer.Id()).
+			ProjectId(project.Id()).
+			Name(roleName)
+		role, err := dbContext.Roles().FirstOrNil(ctx, roleFilter)
+		if err != nil {
+			return nil, fmt.Errorf("getting role: %w", err)
+		}
+		if role == nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, fmt.Sprintf("role %q does not exist", value))
+		}
+
+		if !seen[role.Id()] {
+			seen[role.Id()] = true
+			roleIds = append(roleIds, role.Id())
+		}
+	}
+
+	return roleIds, nil
+}
@@ -21,8 +21,9 @@ import (
 )
 
 const (
-	AdminRoleName       = "admin"
-	SystemAdminRoleName = "system-admin"
+	AdminRoleName           = "admin"
+	SystemAdminRoleName     = "system-admin"
+	ScimProvisionerRoleName = "scim-provisioner"
 
 	AdminApplicationName = "admin-ui"
 )
@@ -133,7 +134,7 @@ func HandleCreateVirtualServer(ctx context.Context, command CreateVirtualServer)
 	keyService := ioc.GetDependency[services.KeyService](scope)
 	var err error
 	for _, alg := range virtualServer.AllSigningAlgorithms() {
-		_, err = keyService.Generate(clockService, command.Name, alg)
+		_, err = keyService.Generate(clockService, command.Name, alg, virtualServer.KeyRotationPolicy())
 		if err != nil {
 			return nil, fmt.Errorf("generating keypair for %s: %w", alg, err)
 		}
@@ -150,6 +151,7 @@ func HandleCreateVirtualServer(ctx context.Context, command CreateVirtualServer)
 	if defaultRolesResult.systemAdminRoleId != nil {
 		roleIdsByFullyQualifiedName[fmt.Sprintf("%s:%s", systemProject.Slug(), SystemAdminRoleName)] = *defaultRolesResult.systemAdminRoleId
 	}
+	roleIdsByFullyQualifiedName[fmt.Sprintf("%s:%s", systemProject.Slug(), ScimProvisionerRoleName)] = defaultRolesResult.scimProvisionerRoleId
 
 	for _, project := range command.Projects {
 		newProject := repositories.NewProject(virtualServer.Id(), project.Slug, project.Name, project.Description)
@@ -291,8 +293,9 @@ func initializeDefaultApplications(ctx context.Context, virtualServer *repositor
 }
 
 type createDefaultAdminUiRolesResult struct {
-	adminRoleId       uuid.UUID
-	systemAdminRoleId *uuid.UUID
+	adminRoleId           uuid.UUID
+	systemAdminRoleId     *uuid.UUID
+	scimProvisionerRoleId uuid.UUID
 }
 
 func initializeDefaultAdminRoles(ctx context.Context, virtualServer *repositories.VirtualServer, project *repositories.Project, createSystemAdminRole bool) *createDefaultAdminUiRolesResult {
@@ -320,9 +323,18 @@ func initializeDefaultAdminRoles(ctx context.Context, virtualServer *repositorie
 		systemAdminRoleId = utils.Ptr(systemAdminRole.Id())
 	}
 
+	scimProvisionerRole := repositories.NewRole(
+		virtualServer.Id(),
+		project.Id(),
+		ScimProvisionerRoleName,
+		"SCIM provisioning role",
+	)
+	dbContext.Roles().Insert(scimProvisionerRole)
+
 	return &createDefaultAdminUiRolesResult{
-		adminRoleId:       adminRole.Id(),
-		systemAdminRoleId: systemAdminRoleId,
+		adminRoleId:           adminRole.Id(),
+		systemAdminRoleId:     systemAdminRoleId,
+		scimProvisionerRoleId: scimProvisionerRole.Id(),
 	}
 }
 
@@ -331,6 +343,36 @@ func initializeDefaultTemplates(ctx context.Context, virtualServer *repositories
 		ctx,
 		"email_verification_template",
 		virtualServer,
+		repositories.EmailVerificationMailTemplate,
+		templates.DefaultEmailVerificationTemplate,
+	)
+	insertTemplate(
+		ctx,
+		"account_locked_template",
+		virtualServer,
+		repositories.AccountLockedMailTemplate,
+		templates.DefaultAccountLockedTemplate,
+	)
+	insertTemplate(
+		ctx,
+		"password_reset_template",
+		virtualServer,
+		repositories.PasswordResetMailTemplate,
+		templates.DefaultPasswordResetTemplate,
+	)
+	insertTemplate(
+		ctx,
+		"email_login_template",
+		virtualServer,
+		repositories.EmailLoginMailTemplate,
+		templates.DefaultEmailLoginTemplate,
+	)
+	insertTemplate(
+		ctx,
+		"sms_code_template",
+		virtualServer,
+		repositories.SmsCodeTemplate,
+		templates.DefaultSmsCodeTemplate,
 	)
 }
 
@@ -338,13 +380,15 @@ func insertTemplate(
 	ctx context.Context,
 	templateName string,
 	virtualServer *repositories.VirtualServer,
+	templateType repositories.TemplateType,
+	content []byte,
 ) {
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
-	file := repositories.NewFile(templateName, "text/plain", templates.DefaultEmailVerificationTemplate)
+	file := repositories.NewFile(templateName, "text/plain", content)
 	dbContext.Files().Insert(file)
 
-	t := repositories.NewTemplate(virtualServer.Id(), file.Id(), repositories.EmailVerificationMailTemplate)
+	t := repositories.NewTemplate(virtualServer.Id(), file.Id(), templateType)
 	dbContext.Templates().Insert(t)
 }
@@ -118,7 +118,7 @@ func (s *CreateVirtualServerCommandSuite) TestHappyPath() {
 
 	keyService := serviceMocks.NewMockKeyService(ctrl)
 	keyService.EXPECT().
-		Generate(gomock.Any(), "virtualServer", gomock.Any()).
+		Generate(gomock.Any(), "virtualServer", gomock.Any(), gomock.Any()).
 		Return(services.KeyPair{}, nil)
 
 	applicationRepository := mocks.NewMockApplicationRepository(ctrl)
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type DeleteIdentityProvider struct {
+	VirtualServerName  string
+	IdentityProviderId uuid.UUID
+}
+
+func (a DeleteIdentityProvider) LogRequest() bool {
+	return true
+}
+
+func (a DeleteIdentityProvider) LogResponse() bool {
+	return true
+}
+
+func (a DeleteIdentityProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.IdentityProviderDelete)
+}
+
+func (a DeleteIdentityProvider) GetRequestName() string {
+	return "DeleteIdentityProvider"
+}
+
+type DeleteIdentityProviderResponse struct{}
+
+func HandleDeleteIdentityProvider(ctx context.Context, command DeleteIdentityProvider) (*DeleteIdentityProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.IdentityProviderId)
+	identityProvider, err := dbContext.IdentityProviders().FirstOrNil(ctx, identityProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity provider: %w", err)
+	}
+
+	if identityProvider == nil {
+		return &DeleteIdentityProviderResponse{}, nil
+	}
+
+	// drop the account links so users cannot sign in through a provider
+	// that no longer exists
+	linkFilter := repositories.NewCredentialFilter().
+		Type(repositories.CredentialTypeIdentityProvider).
+		DetailIdentityProviderId(identityProvider.Id())
+	links, err := dbContext.Credentials().List(ctx, linkFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity provider links: %w", err)
+	}
+	for _, link := range links {
+		dbContext.Credentials().Delete(link.Id())
+	}
+
+	dbContext.IdentityProviders().Delete(identityProvider.Id())
+
+	return &DeleteIdentityProviderResponse{}, nil
+}
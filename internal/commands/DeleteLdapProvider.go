@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type DeleteLdapProvider struct {
+	VirtualServerName string
+	LdapProviderId    uuid.UUID
+}
+
+func (a DeleteLdapProvider) LogRequest() bool {
+	return true
+}
+
+func (a DeleteLdapProvider) LogResponse() bool {
+	return true
+}
+
+func (a DeleteLdapProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderDelete)
+}
+
+func (a DeleteLdapProvider) GetRequestName() string {
+	return "DeleteLdapProvider"
+}
+
+type DeleteLdapProviderResponse struct{}
+
+func HandleDeleteLdapProvider(ctx context.Context, command DeleteLdapProvider) (*DeleteLdapProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrNil(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	if ldapProvider == nil {
+		return &DeleteLdapProviderResponse{}, nil
+	}
+
+	// imported users stay, but without their directory link they can no
+	// longer sign in until a local password is set
+	linkFilter := repositories.NewCredentialFilter().
+		Type(repositories.CredentialTypeLdap).
+		DetailLdapProviderId(ldapProvider.Id())
+	links, err := dbContext.Credentials().List(ctx, linkFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap links: %w", err)
+	}
+	for _, link := range links {
+		dbContext.Credentials().Delete(link.Id())
+	}
+
+	dbContext.LdapProviders().Delete(ldapProvider.Id())
+
+	return &DeleteLdapProviderResponse{}, nil
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type DeletePasskey struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	PasskeyId         uuid.UUID
+}
+
+func (a DeletePasskey) LogRequest() bool {
+	return true
+}
+
+func (a DeletePasskey) LogResponse() bool {
+	return true
+}
+
+func (a DeletePasskey) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.UserUpdate)
+}
+
+func (a DeletePasskey) GetRequestName() string {
+	return "DeletePasskey"
+}
+
+type DeletePasskeyResponse struct{}
+
+func HandleDeletePasskey(ctx context.Context, command DeletePasskey) (*DeletePasskeyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credential, err := getPasskey(ctx, dbContext, command.VirtualServerName, command.UserId, command.PasskeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext.Credentials().Delete(credential.Id())
+	return &DeletePasskeyResponse{}, nil
+}
+
+// getPasskey gets a passkey of a user of the virtual server.
+func getPasskey(ctx context.Context, dbContext database.Context, virtualServerName string, userId uuid.UUID, passkeyId uuid.UUID) (*repositories.Credential, error) {
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(virtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(userId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeWebauthn).
+		Id(passkeyId)
+	credential, err := dbContext.Credentials().FirstOrErr(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting passkey: %w", err)
+	}
+
+	return credential, nil
+}
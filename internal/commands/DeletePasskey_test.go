@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type DeletePasskeyCommandSuite struct {
+	suite.Suite
+}
+
+func TestDeletePasskeyCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(DeletePasskeyCommandSuite))
+}
+
+func (s *DeletePasskeyCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *DeletePasskeyCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	credential := repositories.NewCredential(user.Id(), &repositories.CredentialWebauthnDetails{
+		CredentialId: "credential-id",
+	})
+	credential.Mock(now)
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetId() == credential.Id() &&
+			x.GetUserId() == user.Id() &&
+			x.GetType() == repositories.CredentialTypeWebauthn
+	})).Return(credential, nil)
+	credentialRepository.EXPECT().Delete(credential.Id())
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := DeletePasskey{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+		PasskeyId:         credential.Id(),
+	}
+
+	// act
+	resp, err := HandleDeletePasskey(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
+
+func (s *DeletePasskeyCommandSuite) TestPasskeyError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := DeletePasskey{}
+
+	// act
+	resp, err := HandleDeletePasskey(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
+
+func (s *DeletePasskeyCommandSuite) TestUserError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
+	cmd := DeletePasskey{}
+
+	// act
+	resp, err := HandleDeletePasskey(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
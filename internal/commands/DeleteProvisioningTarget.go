@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// DeleteProvisioningTarget stops provisioning an application. Users already
+// pushed to the service provider are left there.
+type DeleteProvisioningTarget struct {
+	VirtualServerName string
+	ProjectSlug       string
+	ApplicationId     uuid.UUID
+}
+
+func (a DeleteProvisioningTarget) LogRequest() bool {
+	return true
+}
+
+func (a DeleteProvisioningTarget) LogResponse() bool {
+	return true
+}
+
+func (a DeleteProvisioningTarget) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ApplicationUpdate)
+}
+
+func (a DeleteProvisioningTarget) GetRequestName() string {
+	return "DeleteProvisioningTarget"
+}
+
+type DeleteProvisioningTargetResponse struct{}
+
+func HandleDeleteProvisioningTarget(ctx context.Context, command DeleteProvisioningTarget) (*DeleteProvisioningTargetResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	application, err := getProvisionedApplication(ctx, command.VirtualServerName, command.ProjectSlug, command.ApplicationId)
+	if err != nil {
+		return nil, err
+	}
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(application.VirtualServerId()).
+		ApplicationId(application.Id())
+	target, err := dbContext.ProvisioningTargets().FirstOrErr(ctx, targetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting provisioning target: %w", err)
+	}
+
+	dbContext.ProvisioningTargets().Delete(target.Id())
+
+	return &DeleteProvisioningTargetResponse{}, nil
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// DeleteScimGroup deprovisions a group together with its memberships and the
+// role assignments it granted.
+type DeleteScimGroup struct {
+	VirtualServerName string
+	GroupId           uuid.UUID
+}
+
+func (a DeleteScimGroup) LogRequest() bool {
+	return true
+}
+
+func (a DeleteScimGroup) LogResponse() bool {
+	return true
+}
+
+func (a DeleteScimGroup) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a DeleteScimGroup) GetRequestName() string {
+	return "DeleteScimGroup"
+}
+
+type DeleteScimGroupResponse struct{}
+
+func HandleDeleteScimGroup(ctx context.Context, command DeleteScimGroup) (*DeleteScimGroupResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.GroupId)
+	group, err := dbContext.Groups().FirstOrErr(ctx, groupFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting group: %w", err)
+	}
+
+	members, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().GroupId(group.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing group members: %w", err)
+	}
+	for _, member := range members {
+		dbContext.GroupMembers().Delete(member.Id())
+	}
+
+	assignments, _, err := dbContext.UserRoleAssignments().List(ctx, repositories.NewUserRoleAssignmentFilter().GroupId(group.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing role assignments: %w", err)
+	}
+	for _, assignment := range assignments {
+		dbContext.UserRoleAssignments().Delete(assignment.Id())
+	}
+
+	dbContext.Groups().Delete(group.Id())
+
+	return &DeleteScimGroupResponse{}, nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+)
+
+// DeleteScimUser deprovisions a user together with its credentials, role
+// assignments and group memberships.
+type DeleteScimUser struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a DeleteScimUser) LogRequest() bool {
+	return true
+}
+
+func (a DeleteScimUser) LogResponse() bool {
+	return true
+}
+
+func (a DeleteScimUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a DeleteScimUser) GetRequestName() string {
+	return "DeleteScimUser"
+}
+
+type DeleteScimUserResponse struct{}
+
+func HandleDeleteScimUser(ctx context.Context, command DeleteScimUser) (*DeleteScimUserResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		ServiceUser(false).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	err = ensureScimManagesUser(ctx, user.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := dbContext.Credentials().List(ctx, repositories.NewCredentialFilter().UserId(user.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing credentials: %w", err)
+	}
+	for _, credential := range credentials {
+		dbContext.Credentials().Delete(credential.Id())
+	}
+
+	assignments, _, err := dbContext.UserRoleAssignments().List(ctx, repositories.NewUserRoleAssignmentFilter().UserId(user.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing role assignments: %w", err)
+	}
+	for _, assignment := range assignments {
+		dbContext.UserRoleAssignments().Delete(assignment.Id())
+	}
+
+	memberships, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().UserId(user.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing group memberships: %w", err)
+	}
+	for _, membership := range memberships {
+		dbContext.GroupMembers().Delete(membership.Id())
+	}
+
+	dbContext.Users().Delete(user.Id())
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserDeletedEvent{
+		User: user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("raising event: %w", err)
+	}
+
+	return &DeleteScimUserResponse{}, nil
+}
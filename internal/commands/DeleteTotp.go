@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// DeleteTotp removes an authenticator of a user. The recovery codes go
+// with the last authenticator as they only stand in for TOTP codes.
+type DeleteTotp struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	TotpId            uuid.UUID
+}
+
+func (a DeleteTotp) LogRequest() bool {
+	return true
+}
+
+func (a DeleteTotp) LogResponse() bool {
+	return true
+}
+
+func (a DeleteTotp) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.TotpDelete)
+}
+
+func (a DeleteTotp) GetRequestName() string {
+	return "DeleteTotp"
+}
+
+type DeleteTotpResponse struct{}
+
+func HandleDeleteTotp(ctx context.Context, command DeleteTotp) (*DeleteTotpResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credential, err := getTotp(ctx, dbContext, command.VirtualServerName, command.UserId, command.TotpId)
+	if err != nil {
+		return nil, err
+	}
+
+	totpFilter := repositories.NewCredentialFilter().
+		UserId(credential.UserId()).
+		Type(repositories.CredentialTypeTotp)
+	totpCredentials, err := dbContext.Credentials().List(ctx, totpFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting totp credentials: %w", err)
+	}
+
+	if len(totpCredentials) == 1 {
+		err = deleteCredentialsOfType(ctx, dbContext, credential.UserId(), repositories.CredentialTypeRecoveryCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dbContext.Credentials().Delete(credential.Id())
+	return &DeleteTotpResponse{}, nil
+}
+
+// getTotp gets a totp credential of a user of the virtual server.
+func getTotp(ctx context.Context, dbContext database.Context, virtualServerName string, userId uuid.UUID, totpId uuid.UUID) (*repositories.Credential, error) {
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(virtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(userId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeTotp).
+		Id(totpId)
+	credential, err := dbContext.Credentials().FirstOrErr(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting totp: %w", err)
+	}
+
+	return credential, nil
+}
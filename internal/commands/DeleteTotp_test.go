@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type DeleteTotpCommandSuite struct {
+	suite.Suite
+}
+
+func TestDeleteTotpCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(DeleteTotpCommandSuite))
+}
+
+func (s *DeleteTotpCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *DeleteTotpCommandSuite) arrange(ctrl *gomock.Controller, otherTotps int) (context.Context, *mocks.MockCredentialRepository, DeleteTotp) {
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	credential := repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{
+		Secret: "secret",
+	})
+	credential.Mock(now)
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetId() == credential.Id() &&
+			x.GetUserId() == user.Id() &&
+			x.GetType() == repositories.CredentialTypeTotp
+	})).Return(credential, nil)
+
+	totpCredentials := []*repositories.Credential{credential}
+	for range otherTotps {
+		totpCredentials = append(totpCredentials, repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{}))
+	}
+	credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetType() == repositories.CredentialTypeTotp
+	})).Return(totpCredentials, nil)
+	credentialRepository.EXPECT().Delete(credential.Id())
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := DeleteTotp{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+		TotpId:            credential.Id(),
+	}
+	return ctx, credentialRepository, cmd
+}
+
+func (s *DeleteTotpCommandSuite) TestKeepsRecoveryCodesWhileOtherTotpsRemain() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	ctx, _, cmd := s.arrange(ctrl, 1)
+
+	// act
+	resp, err := HandleDeleteTotp(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
+
+func (s *DeleteTotpCommandSuite) TestDeletesRecoveryCodesWithLastTotp() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	ctx, credentialRepository, cmd := s.arrange(ctrl, 0)
+
+	recoveryCode := repositories.NewCredential(cmd.UserId, &repositories.CredentialRecoveryCodeDetails{
+		HashedCode: "hash",
+	})
+	credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == cmd.UserId && x.GetType() == repositories.CredentialTypeRecoveryCode
+	})).Return([]*repositories.Credential{recoveryCode}, nil)
+	credentialRepository.EXPECT().Delete(recoveryCode.Id())
+
+	// act
+	resp, err := HandleDeleteTotp(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
+
+func (s *DeleteTotpCommandSuite) TestTotpError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := DeleteTotp{}
+
+	// act
+	resp, err := HandleDeleteTotp(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
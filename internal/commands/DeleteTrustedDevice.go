@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// DeleteTrustedDevice revokes a trusted browser of a user, its next login
+// asks for the second factor again.
+type DeleteTrustedDevice struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	TrustedDeviceId   uuid.UUID
+}
+
+func (a DeleteTrustedDevice) LogRequest() bool {
+	return true
+}
+
+func (a DeleteTrustedDevice) LogResponse() bool {
+	return true
+}
+
+func (a DeleteTrustedDevice) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.TrustedDeviceDelete)
+}
+
+func (a DeleteTrustedDevice) GetRequestName() string {
+	return "DeleteTrustedDevice"
+}
+
+type DeleteTrustedDeviceResponse struct{}
+
+func HandleDeleteTrustedDevice(ctx context.Context, command DeleteTrustedDevice) (*DeleteTrustedDeviceResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeTrustedDevice).
+		Id(command.TrustedDeviceId)
+	credential, err := dbContext.Credentials().FirstOrErr(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting trusted device: %w", err)
+	}
+
+	dbContext.Credentials().Delete(credential.Id())
+	return &DeleteTrustedDeviceResponse{}, nil
+}
@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/federation"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+)
+
+// ImportLdapUser creates or refreshes the local copy of a directory user.
+type ImportLdapUser struct {
+	VirtualServerName string
+	LdapProviderId    uuid.UUID
+	User              federation.User
+}
+
+func (a ImportLdapUser) LogRequest() bool {
+	return true
+}
+
+func (a ImportLdapUser) LogResponse() bool {
+	return true
+}
+
+func (a ImportLdapUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.UserCreate)
+}
+
+func (a ImportLdapUser) GetRequestName() string {
+	return "ImportLdapUser"
+}
+
+type ImportLdapUserResponse struct {
+	UserId uuid.UUID
+}
+
+func HandleImportLdapUser(ctx context.Context, command ImportLdapUser) (*ImportLdapUserResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	userId, _, err := upsertLdapUser(ctx, ldapProvider, command.User)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportLdapUserResponse{
+		UserId: userId,
+	}, nil
+}
+
+// getLdapLink returns the provider and link details of a directory user, or
+// nil if the user is not linked to a directory.
+func getLdapLink(ctx context.Context, userId uuid.UUID) (*repositories.LdapProvider, *repositories.CredentialLdapDetails, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	linkFilter := repositories.NewCredentialFilter().
+		UserId(userId).
+		Type(repositories.CredentialTypeLdap)
+	link, err := dbContext.Credentials().FirstOrNil(ctx, linkFilter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting ldap link: %w", err)
+	}
+	if link == nil {
+		return nil, nil, nil
+	}
+
+	details, err := link.LdapDetails()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting ldap link details: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().Id(details.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	return ldapProvider, details, nil
+}
+
+// upsertLdapUser finds the user linked to the directory entry and refreshes
+// the mapped attributes, or creates and links a new user. It reports whether
+// a user was created. A local user with the same username is never taken
+// over.
+func upsertLdapUser(ctx context.Context, ldapProvider *repositories.LdapProvider, directoryUser federation.User) (uuid.UUID, bool, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	displayName := cmp.Or(directoryUser.DisplayName, directoryUser.Username)
+
+	linkFilter := repositories.NewCredentialFilter().
+		Type(repositories.CredentialTypeLdap).
+		DetailsId(repositories.LdapCredentialId(ldapProvider.Id(), directoryUser.ExternalId))
+	link, err := dbContext.Credentials().FirstOrNil(ctx, linkFilter)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("getting ldap link: %w", err)
+	}
+
+	if link != nil {
+		userFilter := repositories.NewUserFilter().Id(link.UserId())
+		user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+		if err != nil {
+			return uuid.Nil, false, fmt.Errorf("getting linked user: %w", err)
+		}
+
+		user.SetDisplayName(displayName)
+		if directoryUser.Email != "" {
+			user.SetPrimaryEmail(directoryUser.Email)
+		}
+		if user.HasChanges() {
+			dbContext.Users().Update(user)
+
+			m := ioc.GetDependency[mediatr.Mediator](scope)
+			err = mediatr.SendEvent(ctx, m, events.UserUpdatedEvent{
+				User: user,
+			})
+			if err != nil {
+				return uuid.Nil, false, fmt.Errorf("raising event: %w", err)
+			}
+		}
+
+		details, err := link.LdapDetails()
+		if err != nil {
+			return uuid.Nil, false, fmt.Errorf("getting ldap link details: %w", err)
+		}
+		if details.Dn != directoryUser.Dn {
+			details.Dn = directoryUser.Dn
+			link.SetDetails(details)
+			dbContext.Credentials().Update(link)
+		}
+
+		return user.Id(), false, nil
+	}
+
+	existingFilter := repositories.NewUserFilter().
+		VirtualServerId(ldapProvider.VirtualServerId()).
+		Username(directoryUser.Username)
+	existing, err := dbContext.Users().FirstOrNil(ctx, existingFilter)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("getting user by username: %w", err)
+	}
+	if existing != nil {
+		return uuid.Nil, false, fmt.Errorf("username %q is already taken: %w", directoryUser.Username, utils.ErrHttpConflict)
+	}
+
+	user := repositories.NewUser(
+		directoryUser.Username,
+		displayName,
+		directoryUser.Email,
+		ldapProvider.VirtualServerId(),
+	)
+	// the directory is authoritative for the address
+	user.SetEmailVerified(true)
+	dbContext.Users().Insert(user)
+
+	dbContext.Credentials().Insert(repositories.NewCredential(
+		user.Id(),
+		repositories.NewCredentialLdapDetails(ldapProvider.Id(), directoryUser.ExternalId, directoryUser.Dn),
+	))
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserCreatedEvent{
+		User: user,
+	})
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("raising event: %w", err)
+	}
+
+	return user.Id(), true, nil
+}
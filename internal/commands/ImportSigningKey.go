@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+	"slices"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+// ImportSigningKey brings an existing private key, e.g. of a previous
+// identity provider, into the key store of a virtual server so that the
+// tokens it signed keep verifying. Verify-only keys may use any supported
+// algorithm, keys that sign tokens need one configured on the virtual
+// server.
+type ImportSigningKey struct {
+	VirtualServerName string
+	Kid               string
+	Algorithm         config.SigningAlgorithm
+	// PrivateKey is a PEM block or a JWK.
+	PrivateKey string
+	NotBefore  *time.Time
+	NotAfter   time.Time
+	VerifyOnly bool
+}
+
+func (a ImportSigningKey) LogRequest() bool {
+	return false
+}
+
+func (a ImportSigningKey) LogResponse() bool {
+	return true
+}
+
+func (a ImportSigningKey) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerUpdate)
+}
+
+func (a ImportSigningKey) GetRequestName() string {
+	return "ImportSigningKey"
+}
+
+type ImportSigningKeyResponse struct {
+	Kid   string
+	State services.KeyState
+}
+
+func HandleImportSigningKey(ctx context.Context, command ImportSigningKey) (*ImportSigningKeyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	if command.Kid == "" {
+		return nil, fmt.Errorf("missing kid: %w", utils.ErrHttpBadRequest)
+	}
+
+	if !slices.Contains(config.SupportedSigningAlgorithms, command.Algorithm) {
+		return nil, fmt.Errorf("unsupported algorithm %s: %w", command.Algorithm, utils.ErrHttpBadRequest)
+	}
+
+	if !command.VerifyOnly && !virtualServer.HasSigningAlgorithm(command.Algorithm) {
+		return nil, fmt.Errorf("algorithm %s is not configured on the virtual server, import the key as verify-only: %w", command.Algorithm, utils.ErrHttpBadRequest)
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	notBefore := now
+	if command.NotBefore != nil {
+		notBefore = *command.NotBefore
+	}
+	if !command.NotAfter.After(notBefore) || !command.NotAfter.After(now) {
+		return nil, fmt.Errorf("the validity window must end in the future and after it starts: %w", utils.ErrHttpBadRequest)
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	keyPairs, err := keyService.GetAllKeys(virtualServer.Name())
+	if err != nil {
+		return nil, fmt.Errorf("getting keys: %w", err)
+	}
+	for _, keyPair := range keyPairs {
+		if keyPair.GetKid() == command.Kid {
+			return nil, fmt.Errorf("key %s already exists: %w", command.Kid, utils.ErrSigningKeyExists)
+		}
+	}
+
+	keyPair, err := keyService.Import(clockService, virtualServer.Name(), command.Algorithm, virtualServer.KeyRotationPolicy(), services.ImportedKey{
+		Kid:        command.Kid,
+		PrivateKey: command.PrivateKey,
+		NotBefore:  command.NotBefore,
+		NotAfter:   command.NotAfter,
+		VerifyOnly: command.VerifyOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("importing key: %w", err)
+	}
+
+	return &ImportSigningKeyResponse{
+		Kid:   keyPair.GetKid(),
+		State: services.KeyPairState(append(keyPairs, keyPair), keyPair, now),
+	}, nil
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/internal/services"
+	serviceMocks "github.com/The127/Keyline/internal/services/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type ImportSigningKeyCommandSuite struct {
+	suite.Suite
+}
+
+func TestImportSigningKeyCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ImportSigningKeyCommandSuite))
+}
+
+func (s *ImportSigningKeyCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	keyService services.KeyService,
+	clockService clock.Service,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) services.KeyService {
+		return keyService
+	})
+
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *ImportSigningKeyCommandSuite) exportedEd25519Key() string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+
+	serialized, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Export(privateKey)
+	s.Require().NoError(err)
+	return serialized
+}
+
+func (s *ImportSigningKeyCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(clockService.Now())
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	keyService := services.NewKeyService(caching.NewMemoryCache[services.KeyCacheKey, services.KeyCacheEntry](), services.NewMemoryKeyStore(), clockService)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := ImportSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               "legacy",
+		Algorithm:         config.SigningAlgorithmEdDSA,
+		PrivateKey:        s.exportedEd25519Key(),
+		NotAfter:          clockService.Now().Add(time.Hour),
+		VerifyOnly:        true,
+	}
+
+	// act
+	resp, err := HandleImportSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal("legacy", resp.Kid)
+	s.Equal(services.KeyStateRetired, resp.State)
+
+	keyPair, err := keyService.GetVerificationKey("virtualServer", config.SigningAlgorithmEdDSA, "legacy")
+	s.Require().NoError(err)
+	s.True(keyPair.VerifyOnly())
+}
+
+func (s *ImportSigningKeyCommandSuite) TestSigningKeyNeedsConfiguredAlgorithm() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	keyService := serviceMocks.NewMockKeyService(ctrl)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := ImportSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               "legacy",
+		Algorithm:         config.SigningAlgorithmRS256,
+		PrivateKey:        "pem",
+		NotAfter:          clockService.Now().Add(time.Hour),
+	}
+
+	// act
+	resp, err := HandleImportSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrHttpBadRequest)
+	s.Nil(resp)
+}
+
+func (s *ImportSigningKeyCommandSuite) TestExpiredWindow() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	keyService := serviceMocks.NewMockKeyService(ctrl)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := ImportSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               "legacy",
+		Algorithm:         config.SigningAlgorithmEdDSA,
+		PrivateKey:        "pem",
+		NotAfter:          clockService.Now().Add(-time.Hour),
+		VerifyOnly:        true,
+	}
+
+	// act
+	resp, err := HandleImportSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrHttpBadRequest)
+	s.Nil(resp)
+}
+
+func (s *ImportSigningKeyCommandSuite) TestKnownKid() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	keyPair, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, virtualServer.KeyRotationPolicy())
+	s.Require().NoError(err)
+
+	keyService := serviceMocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().GetAllKeys("virtualServer").Return([]services.KeyPair{keyPair}, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := ImportSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               keyPair.GetKid(),
+		Algorithm:         config.SigningAlgorithmEdDSA,
+		PrivateKey:        "pem",
+		NotAfter:          clockService.Now().Add(time.Hour),
+		VerifyOnly:        true,
+	}
+
+	// act
+	resp, err := HandleImportSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrSigningKeyExists)
+	s.Nil(resp)
+}
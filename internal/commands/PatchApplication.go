@@ -9,6 +9,7 @@ import (
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/saml"
 	"github.com/The127/Keyline/utils"
 
 	"github.com/The127/ioc"
@@ -27,6 +28,10 @@ type PatchApplication struct {
 	RedirectUris           *[]string
 	PostLogoutRedirectUris *[]string
 	SigningAlgorithm       *config.SigningAlgorithm
+	SamlAcsUrl             *string
+	SamlSloUrl             *string
+	SamlCertificate        *string
+	AcrLevels              *repositories.AcrLevels
 }
 
 func (a PatchApplication) LogRequest() bool {
@@ -117,6 +122,47 @@ func HandlePatchApplication(ctx context.Context, command PatchApplication) (*Pat
 		application.SetSigningAlgorithm(command.SigningAlgorithm)
 	}
 
+	if command.SamlAcsUrl != nil || command.SamlSloUrl != nil || command.SamlCertificate != nil {
+		if application.Type() != repositories.ApplicationTypeSaml {
+			return nil, fmt.Errorf("saml settings can only be changed on saml applications: %w", utils.ErrHttpBadRequest)
+		}
+	}
+
+	if command.SamlAcsUrl != nil {
+		if *command.SamlAcsUrl == "" {
+			return nil, fmt.Errorf("assertion consumer service url must not be empty: %w", utils.ErrHttpBadRequest)
+		}
+		application.SetSamlAcsUrl(command.SamlAcsUrl)
+	}
+
+	if command.SamlSloUrl != nil {
+		if *command.SamlSloUrl == "" {
+			application.SetSamlSloUrl(nil)
+		} else {
+			application.SetSamlSloUrl(command.SamlSloUrl)
+		}
+	}
+
+	if command.SamlCertificate != nil {
+		if *command.SamlCertificate == "" {
+			application.SetSamlCertificate(nil)
+		} else {
+			_, err := saml.ParseCertificate(*command.SamlCertificate)
+			if err != nil {
+				return nil, fmt.Errorf("saml certificate: %v: %w", err, utils.ErrHttpBadRequest)
+			}
+			application.SetSamlCertificate(command.SamlCertificate)
+		}
+	}
+
+	if command.AcrLevels != nil {
+		err := command.AcrLevels.Validate()
+		if err != nil {
+			return nil, err
+		}
+		application.SetAcrLevels(*command.AcrLevels)
+	}
+
 	dbContext.Applications().Update(application)
 
 	return &PatchApplicationResponse{}, nil
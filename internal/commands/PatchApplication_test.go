@@ -221,6 +221,38 @@ func (s *PatchApplicationCommandSuite) TestRefusesRedirectUrisOnSystemApplicatio
 	s.Equal([]string{"https://legit.example.com/callback"}, application.RedirectUris())
 }
 
+func (s *PatchApplicationCommandSuite) TestRefusesInvalidSamlCertificate() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+	virtualServer, project, virtualServerRepository, projectRepository := s.setupVSAndProject(ctrl, now)
+
+	application := repositories.NewApplication(virtualServer.Id(), project.Id(), "sp", "Service Provider", repositories.ApplicationTypeSaml, []string{})
+	application.Mock(now)
+	applicationRepository := mocks.NewMockApplicationRepository(ctrl)
+	applicationRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(application, nil)
+	// Update must NOT be called with an unusable certificate.
+
+	ctx := s.createContext(ctrl, virtualServerRepository, projectRepository, applicationRepository)
+	cmd := PatchApplication{
+		VirtualServerName: virtualServer.Name(),
+		ProjectSlug:       project.Slug(),
+		ApplicationId:     application.Id(),
+		SamlCertificate:   utils.Ptr("not a certificate"),
+	}
+
+	// act
+	resp, err := HandlePatchApplication(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+	s.Require().ErrorIs(err, utils.ErrHttpBadRequest)
+	s.Nil(application.SamlCertificate())
+}
+
 func (s *PatchApplicationCommandSuite) TestRefusesPostLogoutUrisOnSystemApplication() {
 	// arrange
 	ctrl := gomock.NewController(s.T())
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type PatchIdentityProvider struct {
+	VirtualServerName  string
+	IdentityProviderId uuid.UUID
+	DisplayName        *string
+	Issuer             *string
+	ClientId           *string
+	ClientSecret       *string
+	Scopes             []string
+	AuthorizationUrl   *string
+	TokenUrl           *string
+	UserinfoUrl        *string
+	ClaimMapping       *repositories.IdentityProviderClaimMapping
+	LinkByEmail        *bool
+	TrustUpstreamMfa   *bool
+}
+
+func (a PatchIdentityProvider) LogRequest() bool {
+	return false
+}
+
+func (a PatchIdentityProvider) LogResponse() bool {
+	return true
+}
+
+func (a PatchIdentityProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.IdentityProviderUpdate)
+}
+
+func (a PatchIdentityProvider) GetRequestName() string {
+	return "PatchIdentityProvider"
+}
+
+type PatchIdentityProviderResponse struct{}
+
+func HandlePatchIdentityProvider(ctx context.Context, command PatchIdentityProvider) (*PatchIdentityProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.IdentityProviderId)
+	identityProvider, err := dbContext.IdentityProviders().FirstOrErr(ctx, identityProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity provider: %w", err)
+	}
+
+	if command.DisplayName != nil {
+		identityProvider.SetDisplayName(*command.DisplayName)
+	}
+	if command.Issuer != nil {
+		identityProvider.SetIssuer(*command.Issuer)
+	}
+	if command.ClientId != nil {
+		identityProvider.SetClientId(*command.ClientId)
+	}
+	if command.ClientSecret != nil {
+		identityProvider.SetClientSecret(*command.ClientSecret)
+	}
+	if command.Scopes != nil {
+		identityProvider.SetScopes(command.Scopes)
+	}
+
+	// an empty endpoint url reverts to discovery
+	if command.AuthorizationUrl != nil {
+		identityProvider.SetAuthorizationUrl(utils.NilIfZero(*command.AuthorizationUrl))
+	}
+	if command.TokenUrl != nil {
+		identityProvider.SetTokenUrl(utils.NilIfZero(*command.TokenUrl))
+	}
+	if command.UserinfoUrl != nil {
+		identityProvider.SetUserinfoUrl(utils.NilIfZero(*command.UserinfoUrl))
+	}
+
+	if command.ClaimMapping != nil {
+		identityProvider.SetClaimMapping(command.ClaimMapping.WithDefaults())
+	}
+	if command.LinkByEmail != nil {
+		identityProvider.SetLinkByEmail(*command.LinkByEmail)
+	}
+	if command.TrustUpstreamMfa != nil {
+		identityProvider.SetTrustUpstreamMfa(*command.TrustUpstreamMfa)
+	}
+
+	dbContext.IdentityProviders().Update(identityProvider)
+	return &PatchIdentityProviderResponse{}, nil
+}
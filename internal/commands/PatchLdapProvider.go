@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type PatchLdapProvider struct {
+	VirtualServerName string
+	LdapProviderId    uuid.UUID
+	ConnectionUrl     *string
+	StartTls          *bool
+	BindDn            *string
+	BindPassword      *string
+	UsersDn           *string
+	UserFilter        *string
+	GroupsDn          *string
+	GroupFilter       *string
+	AttributeMapping  *repositories.LdapAttributeMapping
+	EditMode          *repositories.LdapEditMode
+	SyncInterval      *time.Duration
+}
+
+func (a PatchLdapProvider) LogRequest() bool {
+	return false
+}
+
+func (a PatchLdapProvider) LogResponse() bool {
+	return true
+}
+
+func (a PatchLdapProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderUpdate)
+}
+
+func (a PatchLdapProvider) GetRequestName() string {
+	return "PatchLdapProvider"
+}
+
+type PatchLdapProviderResponse struct{}
+
+func HandlePatchLdapProvider(ctx context.Context, command PatchLdapProvider) (*PatchLdapProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	if command.ConnectionUrl != nil {
+		ldapProvider.SetConnectionUrl(*command.ConnectionUrl)
+	}
+	if command.StartTls != nil {
+		ldapProvider.SetStartTls(*command.StartTls)
+	}
+	if command.BindDn != nil {
+		ldapProvider.SetBindDn(*command.BindDn)
+	}
+	if command.BindPassword != nil {
+		ldapProvider.SetBindPassword(*command.BindPassword)
+	}
+	if command.UsersDn != nil {
+		ldapProvider.SetUsersDn(*command.UsersDn)
+	}
+	if command.UserFilter != nil {
+		ldapProvider.SetUserFilter(*command.UserFilter)
+	}
+
+	// an empty groups dn turns group synchronization off
+	if command.GroupsDn != nil {
+		ldapProvider.SetGroupsDn(utils.NilIfZero(*command.GroupsDn))
+	}
+	if command.GroupFilter != nil {
+		ldapProvider.SetGroupFilter(*command.GroupFilter)
+	}
+
+	if command.AttributeMapping != nil {
+		ldapProvider.SetAttributeMapping(command.AttributeMapping.WithDefaults(ldapProvider.Vendor()))
+	}
+	if command.EditMode != nil {
+		ldapProvider.SetEditMode(*command.EditMode)
+	}
+	if command.SyncInterval != nil {
+		ldapProvider.SetSyncInterval(*command.SyncInterval)
+	}
+
+	dbContext.LdapProviders().Update(ldapProvider)
+	return &PatchLdapProviderResponse{}, nil
+}
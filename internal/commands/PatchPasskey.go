@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type PatchPasskey struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	PasskeyId         uuid.UUID
+	Name              *string
+}
+
+func (a PatchPasskey) LogRequest() bool {
+	return true
+}
+
+func (a PatchPasskey) LogResponse() bool {
+	return true
+}
+
+func (a PatchPasskey) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.UserUpdate)
+}
+
+func (a PatchPasskey) GetRequestName() string {
+	return "PatchPasskey"
+}
+
+type PatchPasskeyResponse struct{}
+
+func HandlePatchPasskey(ctx context.Context, command PatchPasskey) (*PatchPasskeyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credential, err := getPasskey(ctx, dbContext, command.VirtualServerName, command.UserId, command.PasskeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := credential.WebauthnDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	if command.Name != nil {
+		details.Name = *command.Name
+		credential.SetDetails(details)
+	}
+
+	dbContext.Credentials().Update(credential)
+	return &PatchPasskeyResponse{}, nil
+}
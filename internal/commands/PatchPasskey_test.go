@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type PatchPasskeyCommandSuite struct {
+	suite.Suite
+}
+
+func TestPatchPasskeyCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(PatchPasskeyCommandSuite))
+}
+
+func (s *PatchPasskeyCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *PatchPasskeyCommandSuite) TestRename() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	credential := repositories.NewCredential(user.Id(), &repositories.CredentialWebauthnDetails{
+		CredentialId: "credential-id",
+		Name:         "old name",
+		SignCount:    7,
+	})
+	credential.Mock(now)
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetId() == credential.Id() && x.GetType() == repositories.CredentialTypeWebauthn
+	})).Return(credential, nil)
+	credentialRepository.EXPECT().Update(gomock.Cond(func(x *repositories.Credential) bool {
+		details, err := x.WebauthnDetails()
+		return err == nil && details.Name == "new name" && details.SignCount == 7
+	}))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := PatchPasskey{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+		PasskeyId:         credential.Id(),
+		Name:              utils.Ptr("new name"),
+	}
+
+	// act
+	resp, err := HandlePatchPasskey(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
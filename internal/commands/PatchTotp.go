@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type PatchTotp struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	TotpId            uuid.UUID
+	Name              *string
+}
+
+func (a PatchTotp) LogRequest() bool {
+	return true
+}
+
+func (a PatchTotp) LogResponse() bool {
+	return true
+}
+
+func (a PatchTotp) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.TotpUpdate)
+}
+
+func (a PatchTotp) GetRequestName() string {
+	return "PatchTotp"
+}
+
+type PatchTotpResponse struct{}
+
+func HandlePatchTotp(ctx context.Context, command PatchTotp) (*PatchTotpResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credential, err := getTotp(ctx, dbContext, command.VirtualServerName, command.UserId, command.TotpId)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := credential.TotpDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	if command.Name != nil {
+		details.Name = *command.Name
+		credential.SetDetails(details)
+	}
+
+	dbContext.Credentials().Update(credential)
+	return &PatchTotpResponse{}, nil
+}
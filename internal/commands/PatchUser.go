@@ -6,10 +6,14 @@ import (
 	"github.com/The127/Keyline/internal/authentication/permissions"
 	"github.com/The127/Keyline/internal/behaviours"
 	db "github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/federation"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
 
 	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
 
 	"github.com/google/uuid"
 )
@@ -55,7 +59,11 @@ func HandlePatchUser(ctx context.Context, command PatchUser) (*PatchUserResponse
 		return nil, fmt.Errorf("getting user: %w", err)
 	}
 
-	if command.DisplayName != nil {
+	if command.DisplayName != nil && *command.DisplayName != user.DisplayName() {
+		err = writeLdapDisplayName(ctx, user, *command.DisplayName)
+		if err != nil {
+			return nil, err
+		}
 		user.SetDisplayName(*command.DisplayName)
 	}
 
@@ -64,5 +72,43 @@ func HandlePatchUser(ctx context.Context, command PatchUser) (*PatchUserResponse
 	}
 
 	dbContext.Users().Update(user)
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserUpdatedEvent{
+		User: user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("raising event: %w", err)
+	}
+
 	return &PatchUserResponse{}, nil
 }
+
+// writeLdapDisplayName keeps the directory in sync with display name changes
+// of directory users. Read-only directories would revert the change on the
+// next synchronization, so it is rejected instead.
+func writeLdapDisplayName(ctx context.Context, user *repositories.User, displayName string) error {
+	ldapProvider, ldapLink, err := getLdapLink(ctx, user.Id())
+	if err != nil {
+		return err
+	}
+	if ldapProvider == nil {
+		return nil
+	}
+	if ldapProvider.EditMode() != repositories.LdapEditModeWritable {
+		return utils.ErrLdapProviderReadOnly
+	}
+
+	client, err := federation.Dial(federation.ConfigFromProvider(ldapProvider))
+	if err != nil {
+		return fmt.Errorf("connecting to directory: %w", err)
+	}
+	defer utils.PanicOnError(client.Close, "closing ldap connection")
+
+	err = client.UpdateUser(ldapLink.Dn, user.PrimaryEmail(), displayName)
+	if err != nil {
+		return fmt.Errorf("updating directory user: %w", err)
+	}
+
+	return nil
+}
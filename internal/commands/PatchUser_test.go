@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
 	"github.com/The127/Keyline/internal/middlewares"
 	mocks2 "github.com/The127/Keyline/internal/mocks"
 	"github.com/The127/Keyline/internal/repositories"
@@ -13,6 +14,8 @@ import (
 	"time"
 
 	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	mediatrMocks "github.com/The127/mediatr/mocks"
 
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
@@ -31,6 +34,7 @@ func (s *PatchUserCommandSuite) createContext(
 	ctrl *gomock.Controller,
 	virtualServerRepository repositories.VirtualServerRepository,
 	userRepository repositories.UserRepository,
+	m *mediatrMocks.MockMediator,
 ) context.Context {
 	dc := ioc.NewDependencyCollection()
 
@@ -47,6 +51,12 @@ func (s *PatchUserCommandSuite) createContext(
 		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
 	}
 
+	if m != nil {
+		ioc.RegisterTransient(dc, func(_ *ioc.DependencyProvider) mediatr.Mediator {
+			return m
+		})
+	}
+
 	scope := dc.BuildProvider()
 	s.T().Cleanup(func() {
 		utils.PanicOnError(scope.Close, "closing scope")
@@ -78,7 +88,10 @@ func (s *PatchUserCommandSuite) TestHappyPath() {
 	})).Return(user, nil)
 	userRepository.EXPECT().Update(gomock.Any())
 
-	ctx := s.createContext(ctrl, virtualServerRepository, userRepository)
+	m := mediatrMocks.NewMockMediator(ctrl)
+	m.EXPECT().SendEvent(gomock.Any(), gomock.AssignableToTypeOf(events.UserUpdatedEvent{}), gomock.Any())
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, m)
 	cmd := PatchUser{
 		VirtualServerName: virtualServer.Name(),
 		UserId:            user.Id(),
@@ -105,7 +118,7 @@ func (s *PatchUserCommandSuite) TestUserError() {
 	userRepository := mocks.NewMockUserRepository(ctrl)
 	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, userRepository)
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
 	cmd := PatchUser{}
 
 	// act
@@ -124,7 +137,7 @@ func (s *PatchUserCommandSuite) TestVirtualServerError() {
 	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
 	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
 
-	ctx := s.createContext(ctrl, virtualServerRepository, nil)
+	ctx := s.createContext(ctrl, virtualServerRepository, nil, nil)
 	cmd := PatchUser{}
 
 	// act
@@ -134,3 +147,54 @@ func (s *PatchUserCommandSuite) TestVirtualServerError() {
 	s.Require().Error(err)
 	s.Nil(resp)
 }
+
+func (s *PatchUserCommandSuite) TestReadOnlyLdapUserDisplayNameIsRejected() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	ldapProvider := repositories.NewLdapProvider(virtualServer.Id(), "corp", repositories.LdapVendorGeneric, "ldap://ldap.example.com", "cn=keyline", "secret", "ou=people")
+	ldapProvider.Mock(now)
+	ldapProviderRepository := mocks.NewMockLdapProviderRepository(ctrl)
+	ldapProviderRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.LdapProviderFilter) bool {
+		return x.GetId() == ldapProvider.Id()
+	})).Return(ldapProvider, nil)
+
+	link := repositories.NewCredential(user.Id(), repositories.NewCredentialLdapDetails(ldapProvider.Id(), "external-id", "uid=user,ou=people"))
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() &&
+			x.GetType() == repositories.CredentialTypeLdap
+	})).Return(link, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
+	dbContext := ioc.GetDependency[database.Context](middlewares.GetScope(ctx)).(*mocks2.MockContext)
+	dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	dbContext.EXPECT().LdapProviders().Return(ldapProviderRepository).AnyTimes()
+
+	cmd := PatchUser{
+		VirtualServerName: virtualServer.Name(),
+		UserId:            user.Id(),
+		DisplayName:       utils.Ptr("Renamed"),
+	}
+
+	// act
+	resp, err := HandlePatchUser(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrLdapProviderReadOnly)
+	s.Nil(resp)
+	s.Equal("User", user.DisplayName())
+}
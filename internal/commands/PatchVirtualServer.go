@@ -11,9 +11,11 @@ import (
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/services"
 	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
+	"github.com/google/uuid"
 )
 
 type PatchVirtualServer struct {
@@ -23,9 +25,26 @@ type PatchVirtualServer struct {
 	EnableRegistration       *bool
 	Require2fa               *bool
 	RequireEmailVerification *bool
+	EnableEmailLogin         *bool
+	TrustedDeviceDays        *int
 
 	PrimarySigningAlgorithm     *config.SigningAlgorithm
 	AdditionalSigningAlgorithms *[]config.SigningAlgorithm
+
+	KeyRotateAfter    *time.Duration
+	KeyExpireAfter    *time.Duration
+	KeyPrePublishLead *time.Duration
+
+	PasskeyAllowedAaguids     *[]uuid.UUID
+	PasskeyRequireAttestation *bool
+	PasskeyUserVerification   *repositories.PasskeyUserVerification
+
+	LockoutMaxFailedAttempts      *int
+	LockoutMaxFailedAttemptsPerIp *int
+	LockoutDuration               *time.Duration
+	LockoutMaxDuration            *time.Duration
+	LockoutPermanentAfter         *int
+	LockoutNotifyUser             *bool
 }
 
 func (a PatchVirtualServer) LogRequest() bool {
@@ -72,6 +91,14 @@ func HandlePatchVirtualServer(ctx context.Context, command PatchVirtualServer) (
 		virtualServer.SetRequireEmailVerification(*command.RequireEmailVerification)
 	}
 
+	if command.EnableEmailLogin != nil {
+		virtualServer.SetEnableEmailLogin(*command.EnableEmailLogin)
+	}
+
+	if command.TrustedDeviceDays != nil {
+		virtualServer.SetTrustedDeviceDays(*command.TrustedDeviceDays)
+	}
+
 	if command.PrimarySigningAlgorithm != nil {
 		virtualServer.SetPrimarySigningAlgorithm(*command.PrimarySigningAlgorithm)
 	}
@@ -80,6 +107,74 @@ func HandlePatchVirtualServer(ctx context.Context, command PatchVirtualServer) (
 		virtualServer.SetAdditionalSigningAlgorithms(*command.AdditionalSigningAlgorithms)
 	}
 
+	if command.KeyRotateAfter != nil || command.KeyExpireAfter != nil || command.KeyPrePublishLead != nil {
+		policy := virtualServer.KeyRotationPolicy()
+		if command.KeyRotateAfter != nil {
+			policy.RotateAfter = *command.KeyRotateAfter
+		}
+		if command.KeyExpireAfter != nil {
+			policy.ExpireAfter = *command.KeyExpireAfter
+		}
+		if command.KeyPrePublishLead != nil {
+			policy.PrePublishLead = *command.KeyPrePublishLead
+		}
+
+		err = policy.Validate()
+		if err != nil {
+			return nil, err
+		}
+		virtualServer.SetKeyRotationPolicy(policy)
+	}
+
+	if command.PasskeyAllowedAaguids != nil || command.PasskeyRequireAttestation != nil || command.PasskeyUserVerification != nil {
+		policy := virtualServer.PasskeyPolicy()
+		if command.PasskeyAllowedAaguids != nil {
+			policy.AllowedAaguids = *command.PasskeyAllowedAaguids
+		}
+		if command.PasskeyRequireAttestation != nil {
+			policy.RequireAttestation = *command.PasskeyRequireAttestation
+		}
+		if command.PasskeyUserVerification != nil {
+			policy.UserVerification = *command.PasskeyUserVerification
+		}
+
+		err = policy.Validate()
+		if err != nil {
+			return nil, err
+		}
+		virtualServer.SetPasskeyPolicy(policy)
+	}
+
+	if command.LockoutMaxFailedAttempts != nil || command.LockoutMaxFailedAttemptsPerIp != nil ||
+		command.LockoutDuration != nil || command.LockoutMaxDuration != nil ||
+		command.LockoutPermanentAfter != nil || command.LockoutNotifyUser != nil {
+		policy := virtualServer.LockoutPolicy()
+		if command.LockoutMaxFailedAttempts != nil {
+			policy.MaxFailedAttempts = *command.LockoutMaxFailedAttempts
+		}
+		if command.LockoutMaxFailedAttemptsPerIp != nil {
+			policy.MaxFailedAttemptsPerIp = *command.LockoutMaxFailedAttemptsPerIp
+		}
+		if command.LockoutDuration != nil {
+			policy.LockoutDuration = *command.LockoutDuration
+		}
+		if command.LockoutMaxDuration != nil {
+			policy.MaxLockoutDuration = *command.LockoutMaxDuration
+		}
+		if command.LockoutPermanentAfter != nil {
+			policy.PermanentLockoutAfter = *command.LockoutPermanentAfter
+		}
+		if command.LockoutNotifyUser != nil {
+			policy.NotifyUser = *command.LockoutNotifyUser
+		}
+
+		err = policy.Validate()
+		if err != nil {
+			return nil, err
+		}
+		virtualServer.SetLockoutPolicy(policy)
+	}
+
 	if command.PrimarySigningAlgorithm != nil || command.AdditionalSigningAlgorithms != nil {
 		apps, _, err := dbContext.Applications().List(ctx, repositories.NewApplicationFilter().VirtualServerId(virtualServer.Id()))
 		if err != nil {
@@ -107,7 +202,7 @@ func HandlePatchVirtualServer(ctx context.Context, command PatchVirtualServer) (
 			if len(existing) > 0 {
 				continue
 			}
-			_, err = keyService.Generate(clockService, command.VirtualServerName, alg)
+			_, err = keyService.Generate(clockService, command.VirtualServerName, alg, virtualServer.KeyRotationPolicy())
 			if err != nil {
 				return nil, fmt.Errorf("generating key for algorithm %s: %w", alg, err)
 			}
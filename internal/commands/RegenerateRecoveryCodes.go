@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"encoding/base32"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"strings"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCodeCount is the number of recovery codes a user gets at once.
+const RecoveryCodeCount = 10
+
+// recoveryCodeBytes gives 80 random bits per code. That is enough to store
+// the codes with a fast hash like the secrets of sessions.
+const recoveryCodeBytes = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user with new
+// ones. The codes are only returned once, they are stored hashed. They are
+// only asked for in place of a totp code, so they do nothing for users
+// without totp.
+type RegenerateRecoveryCodes struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a RegenerateRecoveryCodes) LogRequest() bool {
+	return true
+}
+
+// LogResponse is false, the response holds the codes in plain text.
+func (a RegenerateRecoveryCodes) LogResponse() bool {
+	return false
+}
+
+func (a RegenerateRecoveryCodes) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.UserUpdate)
+}
+
+func (a RegenerateRecoveryCodes) GetRequestName() string {
+	return "RegenerateRecoveryCodes"
+}
+
+type RegenerateRecoveryCodesResponse struct {
+	RecoveryCodes []string
+}
+
+func HandleRegenerateRecoveryCodes(ctx context.Context, command RegenerateRecoveryCodes) (*RegenerateRecoveryCodesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	err = deleteCredentialsOfType(ctx, dbContext, user.Id(), repositories.CredentialTypeRecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		recoveryCode := generateRecoveryCode()
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+
+		dbContext.Credentials().Insert(repositories.NewCredential(user.Id(), &repositories.CredentialRecoveryCodeDetails{
+			HashedCode: utils.CheapHash(normalizeRecoveryCode(recoveryCode)),
+		}))
+	}
+
+	return &RegenerateRecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// generateRecoveryCode returns a code like ABCD-EFGH-IJKL-MNOP.
+func generateRecoveryCode() string {
+	encoded := recoveryCodeEncoding.EncodeToString(utils.GetSecureRandomBytes(recoveryCodeBytes))
+
+	var groups []string
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:min(i+4, len(encoded))])
+	}
+	return strings.Join(groups, "-")
+}
+
+// normalizeRecoveryCode accepts codes typed in lower case and without or
+// with other separators.
+func normalizeRecoveryCode(recoveryCode string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ':
+			return -1
+		default:
+			return r
+		}
+	}, strings.ToUpper(recoveryCode))
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type RegenerateRecoveryCodesCommandSuite struct {
+	suite.Suite
+}
+
+func TestRegenerateRecoveryCodesCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(RegenerateRecoveryCodesCommandSuite))
+}
+
+func (s *RegenerateRecoveryCodesCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *RegenerateRecoveryCodesCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	oldCode := repositories.NewCredential(user.Id(), &repositories.CredentialRecoveryCodeDetails{
+		HashedCode: utils.CheapHash("OLD"),
+	})
+	oldCode.Mock(now)
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetType() == repositories.CredentialTypeRecoveryCode
+	})).Return([]*repositories.Credential{oldCode}, nil)
+	credentialRepository.EXPECT().Delete(oldCode.Id())
+
+	var inserted []*repositories.Credential
+	credentialRepository.EXPECT().Insert(gomock.Any()).Do(func(credential *repositories.Credential) {
+		inserted = append(inserted, credential)
+	}).Times(RecoveryCodeCount)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := RegenerateRecoveryCodes{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+	}
+
+	// act
+	resp, err := HandleRegenerateRecoveryCodes(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(resp.RecoveryCodes, RecoveryCodeCount)
+	for i, recoveryCode := range resp.RecoveryCodes {
+		s.Regexp(`^[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}$`, recoveryCode)
+
+		details, err := inserted[i].RecoveryCodeDetails()
+		s.Require().NoError(err)
+		s.Equal(user.Id(), inserted[i].UserId())
+		s.True(utils.CheapCompareHash(normalizeRecoveryCode(recoveryCode), details.HashedCode))
+	}
+}
+
+func (s *RegenerateRecoveryCodesCommandSuite) TestUserError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
+	cmd := RegenerateRecoveryCodes{}
+
+	// act
+	resp, err := HandleRegenerateRecoveryCodes(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
+
+func (s *RegenerateRecoveryCodesCommandSuite) TestNormalizeRecoveryCode() {
+	s.Equal("ABCDEFGH", normalizeRecoveryCode("abcd-efgh"))
+	s.Equal("ABCDEFGH", normalizeRecoveryCode("ABCD EFGH"))
+}
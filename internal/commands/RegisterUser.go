@@ -45,18 +45,19 @@ func HandleRegisterUser(ctx context.Context, command RegisterUser) (*RegisterUse
 		return nil, utils.ErrRegistrationNotEnabled
 	}
 
-	passwordValidator := ioc.GetDependency[password.Validator](scope)
-	err = passwordValidator.Validate(ctx, command.Password)
-	if err != nil {
-		return nil, fmt.Errorf("password validation: %w", err)
-	}
-
 	user := repositories.NewUser(
 		command.Username,
 		command.DisplayName,
 		command.Email,
 		virtualServer.Id(),
 	)
+
+	passwordValidator := ioc.GetDependency[password.Validator](scope)
+	err = passwordValidator.Validate(ctx, command.Password, user)
+	if err != nil {
+		return nil, fmt.Errorf("password validation: %w", err)
+	}
+
 	dbContext.Users().Insert(user)
 
 	hashedPassword := utils.HashPassword(command.Password)
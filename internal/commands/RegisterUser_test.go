@@ -98,7 +98,7 @@ func (s *RegisterUserCommandSuite) TestHandleRegisterUser() {
 	credentialRepository.EXPECT().Insert(gomock.Any())
 
 	passwordValidator := mock.NewMockValidator(ctrl)
-	passwordValidator.EXPECT().Validate(gomock.Any(), gomock.Any()).Return(nil)
+	passwordValidator.EXPECT().Validate(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 
 	m := mediatr.NewMediator()
 
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// ReplaceScimGroup renames a group and replaces its members.
+type ReplaceScimGroup struct {
+	VirtualServerName string
+	GroupId           uuid.UUID
+	DisplayName       string
+	Members           []uuid.UUID
+}
+
+func (a ReplaceScimGroup) LogRequest() bool {
+	return true
+}
+
+func (a ReplaceScimGroup) LogResponse() bool {
+	return true
+}
+
+func (a ReplaceScimGroup) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a ReplaceScimGroup) GetRequestName() string {
+	return "ReplaceScimGroup"
+}
+
+type ReplaceScimGroupResponse struct{}
+
+func HandleReplaceScimGroup(ctx context.Context, command ReplaceScimGroup) (*ReplaceScimGroupResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.GroupId)
+	group, err := dbContext.Groups().FirstOrErr(ctx, groupFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting group: %w", err)
+	}
+
+	err = ensureScimGroupNameIsFree(ctx, virtualServer, command.DisplayName, group.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	group.SetName(command.DisplayName)
+	dbContext.Groups().Update(group)
+
+	existing, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().GroupId(group.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing group members: %w", err)
+	}
+
+	err = setScimGroupMembers(ctx, virtualServer, group.Id(), existing, command.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplaceScimGroupResponse{}, nil
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+)
+
+// ReplaceScimUser overwrites the provisioned attributes of a user. Direct
+// role assignments outside the system project are replaced by Roles, group
+// memberships are managed through the group endpoints.
+type ReplaceScimUser struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+	Username          string
+	DisplayName       string
+	Email             string
+	Active            bool
+	Roles             []string
+}
+
+func (a ReplaceScimUser) LogRequest() bool {
+	return true
+}
+
+func (a ReplaceScimUser) LogResponse() bool {
+	return true
+}
+
+func (a ReplaceScimUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a ReplaceScimUser) GetRequestName() string {
+	return "ReplaceScimUser"
+}
+
+type ReplaceScimUserResponse struct{}
+
+func HandleReplaceScimUser(ctx context.Context, command ReplaceScimUser) (*ReplaceScimUserResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		ServiceUser(false).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	err = ensureScimManagesUser(ctx, user.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Username() != command.Username {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeMutability, "userName cannot be changed")
+	}
+
+	roleIds, err := resolveScimRoles(ctx, virtualServer, command.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	user.SetDisplayName(command.DisplayName)
+	user.SetPrimaryEmail(command.Email)
+	user.SetDisabled(!command.Active)
+	dbContext.Users().Update(user)
+
+	assignments, err := scimRoleAssignments(ctx, user.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[uuid.UUID]bool, len(roleIds))
+	for _, roleId := range roleIds {
+		desired[roleId] = true
+	}
+
+	for _, assignment := range assignments {
+		if desired[assignment.RoleId()] {
+			delete(desired, assignment.RoleId())
+			continue
+		}
+		dbContext.UserRoleAssignments().Delete(assignment.Id())
+	}
+
+	for _, roleId := range roleIds {
+		if desired[roleId] {
+			dbContext.UserRoleAssignments().Insert(repositories.NewUserRoleAssignment(user.Id(), roleId, nil))
+		}
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	err = mediatr.SendEvent(ctx, m, events.UserUpdatedEvent{
+		User: user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("raising event: %w", err)
+	}
+
+	return &ReplaceScimUserResponse{}, nil
+}
+
+// ensureScimManagesUser refuses users that hold a role of the system
+// project, directly or through a group. A provisioning client must not be
+// able to change the email of an administrator and take the account over
+// with a password reset.
+func ensureScimManagesUser(ctx context.Context, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	assignmentFilter := repositories.NewUserRoleAssignmentFilter().
+		UserId(userId).
+		IncludeRole()
+	assignments, _, err := dbContext.UserRoleAssignments().List(ctx, assignmentFilter)
+	if err != nil {
+		return fmt.Errorf("listing role assignments: %w", err)
+	}
+
+	for _, assignment := range assignments {
+		if assignment.RoleInfo() != nil && assignment.RoleInfo().ProjectSlug == repositories.SystemProjectSlug {
+			return scim.NewError(http.StatusForbidden, "", "users with system roles cannot be managed through SCIM")
+		}
+	}
+
+	return nil
+}
+
+// scimRoleAssignments returns the direct role assignments of a user that
+// SCIM manages, i.e. those outside the system project.
+func scimRoleAssignments(ctx context.Context, userId uuid.UUID) ([]*repositories.UserRoleAssignment, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	assignmentFilter := repositories.NewUserRoleAssignmentFilter().
+		UserId(userId).
+		IncludeRole()
+	assignments, _, err := dbContext.UserRoleAssignments().List(ctx, assignmentFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing role assignments: %w", err)
+	}
+
+	var managed []*repositories.UserRoleAssignment
+	for _, assignment := range assignments {
+		if assignment.GroupId() != nil || assignment.RoleInfo() == nil {
+			continue
+		}
+		if assignment.RoleInfo().ProjectSlug == repositories.SystemProjectSlug {
+			continue
+		}
+		managed = append(managed, assignment)
+	}
+
+	return managed, nil
+}
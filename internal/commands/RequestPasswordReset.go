@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/templates"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetTokenExpiry is how long a password reset link can be used.
+const PasswordResetTokenExpiry = time.Hour
+
+type RequestPasswordReset struct {
+	VirtualServerName string
+	UsernameOrEmail   string
+}
+
+// Anyone who forgot their password must be able to request a reset.
+// Because of that we don't need to check permissions here.
+
+func (a RequestPasswordReset) GetRequestName() string {
+	return "RequestPasswordReset"
+}
+
+type RequestPasswordResetResponse struct{}
+
+// HandleRequestPasswordReset mails a single-use reset link to the user. It
+// succeeds without sending anything if there is no such user, so that the
+// response does not reveal which accounts exist.
+func HandleRequestPasswordReset(ctx context.Context, command RequestPasswordReset) (*RequestPasswordResetResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	user, err := findPasswordResetUser(ctx, dbContext, virtualServer.Id(), command.UsernameOrEmail)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Disabled() {
+		return &RequestPasswordResetResponse{}, nil
+	}
+
+	// throttled requests succeed the same way, the response must not tell
+	// that the user exists
+	mailThrottle := ioc.GetDependency[services.MailThrottle](scope)
+	err = mailThrottle.Acquire(ctx, repositories.PasswordResetMailTemplate, user.Id())
+	switch {
+	case errors.Is(err, services.ErrMailThrottled):
+		return &RequestPasswordResetResponse{}, nil
+
+	case err != nil:
+		return nil, err
+	}
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	token, err := tokenService.GenerateAndStoreToken(ctx, services.PasswordResetTokenType, user.Id().String(), PasswordResetTokenExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("storing password reset token: %w", err)
+	}
+
+	templateService := ioc.GetDependency[services.TemplateService](scope)
+	mailBody, err := templateService.Template(
+		ctx,
+		virtualServer.Id(),
+		repositories.PasswordResetMailTemplate,
+		templates.PasswordResetTemplateData{
+			ResetLink: fmt.Sprintf(
+				"%s/%s/reset-password?token=%s",
+				config.C.Frontend.ExternalUrl,
+				virtualServer.Name(),
+				token,
+			),
+			ExpiresIn: fmt.Sprintf("%d minutes", int(PasswordResetTokenExpiry.Minutes())),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("templating password reset mail: %w", err)
+	}
+
+	message := &messages.SendEmailMessage{
+		VirtualServerId: virtualServer.Id(),
+		To:              user.PrimaryEmail(),
+		Subject:         "Password reset",
+		Body:            mailBody,
+	}
+
+	outboxMessage, err := repositories.NewOutboxMessage(message)
+	if err != nil {
+		return nil, fmt.Errorf("creating email outbox message: %w", err)
+	}
+
+	dbContext.OutboxMessages().Insert(outboxMessage)
+	return &RequestPasswordResetResponse{}, nil
+}
+
+// findPasswordResetUser looks the user up by username first and by primary
+// email second. Service users have no password to reset.
+func findPasswordResetUser(ctx context.Context, dbContext database.Context, virtualServerId uuid.UUID, usernameOrEmail string) (*repositories.User, error) {
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServerId).
+		ServiceUser(false)
+
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter.Username(usernameOrEmail))
+	if err != nil {
+		return nil, fmt.Errorf("getting user by username: %w", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = dbContext.Users().FirstOrNil(ctx, userFilter.PrimaryEmail(usernameOrEmail))
+	if err != nil {
+		return nil, fmt.Errorf("getting user by email: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/templates"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type RequestPasswordResetCommandSuite struct {
+	suite.Suite
+}
+
+func TestRequestPasswordResetCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(RequestPasswordResetCommandSuite))
+}
+
+func (s *RequestPasswordResetCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	outboxMessageRepository repositories.OutboxMessageRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if outboxMessageRepository != nil {
+		dbContext.EXPECT().OutboxMessages().Return(outboxMessageRepository).AnyTimes()
+	}
+
+	file := repositories.NewFile("password_reset_template", "text/plain", templates.DefaultPasswordResetTemplate)
+	fileRepository := mocks.NewMockFileRepository(ctrl)
+	fileRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(file, nil).AnyTimes()
+	dbContext.EXPECT().Files().Return(fileRepository).AnyTimes()
+
+	templateRepository := mocks.NewMockTemplateRepository(ctrl)
+	templateRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, filter *repositories.TemplateFilter) (*repositories.Template, error) {
+		return repositories.NewTemplate(filter.GetVirtualServerId(), file.Id(), filter.GetTemplateType()), nil
+	}).AnyTimes()
+	dbContext.EXPECT().Templates().Return(templateRepository).AnyTimes()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	store := keyValue.NewMemoryStore()
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) keyValue.Store {
+		return store
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.TokenService {
+		return services.NewTokenService()
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.TemplateService {
+		return services.NewTemplateService()
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.MailThrottle {
+		return services.NewMailThrottle()
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *RequestPasswordResetCommandSuite) TestSendsMailForEmail() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.HasUsername() && x.GetUsername() == "user@mail"
+	})).Return(nil, nil)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetPrimaryEmail() == "user@mail" &&
+			x.GetVirtualServerId() == virtualServer.Id() &&
+			x.HasServiceUser() && !x.GetServiceUser()
+	})).Return(user, nil)
+
+	var sent *messages.SendEmailMessage
+	outboxMessageRepository := mocks.NewMockOutboxMessageRepository(ctrl)
+	outboxMessageRepository.EXPECT().Insert(gomock.Any()).Do(func(outboxMessage *repositories.OutboxMessage) {
+		sent = &messages.SendEmailMessage{}
+		s.Require().NoError(json.Unmarshal(outboxMessage.Details(), sent))
+	})
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, outboxMessageRepository)
+	cmd := RequestPasswordReset{
+		VirtualServerName: "virtualServer",
+		UsernameOrEmail:   "user@mail",
+	}
+
+	// act
+	resp, err := HandleRequestPasswordReset(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+	s.Require().NotNil(sent)
+	s.Equal("user@mail", sent.To)
+	s.Contains(sent.Body, "/virtualServer/reset-password?token=")
+}
+
+func (s *RequestPasswordResetCommandSuite) TestUnknownUserSendsNothing() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
+	cmd := RequestPasswordReset{
+		VirtualServerName: "virtualServer",
+		UsernameOrEmail:   "nobody",
+	}
+
+	// act
+	resp, err := HandleRequestPasswordReset(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
+
+func (s *RequestPasswordResetCommandSuite) TestThrottledRequestSendsNothing() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil).Times(2)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(user, nil).Times(2)
+
+	outboxMessageRepository := mocks.NewMockOutboxMessageRepository(ctrl)
+	outboxMessageRepository.EXPECT().Insert(gomock.Any()).Times(1)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, outboxMessageRepository)
+	cmd := RequestPasswordReset{
+		VirtualServerName: "virtualServer",
+		UsernameOrEmail:   "user",
+	}
+	_, err := HandleRequestPasswordReset(ctx, cmd)
+	s.Require().NoError(err)
+
+	// act
+	resp, err := HandleRequestPasswordReset(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/password"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ResetPassword struct {
+	VirtualServerName string
+	Token             string
+	NewPassword       string
+}
+
+// The password reset token proves that the caller may set the password,
+// so we don't need to check permissions here.
+
+func (a ResetPassword) GetRequestName() string {
+	return "ResetPassword"
+}
+
+type ResetPasswordResponse struct{}
+
+// HandleResetPassword sets a new password with a token from a password reset
+// mail. The token can only be used once and all sessions and refresh tokens
+// of the user are revoked, they might have been obtained with the old
+// password.
+func HandleResetPassword(ctx context.Context, command ResetPassword) (*ResetPasswordResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	value, err := tokenService.GetToken(ctx, services.PasswordResetTokenType, command.Token)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		return nil, utils.ErrInvalidPasswordResetToken
+
+	case err != nil:
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+
+	userId, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing value: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(userId)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	if user == nil || user.Disabled() {
+		return nil, utils.ErrInvalidPasswordResetToken
+	}
+
+	passwordValidator := ioc.GetDependency[password.Validator](scope)
+	err = passwordValidator.Validate(ctx, command.NewPassword, user)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utils.ErrPasswordInvalid, err)
+	}
+
+	// the token is only taken once the new password is valid, so that a
+	// rejected password does not use it up. Of concurrent requests with
+	// the same token only one can take it.
+	_, err = tokenService.TakeToken(ctx, services.PasswordResetTokenType, command.Token)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		return nil, utils.ErrInvalidPasswordResetToken
+
+	case err != nil:
+		return nil, fmt.Errorf("taking token: %w", err)
+	}
+
+	err = setUserPassword(ctx, user.Id(), command.NewPassword, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = revokeUserSessions(ctx, virtualServer, user.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResetPasswordResponse{}, nil
+}
+
+// revokeUserSessions signs the user out everywhere: their sessions are
+// deleted and their refresh tokens can no longer be redeemed.
+func revokeUserSessions(ctx context.Context, virtualServer *repositories.VirtualServer, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	sessionFilter := repositories.NewSessionFilter().
+		VirtualServerId(virtualServer.Id()).
+		UserId(userId)
+	sessions, err := dbContext.Sessions().List(ctx, sessionFilter)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+
+	sessionService := ioc.GetDependency[middlewares.SessionService](scope)
+	for _, session := range sessions {
+		err = sessionService.DeleteSession(ctx, virtualServer.Name(), session.Id())
+		if err != nil {
+			return fmt.Errorf("deleting session: %w", err)
+		}
+	}
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	err = tokenService.RevokeRefreshTokens(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+
+	return nil
+}
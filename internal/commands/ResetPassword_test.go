@@ -0,0 +1,294 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/password"
+	"github.com/The127/Keyline/internal/password/mock"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type ResetPasswordCommandSuite struct {
+	suite.Suite
+}
+
+func TestResetPasswordCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ResetPasswordCommandSuite))
+}
+
+func (s *ResetPasswordCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+	sessionRepository repositories.SessionRepository,
+	validator password.Validator,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	if sessionRepository != nil {
+		dbContext.EXPECT().Sessions().Return(sessionRepository).AnyTimes()
+	}
+
+	if validator != nil {
+		ioc.RegisterTransient(dc, func(_ *ioc.DependencyProvider) password.Validator {
+			return validator
+		})
+	}
+
+	clockService, _ := clock.NewMockClock(time.Now())
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	store := keyValue.NewMemoryStore()
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) keyValue.Store {
+		return store
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.TokenService {
+		return services.NewTokenService()
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) middlewares.SessionService {
+		return services.NewSessionService()
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *ResetPasswordCommandSuite) storeToken(ctx context.Context, value string) string {
+	tokenService := ioc.GetDependency[services.TokenService](middlewares.GetScope(ctx))
+	token, err := tokenService.GenerateAndStoreToken(ctx, services.PasswordResetTokenType, value, time.Hour)
+	s.Require().NoError(err)
+	return token
+}
+
+func (s *ResetPasswordCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil).AnyTimes()
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	hashedOldPassword := utils.HashPassword("old-password")
+	credential := repositories.NewCredential(user.Id(), &repositories.CredentialPasswordDetails{
+		HashedPassword: hashedOldPassword,
+	})
+	credential.Mock(now)
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	credentialRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetType() == repositories.CredentialTypeLdap
+	})).Return(nil, nil)
+	credentialRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetType() == repositories.CredentialTypePassword
+	})).Return(credential, nil)
+	credentialRepository.EXPECT().Update(credential)
+
+	trustedDevice := repositories.NewCredential(user.Id(), &repositories.CredentialTrustedDeviceDetails{
+		ExpiresAt: now.Add(time.Hour),
+	})
+	trustedDevice.Mock(now)
+	credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetType() == repositories.CredentialTypeTrustedDevice
+	})).Return([]*repositories.Credential{trustedDevice}, nil)
+	credentialRepository.EXPECT().Delete(trustedDevice.Id())
+
+	session := repositories.NewSession(virtualServer.Id(), user.Id(), now.Add(time.Hour), nil)
+	session.Mock(now)
+	sessionRepository := mocks.NewMockSessionRepository(ctrl)
+	sessionRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.SessionFilter) bool {
+		return x.GetUserId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return([]*repositories.Session{session}, nil)
+	sessionRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(session, nil)
+	sessionRepository.EXPECT().Delete(session.Id())
+
+	passwordValidator := mock.NewMockValidator(ctrl)
+	passwordValidator.EXPECT().Validate(gomock.Any(), "new-password", user).Return(nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository, sessionRepository, passwordValidator)
+	token := s.storeToken(ctx, user.Id().String())
+
+	cmd := ResetPassword{
+		VirtualServerName: "virtualServer",
+		Token:             token,
+		NewPassword:       "new-password",
+	}
+
+	// act
+	resp, err := HandleResetPassword(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+
+	details, err := credential.PasswordDetails()
+	s.Require().NoError(err)
+	s.True(utils.CompareHash("new-password", details.HashedPassword))
+	s.Equal([]string{hashedOldPassword}, details.History)
+	s.NotNil(details.ChangedAt)
+
+	tokenService := ioc.GetDependency[services.TokenService](middlewares.GetScope(ctx))
+	_, err = tokenService.GetToken(ctx, services.PasswordResetTokenType, token)
+	s.ErrorIs(err, services.ErrTokenNotFound)
+
+	revokedAt, err := tokenService.RefreshTokensRevokedAt(ctx, user.Id())
+	s.Require().NoError(err)
+	s.NotNil(revokedAt)
+}
+
+func (s *ResetPasswordCommandSuite) TestInvalidToken() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, nil, nil, nil, nil)
+	cmd := ResetPassword{
+		VirtualServerName: "virtualServer",
+		Token:             "unknown",
+		NewPassword:       "new-password",
+	}
+
+	// act
+	resp, err := HandleResetPassword(ctx, cmd)
+
+	// assert
+	s.ErrorIs(err, utils.ErrInvalidPasswordResetToken)
+	s.Nil(resp)
+}
+
+func (s *ResetPasswordCommandSuite) TestInvalidPasswordKeepsToken() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	passwordValidator := mock.NewMockValidator(ctrl)
+	passwordValidator.EXPECT().Validate(gomock.Any(), gomock.Any(), user).Return(errors.New("password is a common password"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil, nil, passwordValidator)
+	token := s.storeToken(ctx, user.Id().String())
+
+	cmd := ResetPassword{
+		VirtualServerName: "virtualServer",
+		Token:             token,
+		NewPassword:       "password",
+	}
+
+	// act
+	resp, err := HandleResetPassword(ctx, cmd)
+
+	// assert
+	s.ErrorIs(err, utils.ErrPasswordInvalid)
+	s.Nil(resp)
+
+	tokenService := ioc.GetDependency[services.TokenService](middlewares.GetScope(ctx))
+	_, err = tokenService.GetToken(ctx, services.PasswordResetTokenType, token)
+	s.Require().NoError(err)
+}
+
+func (s *ResetPasswordCommandSuite) TestTokenTakenConcurrently() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(user, nil)
+
+	// no password is set, so the credential repository must not be used
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+
+	var token string
+	passwordValidator := mock.NewMockValidator(ctrl)
+	passwordValidator.EXPECT().Validate(gomock.Any(), gomock.Any(), user).DoAndReturn(func(ctx context.Context, _ string, _ *repositories.User) error {
+		// another request with the same token takes it in the meantime
+		tokenService := ioc.GetDependency[services.TokenService](middlewares.GetScope(ctx))
+		_, err := tokenService.TakeToken(ctx, services.PasswordResetTokenType, token)
+		s.Require().NoError(err)
+		return nil
+	})
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository, nil, passwordValidator)
+	token = s.storeToken(ctx, user.Id().String())
+
+	cmd := ResetPassword{
+		VirtualServerName: "virtualServer",
+		Token:             token,
+		NewPassword:       "new-password",
+	}
+
+	// act
+	resp, err := HandleResetPassword(ctx, cmd)
+
+	// assert
+	s.ErrorIs(err, utils.ErrInvalidPasswordResetToken)
+	s.Nil(resp)
+}
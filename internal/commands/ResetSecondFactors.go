@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// secondFactorCredentialTypes are removed when the second factors of a
+// user are reset.
+var secondFactorCredentialTypes = []repositories.CredentialType{
+	repositories.CredentialTypeTotp,
+	repositories.CredentialTypeRecoveryCode,
+	repositories.CredentialTypeWebauthn,
+	repositories.CredentialTypePhone,
+	repositories.CredentialTypeTrustedDevice,
+}
+
+// ResetSecondFactors removes all second factors of a user, e.g. after they
+// lost their phone. The user has to onboard a new authenticator at their
+// next login, even if the virtual server does not require 2fa.
+type ResetSecondFactors struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a ResetSecondFactors) LogRequest() bool {
+	return true
+}
+
+func (a ResetSecondFactors) LogResponse() bool {
+	return true
+}
+
+func (a ResetSecondFactors) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.UserReset2fa)
+}
+
+func (a ResetSecondFactors) GetRequestName() string {
+	return "ResetSecondFactors"
+}
+
+type ResetSecondFactorsResponse struct{}
+
+func HandleResetSecondFactors(ctx context.Context, command ResetSecondFactors) (*ResetSecondFactorsResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	for _, credentialType := range secondFactorCredentialTypes {
+		err = deleteCredentialsOfType(ctx, dbContext, user.Id(), credentialType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user.SetRequire2faOnboarding(true)
+	dbContext.Users().Update(user)
+
+	return &ResetSecondFactorsResponse{}, nil
+}
+
+// deleteCredentialsOfType deletes all credentials of the type of a user.
+func deleteCredentialsOfType(ctx context.Context, dbContext database.Context, userId uuid.UUID, credentialType repositories.CredentialType) error {
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(userId).
+		Type(credentialType)
+	credentials, err := dbContext.Credentials().List(ctx, credentialFilter)
+	if err != nil {
+		return fmt.Errorf("listing %s credentials: %w", credentialType, err)
+	}
+
+	for _, credential := range credentials {
+		dbContext.Credentials().Delete(credential.Id())
+	}
+
+	return nil
+}
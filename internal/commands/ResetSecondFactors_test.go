@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type ResetSecondFactorsCommandSuite struct {
+	suite.Suite
+}
+
+func TestResetSecondFactorsCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ResetSecondFactorsCommandSuite))
+}
+
+func (s *ResetSecondFactorsCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	credentialRepository repositories.CredentialRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	if credentialRepository != nil {
+		dbContext.EXPECT().Credentials().Return(credentialRepository).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *ResetSecondFactorsCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+	userRepository.EXPECT().Update(gomock.Cond(func(x *repositories.User) bool {
+		return x.Require2faOnboarding()
+	}))
+
+	credentialRepository := mocks.NewMockCredentialRepository(ctrl)
+	for _, credentialType := range secondFactorCredentialTypes {
+		credential := repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{})
+		credentialRepository.EXPECT().List(gomock.Any(), gomock.Cond(func(x *repositories.CredentialFilter) bool {
+			return x.GetUserId() == user.Id() && x.GetType() == credentialType
+		})).Return([]*repositories.Credential{credential}, nil)
+		credentialRepository.EXPECT().Delete(credential.Id())
+	}
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, credentialRepository)
+	cmd := ResetSecondFactors{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+	}
+
+	// act
+	resp, err := HandleResetSecondFactors(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
+
+func (s *ResetSecondFactorsCommandSuite) TestUserError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, nil)
+	cmd := ResetSecondFactors{}
+
+	// act
+	resp, err := HandleResetSecondFactors(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/events"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// ResyncProvisioningTarget queues every user of the virtual server for
+// delivery to the target. Users without a role in the application's project
+// are removed from the service provider if they are found there.
+type ResyncProvisioningTarget struct {
+	VirtualServerName string
+	ProjectSlug       string
+	ApplicationId     uuid.UUID
+}
+
+func (a ResyncProvisioningTarget) LogRequest() bool {
+	return true
+}
+
+func (a ResyncProvisioningTarget) LogResponse() bool {
+	return true
+}
+
+func (a ResyncProvisioningTarget) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ApplicationUpdate)
+}
+
+func (a ResyncProvisioningTarget) GetRequestName() string {
+	return "ResyncProvisioningTarget"
+}
+
+type ResyncProvisioningTargetResponse struct {
+	QueuedUsers int
+}
+
+func HandleResyncProvisioningTarget(ctx context.Context, command ResyncProvisioningTarget) (*ResyncProvisioningTargetResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	application, err := getProvisionedApplication(ctx, command.VirtualServerName, command.ProjectSlug, command.ApplicationId)
+	if err != nil {
+		return nil, err
+	}
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(application.VirtualServerId()).
+		ApplicationId(application.Id())
+	target, err := dbContext.ProvisioningTargets().FirstOrErr(ctx, targetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting provisioning target: %w", err)
+	}
+	if !target.Enabled() {
+		return nil, fmt.Errorf("provisioning target is disabled: %w", utils.ErrHttpBadRequest)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(application.VirtualServerId()).
+		ServiceUser(false)
+	users, _, err := dbContext.Users().List(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+
+	for _, user := range users {
+		err = events.QueueTargetProvisioning(ctx, target, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ResyncProvisioningTargetResponse{
+		QueuedUsers: len(users),
+	}, nil
+}
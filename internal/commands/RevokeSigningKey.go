@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+// RevokeSigningKey removes a compromised key. It stops signing and
+// disappears from the JWKS immediately on every instance, so tokens it
+// signed no longer verify. If the key was signing
+// tokens, a replacement is generated right away.
+type RevokeSigningKey struct {
+	VirtualServerName string
+	Kid               string
+}
+
+func (a RevokeSigningKey) LogRequest() bool {
+	return true
+}
+
+func (a RevokeSigningKey) LogResponse() bool {
+	return true
+}
+
+func (a RevokeSigningKey) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerUpdate)
+}
+
+func (a RevokeSigningKey) GetRequestName() string {
+	return "RevokeSigningKey"
+}
+
+type RevokeSigningKeyResponse struct {
+	// ReplacementKid is set when the revoked key was the active one.
+	ReplacementKid *string
+}
+
+func HandleRevokeSigningKey(ctx context.Context, command RevokeSigningKey) (*RevokeSigningKeyResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	keyPairs, err := keyService.GetAllKeys(virtualServer.Name())
+	if err != nil {
+		return nil, fmt.Errorf("getting keys: %w", err)
+	}
+
+	var revoked *services.KeyPair
+	for _, keyPair := range keyPairs {
+		if keyPair.GetKid() == command.Kid {
+			revoked = &keyPair
+			break
+		}
+	}
+	if revoked == nil {
+		return nil, utils.ErrSigningKeyNotFound
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	state := services.KeyPairState(keyPairs, *revoked, clockService.Now())
+
+	err = keyService.Revoke(virtualServer.Name(), revoked.Algorithm(), revoked.GetKid())
+	if err != nil {
+		return nil, fmt.Errorf("revoking key: %w", err)
+	}
+
+	response := &RevokeSigningKeyResponse{}
+	if state == services.KeyStateActive {
+		replacement, err := keyService.Rotate(clockService, virtualServer.Name(), revoked.Algorithm(), virtualServer.KeyRotationPolicy())
+		if err != nil {
+			return nil, fmt.Errorf("generating replacement key: %w", err)
+		}
+		response.ReplacementKid = utils.Ptr(replacement.GetKid())
+	}
+
+	return response, nil
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/internal/services"
+	serviceMocks "github.com/The127/Keyline/internal/services/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type RevokeSigningKeyCommandSuite struct {
+	suite.Suite
+}
+
+func TestRevokeSigningKeyCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(RevokeSigningKeyCommandSuite))
+}
+
+func (s *RevokeSigningKeyCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	keyService services.KeyService,
+	clockService clock.Service,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) services.KeyService {
+		return keyService
+	})
+
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *RevokeSigningKeyCommandSuite) TestRevokingActiveKeyGeneratesReplacement() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(clockService.Now())
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	policy := virtualServer.KeyRotationPolicy()
+	keyPair, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, policy)
+	s.Require().NoError(err)
+	replacement, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, policy)
+	s.Require().NoError(err)
+
+	keyService := serviceMocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().GetAllKeys("virtualServer").Return([]services.KeyPair{keyPair}, nil)
+	keyService.EXPECT().Revoke("virtualServer", config.SigningAlgorithmEdDSA, keyPair.GetKid())
+	keyService.EXPECT().Rotate(clockService, "virtualServer", config.SigningAlgorithmEdDSA, policy).Return(replacement, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := RevokeSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               keyPair.GetKid(),
+	}
+
+	// act
+	resp, err := HandleRevokeSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.Equal(utils.Ptr(replacement.GetKid()), resp.ReplacementKid)
+}
+
+func (s *RevokeSigningKeyCommandSuite) TestUnknownKid() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	keyService := serviceMocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().GetAllKeys("virtualServer").Return(nil, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, keyService, clockService)
+	cmd := RevokeSigningKey{
+		VirtualServerName: "virtualServer",
+		Kid:               "unknown",
+	}
+
+	// act
+	resp, err := HandleRevokeSigningKey(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrSigningKeyNotFound)
+	s.Nil(resp)
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+// RotateSigningKeys replaces the active signing keys of a virtual server
+// right away instead of waiting for the rotation policy. The previous keys
+// stay published until they expire. Without an algorithm every configured
+// algorithm is rotated.
+type RotateSigningKeys struct {
+	VirtualServerName string
+	Algorithm         *config.SigningAlgorithm
+}
+
+func (a RotateSigningKeys) LogRequest() bool {
+	return true
+}
+
+func (a RotateSigningKeys) LogResponse() bool {
+	return true
+}
+
+func (a RotateSigningKeys) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerUpdate)
+}
+
+func (a RotateSigningKeys) GetRequestName() string {
+	return "RotateSigningKeys"
+}
+
+type RotateSigningKeysResponse struct {
+	Keys []RotateSigningKeysResponseKey
+}
+
+type RotateSigningKeysResponseKey struct {
+	Algorithm config.SigningAlgorithm
+	Kid       string
+}
+
+func HandleRotateSigningKeys(ctx context.Context, command RotateSigningKeys) (*RotateSigningKeysResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	algorithms := virtualServer.AllSigningAlgorithms()
+	if command.Algorithm != nil {
+		if !virtualServer.HasSigningAlgorithm(*command.Algorithm) {
+			return nil, fmt.Errorf("algorithm %s is not configured on the virtual server: %w", *command.Algorithm, utils.ErrHttpBadRequest)
+		}
+		algorithms = []config.SigningAlgorithm{*command.Algorithm}
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	response := &RotateSigningKeysResponse{}
+	for _, alg := range algorithms {
+		keyPair, err := keyService.Rotate(clockService, virtualServer.Name(), alg, virtualServer.KeyRotationPolicy())
+		if err != nil {
+			return nil, fmt.Errorf("rotating key for algorithm %s: %w", alg, err)
+		}
+
+		response.Keys = append(response.Keys, RotateSigningKeysResponseKey{
+			Algorithm: alg,
+			Kid:       keyPair.GetKid(),
+		})
+	}
+
+	return response, nil
+}
@@ -6,10 +6,12 @@ import (
 	"github.com/The127/Keyline/internal/authentication/permissions"
 	"github.com/The127/Keyline/internal/behaviours"
 	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/federation"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/utils"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 	"github.com/google/uuid"
 )
@@ -40,32 +42,56 @@ func (a SetPassword) GetRequestName() string {
 type SetPasswordResponse struct{}
 
 func HandleSetPassword(ctx context.Context, command SetPassword) (*SetPasswordResponse, error) {
+	err := setUserPassword(ctx, command.UserId, command.NewPassword, command.Temporary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetPasswordResponse{}, nil
+}
+
+// setUserPassword replaces the password of a user, directory users get it
+// written back to their directory.
+func setUserPassword(ctx context.Context, userId uuid.UUID, newPassword string, temporary bool) error {
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
-	hashedPassword := utils.HashPassword(command.NewPassword)
+	ldapProvider, ldapLink, err := getLdapLink(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if ldapProvider != nil {
+		err = setLdapPassword(ldapProvider, ldapLink, newPassword)
+		if err != nil {
+			return err
+		}
+		return deleteCredentialsOfType(ctx, dbContext, userId, repositories.CredentialTypeTrustedDevice)
+	}
+
+	hashedPassword := utils.HashPassword(newPassword)
 
 	credentialFilter := repositories.NewCredentialFilter().
-		UserId(command.UserId).
+		UserId(userId).
 		Type(repositories.CredentialTypePassword)
 	credential, err := dbContext.Credentials().FirstOrNil(ctx, credentialFilter)
 	if err != nil {
-		return nil, fmt.Errorf("getting credential: %w", err)
+		return fmt.Errorf("getting credential: %w", err)
 	}
 
 	passwordExists := credential != nil
 
 	if !passwordExists {
-		credential = repositories.NewCredential(command.UserId, &repositories.CredentialPasswordDetails{})
+		credential = repositories.NewCredential(userId, &repositories.CredentialPasswordDetails{})
 	}
 
 	details, err := credential.PasswordDetails()
 	if err != nil {
-		return nil, fmt.Errorf("getting password details: %w", err)
+		return fmt.Errorf("getting password details: %w", err)
 	}
 
-	details.Temporary = command.Temporary
-	details.HashedPassword = hashedPassword
+	clockService := ioc.GetDependency[clock.Service](scope)
+	details.Temporary = temporary
+	details.ChangePassword(hashedPassword, clockService.Now())
 	credential.SetDetails(details)
 
 	if passwordExists {
@@ -74,5 +100,27 @@ func HandleSetPassword(ctx context.Context, command SetPassword) (*SetPasswordRe
 		dbContext.Credentials().Insert(credential)
 	}
 
-	return &SetPasswordResponse{}, nil
+	// browsers were trusted by whoever knew the old password
+	return deleteCredentialsOfType(ctx, dbContext, userId, repositories.CredentialTypeTrustedDevice)
+}
+
+// setLdapPassword writes the password of a directory user back to the
+// directory instead of storing a local credential.
+func setLdapPassword(ldapProvider *repositories.LdapProvider, ldapLink *repositories.CredentialLdapDetails, password string) error {
+	if ldapProvider.EditMode() != repositories.LdapEditModeWritable {
+		return utils.ErrLdapProviderReadOnly
+	}
+
+	client, err := federation.Dial(federation.ConfigFromProvider(ldapProvider))
+	if err != nil {
+		return fmt.Errorf("connecting to directory: %w", err)
+	}
+	defer utils.PanicOnError(client.Close, "closing ldap connection")
+
+	err = client.SetPassword(ldapLink.Dn, password)
+	if err != nil {
+		return fmt.Errorf("setting directory password: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+	"github.com/The127/Keyline/utils"
+	"net/url"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// SetProvisioningTarget creates or replaces the provisioning target of an
+// application. The secret may be omitted to keep the current one.
+type SetProvisioningTarget struct {
+	VirtualServerName string
+	ProjectSlug       string
+	ApplicationId     uuid.UUID
+	Url               string
+	AuthType          repositories.ProvisioningAuthType
+	AuthUsername      *string
+	AuthSecret        *string
+	AttributeMapping  repositories.ProvisioningAttributeMapping
+	Enabled           bool
+}
+
+func (a SetProvisioningTarget) LogRequest() bool {
+	return false
+}
+
+func (a SetProvisioningTarget) LogResponse() bool {
+	return true
+}
+
+func (a SetProvisioningTarget) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ApplicationUpdate)
+}
+
+func (a SetProvisioningTarget) GetRequestName() string {
+	return "SetProvisioningTarget"
+}
+
+type SetProvisioningTargetResponse struct {
+	Id      uuid.UUID
+	Created bool
+}
+
+func HandleSetProvisioningTarget(ctx context.Context, command SetProvisioningTarget) (*SetProvisioningTargetResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	err := validateProvisioningTarget(command)
+	if err != nil {
+		return nil, err
+	}
+
+	application, err := getProvisionedApplication(ctx, command.VirtualServerName, command.ProjectSlug, command.ApplicationId)
+	if err != nil {
+		return nil, err
+	}
+
+	attributeMapping := command.AttributeMapping
+	if attributeMapping == nil {
+		attributeMapping = repositories.DefaultProvisioningAttributeMapping()
+	}
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(application.VirtualServerId()).
+		ApplicationId(application.Id())
+	target, err := dbContext.ProvisioningTargets().FirstOrNil(ctx, targetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting provisioning target: %w", err)
+	}
+
+	if target == nil {
+		if command.AuthSecret == nil {
+			return nil, fmt.Errorf("a secret is required: %w", utils.ErrHttpBadRequest)
+		}
+
+		target = repositories.NewProvisioningTarget(
+			application.VirtualServerId(),
+			application.Id(),
+			command.Url,
+			command.AuthType,
+			*command.AuthSecret,
+		)
+		target.SetAuth(command.AuthType, command.AuthUsername, *command.AuthSecret)
+		target.SetAttributeMapping(attributeMapping)
+		target.SetEnabled(command.Enabled)
+		dbContext.ProvisioningTargets().Insert(target)
+
+		return &SetProvisioningTargetResponse{
+			Id:      target.Id(),
+			Created: true,
+		}, nil
+	}
+
+	authSecret := target.AuthSecret()
+	if command.AuthSecret != nil {
+		authSecret = *command.AuthSecret
+	}
+
+	target.SetUrl(command.Url)
+	target.SetAuth(command.AuthType, command.AuthUsername, authSecret)
+	target.SetAttributeMapping(attributeMapping)
+	target.SetEnabled(command.Enabled)
+	dbContext.ProvisioningTargets().Update(target)
+
+	return &SetProvisioningTargetResponse{
+		Id: target.Id(),
+	}, nil
+}
+
+func validateProvisioningTarget(command SetProvisioningTarget) error {
+	parsedUrl, err := url.Parse(command.Url)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) url: %w", utils.ErrHttpBadRequest)
+	}
+
+	switch command.AuthType {
+	case repositories.ProvisioningAuthTypeBearer:
+	case repositories.ProvisioningAuthTypeBasic:
+		if command.AuthUsername == nil || *command.AuthUsername == "" {
+			return fmt.Errorf("basic authentication requires a username: %w", utils.ErrHttpBadRequest)
+		}
+	default:
+		return fmt.Errorf("unsupported auth type %q: %w", command.AuthType, utils.ErrHttpBadRequest)
+	}
+
+	if command.AttributeMapping == nil {
+		return nil
+	}
+
+	hasUsername := false
+	for attribute, source := range command.AttributeMapping {
+		if !source.Valid() {
+			return fmt.Errorf("unsupported source %q for %s: %w", source, attribute, utils.ErrHttpBadRequest)
+		}
+		err = scim.ValidatePath(attribute)
+		if err != nil {
+			return fmt.Errorf("invalid attribute %q: %w", attribute, utils.ErrHttpBadRequest)
+		}
+		if source == repositories.ProvisioningSourceUsername {
+			hasUsername = true
+		}
+	}
+	if !hasUsername {
+		return fmt.Errorf("the attribute mapping must include the username: %w", utils.ErrHttpBadRequest)
+	}
+
+	return nil
+}
+
+// getProvisionedApplication looks up an application by the path it is
+// addressed with.
+func getProvisionedApplication(ctx context.Context, virtualServerName string, projectSlug string, applicationId uuid.UUID) (*repositories.Application, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(virtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	projectFilter := repositories.NewProjectFilter().VirtualServerId(virtualServer.Id()).Slug(projectSlug)
+	project, err := dbContext.Projects().FirstOrErr(ctx, projectFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	applicationFilter := repositories.NewApplicationFilter().
+		VirtualServerId(virtualServer.Id()).
+		ProjectId(project.Id()).
+		Id(applicationId)
+	application, err := dbContext.Applications().FirstOrErr(ctx, applicationFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting application: %w", err)
+	}
+
+	return application, nil
+}
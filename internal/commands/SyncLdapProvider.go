@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/federation"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"strings"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// SyncLdapProvider imports the users and groups of a directory. Group
+// memberships of directory users are replaced with the directory's view;
+// memberships of other users are left alone.
+type SyncLdapProvider struct {
+	VirtualServerName string
+	LdapProviderId    uuid.UUID
+}
+
+func (a SyncLdapProvider) LogRequest() bool {
+	return true
+}
+
+func (a SyncLdapProvider) LogResponse() bool {
+	return true
+}
+
+func (a SyncLdapProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderSync)
+}
+
+func (a SyncLdapProvider) GetRequestName() string {
+	return "SyncLdapProvider"
+}
+
+type SyncLdapProviderResponse struct {
+	UsersCreated       int
+	UsersUpdated       int
+	UsersSkipped       int
+	GroupsCreated      int
+	MembershipsAdded   int
+	MembershipsRemoved int
+}
+
+func HandleSyncLdapProvider(ctx context.Context, command SyncLdapProvider) (*SyncLdapProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	client, err := federation.Dial(federation.ConfigFromProvider(ldapProvider))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to directory: %w", err)
+	}
+	defer utils.PanicOnError(client.Close, "closing ldap connection")
+
+	directoryUsers, err := client.SearchUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	directoryGroups, err := client.SearchGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &SyncLdapProviderResponse{}
+
+	userIdsByDn := make(map[string]uuid.UUID, len(directoryUsers))
+	for _, directoryUser := range directoryUsers {
+		userId, created, err := upsertLdapUser(ctx, ldapProvider, directoryUser)
+		switch {
+		case errors.Is(err, utils.ErrHttpConflict):
+			logging.Logger.Warnf("skipping ldap user %s: %v", directoryUser.Dn, err)
+			response.UsersSkipped++
+			continue
+
+		case err != nil:
+			return nil, fmt.Errorf("importing %s: %w", directoryUser.Dn, err)
+
+		case created:
+			response.UsersCreated++
+
+		default:
+			response.UsersUpdated++
+		}
+
+		userIdsByDn[strings.ToLower(directoryUser.Dn)] = userId
+	}
+
+	// users that vanished from the directory lose their memberships too
+	linkFilter := repositories.NewCredentialFilter().
+		Type(repositories.CredentialTypeLdap).
+		DetailLdapProviderId(ldapProvider.Id())
+	links, err := dbContext.Credentials().List(ctx, linkFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap links: %w", err)
+	}
+
+	directoryUserIds := make(map[uuid.UUID]bool, len(links)+len(userIdsByDn))
+	for _, link := range links {
+		directoryUserIds[link.UserId()] = true
+	}
+	for _, userId := range userIdsByDn {
+		directoryUserIds[userId] = true
+	}
+
+	for _, directoryGroup := range directoryGroups {
+		err = syncLdapGroup(ctx, virtualServer, ldapProvider, directoryGroup, userIdsByDn, directoryUserIds, response)
+		if err != nil {
+			return nil, fmt.Errorf("synchronizing group %s: %w", directoryGroup.Dn, err)
+		}
+	}
+
+	ldapProvider.SetLastSyncAt(ioc.GetDependency[clock.Service](scope).Now())
+	dbContext.LdapProviders().Update(ldapProvider)
+
+	return response, nil
+}
+
+func syncLdapGroup(
+	ctx context.Context,
+	virtualServer *repositories.VirtualServer,
+	ldapProvider *repositories.LdapProvider,
+	directoryGroup federation.Group,
+	userIdsByDn map[string]uuid.UUID,
+	directoryUserIds map[uuid.UUID]bool,
+	response *SyncLdapProviderResponse,
+) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Name(directoryGroup.Name)
+	group, err := dbContext.Groups().FirstOrNil(ctx, groupFilter)
+	if err != nil {
+		return fmt.Errorf("getting group: %w", err)
+	}
+
+	var existingMembers []*repositories.GroupMember
+	if group == nil {
+		group = repositories.NewGroup(virtualServer.Id(), directoryGroup.Name, fmt.Sprintf("Synchronized from %s", ldapProvider.Name()))
+		dbContext.Groups().Insert(group)
+		response.GroupsCreated++
+	} else {
+		existingMembers, err = dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().GroupId(group.Id()))
+		if err != nil {
+			return fmt.Errorf("listing group members: %w", err)
+		}
+	}
+
+	desired := make(map[uuid.UUID]bool)
+	for _, memberDn := range directoryGroup.MemberDns {
+		userId, ok := userIdsByDn[strings.ToLower(memberDn)]
+		if ok {
+			desired[userId] = true
+		}
+	}
+
+	for _, member := range existingMembers {
+		if desired[member.UserId()] {
+			delete(desired, member.UserId())
+			continue
+		}
+		if directoryUserIds[member.UserId()] {
+			dbContext.GroupMembers().Delete(member.Id())
+			response.MembershipsRemoved++
+		}
+	}
+
+	for userId := range desired {
+		dbContext.GroupMembers().Insert(repositories.NewGroupMember(group.Id(), userId))
+		response.MembershipsAdded++
+	}
+
+	return nil
+}
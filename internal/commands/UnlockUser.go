@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// UnlockUser lifts a temporary or permanent lockout of a user and forgets
+// their failed password attempts.
+type UnlockUser struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a UnlockUser) LogRequest() bool {
+	return true
+}
+
+func (a UnlockUser) LogResponse() bool {
+	return true
+}
+
+func (a UnlockUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.UserUpdate)
+}
+
+func (a UnlockUser) GetRequestName() string {
+	return "UnlockUser"
+}
+
+type UnlockUserResponse struct{}
+
+func HandleUnlockUser(ctx context.Context, command UnlockUser) (*UnlockUserResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(command.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	err = lockoutService.ClearUserState(ctx, user.Id())
+	if err != nil {
+		return nil, fmt.Errorf("unlocking user: %w", err)
+	}
+
+	return &UnlockUserResponse{}, nil
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type UnlockUserCommandSuite struct {
+	suite.Suite
+}
+
+func TestUnlockUserCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(UnlockUserCommandSuite))
+}
+
+func (s *UnlockUserCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	virtualServerRepository repositories.VirtualServerRepository,
+	userRepository repositories.UserRepository,
+	lockoutService services.LockoutService,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if virtualServerRepository != nil {
+		dbContext.EXPECT().VirtualServers().Return(virtualServerRepository).AnyTimes()
+	}
+
+	if userRepository != nil {
+		dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	}
+
+	clockService, _ := clock.NewMockClock(time.Now())
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	store := keyValue.NewMemoryStore()
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) keyValue.Store {
+		return store
+	})
+
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.LockoutService {
+		return lockoutService
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *UnlockUserCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == "virtualServer"
+	})).Return(virtualServer, nil)
+
+	user := repositories.NewUser("user", "User", "user@mail", virtualServer.Id())
+	user.Mock(now)
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.UserFilter) bool {
+		return x.GetId() == user.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(user, nil)
+
+	lockoutService := services.NewLockoutService()
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, lockoutService)
+
+	policy := repositories.DefaultLockoutPolicy()
+	policy.MaxFailedAttempts = 1
+	policy.PermanentLockoutAfter = 1
+	_, locked, err := lockoutService.RecordFailure(ctx, policy, virtualServer.Id(), user.Id(), "203.0.113.7")
+	s.Require().NoError(err)
+	s.Require().True(locked)
+
+	cmd := UnlockUser{
+		VirtualServerName: "virtualServer",
+		UserId:            user.Id(),
+	}
+
+	// act
+	resp, err := HandleUnlockUser(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+	s.Require().NoError(lockoutService.CheckUser(ctx, user.Id()))
+}
+
+func (s *UnlockUserCommandSuite) TestUserError() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	userRepository := mocks.NewMockUserRepository(ctrl)
+	userRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, errors.New("error"))
+
+	ctx := s.createContext(ctrl, virtualServerRepository, userRepository, services.NewLockoutService())
+	cmd := UnlockUser{}
+
+	// act
+	resp, err := HandleUnlockUser(ctx, cmd)
+
+	// assert
+	s.Require().Error(err)
+	s.Nil(resp)
+}
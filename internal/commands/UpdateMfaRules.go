@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+)
+
+// UpdateMfaRules replaces the MFA rules of a virtual server, the order of
+// the rules is the order they are evaluated in.
+type UpdateMfaRules struct {
+	VirtualServerName string
+	Rules             repositories.MfaRules
+}
+
+func (a UpdateMfaRules) LogRequest() bool {
+	return true
+}
+
+func (a UpdateMfaRules) LogResponse() bool {
+	return true
+}
+
+func (a UpdateMfaRules) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerUpdate)
+}
+
+func (a UpdateMfaRules) GetRequestName() string {
+	return "UpdateMfaRules"
+}
+
+type UpdateMfaRulesResponse struct{}
+
+func HandleUpdateMfaRules(ctx context.Context, command UpdateMfaRules) (*UpdateMfaRulesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	err := command.Rules.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(command.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	// roles of other virtual servers would never match
+	for _, rule := range command.Rules {
+		for _, roleId := range rule.RoleIds {
+			roleFilter := repositories.NewRoleFilter().
+				VirtualServerId(virtualServer.Id()).
+				Id(roleId)
+			_, err := dbContext.Roles().FirstOrErr(ctx, roleFilter)
+			if err != nil {
+				return nil, fmt.Errorf("getting role %s: %w", roleId, err)
+			}
+		}
+	}
+
+	rules := command.Rules
+	if rules == nil {
+		rules = repositories.MfaRules{}
+	}
+	virtualServer.SetMfaRules(rules)
+	dbContext.VirtualServers().Update(virtualServer)
+
+	return &UpdateMfaRulesResponse{}, nil
+}
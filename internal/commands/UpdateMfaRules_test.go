@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	mocks2 "github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/mocks"
+	"github.com/The127/Keyline/utils"
+	"testing"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type UpdateMfaRulesCommandSuite struct {
+	suite.Suite
+}
+
+func TestUpdateMfaRulesCommandSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(UpdateMfaRulesCommandSuite))
+}
+
+func (s *UpdateMfaRulesCommandSuite) createContext(
+	ctrl *gomock.Controller,
+	vr repositories.VirtualServerRepository,
+	rr repositories.RoleRepository,
+) context.Context {
+	dc := ioc.NewDependencyCollection()
+
+	dbContext := mocks2.NewMockContext(ctrl)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) database.Context {
+		return dbContext
+	})
+
+	if vr != nil {
+		dbContext.EXPECT().VirtualServers().Return(vr).AnyTimes()
+	}
+
+	if rr != nil {
+		dbContext.EXPECT().Roles().Return(rr).AnyTimes()
+	}
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope)
+}
+
+func (s *UpdateMfaRulesCommandSuite) TestInvalidRules() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	ctx := s.createContext(ctrl, nil, nil)
+	cmd := UpdateMfaRules{
+		VirtualServerName: "virtualServer",
+		Rules: repositories.MfaRules{
+			{Action: repositories.MfaRuleActionSkip, Cidrs: []string{"not-a-network"}},
+		},
+	}
+
+	// act
+	resp, err := HandleUpdateMfaRules(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrHttpBadRequest)
+	s.Nil(resp)
+}
+
+func (s *UpdateMfaRulesCommandSuite) TestUnknownRole() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(virtualServer, nil)
+
+	roleRepository := mocks.NewMockRoleRepository(ctrl)
+	roleRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Any()).Return(nil, utils.ErrRoleNotFound)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, roleRepository)
+	cmd := UpdateMfaRules{
+		VirtualServerName: virtualServer.Name(),
+		Rules: repositories.MfaRules{
+			{Action: repositories.MfaRuleActionRequire, RoleIds: []uuid.UUID{uuid.New()}},
+		},
+	}
+
+	// act
+	resp, err := HandleUpdateMfaRules(ctx, cmd)
+
+	// assert
+	s.Require().ErrorIs(err, utils.ErrRoleNotFound)
+	s.Nil(resp)
+}
+
+func (s *UpdateMfaRulesCommandSuite) TestHappyPath() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	virtualServer := repositories.NewVirtualServer("virtualServer", "Virtual Server")
+	virtualServer.Mock(now)
+	virtualServerRepository := mocks.NewMockVirtualServerRepository(ctrl)
+	virtualServerRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.VirtualServerFilter) bool {
+		return x.GetName() == virtualServer.Name()
+	})).Return(virtualServer, nil)
+	virtualServerRepository.EXPECT().Update(gomock.Cond(func(x *repositories.VirtualServer) bool {
+		return len(x.MfaRules()) == 2
+	}))
+
+	role := repositories.NewRole(virtualServer.Id(), uuid.New(), "admin", "Admin")
+	role.Mock(now)
+	roleRepository := mocks.NewMockRoleRepository(ctrl)
+	roleRepository.EXPECT().FirstOrErr(gomock.Any(), gomock.Cond(func(x *repositories.RoleFilter) bool {
+		return x.GetId() == role.Id() && x.GetVirtualServerId() == virtualServer.Id()
+	})).Return(role, nil)
+
+	ctx := s.createContext(ctrl, virtualServerRepository, roleRepository)
+	cmd := UpdateMfaRules{
+		VirtualServerName: virtualServer.Name(),
+		Rules: repositories.MfaRules{
+			{Action: repositories.MfaRuleActionRequire, RoleIds: []uuid.UUID{role.Id()}},
+			{Action: repositories.MfaRuleActionSkip, Cidrs: []string{"10.0.0.0/8"}},
+		},
+	}
+
+	// act
+	resp, err := HandleUpdateMfaRules(ctx, cmd)
+
+	// assert
+	s.Require().NoError(err)
+	s.NotNil(resp)
+}
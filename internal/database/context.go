@@ -11,15 +11,20 @@ const (
 	AuditLogEntityType
 	CredentialEntityType
 	FileEntityType
+	GroupMemberEntityType
 	GroupRoleEntityType
 	GroupEntityType
+	IdentityProviderEntityType
+	LdapProviderEntityType
 	OutboxMessageEntityType
 	PasswordRuleEntityType
 	ProjectEntityType
+	ProvisioningTargetEntityType
 	ResourceServerEntityType
 	ResourceServerScopeEntityType
 	RoleEntityType
 	SessionEntityType
+	SigningKeyEntityType
 	TemplateEntityType
 	UserRoleAssignmentEntityType
 	UserEntityType
@@ -33,15 +38,20 @@ type Context interface {
 	AuditLogs() repositories.AuditLogRepository
 	Credentials() repositories.CredentialRepository
 	Files() repositories.FileRepository
+	GroupMembers() repositories.GroupMemberRepository
 	GroupRoles() repositories.GroupRoleRepository
 	Groups() repositories.GroupRepository
+	IdentityProviders() repositories.IdentityProviderRepository
+	LdapProviders() repositories.LdapProviderRepository
 	OutboxMessages() repositories.OutboxMessageRepository
 	PasswordRules() repositories.PasswordRuleRepository
 	Projects() repositories.ProjectRepository
+	ProvisioningTargets() repositories.ProvisioningTargetRepository
 	ResourceServers() repositories.ResourceServerRepository
 	ResourceServerScopes() repositories.ResourceServerScopeRepository
 	Roles() repositories.RoleRepository
 	Sessions() repositories.SessionRepository
+	SigningKeys() repositories.SigningKeyRepository
 	Templates() repositories.TemplateRepository
 	UserRoleAssignments() repositories.UserRoleAssignmentRepository
 	Users() repositories.UserRepository
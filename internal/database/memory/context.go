@@ -20,8 +20,12 @@ type Context struct {
 	auditLogs               *memrepos.AuditLogRepository
 	credentials             *memrepos.CredentialRepository
 	files                   *memrepos.FileRepository
+	groupMembers            *memrepos.GroupMemberRepository
 	groupRoles              *memrepos.GroupRoleRepository
 	groups                  *memrepos.GroupRepository
+	identityProviders       *memrepos.IdentityProviderRepository
+	ldapProviders           *memrepos.LdapProviderRepository
+	provisioningTargets     *memrepos.ProvisioningTargetRepository
 	outboxMessages          *memrepos.OutboxMessageRepository
 	passwordRules           *memrepos.PasswordRuleRepository
 	projects                *memrepos.ProjectRepository
@@ -29,6 +33,7 @@ type Context struct {
 	resourceServerScopes    *memrepos.ResourceServerScopeRepository
 	roles                   *memrepos.RoleRepository
 	sessions                *memrepos.SessionRepository
+	signingKeys             *memrepos.SigningKeyRepository
 	templates               *memrepos.TemplateRepository
 	userRoleAssignments     *memrepos.UserRoleAssignmentRepository
 	users                   *memrepos.UserRepository
@@ -77,6 +82,13 @@ func (c *Context) Files() repositories.FileRepository {
 	return c.files
 }
 
+func (c *Context) GroupMembers() repositories.GroupMemberRepository {
+	if c.groupMembers == nil {
+		c.groupMembers = memrepos.NewGroupMemberRepository(c.stores.GroupMembers, &c.stores.mu, c.changeTracker, db.GroupMemberEntityType)
+	}
+	return c.groupMembers
+}
+
 func (c *Context) GroupRoles() repositories.GroupRoleRepository {
 	if c.groupRoles == nil {
 		c.groupRoles = memrepos.NewGroupRoleRepository(c.stores.GroupRoles, &c.stores.mu, c.changeTracker, db.GroupRoleEntityType)
@@ -91,6 +103,27 @@ func (c *Context) Groups() repositories.GroupRepository {
 	return c.groups
 }
 
+func (c *Context) IdentityProviders() repositories.IdentityProviderRepository {
+	if c.identityProviders == nil {
+		c.identityProviders = memrepos.NewIdentityProviderRepository(c.stores.IdentityProviders, &c.stores.mu, c.changeTracker, db.IdentityProviderEntityType)
+	}
+	return c.identityProviders
+}
+
+func (c *Context) ProvisioningTargets() repositories.ProvisioningTargetRepository {
+	if c.provisioningTargets == nil {
+		c.provisioningTargets = memrepos.NewProvisioningTargetRepository(c.stores.ProvisioningTargets, &c.stores.mu, c.changeTracker, db.ProvisioningTargetEntityType)
+	}
+	return c.provisioningTargets
+}
+
+func (c *Context) LdapProviders() repositories.LdapProviderRepository {
+	if c.ldapProviders == nil {
+		c.ldapProviders = memrepos.NewLdapProviderRepository(c.stores.LdapProviders, &c.stores.mu, c.changeTracker, db.LdapProviderEntityType)
+	}
+	return c.ldapProviders
+}
+
 func (c *Context) OutboxMessages() repositories.OutboxMessageRepository {
 	if c.outboxMessages == nil {
 		c.outboxMessages = memrepos.NewOutboxMessageRepository(c.stores.OutboxMessages, &c.stores.mu, c.changeTracker, db.OutboxMessageEntityType)
@@ -140,6 +173,13 @@ func (c *Context) Sessions() repositories.SessionRepository {
 	return c.sessions
 }
 
+func (c *Context) SigningKeys() repositories.SigningKeyRepository {
+	if c.signingKeys == nil {
+		c.signingKeys = memrepos.NewSigningKeyRepository(c.stores.SigningKeys, &c.stores.mu, c.changeTracker, db.SigningKeyEntityType)
+	}
+	return c.signingKeys
+}
+
 func (c *Context) Templates() repositories.TemplateRepository {
 	if c.templates == nil {
 		c.templates = memrepos.NewTemplateRepository(c.stores.Templates, &c.stores.mu, c.changeTracker, db.TemplateEntityType)
@@ -210,12 +250,24 @@ func (c *Context) applyChange(ch *change.Entry) error {
 	case db.FileEntityType:
 		return applyInsertOnly(c.stores.Files, ch, func(e *repositories.File) { e.SetVersion(1) })
 
+	case db.GroupMemberEntityType:
+		return applyChange(c.stores.GroupMembers, ch, func(e *repositories.GroupMember) { e.SetVersion(incrementVersion(e.GetVersion())) })
+
 	case db.GroupRoleEntityType:
 		return fmt.Errorf("unsupported change type for group role: %v", ch.GetChangeType())
 
 	case db.GroupEntityType:
 		return applyChange(c.stores.Groups, ch, func(e *repositories.Group) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
 
+	case db.IdentityProviderEntityType:
+		return applyChange(c.stores.IdentityProviders, ch, func(e *repositories.IdentityProvider) {
+			e.SetVersion(incrementVersion(e.GetVersion()))
+			e.ClearChanges()
+		})
+
+	case db.LdapProviderEntityType:
+		return applyChange(c.stores.LdapProviders, ch, func(e *repositories.LdapProvider) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
+
 	case db.OutboxMessageEntityType:
 		return applyOutboxMessageChange(c.stores.OutboxMessages, ch)
 
@@ -225,6 +277,12 @@ func (c *Context) applyChange(ch *change.Entry) error {
 	case db.ProjectEntityType:
 		return applyChange(c.stores.Projects, ch, func(e *repositories.Project) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
 
+	case db.ProvisioningTargetEntityType:
+		return applyChange(c.stores.ProvisioningTargets, ch, func(e *repositories.ProvisioningTarget) {
+			e.SetVersion(incrementVersion(e.GetVersion()))
+			e.ClearChanges()
+		})
+
 	case db.ResourceServerEntityType:
 		return applyChange(c.stores.ResourceServers, ch, func(e *repositories.ResourceServer) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
 
@@ -240,11 +298,14 @@ func (c *Context) applyChange(ch *change.Entry) error {
 	case db.SessionEntityType:
 		return applySessionChange(c.stores.Sessions, ch)
 
+	case db.SigningKeyEntityType:
+		return applyChange(c.stores.SigningKeys, ch, func(e *repositories.SigningKey) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
+
 	case db.TemplateEntityType:
 		return applyInsertOnly(c.stores.Templates, ch, func(e *repositories.Template) { e.SetVersion(1) })
 
 	case db.UserRoleAssignmentEntityType:
-		return applyInsertOnly(c.stores.UserRoleAssignments, ch, func(e *repositories.UserRoleAssignment) { e.SetVersion(1) })
+		return applyChange(c.stores.UserRoleAssignments, ch, func(e *repositories.UserRoleAssignment) { e.SetVersion(1) })
 
 	case db.UserEntityType:
 		return applyChange(c.stores.Users, ch, func(e *repositories.User) { e.SetVersion(incrementVersion(e.GetVersion())); e.ClearChanges() })
@@ -316,6 +377,13 @@ func applyOutboxMessageChange(store map[uuid.UUID]*repositories.OutboxMessage, c
 		store[entity.Id()] = entity
 		return nil
 
+	case change.Updated:
+		entity := ch.GetItem().(*repositories.OutboxMessage)
+		entity.SetVersion(incrementVersion(entity.GetVersion()))
+		entity.ClearChanges()
+		store[entity.Id()] = entity
+		return nil
+
 	case change.Deleted:
 		id := ch.GetItem().(uuid.UUID)
 		delete(store, id)
@@ -18,8 +18,12 @@ type Stores struct {
 	AuditLogs               map[uuid.UUID]*repositories.AuditLog
 	Credentials             map[uuid.UUID]*repositories.Credential
 	Files                   map[uuid.UUID]*repositories.File
+	GroupMembers            map[uuid.UUID]*repositories.GroupMember
 	GroupRoles              map[uuid.UUID]*repositories.GroupRole
 	Groups                  map[uuid.UUID]*repositories.Group
+	IdentityProviders       map[uuid.UUID]*repositories.IdentityProvider
+	LdapProviders           map[uuid.UUID]*repositories.LdapProvider
+	ProvisioningTargets     map[uuid.UUID]*repositories.ProvisioningTarget
 	OutboxMessages          map[uuid.UUID]*repositories.OutboxMessage
 	PasswordRules           map[uuid.UUID]*repositories.PasswordRule
 	Projects                map[uuid.UUID]*repositories.Project
@@ -27,6 +31,7 @@ type Stores struct {
 	ResourceServerScopes    map[uuid.UUID]*repositories.ResourceServerScope
 	Roles                   map[uuid.UUID]*repositories.Role
 	Sessions                map[uuid.UUID]*repositories.Session
+	SigningKeys             map[uuid.UUID]*repositories.SigningKey
 	Templates               map[uuid.UUID]*repositories.Template
 	UserRoleAssignments     map[uuid.UUID]*repositories.UserRoleAssignment
 	Users                   map[uuid.UUID]*repositories.User
@@ -40,8 +45,12 @@ func newStores() *Stores {
 		AuditLogs:               make(map[uuid.UUID]*repositories.AuditLog),
 		Credentials:             make(map[uuid.UUID]*repositories.Credential),
 		Files:                   make(map[uuid.UUID]*repositories.File),
+		GroupMembers:            make(map[uuid.UUID]*repositories.GroupMember),
 		GroupRoles:              make(map[uuid.UUID]*repositories.GroupRole),
 		Groups:                  make(map[uuid.UUID]*repositories.Group),
+		IdentityProviders:       make(map[uuid.UUID]*repositories.IdentityProvider),
+		LdapProviders:           make(map[uuid.UUID]*repositories.LdapProvider),
+		ProvisioningTargets:     make(map[uuid.UUID]*repositories.ProvisioningTarget),
 		OutboxMessages:          make(map[uuid.UUID]*repositories.OutboxMessage),
 		PasswordRules:           make(map[uuid.UUID]*repositories.PasswordRule),
 		Projects:                make(map[uuid.UUID]*repositories.Project),
@@ -49,6 +58,7 @@ func newStores() *Stores {
 		ResourceServerScopes:    make(map[uuid.UUID]*repositories.ResourceServerScope),
 		Roles:                   make(map[uuid.UUID]*repositories.Role),
 		Sessions:                make(map[uuid.UUID]*repositories.Session),
+		SigningKeys:             make(map[uuid.UUID]*repositories.SigningKey),
 		Templates:               make(map[uuid.UUID]*repositories.Template),
 		UserRoleAssignments:     make(map[uuid.UUID]*repositories.UserRoleAssignment),
 		Users:                   make(map[uuid.UUID]*repositories.User),
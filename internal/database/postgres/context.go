@@ -21,8 +21,12 @@ type Context struct {
 	auditLogs               *postgres.AuditLogRepository
 	credentials             *postgres.CredentialRepository
 	files                   *postgres.FileRepository
+	groupMembers            *postgres.GroupMemberRepository
 	groupRoles              *postgres.GroupRoleRepository
 	groups                  *postgres.GroupRepository
+	identityProviders       *postgres.IdentityProviderRepository
+	ldapProviders           *postgres.LdapProviderRepository
+	provisioningTargets     *postgres.ProvisioningTargetRepository
 	outboxMessages          *postgres.OutboxMessageRepository
 	passwordRules           *postgres.PasswordRuleRepository
 	projects                *postgres.ProjectRepository
@@ -30,6 +34,7 @@ type Context struct {
 	resourceServerScopes    *postgres.ResourceServerScopeRepository
 	roles                   *postgres.RoleRepository
 	sessions                *postgres.SessionRepository
+	signingKeys             *postgres.SigningKeyRepository
 	templates               *postgres.TemplateRepository
 	userRoleAssignments     *postgres.UserRoleAssignmentRepository
 	users                   *postgres.UserRepository
@@ -76,6 +81,14 @@ func (c *Context) Files() repositories.FileRepository {
 	return c.files
 }
 
+func (c *Context) GroupMembers() repositories.GroupMemberRepository {
+	if c.groupMembers == nil {
+		c.groupMembers = postgres.NewGroupMemberRepository(c.db, c.changeTracker, db.GroupMemberEntityType)
+	}
+
+	return c.groupMembers
+}
+
 func (c *Context) GroupRoles() repositories.GroupRoleRepository {
 	if c.groupRoles == nil {
 		c.groupRoles = postgres.NewGroupRoleRepository(c.db, c.changeTracker, db.GroupRoleEntityType)
@@ -92,6 +105,29 @@ func (c *Context) Groups() repositories.GroupRepository {
 	return c.groups
 }
 
+func (c *Context) IdentityProviders() repositories.IdentityProviderRepository {
+	if c.identityProviders == nil {
+		c.identityProviders = postgres.NewIdentityProviderRepository(c.db, c.changeTracker, db.IdentityProviderEntityType)
+	}
+
+	return c.identityProviders
+}
+
+func (c *Context) ProvisioningTargets() repositories.ProvisioningTargetRepository {
+	if c.provisioningTargets == nil {
+		c.provisioningTargets = postgres.NewProvisioningTargetRepository(c.db, c.changeTracker, db.ProvisioningTargetEntityType)
+	}
+	return c.provisioningTargets
+}
+
+func (c *Context) LdapProviders() repositories.LdapProviderRepository {
+	if c.ldapProviders == nil {
+		c.ldapProviders = postgres.NewLdapProviderRepository(c.db, c.changeTracker, db.LdapProviderEntityType)
+	}
+
+	return c.ldapProviders
+}
+
 func (c *Context) OutboxMessages() repositories.OutboxMessageRepository {
 	if c.outboxMessages == nil {
 		c.outboxMessages = postgres.NewOutboxMessageRepository(c.db, c.changeTracker, db.OutboxMessageEntityType)
@@ -148,6 +184,13 @@ func (c *Context) Sessions() repositories.SessionRepository {
 	return c.sessions
 }
 
+func (c *Context) SigningKeys() repositories.SigningKeyRepository {
+	if c.signingKeys == nil {
+		c.signingKeys = postgres.NewSigningKeyRepository(c.db, c.changeTracker, db.SigningKeyEntityType)
+	}
+	return c.signingKeys
+}
+
 func (c *Context) Templates() repositories.TemplateRepository {
 	if c.templates == nil {
 		c.templates = postgres.NewTemplateRepository(c.db, c.changeTracker, db.TemplateEntityType)
@@ -226,12 +269,21 @@ func (c *Context) applyChange(ctx context.Context, tx *sql.Tx, ch *change.Entry)
 	case db.FileEntityType:
 		return c.applyFileChange(ctx, tx, ch)
 
+	case db.GroupMemberEntityType:
+		return c.applyGroupMemberChange(ctx, tx, ch)
+
 	case db.GroupRoleEntityType:
 		return c.applyGroupRoleChange(ctx, tx, ch)
 
 	case db.GroupEntityType:
 		return c.applyGroupChange(ctx, tx, ch)
 
+	case db.IdentityProviderEntityType:
+		return c.applyIdentityProviderChange(ctx, tx, ch)
+
+	case db.LdapProviderEntityType:
+		return c.applyLdapProviderChange(ctx, tx, ch)
+
 	case db.OutboxMessageEntityType:
 		return c.applyOutboxMessageChange(ctx, tx, ch)
 
@@ -241,6 +293,9 @@ func (c *Context) applyChange(ctx context.Context, tx *sql.Tx, ch *change.Entry)
 	case db.ProjectEntityType:
 		return c.applyProjectChange(ctx, tx, ch)
 
+	case db.ProvisioningTargetEntityType:
+		return c.applyProvisioningTargetChange(ctx, tx, ch)
+
 	case db.ResourceServerEntityType:
 		return c.applyResourceServerChange(ctx, tx, ch)
 
@@ -253,6 +308,9 @@ func (c *Context) applyChange(ctx context.Context, tx *sql.Tx, ch *change.Entry)
 	case db.SessionEntityType:
 		return c.applySessionChange(ctx, tx, ch)
 
+	case db.SigningKeyEntityType:
+		return c.applySigningKeyChange(ctx, tx, ch)
+
 	case db.TemplateEntityType:
 		return c.applyTemplateChange(ctx, tx, ch)
 
@@ -360,6 +418,9 @@ func (c *Context) applyOutboxMessageChange(ctx context.Context, tx *sql.Tx, ch *
 	case change.Added:
 		return c.outboxMessages.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.OutboxMessage))
 
+	case change.Updated:
+		return c.outboxMessages.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.OutboxMessage))
+
 	case change.Deleted:
 		return c.outboxMessages.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
 
@@ -368,6 +429,67 @@ func (c *Context) applyOutboxMessageChange(ctx context.Context, tx *sql.Tx, ch *
 	}
 }
 
+func (c *Context) applyIdentityProviderChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
+	switch ch.GetChangeType() {
+	case change.Added:
+		return c.identityProviders.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.IdentityProvider))
+
+	case change.Updated:
+		return c.identityProviders.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.IdentityProvider))
+
+	case change.Deleted:
+		return c.identityProviders.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
+	}
+}
+
+func (c *Context) applyLdapProviderChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
+	switch ch.GetChangeType() {
+	case change.Added:
+		return c.ldapProviders.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.LdapProvider))
+
+	case change.Updated:
+		return c.ldapProviders.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.LdapProvider))
+
+	case change.Deleted:
+		return c.ldapProviders.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
+	}
+}
+
+func (c *Context) applyProvisioningTargetChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
+	switch ch.GetChangeType() {
+	case change.Added:
+		return c.provisioningTargets.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.ProvisioningTarget))
+
+	case change.Updated:
+		return c.provisioningTargets.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.ProvisioningTarget))
+
+	case change.Deleted:
+		return c.provisioningTargets.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
+	}
+}
+
+func (c *Context) applyGroupMemberChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
+	switch ch.GetChangeType() {
+	case change.Added:
+		return c.groupMembers.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.GroupMember))
+
+	case change.Deleted:
+		return c.groupMembers.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
+	}
+}
+
 func (c *Context) applyPasswordRuleChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
 	switch ch.GetChangeType() {
 	case change.Added:
@@ -461,6 +583,22 @@ func (c *Context) applySessionChange(ctx context.Context, tx *sql.Tx, ch *change
 	}
 }
 
+func (c *Context) applySigningKeyChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
+	switch ch.GetChangeType() {
+	case change.Added:
+		return c.signingKeys.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.SigningKey))
+
+	case change.Updated:
+		return c.signingKeys.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.SigningKey))
+
+	case change.Deleted:
+		return c.signingKeys.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
+	default:
+		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
+	}
+}
+
 func (c *Context) applyTemplateChange(ctx context.Context, tx *sql.Tx, ch *change.Entry) error {
 	switch ch.GetChangeType() {
 	case change.Added:
@@ -476,6 +614,9 @@ func (c *Context) applyUserRoleAssignmentChange(ctx context.Context, tx *sql.Tx,
 	case change.Added:
 		return c.userRoleAssignments.ExecuteInsert(ctx, tx, ch.GetItem().(*repositories.UserRoleAssignment))
 
+	case change.Deleted:
+		return c.userRoleAssignments.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
 	default:
 		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
 	}
@@ -489,6 +630,9 @@ func (c *Context) applyUserChange(ctx context.Context, tx *sql.Tx, ch *change.En
 	case change.Updated:
 		return c.users.ExecuteUpdate(ctx, tx, ch.GetItem().(*repositories.User))
 
+	case change.Deleted:
+		return c.users.ExecuteDelete(ctx, tx, ch.GetItem().(uuid.UUID))
+
 	default:
 		return fmt.Errorf("unsupported change type: %v", ch.GetChangeType())
 	}
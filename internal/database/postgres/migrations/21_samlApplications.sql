@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE applications ADD COLUMN saml_entity_id TEXT NULL;
+ALTER TABLE applications ADD COLUMN saml_acs_url TEXT NULL;
+ALTER TABLE applications ADD COLUMN saml_slo_url TEXT NULL;
+
+CREATE UNIQUE INDEX idx_applications_saml_entity_id ON applications (virtual_server_id, saml_entity_id) WHERE saml_entity_id IS NOT NULL;
+
+-- +migrate Down
+DROP INDEX idx_applications_saml_entity_id;
+ALTER TABLE applications DROP COLUMN saml_slo_url;
+ALTER TABLE applications DROP COLUMN saml_acs_url;
+ALTER TABLE applications DROP COLUMN saml_entity_id;
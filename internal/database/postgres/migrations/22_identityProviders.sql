@@ -0,0 +1,39 @@
+-- +migrate Up
+
+create table "identity_providers"
+(
+    "id" uuid not null,
+    "audit_created_at" timestamp not null,
+    "audit_updated_at" timestamp not null,
+
+    "virtual_server_id" uuid not null,
+
+    "name" text not null,
+    "display_name" text not null,
+
+    "issuer" text not null,
+    "client_id" text not null,
+    "client_secret" text not null,
+    "scopes" text[] not null default '{}',
+
+    "authorization_url" text,
+    "token_url" text,
+    "userinfo_url" text,
+
+    "claim_mapping" jsonb not null,
+    "link_by_email" boolean not null default false,
+
+    primary key ("id"),
+    foreign key ("virtual_server_id") references "virtual_servers" ("id"),
+    unique ("virtual_server_id", "name")
+);
+
+create trigger "trg_set_audit_updated_at"
+    before update
+    on "identity_providers"
+    for each row
+execute function update_audit_timestamp();
+
+-- +migrate Down
+
+drop table "identity_providers";
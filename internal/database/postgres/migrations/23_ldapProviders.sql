@@ -0,0 +1,65 @@
+-- +migrate Up
+
+create table "ldap_providers"
+(
+    "id" uuid not null,
+    "audit_created_at" timestamp not null,
+    "audit_updated_at" timestamp not null,
+
+    "virtual_server_id" uuid not null,
+
+    "name" text not null,
+    "vendor" text not null,
+
+    "connection_url" text not null,
+    "start_tls" boolean not null default false,
+    "bind_dn" text not null,
+    "bind_password" text not null,
+
+    "users_dn" text not null,
+    "user_filter" text not null,
+    "groups_dn" text,
+    "group_filter" text not null,
+
+    "attribute_mapping" jsonb not null,
+    "edit_mode" text not null,
+
+    "sync_interval_seconds" bigint not null default 0,
+    "last_sync_at" timestamp,
+
+    primary key ("id"),
+    foreign key ("virtual_server_id") references "virtual_servers" ("id"),
+    unique ("virtual_server_id", "name")
+);
+
+create trigger "trg_set_audit_updated_at"
+    before update
+    on "ldap_providers"
+    for each row
+execute function update_audit_timestamp();
+
+create table "group_members"
+(
+    "id" uuid not null,
+    "audit_created_at" timestamp not null,
+    "audit_updated_at" timestamp not null,
+
+    "group_id" uuid not null,
+    "user_id" uuid not null,
+
+    primary key ("id"),
+    foreign key ("group_id") references "groups" ("id") on delete cascade,
+    foreign key ("user_id") references "users" ("id") on delete cascade,
+    unique ("group_id", "user_id")
+);
+
+create trigger "trg_set_audit_updated_at"
+    before update
+    on "group_members"
+    for each row
+execute function update_audit_timestamp();
+
+-- +migrate Down
+
+drop table "group_members";
+drop table "ldap_providers";
@@ -0,0 +1,63 @@
+-- +migrate Up
+
+alter table "users" add column "disabled" boolean not null default false;
+
+-- users and groups deprovisioned over scim take their dependent rows with them
+alter table "credentials" drop constraint "credentials_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id") on delete cascade;
+
+alter table "sessions" drop constraint "sessions_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id") on delete cascade;
+
+alter table "application_user_metadata" drop constraint "application_user_metadata_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id") on delete cascade;
+
+alter table "user_role_assignments" drop constraint "user_role_assignments_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id") on delete cascade;
+
+alter table "user_role_assignments" drop constraint "user_role_assignments_group_id_fkey",
+    add foreign key ("group_id") references "groups" ("id") on delete cascade;
+
+alter table "group_roles" drop constraint "group_roles_group_id_fkey",
+    add foreign key ("group_id") references "groups" ("id") on delete cascade;
+
+insert into "roles" ("id", "audit_created_at", "audit_updated_at", "virtual_server_id", "project_id", "name", "description")
+select gen_random_uuid(), now(), now(), p."virtual_server_id", p."id", 'scim-provisioner', 'SCIM provisioning role'
+from "projects" p
+where p."system_project"
+  and not exists (
+    select 1 from "roles" r where r."project_id" = p."id" and r."name" = 'scim-provisioner'
+  );
+
+-- +migrate Down
+
+delete from "user_role_assignments"
+where "role_id" in (
+    select r."id" from "roles" r
+    join "projects" p on p."id" = r."project_id"
+    where p."system_project" and r."name" = 'scim-provisioner'
+);
+
+delete from "roles"
+where "name" = 'scim-provisioner'
+  and "project_id" in (select "id" from "projects" where "system_project");
+
+alter table "group_roles" drop constraint "group_roles_group_id_fkey",
+    add foreign key ("group_id") references "groups" ("id");
+
+alter table "user_role_assignments" drop constraint "user_role_assignments_group_id_fkey",
+    add foreign key ("group_id") references "groups" ("id");
+
+alter table "user_role_assignments" drop constraint "user_role_assignments_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id");
+
+alter table "application_user_metadata" drop constraint "application_user_metadata_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id");
+
+alter table "sessions" drop constraint "sessions_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id");
+
+alter table "credentials" drop constraint "credentials_user_id_fkey",
+    add foreign key ("user_id") references "users" ("id");
+
+alter table "users" drop column "disabled";
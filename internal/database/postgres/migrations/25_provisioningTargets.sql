@@ -0,0 +1,48 @@
+-- +migrate Up
+
+create table "provisioning_targets"
+(
+    "id" uuid not null,
+    "audit_created_at" timestamp not null,
+    "audit_updated_at" timestamp not null,
+
+    "virtual_server_id" uuid not null,
+    "application_id" uuid not null,
+
+    "url" text not null,
+    "auth_type" text not null,
+    "auth_username" text,
+    "auth_secret" text not null,
+
+    "attribute_mapping" jsonb not null,
+    "enabled" boolean not null default true,
+
+    "last_sync_at" timestamp,
+    "last_error" text,
+    "last_error_at" timestamp,
+
+    primary key ("id"),
+    foreign key ("virtual_server_id") references "virtual_servers" ("id"),
+    foreign key ("application_id") references "applications" ("id") on delete cascade,
+    unique ("application_id")
+);
+
+create trigger "trg_set_audit_updated_at"
+    before update
+    on "provisioning_targets"
+    for each row
+execute function update_audit_timestamp();
+
+alter table "outbox_messages"
+    add column "attempts" integer not null default 0,
+    add column "next_attempt_at" timestamp,
+    add column "last_error" text;
+
+-- +migrate Down
+
+alter table "outbox_messages"
+    drop column "last_error",
+    drop column "next_attempt_at",
+    drop column "attempts";
+
+drop table "provisioning_targets";
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "key_rotate_after_seconds" bigint not null default 1728000,
+    add column "key_expire_after_seconds" bigint not null default 2592000,
+    add column "key_pre_publish_lead_seconds" bigint not null default 0;
+
+-- +migrate Down
+
+alter table "virtual_servers"
+    drop column "key_pre_publish_lead_seconds",
+    drop column "key_expire_after_seconds",
+    drop column "key_rotate_after_seconds";
@@ -0,0 +1,35 @@
+-- +migrate Up
+
+create table "signing_keys"
+(
+    "id" uuid not null,
+    "audit_created_at" timestamp not null,
+    "audit_updated_at" timestamp not null,
+
+    "virtual_server_name" text not null,
+    "algorithm" text not null,
+    "kid" text not null,
+
+    "encrypted_private_key" bytea not null,
+    "wrapped_data_key" bytea not null,
+    "master_key_id" text not null,
+
+    "created_at" timestamp not null,
+    "activates_at" timestamp not null,
+    "rotates_at" timestamp not null,
+    "expires_at" timestamp not null,
+    "verify_only" boolean not null default false,
+
+    primary key ("id"),
+    unique ("virtual_server_name", "algorithm", "kid")
+);
+
+create trigger "trg_set_audit_updated_at"
+    before update
+    on "signing_keys"
+    for each row
+execute function update_audit_timestamp();
+
+-- +migrate Down
+
+drop table "signing_keys";
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "passkey_allowed_aaguids" uuid[] not null default '{}',
+    add column "passkey_require_attestation" boolean not null default false,
+    add column "passkey_user_verification" text not null default 'preferred';
+
+-- +migrate Down
+
+alter table "virtual_servers"
+    drop column "passkey_user_verification",
+    drop column "passkey_require_attestation",
+    drop column "passkey_allowed_aaguids";
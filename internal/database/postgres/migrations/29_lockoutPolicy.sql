@@ -0,0 +1,41 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "lockout_max_failed_attempts" integer not null default 0,
+    add column "lockout_max_failed_attempts_per_ip" integer not null default 0,
+    add column "lockout_duration_seconds" bigint not null default 60,
+    add column "lockout_max_duration_seconds" bigint not null default 3600,
+    add column "lockout_permanent_after" integer not null default 0,
+    add column "lockout_notify_user" boolean not null default false;
+
+-- existing virtual servers get the default template for lockout notifications,
+-- the file id is derived from the virtual server to pair both inserts
+insert into "files" ("id", "audit_created_at", "audit_updated_at", "name", "mime_type", "content")
+select md5(vs."id"::text || ':account_locked')::uuid, now(), now(), 'account_locked_template', 'text/plain',
+       convert_to('Your account was locked after too many failed sign-in attempts.{{if .Permanent}} Please contact your administrator to unlock it.{{else}} You can sign in again after {{.LockedUntil}}.{{end}} If this was not you, someone may be trying to guess your password.', 'UTF8')
+from "virtual_servers" vs
+where not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'account_locked'
+);
+
+insert into "templates" ("id", "audit_created_at", "audit_updated_at", "virtual_server_id", "file_id", "type")
+select gen_random_uuid(), now(), now(), vs."id", md5(vs."id"::text || ':account_locked')::uuid, 'account_locked'
+from "virtual_servers" vs
+where exists (
+    select 1 from "files" f where f."id" = md5(vs."id"::text || ':account_locked')::uuid
+) and not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'account_locked'
+);
+
+-- +migrate Down
+
+delete from "templates" where "type" = 'account_locked';
+delete from "files" where "name" = 'account_locked_template';
+
+alter table "virtual_servers"
+    drop column "lockout_notify_user",
+    drop column "lockout_permanent_after",
+    drop column "lockout_max_duration_seconds",
+    drop column "lockout_duration_seconds",
+    drop column "lockout_max_failed_attempts_per_ip",
+    drop column "lockout_max_failed_attempts";
@@ -0,0 +1,25 @@
+-- +migrate Up
+
+-- existing virtual servers get the default template for password reset mails,
+-- the file id is derived from the virtual server to pair both inserts
+insert into "files" ("id", "audit_created_at", "audit_updated_at", "name", "mime_type", "content")
+select md5(vs."id"::text || ':password_reset')::uuid, now(), now(), 'password_reset_template', 'text/plain',
+       convert_to('Someone asked to reset the password of your account. Set a new password by opening the following link: {{.ResetLink}}. The link expires after {{.ExpiresIn}}. If this was not you, you can ignore this mail.', 'UTF8')
+from "virtual_servers" vs
+where not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'password_reset'
+);
+
+insert into "templates" ("id", "audit_created_at", "audit_updated_at", "virtual_server_id", "file_id", "type")
+select gen_random_uuid(), now(), now(), vs."id", md5(vs."id"::text || ':password_reset')::uuid, 'password_reset'
+from "virtual_servers" vs
+where exists (
+    select 1 from "files" f where f."id" = md5(vs."id"::text || ':password_reset')::uuid
+) and not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'password_reset'
+);
+
+-- +migrate Down
+
+delete from "templates" where "type" = 'password_reset';
+delete from "files" where "name" = 'password_reset_template';
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "enable_email_login" boolean not null default false;
+
+-- existing virtual servers get the default template for email login mails,
+-- the file id is derived from the virtual server to pair both inserts
+insert into "files" ("id", "audit_created_at", "audit_updated_at", "name", "mime_type", "content")
+select md5(vs."id"::text || ':email_login')::uuid, now(), now(), 'email_login_template', 'text/plain',
+       convert_to('Someone asked to sign in to your account. Sign in by opening the following link: {{.LoginLink}} or enter the code {{.Code}} on the sign-in page. The link and the code expire after {{.ExpiresIn}}. If this was not you, you can ignore this mail.', 'UTF8')
+from "virtual_servers" vs
+where not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'email_login'
+);
+
+insert into "templates" ("id", "audit_created_at", "audit_updated_at", "virtual_server_id", "file_id", "type")
+select gen_random_uuid(), now(), now(), vs."id", md5(vs."id"::text || ':email_login')::uuid, 'email_login'
+from "virtual_servers" vs
+where exists (
+    select 1 from "files" f where f."id" = md5(vs."id"::text || ':email_login')::uuid
+) and not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'email_login'
+);
+
+-- +migrate Down
+
+delete from "templates" where "type" = 'email_login';
+delete from "files" where "name" = 'email_login_template';
+
+alter table "virtual_servers"
+    drop column "enable_email_login";
@@ -0,0 +1,25 @@
+-- +migrate Up
+
+-- existing virtual servers get the default template for sms codes, the
+-- file id is derived from the virtual server to pair both inserts
+insert into "files" ("id", "audit_created_at", "audit_updated_at", "name", "mime_type", "content")
+select md5(vs."id"::text || ':sms_code')::uuid, now(), now(), 'sms_code_template', 'text/plain',
+       convert_to('Your verification code is {{.Code}}. It expires after {{.ExpiresIn}}.', 'UTF8')
+from "virtual_servers" vs
+where not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'sms_code'
+);
+
+insert into "templates" ("id", "audit_created_at", "audit_updated_at", "virtual_server_id", "file_id", "type")
+select gen_random_uuid(), now(), now(), vs."id", md5(vs."id"::text || ':sms_code')::uuid, 'sms_code'
+from "virtual_servers" vs
+where exists (
+    select 1 from "files" f where f."id" = md5(vs."id"::text || ':sms_code')::uuid
+) and not exists (
+    select 1 from "templates" t where t."virtual_server_id" = vs."id" and t."type" = 'sms_code'
+);
+
+-- +migrate Down
+
+delete from "templates" where "type" = 'sms_code';
+delete from "files" where "name" = 'sms_code_template';
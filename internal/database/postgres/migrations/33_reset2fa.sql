@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table "users" add column "require_2fa_onboarding" boolean not null default false;
+
+-- +migrate Down
+
+alter table "users" drop column "require_2fa_onboarding";
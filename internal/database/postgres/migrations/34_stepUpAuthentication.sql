@@ -0,0 +1,12 @@
+-- +migrate Up
+
+alter table "applications" add column "acr_levels" jsonb not null default '[]';
+
+-- sessions created before remember no methods, so they cannot satisfy an
+-- acr and step up on the first request for one
+alter table "sessions" add column "authentication_methods" text[] not null default '{}';
+
+-- +migrate Down
+
+alter table "sessions" drop column "authentication_methods";
+alter table "applications" drop column "acr_levels";
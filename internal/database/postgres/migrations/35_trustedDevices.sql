@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "trusted_device_days" integer not null default 0;
+
+-- +migrate Down
+
+alter table "virtual_servers"
+    drop column "trusted_device_days";
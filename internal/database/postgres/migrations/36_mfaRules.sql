@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table "virtual_servers"
+    add column "mfa_rules" jsonb not null default '[]';
+
+-- +migrate Down
+
+alter table "virtual_servers"
+    drop column "mfa_rules";
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table "outbox_messages"
+    add column "dead_lettered_at" timestamp;
+
+-- +migrate Down
+
+alter table "outbox_messages"
+    drop column "dead_lettered_at";
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table "applications"
+    add column "saml_certificate" text;
+
+-- +migrate Down
+
+alter table "applications"
+    drop column "saml_certificate";
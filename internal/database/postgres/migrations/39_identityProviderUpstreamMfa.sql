@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table "identity_providers"
+    add column "trust_upstream_mfa" boolean not null default false;
+
+-- +migrate Down
+
+alter table "identity_providers"
+    drop column "trust_upstream_mfa";
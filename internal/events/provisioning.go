@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	db "github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+)
+
+func QueueProvisioningOnUserCreatedEvent(ctx context.Context, event UserCreatedEvent) error {
+	return QueueUserProvisioning(ctx, event.User)
+}
+
+func QueueProvisioningOnUserUpdatedEvent(ctx context.Context, event UserUpdatedEvent) error {
+	return QueueUserProvisioning(ctx, event.User)
+}
+
+func QueueProvisioningOnUserDeletedEvent(ctx context.Context, event UserDeletedEvent) error {
+	return QueueUserProvisioning(ctx, event.User)
+}
+
+// QueueUserProvisioning queues an outbox message per enabled provisioning
+// target of the user's virtual server. Service users are never provisioned.
+func QueueUserProvisioning(ctx context.Context, user *repositories.User) error {
+	if user.IsServiceUser() {
+		return nil
+	}
+
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(user.VirtualServerId()).
+		Enabled(true)
+	targets, err := dbContext.ProvisioningTargets().List(ctx, targetFilter)
+	if err != nil {
+		return fmt.Errorf("listing provisioning targets: %w", err)
+	}
+
+	for _, target := range targets {
+		err = QueueTargetProvisioning(ctx, target, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QueueTargetProvisioning queues an outbox message that brings the user up
+// to date at the given target.
+func QueueTargetProvisioning(ctx context.Context, target *repositories.ProvisioningTarget, user *repositories.User) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+
+	outboxMessage, err := repositories.NewOutboxMessage(&messages.ScimProvisionMessage{
+		VirtualServerId:      user.VirtualServerId(),
+		ProvisioningTargetId: target.Id(),
+		UserId:               user.Id(),
+		Username:             user.Username(),
+	})
+	if err != nil {
+		return fmt.Errorf("creating provisioning outbox message: %w", err)
+	}
+
+	dbContext.OutboxMessages().Insert(outboxMessage)
+	return nil
+}
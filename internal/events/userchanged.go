@@ -0,0 +1,15 @@
+package events
+
+import (
+	"github.com/The127/Keyline/internal/repositories"
+)
+
+// UserUpdatedEvent is raised when the profile or the role assignments of a
+// user change.
+type UserUpdatedEvent struct {
+	User *repositories.User
+}
+
+type UserDeletedEvent struct {
+	User *repositories.User
+}
@@ -0,0 +1,349 @@
+// Package federation connects Keyline to external user directories.
+package federation
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+	"unicode/utf16"
+
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+)
+
+const (
+	searchPageSize = 500
+	dialTimeout    = 10 * time.Second
+)
+
+var (
+	ErrUserNotFound       = errors.New("ldap user not found")
+	ErrAmbiguousUser      = errors.New("ldap username matches more than one entry")
+	ErrInvalidCredentials = errors.New("invalid ldap credentials")
+)
+
+// Config describes how to reach and read a directory.
+type Config struct {
+	ConnectionUrl string
+	StartTls      bool
+	BindDn        string
+	BindPassword  string
+
+	UsersDn    string
+	UserFilter string
+
+	// GroupsDn is empty when groups are not read from the directory.
+	GroupsDn    string
+	GroupFilter string
+
+	Mapping         repositories.LdapAttributeMapping
+	ActiveDirectory bool
+}
+
+// ConfigFromProvider builds the client configuration of a stored provider.
+func ConfigFromProvider(provider *repositories.LdapProvider) Config {
+	groupsDn := ""
+	if provider.GroupsDn() != nil {
+		groupsDn = *provider.GroupsDn()
+	}
+
+	return Config{
+		ConnectionUrl:   provider.ConnectionUrl(),
+		StartTls:        provider.StartTls(),
+		BindDn:          provider.BindDn(),
+		BindPassword:    provider.BindPassword(),
+		UsersDn:         provider.UsersDn(),
+		UserFilter:      provider.UserFilter(),
+		GroupsDn:        groupsDn,
+		GroupFilter:     provider.GroupFilter(),
+		Mapping:         provider.AttributeMapping().WithDefaults(provider.Vendor()),
+		ActiveDirectory: provider.Vendor() == repositories.LdapVendorActiveDirectory,
+	}
+}
+
+// User is a directory user after applying the attribute mapping.
+type User struct {
+	Dn          string
+	ExternalId  string
+	Username    string
+	Email       string
+	DisplayName string
+}
+
+// Group is a directory group with the dns of its direct members.
+type Group struct {
+	Dn        string
+	Name      string
+	MemberDns []string
+}
+
+// Client is a connection bound as the configured service account.
+type Client struct {
+	config Config
+	conn   *ldap.Conn
+}
+
+// Dial connects to the directory and binds as the service account.
+func Dial(config Config) (*Client, error) {
+	conn, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Bind(config.BindDn, config.BindPassword)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("binding service account: %w", err)
+	}
+
+	return &Client{
+		config: config,
+		conn:   conn,
+	}, nil
+}
+
+func connect(config Config) (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(config.ConnectionUrl, ldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to ldap server: %w", err)
+	}
+
+	if config.StartTls {
+		parsed, err := url.Parse(config.ConnectionUrl)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("parsing connection url: %w", err)
+		}
+
+		err = conn.StartTLS(&tls.Config{ServerName: parsed.Hostname()})
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("starting tls: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) userAttributes() []string {
+	return []string{
+		c.config.Mapping.ExternalId,
+		c.config.Mapping.Username,
+		c.config.Mapping.Email,
+		c.config.Mapping.DisplayName,
+	}
+}
+
+// FindUser looks up the user with the given username below UsersDn.
+func (c *Client) FindUser(username string) (*User, error) {
+	filter := fmt.Sprintf("(&%s(%s=%s))", c.config.UserFilter, c.config.Mapping.Username, ldap.EscapeFilter(username))
+	request := ldap.NewSearchRequest(
+		c.config.UsersDn,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		0,
+		false,
+		filter,
+		c.userAttributes(),
+		nil,
+	)
+
+	result, err := c.conn.Search(request)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("searching user: %w", err)
+	}
+
+	switch {
+	case len(result.Entries) == 0:
+		return nil, ErrUserNotFound
+
+	case len(result.Entries) > 1:
+		return nil, ErrAmbiguousUser
+	}
+
+	return c.mapUser(result.Entries[0]), nil
+}
+
+// SearchUsers returns every user matching UserFilter below UsersDn.
+func (c *Client) SearchUsers() ([]User, error) {
+	request := ldap.NewSearchRequest(
+		c.config.UsersDn,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		c.config.UserFilter,
+		c.userAttributes(),
+		nil,
+	)
+
+	result, err := c.conn.SearchWithPaging(request, searchPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("searching users: %w", err)
+	}
+
+	users := make([]User, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		user := c.mapUser(entry)
+		if user.ExternalId == "" || user.Username == "" {
+			continue
+		}
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+// SearchGroups returns every group matching GroupFilter below GroupsDn. It
+// returns nothing if no GroupsDn is configured.
+func (c *Client) SearchGroups() ([]Group, error) {
+	if c.config.GroupsDn == "" {
+		return nil, nil
+	}
+
+	request := ldap.NewSearchRequest(
+		c.config.GroupsDn,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		c.config.GroupFilter,
+		[]string{c.config.Mapping.GroupName, c.config.Mapping.GroupMember},
+		nil,
+	)
+
+	result, err := c.conn.SearchWithPaging(request, searchPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("searching groups: %w", err)
+	}
+
+	groups := make([]Group, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		name := entry.GetEqualFoldAttributeValue(c.config.Mapping.GroupName)
+		if name == "" {
+			continue
+		}
+
+		groups = append(groups, Group{
+			Dn:        entry.DN,
+			Name:      name,
+			MemberDns: entry.GetEqualFoldAttributeValues(c.config.Mapping.GroupMember),
+		})
+	}
+
+	return groups, nil
+}
+
+func (c *Client) mapUser(entry *ldap.Entry) *User {
+	return &User{
+		Dn:          entry.DN,
+		ExternalId:  c.externalId(entry),
+		Username:    entry.GetEqualFoldAttributeValue(c.config.Mapping.Username),
+		Email:       entry.GetEqualFoldAttributeValue(c.config.Mapping.Email),
+		DisplayName: entry.GetEqualFoldAttributeValue(c.config.Mapping.DisplayName),
+	}
+}
+
+// externalId reads the immutable id of an entry. Active Directory returns
+// objectGUID as 16 raw bytes which are formatted as a GUID string.
+func (c *Client) externalId(entry *ldap.Entry) string {
+	raw := entry.GetEqualFoldRawAttributeValue(c.config.Mapping.ExternalId)
+	if c.config.ActiveDirectory && len(raw) == 16 {
+		return formatGuid(raw)
+	}
+	return string(raw)
+}
+
+// formatGuid converts the mixed-endian byte layout of a Windows GUID into
+// its canonical string form.
+func formatGuid(raw []byte) string {
+	var id uuid.UUID
+	binary.BigEndian.PutUint32(id[0:4], binary.LittleEndian.Uint32(raw[0:4]))
+	binary.BigEndian.PutUint16(id[4:6], binary.LittleEndian.Uint16(raw[4:6]))
+	binary.BigEndian.PutUint16(id[6:8], binary.LittleEndian.Uint16(raw[6:8]))
+	copy(id[8:], raw[8:])
+	return id.String()
+}
+
+// Authenticate verifies a user's password with a bind on a separate
+// connection, so the service connection stays bound as the service account.
+func (c *Client) Authenticate(dn string, password string) error {
+	// an empty password would be an unauthenticated bind, which most
+	// servers accept without checking anything
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+
+	conn, err := connect(c.config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Bind(dn, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("binding user: %w", err)
+	}
+
+	return nil
+}
+
+// SetPassword replaces a user's password. Active Directory only accepts the
+// quoted UTF-16LE unicodePwd attribute, other servers get the password
+// modify extended operation.
+func (c *Client) SetPassword(dn string, password string) error {
+	if c.config.ActiveDirectory {
+		request := ldap.NewModifyRequest(dn, nil)
+		request.Replace("unicodePwd", []string{encodeUnicodePwd(password)})
+
+		err := c.conn.Modify(request)
+		if err != nil {
+			return fmt.Errorf("modifying unicodePwd: %w", err)
+		}
+		return nil
+	}
+
+	_, err := c.conn.PasswordModify(ldap.NewPasswordModifyRequest(dn, "", password))
+	if err != nil {
+		return fmt.Errorf("modifying password: %w", err)
+	}
+	return nil
+}
+
+func encodeUnicodePwd(password string) string {
+	units := utf16.Encode([]rune(`"` + password + `"`))
+	encoded := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+	}
+	return string(encoded)
+}
+
+// UpdateUser writes the mapped email and display name attributes.
+func (c *Client) UpdateUser(dn string, email string, displayName string) error {
+	request := ldap.NewModifyRequest(dn, nil)
+	request.Replace(c.config.Mapping.Email, []string{email})
+	request.Replace(c.config.Mapping.DisplayName, []string{displayName})
+
+	err := c.conn.Modify(request)
+	if err != nil {
+		return fmt.Errorf("modifying user: %w", err)
+	}
+	return nil
+}
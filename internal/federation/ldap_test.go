@@ -0,0 +1,220 @@
+package federation
+
+import (
+	"testing"
+
+	"github.com/The127/Keyline/internal/federation/ldaptest"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testServiceDn = "cn=keyline,dc=example,dc=com"
+	testUserDn    = "uid=alice,ou=people,dc=example,dc=com"
+)
+
+func newTestDirectory(t *testing.T) *ldaptest.Server {
+	t.Helper()
+
+	server, err := ldaptest.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	server.AddEntry(testServiceDn, map[string][]string{
+		"objectClass":  {"person"},
+		"userPassword": {"service-secret"},
+	})
+	server.AddEntry(testUserDn, map[string][]string{
+		"objectClass":  {"inetOrgPerson"},
+		"entryUUID":    {"6f1c1c1e-6c4b-4a4f-9d6a-3a1f5d0b7e11"},
+		"uid":          {"alice"},
+		"mail":         {"alice@example.com"},
+		"cn":           {"Alice Example"},
+		"userPassword": {"alice-secret"},
+	})
+	server.AddEntry("uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"entryUUID":   {"0b8e5a4e-58f4-4a7c-8f5e-2c8d7f6a1b22"},
+		"uid":         {"bob"},
+		"cn":          {"Bob Example"},
+	})
+	server.AddEntry("cn=admins,ou=groups,dc=example,dc=com", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"admins"},
+		"member":      {testUserDn},
+	})
+
+	return server
+}
+
+func testConfig(server *ldaptest.Server) Config {
+	return Config{
+		ConnectionUrl: server.Url(),
+		BindDn:        testServiceDn,
+		BindPassword:  "service-secret",
+		UsersDn:       "ou=people,dc=example,dc=com",
+		UserFilter:    repositories.DefaultLdapUserFilter(repositories.LdapVendorGeneric),
+		GroupsDn:      "ou=groups,dc=example,dc=com",
+		GroupFilter:   repositories.DefaultLdapGroupFilter(repositories.LdapVendorGeneric),
+		Mapping:       repositories.DefaultLdapAttributeMapping(repositories.LdapVendorGeneric),
+	}
+}
+
+func dialTestDirectory(t *testing.T, config Config) *Client {
+	t.Helper()
+
+	client, err := Dial(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestDialRejectsWrongServicePassword(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := newTestDirectory(t)
+	config := testConfig(server)
+	config.BindPassword = "wrong"
+
+	// act
+	_, err := Dial(config)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestFindUserMapsAttributes(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	client := dialTestDirectory(t, testConfig(newTestDirectory(t)))
+
+	// act
+	user, err := client.FindUser("alice")
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, User{
+		Dn:          testUserDn,
+		ExternalId:  "6f1c1c1e-6c4b-4a4f-9d6a-3a1f5d0b7e11",
+		Username:    "alice",
+		Email:       "alice@example.com",
+		DisplayName: "Alice Example",
+	}, *user)
+}
+
+func TestFindUserEscapesUsername(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	client := dialTestDirectory(t, testConfig(newTestDirectory(t)))
+
+	// act
+	_, err := client.FindUser("*")
+
+	// assert
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	client := dialTestDirectory(t, testConfig(newTestDirectory(t)))
+
+	// act
+	validErr := client.Authenticate(testUserDn, "alice-secret")
+	wrongErr := client.Authenticate(testUserDn, "wrong")
+	emptyErr := client.Authenticate(testUserDn, "")
+
+	// assert
+	require.NoError(t, validErr)
+	require.ErrorIs(t, wrongErr, ErrInvalidCredentials)
+	require.ErrorIs(t, emptyErr, ErrInvalidCredentials)
+}
+
+func TestSearchUsersAndGroups(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	client := dialTestDirectory(t, testConfig(newTestDirectory(t)))
+
+	// act
+	users, usersErr := client.SearchUsers()
+	groups, groupsErr := client.SearchGroups()
+
+	// assert
+	require.NoError(t, usersErr)
+	require.NoError(t, groupsErr)
+	require.Len(t, users, 2)
+	require.Equal(t, []Group{{
+		Dn:        "cn=admins,ou=groups,dc=example,dc=com",
+		Name:      "admins",
+		MemberDns: []string{testUserDn},
+	}}, groups)
+}
+
+func TestSetPasswordUsesPasswordModify(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := newTestDirectory(t)
+	client := dialTestDirectory(t, testConfig(server))
+
+	// act
+	err := client.SetPassword(testUserDn, "new-secret")
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, client.Authenticate(testUserDn, "new-secret"))
+}
+
+func TestSetPasswordWritesUnicodePwdForActiveDirectory(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := newTestDirectory(t)
+	config := testConfig(server)
+	config.ActiveDirectory = true
+	client := dialTestDirectory(t, config)
+
+	// act
+	err := client.SetPassword(testUserDn, "new-secret")
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, []string{"new-secret"}, server.Entry(testUserDn).Attributes["userPassword"])
+}
+
+func TestUpdateUser(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := newTestDirectory(t)
+	client := dialTestDirectory(t, testConfig(server))
+
+	// act
+	err := client.UpdateUser(testUserDn, "alice@corp.example.com", "Alice Corp")
+
+	// assert
+	require.NoError(t, err)
+	entry := server.Entry(testUserDn)
+	require.Equal(t, []string{"alice@corp.example.com"}, entry.Attributes["mail"])
+	require.Equal(t, []string{"Alice Corp"}, entry.Attributes["cn"])
+}
+
+func TestFormatGuid(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	raw := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+	// act
+	guid := formatGuid(raw)
+
+	// assert
+	require.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", guid)
+}
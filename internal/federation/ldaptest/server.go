@@ -0,0 +1,489 @@
+// Package ldaptest provides a minimal in-process LDAP server for tests.
+//
+// It understands just enough of the protocol for the federation client:
+// simple binds, searches with the common filter types, modify requests and
+// the password modify extended operation. Passwords are kept in the
+// userPassword attribute in clear text; unicodePwd modifications as sent to
+// Active Directory are translated into it.
+package ldaptest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const passwordModifyOid = "1.3.6.1.4.1.4203.1.11.1"
+
+// Entry is a directory entry. Attribute names are matched case-insensitively.
+type Entry struct {
+	Dn         string
+	Attributes map[string][]string
+}
+
+func (e *Entry) values(name string) []string {
+	for key, values := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e *Entry) setValues(name string, values []string) {
+	for key := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			delete(e.Attributes, key)
+		}
+	}
+	if len(values) > 0 {
+		e.Attributes[name] = values
+	}
+}
+
+func (e *Entry) clone() *Entry {
+	attributes := make(map[string][]string, len(e.Attributes))
+	for key, values := range e.Attributes {
+		attributes[key] = slices.Clone(values)
+	}
+	return &Entry{Dn: e.Dn, Attributes: attributes}
+}
+
+type Server struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	entries []*Entry
+	binds   []string
+}
+
+// Start listens on a random local port and serves connections until Close is
+// called.
+func Start() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+
+	s := &Server{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+// Url returns the ldap:// url the server listens on.
+func (s *Server) Url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// AddEntry adds or replaces the entry with the given dn.
+func (s *Server) AddEntry(dn string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := (&Entry{Dn: dn, Attributes: attributes}).clone()
+	for i, existing := range s.entries {
+		if strings.EqualFold(existing.Dn, dn) {
+			s.entries[i] = entry
+			return
+		}
+	}
+	s.entries = append(s.entries, entry)
+}
+
+// RemoveEntry deletes the entry with the given dn.
+func (s *Server) RemoveEntry(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = slices.DeleteFunc(s.entries, func(e *Entry) bool {
+		return strings.EqualFold(e.Dn, dn)
+	})
+}
+
+// Entry returns a copy of the entry with the given dn or nil.
+func (s *Server) Entry(dn string) *Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.find(dn)
+	if entry == nil {
+		return nil
+	}
+	return entry.clone()
+}
+
+// Binds returns the dns of all successful non-anonymous binds in order.
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.binds)
+}
+
+func (s *Server) find(dn string) *Entry {
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.Dn, dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+type session struct {
+	conn    net.Conn
+	boundDn string
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	session := &session{conn: conn}
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+
+		messageId, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			s.bind(session, messageId, op)
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		case ldap.ApplicationSearchRequest:
+			s.search(session, messageId, op)
+
+		case ldap.ApplicationModifyRequest:
+			s.modify(session, messageId, op)
+
+		case ldap.ApplicationExtendedRequest:
+			s.extended(session, messageId, op)
+
+		default:
+			session.write(messageId, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform, "operation not supported"))
+		}
+	}
+}
+
+func (s *session) write(messageId int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "Message ID"))
+	envelope.AppendChild(op)
+
+	_, err := s.conn.Write(envelope.Bytes())
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = s.conn.Close()
+	}
+}
+
+func result(tag ber.Tag, code int, message string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	return op
+}
+
+func decode(packet *ber.Packet) string {
+	return ber.DecodeString(packet.Data.Bytes())
+}
+
+func (s *Server) bind(session *session, messageId int64, op *ber.Packet) {
+	if len(op.Children) < 3 || op.Children[2].Tag != 0 {
+		session.write(messageId, result(ldap.ApplicationBindResponse, ldap.LDAPResultAuthMethodNotSupported, "only simple binds are supported"))
+		return
+	}
+
+	dn := decode(op.Children[1])
+	password := decode(op.Children[2])
+
+	// an empty password is an unauthenticated bind, which real servers
+	// accept as anonymous
+	if password == "" {
+		session.boundDn = ""
+		session.write(messageId, result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, ""))
+		return
+	}
+
+	s.mu.Lock()
+	entry := s.find(dn)
+	ok := entry != nil && slices.Contains(entry.values("userPassword"), password)
+	if ok {
+		s.binds = append(s.binds, entry.Dn)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		session.write(messageId, result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "invalid credentials"))
+		return
+	}
+
+	session.boundDn = dn
+	session.write(messageId, result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, ""))
+}
+
+func (s *Server) search(session *session, messageId int64, op *ber.Packet) {
+	if session.boundDn == "" {
+		session.write(messageId, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights, "bind required"))
+		return
+	}
+	if len(op.Children) < 8 {
+		session.write(messageId, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "malformed search request"))
+		return
+	}
+
+	baseDn := strings.ToLower(decode(op.Children[0]))
+	scope, _ := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, decode(attribute))
+	}
+
+	s.mu.Lock()
+	var matches []*Entry
+	for _, entry := range s.entries {
+		if inScope(strings.ToLower(entry.Dn), baseDn, scope) && matchesFilter(entry, filter) {
+			matches = append(matches, entry.clone())
+		}
+	}
+	s.mu.Unlock()
+
+	for _, entry := range matches {
+		session.write(messageId, searchEntry(entry, requested))
+	}
+	session.write(messageId, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, ""))
+}
+
+func inScope(dn string, baseDn string, scope int64) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn == baseDn
+
+	case ldap.ScopeSingleLevel:
+		_, parent, found := strings.Cut(dn, ",")
+		return found && parent == baseDn
+
+	default:
+		return dn == baseDn || strings.HasSuffix(dn, ","+baseDn)
+	}
+}
+
+func searchEntry(entry *Entry, requested []string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.Dn, "Object Name"))
+
+	all := len(requested) == 0 || slices.Contains(requested, "*")
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.Attributes {
+		explicit := slices.ContainsFunc(requested, func(r string) bool { return strings.EqualFold(r, name) })
+		if !explicit && (!all || strings.EqualFold(name, "userPassword")) {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+
+	return op
+}
+
+func matchesFilter(entry *Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchesFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchesFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !matchesFilter(entry, filter.Children[0])
+
+	case ldap.FilterEqualityMatch:
+		expected := decode(filter.Children[1])
+		return slices.ContainsFunc(entry.values(decode(filter.Children[0])), func(value string) bool {
+			return strings.EqualFold(value, expected)
+		})
+
+	case ldap.FilterPresent:
+		return len(entry.values(decode(filter))) > 0
+
+	case ldap.FilterSubstrings:
+		return slices.ContainsFunc(entry.values(decode(filter.Children[0])), func(value string) bool {
+			return matchesSubstrings(strings.ToLower(value), filter.Children[1].Children)
+		})
+
+	default:
+		return false
+	}
+}
+
+func matchesSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		substring := strings.ToLower(decode(part))
+		switch part.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, substring) {
+				return false
+			}
+			value = value[len(substring):]
+
+		case ldap.FilterSubstringsAny:
+			index := strings.Index(value, substring)
+			if index < 0 {
+				return false
+			}
+			value = value[index+len(substring):]
+
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, substring) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Server) modify(session *session, messageId int64, op *ber.Packet) {
+	if session.boundDn == "" {
+		session.write(messageId, result(ldap.ApplicationModifyResponse, ldap.LDAPResultInsufficientAccessRights, "bind required"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.find(decode(op.Children[0]))
+	if entry == nil {
+		session.write(messageId, result(ldap.ApplicationModifyResponse, ldap.LDAPResultNoSuchObject, "no such object"))
+		return
+	}
+
+	for _, change := range op.Children[1].Children {
+		operation, _ := change.Children[0].Value.(int64)
+		name := decode(change.Children[1].Children[0])
+		var values []string
+		for _, value := range change.Children[1].Children[1].Children {
+			values = append(values, decode(value))
+		}
+
+		if strings.EqualFold(name, "unicodePwd") {
+			name = "userPassword"
+			for i, value := range values {
+				values[i] = decodeUnicodePwd(value)
+			}
+		}
+
+		switch operation {
+		case ldap.AddAttribute:
+			entry.setValues(name, append(entry.values(name), values...))
+
+		case ldap.DeleteAttribute:
+			if len(values) == 0 {
+				entry.setValues(name, nil)
+			} else {
+				entry.setValues(name, slices.DeleteFunc(slices.Clone(entry.values(name)), func(v string) bool {
+					return slices.Contains(values, v)
+				}))
+			}
+
+		case ldap.ReplaceAttribute:
+			entry.setValues(name, values)
+		}
+	}
+
+	session.write(messageId, result(ldap.ApplicationModifyResponse, ldap.LDAPResultSuccess, ""))
+}
+
+// decodeUnicodePwd reverses the quoted UTF-16LE encoding Active Directory
+// expects for unicodePwd.
+func decodeUnicodePwd(value string) string {
+	raw := []byte(value)
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])|uint16(raw[i+1])<<8)
+	}
+	return strings.Trim(string(utf16.Decode(units)), `"`)
+}
+
+func (s *Server) extended(session *session, messageId int64, op *ber.Packet) {
+	if len(op.Children) < 2 || decode(op.Children[0]) != passwordModifyOid {
+		session.write(messageId, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError, "unsupported extended operation"))
+		return
+	}
+	if session.boundDn == "" {
+		session.write(messageId, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultInsufficientAccessRights, "bind required"))
+		return
+	}
+
+	request := ber.DecodePacket(op.Children[1].Data.Bytes())
+	userIdentity := session.boundDn
+	newPassword := ""
+	for _, child := range request.Children {
+		switch child.Tag {
+		case 0:
+			userIdentity = decode(child)
+		case 2:
+			newPassword = decode(child)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.find(userIdentity)
+	if entry == nil || newPassword == "" {
+		session.write(messageId, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform, "cannot change password"))
+		return
+	}
+
+	entry.setValues("userPassword", []string{newPassword})
+	session.write(messageId, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess, ""))
+}
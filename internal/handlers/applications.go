@@ -70,11 +70,15 @@ func CreateApplication(w http.ResponseWriter, r *http.Request) {
 		Name:                   dto.Name,
 		DisplayName:            dto.DisplayName,
 		Type:                   repositories.ApplicationType(dto.Type),
-		RedirectUris:           dto.RedirectUris,
+		RedirectUris:           utils.EmptyIfNil(dto.RedirectUris),
 		PostLogoutRedirectUris: utils.EmptyIfNil(dto.PostLogoutUris),
 		AccessTokenHeaderType:  accessTokenHeaderType,
 		DeviceFlowEnabled:      dto.DeviceFlowEnabled,
 		SigningAlgorithm:       (*config.SigningAlgorithm)(dto.SigningAlgorithm),
+		SamlEntityId:           dto.SamlEntityId,
+		SamlAcsUrl:             dto.SamlAcsUrl,
+		SamlSloUrl:             dto.SamlSloUrl,
+		SamlCertificate:        dto.SamlCertificate,
 	})
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -144,6 +148,15 @@ func GetApplication(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	acrLevels := utils.MapSlice(application.AcrLevels, func(level repositories.AcrLevel) api.AcrLevelDto {
+		return api.AcrLevelDto{
+			Acr: level.Acr,
+			Methods: utils.MapSlice(level.Methods, func(method repositories.AuthenticationMethod) string {
+				return string(method)
+			}),
+		}
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -159,6 +172,11 @@ func GetApplication(w http.ResponseWriter, r *http.Request) {
 		AccessTokenHeaderType:  application.AccessTokenHeaderType,
 		DeviceFlowEnabled:      application.DeviceFlowEnabled,
 		SigningAlgorithm:       (*string)(application.SigningAlgorithm),
+		SamlEntityId:           application.SamlEntityId,
+		SamlAcsUrl:             application.SamlAcsUrl,
+		SamlSloUrl:             application.SamlSloUrl,
+		SamlCertificate:        application.SamlCertificate,
+		AcrLevels:              acrLevels,
 		CreatedAt:              application.CreatedAt,
 		UpdatedAt:              application.UpdatedAt,
 	})
@@ -219,6 +237,18 @@ func PatchApplication(w http.ResponseWriter, r *http.Request) {
 	if dto.PostLogoutUris != nil {
 		postLogoutUris = &dto.PostLogoutUris
 	}
+	var acrLevels *repositories.AcrLevels
+	if dto.AcrLevels != nil {
+		levels := repositories.AcrLevels(utils.MapSlice(*dto.AcrLevels, func(level api.AcrLevelDto) repositories.AcrLevel {
+			return repositories.AcrLevel{
+				Acr: level.Acr,
+				Methods: utils.MapSlice(level.Methods, func(method string) repositories.AuthenticationMethod {
+					return repositories.AuthenticationMethod(method)
+				}),
+			}
+		}))
+		acrLevels = &levels
+	}
 
 	_, err = mediatr.Send[*commands.PatchApplicationResponse](ctx, m, commands.PatchApplication{
 		VirtualServerName:      vsName,
@@ -231,6 +261,10 @@ func PatchApplication(w http.ResponseWriter, r *http.Request) {
 		PostLogoutRedirectUris: postLogoutUris,
 		AccessTokenHeaderType:  dto.AccessTokenHeaderType,
 		SigningAlgorithm:       (*config.SigningAlgorithm)(dto.SigningAlgorithm),
+		SamlAcsUrl:             dto.SamlAcsUrl,
+		SamlSloUrl:             dto.SamlSloUrl,
+		SamlCertificate:        dto.SamlCertificate,
+		AcrLevels:              acrLevels,
 	})
 	if err != nil {
 		utils.HandleHttpError(w, err)
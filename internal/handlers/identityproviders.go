@@ -0,0 +1,782 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/broker"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/jsonTypes"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"net/http"
+	"time"
+
+	"github.com/The127/go-clock"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const identityProviderLoginStateExpiration = 10 * time.Minute
+
+// identityProviderRequestTimeout bounds the discovery, token and userinfo
+// requests to an upstream, a hanging upstream must not hold logins open.
+const identityProviderRequestTimeout = 10 * time.Second
+
+// ListIdentityProviders
+// @summary     List identity providers
+// @description Retrieve all upstream identity providers of a virtual server.
+// @tags        Identity providers
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @success     200 {object} api.ListIdentityProvidersResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/identity-providers [get]
+func ListIdentityProviders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	identityProviders, err := mediatr.Send[*queries.ListIdentityProvidersResponse](ctx, m, queries.ListIdentityProviders{
+		VirtualServerName: vsName,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	response := api.ListIdentityProvidersResponseDto{
+		Items: make([]api.ListIdentityProvidersResponseItemDto, 0, len(identityProviders.Items)),
+	}
+	for _, item := range identityProviders.Items {
+		response.Items = append(response.Items, api.ListIdentityProvidersResponseItemDto{
+			Id:          item.Id,
+			Name:        item.Name,
+			DisplayName: item.DisplayName,
+			Issuer:      item.Issuer,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// CreateIdentityProvider
+// @summary     Create identity provider
+// @description Register an upstream OIDC/OAuth2 identity provider users can sign in with.
+// @tags        Identity providers
+// @accept      application/json
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       body  body   api.CreateIdentityProviderRequestDto  true  "Identity provider details"
+// @success     201 {object} api.CreateIdentityProviderResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     409  {string}  string "Conflict"
+// @router      /api/virtual-servers/{virtualServerName}/identity-providers [post]
+func CreateIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.CreateIdentityProviderRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*commands.CreateIdentityProviderResponse](ctx, m, commands.CreateIdentityProvider{
+		VirtualServerName: vsName,
+		Name:              dto.Name,
+		DisplayName:       dto.DisplayName,
+		Issuer:            dto.Issuer,
+		ClientId:          dto.ClientId,
+		ClientSecret:      dto.ClientSecret,
+		Scopes:            identityProviderScopes(dto.Scopes),
+		AuthorizationUrl:  dto.AuthorizationUrl,
+		TokenUrl:          dto.TokenUrl,
+		UserinfoUrl:       dto.UserinfoUrl,
+		ClaimMapping:      mapIdentityProviderClaimMappingDto(utils.ZeroIfNil(dto.ClaimMapping)),
+		LinkByEmail:       dto.LinkByEmail,
+		TrustUpstreamMfa:  dto.TrustUpstreamMfa,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(api.CreateIdentityProviderResponseDto{
+		Id:          response.Id,
+		RedirectUri: identityProviderRedirectUri(vsName, dto.Name),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// GetIdentityProvider
+// @summary     Get identity provider
+// @description Retrieve an upstream identity provider. The client secret is never returned.
+// @tags        Identity providers
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       identityProviderId  path   string  true  "Identity provider ID (UUID)"
+// @success     200 {object} api.GetIdentityProviderResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/identity-providers/{identityProviderId} [get]
+func GetIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	identityProviderId, err := uuid.Parse(mux.Vars(r)["identityProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	identityProvider, err := mediatr.Send[*queries.GetIdentityProviderResponse](ctx, m, queries.GetIdentityProvider{
+		VirtualServerName:  vsName,
+		IdentityProviderId: identityProviderId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	claimMapping := identityProvider.ClaimMapping
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(api.GetIdentityProviderResponseDto{
+		Id:               identityProvider.Id,
+		Name:             identityProvider.Name,
+		DisplayName:      identityProvider.DisplayName,
+		Issuer:           identityProvider.Issuer,
+		ClientId:         identityProvider.ClientId,
+		Scopes:           identityProvider.Scopes,
+		AuthorizationUrl: identityProvider.AuthorizationUrl,
+		TokenUrl:         identityProvider.TokenUrl,
+		UserinfoUrl:      identityProvider.UserinfoUrl,
+		ClaimMapping: api.IdentityProviderClaimMappingDto{
+			Subject:       claimMapping.Subject,
+			Username:      claimMapping.Username,
+			Email:         claimMapping.Email,
+			EmailVerified: claimMapping.EmailVerified,
+			DisplayName:   claimMapping.DisplayName,
+		},
+		LinkByEmail:      identityProvider.LinkByEmail,
+		TrustUpstreamMfa: identityProvider.TrustUpstreamMfa,
+		RedirectUri:      identityProviderRedirectUri(vsName, identityProvider.Name),
+		CreatedAt:        identityProvider.CreatedAt,
+		UpdatedAt:        identityProvider.UpdatedAt,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// PatchIdentityProvider
+// @summary     Patch identity provider
+// @description Update an upstream identity provider. An empty endpoint url falls back to discovery.
+// @tags        Identity providers
+// @accept      application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       identityProviderId  path   string  true  "Identity provider ID (UUID)"
+// @param       body  body   api.PatchIdentityProviderRequestDto  true  "Identity provider details"
+// @success     204 "No Content"
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/identity-providers/{identityProviderId} [patch]
+func PatchIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	identityProviderId, err := uuid.Parse(mux.Vars(r)["identityProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	var dto api.PatchIdentityProviderRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var claimMapping *repositories.IdentityProviderClaimMapping
+	if dto.ClaimMapping != nil {
+		claimMapping = utils.Ptr(mapIdentityProviderClaimMappingDto(*dto.ClaimMapping))
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.PatchIdentityProviderResponse](ctx, m, commands.PatchIdentityProvider{
+		VirtualServerName:  vsName,
+		IdentityProviderId: identityProviderId,
+		DisplayName:        dto.DisplayName,
+		Issuer:             dto.Issuer,
+		ClientId:           dto.ClientId,
+		ClientSecret:       dto.ClientSecret,
+		Scopes:             dto.Scopes,
+		AuthorizationUrl:   dto.AuthorizationUrl,
+		TokenUrl:           dto.TokenUrl,
+		UserinfoUrl:        dto.UserinfoUrl,
+		ClaimMapping:       claimMapping,
+		LinkByEmail:        dto.LinkByEmail,
+		TrustUpstreamMfa:   dto.TrustUpstreamMfa,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteIdentityProvider
+// @summary     Delete identity provider
+// @description Delete an upstream identity provider together with all account links to it.
+// @tags        Identity providers
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       identityProviderId  path   string  true  "Identity provider ID (UUID)"
+// @success     204 "No Content"
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/identity-providers/{identityProviderId} [delete]
+func DeleteIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	identityProviderId, err := uuid.Parse(mux.Vars(r)["identityProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.DeleteIdentityProviderResponse](ctx, m, commands.DeleteIdentityProvider{
+		VirtualServerName:  vsName,
+		IdentityProviderId: identityProviderId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginIdentityProviderLogin sends the user to an upstream identity provider.
+// @Summary      Sign in with an identity provider
+// @Tags         Logins
+// @Param        loginToken  path   string true  "Login session token"
+// @Param        identityProviderName  path   string true  "Identity provider name"
+// @Success      302
+// @Failure      401  {string}  string "Unknown/invalid token"
+// @Failure      404  {string}  string "Not Found"
+// @Router       /logins/{loginToken}/identity-providers/{identityProviderName} [get]
+func BeginIdentityProviderLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+	identityProviderName := vars["identityProviderName"]
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	rawLoginInfo, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting login session: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	var loginInfo jsonTypes.LoginInfo
+	err = json.Unmarshal([]byte(rawLoginInfo), &loginInfo)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("unmarshal login info: %w", err))
+		return
+	}
+
+	// brokering replaces the first factor, so it is only offered before the
+	// user has started authenticating locally
+	if loginInfo.Step != jsonTypes.LoginStepPasswordVerification {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(loginInfo.VirtualServerId).
+		Name(identityProviderName)
+	identityProvider, err := dbContext.IdentityProviders().FirstOrErr(ctx, identityProviderFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	provider, err := newBrokerProvider(ctx, loginInfo.VirtualServerName, identityProvider)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	state := base64.RawURLEncoding.EncodeToString(utils.GetSecureRandomBytes(32))
+	loginState := jsonTypes.IdentityProviderLoginState{
+		LoginSessionToken:  loginToken,
+		IdentityProviderId: identityProvider.Id(),
+		CodeVerifier:       broker.NewVerifier(),
+		Nonce:              base64.RawURLEncoding.EncodeToString(utils.GetSecureRandomBytes(32)),
+	}
+
+	loginStateJson, err := json.Marshal(loginState)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	err = kvStore.Set(ctx, identityProviderLoginStateKey(state), string(loginStateJson), keyValue.WithExpiration(identityProviderLoginStateExpiration))
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// The state is also bound to the browser that started the flow, so a
+	// callback URL planted by someone else cannot log the victim into the
+	// attacker's upstream account (RFC 6749 §10.12).
+	setIdentityProviderStateCookie(w, loginInfo.VirtualServerName, state, int(identityProviderLoginStateExpiration.Seconds()))
+
+	http.Redirect(w, r, provider.AuthCodeUrl(state, loginState.Nonce, loginState.CodeVerifier), http.StatusFound)
+}
+
+// IdentityProviderCallback completes a brokered login after the upstream
+// identity provider redirected the user back.
+// @Summary      Identity provider callback
+// @Tags         OIDC
+// @Param        virtualServerName  path   string true  "Virtual server name"  default(keyline)
+// @Param        identityProviderName  path   string true  "Identity provider name"
+// @Param        code   query  string true  "Authorization code"
+// @Param        state  query  string true  "State"
+// @Success      302
+// @Failure      401  {string}  string "Unauthorized"
+// @Router       /oidc/{virtualServerName}/identity-providers/{identityProviderName}/callback [get]
+func IdentityProviderCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	identityProviderName := mux.Vars(r)["identityProviderName"]
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	if state == "" {
+		utils.HandleHttpError(w, fmt.Errorf("missing state: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	stateCookie, err := r.Cookie(identityProviderStateCookieName(vsName))
+	if err != nil || stateCookie.Value != state {
+		utils.HandleHttpError(w, fmt.Errorf("state does not match this browser: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+	setIdentityProviderStateCookie(w, vsName, "", -1)
+
+	// the state is single use
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	loginStateJson, err := kvStore.GetAndDelete(ctx, identityProviderLoginStateKey(state))
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("state expired or missing: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	var loginState jsonTypes.IdentityProviderLoginState
+	err = json.Unmarshal([]byte(loginStateJson), &loginState)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	if upstreamError := query.Get("error"); upstreamError != "" {
+		utils.HandleHttpError(w, fmt.Errorf("identity provider returned %q: %w", upstreamError, utils.ErrHttpUnauthorized))
+		return
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(loginState.IdentityProviderId)
+	identityProvider, err := dbContext.IdentityProviders().FirstOrErr(ctx, identityProviderFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	if identityProvider.Name() != identityProviderName {
+		utils.HandleHttpError(w, fmt.Errorf("state was issued for another identity provider: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	provider, err := newBrokerProvider(ctx, vsName, identityProvider)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	claims, err := provider.Exchange(ctx, broker.ExchangeParams{
+		Code:     query.Get("code"),
+		Verifier: loginState.CodeVerifier,
+		Nonce:    loginState.Nonce,
+		Now:      clockService.Now(),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("%w: %w", utils.ErrHttpUnauthorized, err))
+		return
+	}
+
+	err = updateLoginStep(ctx, loginState.LoginSessionToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		if loginInfo.VirtualServerId != virtualServer.Id() {
+			return utils.ErrHttpUnauthorized
+		}
+		if loginInfo.Step != jsonTypes.LoginStepPasswordVerification {
+			return utils.ErrHttpUnauthorized
+		}
+
+		userId, err := resolveBrokeredUser(ctx, virtualServer, identityProvider, claims)
+		if err != nil {
+			return err
+		}
+		// a step-up login is for the user of the session
+		if loginInfo.UserId != uuid.Nil && loginInfo.UserId != userId {
+			return utils.ErrHttpUnauthorized
+		}
+
+		lockoutService := ioc.GetDependency[services.LockoutService](scope)
+		err = lockoutService.CheckUser(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		trustedDeviceId, err := trustedDeviceOfRequest(ctx, r, virtualServer, userId)
+		if err != nil {
+			return err
+		}
+
+		loginInfo.UserId = userId
+		loginInfo.TrustedDeviceId = trustedDeviceId
+		loginInfo.ClientIp = utils.ClientIp(r, config.C.Server.TrustedProxies)
+		loginInfo.UserAgent = r.UserAgent()
+		// any provider can report more than one factor, only the ones an
+		// admin trusts for it skip the local second factor
+		loginInfo.UpstreamMultiFactor = identityProvider.TrustUpstreamMfa() && broker.ProvesMultiFactor(claims)
+		loginInfo.Step = jsonTypes.LoginStepIdentityProvider
+		return nil
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	redirectUrl := fmt.Sprintf(
+		"%s/login?token=%s",
+		config.C.Frontend.ExternalUrl,
+		loginState.LoginSessionToken,
+	)
+	http.Redirect(w, r, redirectUrl, http.StatusFound)
+}
+
+// resolveBrokeredUser returns the local user for an upstream account. The
+// account link is looked up first; without one an existing user is linked
+// by verified email if the provider allows it, otherwise a new user is
+// created just in time. Disabled users are rejected.
+func resolveBrokeredUser(
+	ctx context.Context,
+	virtualServer *repositories.VirtualServer,
+	identityProvider *repositories.IdentityProvider,
+	claims map[string]any,
+) (uuid.UUID, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	claimMapping := identityProvider.ClaimMapping()
+
+	subject := broker.StringClaim(claims, claimMapping.Subject)
+	if subject == "" {
+		return uuid.Nil, fmt.Errorf("upstream claims have no %q: %w", claimMapping.Subject, utils.ErrHttpUnauthorized)
+	}
+
+	linkFilter := repositories.NewCredentialFilter().
+		Type(repositories.CredentialTypeIdentityProvider).
+		DetailsId(repositories.IdentityProviderCredentialId(identityProvider.Id(), subject))
+	link, err := dbContext.Credentials().FirstOrNil(ctx, linkFilter)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting account link: %w", err)
+	}
+	if link != nil {
+		userFilter := repositories.NewUserFilter().
+			VirtualServerId(virtualServer.Id()).
+			Id(link.UserId())
+		user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("getting linked user: %w", err)
+		}
+		if user.Disabled() {
+			return uuid.Nil, fmt.Errorf("user is disabled: %w", utils.ErrHttpUnauthorized)
+		}
+		return user.Id(), nil
+	}
+
+	email := broker.StringClaim(claims, claimMapping.Email)
+	emailVerified := email != "" && broker.BoolClaim(claims, claimMapping.EmailVerified)
+
+	var userId uuid.UUID
+
+	// only a verified email proves the upstream account belongs to the
+	// owner of the local one
+	if identityProvider.LinkByEmail() && emailVerified {
+		userFilter := repositories.NewUserFilter().
+			VirtualServerId(virtualServer.Id()).
+			ServiceUser(false).
+			PrimaryEmail(email)
+		user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("getting user by email: %w", err)
+		}
+		if user != nil && user.Disabled() {
+			return uuid.Nil, fmt.Errorf("user is disabled: %w", utils.ErrHttpUnauthorized)
+		}
+		if user != nil {
+			userId = user.Id()
+		}
+	}
+
+	if userId == uuid.Nil {
+		userId, err = createBrokeredUser(ctx, virtualServer, identityProvider, claims, subject, email, emailVerified)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(
+		userId,
+		repositories.NewCredentialIdentityProviderDetails(identityProvider.Id(), subject),
+	))
+
+	return userId, nil
+}
+
+func createBrokeredUser(
+	ctx context.Context,
+	virtualServer *repositories.VirtualServer,
+	identityProvider *repositories.IdentityProvider,
+	claims map[string]any,
+	subject string,
+	email string,
+	emailVerified bool,
+) (uuid.UUID, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	claimMapping := identityProvider.ClaimMapping()
+
+	username := broker.StringClaim(claims, claimMapping.Username)
+	if username == "" {
+		username = email
+	}
+	if username == "" {
+		username = fmt.Sprintf("%s-%s", identityProvider.Name(), subject)
+	}
+
+	existingFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Username(username)
+	existing, err := dbContext.Users().FirstOrNil(ctx, existingFilter)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting user by username: %w", err)
+	}
+	if existing != nil {
+		return uuid.Nil, fmt.Errorf("username %q is already taken: %w", username, utils.ErrHttpConflict)
+	}
+
+	displayName := broker.StringClaim(claims, claimMapping.DisplayName)
+	if displayName == "" {
+		displayName = username
+	}
+
+	// The login flow is pre-authentication; the verified upstream claims are
+	// what authorises creating the account.
+	sysCtx := authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.CreateUserResponse](sysCtx, m, commands.CreateUser{
+		VirtualServerName: virtualServer.Name(),
+		Username:          username,
+		DisplayName:       displayName,
+		Email:             email,
+		EmailVerified:     emailVerified,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating user: %w", err)
+	}
+
+	return response.Id, nil
+}
+
+func newBrokerProvider(ctx context.Context, vsName string, identityProvider *repositories.IdentityProvider) (*broker.Provider, error) {
+	endpoints := broker.Endpoints{
+		AuthorizationUrl: utils.ZeroIfNil(identityProvider.AuthorizationUrl()),
+		TokenUrl:         utils.ZeroIfNil(identityProvider.TokenUrl()),
+		UserinfoUrl:      utils.ZeroIfNil(identityProvider.UserinfoUrl()),
+	}
+
+	httpClient := &http.Client{Timeout: identityProviderRequestTimeout}
+
+	// plain OAuth2 providers have no discovery document and must configure
+	// their endpoints explicitly
+	if endpoints.AuthorizationUrl == "" || endpoints.TokenUrl == "" {
+		discovered, err := broker.Discover(ctx, httpClient, identityProvider.Issuer())
+		if err != nil {
+			return nil, fmt.Errorf("discovering identity provider %q: %w", identityProvider.Name(), err)
+		}
+
+		endpoints.AuthorizationUrl = cmp.Or(endpoints.AuthorizationUrl, discovered.AuthorizationUrl)
+		endpoints.TokenUrl = cmp.Or(endpoints.TokenUrl, discovered.TokenUrl)
+		endpoints.UserinfoUrl = cmp.Or(endpoints.UserinfoUrl, discovered.UserinfoUrl)
+	}
+
+	return &broker.Provider{
+		Issuer:       identityProvider.Issuer(),
+		ClientId:     identityProvider.ClientId(),
+		ClientSecret: identityProvider.ClientSecret(),
+		Scopes:       identityProvider.Scopes(),
+		RedirectUrl:  identityProviderRedirectUri(vsName, identityProvider.Name()),
+		Endpoints:    endpoints,
+		HttpClient:   httpClient,
+		// a local upstream in development usually has no certificate
+		AllowInsecureTokenUrl: !config.IsProduction(),
+	}, nil
+}
+
+func identityProviderRedirectUri(vsName string, identityProviderName string) string {
+	return fmt.Sprintf("%s/oidc/%s/identity-providers/%s/callback", config.C.Server.ExternalUrl, vsName, identityProviderName)
+}
+
+func identityProviderLoginStateKey(state string) string {
+	return "identity_provider_login:" + state
+}
+
+func identityProviderStateCookieName(vsName string) string {
+	return fmt.Sprintf("keylineIdpState_%s", vsName)
+}
+
+func setIdentityProviderStateCookie(w http.ResponseWriter, vsName string, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     identityProviderStateCookieName(vsName),
+		Value:    value,
+		Path:     fmt.Sprintf("/oidc/%s/identity-providers/", vsName),
+		Domain:   config.C.Server.ExternalDomain,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func identityProviderScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return []string{"openid", "profile", "email"}
+	}
+	return scopes
+}
+
+func mapIdentityProviderClaimMappingDto(dto api.IdentityProviderClaimMappingDto) repositories.IdentityProviderClaimMapping {
+	return repositories.IdentityProviderClaimMapping{
+		Subject:       dto.Subject,
+		Username:      dto.Username,
+		Email:         dto.Email,
+		EmailVerified: dto.EmailVerified,
+		DisplayName:   dto.DisplayName,
+	}
+}
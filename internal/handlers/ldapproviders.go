@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/federation"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"net/http"
+	"time"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ListLdapProviders
+// @summary     List LDAP providers
+// @description Retrieve all LDAP/Active Directory user storage providers of a virtual server.
+// @tags        LDAP providers
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @success     200 {object} api.ListLdapProvidersResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers [get]
+func ListLdapProviders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	ldapProviders, err := mediatr.Send[*queries.ListLdapProvidersResponse](ctx, m, queries.ListLdapProviders{
+		VirtualServerName: vsName,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	response := api.ListLdapProvidersResponseDto{
+		Items: make([]api.ListLdapProvidersResponseItemDto, 0, len(ldapProviders.Items)),
+	}
+	for _, item := range ldapProviders.Items {
+		response.Items = append(response.Items, api.ListLdapProvidersResponseItemDto{
+			Id:            item.Id,
+			Name:          item.Name,
+			Vendor:        string(item.Vendor),
+			ConnectionUrl: item.ConnectionUrl,
+			EditMode:      string(item.EditMode),
+			LastSyncAt:    item.LastSyncAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// CreateLdapProvider
+// @summary     Create LDAP provider
+// @description Register an LDAP/Active Directory server whose users can sign in with their directory password.
+// @tags        LDAP providers
+// @accept      application/json
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       body  body   api.CreateLdapProviderRequestDto  true  "LDAP provider details"
+// @success     201 {object} api.CreateLdapProviderResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     409  {string}  string "Conflict"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers [post]
+func CreateLdapProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.CreateLdapProviderRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*commands.CreateLdapProviderResponse](ctx, m, commands.CreateLdapProvider{
+		VirtualServerName: vsName,
+		Name:              dto.Name,
+		Vendor:            repositories.LdapVendor(dto.Vendor),
+		ConnectionUrl:     dto.ConnectionUrl,
+		StartTls:          dto.StartTls,
+		BindDn:            dto.BindDn,
+		BindPassword:      dto.BindPassword,
+		UsersDn:           dto.UsersDn,
+		UserFilter:        dto.UserFilter,
+		GroupsDn:          dto.GroupsDn,
+		GroupFilter:       dto.GroupFilter,
+		AttributeMapping:  mapLdapAttributeMappingDto(utils.ZeroIfNil(dto.AttributeMapping)),
+		EditMode:          repositories.LdapEditMode(dto.EditMode),
+		SyncInterval:      time.Duration(dto.SyncIntervalSeconds) * time.Second,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(api.CreateLdapProviderResponseDto{
+		Id: response.Id,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// GetLdapProvider
+// @summary     Get LDAP provider
+// @description Retrieve an LDAP provider. The bind password is never returned.
+// @tags        LDAP providers
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       ldapProviderId  path   string  true  "LDAP provider ID (UUID)"
+// @success     200 {object} api.GetLdapProviderResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers/{ldapProviderId} [get]
+func GetLdapProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	ldapProviderId, err := uuid.Parse(mux.Vars(r)["ldapProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	ldapProvider, err := mediatr.Send[*queries.GetLdapProviderResponse](ctx, m, queries.GetLdapProvider{
+		VirtualServerName: vsName,
+		LdapProviderId:    ldapProviderId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	mapping := ldapProvider.AttributeMapping
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(api.GetLdapProviderResponseDto{
+		Id:            ldapProvider.Id,
+		Name:          ldapProvider.Name,
+		Vendor:        string(ldapProvider.Vendor),
+		ConnectionUrl: ldapProvider.ConnectionUrl,
+		StartTls:      ldapProvider.StartTls,
+		BindDn:        ldapProvider.BindDn,
+		UsersDn:       ldapProvider.UsersDn,
+		UserFilter:    ldapProvider.UserFilter,
+		GroupsDn:      ldapProvider.GroupsDn,
+		GroupFilter:   ldapProvider.GroupFilter,
+		AttributeMapping: api.LdapAttributeMappingDto{
+			ExternalId:  mapping.ExternalId,
+			Username:    mapping.Username,
+			Email:       mapping.Email,
+			DisplayName: mapping.DisplayName,
+			GroupName:   mapping.GroupName,
+			GroupMember: mapping.GroupMember,
+		},
+		EditMode:            string(ldapProvider.EditMode),
+		SyncIntervalSeconds: int64(ldapProvider.SyncInterval.Seconds()),
+		LastSyncAt:          ldapProvider.LastSyncAt,
+		CreatedAt:           ldapProvider.CreatedAt,
+		UpdatedAt:           ldapProvider.UpdatedAt,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// PatchLdapProvider
+// @summary     Patch LDAP provider
+// @description Update an LDAP provider. An empty groupsDn turns off group synchronization.
+// @tags        LDAP providers
+// @accept      application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       ldapProviderId  path   string  true  "LDAP provider ID (UUID)"
+// @param       body  body   api.PatchLdapProviderRequestDto  true  "LDAP provider details"
+// @success     204 "No Content"
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers/{ldapProviderId} [patch]
+func PatchLdapProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	ldapProviderId, err := uuid.Parse(mux.Vars(r)["ldapProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	var dto api.PatchLdapProviderRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var attributeMapping *repositories.LdapAttributeMapping
+	if dto.AttributeMapping != nil {
+		attributeMapping = utils.Ptr(mapLdapAttributeMappingDto(*dto.AttributeMapping))
+	}
+
+	var editMode *repositories.LdapEditMode
+	if dto.EditMode != nil {
+		editMode = utils.Ptr(repositories.LdapEditMode(*dto.EditMode))
+	}
+
+	var syncInterval *time.Duration
+	if dto.SyncIntervalSeconds != nil {
+		syncInterval = utils.Ptr(time.Duration(*dto.SyncIntervalSeconds) * time.Second)
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.PatchLdapProviderResponse](ctx, m, commands.PatchLdapProvider{
+		VirtualServerName: vsName,
+		LdapProviderId:    ldapProviderId,
+		ConnectionUrl:     dto.ConnectionUrl,
+		StartTls:          dto.StartTls,
+		BindDn:            dto.BindDn,
+		BindPassword:      dto.BindPassword,
+		UsersDn:           dto.UsersDn,
+		UserFilter:        dto.UserFilter,
+		GroupsDn:          dto.GroupsDn,
+		GroupFilter:       dto.GroupFilter,
+		AttributeMapping:  attributeMapping,
+		EditMode:          editMode,
+		SyncInterval:      syncInterval,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteLdapProvider
+// @summary     Delete LDAP provider
+// @description Delete an LDAP provider. Imported users are kept but can no longer sign in with their directory password.
+// @tags        LDAP providers
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       ldapProviderId  path   string  true  "LDAP provider ID (UUID)"
+// @success     204 "No Content"
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers/{ldapProviderId} [delete]
+func DeleteLdapProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	ldapProviderId, err := uuid.Parse(mux.Vars(r)["ldapProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.DeleteLdapProviderResponse](ctx, m, commands.DeleteLdapProvider{
+		VirtualServerName: vsName,
+		LdapProviderId:    ldapProviderId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SyncLdapProvider
+// @summary     Synchronize LDAP provider
+// @description Import the users, groups and group memberships of the directory right away instead of waiting for the next scheduled sync.
+// @tags        LDAP providers
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       ldapProviderId  path   string  true  "LDAP provider ID (UUID)"
+// @success     200 {object} api.SyncLdapProviderResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/ldap-providers/{ldapProviderId}/sync [post]
+func SyncLdapProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	ldapProviderId, err := uuid.Parse(mux.Vars(r)["ldapProviderId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*commands.SyncLdapProviderResponse](ctx, m, commands.SyncLdapProvider{
+		VirtualServerName: vsName,
+		LdapProviderId:    ldapProviderId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(api.SyncLdapProviderResponseDto{
+		UsersCreated:       response.UsersCreated,
+		UsersUpdated:       response.UsersUpdated,
+		UsersSkipped:       response.UsersSkipped,
+		GroupsCreated:      response.GroupsCreated,
+		MembershipsAdded:   response.MembershipsAdded,
+		MembershipsRemoved: response.MembershipsRemoved,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// verifyLdapPassword checks the password of a directory user with a bind
+// against the provider the user was imported from.
+func verifyLdapPassword(
+	ctx context.Context,
+	dbContext database.Context,
+	link *repositories.Credential,
+	password string,
+) (bool, error) {
+	details, err := link.LdapDetails()
+	if err != nil {
+		return false, fmt.Errorf("getting ldap link details: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().Id(details.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return false, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	client, err := federation.Dial(federation.ConfigFromProvider(ldapProvider))
+	if err != nil {
+		return false, fmt.Errorf("connecting to ldap provider %q: %w", ldapProvider.Name(), err)
+	}
+	defer client.Close()
+
+	err = client.Authenticate(details.Dn, password)
+	if errors.Is(err, federation.ErrInvalidCredentials) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("authenticating against ldap provider %q: %w", ldapProvider.Name(), err)
+	}
+
+	return true, nil
+}
+
+// authenticateNewLdapUser looks for a user Keyline does not know yet in the
+// directories of the virtual server. If one of them accepts the password
+// the user is imported and returned, otherwise nil is returned.
+func authenticateNewLdapUser(
+	ctx context.Context,
+	dbContext database.Context,
+	virtualServerId uuid.UUID,
+	username string,
+	password string,
+) (*repositories.User, error) {
+	ldapProviderFilter := repositories.NewLdapProviderFilter().VirtualServerId(virtualServerId)
+	ldapProviders, err := dbContext.LdapProviders().List(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing ldap providers: %w", err)
+	}
+
+	for _, ldapProvider := range ldapProviders {
+		directoryUser, err := findLdapUser(ldapProvider, username, password)
+		if err != nil {
+			// one unreachable directory must not lock out the users of the others
+			logging.Logger.Errorf("looking up %q in ldap provider %q: %v", username, ldapProvider.Name(), err)
+			continue
+		}
+		if directoryUser == nil {
+			continue
+		}
+
+		virtualServerFilter := repositories.NewVirtualServerFilter().Id(virtualServerId)
+		virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+		if err != nil {
+			return nil, fmt.Errorf("getting virtual server: %w", err)
+		}
+
+		// The login flow is pre-authentication; the successful bind is what
+		// authorises importing the account.
+		sysCtx := authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+		m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+		response, err := mediatr.Send[*commands.ImportLdapUserResponse](sysCtx, m, commands.ImportLdapUser{
+			VirtualServerName: virtualServer.Name(),
+			LdapProviderId:    ldapProvider.Id(),
+			User:              *directoryUser,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("importing ldap user: %w", err)
+		}
+
+		userFilter := repositories.NewUserFilter().Id(response.UserId)
+		user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+		if err != nil {
+			return nil, fmt.Errorf("getting imported user: %w", err)
+		}
+
+		return user, nil
+	}
+
+	return nil, nil
+}
+
+// findLdapUser returns the directory user with the given username if the
+// password is correct, or nil if the user does not exist there or the
+// password is wrong.
+func findLdapUser(ldapProvider *repositories.LdapProvider, username string, password string) (*federation.User, error) {
+	client, err := federation.Dial(federation.ConfigFromProvider(ldapProvider))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	directoryUser, err := client.FindUser(username)
+	if errors.Is(err, federation.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Authenticate(directoryUser.Dn, password)
+	if errors.Is(err, federation.ErrInvalidCredentials) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return directoryUser, nil
+}
+
+func mapLdapAttributeMappingDto(dto api.LdapAttributeMappingDto) repositories.LdapAttributeMapping {
+	return repositories.LdapAttributeMapping{
+		ExternalId:  dto.ExternalId,
+		Username:    dto.Username,
+		Email:       dto.Email,
+		DisplayName: dto.DisplayName,
+		GroupName:   dto.GroupName,
+		GroupMember: dto.GroupMember,
+	}
+}
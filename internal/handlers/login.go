@@ -8,9 +8,11 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/x509"
 	"encoding/base32"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,13 +23,17 @@ import (
 	"github.com/The127/Keyline/internal/jsonTypes"
 	"github.com/The127/Keyline/internal/messages"
 	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/password"
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/services"
 	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/internal/webauthn"
 	"github.com/The127/Keyline/templates"
 	"github.com/The127/Keyline/utils"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/The127/go-clock"
@@ -90,10 +96,6 @@ func DetermineNextLoginStep(
 	ctx context.Context,
 	loginInfo *jsonTypes.LoginInfo,
 ) (jsonTypes.LoginStep, error) {
-	if loginInfo.Step == jsonTypes.LoginStepPasskey {
-		return jsonTypes.LoginStepFinish, nil
-	}
-
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
@@ -109,25 +111,88 @@ func DetermineNextLoginStep(
 		return "", err
 	}
 
-	passwordFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypePassword)
-	passwordCredential, err := dbContext.Credentials().FirstOrNil(ctx, passwordFilter)
+	// the MFA rules apply to every kind of login, so they are evaluated
+	// before any step can finish it
+	mfaAction, _, err := evaluateMfaRules(ctx, dbContext, loginInfo, virtualServer, user)
 	if err != nil {
 		return "", err
 	}
-	passwordDetails, err := passwordCredential.PasswordDetails()
+
+	// passkeys authenticate the user on their own, so no further local
+	// factors are asked for unless the requested acr or an MFA rule needs
+	// more
+	if loginInfo.Step == jsonTypes.LoginStepPasskey {
+		if len(loginInfo.MissingAuthenticationMethods()) == 0 && mfaAction != repositories.MfaRuleActionRequire {
+			return jsonTypes.LoginStepFinish, nil
+		}
+		loginInfo.Step = jsonTypes.LoginStepStepUp
+	}
+
+	passwordFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypePassword)
+	passwordCredential, err := dbContext.Credentials().FirstOrNil(ctx, passwordFilter)
 	if err != nil {
 		return "", err
 	}
 
+	// directory users have no local password credential
+	temporaryPassword := false
+	if passwordCredential != nil {
+		passwordDetails, err := passwordCredential.PasswordDetails()
+		if err != nil {
+			return "", err
+		}
+		temporaryPassword = passwordDetails.Temporary
+
+		// expired passwords are changed like temporary ones right after
+		// they were verified
+		if !temporaryPassword && loginInfo.Step == jsonTypes.LoginStepPasswordVerification {
+			passwordValidator := ioc.GetDependency[password.Validator](scope)
+			expired, err := passwordValidator.IsExpired(ctx, user, passwordCredential)
+			if err != nil {
+				return "", err
+			}
+			loginInfo.PasswordExpired = expired
+			temporaryPassword = expired
+		}
+	}
+
 	totpFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypeTotp)
 	totpCredentials, err := dbContext.Credentials().List(ctx, totpFilter)
 	if err != nil {
 		return "", err
 	}
 
+	passkeyFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypeWebauthn)
+	passkeyCredentials, err := dbContext.Credentials().List(ctx, passkeyFilter)
+	if err != nil {
+		return "", err
+	}
+
+	phoneFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypePhone)
+	phoneCredentials, err := dbContext.Credentials().List(ctx, phoneFilter)
+	if err != nil {
+		return "", err
+	}
+
+	var secondFactors []jsonTypes.SecondFactor
+	if len(totpCredentials) > 0 {
+		secondFactors = append(secondFactors, jsonTypes.SecondFactorTotp)
+	}
+	if len(passkeyCredentials) > 0 {
+		secondFactors = append(secondFactors, jsonTypes.SecondFactorPasskey)
+	}
+	// phone numbers are kept if sms is turned off, they only can't be used
+	smsProvider := ioc.GetDependency[services.SmsProvider](scope)
+	if len(phoneCredentials) > 0 && smsProvider.Enabled() {
+		secondFactors = append(secondFactors, jsonTypes.SecondFactorSms)
+	}
+
 	switch loginInfo.Step {
+	case jsonTypes.LoginStepStepUp:
+		return nextSecondFactorLoginStep(ctx, dbContext, loginInfo, virtualServer, user, secondFactors, mfaAction)
+
 	case jsonTypes.LoginStepPasswordVerification:
-		if passwordDetails.Temporary {
+		if temporaryPassword {
 			return jsonTypes.LoginStepTemporaryPassword, nil
 		}
 		fallthrough
@@ -138,24 +203,198 @@ func DetermineNextLoginStep(
 		}
 		fallthrough
 
-	case jsonTypes.LoginStepEmailVerification:
-		if len(totpCredentials) > 0 {
-			return jsonTypes.LoginStepVerifyTotp, nil
+	// the email login already proved that the user can read mails sent to
+	// their primary email and brokered users were verified upstream, so
+	// both skip the email verification
+	case jsonTypes.LoginStepEmailVerification, jsonTypes.LoginStepEmailLogin, jsonTypes.LoginStepIdentityProvider:
+		return nextSecondFactorLoginStep(ctx, dbContext, loginInfo, virtualServer, user, secondFactors, mfaAction)
+
+	case jsonTypes.LoginStepSelectSecondFactor:
+		if !slices.Contains(loginInfo.SecondFactors, loginInfo.SecondFactor) {
+			return "", fmt.Errorf("second factor %q is not available: %w", loginInfo.SecondFactor, utils.ErrHttpBadRequest)
+		}
+		return secondFactorLoginStep(loginInfo.SecondFactor)
+
+	case jsonTypes.LoginStepOnboardTotp, jsonTypes.LoginStepVerifyTotp, jsonTypes.LoginStepVerifyPasskey, jsonTypes.LoginStepVerifySms:
+		// an acr can require more than one second factor
+		if len(loginInfo.MissingAuthenticationMethods()) > 0 {
+			return nextSecondFactorLoginStep(ctx, dbContext, loginInfo, virtualServer, user, secondFactors, mfaAction)
+		}
+		return jsonTypes.LoginStepFinish, nil
+
+	default:
+		return "", errors.New("invalid login step")
+	}
+}
+
+// isTrustedDevice tells whether the login comes from a browser the user
+// trusts and can skip the second factor. A requested acr is never reached
+// through a trusted device.
+func isTrustedDevice(ctx context.Context, dbContext database.Context, loginInfo *jsonTypes.LoginInfo) (bool, error) {
+	if loginInfo.TrustedDeviceId == uuid.Nil || len(loginInfo.MissingAuthenticationMethods()) > 0 {
+		return false, nil
+	}
+
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	credential, _, err := getTrustedDevice(ctx, dbContext, loginInfo.UserId, loginInfo.TrustedDeviceId, clockService.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return credential != nil, nil
+}
+
+// nextSecondFactorLoginStep returns the step that asks for a second factor,
+// or finishes the login if none is needed. If the requested acr is not
+// reached yet only the factors that complete a missing method are offered.
+// Otherwise the action of the matching MFA rule and trusted devices decide
+// whether the second factor is asked for.
+func nextSecondFactorLoginStep(
+	ctx context.Context,
+	dbContext database.Context,
+	loginInfo *jsonTypes.LoginInfo,
+	virtualServer *repositories.VirtualServer,
+	user *repositories.User,
+	secondFactors []jsonTypes.SecondFactor,
+	mfaAction repositories.MfaRuleAction,
+) (jsonTypes.LoginStep, error) {
+	missing := loginInfo.MissingAuthenticationMethods()
+
+	// logins that did not start with the password ask for it first,
+	// VerifyPassword only accepts the user the login is for
+	if slices.Contains(missing, repositories.AuthenticationMethodPassword) {
+		return jsonTypes.LoginStepPasswordVerification, nil
+	}
+
+	// an admin reset of the second factors is not skipped
+	if len(missing) == 0 && !user.Require2faOnboarding() {
+		if mfaAction == repositories.MfaRuleActionSkip {
+			return jsonTypes.LoginStepFinish, nil
+		}
+
+		if mfaAction != repositories.MfaRuleActionRequire {
+			// an upstream identity provider that reported more than one
+			// factor already did what a local second factor would, unless
+			// a rule asks for a local one
+			if loginInfo.UpstreamMultiFactor {
+				return jsonTypes.LoginStepFinish, nil
+			}
+
+			trusted, err := isTrustedDevice(ctx, dbContext, loginInfo)
+			if err != nil {
+				return "", err
+			}
+			if trusted {
+				return jsonTypes.LoginStepFinish, nil
+			}
 		}
-		if virtualServer.Require2fa() {
+	}
+
+	require2fa := mfaAction == repositories.MfaRuleActionRequire ||
+		virtualServer.Require2fa() && mfaAction != repositories.MfaRuleActionSkip
+
+	if len(missing) > 0 {
+		secondFactors = slices.DeleteFunc(slices.Clone(secondFactors), func(secondFactor jsonTypes.SecondFactor) bool {
+			return !completesMissingAuthenticationMethod(secondFactor, loginInfo.AuthenticationMethods, missing)
+		})
+	} else {
+		// a passkey login that an MFA rule asks a second factor of does
+		// not offer the passkey again
+		secondFactors = slices.DeleteFunc(slices.Clone(secondFactors), func(secondFactor jsonTypes.SecondFactor) bool {
+			return secondFactor == jsonTypes.SecondFactorPasskey &&
+				slices.ContainsFunc(secondFactorAuthenticationMethods(secondFactor), func(method repositories.AuthenticationMethod) bool {
+					return slices.Contains(loginInfo.AuthenticationMethods, method)
+				})
+		})
+	}
+
+	loginInfo.SecondFactors = secondFactors
+	switch len(secondFactors) {
+	case 0:
+		// users whose second factors were reset by an admin onboard a
+		// new one even if the virtual server does not require 2fa
+		if require2fa || user.Require2faOnboarding() || onboardingTotpCompletes(loginInfo.AuthenticationMethods, missing) {
 			loginInfo.TotpSecret = base32.StdEncoding.EncodeToString(utils.GetSecureRandomBytes(32))
 			return jsonTypes.LoginStepOnboardTotp, nil
 		}
+		if len(missing) > 0 {
+			return "", fmt.Errorf("user cannot complete the requested acr: %w", utils.ErrHttpUnauthorized)
+		}
 		return jsonTypes.LoginStepFinish, nil
 
-	case jsonTypes.LoginStepOnboardTotp:
-		fallthrough
+	case 1:
+		loginInfo.SecondFactor = secondFactors[0]
+		return secondFactorLoginStep(loginInfo.SecondFactor)
 
-	case jsonTypes.LoginStepVerifyTotp:
-		return jsonTypes.LoginStepFinish, nil
+	default:
+		return jsonTypes.LoginStepSelectSecondFactor, nil
+	}
+}
+
+// secondFactorAuthenticationMethods returns the methods a factor can
+// complete. Passkeys count as hardware keys if they come with an
+// attestation, which is only known once one is used.
+func secondFactorAuthenticationMethods(secondFactor jsonTypes.SecondFactor) []repositories.AuthenticationMethod {
+	switch secondFactor {
+	case jsonTypes.SecondFactorTotp:
+		return []repositories.AuthenticationMethod{repositories.AuthenticationMethodOtp}
+
+	case jsonTypes.SecondFactorPasskey:
+		return []repositories.AuthenticationMethod{repositories.AuthenticationMethodHardwareKey, repositories.AuthenticationMethodSoftwareKey}
+
+	case jsonTypes.SecondFactorSms:
+		return []repositories.AuthenticationMethod{repositories.AuthenticationMethodSms}
 
 	default:
-		return "", errors.New("invalid login step")
+		return nil
+	}
+}
+
+// completesMissingAuthenticationMethod tells whether verifying the factor
+// completes one of the missing methods. A missing "mfa" is completed by
+// any method that was not completed yet.
+func completesMissingAuthenticationMethod(
+	secondFactor jsonTypes.SecondFactor,
+	completed []repositories.AuthenticationMethod,
+	missing []repositories.AuthenticationMethod,
+) bool {
+	for _, method := range secondFactorAuthenticationMethods(secondFactor) {
+		if slices.Contains(missing, method) {
+			return true
+		}
+		if slices.Contains(missing, repositories.AuthenticationMethodMultiFactor) && !slices.Contains(completed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// onboardingTotpCompletes tells whether a user without a usable factor can
+// reach the requested acr by onboarding TOTP.
+func onboardingTotpCompletes(completed []repositories.AuthenticationMethod, missing []repositories.AuthenticationMethod) bool {
+	if slices.Contains(missing, repositories.AuthenticationMethodOtp) {
+		return true
+	}
+	return slices.Contains(missing, repositories.AuthenticationMethodMultiFactor) &&
+		!slices.Contains(completed, repositories.AuthenticationMethodOtp)
+}
+
+// secondFactorLoginStep returns the step that verifies the given factor.
+func secondFactorLoginStep(secondFactor jsonTypes.SecondFactor) (jsonTypes.LoginStep, error) {
+	switch secondFactor {
+	case jsonTypes.SecondFactorTotp:
+		return jsonTypes.LoginStepVerifyTotp, nil
+
+	case jsonTypes.SecondFactorPasskey:
+		return jsonTypes.LoginStepVerifyPasskey, nil
+
+	case jsonTypes.SecondFactorSms:
+		return jsonTypes.LoginStepVerifySms, nil
+
+	default:
+		return "", fmt.Errorf("unknown second factor %q: %w", secondFactor, utils.ErrHttpBadRequest)
 	}
 }
 
@@ -167,6 +406,25 @@ type GetLoginStateResponseDto struct {
 	VirtualServerName        string `json:"virtualServerName"`
 	SignupEnabled            bool   `json:"signupEnabled"`
 	TotpSecret               string `json:"totpSecret"`
+	// EmailLoginEnabled tells whether the user can ask for a link or code
+	// by email instead of entering their password
+	EmailLoginEnabled bool `json:"emailLoginEnabled"`
+	// TrustDeviceEnabled tells whether the verifyTotp step can offer to
+	// trust the browser
+	TrustDeviceEnabled bool `json:"trustDeviceEnabled"`
+	// PasswordExpired tells whether the temporaryPassword step asks for a
+	// new password because the current one is too old
+	PasswordExpired bool `json:"passwordExpired"`
+	// SecondFactors are the factors the user can pick from in the
+	// selectSecondFactor step: totp | passkey | sms
+	SecondFactors []string `json:"secondFactors"`
+	// IdentityProviders are the upstream providers the user can sign in with instead
+	IdentityProviders []GetLoginStateIdentityProviderDto `json:"identityProviders"`
+}
+
+type GetLoginStateIdentityProviderDto struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
 }
 
 // GetLoginState returns the current step of the login session.
@@ -210,7 +468,29 @@ func GetLoginState(w http.ResponseWriter, r *http.Request) {
 		VirtualServerDisplayName: loginInfo.VirtualServerDisplayName,
 		VirtualServerName:        loginInfo.VirtualServerName,
 		SignupEnabled:            loginInfo.RegistrationEnabled,
+		EmailLoginEnabled:        loginInfo.EmailLoginEnabled,
+		TrustDeviceEnabled:       loginInfo.TrustDeviceEnabled,
+		PasswordExpired:          loginInfo.PasswordExpired,
 		TotpSecret:               loginInfo.TotpSecret,
+		SecondFactors:            []string{},
+		IdentityProviders:        []GetLoginStateIdentityProviderDto{},
+	}
+	for _, secondFactor := range loginInfo.SecondFactors {
+		response.SecondFactors = append(response.SecondFactors, string(secondFactor))
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+	identityProviderFilter := repositories.NewIdentityProviderFilter().VirtualServerId(loginInfo.VirtualServerId)
+	identityProviders, err := dbContext.IdentityProviders().List(ctx, identityProviderFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	for _, identityProvider := range identityProviders {
+		response.IdentityProviders = append(response.IdentityProviders, GetLoginStateIdentityProviderDto{
+			Name:        identityProvider.Name(),
+			DisplayName: identityProvider.DisplayName(),
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -287,13 +567,50 @@ func VerifyPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, ok, err := verifyPasswordCredential(ctx, dbContext, loginInfo.VirtualServerId, dto.Username, dto.Password)
+	virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting virtual server: %w", err))
+		return
+	}
+	lockoutPolicy := virtualServer.LockoutPolicy()
+
+	// the per-loginToken cap below is reset by starting a new login, so
+	// failed attempts are counted per user and per source IP as well
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	clientIp := utils.ClientIp(r, config.C.Server.TrustedProxies)
+	err = lockoutService.CheckIp(ctx, loginInfo.VirtualServerId, clientIp)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// the password of a locked user is not verified at all, and the
+	// attempt fails like one for an unknown username so that it does not
+	// tell whether the account exists
+	locked, err := isUsernameLocked(ctx, dbContext, loginInfo.VirtualServerId, dto.Username)
 	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("verifying password: %w", err))
+		utils.HandleHttpError(w, err)
 		return
 	}
 
+	var user *repositories.User
+	ok := false
+	if !locked {
+		user, ok, err = verifyPasswordCredential(ctx, dbContext, loginInfo.VirtualServerId, dto.Username, dto.Password)
+		if err != nil {
+			utils.HandleHttpError(w, fmt.Errorf("verifying password: %w", err))
+			return
+		}
+	}
+
 	if !ok {
+		err = recordFailedLoginAttempt(ctx, lockoutPolicy, loginInfo.VirtualServerId, user, clientIp)
+		if err != nil {
+			utils.HandleHttpError(w, err)
+			return
+		}
+
 		loginInfo.FailedPasswordAttempts++
 		if loginInfo.FailedPasswordAttempts >= MaxFailedPasswordAttempts {
 			// Drop the loginToken entirely so the attacker cannot keep
@@ -318,9 +635,26 @@ func VerifyPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a step-up or a passkey login already knows the user, the password
+	// of another user must not take it over
+	if loginInfo.UserId != uuid.Nil && loginInfo.UserId != user.Id() {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+	}
+
+	trustedDeviceId, err := trustedDeviceOfRequest(ctx, r, virtualServer, user.Id())
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	err = updateLoginStep(ctx, loginToken, func(info *jsonTypes.LoginInfo) error {
 		info.UserId = user.Id()
 		info.FailedPasswordAttempts = 0
+		info.TrustedDeviceId = trustedDeviceId
+		info.ClientIp = clientIp
+		info.UserAgent = r.UserAgent()
+		info.AddAuthenticationMethod(repositories.AuthenticationMethodPassword)
 		return nil
 	})
 	if err != nil {
@@ -331,16 +665,110 @@ func VerifyPassword(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// isUsernameLocked tells whether the user with the given username exists and
+// is locked out.
+func isUsernameLocked(ctx context.Context, dbContext database.Context, virtualServerId uuid.UUID, username string) (bool, error) {
+	userFilter := repositories.NewUserFilter().VirtualServerId(virtualServerId).Username(username)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	if err != nil {
+		return false, fmt.Errorf("getting user: %w", err)
+	}
+	if user == nil {
+		return false, nil
+	}
+
+	scope := middlewares.GetScope(ctx)
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	err = lockoutService.CheckUser(ctx, user.Id())
+	switch {
+	case errors.Is(err, services.ErrAccountLocked):
+		return true, nil
+
+	case err != nil:
+		return false, fmt.Errorf("checking lockout: %w", err)
+	}
+
+	return false, nil
+}
+
+// recordFailedLoginAttempt counts a failed attempt against the lockout of
+// the source IP and, if known, the user, and tells the user if it locked
+// their account.
+func recordFailedLoginAttempt(
+	ctx context.Context,
+	lockoutPolicy repositories.LockoutPolicy,
+	virtualServerId uuid.UUID,
+	user *repositories.User,
+	clientIp string,
+) error {
+	scope := middlewares.GetScope(ctx)
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+
+	userId := uuid.Nil
+	if user != nil {
+		userId = user.Id()
+	}
+	lockoutState, locked, err := lockoutService.RecordFailure(ctx, lockoutPolicy, virtualServerId, userId, clientIp)
+	if err != nil {
+		return fmt.Errorf("recording failed attempt: %w", err)
+	}
+
+	if locked && lockoutPolicy.NotifyUser {
+		return queueAccountLockedMail(ctx, user, lockoutState)
+	}
+	return nil
+}
+
+// queueAccountLockedMail tells the user that their account was locked, so
+// that they notice someone guessing their password.
+func queueAccountLockedMail(ctx context.Context, user *repositories.User, lockoutState *services.LockoutState) error {
+	scope := middlewares.GetScope(ctx)
+
+	data := templates.AccountLockedTemplateData{
+		Permanent: lockoutState.Permanent,
+	}
+	if lockoutState.LockedUntil != nil {
+		data.LockedUntil = lockoutState.LockedUntil.UTC().Format(time.RFC1123)
+	}
+
+	templateService := ioc.GetDependency[services.TemplateService](scope)
+	mailBody, err := templateService.Template(ctx, user.VirtualServerId(), repositories.AccountLockedMailTemplate, data)
+	if err != nil {
+		return fmt.Errorf("templating account locked mail: %w", err)
+	}
+
+	message := &messages.SendEmailMessage{
+		VirtualServerId: user.VirtualServerId(),
+		To:              user.PrimaryEmail(),
+		Subject:         "Account locked",
+		Body:            mailBody,
+	}
+
+	outboxMessage, err := repositories.NewOutboxMessage(message)
+	if err != nil {
+		return fmt.Errorf("creating account locked outbox message: %w", err)
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+	dbContext.OutboxMessages().Insert(outboxMessage)
+	return nil
+}
+
 // verifyPasswordCredential looks up the password credential for the given
 // (virtualServerId, username) pair and compares the supplied password
 // against the stored hash. It returns (user, true, nil) only when the user
 // exists, has a password credential, and the hash compares equal.
 //
+// Directory users have no password credential; their password is checked
+// with a bind against their LDAP provider instead. A username Keyline does
+// not know yet is looked up in the virtual server's LDAP providers and
+// imported on a successful bind.
+//
 // On a wrong password / unknown user / missing credential it returns
 // (user-or-nil, false, nil) -- those are not errors, they're a failed
 // authentication attempt that the caller MUST count against the
 // loginToken's failed-attempt budget. A non-nil error is reserved for
-// underlying database failures.
+// underlying database and directory failures.
 func verifyPasswordCredential(
 	ctx context.Context,
 	dbContext database.Context,
@@ -354,7 +782,14 @@ func verifyPasswordCredential(
 		return nil, false, fmt.Errorf("getting user: %w", err)
 	}
 	if user == nil {
-		return nil, false, nil
+		user, err = authenticateNewLdapUser(ctx, dbContext, virtualServerId, username, password)
+		if err != nil {
+			return nil, false, err
+		}
+		return user, user != nil, nil
+	}
+	if user.Disabled() {
+		return user, false, nil
 	}
 
 	credentialFilter := repositories.NewCredentialFilter().
@@ -365,7 +800,19 @@ func verifyPasswordCredential(
 		return user, false, fmt.Errorf("getting credential: %w", err)
 	}
 	if credential == nil {
-		return user, false, nil
+		linkFilter := repositories.NewCredentialFilter().
+			UserId(user.Id()).
+			Type(repositories.CredentialTypeLdap)
+		link, err := dbContext.Credentials().FirstOrNil(ctx, linkFilter)
+		if err != nil {
+			return user, false, fmt.Errorf("getting ldap link: %w", err)
+		}
+		if link == nil {
+			return user, false, nil
+		}
+
+		ok, err := verifyLdapPassword(ctx, dbContext, link, password)
+		return user, ok, err
 	}
 
 	passwordDetails, err := credential.PasswordDetails()
@@ -427,14 +874,21 @@ type OnboardTotpRequestDto struct {
 	TotpCode string `json:"totpCode" validate:"required"`
 }
 
-// OnboardTotp advances the login after the user has onboarded TOTP.
+type OnboardTotpResponseDto struct {
+	// RecoveryCodes can be used once each in place of a TOTP code. They
+	// are only shown once.
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// OnboardTotp advances the login after the user has onboarded TOTP and
+// returns the recovery codes for the new authenticator.
 // @Summary      Onboard TOTP (advance state)
 // @Tags         Logins
 // @Accept       json
-// @Produce      plain
+// @Produce      json
 // @Param        loginToken  path   string true  "Login session token"
 // @Param        body        body   handlers.OnboardTotpRequestDto true "TOTP code"
-// @Success      204         {string} string "No Content"
+// @Success      200         {object} handlers.OnboardTotpResponseDto
 // @Failure      400         {string} string "Bad Request"
 // @Failure      401         {string} string "Unauthorized or wrong step"
 // @Router       /logins/{loginToken}/onboard-totp [post]
@@ -457,6 +911,7 @@ func OnboardTotp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var response OnboardTotpResponseDto
 	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
 		if loginInfo.Step != jsonTypes.LoginStepOnboardTotp {
 			return utils.ErrHttpUnauthorized
@@ -477,7 +932,33 @@ func OnboardTotp(w http.ResponseWriter, r *http.Request) {
 		})
 		dbContext.Credentials().Insert(totpCredential)
 
+		userFilter := repositories.NewUserFilter().Id(loginInfo.UserId)
+		user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+		if err != nil {
+			return err
+		}
+		if user.Require2faOnboarding() {
+			user.SetRequire2faOnboarding(false)
+			dbContext.Users().Update(user)
+		}
+
+		// The login flow is pre-authentication, the login token and the
+		// LoginStepOnboardTotp guard above prove that the recovery codes
+		// are for the caller.
+		sysCtx := authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+		sysCtx = middlewares.ContextWithVirtualServerName(sysCtx, loginInfo.VirtualServerName)
+		m := ioc.GetDependency[mediatr.Mediator](scope)
+		recoveryCodes, err := mediatr.Send[*commands.RegenerateRecoveryCodesResponse](sysCtx, m, commands.RegenerateRecoveryCodes{
+			VirtualServerName: loginInfo.VirtualServerName,
+			UserId:            loginInfo.UserId,
+		})
+		if err != nil {
+			return fmt.Errorf("generating recovery codes: %w", err)
+		}
+		response.RecoveryCodes = recoveryCodes.RecoveryCodes
+
 		loginInfo.TotpSecret = ""
+		loginInfo.AddAuthenticationMethod(repositories.AuthenticationMethodOtp)
 
 		return nil
 	})
@@ -486,30 +967,39 @@ func OnboardTotp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
 }
 
-type VerifyTotpRequestDto struct {
-	TotpCode string `json:"totpCode" validate:"required"`
+type SelectSecondFactorRequestDto struct {
+	SecondFactor string `json:"secondFactor" validate:"required,oneof=totp passkey sms"`
 }
 
-// VerifyTotp advances the login after the user has verified TOTP.
-// @Summary      Verify TOTP (advance state)
+// SelectSecondFactor picks the factor a user with several second factors
+// verifies. It can also be used to switch to another factor, e.g. when the
+// security key is not at hand.
+// @Summary      Select second factor
 // @Tags         Logins
+// @Accept       json
 // @Produce      plain
 // @Param        loginToken  path   string true  "Login session token"
-// @Param        body        body   handlers.VerifyTotpRequestDto true "TOTP code"
+// @Param        body        body   handlers.SelectSecondFactorRequestDto true "Second factor"
 // @Success      204         {string} string "No Content"
-// @Failure      400         {string} string "Bad Request"
+// @Failure      400         {string} string "Bad Request or factor not set up"
 // @Failure      401         {string} string "Unauthorized or wrong step"
-// @Router       /logins/{loginToken}/verify-totp [post]
-func VerifyTotp(w http.ResponseWriter, r *http.Request) {
+// @Router       /logins/{loginToken}/select-second-factor [post]
+func SelectSecondFactor(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	vars := mux.Vars(r)
 	loginToken := vars["loginToken"]
 
-	var dto VerifyTotpRequestDto
+	var dto SelectSecondFactorRequestDto
 	err := json.NewDecoder(r.Body).Decode(&dto)
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -523,20 +1013,137 @@ func VerifyTotp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
-		if loginInfo.Step != jsonTypes.LoginStepVerifyTotp {
+		switch loginInfo.Step {
+		case jsonTypes.LoginStepSelectSecondFactor,
+			jsonTypes.LoginStepVerifyTotp,
+			jsonTypes.LoginStepVerifyPasskey,
+			jsonTypes.LoginStepVerifySms:
+		default:
 			return utils.ErrHttpUnauthorized
 		}
 
-		scope := middlewares.GetScope(ctx)
-		dbContext := ioc.GetDependency[database.Context](scope)
+		loginInfo.Step = jsonTypes.LoginStepSelectSecondFactor
+		loginInfo.SecondFactor = jsonTypes.SecondFactor(dto.SecondFactor)
+		return nil
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
 
-		totpCredentialFilter := repositories.NewCredentialFilter().
-			UserId(loginInfo.UserId).
-			Type(repositories.CredentialTypeTotp)
-		totpCredentials, err := dbContext.Credentials().List(ctx, totpCredentialFilter)
-		if err != nil {
-			return fmt.Errorf("failed to get totp credentials: %w", err)
-		}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type VerifyTotpRequestDto struct {
+	// RecoveryCode can be sent in place of TotpCode if the authenticator
+	// is lost, each recovery code works once.
+	TotpCode     string `json:"totpCode" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=TotpCode"`
+	// TrustDevice skips the second factor in this browser for the number
+	// of days the virtual server allows. It is ignored if the virtual
+	// server does not allow trusting browsers.
+	TrustDevice bool `json:"trustDevice"`
+}
+
+// VerifyTotp advances the login after the user has verified TOTP or used a
+// recovery code, and trusts the browser if the user asked for it.
+// @Summary      Verify TOTP (advance state)
+// @Tags         Logins
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Param        body        body   handlers.VerifyTotpRequestDto true "TOTP code"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request"
+// @Failure      401         {string} string "Unauthorized or wrong step"
+// @Router       /logins/{loginToken}/verify-totp [post]
+func VerifyTotp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	var dto VerifyTotpRequestDto
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var virtualServerId, userId uuid.UUID
+	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		if loginInfo.Step != jsonTypes.LoginStepVerifyTotp {
+			return utils.ErrHttpUnauthorized
+		}
+		virtualServerId = loginInfo.VirtualServerId
+		userId = loginInfo.UserId
+
+		scope := middlewares.GetScope(ctx)
+		dbContext := ioc.GetDependency[database.Context](scope)
+
+		// wrong totp and recovery codes count against the same lockout as
+		// wrong passwords
+		lockoutService := ioc.GetDependency[services.LockoutService](scope)
+		err := lockoutService.CheckUser(ctx, loginInfo.UserId)
+		if err != nil {
+			return err
+		}
+
+		recordFailure := func() error {
+			virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+			virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+			if err != nil {
+				return fmt.Errorf("getting virtual server: %w", err)
+			}
+
+			userFilter := repositories.NewUserFilter().Id(loginInfo.UserId)
+			user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+			if err != nil {
+				return fmt.Errorf("getting user: %w", err)
+			}
+
+			clientIp := utils.ClientIp(r, config.C.Server.TrustedProxies)
+			return recordFailedLoginAttempt(ctx, virtualServer.LockoutPolicy(), loginInfo.VirtualServerId, user, clientIp)
+		}
+
+		if dto.TotpCode == "" {
+			// the caller is not signed in yet, the login token proves who
+			// they are and the command is attributed to them
+			loginCtx := authentication.ContextWithCurrentUser(ctx, authentication.NewCurrentUser(loginInfo.UserId))
+			loginCtx = middlewares.ContextWithVirtualServerName(loginCtx, loginInfo.VirtualServerName)
+			m := ioc.GetDependency[mediatr.Mediator](scope)
+			_, err := mediatr.Send[*commands.ConsumeRecoveryCodeResponse](loginCtx, m, commands.ConsumeRecoveryCode{
+				VirtualServerName: loginInfo.VirtualServerName,
+				UserId:            loginInfo.UserId,
+				RecoveryCode:      dto.RecoveryCode,
+			})
+			if errors.Is(err, utils.ErrInvalidRecoveryCode) {
+				recordErr := recordFailure()
+				if recordErr != nil {
+					return recordErr
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			// recovery codes stand in for the authenticator
+			loginInfo.AddAuthenticationMethod(repositories.AuthenticationMethodOtp)
+			return nil
+		}
+
+		totpCredentialFilter := repositories.NewCredentialFilter().
+			UserId(loginInfo.UserId).
+			Type(repositories.CredentialTypeTotp)
+		totpCredentials, err := dbContext.Credentials().List(ctx, totpCredentialFilter)
+		if err != nil {
+			return fmt.Errorf("failed to get totp credentials: %w", err)
+		}
 
 		clockService := ioc.GetDependency[clock.Service](scope)
 		now := clockService.Now()
@@ -563,9 +1170,192 @@ func VerifyTotp(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if !isValid {
+			err = recordFailure()
+			if err != nil {
+				return err
+			}
 			return fmt.Errorf("invalid totp code: %w", utils.ErrHttpBadRequest)
 		}
 
+		loginInfo.AddAuthenticationMethod(repositories.AuthenticationMethodOtp)
+		return nil
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	if dto.TrustDevice {
+		scope := middlewares.GetScope(ctx)
+		dbContext := ioc.GetDependency[database.Context](scope)
+
+		virtualServerFilter := repositories.NewVirtualServerFilter().Id(virtualServerId)
+		virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+		if err != nil {
+			utils.HandleHttpError(w, fmt.Errorf("getting virtual server: %w", err))
+			return
+		}
+
+		if virtualServer.TrustedDeviceDays() > 0 {
+			err = trustDevice(ctx, w, r, virtualServer, userId)
+			if err != nil {
+				utils.HandleHttpError(w, err)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendLoginSmsCode sends a code to the phone number of the user. Calling it
+// again sends a new code that replaces the previous one.
+// @Summary      Send SMS code
+// @Tags         Logins
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request or sms not configured"
+// @Failure      401         {string} string "Unauthorized or wrong step"
+// @Failure      429         {string} string "Too many codes were requested"
+// @Router       /logins/{loginToken}/sms/send [post]
+func SendLoginSmsCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	rawLoginInfo, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting token: %w", err))
+		return
+	}
+
+	var loginInfo jsonTypes.LoginInfo
+	if err := json.Unmarshal([]byte(rawLoginInfo), &loginInfo); err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("unmarshal login info: %w", err))
+		return
+	}
+
+	if loginInfo.Step != jsonTypes.LoginStepVerifySms {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+	}
+
+	phoneFilter := repositories.NewCredentialFilter().
+		UserId(loginInfo.UserId).
+		Type(repositories.CredentialTypePhone)
+	phoneCredential, err := dbContext.Credentials().FirstOrErr(ctx, phoneFilter)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting phone credential: %w", err))
+		return
+	}
+
+	phoneDetails, err := phoneCredential.PhoneDetails()
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = queueSmsCode(
+		ctx,
+		services.SmsLoginCodeTokenType,
+		loginToken,
+		loginInfo.VirtualServerId,
+		loginInfo.UserId,
+		phoneDetails.PhoneNumber,
+	)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type VerifySmsRequestDto struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// VerifySms advances the login after the user has entered the code that
+// was sent to their phone.
+// @Summary      Verify SMS code
+// @Tags         Logins
+// @Accept       json
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Param        body        body   handlers.VerifySmsRequestDto true "SMS code"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request"
+// @Failure      401         {string} string "Unauthorized, wrong step or invalid code"
+// @Router       /logins/{loginToken}/verify-sms [post]
+func VerifySms(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	var dto VerifySmsRequestDto
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		if loginInfo.Step != jsonTypes.LoginStepVerifySms {
+			return utils.ErrHttpUnauthorized
+		}
+
+		// wrong sms codes count against the same lockout as wrong
+		// passwords
+		scope := middlewares.GetScope(ctx)
+		lockoutService := ioc.GetDependency[services.LockoutService](scope)
+		err := lockoutService.CheckUser(ctx, loginInfo.UserId)
+		if err != nil {
+			return err
+		}
+
+		challenge, err := verifySmsCode(ctx, services.SmsLoginCodeTokenType, loginToken, dto.Code)
+		if errors.Is(err, errInvalidSmsCode) {
+			dbContext := ioc.GetDependency[database.Context](scope)
+
+			virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+			virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+			if err != nil {
+				return fmt.Errorf("getting virtual server: %w", err)
+			}
+
+			userFilter := repositories.NewUserFilter().Id(loginInfo.UserId)
+			user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+			if err != nil {
+				return fmt.Errorf("getting user: %w", err)
+			}
+
+			clientIp := utils.ClientIp(r, config.C.Server.TrustedProxies)
+			err = recordFailedLoginAttempt(ctx, virtualServer.LockoutPolicy(), loginInfo.VirtualServerId, user, clientIp)
+			if err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if challenge.UserId != loginInfo.UserId {
+			return utils.ErrHttpUnauthorized
+		}
+
+		loginInfo.AddAuthenticationMethod(repositories.AuthenticationMethodSms)
 		return nil
 	})
 	if err != nil {
@@ -611,7 +1401,25 @@ func FinishLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = middlewares.CreateSession(w, r, loginInfo.VirtualServerName, loginInfo.UserId)
+	// failed attempts are only forgotten once every factor is done, a correct
+	// password alone must not reset the count of wrong second factors
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	err = lockoutService.RecordSuccess(ctx, loginInfo.UserId)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("resetting failed attempts: %w", err))
+		return
+	}
+
+	err = rememberKnownDevice(ctx, r, &loginInfo)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	authenticationMethods := utils.MapSlice(loginInfo.AuthenticationMethods, func(method repositories.AuthenticationMethod) string {
+		return string(method)
+	})
+	err = middlewares.CreateSession(w, r, loginInfo.VirtualServerName, loginInfo.UserId, authenticationMethods)
 	if err != nil {
 		utils.HandleHttpError(w, err)
 		return
@@ -639,6 +1447,7 @@ func FinishLogin(w http.ResponseWriter, r *http.Request) {
 		userIdStr := loginInfo.UserId
 		deviceCodeInfo.Status = string(jsonTypes.DeviceCodeStatusAuthorized)
 		deviceCodeInfo.UserId = userIdStr.String()
+		deviceCodeInfo.AuthenticationMethods = loginInfo.AuthenticationMethods
 
 		updatedInfoJson, err := json.Marshal(deviceCodeInfo)
 		if err != nil {
@@ -662,156 +1471,577 @@ func FinishLogin(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, loginInfo.OriginalUrl, http.StatusFound)
 }
 
-type ResetTemporaryPasswordRequestDto struct {
-	NewPassword string `json:"newPassword" validate:"required"`
+type ResetTemporaryPasswordRequestDto struct {
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+// ResetTemporaryPassword sets a new password when the current one is temporary.
+// @Summary      Reset temporary password
+// @Tags         Logins
+// @Accept       json
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Param        body        body   handlers.ResetTemporaryPasswordRequestDto true "New password"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request"
+// @Failure      401         {string} string "Unauthorized or wrong step"
+// @Router       /logins/{loginToken}/reset-temporary-password [post]
+func ResetTemporaryPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	err := updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		if loginInfo.Step != jsonTypes.LoginStepTemporaryPassword {
+			return utils.ErrHttpUnauthorized
+		}
+
+		var dto ResetTemporaryPasswordRequestDto
+		err := json.NewDecoder(r.Body).Decode(&dto)
+		if err != nil {
+			return err
+		}
+
+		err = utils.ValidateDto(dto)
+		if err != nil {
+			return err
+		}
+
+		dbContext := ioc.GetDependency[database.Context](scope)
+		userFilter := repositories.NewUserFilter().VirtualServerId(loginInfo.VirtualServerId).Id(loginInfo.UserId)
+		user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+
+		passwordValidator := ioc.GetDependency[password.Validator](scope)
+		err = passwordValidator.Validate(ctx, dto.NewPassword, user)
+		if err != nil {
+			return fmt.Errorf("%w: %w", utils.ErrPasswordInvalid, err)
+		}
+
+		// The login flow is pre-authentication, so /logins/* skips
+		// authentication.Middleware and ctx carries no CurrentUser. Switch
+		// to the system identity for this command: the login token plus the
+		// LoginStepTemporaryPassword guard above are the proof that the
+		// caller is authorised to reset their own temp password.
+		sysCtx := authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+		m := ioc.GetDependency[mediatr.Mediator](scope)
+		_, err = mediatr.Send[*commands.SetPasswordResponse](sysCtx, m, commands.SetPassword{
+			UserId:      loginInfo.UserId,
+			NewPassword: dto.NewPassword,
+			Temporary:   false,
+		})
+		if err != nil {
+			return err
+		}
+
+		loginInfo.PasswordExpired = false
+		return nil
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendEmailVerification sends a new email verification message.
+// @Summary      Resend email verification
+// @Tags         Logins
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request"
+// @Failure      401         {string} string "Unauthorized or wrong step"
+// @Router       /logins/{loginToken}/resend-email-verification [post]
+func ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	rawTokenData, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	if err != nil {
+		http.Error(w, "invalid login token", http.StatusBadRequest)
+		return
+	}
+
+	var loginInfo jsonTypes.LoginInfo
+	err = json.Unmarshal([]byte(rawTokenData), &loginInfo)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	if loginInfo.Step != jsonTypes.LoginStepEmailVerification {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	mailThrottle := ioc.GetDependency[services.MailThrottle](scope)
+	err = mailThrottle.Acquire(ctx, repositories.EmailVerificationMailTemplate, loginInfo.UserId)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// retrigger email verification sending
+	token, err := tokenService.GenerateAndStoreToken(ctx, services.EmailVerificationTokenType, loginInfo.UserId.String(), time.Minute*15)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("storing email verification token: %w", err))
+		return
+	}
+
+	templateService := ioc.GetDependency[services.TemplateService](scope)
+	mailBody, err := templateService.Template(
+		ctx,
+		loginInfo.VirtualServerId,
+		repositories.EmailVerificationMailTemplate,
+		templates.EmailVerificationTemplateData{
+			VerificationLink: fmt.Sprintf(
+				"%s/api/virtual-servers/%s/users/verify-email?token=%s",
+				config.C.Server.ExternalUrl,
+				loginInfo.VirtualServerName,
+				token,
+			),
+		},
+	)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("templating email verification mail: %w", err))
+		return
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	userFilter := repositories.NewUserFilter().Id(loginInfo.UserId)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
+		return
+	}
+
+	message := &messages.SendEmailMessage{
+		VirtualServerId: loginInfo.VirtualServerId,
+		To:              user.PrimaryEmail(),
+		Subject:         "Email verification",
+		Body:            mailBody,
+	}
+
+	outboxMessage, err := repositories.NewOutboxMessage(message)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("creating email outbox message: %w", err))
+		return
+	}
+
+	dbContext.OutboxMessages().Insert(outboxMessage)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EmailLoginExpiry is how long the link and the code of an email login
+// are valid.
+const EmailLoginExpiry = 10 * time.Minute
+
+// MaxFailedEmailLoginAttempts is the number of wrong codes after which the
+// code is dropped and a new one has to be requested. Together with the
+// mail throttle this keeps the six digit code from being guessed.
+const MaxFailedEmailLoginAttempts = 5
+
+type StartEmailLoginRequestDto struct {
+	UsernameOrEmail string `json:"usernameOrEmail" validate:"required"`
+}
+
+// StartEmailLogin sends a link and a code to sign in with to the user.
+// @Summary      Start email login
+// @Tags         Logins
+// @Accept       json
+// @Produce      plain
+// @Param        loginToken  path   string true  "Login session token"
+// @Param        body        body   handlers.StartEmailLoginRequestDto true "User to sign in"
+// @Success      204         {string} string "No Content"
+// @Failure      400         {string} string "Bad Request"
+// @Failure      401         {string} string "Unauthorized or wrong step"
+// @Router       /logins/{loginToken}/email-login/start [post]
+func StartEmailLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vars := mux.Vars(r)
+	loginToken := vars["loginToken"]
+
+	var dto StartEmailLoginRequestDto
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	rawLoginInfo, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting token: %w", err))
+		return
+	}
+
+	var loginInfo jsonTypes.LoginInfo
+	if err := json.Unmarshal([]byte(rawLoginInfo), &loginInfo); err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("unmarshal login info: %w", err))
+		return
+	}
+
+	if loginInfo.Step != jsonTypes.LoginStepPasswordVerification {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+	}
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting virtual server: %w", err))
+		return
+	}
+
+	if !virtualServer.EnableEmailLogin() {
+		utils.HandleHttpError(w, utils.ErrEmailLoginNotEnabled)
+		return
+	}
+
+	err = queueEmailLoginMail(ctx, loginToken, virtualServer, dto.UsernameOrEmail)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queueEmailLoginMail sends a new link and code to the user. Unknown,
+// disabled and locked users as well as throttled mails are skipped
+// silently, so that the response does not tell which accounts exist.
+func queueEmailLoginMail(ctx context.Context, loginToken string, virtualServer *repositories.VirtualServer, usernameOrEmail string) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	user, err := findEmailLoginUser(ctx, dbContext, virtualServer.Id(), usernameOrEmail)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Disabled() {
+		return nil
+	}
+
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	err = lockoutService.CheckUser(ctx, user.Id())
+	switch {
+	case errors.Is(err, services.ErrAccountLocked):
+		return nil
+
+	case err != nil:
+		return err
+	}
+
+	mailThrottle := ioc.GetDependency[services.MailThrottle](scope)
+	err = mailThrottle.Acquire(ctx, repositories.EmailLoginMailTemplate, user.Id())
+	switch {
+	case errors.Is(err, services.ErrMailThrottled):
+		return nil
+
+	case err != nil:
+		return err
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	challenge := jsonTypes.EmailLoginChallenge{
+		UserId:    user.Id(),
+		Code:      generateOneTimeCode(),
+		LinkToken: base64.RawURLEncoding.EncodeToString(utils.GetSecureRandomBytes(32)),
+		ExpiresAt: clockService.Now().Add(EmailLoginExpiry),
+	}
+
+	// a new mail replaces the link and code of the previous one
+	err = storeEmailLoginChallenge(ctx, loginToken, challenge)
+	if err != nil {
+		return err
+	}
+
+	templateService := ioc.GetDependency[services.TemplateService](scope)
+	mailBody, err := templateService.Template(
+		ctx,
+		virtualServer.Id(),
+		repositories.EmailLoginMailTemplate,
+		templates.EmailLoginTemplateData{
+			LoginLink: fmt.Sprintf(
+				"%s/login?token=%s&emailLoginToken=%s",
+				config.C.Frontend.ExternalUrl,
+				loginToken,
+				challenge.LinkToken,
+			),
+			Code:      challenge.Code,
+			ExpiresIn: fmt.Sprintf("%d minutes", int(EmailLoginExpiry.Minutes())),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("templating email login mail: %w", err)
+	}
+
+	message := &messages.SendEmailMessage{
+		VirtualServerId: virtualServer.Id(),
+		To:              user.PrimaryEmail(),
+		Subject:         "Sign in",
+		Body:            mailBody,
+	}
+
+	outboxMessage, err := repositories.NewOutboxMessage(message)
+	if err != nil {
+		return fmt.Errorf("creating email login outbox message: %w", err)
+	}
+
+	dbContext.OutboxMessages().Insert(outboxMessage)
+	return nil
+}
+
+// findEmailLoginUser looks the user up by username first and by primary
+// email second. Service users cannot sign in with an email.
+func findEmailLoginUser(ctx context.Context, dbContext database.Context, virtualServerId uuid.UUID, usernameOrEmail string) (*repositories.User, error) {
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServerId).
+		ServiceUser(false)
+
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter.Username(usernameOrEmail))
+	if err != nil {
+		return nil, fmt.Errorf("getting user by username: %w", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = dbContext.Users().FirstOrNil(ctx, userFilter.PrimaryEmail(usernameOrEmail))
+	if err != nil {
+		return nil, fmt.Errorf("getting user by email: %w", err)
+	}
+
+	return user, nil
+}
+
+// generateOneTimeCode returns a random six digit code for codes that are
+// typed in by hand, i.e. the email login and SMS codes.
+func generateOneTimeCode() string {
+	b := utils.GetSecureRandomBytes(4)
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(b)%1_000_000)
+}
+
+// oneTimeCodeAttemptsKey is where the attempts at the one-time code stored
+// under the token are counted.
+func oneTimeCodeAttemptsKey(tokenType services.TokenType, token string) string {
+	return tokenType.Key(token) + ":attempts"
+}
+
+// countOneTimeCodeAttempt counts an attempt at the one-time code stored
+// under the token and returns the number of attempts so far. The count is
+// kept outside of the code, so that parallel guesses cannot overwrite each
+// other's count.
+func countOneTimeCodeAttempt(ctx context.Context, tokenType services.TokenType, token string, expiration time.Duration) (int64, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+
+	attempts, err := kvStore.Increment(ctx, oneTimeCodeAttemptsKey(tokenType, token), expiration)
+	if err != nil {
+		return 0, fmt.Errorf("counting one-time code attempts: %w", err)
+	}
+
+	return attempts, nil
 }
 
-// ResetTemporaryPassword sets a new password when the current one is temporary.
-// @Summary      Reset temporary password
-// @Tags         Logins
-// @Accept       json
-// @Produce      plain
-// @Param        loginToken  path   string true  "Login session token"
-// @Param        body        body   handlers.ResetTemporaryPasswordRequestDto true "New password"
-// @Success      204         {string} string "No Content"
-// @Failure      400         {string} string "Bad Request"
-// @Failure      401         {string} string "Unauthorized or wrong step"
-// @Router       /logins/{loginToken}/reset-temporary-password [post]
-func ResetTemporaryPassword(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// resetOneTimeCodeAttempts forgets the attempts at the previous code when a
+// new one is stored under the token.
+func resetOneTimeCodeAttempts(ctx context.Context, tokenType services.TokenType, token string) error {
 	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
 
-	vars := mux.Vars(r)
-	loginToken := vars["loginToken"]
+	err := kvStore.Delete(ctx, oneTimeCodeAttemptsKey(tokenType, token))
+	if err != nil && !errors.Is(err, keyValue.ErrNotFound) {
+		return fmt.Errorf("resetting one-time code attempts: %w", err)
+	}
 
-	err := updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
-		if loginInfo.Step != jsonTypes.LoginStepTemporaryPassword {
-			return utils.ErrHttpUnauthorized
-		}
+	return nil
+}
 
-		var dto ResetTemporaryPasswordRequestDto
-		err := json.NewDecoder(r.Body).Decode(&dto)
-		if err != nil {
-			return err
-		}
+func storeEmailLoginChallenge(ctx context.Context, loginToken string, challenge jsonTypes.EmailLoginChallenge) error {
+	scope := middlewares.GetScope(ctx)
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
 
-		err = utils.ValidateDto(dto)
-		if err != nil {
-			return err
-		}
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("marshal email login challenge: %w", err)
+	}
 
-		// The login flow is pre-authentication, so /logins/* skips
-		// authentication.Middleware and ctx carries no CurrentUser. Switch
-		// to the system identity for this command: the login token plus the
-		// LoginStepTemporaryPassword guard above are the proof that the
-		// caller is authorised to reset their own temp password.
-		sysCtx := authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
-		m := ioc.GetDependency[mediatr.Mediator](scope)
-		_, err = mediatr.Send[*commands.SetPasswordResponse](sysCtx, m, commands.SetPassword{
-			UserId:      loginInfo.UserId,
-			NewPassword: dto.NewPassword,
-			Temporary:   false,
-		})
-		if err != nil {
-			return err
-		}
+	err = resetOneTimeCodeAttempts(ctx, services.EmailLoginTokenType, loginToken)
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
+	err = tokenService.StoreToken(ctx, services.EmailLoginTokenType, loginToken, string(value), challenge.ExpiresAt.Sub(clockService.Now()))
 	if err != nil {
-		utils.HandleHttpError(w, err)
-		return
+		return fmt.Errorf("storing email login challenge: %w", err)
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
-// ResendEmailVerification sends a new email verification message.
-// @Summary      Resend email verification
+type VerifyEmailLoginRequestDto struct {
+	// Code is the code from the mail, Token the token from the link in it.
+	Code  string `json:"code" validate:"required_without=Token"`
+	Token string `json:"token" validate:"required_without=Code"`
+}
+
+// VerifyEmailLogin signs the user in with the link or code from the mail.
+// @Summary      Verify email login
 // @Tags         Logins
+// @Accept       json
 // @Produce      plain
 // @Param        loginToken  path   string true  "Login session token"
+// @Param        body        body   handlers.VerifyEmailLoginRequestDto true "Code or link token"
 // @Success      204         {string} string "No Content"
 // @Failure      400         {string} string "Bad Request"
 // @Failure      401         {string} string "Unauthorized or wrong step"
-// @Router       /logins/{loginToken}/resend-email-verification [post]
-func ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
-	// TODO: add "cooldown" for sending emails
-
+// @Router       /logins/{loginToken}/email-login/verify [post]
+func VerifyEmailLogin(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	scope := middlewares.GetScope(ctx)
 
 	vars := mux.Vars(r)
 	loginToken := vars["loginToken"]
 
-	tokenService := ioc.GetDependency[services.TokenService](scope)
-	rawTokenData, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	var dto VerifyEmailLoginRequestDto
+	err := json.NewDecoder(r.Body).Decode(&dto)
 	if err != nil {
-		http.Error(w, "invalid login token", http.StatusBadRequest)
+		utils.HandleHttpError(w, err)
 		return
 	}
 
-	var loginInfo jsonTypes.LoginInfo
-	err = json.Unmarshal([]byte(rawTokenData), &loginInfo)
+	err = utils.ValidateDto(dto)
 	if err != nil {
 		utils.HandleHttpError(w, err)
 		return
 	}
 
-	if loginInfo.Step != jsonTypes.LoginStepEmailVerification {
-		w.WriteHeader(http.StatusUnauthorized)
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	rawChallenge, err := tokenService.GetToken(ctx, services.EmailLoginTokenType, loginToken)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+
+	case err != nil:
+		utils.HandleHttpError(w, err)
 		return
 	}
 
-	// retrigger email verification sending
-	token, err := tokenService.GenerateAndStoreToken(ctx, services.EmailVerificationTokenType, loginInfo.UserId.String(), time.Minute*15)
-	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("storing email verification token: %w", err))
+	var challenge jsonTypes.EmailLoginChallenge
+	if err := json.Unmarshal([]byte(rawChallenge), &challenge); err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("unmarshal email login challenge: %w", err))
 		return
 	}
 
-	templateService := ioc.GetDependency[services.TemplateService](scope)
-	mailBody, err := templateService.Template(
-		ctx,
-		loginInfo.VirtualServerId,
-		repositories.EmailVerificationMailTemplate,
-		templates.EmailVerificationTemplateData{
-			VerificationLink: fmt.Sprintf(
-				"%s/api/virtual-servers/%s/users/verify-email?token=%s",
-				config.C.Server.ExternalUrl,
-				loginInfo.VirtualServerName,
-				token,
-			),
-		},
-	)
+	attempts, err := countOneTimeCodeAttempt(ctx, services.EmailLoginTokenType, loginToken, EmailLoginExpiry)
 	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("templating email verification mail: %w", err))
+		utils.HandleHttpError(w, err)
 		return
 	}
 
-	dbContext := ioc.GetDependency[database.Context](scope)
+	codeMatches := dto.Code != "" && subtle.ConstantTimeCompare([]byte(dto.Code), []byte(challenge.Code)) == 1
+	tokenMatches := dto.Token != "" && subtle.ConstantTimeCompare([]byte(dto.Token), []byte(challenge.LinkToken)) == 1
+	if attempts > MaxFailedEmailLoginAttempts || (!codeMatches && !tokenMatches) {
+		if attempts >= MaxFailedEmailLoginAttempts {
+			err = tokenService.DeleteToken(ctx, services.EmailLoginTokenType, loginToken)
+			if err != nil {
+				utils.HandleHttpError(w, err)
+				return
+			}
+		}
 
-	userFilter := repositories.NewUserFilter().Id(loginInfo.UserId)
-	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
-	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
 		return
 	}
 
-	message := &messages.SendEmailMessage{
-		VirtualServerId: loginInfo.VirtualServerId,
-		To:              user.PrimaryEmail(),
-		Subject:         "Email verification",
-		Body:            mailBody,
+	// the link and the code can only be used once, of two requests with
+	// the right code only the one that takes the challenge signs in
+	takenChallenge, err := tokenService.TakeToken(ctx, services.EmailLoginTokenType, loginToken)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+
+	case err != nil:
+		utils.HandleHttpError(w, fmt.Errorf("taking email login challenge: %w", err))
+		return
 	}
 
-	outboxMessage, err := repositories.NewOutboxMessage(message)
-	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("creating email outbox message: %w", err))
+	// a new mail may have replaced the challenge in between
+	if takenChallenge != rawChallenge {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
 		return
 	}
 
-	dbContext.OutboxMessages().Insert(outboxMessage)
+	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		if loginInfo.Step != jsonTypes.LoginStepPasswordVerification {
+			return utils.ErrHttpUnauthorized
+		}
+
+		// the user may have been disabled since the mail was sent
+		dbContext := ioc.GetDependency[database.Context](scope)
+		userFilter := repositories.NewUserFilter().
+			VirtualServerId(loginInfo.VirtualServerId).
+			Id(challenge.UserId)
+		user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+		if user == nil || user.Disabled() {
+			return utils.ErrHttpUnauthorized
+		}
+		if loginInfo.UserId != uuid.Nil && loginInfo.UserId != user.Id() {
+			return utils.ErrHttpUnauthorized
+		}
+
+		virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+		virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+		if err != nil {
+			return fmt.Errorf("getting virtual server: %w", err)
+		}
+
+		trustedDeviceId, err := trustedDeviceOfRequest(ctx, r, virtualServer, user.Id())
+		if err != nil {
+			return err
+		}
+
+		loginInfo.UserId = user.Id()
+		loginInfo.TrustedDeviceId = trustedDeviceId
+		loginInfo.ClientIp = utils.ClientIp(r, config.C.Server.TrustedProxies)
+		loginInfo.UserAgent = r.UserAgent()
+		loginInfo.Step = jsonTypes.LoginStepEmailLogin
+		loginInfo.AddAuthenticationMethod(repositories.AuthenticationMethodEmail)
+		return nil
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -819,6 +2049,10 @@ func ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
 type StartPasskeyLoginResponseDto struct {
 	Id        uuid.UUID `json:"id"`
 	Challenge string    `json:"challenge"`
+	// AllowCredentials lists the credential ids of the user if the user
+	// is already known. It is empty if the user is not known yet, the
+	// authenticator then offers its discoverable credentials.
+	AllowCredentials []string `json:"allowCredentials"`
 }
 
 func StartPasskeyLogin(w http.ResponseWriter, r *http.Request) {
@@ -828,12 +2062,49 @@ func StartPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	loginToken := vars["loginToken"]
 
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	rawTokenData, err := tokenService.GetToken(ctx, services.LoginSessionTokenType, loginToken)
+	if err != nil {
+		http.Error(w, "invalid login token", http.StatusBadRequest)
+		return
+	}
+
+	var loginInfo jsonTypes.LoginInfo
+	err = json.Unmarshal([]byte(rawTokenData), &loginInfo)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	allowCredentials := make([]string, 0)
+	if loginInfo.UserId != uuid.Nil {
+		dbContext := ioc.GetDependency[database.Context](scope)
+		credentialFilter := repositories.NewCredentialFilter().
+			UserId(loginInfo.UserId).
+			Type(repositories.CredentialTypeWebauthn)
+		credentials, err := dbContext.Credentials().List(ctx, credentialFilter)
+		if err != nil {
+			utils.HandleHttpError(w, err)
+			return
+		}
+
+		for _, credential := range credentials {
+			details, err := credential.WebauthnDetails()
+			if err != nil {
+				utils.HandleHttpError(w, err)
+				return
+			}
+			allowCredentials = append(allowCredentials, details.CredentialId)
+		}
+	}
+
 	challengeBytes := utils.GetSecureRandomBytes(64)
 
 	challenge := jsonTypes.PasskeyLoginChallenge{
 		Id:                uuid.New(),
 		Challenge:         base64.StdEncoding.EncodeToString(challengeBytes),
 		LoginSessionToken: loginToken,
+		UserId:            loginInfo.UserId,
 	}
 
 	challengeJson, err := json.Marshal(challenge)
@@ -852,8 +2123,9 @@ func StartPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	err = json.NewEncoder(w).Encode(StartPasskeyLoginResponseDto{
-		Id:        challenge.Id,
-		Challenge: challenge.Challenge,
+		Id:               challenge.Id,
+		Challenge:        challenge.Challenge,
+		AllowCredentials: allowCredentials,
 	})
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -898,12 +2170,16 @@ func FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get from kv store
+	// take from kv store, a challenge can only be answered once
 	kvStore := ioc.GetDependency[keyValue.Store](scope)
 	challengeKey := "passkey_login:" + dto.Id.String()
-	challengeJson, err := kvStore.Get(ctx, challengeKey)
+	challengeJson, err := kvStore.GetAndDelete(ctx, challengeKey)
+	if errors.Is(err, keyValue.ErrNotFound) {
+		utils.HandleHttpError(w, fmt.Errorf("challenge expired or missing: %w", utils.ErrHttpUnauthorized))
+		return
+	}
 	if err != nil {
-		utils.HandleHttpError(w, fmt.Errorf("challenge expired or missing"))
+		utils.HandleHttpError(w, fmt.Errorf("taking challenge: %w", err))
 		return
 	}
 
@@ -990,6 +2266,19 @@ func FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a login that started with a known user must be finished by that
+	// user, for discoverable credentials the user handle identifies the
+	// user instead
+	if challenge.UserId != uuid.Nil && credential.UserId() != challenge.UserId {
+		utils.HandleHttpError(w, fmt.Errorf("credential does not belong to the user of the login: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	if dto.WebauthnResponse.Response.UserHandle != "" && !webauthnUserHandleMatches(dto.WebauthnResponse.Response.UserHandle, credential.UserId()) {
+		utils.HandleHttpError(w, fmt.Errorf("user handle does not match the credential owner: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
 	credentialDetails, err := credential.WebauthnDetails()
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -1019,7 +2308,28 @@ func FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	signCount, err := webauthnSignCount(authData)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	err = updateLoginStep(ctx, loginToken, func(loginInfo *jsonTypes.LoginInfo) error {
+		// after a password the passkey is a second factor of the user
+		// who entered it, otherwise it logs the user in on its own
+		secondFactor := false
+		switch loginInfo.Step {
+		case jsonTypes.LoginStepPasswordVerification:
+		case jsonTypes.LoginStepVerifyPasskey:
+			secondFactor = true
+		default:
+			return utils.ErrHttpUnauthorized
+		}
+		// a step-up login is for the user of the session
+		if (secondFactor || loginInfo.UserId != uuid.Nil) && credential.UserId() != loginInfo.UserId {
+			return fmt.Errorf("credential does not belong to the user of the login: %w", utils.ErrHttpUnauthorized)
+		}
+
 		// The credential lookup above is keyed only by RawId; without a
 		// virtual-server scope a credential registered against a user in
 		// VS-A would otherwise authenticate the holder into VS-B's
@@ -1037,9 +2347,47 @@ func FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
 		if owner == nil {
 			return fmt.Errorf("credential does not belong to this virtual server: %w", utils.ErrHttpUnauthorized)
 		}
+		if owner.Disabled() {
+			return fmt.Errorf("credential owner is disabled: %w", utils.ErrHttpUnauthorized)
+		}
+
+		lockoutService := ioc.GetDependency[services.LockoutService](scope)
+		err = lockoutService.CheckUser(ctx, owner.Id())
+		if err != nil {
+			return err
+		}
+
+		// the passkey policy applies to existing passkeys as well, e.g.
+		// after an authenticator was removed from the allow-list
+		virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+		virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+		if err != nil {
+			return fmt.Errorf("getting virtual server: %w", err)
+		}
+		rpId, err := webauthnRpId(config.C.Frontend.ExternalUrl)
+		if err != nil {
+			return err
+		}
+		err = verifyPasskeyLogin(authData, rpId, credentialDetails, virtualServer.PasskeyPolicy())
+		if err != nil {
+			return err
+		}
 
-		loginInfo.UserId = credential.UserId()
-		loginInfo.Step = jsonTypes.LoginStepPasskey
+		clockService := ioc.GetDependency[clock.Service](scope)
+		err = credentialDetails.RecordUse(signCount, clockService.Now())
+		if err != nil {
+			return fmt.Errorf("%w: %w", err, utils.ErrHttpUnauthorized)
+		}
+		credential.SetDetails(credentialDetails)
+		dbContext.Credentials().Update(credential)
+		loginInfo.AddAuthenticationMethod(credentialDetails.AuthenticationMethod())
+
+		if !secondFactor {
+			loginInfo.UserId = credential.UserId()
+			loginInfo.ClientIp = utils.ClientIp(r, config.C.Server.TrustedProxies)
+			loginInfo.UserAgent = r.UserAgent()
+			loginInfo.Step = jsonTypes.LoginStepPasskey
+		}
 		return nil
 	})
 	if err != nil {
@@ -1106,6 +2454,55 @@ func validateSignature(pubKey any, pubKeyAlgorithm int, message, sigBytes []byte
 	return nil
 }
 
+// verifyPasskeyLogin checks an assertion against the relying party and the
+// passkey policy of the virtual server.
+func verifyPasskeyLogin(authData []byte, rpId string, details *repositories.CredentialWebauthnDetails, policy repositories.PasskeyPolicy) error {
+	parsed, err := webauthn.ParseAuthenticatorData(authData)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(parsed.RpIdHash, webauthn.RpIdHash(rpId)) {
+		return fmt.Errorf("assertion was made for another relying party: %w", utils.ErrHttpUnauthorized)
+	}
+
+	if !parsed.UserPresent() {
+		return fmt.Errorf("user presence is required: %w", utils.ErrHttpUnauthorized)
+	}
+
+	if policy.UserVerification == repositories.PasskeyUserVerificationRequired && !parsed.UserVerified() {
+		return fmt.Errorf("user verification is required: %w", utils.ErrHttpUnauthorized)
+	}
+
+	if !policy.AllowsAaguid(details.Aaguid) {
+		return fmt.Errorf("authenticator %s is not allowed: %w", details.Aaguid, utils.ErrHttpUnauthorized)
+	}
+
+	return nil
+}
+
+// webauthnSignCount reads the signature counter from authenticator data,
+// which starts with the 32 byte rpIdHash and the flags byte.
+func webauthnSignCount(authData []byte) (uint32, error) {
+	if len(authData) < 37 {
+		return 0, fmt.Errorf("authenticator data too short: %w", utils.ErrHttpBadRequest)
+	}
+
+	return binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+// webauthnUserHandleMatches checks the user handle an authenticator returns
+// for a discoverable credential. Keyline uses the user id as user handle,
+// either as its 16 raw bytes or as its string form.
+func webauthnUserHandleMatches(userHandle string, userId uuid.UUID) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(userHandle, "="))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(decoded, userId[:]) || string(decoded) == userId.String()
+}
+
 // webauthnExpectedOrigin returns the origin (scheme + host[:port]) that
 // `clientData.Origin` MUST equal for a WebAuthn assertion accepted at
 // /logins/{token}/passkey/finish. The expected origin is derived from
@@ -1128,3 +2525,19 @@ func webauthnExpectedOrigin(frontendExternalUrl string) (string, error) {
 	}
 	return u.Scheme + "://" + u.Host, nil
 }
+
+// webauthnRpId returns the relying party id credentials are registered for,
+// the host the login UI runs at.
+func webauthnRpId(frontendExternalUrl string) (string, error) {
+	if frontendExternalUrl == "" {
+		return "", fmt.Errorf("frontend.externalUrl is not configured")
+	}
+	u, err := url.Parse(frontendExternalUrl)
+	if err != nil {
+		return "", fmt.Errorf("parsing frontend.externalUrl: %w", err)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("frontend.externalUrl is missing host: %q", frontendExternalUrl)
+	}
+	return u.Hostname(), nil
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"testing"
 	"time"
 
 	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/jsonTypes"
 	"github.com/The127/Keyline/internal/mocks"
 	"github.com/The127/Keyline/internal/repositories"
 	repoMocks "github.com/The127/Keyline/internal/repositories/mocks"
@@ -85,6 +87,250 @@ func TestWebauthnExpectedOrigin(t *testing.T) {
 	}
 }
 
+func TestWebauthnSignCount(t *testing.T) {
+	t.Parallel()
+
+	authData := make([]byte, 37)
+	authData[33], authData[36] = 0x01, 0x02
+
+	signCount, err := webauthnSignCount(authData)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x01000002), signCount)
+
+	_, err = webauthnSignCount(authData[:36])
+	assert.Error(t, err)
+}
+
+func TestWebauthnUserHandleMatches(t *testing.T) {
+	t.Parallel()
+
+	userId := uuid.New()
+
+	assert.True(t, webauthnUserHandleMatches(base64.RawURLEncoding.EncodeToString(userId[:]), userId))
+	assert.True(t, webauthnUserHandleMatches(base64.URLEncoding.EncodeToString([]byte(userId.String())), userId))
+	assert.False(t, webauthnUserHandleMatches(base64.RawURLEncoding.EncodeToString([]byte(uuid.NewString())), userId))
+	assert.False(t, webauthnUserHandleMatches("not base64!", userId))
+}
+
+func TestSecondFactorLoginStep(t *testing.T) {
+	t.Parallel()
+
+	step, err := secondFactorLoginStep(jsonTypes.SecondFactorTotp)
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepVerifyTotp, step)
+
+	step, err = secondFactorLoginStep(jsonTypes.SecondFactorPasskey)
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepVerifyPasskey, step)
+
+	step, err = secondFactorLoginStep(jsonTypes.SecondFactorSms)
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepVerifySms, step)
+
+	_, err = secondFactorLoginStep("carrier-pigeon")
+	assert.ErrorIs(t, err, utils.ErrHttpBadRequest)
+}
+
+func TestNextSecondFactorLoginStep_UpstreamMultiFactor(t *testing.T) {
+	t.Parallel()
+
+	virtualServer := repositories.NewVirtualServer("test", "Test")
+	newLoginInfo := func() *jsonTypes.LoginInfo {
+		return &jsonTypes.LoginInfo{
+			AuthenticationMethods: []repositories.AuthenticationMethod{repositories.AuthenticationMethodPassword},
+			UpstreamMultiFactor:   true,
+		}
+	}
+
+	// without a rule the upstream second factor is enough
+	user := repositories.NewUser("user", "User", "user@example.com", virtualServer.Id())
+	step, err := nextSecondFactorLoginStep(t.Context(), nil, newLoginInfo(), virtualServer, user, []jsonTypes.SecondFactor{jsonTypes.SecondFactorTotp}, "")
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepFinish, step)
+
+	// a rule that requires a second factor asks for a local one
+	step, err = nextSecondFactorLoginStep(t.Context(), nil, newLoginInfo(), virtualServer, user, []jsonTypes.SecondFactor{jsonTypes.SecondFactorTotp}, repositories.MfaRuleActionRequire)
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepVerifyTotp, step)
+
+	// an admin reset of the second factors is not skipped either
+	user.SetRequire2faOnboarding(true)
+	step, err = nextSecondFactorLoginStep(t.Context(), nil, newLoginInfo(), virtualServer, user, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, jsonTypes.LoginStepOnboardTotp, step)
+}
+
+func TestCompletesMissingAuthenticationMethod(t *testing.T) {
+	t.Parallel()
+
+	password := []repositories.AuthenticationMethod{repositories.AuthenticationMethodPassword}
+	cases := []struct {
+		name         string
+		secondFactor jsonTypes.SecondFactor
+		completed    []repositories.AuthenticationMethod
+		missing      []repositories.AuthenticationMethod
+		want         bool
+	}{
+		{name: "totp completes otp", secondFactor: jsonTypes.SecondFactorTotp, completed: password, missing: []repositories.AuthenticationMethod{"otp"}, want: true},
+		{name: "sms does not complete otp", secondFactor: jsonTypes.SecondFactorSms, completed: password, missing: []repositories.AuthenticationMethod{"otp"}},
+		{name: "passkey completes hwk", secondFactor: jsonTypes.SecondFactorPasskey, completed: password, missing: []repositories.AuthenticationMethod{"hwk"}, want: true},
+		{name: "totp does not complete hwk", secondFactor: jsonTypes.SecondFactorTotp, completed: password, missing: []repositories.AuthenticationMethod{"hwk"}},
+		{name: "any new factor completes mfa", secondFactor: jsonTypes.SecondFactorSms, completed: password, missing: []repositories.AuthenticationMethod{"mfa"}, want: true},
+		{name: "completed factor does not complete mfa", secondFactor: jsonTypes.SecondFactorTotp, completed: []repositories.AuthenticationMethod{"otp"}, missing: []repositories.AuthenticationMethod{"mfa"}},
+		{name: "totp completes mfa after an email login", secondFactor: jsonTypes.SecondFactorTotp, completed: []repositories.AuthenticationMethod{"email"}, missing: []repositories.AuthenticationMethod{"mfa"}, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, completesMissingAuthenticationMethod(tc.secondFactor, tc.completed, tc.missing))
+		})
+	}
+}
+
+func TestCredentialWebauthnDetails_AuthenticationMethod(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, repositories.AuthenticationMethodSoftwareKey, (&repositories.CredentialWebauthnDetails{}).AuthenticationMethod())
+	assert.Equal(t, repositories.AuthenticationMethodSoftwareKey, (&repositories.CredentialWebauthnDetails{AttestationFormat: "none"}).AuthenticationMethod())
+	assert.Equal(t, repositories.AuthenticationMethodHardwareKey, (&repositories.CredentialWebauthnDetails{AttestationFormat: "packed"}).AuthenticationMethod())
+}
+
+func TestMissingAuthenticationMethods(t *testing.T) {
+	t.Parallel()
+
+	pwd := repositories.AuthenticationMethodPassword
+	otp := repositories.AuthenticationMethodOtp
+	mfa := repositories.AuthenticationMethodMultiFactor
+
+	assert.Empty(t, repositories.MissingAuthenticationMethods(nil, []repositories.AuthenticationMethod{pwd}))
+	assert.Equal(t, []repositories.AuthenticationMethod{otp}, repositories.MissingAuthenticationMethods([]repositories.AuthenticationMethod{pwd, otp}, []repositories.AuthenticationMethod{pwd}))
+	assert.Equal(t, []repositories.AuthenticationMethod{mfa}, repositories.MissingAuthenticationMethods([]repositories.AuthenticationMethod{mfa}, []repositories.AuthenticationMethod{pwd}))
+	assert.Empty(t, repositories.MissingAuthenticationMethods([]repositories.AuthenticationMethod{mfa}, []repositories.AuthenticationMethod{pwd, otp}))
+
+	assert.Equal(t, []string{"pwd"}, repositories.AmrClaim([]repositories.AuthenticationMethod{pwd}))
+	assert.Equal(t, []string{"pwd", "otp", "mfa"}, repositories.AmrClaim([]repositories.AuthenticationMethod{pwd, otp}))
+}
+
+func TestAcrLevels_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := repositories.AcrLevels{
+		{Acr: "basic", Methods: []repositories.AuthenticationMethod{"pwd"}},
+		{Acr: "transfer", Methods: []repositories.AuthenticationMethod{"pwd", "hwk"}},
+	}
+	require.NoError(t, valid.Validate())
+	assert.Equal(t, "transfer", valid.Find("transfer").Acr)
+	assert.Nil(t, valid.Find("unknown"))
+
+	assert.ErrorIs(t, repositories.AcrLevels{{Acr: ""}}.Validate(), utils.ErrHttpBadRequest)
+	assert.ErrorIs(t, repositories.AcrLevels{{Acr: "a"}, {Acr: "a"}}.Validate(), utils.ErrHttpBadRequest)
+	assert.ErrorIs(t, repositories.AcrLevels{{Acr: "a", Methods: []repositories.AuthenticationMethod{"face"}}}.Validate(), utils.ErrHttpBadRequest)
+}
+
+func TestMfaRules_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	adminRoleId := uuid.New()
+	rules := repositories.MfaRules{
+		{Action: repositories.MfaRuleActionRequire, RoleIds: []uuid.UUID{adminRoleId}},
+		{Action: repositories.MfaRuleActionSkip, Cidrs: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{Action: repositories.MfaRuleActionRequire, Projects: []string{"payments"}},
+		{Action: repositories.MfaRuleActionRequire, NewDevice: true},
+	}
+	require.NoError(t, rules.Validate())
+
+	tests := []struct {
+		name   string
+		login  repositories.MfaLogin
+		action repositories.MfaRuleAction
+		ok     bool
+	}{
+		{"admins from the office", repositories.MfaLogin{ClientIp: "10.1.2.3", RoleIds: []uuid.UUID{adminRoleId}}, repositories.MfaRuleActionRequire, true},
+		{"office network", repositories.MfaLogin{ClientIp: "10.1.2.3", ProjectSlug: "payments"}, repositories.MfaRuleActionSkip, true},
+		{"office network over ipv6", repositories.MfaLogin{ClientIp: "2001:db8::1"}, repositories.MfaRuleActionSkip, true},
+		{"project", repositories.MfaLogin{ClientIp: "192.0.2.1", ProjectSlug: "payments"}, repositories.MfaRuleActionRequire, true},
+		{"new device", repositories.MfaLogin{ClientIp: "192.0.2.1", NewDevice: true}, repositories.MfaRuleActionRequire, true},
+		{"no rule matches", repositories.MfaLogin{ClientIp: "192.0.2.1"}, "", false},
+		{"unparsable ip", repositories.MfaLogin{ClientIp: "unknown"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, ok := rules.Evaluate(tt.login)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.action, action)
+		})
+	}
+
+	assert.True(t, rules.UsesRoles())
+	assert.True(t, rules.UsesNewDevice())
+	assert.False(t, repositories.MfaRules{{Action: repositories.MfaRuleActionSkip}}.UsesNewDevice())
+}
+
+func TestMfaRules_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.ErrorIs(t, repositories.MfaRules{{Action: "maybe"}}.Validate(), utils.ErrHttpBadRequest)
+	assert.ErrorIs(t, repositories.MfaRules{{Action: repositories.MfaRuleActionSkip, Cidrs: []string{"10.0.0.1"}}}.Validate(), utils.ErrHttpBadRequest)
+	assert.NoError(t, repositories.MfaRules{}.Validate())
+}
+
+func TestCredentialPasswordDetails_ChangePassword(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	details := &repositories.CredentialPasswordDetails{}
+
+	details.ChangePassword("first", now)
+	assert.Empty(t, details.History)
+
+	for i := range repositories.MaxPasswordHistory + 1 {
+		details.ChangePassword(string(rune('a'+i)), now)
+	}
+
+	assert.Len(t, details.History, repositories.MaxPasswordHistory)
+	assert.Equal(t, string(rune('a'+repositories.MaxPasswordHistory-1)), details.History[0])
+	assert.Equal(t, &now, details.ChangedAt)
+}
+
+func TestCredentialWebauthnDetails_RecordUse(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cases := []struct {
+		name    string
+		stored  uint32
+		got     uint32
+		wantErr bool
+	}{
+		{name: "counter increased", stored: 4, got: 5},
+		{name: "authenticator without counter", stored: 0, got: 0},
+		{name: "first counter", stored: 0, got: 1},
+		{name: "counter repeated", stored: 5, got: 5, wantErr: true},
+		{name: "counter regressed", stored: 5, got: 2, wantErr: true},
+		{name: "counter reset", stored: 5, got: 0, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			details := &repositories.CredentialWebauthnDetails{SignCount: tc.stored}
+
+			err := details.RecordUse(tc.got, now)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, repositories.ErrWebauthnSignCountRegression)
+				assert.Equal(t, tc.stored, details.SignCount)
+				assert.Nil(t, details.LastUsedAt)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.got, details.SignCount)
+			assert.Equal(t, now, *details.LastUsedAt)
+		})
+	}
+}
+
 func newPasswordVerifyTestContext(t *testing.T) (context.Context, *mocks.MockContext, *gomock.Controller) {
 	ctrl := gomock.NewController(t)
 	dbContext := mocks.NewMockContext(ctrl)
@@ -161,6 +407,9 @@ func newUnknownUserContext(t *testing.T) (context.Context, *mocks.MockContext, *
 	userRepository := repoMocks.NewMockUserRepository(ctrl)
 	userRepository.EXPECT().FirstOrNil(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 	dbContext.EXPECT().Users().Return(userRepository).AnyTimes()
+	ldapProviderRepository := repoMocks.NewMockLdapProviderRepository(ctrl)
+	ldapProviderRepository.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	dbContext.EXPECT().LdapProviders().Return(ldapProviderRepository).AnyTimes()
 	return context.Background(), dbContext, ctrl
 }
 
@@ -169,3 +418,11 @@ func newUnknownUserContext(t *testing.T) (context.Context, *mocks.MockContext, *
 // compile a refactor changed the helper's signature in a way the
 // VerifyPassword caller can no longer satisfy.
 var _ func(context.Context, database.Context, uuid.UUID, string, string) (*repositories.User, bool, error) = verifyPasswordCredential
+
+func TestGenerateOneTimeCode(t *testing.T) {
+	t.Parallel()
+	for range 100 {
+		code := generateOneTimeCode()
+		assert.Regexp(t, `^[0-9]{6}$`, code)
+	}
+}
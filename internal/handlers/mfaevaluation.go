@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/jsonTypes"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// evaluateMfaRules returns the action of the first MFA rule of the virtual
+// server the login matches, ok is false if none matches. Only what the
+// rules ask about is looked up.
+func evaluateMfaRules(
+	ctx context.Context,
+	dbContext database.Context,
+	loginInfo *jsonTypes.LoginInfo,
+	virtualServer *repositories.VirtualServer,
+	user *repositories.User,
+) (repositories.MfaRuleAction, bool, error) {
+	rules := virtualServer.MfaRules()
+	if len(rules) == 0 {
+		return "", false, nil
+	}
+
+	login := repositories.MfaLogin{
+		ClientIp: loginInfo.ClientIp,
+	}
+
+	if loginInfo.ApplicationId != uuid.Nil {
+		applicationFilter := repositories.NewApplicationFilter().
+			VirtualServerId(virtualServer.Id()).
+			Id(loginInfo.ApplicationId)
+		application, err := dbContext.Applications().FirstOrErr(ctx, applicationFilter)
+		if err != nil {
+			return "", false, fmt.Errorf("getting application: %w", err)
+		}
+
+		projectFilter := repositories.NewProjectFilter().
+			VirtualServerId(virtualServer.Id()).
+			Id(application.ProjectId())
+		project, err := dbContext.Projects().FirstOrErr(ctx, projectFilter)
+		if err != nil {
+			return "", false, fmt.Errorf("getting project: %w", err)
+		}
+		login.ProjectSlug = project.Slug()
+	}
+
+	if rules.UsesRoles() {
+		assignmentFilter := repositories.NewUserRoleAssignmentFilter().UserId(user.Id())
+		assignments, _, err := dbContext.UserRoleAssignments().List(ctx, assignmentFilter)
+		if err != nil {
+			return "", false, fmt.Errorf("listing role assignments: %w", err)
+		}
+		for _, assignment := range assignments {
+			login.RoleIds = append(login.RoleIds, assignment.RoleId())
+		}
+	}
+
+	if rules.UsesNewDevice() {
+		knownDevice, err := getKnownDevice(ctx, dbContext, user.Id(), loginInfo.UserAgent)
+		if err != nil {
+			return "", false, err
+		}
+		login.NewDevice = knownDevice == nil
+	}
+
+	action, ok := rules.Evaluate(login)
+	return action, ok, nil
+}
+
+// rememberKnownDevice records the user agent of a finished login, so that
+// MFA rules for new devices stop matching it. Devices are only recorded
+// while a rule asks about them.
+func rememberKnownDevice(ctx context.Context, r *http.Request, loginInfo *jsonTypes.LoginInfo) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Id(loginInfo.VirtualServerId)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return fmt.Errorf("getting virtual server: %w", err)
+	}
+	if !virtualServer.MfaRules().UsesNewDevice() {
+		return nil
+	}
+
+	userAgent := r.UserAgent()
+	knownDevice, err := getKnownDevice(ctx, dbContext, loginInfo.UserId, userAgent)
+	if err != nil {
+		return err
+	}
+
+	if knownDevice == nil {
+		name := userAgent
+		if len(name) > maxTrustedDeviceNameLength {
+			name = name[:maxTrustedDeviceNameLength]
+		}
+		dbContext.Credentials().Insert(repositories.NewCredential(loginInfo.UserId, &repositories.CredentialKnownDeviceDetails{
+			CredentialId: repositories.KnownDeviceCredentialId(userAgent),
+			Name:         name,
+			LastSeenAt:   clockService.Now(),
+		}))
+		return nil
+	}
+
+	details, err := knownDevice.KnownDeviceDetails()
+	if err != nil {
+		return err
+	}
+	details.LastSeenAt = clockService.Now()
+	knownDevice.SetDetails(details)
+	dbContext.Credentials().Update(knownDevice)
+	return nil
+}
+
+func getKnownDevice(ctx context.Context, dbContext database.Context, userId uuid.UUID, userAgent string) (*repositories.Credential, error) {
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(userId).
+		Type(repositories.CredentialTypeKnownDevice).
+		DetailsId(repositories.KnownDeviceCredentialId(userAgent))
+	credential, err := dbContext.Credentials().FirstOrNil(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting known device: %w", err)
+	}
+	return credential, nil
+}
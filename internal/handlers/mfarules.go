@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"net/http"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+)
+
+// GetMfaRules
+// @summary     Get MFA rules
+// @description Retrieve the MFA rules of a virtual server in the order they are evaluated.
+// @tags        MFA rules
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @success     200 {object} api.MfaRulesDto
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/mfa-policy/rules [get]
+func GetMfaRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*queries.GetMfaRulesResponse](ctx, m, queries.GetMfaRules{
+		VirtualServerName: vsName,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	rules := utils.MapSlice(response.Rules, func(x repositories.MfaRule) api.MfaRuleDto {
+		return api.MfaRuleDto{
+			Action:    string(x.Action),
+			Projects:  x.Projects,
+			Cidrs:     x.Cidrs,
+			RoleIds:   x.RoleIds,
+			NewDevice: x.NewDevice,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.MfaRulesDto{
+		Rules: rules,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// UpdateMfaRules
+// @summary     Replace MFA rules
+// @description Replace the MFA rules of a virtual server. The first rule a login matches decides whether it needs a second factor, logins no rule matches follow require2fa.
+// @tags        MFA rules
+// @accept      application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       body  body   api.MfaRulesDto  true  "Ordered MFA rules"
+// @success     204 "No Content"
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/mfa-policy/rules [put]
+func UpdateMfaRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.MfaRulesDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	rules := utils.MapSlice(dto.Rules, func(x api.MfaRuleDto) repositories.MfaRule {
+		return repositories.MfaRule{
+			Action:    repositories.MfaRuleAction(x.Action),
+			Projects:  x.Projects,
+			Cidrs:     x.Cidrs,
+			RoleIds:   x.RoleIds,
+			NewDevice: x.NewDevice,
+		}
+	})
+
+	_, err = mediatr.Send[*commands.UpdateMfaRulesResponse](ctx, m, commands.UpdateMfaRules{
+		VirtualServerName: vsName,
+		Rules:             rules,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
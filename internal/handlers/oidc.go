@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/subtle"
@@ -46,6 +48,11 @@ var (
 		ErrorDescription: "The Authorization Server requires End-User authentication",
 		ErrorUri:         "https://openid.net/specs/openid-connect-core-1_0.html#AuthError",
 	}
+	interactionRequired = OidcError{
+		Error:            "interaction_required",
+		ErrorDescription: "The Authorization Server requires End-User interaction of some form to proceed",
+		ErrorUri:         "https://openid.net/specs/openid-connect-core-1_0.html#AuthError",
+	}
 	unsupportedResponseType = OidcError{
 		Error:            "unsupported_response_type",
 		ErrorDescription: "The authorization server does not support obtaining an authorization code using this method.",
@@ -67,15 +74,25 @@ type Ed25519JWK struct {
 	X   string `json:"x"`   // Public key (base64url)
 }
 
-type RS256JWK struct {
+type RSAJWK struct {
 	Kty string `json:"kty"` // Key Type, e.g. "RSA"
-	Alg string `json:"alg"` // Algorithm, e.g. "RS256"
+	Alg string `json:"alg"` // Algorithm, e.g. "RS256" or "PS256"
 	Use string `json:"use"` // Public key use, usually "sig"
 	Kid string `json:"kid"` // Key ID
 	N   string `json:"n"`   // Modulus, base64url encoded
 	E   string `json:"e"`   // Exponent, base64url encoded
 }
 
+type ECJWK struct {
+	Kty string `json:"kty"` // Key Type, "EC"
+	Crv string `json:"crv"` // Curve, e.g. "P-256"
+	Alg string `json:"alg"` // Algorithm, e.g. "ES256"
+	Use string `json:"use"` // Use (sig = signature)
+	Kid string `json:"kid"` // Key ID
+	X   string `json:"x"`   // X coordinate (base64url)
+	Y   string `json:"y"`   // Y coordinate (base64url)
+}
+
 type JwksResponseDto struct {
 	Keys []any `json:"keys"`
 }
@@ -135,12 +152,14 @@ func WellKnownJwks(w http.ResponseWriter, r *http.Request) {
 	keys := make([]any, 0, len(allKeyPairs))
 
 	for _, keyPair := range allKeyPairs {
-		if !configuredAlgs[keyPair.Algorithm()] {
+		// imported verify-only keys keep tokens of a previous issuer valid,
+		// whatever algorithm it signed them with
+		if !configuredAlgs[keyPair.Algorithm()] && !keyPair.VerifyOnly() {
 			continue
 		}
 		kid := keyPair.GetKid()
-		switch keyPair.Algorithm() {
-		case config.SigningAlgorithmEdDSA:
+		switch publicKey := keyPair.PublicKey().(type) {
+		case ed25519.PublicKey:
 			keys = append(keys, Ed25519JWK{
 				Kty: "OKP",
 				Crv: "Ed25519",
@@ -150,23 +169,38 @@ func WellKnownJwks(w http.ResponseWriter, r *http.Request) {
 				X:   base64.RawURLEncoding.EncodeToString(keyPair.PublicKeyBytes()),
 			})
 
-		case config.SigningAlgorithmRS256:
-			rsaPublicKey := keyPair.PublicKey().(*rsa.PublicKey)
-
+		case *rsa.PublicKey:
 			eBytes := make([]byte, 8)
-			binary.BigEndian.PutUint64(eBytes, uint64(rsaPublicKey.E))
+			binary.BigEndian.PutUint64(eBytes, uint64(publicKey.E))
 
 			// trim leading zero bytes (JWK requires minimal representation)
 			eBytes = trimLeadingZeros(eBytes)
 
-			keys = append(keys, RS256JWK{
+			keys = append(keys, RSAJWK{
 				Kty: "RSA",
-				Alg: "RS256",
+				Alg: string(keyPair.Algorithm()),
 				Use: "sig",
 				Kid: kid,
-				N:   base64.RawURLEncoding.EncodeToString(rsaPublicKey.N.Bytes()),
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
 				E:   base64.RawURLEncoding.EncodeToString(eBytes),
 			})
+
+		case *ecdsa.PublicKey:
+			crv, x, y, err := services.ECPublicKeyCoordinates(publicKey)
+			if err != nil {
+				utils.HandleHttpError(w, err)
+				return
+			}
+
+			keys = append(keys, ECJWK{
+				Kty: "EC",
+				Crv: crv,
+				Alg: string(keyPair.Algorithm()),
+				Use: "sig",
+				Kid: kid,
+				X:   base64.RawURLEncoding.EncodeToString(x),
+				Y:   base64.RawURLEncoding.EncodeToString(y),
+			})
 		}
 	}
 
@@ -255,8 +289,8 @@ func WellKnownOpenIdConfiguration(w http.ResponseWriter, r *http.Request) {
 		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
 		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:device_code"},
 
-		ScopesSupported: []string{"openid", "email", "profile"}, // TODO: get from db
-		ClaimsSupported: []string{"sub", "name", "email"},       // TODO: get from db
+		ScopesSupported: []string{"openid", "email", "profile"},         // TODO: get from db
+		ClaimsSupported: []string{"sub", "name", "email", "acr", "amr"}, // TODO: get from db
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -280,6 +314,9 @@ type AuthorizationRequest struct {
 	ResponseMode        string
 	PKCEChallenge       string
 	PKCEChallengeMethod string
+	// AcrValues are the requested authentication context classes in order
+	// of preference.
+	AcrValues []string
 }
 
 // BeginAuthorizationFlow starts the OIDC authorization code flow.
@@ -297,6 +334,7 @@ type AuthorizationRequest struct {
 // @Param        response_mode          query    string false  "e.g. 'query'"
 // @Param        code_challenge         query    string false  "PKCE code challenge"
 // @Param        code_challenge_method  query    string false  "S256 or plain" Enums(S256,plain)
+// @Param        acr_values             query    string false  "Space-delimited acr values the login has to reach"
 // @Success      302  {string}  string  "Redirect to redirect_uri with code (& state)"
 // @Failure      400  {string}  string
 // @Router       /oidc/{virtualServerName}/authorize [get]
@@ -329,6 +367,7 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 		ResponseMode:        r.Form.Get("response_mode"),
 		PKCEChallenge:       r.Form.Get("code_challenge"),
 		PKCEChallengeMethod: r.Form.Get("code_challenge_method"),
+		AcrValues:           strings.Fields(r.Form.Get("acr_values")),
 	}
 
 	requestParam := r.Form.Get("request")
@@ -361,6 +400,14 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 			if claims["response_mode"] != nil {
 				authRequest.ResponseMode = claims["response_mode"].(string)
 			}
+			if claims["acr_values"] != nil {
+				acrValues, ok := claims["acr_values"].(string)
+				if !ok {
+					utils.HandleHttpError(w, fmt.Errorf("acr_values must be a string: %w", utils.ErrHttpBadRequest))
+					return
+				}
+				authRequest.AcrValues = strings.Fields(acrValues)
+			}
 		}
 
 	}
@@ -394,6 +441,11 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if application.Type() == repositories.ApplicationTypeSaml {
+		utils.HandleHttpError(w, fmt.Errorf("application is a saml service provider: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
 	if application.RedirectUris() == nil || len(application.RedirectUris()) == 0 {
 		utils.HandleHttpError(w, fmt.Errorf("application has no redirect uris"))
 		return
@@ -449,10 +501,56 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: check the scopes for email and profile
 
+	// acr values the application does not know are ignored
+	var acrLevels []repositories.AcrLevel
+	for _, acr := range authRequest.AcrValues {
+		acrLevel := application.AcrLevels().Find(acr)
+		if acrLevel != nil {
+			acrLevels = append(acrLevels, *acrLevel)
+		}
+	}
+
 	tokenService := ioc.GetDependency[services.TokenService](scope)
 
 	s, ok := middlewares.GetSession(ctx)
 	if ok {
+		authenticationMethods := utils.MapSlice(s.AuthenticationMethods(), func(method string) repositories.AuthenticationMethod {
+			return repositories.AuthenticationMethod(method)
+		})
+
+		// the first requested level the session already reaches is used,
+		// otherwise the user completes the methods of the preferred one
+		acr := ""
+		if len(acrLevels) > 0 {
+			for _, acrLevel := range acrLevels {
+				if len(repositories.MissingAuthenticationMethods(acrLevel.Methods, authenticationMethods)) == 0 {
+					acr = acrLevel.Acr
+					break
+				}
+			}
+
+			if acr == "" {
+				if prompt == "none" {
+					errorRedirect(w, r, authRequest, interactionRequired)
+					return
+				}
+
+				loginInfo := jsonTypes.NewLoginInfo(virtualServer, application, r.URL.String())
+				loginInfo.Step = jsonTypes.LoginStepStepUp
+				loginInfo.UserId = s.UserId()
+				loginInfo.AuthenticationMethods = authenticationMethods
+				loginInfo.RequiredAuthenticationMethods = acrLevels[0].Methods
+				loginInfo.Step, err = DetermineNextLoginStep(ctx, &loginInfo)
+				if err != nil {
+					utils.HandleHttpError(w, fmt.Errorf("determining step-up login step: %w", err))
+					return
+				}
+
+				startLogin(w, r, loginInfo)
+				return
+			}
+		}
+
 		// TODO: consent page
 
 		codeInfo := jsonTypes.NewCodeInfo(
@@ -465,6 +563,8 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 			authRequest.RedirectUri,
 			authRequest.PKCEChallenge,
 			authRequest.PKCEChallengeMethod,
+			acr,
+			authenticationMethods,
 		)
 
 		codeInfoString, err := json.Marshal(codeInfo)
@@ -504,11 +604,30 @@ func BeginAuthorizationFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	loginInfo := jsonTypes.NewLoginInfo(
-		virtualServer,
-		application,
-		r.URL.String(),
-	)
+	loginInfo := jsonTypes.NewLoginInfo(virtualServer, application, r.URL.String())
+	if len(acrLevels) > 0 {
+		loginInfo.RequiredAuthenticationMethods = acrLevels[0].Methods
+	}
+	startLogin(w, r, loginInfo)
+}
+
+// redirectToLogin starts a new login flow that resumes at originalUrl once
+// the user is authenticated.
+func redirectToLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	virtualServer *repositories.VirtualServer,
+	application *repositories.Application,
+	originalUrl string,
+) {
+	startLogin(w, r, jsonTypes.NewLoginInfo(virtualServer, application, originalUrl))
+}
+
+// startLogin stores the login info and redirects to the login UI.
+func startLogin(w http.ResponseWriter, r *http.Request, loginInfo jsonTypes.LoginInfo) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	tokenService := ioc.GetDependency[services.TokenService](scope)
 
 	loginInfoString, err := json.Marshal(loginInfo)
 	if err != nil {
@@ -597,7 +716,8 @@ func OidcEndSession(w http.ResponseWriter, r *http.Request) {
 
 	idToken, err := jwt.Parse(idTokenString, func(token *jwt.Token) (interface{}, error) {
 		alg := config.SigningAlgorithm(token.Method.Alg())
-		keyPair, err := keyService.GetKey(vsName, alg)
+		kid, _ := token.Header["kid"].(string)
+		keyPair, err := keyService.GetVerificationKey(vsName, alg, kid)
 		if err != nil {
 			return nil, fmt.Errorf("getting key: %w", err)
 		}
@@ -771,7 +891,8 @@ func OidcUserinfo(w http.ResponseWriter, r *http.Request) {
 
 	tokenJwt, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		alg := config.SigningAlgorithm(token.Method.Alg())
-		keyPair, err := keyService.GetKey(vsName, alg)
+		kid, _ := token.Header["kid"].(string)
+		keyPair, err := keyService.GetVerificationKey(vsName, alg, kid)
 		if err != nil {
 			return nil, fmt.Errorf("getting key: %w", err)
 		}
@@ -800,7 +921,7 @@ func OidcUserinfo(w http.ResponseWriter, r *http.Request) {
 		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
 		return
 	}
-	if user == nil {
+	if user == nil || user.Disabled() {
 		utils.HandleHttpError(w, fmt.Errorf("user not found"))
 		return
 	}
@@ -1083,7 +1204,7 @@ func handleAuthorizationCode(w http.ResponseWriter, r *http.Request) {
 		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
 		return
 	}
-	if user == nil {
+	if user == nil || user.Disabled() {
 		utils.HandleHttpError(w, fmt.Errorf("user not found"))
 		return
 	}
@@ -1119,6 +1240,8 @@ func handleAuthorizationCode(w http.ResponseWriter, r *http.Request) {
 		Nonce:                 codeInfo.Nonce,
 		AuthenticatedAt:       codeInfo.AuthenticatedAt,
 		AccessTokenHeaderType: application.AccessTokenHeaderType(),
+		Acr:                   codeInfo.Acr,
+		AuthenticationMethods: codeInfo.AuthenticationMethods,
 	}
 
 	tokens, err := generateTokens(ctx, params, tokenService)
@@ -1160,6 +1283,23 @@ func appSigningAlgorithm(vs *repositories.VirtualServer, app *repositories.Appli
 	return vs.PrimarySigningAlgorithm()
 }
 
+// signJwt signs the token with the signer of the key pair instead of passing
+// the private key to the jwt library, so that keys which never leave an
+// external key store can sign tokens too.
+func signJwt(token *jwt.Token, keyPair services.KeyPair) (string, error) {
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", fmt.Errorf("encoding token: %w", err)
+	}
+
+	signature, err := keyPair.Sign([]byte(signingString))
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+
+	return signingString + "." + token.EncodeSegment(signature), nil
+}
+
 func getJwtSigningMethod(algorithm config.SigningAlgorithm) (jwt.SigningMethod, error) {
 	switch algorithm {
 	case config.SigningAlgorithmEdDSA:
@@ -1168,6 +1308,24 @@ func getJwtSigningMethod(algorithm config.SigningAlgorithm) (jwt.SigningMethod,
 	case config.SigningAlgorithmRS256:
 		return jwt.SigningMethodRS256, nil
 
+	case config.SigningAlgorithmRS384:
+		return jwt.SigningMethodRS384, nil
+
+	case config.SigningAlgorithmRS512:
+		return jwt.SigningMethodRS512, nil
+
+	case config.SigningAlgorithmPS256:
+		return jwt.SigningMethodPS256, nil
+
+	case config.SigningAlgorithmES256:
+		return jwt.SigningMethodES256, nil
+
+	case config.SigningAlgorithmES384:
+		return jwt.SigningMethodES384, nil
+
+	case config.SigningAlgorithmES512:
+		return jwt.SigningMethodES512, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
 	}
@@ -1198,6 +1356,8 @@ type TokenGenerationParams struct {
 	Nonce                 string
 	AuthenticatedAt       time.Time
 	AccessTokenHeaderType string
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 func (t *TokenGenerationParams) ToAccessTokenGenerationParams() AccessTokenGenerationParams {
@@ -1212,6 +1372,9 @@ func (t *TokenGenerationParams) ToAccessTokenGenerationParams() AccessTokenGener
 		UserId:            t.UserId,
 		KeyPair:           t.KeyPair,
 		HeaderType:        t.AccessTokenHeaderType,
+
+		Acr:                   t.Acr,
+		AuthenticationMethods: t.AuthenticationMethods,
 	}
 }
 
@@ -1228,6 +1391,9 @@ func (t *TokenGenerationParams) ToIdTokenGenerationParams() IdTokenGenerationPar
 		UserId:            t.UserId,
 		KeyPair:           t.KeyPair,
 		AuthenticatedAt:   t.AuthenticatedAt,
+
+		Acr:                   t.Acr,
+		AuthenticationMethods: t.AuthenticationMethods,
 	}
 }
 
@@ -1237,6 +1403,10 @@ func (t *TokenGenerationParams) ToRefreshTokenGenerationParams() RefreshTokenGen
 		GrantedScopes:     t.GrantedScopes,
 		ClientId:          t.ClientId,
 		UserId:            t.UserId,
+		IssuedAt:          t.IssuedAt,
+
+		Acr:                   t.Acr,
+		AuthenticationMethods: t.AuthenticationMethods,
 	}
 }
 
@@ -1245,6 +1415,10 @@ type RefreshTokenGenerationParams struct {
 	GrantedScopes     []string
 	ClientId          string
 	UserId            uuid.UUID
+	IssuedAt          time.Time
+
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 type AccessTokenGenerationParams struct {
@@ -1258,6 +1432,9 @@ type AccessTokenGenerationParams struct {
 	UserId            uuid.UUID
 	KeyPair           services.KeyPair
 	HeaderType        string
+
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 type IdTokenGenerationParams struct {
@@ -1272,6 +1449,9 @@ type IdTokenGenerationParams struct {
 	KeyPair           services.KeyPair
 	GrantedScopes     []string
 	AuthenticatedAt   time.Time
+
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 type GeneratedTokens struct {
@@ -1309,9 +1489,11 @@ func generateIdToken(params IdTokenGenerationParams) (string, error) {
 		idTokenClaims["nonce"] = params.Nonce
 	}
 
+	addAuthenticationClaims(idTokenClaims, params.Acr, params.AuthenticationMethods)
+
 	idToken := jwt.NewWithClaims(jwtSigningMethod, idTokenClaims)
 	idToken.Header["kid"] = kid
-	return idToken.SignedString(params.KeyPair.PrivateKey())
+	return signJwt(idToken, params.KeyPair)
 }
 
 func generateAccessToken(ctx context.Context, params AccessTokenGenerationParams) (string, error) {
@@ -1333,11 +1515,24 @@ func generateAccessToken(ctx context.Context, params AccessTokenGenerationParams
 	accessTokenClaims["scopes"] = params.GrantedScopes
 	accessTokenClaims["iat"] = params.IssuedAt.Unix()
 	accessTokenClaims["exp"] = params.IssuedAt.Add(params.Expiry).Unix()
+	addAuthenticationClaims(accessTokenClaims, params.Acr, params.AuthenticationMethods)
 
 	accessToken := jwt.NewWithClaims(jwtSigningMethod, accessTokenClaims)
 	accessToken.Header["kid"] = kid
 	accessToken.Header["typ"] = params.HeaderType
-	return accessToken.SignedString(params.KeyPair.PrivateKey())
+	return signJwt(accessToken, params.KeyPair)
+}
+
+// addAuthenticationClaims adds the "acr" and "amr" claims of the login the
+// tokens are issued for. Logins without recorded methods, e.g. of sessions
+// created before they were tracked, get neither.
+func addAuthenticationClaims(claims jwt.MapClaims, acr string, authenticationMethods []repositories.AuthenticationMethod) {
+	if acr != "" {
+		claims["acr"] = acr
+	}
+	if len(authenticationMethods) > 0 {
+		claims["amr"] = repositories.AmrClaim(authenticationMethods)
+	}
 }
 
 func mapClaims(ctx context.Context, params AccessTokenGenerationParams) (jwt.MapClaims, error) {
@@ -1424,6 +1619,9 @@ func generateRefreshTokenInfo(params RefreshTokenGenerationParams) (string, erro
 		params.ClientId,
 		params.UserId,
 		params.GrantedScopes,
+		params.IssuedAt,
+		params.Acr,
+		params.AuthenticationMethods,
 	)
 	refreshTokenInfoJson, err := json.Marshal(refreshTokenInfo)
 	if err != nil {
@@ -1513,6 +1711,16 @@ func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	revokedAt, err := tokenService.RefreshTokensRevokedAt(ctx, refreshTokenInfo.UserId)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	if revokedAt != nil && !refreshTokenInfo.IssuedAt.After(*revokedAt) {
+		writeOAuthError(w, "invalid_grant", "refresh token was revoked")
+		return
+	}
+
 	err = tokenService.DeleteToken(ctx, services.OidcRefreshTokenTokenType, r.Form.Get("refresh_token"))
 	if err != nil {
 		utils.HandleHttpError(w, fmt.Errorf("deleting refresh token: %w", err))
@@ -1525,7 +1733,7 @@ func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
 		return
 	}
-	if user == nil {
+	if user == nil || user.Disabled() {
 		utils.HandleHttpError(w, fmt.Errorf("user not found"))
 		return
 	}
@@ -1570,6 +1778,8 @@ func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 		IdTokenExpiry:         tokenDuration,
 		RefreshTokenExpiry:    tokenDuration,
 		AccessTokenHeaderType: application.AccessTokenHeaderType(),
+		Acr:                   refreshTokenInfo.Acr,
+		AuthenticationMethods: refreshTokenInfo.AuthenticationMethods,
 	}
 
 	tokens, err := generateTokens(ctx, params, tokenService)
@@ -2061,7 +2271,7 @@ func handleDeviceCodeGrant(w http.ResponseWriter, r *http.Request) {
 		utils.HandleHttpError(w, fmt.Errorf("getting user: %w", err))
 		return
 	}
-	if user == nil {
+	if user == nil || user.Disabled() {
 		utils.HandleHttpError(w, fmt.Errorf("user not found"))
 		return
 	}
@@ -2093,6 +2303,7 @@ func handleDeviceCodeGrant(w http.ResponseWriter, r *http.Request) {
 		IdTokenExpiry:         tokenDuration,
 		RefreshTokenExpiry:    tokenDuration,
 		AccessTokenHeaderType: application.AccessTokenHeaderType(),
+		AuthenticationMethods: deviceCodeInfo.AuthenticationMethods,
 	}
 
 	tokens, err := generateTokens(ctx, params, tokenService)
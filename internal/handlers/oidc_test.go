@@ -68,7 +68,7 @@ func newTestContext(t *testing.T) context.Context {
 }
 
 func newDefaultParams(algorithm config.SigningAlgorithm) TokenGenerationParams {
-	keyPair, err := services.GetKeyStrategy(algorithm).Generate(clock.NewSystemClock())
+	keyPair, err := services.GetKeyStrategy(algorithm).Generate(clock.NewSystemClock(), repositories.DefaultKeyRotationPolicy())
 	if err != nil {
 		panic(err)
 	}
@@ -158,6 +158,43 @@ func TestGenerateIdToken_HasExpectedClaims(t *testing.T) {
 	assert.Equal(t, now.Add(time.Hour).Unix(), int64(claims["exp"].(float64)))
 }
 
+func TestGenerateIdToken_HasAuthenticationClaims(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	params := newDefaultParams(config.SigningAlgorithmEdDSA)
+	params.Acr = "urn:bank:transfer"
+	params.AuthenticationMethods = []repositories.AuthenticationMethod{
+		repositories.AuthenticationMethodPassword,
+		repositories.AuthenticationMethodOtp,
+	}
+
+	// Act
+	tokenString, _ := generateIdToken(params.ToIdTokenGenerationParams())
+	token := parseToken(t, tokenString, params.KeyPair.PublicKey())
+	claims := token.Claims.(jwt.MapClaims)
+
+	// Assert
+	assert.Equal(t, "urn:bank:transfer", claims["acr"])
+	assert.Equal(t, []interface{}{"pwd", "otp", "mfa"}, claims["amr"])
+}
+
+func TestGenerateIdToken_OmitsAuthenticationClaimsWithoutMethods(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	params := newDefaultParams(config.SigningAlgorithmEdDSA)
+
+	// Act
+	tokenString, _ := generateIdToken(params.ToIdTokenGenerationParams())
+	token := parseToken(t, tokenString, params.KeyPair.PublicKey())
+	claims := token.Claims.(jwt.MapClaims)
+
+	// Assert
+	assert.NotContains(t, claims, "acr")
+	assert.NotContains(t, claims, "amr")
+}
+
 func TestGenerateIdToken_HasExpectedHeaders(t *testing.T) {
 	t.Parallel()
 
@@ -223,6 +260,28 @@ func TestGenerateAccessToken_HasExpectedClaims(t *testing.T) {
 	assert.Equal(t, now.Add(time.Hour).Unix(), int64(claims["exp"].(float64)))
 }
 
+func TestGenerateAccessToken_HasAuthenticationClaims(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctx := newTestContext(t)
+
+	params := newDefaultParams(config.SigningAlgorithmEdDSA)
+	params.Acr = "urn:bank:transfer"
+	params.AuthenticationMethods = []repositories.AuthenticationMethod{
+		repositories.AuthenticationMethodHardwareKey,
+	}
+
+	// Act
+	tokenString, _ := generateAccessToken(ctx, params.ToAccessTokenGenerationParams())
+	token := parseToken(t, tokenString, params.KeyPair.PublicKey())
+	claims := token.Claims.(jwt.MapClaims)
+
+	// Assert
+	assert.Equal(t, "urn:bank:transfer", claims["acr"])
+	assert.Equal(t, []interface{}{"hwk"}, claims["amr"])
+}
+
 func TestGenerateAccessToken_HasExpectedHeaders(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"net/http"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// applicationPath returns the project slug and the application id of routes
+// nested below an application.
+func applicationPath(r *http.Request) (string, uuid.UUID, error) {
+	vars := mux.Vars(r)
+
+	appId, err := uuid.Parse(vars["appId"])
+	if err != nil {
+		return "", uuid.Nil, utils.ErrInvalidUuid
+	}
+
+	return vars["projectSlug"], appId, nil
+}
+
+// GetProvisioningTarget
+// @summary     Get provisioning target
+// @description Retrieve the SCIM provisioning target of an application together with its delivery status. The secret is never returned.
+// @tags        Provisioning
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       projectSlug  path   string  true  "Project slug"
+// @param       appId  path   string  true  "Application ID (UUID)"
+// @success     200 {object} api.GetProvisioningTargetResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/projects/{projectSlug}/applications/{appId}/provisioning [get]
+func GetProvisioningTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	projectSlug, appId, err := applicationPath(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	target, err := mediatr.Send[*queries.GetProvisioningTargetResponse](ctx, m, queries.GetProvisioningTarget{
+		VirtualServerName: vsName,
+		ProjectSlug:       projectSlug,
+		ApplicationId:     appId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	attributeMapping := make(map[string]string, len(target.AttributeMapping))
+	for attribute, source := range target.AttributeMapping {
+		attributeMapping[attribute] = string(source)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.GetProvisioningTargetResponseDto{
+		Id:               target.Id,
+		Url:              target.Url,
+		AuthType:         string(target.AuthType),
+		AuthUsername:     target.AuthUsername,
+		AttributeMapping: attributeMapping,
+		Enabled:          target.Enabled,
+		LastSyncAt:       target.LastSyncAt,
+		LastError:        target.LastError,
+		LastErrorAt:      target.LastErrorAt,
+		CreatedAt:        target.CreatedAt,
+		UpdatedAt:        target.UpdatedAt,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// SetProvisioningTarget
+// @summary     Set provisioning target
+// @description Create or replace the SCIM endpoint that users of the application are pushed to. Users holding a role in the application's project are created, updated and deleted there as they change in Keyline.
+// @tags        Provisioning
+// @accept      application/json
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       projectSlug  path   string  true  "Project slug"
+// @param       appId  path   string  true  "Application ID (UUID)"
+// @param       body  body   api.SetProvisioningTargetRequestDto  true  "Provisioning target"
+// @success     200 {object} api.SetProvisioningTargetResponseDto
+// @success     201 {object} api.SetProvisioningTargetResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/projects/{projectSlug}/applications/{appId}/provisioning [put]
+func SetProvisioningTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	projectSlug, appId, err := applicationPath(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.SetProvisioningTargetRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var attributeMapping repositories.ProvisioningAttributeMapping
+	if dto.AttributeMapping != nil {
+		attributeMapping = make(repositories.ProvisioningAttributeMapping, len(dto.AttributeMapping))
+		for attribute, source := range dto.AttributeMapping {
+			attributeMapping[attribute] = repositories.ProvisioningSource(source)
+		}
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*commands.SetProvisioningTargetResponse](ctx, m, commands.SetProvisioningTarget{
+		VirtualServerName: vsName,
+		ProjectSlug:       projectSlug,
+		ApplicationId:     appId,
+		Url:               dto.Url,
+		AuthType:          repositories.ProvisioningAuthType(dto.AuthType),
+		AuthUsername:      dto.AuthUsername,
+		AuthSecret:        dto.AuthSecret,
+		AttributeMapping:  attributeMapping,
+		Enabled:           dto.Enabled,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Created {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	err = json.NewEncoder(w).Encode(api.SetProvisioningTargetResponseDto{
+		Id: response.Id,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// DeleteProvisioningTarget
+// @summary     Delete provisioning target
+// @description Stop provisioning the application. Users already pushed to the service provider are left there.
+// @tags        Provisioning
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       projectSlug  path   string  true  "Project slug"
+// @param       appId  path   string  true  "Application ID (UUID)"
+// @success     204
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/projects/{projectSlug}/applications/{appId}/provisioning [delete]
+func DeleteProvisioningTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	projectSlug, appId, err := applicationPath(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.DeleteProvisioningTargetResponse](ctx, m, commands.DeleteProvisioningTarget{
+		VirtualServerName: vsName,
+		ProjectSlug:       projectSlug,
+		ApplicationId:     appId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResyncProvisioningTarget
+// @summary     Resync provisioning target
+// @description Queue every user of the virtual server for delivery to the provisioning target of the application. Delivery happens in the background.
+// @tags        Provisioning
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @param       projectSlug  path   string  true  "Project slug"
+// @param       appId  path   string  true  "Application ID (UUID)"
+// @success     202 {object} api.ResyncProvisioningTargetResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @failure     404  {string}  string "Not Found"
+// @router      /api/virtual-servers/{virtualServerName}/projects/{projectSlug}/applications/{appId}/provisioning/resync [post]
+func ResyncProvisioningTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	projectSlug, appId, err := applicationPath(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	response, err := mediatr.Send[*commands.ResyncProvisioningTargetResponse](ctx, m, commands.ResyncProvisioningTarget{
+		VirtualServerName: vsName,
+		ProjectSlug:       projectSlug,
+		ApplicationId:     appId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	err = json.NewEncoder(w).Encode(api.ResyncProvisioningTargetResponseDto{
+		QueuedUsers: response.QueuedUsers,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// ListProvisioningTargets
+// @summary     List provisioning targets
+// @description Retrieve the provisioning targets of all applications of a virtual server with their last delivery and last error.
+// @tags        Provisioning
+// @produce     application/json
+// @param       virtualServerName  path   string  true  "Virtual server name"  default(keyline)
+// @success     200 {object} api.ListProvisioningTargetsResponseDto
+// @failure     400  {string}  string "Bad Request"
+// @router      /api/virtual-servers/{virtualServerName}/provisioning-targets [get]
+func ListProvisioningTargets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	targets, err := mediatr.Send[*queries.ListProvisioningTargetsResponse](ctx, m, queries.ListProvisioningTargets{
+		VirtualServerName: vsName,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	response := api.ListProvisioningTargetsResponseDto{
+		Items: make([]api.ListProvisioningTargetsResponseItemDto, 0, len(targets.Items)),
+	}
+	for _, item := range targets.Items {
+		response.Items = append(response.Items, api.ListProvisioningTargetsResponseItemDto{
+			Id:              item.Id,
+			ApplicationId:   item.ApplicationId,
+			ApplicationName: item.ApplicationName,
+			Url:             item.Url,
+			Enabled:         item.Enabled,
+			LastSyncAt:      item.LastSyncAt,
+			LastError:       item.LastError,
+			LastErrorAt:     item.LastErrorAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
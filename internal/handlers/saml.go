@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/saml"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+const samlAssertionValidity = 5 * time.Minute
+
+func samlEntityId(vsName string) string {
+	return fmt.Sprintf("%s/saml/%s", config.C.Server.ExternalUrl, vsName)
+}
+
+func samlSloUrl(vsName string) string {
+	return samlEntityId(vsName) + "/slo"
+}
+
+// getSamlSigner returns a signer backed by the virtual server's current RS256
+// key together with a certificate wrapping its public key.
+func getSamlSigner(keyService services.KeyService, vsName string) (*saml.Signer, services.KeyPair, error) {
+	keyPair, err := keyService.GetKey(vsName, config.SigningAlgorithmRS256)
+	if err != nil {
+		return nil, services.KeyPair{}, fmt.Errorf("getting rs256 key: %w", err)
+	}
+
+	signer, err := keyPair.Signer()
+	if err != nil {
+		return nil, services.KeyPair{}, fmt.Errorf("getting rs256 signer: %w", err)
+	}
+
+	certificate, err := saml.Certificate(samlEntityId(vsName), signer, keyPair.CreatedAt(), keyPair.ExpiresAt())
+	if err != nil {
+		return nil, services.KeyPair{}, fmt.Errorf("creating certificate: %w", err)
+	}
+
+	return saml.NewSigner(signer, certificate), keyPair, nil
+}
+
+func getSamlApplication(r *http.Request, virtualServer *repositories.VirtualServer, entityId string) (*repositories.Application, error) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	applicationFilter := repositories.NewApplicationFilter().
+		VirtualServerId(virtualServer.Id()).
+		SamlEntityId(entityId)
+	application, err := dbContext.Applications().FirstOrNil(ctx, applicationFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting application: %w", err)
+	}
+
+	if application == nil || application.Type() != repositories.ApplicationTypeSaml {
+		return nil, fmt.Errorf("unknown service provider %q: %w", entityId, utils.ErrHttpBadRequest)
+	}
+
+	return application, nil
+}
+
+func getRequestVirtualServer(r *http.Request) (*repositories.VirtualServer, error) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	return virtualServer, nil
+}
+
+// decodeSamlMessage reads a saml message from either the HTTP-Redirect
+// (deflated, query string) or the HTTP-POST (form body) binding.
+func decodeSamlMessage(r *http.Request, field string) ([]byte, error) {
+	value := r.Form.Get(field)
+	if value == "" {
+		return nil, fmt.Errorf("missing %s: %w", field, utils.ErrHttpBadRequest)
+	}
+
+	var data []byte
+	var err error
+	if r.Method == http.MethodPost {
+		data, err = saml.DecodePost(value)
+	} else {
+		data, err = saml.DecodeRedirect(value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v: %w", field, err, utils.ErrHttpBadRequest)
+	}
+
+	return data, nil
+}
+
+// verifySamlSignature checks that a message was signed with the certificate
+// registered for the service provider and returns the signed content.
+func verifySamlSignature(r *http.Request, application *repositories.Application, field string, data []byte) ([]byte, error) {
+	certificatePem := application.SamlCertificate()
+	if certificatePem == nil {
+		return nil, fmt.Errorf("service provider has no signing certificate: %w", utils.ErrHttpBadRequest)
+	}
+
+	certificate, err := saml.ParseCertificate(*certificatePem)
+	if err != nil {
+		return nil, fmt.Errorf("parsing service provider certificate: %w", err)
+	}
+
+	if r.Method == http.MethodPost {
+		signed, err := saml.VerifyPostSignature(data, certificate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v: %w", field, err, utils.ErrHttpBadRequest)
+		}
+		return signed, nil
+	}
+
+	err = saml.VerifyRedirectSignature(r.URL.RawQuery, field, certificate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", field, err, utils.ErrHttpBadRequest)
+	}
+
+	return data, nil
+}
+
+// SamlMetadata returns the SAML 2.0 identity provider metadata.
+// @Summary      SAML metadata
+// @Tags         SAML
+// @Produce      xml
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Success      200  {string}  string
+// @Failure      404  {string}  string
+// @Router       /saml/{virtualServerName}/metadata [get]
+func SamlMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	virtualServer, err := getRequestVirtualServer(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	signer, keyPair, err := getSamlSigner(keyService, virtualServer.Name())
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	entityId := samlEntityId(virtualServer.Name())
+	metadata, err := signer.BuildMetadata(saml.MetadataParams{
+		EntityId:   entityId,
+		SsoUrl:     entityId + "/sso",
+		SloUrl:     samlSloUrl(virtualServer.Name()),
+		ValidUntil: keyPair.ExpiresAt(),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(metadata)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// SamlSingleSignOn handles SP-initiated SSO.
+// @Summary      SAML single sign-on
+// @Description  Accepts an AuthnRequest via the HTTP-Redirect or HTTP-POST binding. If the user is not authenticated, redirects to the login UI; otherwise posts a signed Response to the service provider's assertion consumer service.
+// @Tags         SAML
+// @Produce      html
+// @Param        virtualServerName  path   string  true   "Virtual server name"  default(keyline)
+// @Param        SAMLRequest        query  string  true   "Encoded AuthnRequest"
+// @Param        RelayState         query  string  false  "Opaque value returned to the service provider"
+// @Success      200  {string}  string
+// @Failure      400  {string}  string
+// @Router       /saml/{virtualServerName}/sso [get]
+// @Router       /saml/{virtualServerName}/sso [post]
+func SamlSingleSignOn(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	virtualServer, err := getRequestVirtualServer(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	data, err := decodeSamlMessage(r, "SAMLRequest")
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	authnRequest, err := saml.ParseAuthnRequest(data)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("%v: %w", err, utils.ErrHttpBadRequest))
+		return
+	}
+
+	application, err := getSamlApplication(r, virtualServer, authnRequest.Issuer)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// only the registered acs url is accepted, otherwise anyone could make
+	// us post assertions to arbitrary endpoints
+	acsUrl := utils.ZeroIfNil(application.SamlAcsUrl())
+	if authnRequest.AssertionConsumerServiceURL != "" && authnRequest.AssertionConsumerServiceURL != acsUrl {
+		utils.HandleHttpError(w, fmt.Errorf("assertion consumer service url does not match: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	relayState := r.Form.Get("RelayState")
+
+	if _, ok := middlewares.GetSession(r.Context()); !ok {
+		// the login flow resumes with a GET, so the request is re-encoded
+		// for the redirect binding regardless of how it arrived
+		encoded, err := saml.EncodeRedirect(data)
+		if err != nil {
+			utils.HandleHttpError(w, err)
+			return
+		}
+
+		query := url.Values{}
+		query.Set("SAMLRequest", encoded)
+		if relayState != "" {
+			query.Set("RelayState", relayState)
+		}
+		originalUrl := fmt.Sprintf("/saml/%s/sso?%s", virtualServer.Name(), query.Encode())
+
+		redirectToLogin(w, r, virtualServer, application, originalUrl)
+		return
+	}
+
+	issueSamlResponse(w, r, virtualServer, application, authnRequest.ID, relayState)
+}
+
+// SamlIdpInitiatedSignOn handles IdP-initiated SSO.
+// @Summary      SAML IdP-initiated sign-on
+// @Description  Posts an unsolicited signed Response to the service provider identified by its application name.
+// @Tags         SAML
+// @Produce      html
+// @Param        virtualServerName  path   string  true   "Virtual server name"  default(keyline)
+// @Param        app                query  string  true   "Application name"
+// @Param        RelayState         query  string  false  "Opaque value forwarded to the service provider"
+// @Success      200  {string}  string
+// @Failure      400  {string}  string
+// @Router       /saml/{virtualServerName}/sso/init [get]
+func SamlIdpInitiatedSignOn(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServer, err := getRequestVirtualServer(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	applicationFilter := repositories.NewApplicationFilter().
+		VirtualServerId(virtualServer.Id()).
+		Name(r.URL.Query().Get("app"))
+	application, err := dbContext.Applications().FirstOrNil(ctx, applicationFilter)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("getting application: %w", err))
+		return
+	}
+	if application == nil || application.Type() != repositories.ApplicationTypeSaml {
+		utils.HandleHttpError(w, utils.ErrApplicationNotFound)
+		return
+	}
+
+	if _, ok := middlewares.GetSession(ctx); !ok {
+		redirectToLogin(w, r, virtualServer, application, r.URL.String())
+		return
+	}
+
+	issueSamlResponse(w, r, virtualServer, application, "", r.URL.Query().Get("RelayState"))
+}
+
+func issueSamlResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	virtualServer *repositories.VirtualServer,
+	application *repositories.Application,
+	inResponseTo string,
+	relayState string,
+) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	session, ok := middlewares.GetSession(ctx)
+	if !ok {
+		utils.HandleHttpError(w, utils.ErrHttpUnauthorized)
+		return
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	signer, _, err := getSamlSigner(keyService, virtualServer.Name())
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	attributes, err := mapClaims(ctx, AccessTokenGenerationParams{
+		UserId:        session.UserId(),
+		ApplicationId: application.Id(),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("mapping attributes: %w", err))
+		return
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	acsUrl := utils.ZeroIfNil(application.SamlAcsUrl())
+
+	response, err := signer.BuildResponse(saml.ResponseParams{
+		IdpEntityId:  samlEntityId(virtualServer.Name()),
+		SpEntityId:   utils.ZeroIfNil(application.SamlEntityId()),
+		Destination:  acsUrl,
+		InResponseTo: inResponseTo,
+		NameId:       session.UserId().String(),
+		NameIdFormat: saml.NameIdFormatPersistent,
+		SessionIndex: session.SessionId().String(),
+		IssuedAt:     clockService.Now(),
+		AuthnInstant: session.CreatedAt(),
+		Validity:     samlAssertionValidity,
+		Attributes:   attributes,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("building saml response: %w", err))
+		return
+	}
+
+	err = saml.WritePostForm(w, acsUrl, "SAMLResponse", response, relayState)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// SamlSingleLogout handles SP-initiated single logout.
+// @Summary      SAML single logout
+// @Description  Accepts a recent LogoutRequest addressed to this endpoint and signed with the service provider's certificate via the HTTP-Redirect or HTTP-POST binding, ends the session it names and posts a signed LogoutResponse to the service provider.
+// @Tags         SAML
+// @Produce      html
+// @Param        virtualServerName  path   string  true   "Virtual server name"  default(keyline)
+// @Param        SAMLRequest        query  string  true   "Encoded LogoutRequest"
+// @Param        RelayState         query  string  false  "Opaque value returned to the service provider"
+// @Success      200  {string}  string
+// @Failure      400  {string}  string
+// @Router       /saml/{virtualServerName}/slo [get]
+// @Router       /saml/{virtualServerName}/slo [post]
+func SamlSingleLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	err := r.ParseForm()
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	virtualServer, err := getRequestVirtualServer(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	data, err := decodeSamlMessage(r, "SAMLRequest")
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	logoutRequest, err := saml.ParseLogoutRequest(data)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("%v: %w", err, utils.ErrHttpBadRequest))
+		return
+	}
+
+	application, err := getSamlApplication(r, virtualServer, logoutRequest.Issuer)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// any page can send the browser here, so only requests signed by the
+	// service provider are allowed to end a session
+	signed, err := verifySamlSignature(r, application, "SAMLRequest", data)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	logoutRequest, err = saml.ParseLogoutRequest(signed)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("%v: %w", err, utils.ErrHttpBadRequest))
+		return
+	}
+	if logoutRequest.Issuer != utils.ZeroIfNil(application.SamlEntityId()) {
+		utils.HandleHttpError(w, fmt.Errorf("logout request issuer does not match: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	err = logoutRequest.Validate(samlSloUrl(virtualServer.Name()), clockService.Now())
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("%v: %w", err, utils.ErrHttpBadRequest))
+		return
+	}
+
+	// only end the session when it is the one the service provider was
+	// issued, the session index of our assertions is the session id
+	session, ok := middlewares.GetSession(ctx)
+	if ok &&
+		session.UserId().String() == logoutRequest.NameID &&
+		slices.Contains(logoutRequest.SessionIndex, session.SessionId().String()) {
+		err = middlewares.DeleteSession(w, r, virtualServer.Name())
+		if err != nil {
+			utils.HandleHttpError(w, err)
+			return
+		}
+	}
+
+	sloUrl := application.SamlSloUrl()
+	if sloUrl == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	signer, _, err := getSamlSigner(keyService, virtualServer.Name())
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	response, err := signer.BuildLogoutResponse(saml.LogoutResponseParams{
+		IdpEntityId:  samlEntityId(virtualServer.Name()),
+		Destination:  *sloUrl,
+		InResponseTo: logoutRequest.ID,
+		IssuedAt:     clockService.Now(),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("building logout response: %w", err))
+		return
+	}
+
+	err = saml.WritePostForm(w, *sloUrl, "SAMLResponse", response, r.Form.Get("RelayState"))
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
@@ -0,0 +1,999 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/scim"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func scimBaseUrl(vsName string) string {
+	return fmt.Sprintf("%s/scim/v2/%s", config.C.Server.ExternalUrl, vsName)
+}
+
+func writeScimResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logging.Logger.Errorf("writing scim response: %v", err)
+	}
+}
+
+// toScimError maps the errors of the mediator pipeline onto SCIM errors.
+func toScimError(err error) *scim.Error {
+	var scimErr *scim.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &scimErr):
+		return scimErr
+
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, err.Error())
+
+	case errors.Is(err, utils.ErrHttpBadRequest):
+		return scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, err.Error())
+
+	case errors.Is(err, utils.ErrHttpUnauthorized):
+		return scim.NewError(http.StatusUnauthorized, "", err.Error())
+
+	case errors.Is(err, utils.ErrHttpNotFound):
+		return scim.NewError(http.StatusNotFound, "", err.Error())
+
+	case errors.Is(err, utils.ErrHttpConflict):
+		return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, err.Error())
+
+	default:
+		detail := err.Error()
+		if config.IsProduction() {
+			detail = "internal server error"
+		}
+		return scim.NewError(http.StatusInternalServerError, "", detail)
+	}
+}
+
+// logScimError logs client errors at warn level, they are expected
+// results of provisioning requests.
+func logScimError(message string, status int, err error) {
+	if status < http.StatusInternalServerError {
+		logging.Logger.Warnf("%s with status %d: %v", message, status, err)
+		return
+	}
+	logging.Logger.Errorf("%s with status %d: %v", message, status, err)
+}
+
+func handleScimError(w http.ResponseWriter, err error) {
+	scimErr := toScimError(err)
+	logScimError("scim request failed", scimErr.Status, err)
+	writeScimResponse(w, scimErr.Status, scimErr)
+}
+
+func scimResourceId(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return uuid.Nil, scim.NewError(http.StatusNotFound, "", "resource not found")
+	}
+	return id, nil
+}
+
+func decodeScimBody[T any](data []byte) (*T, error) {
+	var body T
+	err := json.Unmarshal(data, &body)
+	if err != nil {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, err.Error())
+	}
+	return &body, nil
+}
+
+// scimListPage reads startIndex and count of a list request.
+func scimListPage(r *http.Request) (page int, size int, startIndex int) {
+	startIndex, _ = strconv.Atoi(r.URL.Query().Get("startIndex"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	return scim.Pagination(startIndex, count, scim.MaxResults)
+}
+
+func toScimUser(vsName string, user *queries.ScimUserResult) *scim.User {
+	base := scimBaseUrl(vsName)
+
+	result := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		Id:          user.Id.String(),
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Active:      utils.Ptr(user.Active),
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceTypeUser,
+			Created:      utils.Ptr(user.CreatedAt),
+			LastModified: utils.Ptr(user.UpdatedAt),
+			Location:     fmt.Sprintf("%s/Users/%s", base, user.Id),
+		},
+	}
+
+	if user.Email != "" {
+		result.Emails = []scim.MultiValued{{
+			Value:   user.Email,
+			Type:    "work",
+			Primary: true,
+		}}
+	}
+
+	for _, group := range user.Groups {
+		result.Groups = append(result.Groups, scim.MultiValued{
+			Value:   group.Id.String(),
+			Display: group.Name,
+			Ref:     fmt.Sprintf("%s/Groups/%s", base, group.Id),
+		})
+	}
+
+	for _, role := range user.Roles {
+		result.Roles = append(result.Roles, scim.MultiValued{Value: role})
+	}
+
+	return result
+}
+
+func toScimGroup(vsName string, group *queries.ScimGroupResult) *scim.Group {
+	base := scimBaseUrl(vsName)
+
+	result := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		Id:          group.Id.String(),
+		DisplayName: group.DisplayName,
+		Meta: &scim.Meta{
+			ResourceType: scim.ResourceTypeGroup,
+			Created:      utils.Ptr(group.CreatedAt),
+			LastModified: utils.Ptr(group.UpdatedAt),
+			Location:     fmt.Sprintf("%s/Groups/%s", base, group.Id),
+		},
+	}
+
+	for _, member := range group.Members {
+		result.Members = append(result.Members, scim.MultiValued{
+			Value:   member.Id.String(),
+			Display: member.Username,
+			Ref:     fmt.Sprintf("%s/Users/%s", base, member.Id),
+		})
+	}
+
+	return result
+}
+
+func scimRoleValues(user *scim.User) []string {
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Value)
+	}
+	return roles
+}
+
+func scimMemberIds(group *scim.Group) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(group.Members))
+	for _, member := range group.Members {
+		id, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, fmt.Sprintf("member %q does not exist", member.Value))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func validateScimUser(user *scim.User) error {
+	if user.UserName == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, "userName is required")
+	}
+	return nil
+}
+
+func validateScimGroup(group *scim.Group) error {
+	if group.DisplayName == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidValue, "displayName is required")
+	}
+	return nil
+}
+
+func getScimUser(ctx context.Context, vsName string, id uuid.UUID) (*scim.User, error) {
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	user, err := mediatr.Send[*queries.ScimUserResult](ctx, m, queries.GetScimUser{
+		VirtualServerName: vsName,
+		UserId:            id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toScimUser(vsName, user), nil
+}
+
+func createScimUser(ctx context.Context, vsName string, data []byte) (*scim.User, error) {
+	user, err := decodeScimBody[scim.User](data)
+	if err != nil {
+		return nil, err
+	}
+	err = validateScimUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	response, err := mediatr.Send[*commands.CreateScimUserResponse](ctx, m, commands.CreateScimUser{
+		VirtualServerName: vsName,
+		Username:          user.UserName,
+		DisplayName:       user.ResolvedDisplayName(),
+		Email:             user.PrimaryEmail(),
+		Active:            user.IsActive(),
+		Roles:             scimRoleValues(user),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimUser(ctx, vsName, response.Id)
+}
+
+func replaceScimUser(ctx context.Context, vsName string, id uuid.UUID, user *scim.User) (*scim.User, error) {
+	err := validateScimUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	_, err = mediatr.Send[*commands.ReplaceScimUserResponse](ctx, m, commands.ReplaceScimUser{
+		VirtualServerName: vsName,
+		UserId:            id,
+		Username:          user.UserName,
+		DisplayName:       user.ResolvedDisplayName(),
+		Email:             user.PrimaryEmail(),
+		Active:            user.IsActive(),
+		Roles:             scimRoleValues(user),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimUser(ctx, vsName, id)
+}
+
+func patchScimUser(ctx context.Context, vsName string, id uuid.UUID, data []byte) (*scim.User, error) {
+	request, err := decodeScimBody[scim.PatchRequest](data)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := getScimUser(ctx, vsName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := scim.Patch(current, request.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceScimUser(ctx, vsName, id, patched)
+}
+
+func deleteScimUser(ctx context.Context, vsName string, id uuid.UUID) error {
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	_, err := mediatr.Send[*commands.DeleteScimUserResponse](ctx, m, commands.DeleteScimUser{
+		VirtualServerName: vsName,
+		UserId:            id,
+	})
+	return err
+}
+
+func getScimGroup(ctx context.Context, vsName string, id uuid.UUID, excludeMembers bool) (*scim.Group, error) {
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	group, err := mediatr.Send[*queries.ScimGroupResult](ctx, m, queries.GetScimGroup{
+		VirtualServerName: vsName,
+		GroupId:           id,
+		ExcludeMembers:    excludeMembers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toScimGroup(vsName, group), nil
+}
+
+func createScimGroup(ctx context.Context, vsName string, data []byte) (*scim.Group, error) {
+	group, err := decodeScimBody[scim.Group](data)
+	if err != nil {
+		return nil, err
+	}
+	err = validateScimGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := scimMemberIds(group)
+	if err != nil {
+		return nil, err
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	response, err := mediatr.Send[*commands.CreateScimGroupResponse](ctx, m, commands.CreateScimGroup{
+		VirtualServerName: vsName,
+		DisplayName:       group.DisplayName,
+		Members:           members,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimGroup(ctx, vsName, response.Id, false)
+}
+
+func replaceScimGroup(ctx context.Context, vsName string, id uuid.UUID, group *scim.Group) (*scim.Group, error) {
+	err := validateScimGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := scimMemberIds(group)
+	if err != nil {
+		return nil, err
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	_, err = mediatr.Send[*commands.ReplaceScimGroupResponse](ctx, m, commands.ReplaceScimGroup{
+		VirtualServerName: vsName,
+		GroupId:           id,
+		DisplayName:       group.DisplayName,
+		Members:           members,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimGroup(ctx, vsName, id, false)
+}
+
+func patchScimGroup(ctx context.Context, vsName string, id uuid.UUID, data []byte) (*scim.Group, error) {
+	request, err := decodeScimBody[scim.PatchRequest](data)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := getScimGroup(ctx, vsName, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := scim.Patch(current, request.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceScimGroup(ctx, vsName, id, patched)
+}
+
+func deleteScimGroup(ctx context.Context, vsName string, id uuid.UUID) error {
+	m := ioc.GetDependency[mediatr.Mediator](middlewares.GetScope(ctx))
+
+	_, err := mediatr.Send[*commands.DeleteScimGroupResponse](ctx, m, commands.DeleteScimGroup{
+		VirtualServerName: vsName,
+		GroupId:           id,
+	})
+	return err
+}
+
+func readScimBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, scim.NewError(http.StatusRequestEntityTooLarge, "", "request body is too large")
+		}
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	return data, nil
+}
+
+// ListScimUsers returns the users of a virtual server as a SCIM list
+// response, narrowed by the filter parameter.
+func ListScimUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	filter, err := scim.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	page, size, startIndex := scimListPage(r)
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	users, err := mediatr.Send[*queries.ListScimUsersResponse](ctx, m, queries.ListScimUsers{
+		PagedQuery: queries.PagedQuery{
+			Page:     page,
+			PageSize: size,
+		},
+		VirtualServerName: vsName,
+		Filter:            filter,
+	})
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	resources := make([]*scim.User, 0, len(users.Items))
+	for _, user := range users.Items {
+		resources = append(resources, toScimUser(vsName, &user))
+	}
+
+	writeScimResponse(w, http.StatusOK, scim.NewListResponse(resources, users.TotalCount, startIndex))
+}
+
+func GetScimUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	user, err := getScimUser(ctx, vsName, id)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, user)
+}
+
+func CreateScimUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	user, err := createScimUser(ctx, vsName, data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", user.Meta.Location)
+	writeScimResponse(w, http.StatusCreated, user)
+}
+
+func ReplaceScimUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	body, err := decodeScimBody[scim.User](data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	user, err := replaceScimUser(ctx, vsName, id, body)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, user)
+}
+
+func PatchScimUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	user, err := patchScimUser(ctx, vsName, id, data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, user)
+}
+
+func DeleteScimUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	err = deleteScimUser(ctx, vsName, id)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func scimExcludesMembers(r *http.Request) bool {
+	for _, attribute := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+// ListScimGroups returns the groups of a virtual server as a SCIM list
+// response. Members are left out if excludedAttributes names them, which
+// clients use to avoid loading large groups.
+func ListScimGroups(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	filter, err := scim.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	page, size, startIndex := scimListPage(r)
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	groups, err := mediatr.Send[*queries.ListScimGroupsResponse](ctx, m, queries.ListScimGroups{
+		PagedQuery: queries.PagedQuery{
+			Page:     page,
+			PageSize: size,
+		},
+		VirtualServerName: vsName,
+		Filter:            filter,
+		ExcludeMembers:    scimExcludesMembers(r),
+	})
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	resources := make([]*scim.Group, 0, len(groups.Items))
+	for _, group := range groups.Items {
+		resources = append(resources, toScimGroup(vsName, &group))
+	}
+
+	writeScimResponse(w, http.StatusOK, scim.NewListResponse(resources, groups.TotalCount, startIndex))
+}
+
+func GetScimGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	group, err := getScimGroup(ctx, vsName, id, scimExcludesMembers(r))
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, group)
+}
+
+func CreateScimGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	group, err := createScimGroup(ctx, vsName, data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", group.Meta.Location)
+	writeScimResponse(w, http.StatusCreated, group)
+}
+
+func ReplaceScimGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	body, err := decodeScimBody[scim.Group](data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	group, err := replaceScimGroup(ctx, vsName, id, body)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, group)
+}
+
+func PatchScimGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	group, err := patchScimGroup(ctx, vsName, id, data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, group)
+}
+
+func DeleteScimGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	id, err := scimResourceId(r)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	err = deleteScimGroup(ctx, vsName, id)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetScimServiceProviderConfig, GetScimResourceTypes and GetScimSchemas serve
+// the discovery documents. Like every other SCIM endpoint they require a
+// provisioning token, which RFC 7644 permits.
+func GetScimServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	vsName, err := middlewares.GetVirtualServerName(r.Context())
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	writeScimResponse(w, http.StatusOK, scim.NewServiceProviderConfig(scimBaseUrl(vsName)+"/ServiceProviderConfig"))
+}
+
+func GetScimResourceTypes(w http.ResponseWriter, r *http.Request) {
+	vsName, err := middlewares.GetVirtualServerName(r.Context())
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	resourceTypes := scim.NewResourceTypes(scimBaseUrl(vsName))
+	writeScimResponse(w, http.StatusOK, scim.NewListResponse(resourceTypes, len(resourceTypes), 1))
+}
+
+func GetScimSchemas(w http.ResponseWriter, r *http.Request) {
+	vsName, err := middlewares.GetVirtualServerName(r.Context())
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	schemas := scim.NewSchemas(scimBaseUrl(vsName))
+	writeScimResponse(w, http.StatusOK, scim.NewListResponse(schemas, len(schemas), 1))
+}
+
+// ScimBulk runs the operations of a bulk request in order. Every operation is
+// committed on its own; "bulkId:<id>" references in later operations are
+// replaced with the ids of resources created earlier in the request.
+func ScimBulk(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	data, err := readScimBody(w, r, scim.MaxBulkPayloadSize)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	request, err := decodeScimBody[scim.BulkRequest](data)
+	if err != nil {
+		handleScimError(w, err)
+		return
+	}
+
+	if len(request.Operations) > scim.MaxBulkOperations {
+		handleScimError(w, scim.NewError(http.StatusRequestEntityTooLarge, scim.ErrorTypeTooMany, fmt.Sprintf("at most %d operations are allowed", scim.MaxBulkOperations)))
+		return
+	}
+
+	response := scim.BulkResponse{
+		Schemas:    []string{scim.SchemaBulkResponse},
+		Operations: make([]scim.BulkOperationResponse, 0, len(request.Operations)),
+	}
+
+	createdIds := make(map[string]string)
+	failures := 0
+	for _, operation := range request.Operations {
+		if request.FailOnErrors > 0 && failures >= request.FailOnErrors {
+			break
+		}
+
+		result := runScimBulkOperation(ctx, vsName, operation, createdIds)
+		if result.Status[0] != '2' {
+			failures++
+		}
+		response.Operations = append(response.Operations, result)
+	}
+
+	writeScimResponse(w, http.StatusOK, response)
+}
+
+func resolveBulkIds(s string, createdIds map[string]string) string {
+	for bulkId, id := range createdIds {
+		s = strings.ReplaceAll(s, "bulkId:"+bulkId, id)
+	}
+	return s
+}
+
+func runScimBulkOperation(ctx context.Context, vsName string, operation scim.BulkOperation, createdIds map[string]string) scim.BulkOperationResponse {
+	result := scim.BulkOperationResponse{
+		Method: operation.Method,
+		BulkId: operation.BulkId,
+	}
+
+	fail := func(err error) scim.BulkOperationResponse {
+		scimErr := toScimError(err)
+		logScimError("scim bulk operation failed", scimErr.Status, err)
+		result.Status = strconv.Itoa(scimErr.Status)
+		result.Response = scimErr
+		return result
+	}
+
+	path := resolveBulkIds(operation.Path, createdIds)
+	data := []byte(resolveBulkIds(string(operation.Data), createdIds))
+
+	if strings.Contains(path, "bulkId:") || strings.Contains(string(data), "bulkId:") {
+		return fail(scim.NewError(http.StatusConflict, scim.ErrorTypeInvalidValue, "unresolved bulkId reference"))
+	}
+
+	resourceType, idString, hasId := strings.Cut(strings.Trim(path, "/"), "/")
+	var id uuid.UUID
+	if hasId {
+		var err error
+		id, err = uuid.Parse(idString)
+		if err != nil {
+			return fail(scim.NewError(http.StatusNotFound, "", "resource not found"))
+		}
+	}
+
+	method := strings.ToUpper(operation.Method)
+	var location string
+	var err error
+	switch {
+	case resourceType == "Users" && !hasId && method == http.MethodPost:
+		var user *scim.User
+		user, err = createScimUser(ctx, vsName, data)
+		if err == nil {
+			location = user.Meta.Location
+			createdIds[operation.BulkId] = user.Id
+			result.Status = strconv.Itoa(http.StatusCreated)
+		}
+
+	case resourceType == "Users" && hasId && method == http.MethodPut:
+		var body *scim.User
+		body, err = decodeScimBody[scim.User](data)
+		if err == nil {
+			var user *scim.User
+			user, err = replaceScimUser(ctx, vsName, id, body)
+			if err == nil {
+				location = user.Meta.Location
+			}
+		}
+
+	case resourceType == "Users" && hasId && method == http.MethodPatch:
+		var user *scim.User
+		user, err = patchScimUser(ctx, vsName, id, data)
+		if err == nil {
+			location = user.Meta.Location
+		}
+
+	case resourceType == "Users" && hasId && method == http.MethodDelete:
+		err = deleteScimUser(ctx, vsName, id)
+		result.Status = strconv.Itoa(http.StatusNoContent)
+
+	case resourceType == "Groups" && !hasId && method == http.MethodPost:
+		var group *scim.Group
+		group, err = createScimGroup(ctx, vsName, data)
+		if err == nil {
+			location = group.Meta.Location
+			createdIds[operation.BulkId] = group.Id
+			result.Status = strconv.Itoa(http.StatusCreated)
+		}
+
+	case resourceType == "Groups" && hasId && method == http.MethodPut:
+		var body *scim.Group
+		body, err = decodeScimBody[scim.Group](data)
+		if err == nil {
+			var group *scim.Group
+			group, err = replaceScimGroup(ctx, vsName, id, body)
+			if err == nil {
+				location = group.Meta.Location
+			}
+		}
+
+	case resourceType == "Groups" && hasId && method == http.MethodPatch:
+		var group *scim.Group
+		group, err = patchScimGroup(ctx, vsName, id, data)
+		if err == nil {
+			location = group.Meta.Location
+		}
+
+	case resourceType == "Groups" && hasId && method == http.MethodDelete:
+		err = deleteScimGroup(ctx, vsName, id)
+		result.Status = strconv.Itoa(http.StatusNoContent)
+
+	default:
+		err = scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, fmt.Sprintf("unsupported bulk operation %s %s", operation.Method, operation.Path))
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+
+	if result.Status == "" {
+		result.Status = strconv.Itoa(http.StatusOK)
+	}
+	result.Location = location
+	return result
+}
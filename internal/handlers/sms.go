@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/jsonTypes"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/templates"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+const SmsCodeExpiry = 5 * time.Minute
+
+// MaxFailedSmsCodeAttempts is the number of wrong codes after which a code
+// is dropped and a new one has to be sent.
+const MaxFailedSmsCodeAttempts = 5
+
+var errSmsNotAvailable = fmt.Errorf("%w: %w", services.ErrSmsNotConfigured, utils.ErrHttpBadRequest)
+
+// queueSmsCode sends a new code to the phone number and stores it under
+// the given token, replacing the code that was sent before. Codes count
+// towards the mail throttle of the user like mails do.
+func queueSmsCode(
+	ctx context.Context,
+	tokenType services.TokenType,
+	token string,
+	virtualServerId uuid.UUID,
+	userId uuid.UUID,
+	phoneNumber string,
+) error {
+	scope := middlewares.GetScope(ctx)
+
+	smsProvider := ioc.GetDependency[services.SmsProvider](scope)
+	if !smsProvider.Enabled() {
+		return errSmsNotAvailable
+	}
+
+	mailThrottle := ioc.GetDependency[services.MailThrottle](scope)
+	err := mailThrottle.Acquire(ctx, repositories.SmsCodeTemplate, userId)
+	if err != nil {
+		return err
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	challenge := jsonTypes.SmsCodeChallenge{
+		UserId:      userId,
+		PhoneNumber: phoneNumber,
+		Code:        generateOneTimeCode(),
+		ExpiresAt:   clockService.Now().Add(SmsCodeExpiry),
+	}
+
+	err = storeSmsCodeChallenge(ctx, tokenType, token, challenge)
+	if err != nil {
+		return err
+	}
+
+	templateService := ioc.GetDependency[services.TemplateService](scope)
+	body, err := templateService.Template(
+		ctx,
+		virtualServerId,
+		repositories.SmsCodeTemplate,
+		templates.SmsCodeTemplateData{
+			Code:      challenge.Code,
+			ExpiresIn: fmt.Sprintf("%d minutes", int(SmsCodeExpiry.Minutes())),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("templating sms code: %w", err)
+	}
+
+	message := &messages.SendSmsMessage{
+		VirtualServerId: virtualServerId,
+		To:              phoneNumber,
+		Body:            body,
+	}
+
+	outboxMessage, err := repositories.NewOutboxMessage(message)
+	if err != nil {
+		return fmt.Errorf("creating sms outbox message: %w", err)
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+	dbContext.OutboxMessages().Insert(outboxMessage)
+	return nil
+}
+
+func storeSmsCodeChallenge(ctx context.Context, tokenType services.TokenType, token string, challenge jsonTypes.SmsCodeChallenge) error {
+	scope := middlewares.GetScope(ctx)
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("marshal sms code challenge: %w", err)
+	}
+
+	err = resetOneTimeCodeAttempts(ctx, tokenType, token)
+	if err != nil {
+		return err
+	}
+
+	err = tokenService.StoreToken(ctx, tokenType, token, string(value), challenge.ExpiresAt.Sub(clockService.Now()))
+	if err != nil {
+		return fmt.Errorf("storing sms code challenge: %w", err)
+	}
+
+	return nil
+}
+
+// errInvalidSmsCode is returned for wrong codes, callers count it against
+// the lockout of the user.
+var errInvalidSmsCode = fmt.Errorf("invalid sms code: %w", utils.ErrHttpUnauthorized)
+
+// verifySmsCode checks the code against the one stored under the token.
+// A code can only be used once, attempts are counted and the code is
+// dropped after MaxFailedSmsCodeAttempts.
+func verifySmsCode(ctx context.Context, tokenType services.TokenType, token string, code string) (*jsonTypes.SmsCodeChallenge, error) {
+	scope := middlewares.GetScope(ctx)
+	tokenService := ioc.GetDependency[services.TokenService](scope)
+
+	rawChallenge, err := tokenService.GetToken(ctx, tokenType, token)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		return nil, fmt.Errorf("no sms code was sent or it expired: %w", utils.ErrHttpUnauthorized)
+
+	case err != nil:
+		return nil, err
+	}
+
+	var challenge jsonTypes.SmsCodeChallenge
+	err = json.Unmarshal([]byte(rawChallenge), &challenge)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal sms code challenge: %w", err)
+	}
+
+	attempts, err := countOneTimeCodeAttempt(ctx, tokenType, token, SmsCodeExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts > MaxFailedSmsCodeAttempts || subtle.ConstantTimeCompare([]byte(code), []byte(challenge.Code)) != 1 {
+		if attempts >= MaxFailedSmsCodeAttempts {
+			err = tokenService.DeleteToken(ctx, tokenType, token)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, errInvalidSmsCode
+	}
+
+	// of two requests with the right code only the one that takes the
+	// challenge succeeds
+	takenChallenge, err := tokenService.TakeToken(ctx, tokenType, token)
+	switch {
+	case errors.Is(err, services.ErrTokenNotFound):
+		return nil, errInvalidSmsCode
+
+	case err != nil:
+		return nil, fmt.Errorf("taking sms code challenge: %w", err)
+	}
+
+	// a new code may have replaced this one in between
+	if takenChallenge != rawChallenge {
+		return nil, errInvalidSmsCode
+	}
+
+	return &challenge, nil
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// trustedDeviceTokenType is the typ header of trusted device cookies, it
+// keeps other tokens signed with the same key from passing as one.
+const trustedDeviceTokenType = "keyline-trusted-device+jwt"
+
+// maxTrustedDeviceNameLength caps the user agent stored as device name.
+const maxTrustedDeviceNameLength = 255
+
+// trustDevice remembers the browser of the request for the number of days
+// the virtual server allows. The cookie only carries the id of the trusted
+// device credential, so revoking the credential revokes the cookie.
+func trustDevice(ctx context.Context, w http.ResponseWriter, r *http.Request, virtualServer *repositories.VirtualServer, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	lifetime := time.Duration(virtualServer.TrustedDeviceDays()) * 24 * time.Hour
+	now := clockService.Now()
+
+	name := r.UserAgent()
+	if len(name) > maxTrustedDeviceNameLength {
+		name = name[:maxTrustedDeviceNameLength]
+	}
+
+	credential := repositories.NewCredential(userId, &repositories.CredentialTrustedDeviceDetails{
+		Name:      name,
+		ExpiresAt: now.Add(lifetime),
+	})
+	dbContext.Credentials().Insert(credential)
+
+	keyPair, err := keyService.GetKey(virtualServer.Name(), virtualServer.PrimarySigningAlgorithm())
+	if err != nil {
+		return fmt.Errorf("getting key: %w", err)
+	}
+
+	signingMethod, err := getJwtSigningMethod(keyPair.Algorithm())
+	if err != nil {
+		return fmt.Errorf("getting jwt signing method: %w", err)
+	}
+
+	token := jwt.NewWithClaims(signingMethod, jwt.MapClaims{
+		"sub": userId,
+		"jti": credential.Id(),
+		"iat": now.Unix(),
+		"exp": now.Add(lifetime).Unix(),
+	})
+	token.Header["kid"] = keyPair.GetKid()
+	token.Header["typ"] = trustedDeviceTokenType
+
+	signed, err := signJwt(token, keyPair)
+	if err != nil {
+		return err
+	}
+
+	middlewares.SetTrustedDeviceCookie(w, virtualServer.Name(), signed, int(lifetime.Seconds()))
+	return nil
+}
+
+// trustedDeviceOfRequest returns the trusted device the browser of the
+// request presents for the user, or uuid.Nil if there is none. Invalid,
+// expired or revoked cookies are ignored, the user is asked for the
+// second factor as usual then.
+func trustedDeviceOfRequest(ctx context.Context, r *http.Request, virtualServer *repositories.VirtualServer, userId uuid.UUID) (uuid.UUID, error) {
+	if virtualServer.TrustedDeviceDays() == 0 {
+		return uuid.Nil, nil
+	}
+
+	cookie, err := r.Cookie(middlewares.GetTrustedDeviceCookieName(virtualServer.Name()))
+	if errors.Is(err, http.ErrNoCookie) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("reading trusted device cookie: %w", err)
+	}
+
+	scope := middlewares.GetScope(ctx)
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != trustedDeviceTokenType {
+			return nil, fmt.Errorf("unexpected token type %q", typ)
+		}
+		alg := config.SigningAlgorithm(token.Method.Alg())
+		kid, _ := token.Header["kid"].(string)
+		keyPair, err := keyService.GetVerificationKey(virtualServer.Name(), alg, kid)
+		if err != nil {
+			return nil, fmt.Errorf("getting key: %w", err)
+		}
+		return keyPair.PublicKey(), nil
+	}, jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil || !token.Valid {
+		return uuid.Nil, nil
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject != userId.String() {
+		return uuid.Nil, nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	trustedDeviceId, err := uuid.Parse(jti)
+	if err != nil {
+		return uuid.Nil, nil
+	}
+
+	dbContext := ioc.GetDependency[database.Context](scope)
+	credential, details, err := getTrustedDevice(ctx, dbContext, userId, trustedDeviceId, now)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if credential == nil {
+		return uuid.Nil, nil
+	}
+
+	details.LastUsedAt = &now
+	credential.SetDetails(details)
+	dbContext.Credentials().Update(credential)
+
+	return trustedDeviceId, nil
+}
+
+// getTrustedDevice returns the trusted device of the user, or nil if it was
+// revoked or has expired.
+func getTrustedDevice(
+	ctx context.Context,
+	dbContext database.Context,
+	userId uuid.UUID,
+	trustedDeviceId uuid.UUID,
+	now time.Time,
+) (*repositories.Credential, *repositories.CredentialTrustedDeviceDetails, error) {
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(userId).
+		Type(repositories.CredentialTypeTrustedDevice).
+		Id(trustedDeviceId)
+	credential, err := dbContext.Credentials().FirstOrNil(ctx, credentialFilter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting trusted device: %w", err)
+	}
+	if credential == nil {
+		return nil, nil, nil
+	}
+
+	details, err := credential.TrustedDeviceDetails()
+	if err != nil {
+		return nil, nil, err
+	}
+	if details.IsExpired(now) {
+		return nil, nil, nil
+	}
+
+	return credential, details, nil
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -10,14 +13,19 @@ import (
 	"github.com/The127/Keyline/internal/commands"
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/jsonTypes"
+	"github.com/The127/Keyline/internal/logging"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/queries"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
 	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/internal/webauthn"
 	"github.com/The127/Keyline/utils"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 	"github.com/The127/mediatr"
 
@@ -116,6 +124,102 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ForgotPassword mails a password reset link to a user.
+// @Summary      Request password reset
+// @Tags         Users
+// @Accept       json
+// @Produce      plain
+// @Param        virtualServerName  path  string                     true "Virtual server name"  default(keyline)
+// @Param        body               body  ForgotPasswordRequestDto   true "Username or email"
+// @Success      204                {string} string "No Content"
+// @Failure      400                {string} string
+// @Router       /api/virtual-servers/{virtualServerName}/users/forgot-password [post]
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.ForgotPasswordRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.RequestPasswordResetResponse](ctx, m, commands.RequestPasswordReset{
+		VirtualServerName: vsName,
+		UsernameOrEmail:   strings.TrimSpace(dto.UsernameOrEmail),
+	})
+	if err != nil {
+		// failing only for existing users would reveal them, so the
+		// error is logged instead
+		logging.Logger.Errorf("requesting password reset: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetPassword sets a new password with the token from a password reset mail.
+// @Summary      Reset password
+// @Tags         Users
+// @Accept       json
+// @Produce      plain
+// @Param        virtualServerName  path  string                    true "Virtual server name"  default(keyline)
+// @Param        body               body  ResetPasswordRequestDto   true "Token and new password"
+// @Success      204                {string} string "No Content"
+// @Failure      400                {string} string
+// @Router       /api/virtual-servers/{virtualServerName}/users/reset-password [post]
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.ResetPasswordRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	scope := middlewares.GetScope(ctx)
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+
+	_, err = mediatr.Send[*commands.ResetPasswordResponse](ctx, m, commands.ResetPassword{
+		VirtualServerName: vsName,
+		Token:             dto.Token,
+		NewPassword:       dto.NewPassword,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // CreateUser creates a new user.
 // @Summary      Create user
 // @Tags         Users
@@ -927,6 +1031,12 @@ func PasskeyCreateChallenge(w http.ResponseWriter, r *http.Request) {
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	vars := mux.Vars(r)
 	userId, err := uuid.Parse(vars["userId"])
 	if err != nil {
@@ -947,6 +1057,20 @@ func PasskeyCreateChallenge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	policy := virtualServer.PasskeyPolicy()
+
+	rpId, err := webauthnRpId(config.C.Frontend.ExternalUrl)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	challengeBytes := utils.GetSecureRandomBytes(64)
 
 	challenge := jsonTypes.PasskeyCreateChallenge{
@@ -968,14 +1092,22 @@ func PasskeyCreateChallenge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attestation := "none"
+	if policy.AttestationRequired() {
+		attestation = "direct"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	err = json.NewEncoder(w).Encode(api.PasskeyCreateChallengeResponseDto{
-		Id:          challenge.Id,
-		Challenge:   challenge.Challenge,
-		UserId:      userId,
-		Username:    user.Username(),
-		DisplayName: user.DisplayName(),
+		Id:               challenge.Id,
+		Challenge:        challenge.Challenge,
+		UserId:           userId,
+		Username:         user.Username(),
+		DisplayName:      user.DisplayName(),
+		RpId:             rpId,
+		Attestation:      attestation,
+		UserVerification: string(policy.UserVerification),
 	})
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -988,6 +1120,12 @@ func PasskeyValidateCreateChallengeResponse(w http.ResponseWriter, r *http.Reque
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	vars := mux.Vars(r)
 	userId, err := uuid.Parse(vars["userId"])
 	if err != nil {
@@ -1015,28 +1153,162 @@ func PasskeyValidateCreateChallengeResponse(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// get challenge from kv store
+	// get challenge from kv store, it can only be used once
 	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	challengeKey := "passkey_challenge:" + dto.Id.String()
+	challengeJson, err := kvStore.Get(ctx, challengeKey)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("challenge expired or missing: %w", utils.ErrHttpBadRequest))
+		return
+	}
+	_ = kvStore.Delete(ctx, challengeKey)
+
+	var challenge jsonTypes.PasskeyCreateChallenge
+	err = json.Unmarshal([]byte(challengeJson), &challenge)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	if challenge.UserId != userId {
+		utils.HandleHttpError(w, fmt.Errorf("challenge was issued for another user: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	challengeBytes, err := base64.StdEncoding.DecodeString(challenge.Challenge)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	clientDataBytes, err := base64.StdEncoding.DecodeString(dto.WebauthnResponse.Response.ClientDataJSON)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("decoding client data: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	clientData, err := webauthn.ParseClientData(clientDataBytes)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	expectedOrigin, err := webauthnExpectedOrigin(config.C.Frontend.ExternalUrl)
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("computing expected webauthn origin: %w", err))
+		return
+	}
+
+	err = clientData.Verify("webauthn.create", challengeBytes, expectedOrigin)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	attestationObjectBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(dto.WebauthnResponse.Response.AttestationObject, "="))
+	if err != nil {
+		utils.HandleHttpError(w, fmt.Errorf("decoding attestation object: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	attestationObject, err := webauthn.ParseAttestationObject(attestationObjectBytes)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	clientDataHash := sha256.Sum256(clientDataBytes)
+	attestation, err := attestationObject.Verify(clientDataHash[:])
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	if dto.WebauthnResponse.RawId != base64.RawURLEncoding.EncodeToString(attestation.AuthData.CredentialId) {
+		utils.HandleHttpError(w, fmt.Errorf("credential id does not match the attestation: %w", utils.ErrHttpBadRequest))
+		return
+	}
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	rpId, err := webauthnRpId(config.C.Frontend.ExternalUrl)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	trustStore := ioc.GetDependency[webauthn.TrustStore](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	err = verifyPasskeyRegistration(attestation, rpId, virtualServer.PasskeyPolicy(), trustStore, clockService.Now())
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
 
-	pubKey, err := base64.RawURLEncoding.DecodeString(dto.WebauthnResponse.Response.PublicKey)
+	pubKey, err := x509.MarshalPKIXPublicKey(attestation.PublicKey)
 	if err != nil {
 		utils.HandleHttpError(w, err)
 		return
 	}
 
-	// store the credential in the db
+	// store the credential in the db, the counter of the authenticator
+	// starts at the value it reports on registration
 	credential := repositories.NewCredential(userId, &repositories.CredentialWebauthnDetails{
 		CredentialId:       dto.WebauthnResponse.RawId,
-		PublicKeyAlgorithm: dto.WebauthnResponse.Response.PublicKeyAlgorithm,
+		PublicKeyAlgorithm: attestation.PublicKeyAlgorithm,
 		PublicKey:          pubKey,
+		Name:               strings.TrimSpace(dto.Name),
+		SignCount:          attestation.AuthData.SignCount,
+		Aaguid:             attestation.AuthData.Aaguid,
+		AttestationFormat:  attestation.Format,
 	})
 	dbContext.Credentials().Insert(credential)
 
-	_ = kvStore.Delete(ctx, "passkey_challenge:"+dto.Id.String())
-
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// verifyPasskeyRegistration checks a verified attestation against the
+// relying party and the passkey policy of the virtual server.
+func verifyPasskeyRegistration(
+	attestation *webauthn.Attestation,
+	rpId string,
+	policy repositories.PasskeyPolicy,
+	trustStore webauthn.TrustStore,
+	now time.Time,
+) error {
+	authData := attestation.AuthData
+	if !bytes.Equal(authData.RpIdHash, webauthn.RpIdHash(rpId)) {
+		return fmt.Errorf("credential was created for another relying party: %w", utils.ErrHttpUnauthorized)
+	}
+
+	if !authData.UserPresent() {
+		return fmt.Errorf("user presence is required: %w", utils.ErrHttpBadRequest)
+	}
+
+	if policy.UserVerification == repositories.PasskeyUserVerificationRequired && !authData.UserVerified() {
+		return fmt.Errorf("user verification is required: %w", utils.ErrHttpBadRequest)
+	}
+
+	if !policy.AttestationRequired() {
+		return nil
+	}
+
+	err := trustStore.Verify(attestation, now)
+	if err != nil {
+		return err
+	}
+
+	if !policy.AllowsAaguid(authData.Aaguid) {
+		return fmt.Errorf("authenticator %s is not allowed: %w", authData.Aaguid, utils.ErrHttpBadRequest)
+	}
+
+	return nil
+}
+
 func ListPasskeys(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	scope := middlewares.GetScope(ctx)
@@ -1068,7 +1340,14 @@ func ListPasskeys(w http.ResponseWriter, r *http.Request) {
 
 	items := utils.MapSlice(passkeys.Items, func(x queries.ListPasskeysResponseItem) api.ListPasskeyResponseDto {
 		return api.ListPasskeyResponseDto{
-			Id: x.Id,
+			Id:         x.Id,
+			Name:       x.Name,
+			CreatedAt:  x.CreatedAt,
+			LastUsedAt: x.LastUsedAt,
+			SignCount:  x.SignCount,
+
+			Aaguid:            x.Aaguid,
+			AttestationFormat: x.AttestationFormat,
 		}
 	})
 
@@ -1082,3 +1361,695 @@ func ListPasskeys(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func parsePasskeyRouteIds(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, utils.ErrInvalidUuid
+	}
+
+	passkeyId, err := uuid.Parse(vars["passkeyId"])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, utils.ErrInvalidUuid
+	}
+
+	return userId, passkeyId, nil
+}
+
+func DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	userId, passkeyId, err := parsePasskeyRouteIds(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.DeletePasskeyResponse](ctx, m, commands.DeletePasskey{
+		VirtualServerName: vsName,
+		UserId:            userId,
+		PasskeyId:         passkeyId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func PatchPasskey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	userId, passkeyId, err := parsePasskeyRouteIds(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.PatchPasskeyRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	dto.Name = utils.TrimSpace(dto.Name)
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.PatchPasskeyResponse](ctx, m, commands.PatchPasskey{
+		VirtualServerName: vsName,
+		UserId:            userId,
+		PasskeyId:         passkeyId,
+		Name:              dto.Name,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartPhoneRegistration sends a code to the phone number the current user
+// wants to use for the SMS second factor.
+// @Summary      Start phone registration
+// @Tags         Users
+// @Accept       json
+// @Produce      plain
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Param        body               body  api.StartPhoneRegistrationRequestDto  true  "Phone number"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string "Bad Request or sms not configured"
+// @Failure      401  {string}  string
+// @Failure      429  {string}  string "Too many codes were requested"
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/phone/register/start [post]
+func StartPhoneRegistration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	currentUser := authentication.GetCurrentUser(ctx)
+	if currentUser.UserId != userId {
+		utils.HandleHttpError(w, fmt.Errorf("not allowed to register a phone number for another user: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	var dto api.StartPhoneRegistrationRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = queueSmsCode(
+		ctx,
+		services.SmsOnboardingCodeTokenType,
+		userId.String(),
+		virtualServer.Id(),
+		userId,
+		dto.PhoneNumber,
+	)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FinishPhoneRegistration stores the phone number once the current user
+// entered the code sent to it. It replaces the phone number the user had
+// registered before.
+// @Summary      Finish phone registration
+// @Tags         Users
+// @Accept       json
+// @Produce      plain
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Param        body               body  api.FinishPhoneRegistrationRequestDto  true  "SMS code"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      401  {string}  string "Unauthorized or invalid code"
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/phone/register/finish [post]
+func FinishPhoneRegistration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	currentUser := authentication.GetCurrentUser(ctx)
+	if currentUser.UserId != userId {
+		utils.HandleHttpError(w, fmt.Errorf("not allowed to register a phone number for another user: %w", utils.ErrHttpUnauthorized))
+		return
+	}
+
+	var dto api.FinishPhoneRegistrationRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	challenge, err := verifySmsCode(ctx, services.SmsOnboardingCodeTokenType, userId.String(), dto.Code)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	phoneFilter := repositories.NewCredentialFilter().
+		UserId(userId).
+		Type(repositories.CredentialTypePhone)
+	phoneCredentials, err := dbContext.Credentials().List(ctx, phoneFilter)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+	for _, phoneCredential := range phoneCredentials {
+		dbContext.Credentials().Delete(phoneCredential.Id())
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	credential := repositories.NewCredential(userId, &repositories.CredentialPhoneDetails{
+		PhoneNumber: challenge.PhoneNumber,
+		VerifiedAt:  clockService.Now(),
+	})
+	dbContext.Credentials().Insert(credential)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserLockout returns whether a user is locked out by failed password attempts.
+// @Summary      Get user lockout
+// @Tags         Users
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      200  {object}  GetUserLockoutResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/lockout [get]
+func GetUserLockout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	lockout, err := mediatr.Send[*queries.GetUserLockoutResponse](ctx, m, queries.GetUserLockout{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.GetUserLockoutResponseDto{
+		Locked:         lockout.Locked,
+		Permanent:      lockout.Permanent,
+		LockedUntil:    lockout.LockedUntil,
+		FailedAttempts: lockout.FailedAttempts,
+		Lockouts:       lockout.Lockouts,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// UnlockUser lifts the lockout of a user.
+// @Summary      Unlock user
+// @Tags         Users
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/lockout [delete]
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.UnlockUserResponse](ctx, m, commands.UnlockUser{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the TOTP recovery codes of a user. The
+// new codes are only shown in this response.
+// @Summary      Regenerate recovery codes
+// @Tags         Users
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      200  {object}  api.RegenerateRecoveryCodesResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/recovery-codes [post]
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.RegenerateRecoveryCodesResponse](ctx, m, commands.RegenerateRecoveryCodes{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.RegenerateRecoveryCodesResponseDto{
+		RecoveryCodes: response.RecoveryCodes,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// ListTotp lists the TOTP authenticators of a user.
+// @Summary      List TOTP authenticators
+// @Tags         Users
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      200  {object}  api.PagedListTotpResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/totp [get]
+func ListTotp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	authenticators, err := mediatr.Send[*queries.ListTotpResponse](ctx, m, queries.ListTotp{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	items := utils.MapSlice(authenticators.Items, func(x queries.ListTotpResponseItem) api.ListTotpResponseDto {
+		return api.ListTotpResponseDto{
+			Id:        x.Id,
+			Name:      x.Name,
+			CreatedAt: x.CreatedAt,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.PagedListTotpResponseDto{
+		Items: items,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+func parseTotpRouteIds(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, utils.ErrInvalidUuid
+	}
+
+	totpId, err := uuid.Parse(vars["totpId"])
+	if err != nil {
+		return uuid.Nil, uuid.Nil, utils.ErrInvalidUuid
+	}
+
+	return userId, totpId, nil
+}
+
+// PatchTotp renames a TOTP authenticator of a user.
+// @Summary      Rename TOTP authenticator
+// @Tags         Users
+// @Accept       json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Param        totpId             path  string  true  "TOTP ID (UUID)"
+// @Param        body               body  api.PatchTotpRequestDto  true  "New name"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/totp/{totpId} [patch]
+func PatchTotp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	userId, totpId, err := parseTotpRouteIds(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.PatchTotpRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	dto.Name = utils.TrimSpace(dto.Name)
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.PatchTotpResponse](ctx, m, commands.PatchTotp{
+		VirtualServerName: vsName,
+		UserId:            userId,
+		TotpId:            totpId,
+		Name:              dto.Name,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteTotp removes a TOTP authenticator of a user. Removing the last
+// one also removes the recovery codes.
+// @Summary      Delete TOTP authenticator
+// @Tags         Users
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Param        totpId             path  string  true  "TOTP ID (UUID)"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/totp/{totpId} [delete]
+func DeleteTotp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	userId, totpId, err := parseTotpRouteIds(r)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.DeleteTotpResponse](ctx, m, commands.DeleteTotp{
+		VirtualServerName: vsName,
+		UserId:            userId,
+		TotpId:            totpId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTrustedDevices lists the browsers of a user that skip the second
+// factor.
+// @Summary      List trusted devices
+// @Tags         Users
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      200  {object}  api.PagedListTrustedDeviceResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/trusted-devices [get]
+func ListTrustedDevices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	trustedDevices, err := mediatr.Send[*queries.ListTrustedDevicesResponse](ctx, m, queries.ListTrustedDevices{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	items := utils.MapSlice(trustedDevices.Items, func(x queries.ListTrustedDevicesResponseItem) api.ListTrustedDeviceResponseDto {
+		return api.ListTrustedDeviceResponseDto{
+			Id:         x.Id,
+			Name:       x.Name,
+			CreatedAt:  x.CreatedAt,
+			ExpiresAt:  x.ExpiresAt,
+			LastUsedAt: x.LastUsedAt,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(api.PagedListTrustedDeviceResponseDto{
+		Items: items,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+}
+
+// DeleteTrustedDevice revokes a trusted device of a user.
+// @Summary      Delete trusted device
+// @Tags         Users
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Param        trustedDeviceId    path  string  true  "Trusted device ID (UUID)"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/trusted-devices/{trustedDeviceId} [delete]
+func DeleteTrustedDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	trustedDeviceId, err := uuid.Parse(vars["trustedDeviceId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.DeleteTrustedDeviceResponse](ctx, m, commands.DeleteTrustedDevice{
+		VirtualServerName: vsName,
+		UserId:            userId,
+		TrustedDeviceId:   trustedDeviceId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetSecondFactors removes all second factors of a user and makes them
+// onboard a new authenticator at their next login.
+// @Summary      Reset second factors
+// @Tags         Users
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        userId             path  string  true  "User ID (UUID)"
+// @Success      204  {string}  string "No Content"
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/users/{userId}/reset-2fa [post]
+func ResetSecondFactors(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		utils.HandleHttpError(w, utils.ErrInvalidUuid)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	_, err = mediatr.Send[*commands.ResetSecondFactorsResponse](ctx, m, commands.ResetSecondFactors{
+		VirtualServerName: vsName,
+		UserId:            userId,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/webauthn"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubTrustStore struct {
+	err error
+}
+
+func (s stubTrustStore) Verify(*webauthn.Attestation, time.Time) error {
+	return s.err
+}
+
+func testPasskeyAttestation(rpId string, flags byte, aaguid uuid.UUID, attestationType webauthn.AttestationType) *webauthn.Attestation {
+	return &webauthn.Attestation{
+		Format: webauthn.AttestationFormatPacked,
+		Type:   attestationType,
+		AuthData: &webauthn.AuthenticatorData{
+			RpIdHash: webauthn.RpIdHash(rpId),
+			Flags:    flags,
+			Aaguid:   aaguid,
+		},
+	}
+}
+
+func TestVerifyPasskeyRegistration(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rpId            = "login.example.com"
+		userPresent     = 0x01
+		presentVerified = 0x05
+	)
+	allowed := uuid.New()
+	trusted := stubTrustStore{}
+	untrusted := stubTrustStore{err: webauthn.ErrUntrustedAttestation}
+
+	tests := []struct {
+		name        string
+		attestation *webauthn.Attestation
+		policy      repositories.PasskeyPolicy
+		trustStore  webauthn.TrustStore
+		wantErr     error
+	}{
+		{
+			name:        "default policy accepts no attestation",
+			attestation: testPasskeyAttestation(rpId, userPresent, uuid.Nil, webauthn.AttestationTypeNone),
+			policy:      repositories.DefaultPasskeyPolicy(),
+			trustStore:  untrusted,
+		},
+		{
+			name:        "other relying party",
+			attestation: testPasskeyAttestation("evil.example.com", userPresent, uuid.Nil, webauthn.AttestationTypeNone),
+			policy:      repositories.DefaultPasskeyPolicy(),
+			trustStore:  trusted,
+			wantErr:     utils.ErrHttpUnauthorized,
+		},
+		{
+			name:        "user not present",
+			attestation: testPasskeyAttestation(rpId, 0, uuid.Nil, webauthn.AttestationTypeNone),
+			policy:      repositories.DefaultPasskeyPolicy(),
+			trustStore:  trusted,
+			wantErr:     utils.ErrHttpBadRequest,
+		},
+		{
+			name:        "user verification required",
+			attestation: testPasskeyAttestation(rpId, userPresent, uuid.Nil, webauthn.AttestationTypeNone),
+			policy:      repositories.PasskeyPolicy{UserVerification: repositories.PasskeyUserVerificationRequired},
+			trustStore:  trusted,
+			wantErr:     utils.ErrHttpBadRequest,
+		},
+		{
+			name:        "user verified",
+			attestation: testPasskeyAttestation(rpId, presentVerified, uuid.Nil, webauthn.AttestationTypeNone),
+			policy:      repositories.PasskeyPolicy{UserVerification: repositories.PasskeyUserVerificationRequired},
+			trustStore:  trusted,
+		},
+		{
+			name:        "required attestation is untrusted",
+			attestation: testPasskeyAttestation(rpId, userPresent, allowed, webauthn.AttestationTypeBasic),
+			policy:      repositories.PasskeyPolicy{RequireAttestation: true},
+			trustStore:  untrusted,
+			wantErr:     webauthn.ErrUntrustedAttestation,
+		},
+		{
+			name:        "allowed authenticator",
+			attestation: testPasskeyAttestation(rpId, userPresent, allowed, webauthn.AttestationTypeBasic),
+			policy:      repositories.PasskeyPolicy{AllowedAaguids: []uuid.UUID{allowed}},
+			trustStore:  trusted,
+		},
+		{
+			name:        "allow-list implies attestation",
+			attestation: testPasskeyAttestation(rpId, userPresent, allowed, webauthn.AttestationTypeNone),
+			policy:      repositories.PasskeyPolicy{AllowedAaguids: []uuid.UUID{allowed}},
+			trustStore:  untrusted,
+			wantErr:     webauthn.ErrUntrustedAttestation,
+		},
+		{
+			name:        "authenticator not on the allow-list",
+			attestation: testPasskeyAttestation(rpId, userPresent, uuid.New(), webauthn.AttestationTypeBasic),
+			policy:      repositories.PasskeyPolicy{AllowedAaguids: []uuid.UUID{allowed}},
+			trustStore:  trusted,
+			wantErr:     utils.ErrHttpBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := verifyPasskeyRegistration(tt.attestation, rpId, tt.policy, tt.trustStore, time.Now())
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPasskeyLogin(t *testing.T) {
+	t.Parallel()
+
+	const rpId = "login.example.com"
+	allowed := uuid.New()
+	authData := func(rpId string, flags byte) []byte {
+		data := make([]byte, 37)
+		copy(data, webauthn.RpIdHash(rpId))
+		data[32] = flags
+		return data
+	}
+	unverified := authData(rpId, 0x01)
+	verified := authData(rpId, 0x05)
+
+	details := &repositories.CredentialWebauthnDetails{Aaguid: allowed}
+
+	assert.NoError(t, verifyPasskeyLogin(unverified, rpId, details, repositories.DefaultPasskeyPolicy()))
+
+	otherRelyingParty := authData("evil.example.com", 0x05)
+	assert.ErrorIs(t, verifyPasskeyLogin(otherRelyingParty, rpId, details, repositories.DefaultPasskeyPolicy()), utils.ErrHttpUnauthorized)
+
+	notPresent := authData(rpId, 0x04)
+	assert.ErrorIs(t, verifyPasskeyLogin(notPresent, rpId, details, repositories.DefaultPasskeyPolicy()), utils.ErrHttpUnauthorized)
+
+	requireVerification := repositories.PasskeyPolicy{UserVerification: repositories.PasskeyUserVerificationRequired}
+	assert.ErrorIs(t, verifyPasskeyLogin(unverified, rpId, details, requireVerification), utils.ErrHttpUnauthorized)
+	assert.NoError(t, verifyPasskeyLogin(verified, rpId, details, requireVerification))
+
+	allowList := repositories.PasskeyPolicy{AllowedAaguids: []uuid.UUID{allowed}}
+	assert.NoError(t, verifyPasskeyLogin(unverified, rpId, details, allowList))
+	other := &repositories.CredentialWebauthnDetails{Aaguid: uuid.New()}
+	assert.ErrorIs(t, verifyPasskeyLogin(unverified, rpId, other, allowList), utils.ErrHttpUnauthorized)
+}
@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/The127/Keyline/api"
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/commands"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/utils"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/The127/ioc"
 	"github.com/The127/mediatr"
+
+	"github.com/gorilla/mux"
 )
 
 // CreateVirtualServer creates a new virtual server.
@@ -161,10 +167,30 @@ func GetVirtualServer(w http.ResponseWriter, r *http.Request) {
 		RegistrationEnabled:         response.RegistrationEnabled,
 		Require2fa:                  response.Require2fa,
 		RequireEmailVerification:    response.RequireEmailVerification,
+		EnableEmailLogin:            response.EnableEmailLogin,
+		TrustedDeviceDays:           response.TrustedDeviceDays,
 		PrimarySigningAlgorithm:     string(response.PrimarySigningAlgorithm),
 		AdditionalSigningAlgorithms: additionalAlgorithms,
-		CreatedAt:                   response.CreatedAt,
-		UpdatedAt:                   response.UpdatedAt,
+		KeyRotation: api.KeyRotationPolicyDto{
+			RotateAfterSeconds:    int64(response.KeyRotationPolicy.RotateAfter / time.Second),
+			ExpireAfterSeconds:    int64(response.KeyRotationPolicy.ExpireAfter / time.Second),
+			PrePublishLeadSeconds: int64(response.KeyRotationPolicy.PrePublishLead / time.Second),
+		},
+		Passkeys: api.PasskeyPolicyDto{
+			AllowedAaguids:     response.PasskeyPolicy.AllowedAaguids,
+			RequireAttestation: response.PasskeyPolicy.RequireAttestation,
+			UserVerification:   string(response.PasskeyPolicy.UserVerification),
+		},
+		Lockout: api.LockoutPolicyDto{
+			MaxFailedAttempts:         response.LockoutPolicy.MaxFailedAttempts,
+			MaxFailedAttemptsPerIp:    response.LockoutPolicy.MaxFailedAttemptsPerIp,
+			LockoutDurationSeconds:    int64(response.LockoutPolicy.LockoutDuration / time.Second),
+			MaxLockoutDurationSeconds: int64(response.LockoutPolicy.MaxLockoutDuration / time.Second),
+			PermanentLockoutAfter:     response.LockoutPolicy.PermanentLockoutAfter,
+			NotifyUser:                response.LockoutPolicy.NotifyUser,
+		},
+		CreatedAt: response.CreatedAt,
+		UpdatedAt: response.UpdatedAt,
 	})
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -239,6 +265,12 @@ func PatchVirtualServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
 	var additionalAlgorithms *[]config.SigningAlgorithm
 	if dto.AdditionalSigningAlgorithms != nil {
 		converted := make([]config.SigningAlgorithm, len(*dto.AdditionalSigningAlgorithms))
@@ -255,9 +287,29 @@ func PatchVirtualServer(w http.ResponseWriter, r *http.Request) {
 		EnableRegistration:          dto.EnableRegistration,
 		Require2fa:                  dto.Require2fa,
 		RequireEmailVerification:    dto.RequireEmailVerification,
+		EnableEmailLogin:            dto.EnableEmailLogin,
+		TrustedDeviceDays:           dto.TrustedDeviceDays,
 		PrimarySigningAlgorithm:     (*config.SigningAlgorithm)(dto.PrimarySigningAlgorithm),
 		AdditionalSigningAlgorithms: additionalAlgorithms,
 	}
+	if dto.KeyRotation != nil {
+		command.KeyRotateAfter = secondsToDuration(dto.KeyRotation.RotateAfterSeconds)
+		command.KeyExpireAfter = secondsToDuration(dto.KeyRotation.ExpireAfterSeconds)
+		command.KeyPrePublishLead = secondsToDuration(dto.KeyRotation.PrePublishLeadSeconds)
+	}
+	if dto.Passkeys != nil {
+		command.PasskeyAllowedAaguids = dto.Passkeys.AllowedAaguids
+		command.PasskeyRequireAttestation = dto.Passkeys.RequireAttestation
+		command.PasskeyUserVerification = (*repositories.PasskeyUserVerification)(dto.Passkeys.UserVerification)
+	}
+	if dto.Lockout != nil {
+		command.LockoutMaxFailedAttempts = dto.Lockout.MaxFailedAttempts
+		command.LockoutMaxFailedAttemptsPerIp = dto.Lockout.MaxFailedAttemptsPerIp
+		command.LockoutDuration = secondsToDuration(dto.Lockout.LockoutDurationSeconds)
+		command.LockoutMaxDuration = secondsToDuration(dto.Lockout.MaxLockoutDurationSeconds)
+		command.LockoutPermanentAfter = dto.Lockout.PermanentLockoutAfter
+		command.LockoutNotifyUser = dto.Lockout.NotifyUser
+	}
 	_, err = mediatr.Send[*commands.PatchVirtualServerResponse](ctx, m, command)
 	if err != nil {
 		utils.HandleHttpError(w, err)
@@ -266,3 +318,217 @@ func PatchVirtualServer(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func secondsToDuration(seconds *int64) *time.Duration {
+	return utils.MapPtr(seconds, func(seconds int64) time.Duration {
+		return time.Duration(seconds) * time.Second
+	})
+}
+
+// ListSigningKeys lists the signing keys of a virtual server.
+// @Summary      List signing keys
+// @Tags         Admin
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Success      200  {object}  api.ListSigningKeysResponseDto
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/keys [get]
+func ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*queries.ListSigningKeysResponse](ctx, m, queries.ListSigningKeysQuery{
+		VirtualServerName: vsName,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	items := utils.MapSlice(response.Items, func(x queries.ListSigningKeysResponseItem) api.ListSigningKeysResponseItemDto {
+		return api.ListSigningKeysResponseItemDto{
+			Kid:         x.Kid,
+			Algorithm:   string(x.Algorithm),
+			State:       string(x.State),
+			CreatedAt:   x.CreatedAt,
+			ActivatesAt: x.ActivatesAt,
+			RotatesAt:   x.RotatesAt,
+			ExpiresAt:   x.ExpiresAt,
+			VerifyOnly:  x.VerifyOnly,
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(api.ListSigningKeysResponseDto{
+		Items: items,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// RotateSigningKeys replaces the active signing keys right away.
+// @Summary      Rotate signing keys
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        body  body  api.RotateSigningKeysRequestDto  false  "Algorithm to rotate"
+// @Success      200  {object}  api.RotateSigningKeysResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/keys/rotate [post]
+func RotateSigningKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	// the body is optional, an empty one rotates every algorithm
+	var dto api.RotateSigningKeysRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.RotateSigningKeysResponse](ctx, m, commands.RotateSigningKeys{
+		VirtualServerName: vsName,
+		Algorithm:         (*config.SigningAlgorithm)(dto.Algorithm),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(api.RotateSigningKeysResponseDto{
+		Keys: utils.MapSlice(response.Keys, func(x commands.RotateSigningKeysResponseKey) api.RotateSigningKeysResponseKeyDto {
+			return api.RotateSigningKeysResponseKeyDto{
+				Algorithm: string(x.Algorithm),
+				Kid:       x.Kid,
+			}
+		}),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// RevokeSigningKey removes a compromised signing key from the JWKS.
+// @Summary      Revoke signing key
+// @Tags         Admin
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        kid  path  string  true  "Key id"
+// @Success      200  {object}  api.RevokeSigningKeyResponseDto
+// @Failure      404  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/keys/{kid}/revoke [post]
+func RevokeSigningKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.RevokeSigningKeyResponse](ctx, m, commands.RevokeSigningKey{
+		VirtualServerName: vsName,
+		Kid:               mux.Vars(r)["kid"],
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(api.RevokeSigningKeyResponseDto{
+		ReplacementKid: response.ReplacementKid,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
+
+// ImportSigningKey imports an existing private key as a signing key.
+// @Summary      Import signing key
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        virtualServerName  path  string  true  "Virtual server name"  default(keyline)
+// @Param        body  body  api.ImportSigningKeyRequestDto  true  "Key to import"
+// @Success      201  {object}  api.ImportSigningKeyResponseDto
+// @Failure      400  {string}  string
+// @Failure      404  {string}  string
+// @Failure      409  {string}  string
+// @Router       /api/virtual-servers/{virtualServerName}/keys/import [post]
+func ImportSigningKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := middlewares.GetScope(ctx)
+
+	vsName, err := middlewares.GetVirtualServerName(ctx)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	var dto api.ImportSigningKeyRequestDto
+	err = json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	err = utils.ValidateDto(dto)
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.ImportSigningKeyResponse](ctx, m, commands.ImportSigningKey{
+		VirtualServerName: vsName,
+		Kid:               dto.Kid,
+		Algorithm:         config.SigningAlgorithm(dto.Algorithm),
+		PrivateKey:        dto.PrivateKey,
+		NotBefore:         dto.NotBefore,
+		NotAfter:          dto.NotAfter,
+		VerifyOnly:        dto.VerifyOnly,
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(api.ImportSigningKeyResponseDto{
+		Kid:   response.Kid,
+		State: string(response.State),
+	})
+	if err != nil {
+		utils.HandleHttpError(w, err)
+	}
+}
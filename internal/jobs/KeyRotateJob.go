@@ -98,7 +98,9 @@ func deleteOrphanKeys(
 		configured[alg] = true
 	}
 	for _, keyPair := range keyPairs {
-		if configured[keyPair.Algorithm()] {
+		// imported verify-only keys may use algorithms the virtual server
+		// no longer signs with, they go away when they expire
+		if configured[keyPair.Algorithm()] || keyPair.VerifyOnly() {
 			continue
 		}
 		logging.Logger.Infof("removing orphan key for virtual server %s, algorithm %s", server.Name(), keyPair.Algorithm())
@@ -109,43 +111,53 @@ func deleteOrphanKeys(
 	return nil
 }
 
+// generateNewKeys keeps a key for every configured algorithm and replaces it
+// according to the rotation policy of the virtual server. With a pre-publish
+// lead the successor is published ahead of time and takes over when the
+// current key rotates.
 func generateNewKeys(
 	keyPairs []services.KeyPair,
 	keyService services.KeyService,
 	server *repositories.VirtualServer,
 	clockService clock.Service,
 ) error {
-	algorithmsToRotate := make(map[config.SigningAlgorithm]bool)
-	for _, keyPair := range keyPairs {
-		algorithmsToRotate[keyPair.Algorithm()] = true
-	}
+	now := clockService.Now()
+	policy := server.KeyRotationPolicy()
 
+	var live []services.KeyPair
 	for _, keyPair := range keyPairs {
-		if keyPair.RotatesAt().After(clockService.Now()) {
-			algorithmsToRotate[keyPair.Algorithm()] = false
+		if !keyPair.ExpiresAt().Before(now) {
+			live = append(live, keyPair)
 		}
 	}
 
-	for alg, needsNewKey := range algorithmsToRotate {
-		if !needsNewKey {
+	for _, alg := range server.AllSigningAlgorithms() {
+		if _, pending := services.NextKeyPair(live, alg, now); pending {
 			continue
 		}
 
-		logging.Logger.Infof("generating new key for virtual server %s, algorithm %s", server.Name(), alg)
-		_, err := keyService.Generate(clockService, server.Name(), alg)
-		if err != nil {
-			return fmt.Errorf("generating key pair: %w", err)
-		}
-	}
+		active, ok := services.ActiveKeyPair(live, alg, now)
+		switch {
+		case !ok:
+			logging.Logger.Infof("seeding initial key for virtual server %s, algorithm %s", server.Name(), alg)
+			_, err := keyService.Generate(clockService, server.Name(), alg, policy)
+			if err != nil {
+				return fmt.Errorf("generating key pair: %w", err)
+			}
 
-	for _, alg := range server.AllSigningAlgorithms() {
-		if _, known := algorithmsToRotate[alg]; known {
-			continue
-		}
-		logging.Logger.Infof("seeding initial key for virtual server %s, algorithm %s", server.Name(), alg)
-		_, err := keyService.Generate(clockService, server.Name(), alg)
-		if err != nil {
-			return fmt.Errorf("generating key pair: %w", err)
+		case !now.Before(active.RotatesAt()):
+			logging.Logger.Infof("generating new key for virtual server %s, algorithm %s", server.Name(), alg)
+			_, err := keyService.Generate(clockService, server.Name(), alg, policy)
+			if err != nil {
+				return fmt.Errorf("generating key pair: %w", err)
+			}
+
+		case policy.PrePublishLead > 0 && !now.Before(active.RotatesAt().Add(-policy.PrePublishLead)):
+			logging.Logger.Infof("pre-publishing next key for virtual server %s, algorithm %s", server.Name(), alg)
+			_, err := keyService.PrePublish(clockService, server.Name(), alg, policy, active.RotatesAt())
+			if err != nil {
+				return fmt.Errorf("pre-publishing key pair: %w", err)
+			}
 		}
 	}
 
@@ -1,7 +1,10 @@
 package jobs
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/caching"
 	"github.com/The127/Keyline/internal/logging"
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/services"
@@ -34,7 +37,7 @@ func (s *KeyRotateJobSuite) TestDeletesExpiredKeys() {
 
 	clockSetter(time.Now().Add(-time.Hour * 24 * 365 * 10))
 	signingAlgorithm := config.SigningAlgorithmEdDSA
-	keyPair, err := services.GetKeyStrategy(signingAlgorithm).Generate(clockService)
+	keyPair, err := services.GetKeyStrategy(signingAlgorithm).Generate(clockService, repositories.DefaultKeyRotationPolicy())
 	s.Require().NoError(err)
 
 	clockSetter(time.Now())
@@ -59,9 +62,9 @@ func (s *KeyRotateJobSuite) TestDeletesOrphanKeys() {
 
 	clockService, _ := clock.NewMockClock(time.Now())
 
-	eddsaKey, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService)
+	eddsaKey, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, repositories.DefaultKeyRotationPolicy())
 	s.Require().NoError(err)
-	rs256Key, err := services.GetKeyStrategy(config.SigningAlgorithmRS256).Generate(clockService)
+	rs256Key, err := services.GetKeyStrategy(config.SigningAlgorithmRS256).Generate(clockService, repositories.DefaultKeyRotationPolicy())
 	s.Require().NoError(err)
 
 	// VS is configured with EdDSA only; RS256 key is an orphan
@@ -87,9 +90,9 @@ func (s *KeyRotateJobSuite) TestDoesNotDeleteConfiguredKeys() {
 
 	clockService, _ := clock.NewMockClock(time.Now())
 
-	eddsaKey, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService)
+	eddsaKey, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, repositories.DefaultKeyRotationPolicy())
 	s.Require().NoError(err)
-	rs256Key, err := services.GetKeyStrategy(config.SigningAlgorithmRS256).Generate(clockService)
+	rs256Key, err := services.GetKeyStrategy(config.SigningAlgorithmRS256).Generate(clockService, repositories.DefaultKeyRotationPolicy())
 	s.Require().NoError(err)
 
 	// VS is configured with both algorithms — nothing is an orphan
@@ -106,3 +109,140 @@ func (s *KeyRotateJobSuite) TestDoesNotDeleteConfiguredKeys() {
 	// assert
 	s.Require().NoError(err)
 }
+
+func (s *KeyRotateJobSuite) TestKeepsVerifyOnlyKeysOfOtherAlgorithms() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	_, legacyKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	serialized, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Export(legacyKey)
+	s.Require().NoError(err)
+
+	keyService := services.NewKeyService(caching.NewMemoryCache[services.KeyCacheKey, services.KeyCacheEntry](), services.NewMemoryKeyStore(), clockService)
+	imported, err := keyService.Import(clockService, "vs-name", config.SigningAlgorithmEdDSA, repositories.DefaultKeyRotationPolicy(), services.ImportedKey{
+		Kid:        "legacy",
+		PrivateKey: serialized,
+		NotAfter:   clockService.Now().Add(time.Hour),
+		VerifyOnly: true,
+	})
+	s.Require().NoError(err)
+
+	// VS only signs with RS256, the imported EdDSA key is kept for verification
+	vs := repositories.NewVirtualServer("vs-name", "VS Name")
+	vs.SetPrimarySigningAlgorithm(config.SigningAlgorithmRS256)
+	vs.SetAdditionalSigningAlgorithms([]config.SigningAlgorithm{})
+
+	keyStore := mocks.NewMockKeyStore(ctrl)
+
+	// act
+	err = deleteOrphanKeys([]services.KeyPair{imported}, keyStore, vs)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *KeyRotateJobSuite) newVirtualServer(policy repositories.KeyRotationPolicy) *repositories.VirtualServer {
+	vs := repositories.NewVirtualServer("vs-name", "VS Name")
+	vs.SetPrimarySigningAlgorithm(config.SigningAlgorithmEdDSA)
+	vs.SetKeyRotationPolicy(policy)
+	return vs
+}
+
+func (s *KeyRotateJobSuite) TestSeedsMissingKeys() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+	vs := s.newVirtualServer(policy)
+
+	keyService := mocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().Generate(clockService, "vs-name", config.SigningAlgorithmEdDSA, policy)
+
+	// act
+	err := generateNewKeys(nil, keyService, vs, clockService)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *KeyRotateJobSuite) TestKeepsKeyBeforeRotation() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, clockSetter := clock.NewMockClock(time.Now())
+	policy := repositories.KeyRotationPolicy{
+		RotateAfter:    time.Hour * 24 * 10,
+		ExpireAfter:    time.Hour * 24 * 20,
+		PrePublishLead: time.Hour * 24,
+	}
+	vs := s.newVirtualServer(policy)
+
+	keyPair, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, policy)
+	s.Require().NoError(err)
+	clockSetter(time.Now().Add(time.Hour * 24 * 8))
+
+	keyService := mocks.NewMockKeyService(ctrl) // nothing to generate
+
+	// act
+	err = generateNewKeys([]services.KeyPair{keyPair}, keyService, vs, clockService)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *KeyRotateJobSuite) TestPrePublishesSuccessor() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, clockSetter := clock.NewMockClock(time.Now())
+	policy := repositories.KeyRotationPolicy{
+		RotateAfter:    time.Hour * 24 * 10,
+		ExpireAfter:    time.Hour * 24 * 20,
+		PrePublishLead: time.Hour * 24 * 2,
+	}
+	vs := s.newVirtualServer(policy)
+
+	keyPair, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, policy)
+	s.Require().NoError(err)
+	clockSetter(time.Now().Add(time.Hour * 24 * 9))
+
+	keyService := mocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().PrePublish(clockService, "vs-name", config.SigningAlgorithmEdDSA, policy, keyPair.RotatesAt())
+
+	// act
+	err = generateNewKeys([]services.KeyPair{keyPair}, keyService, vs, clockService)
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *KeyRotateJobSuite) TestGeneratesKeyAfterRotation() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	clockService, clockSetter := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+	vs := s.newVirtualServer(policy)
+
+	keyPair, err := services.GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, policy)
+	s.Require().NoError(err)
+	clockSetter(keyPair.RotatesAt().Add(time.Minute))
+
+	keyService := mocks.NewMockKeyService(ctrl)
+	keyService.EXPECT().Generate(clockService, "vs-name", config.SigningAlgorithmEdDSA, policy)
+
+	// act
+	err = generateNewKeys([]services.KeyPair{keyPair}, keyService, vs, clockService)
+
+	// assert
+	s.Require().NoError(err)
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+)
+
+// LdapSyncJob synchronizes every LDAP provider whose sync interval has
+// elapsed. Providers without a sync interval are only synchronized on
+// demand.
+func LdapSyncJob() JobFn {
+	return func(ctx context.Context) error {
+		scope := middlewares.GetScope(ctx).NewScope()
+		defer utils.PanicOnError(scope.Close, "failed to close scope")
+
+		dbContext := ioc.GetDependency[database.Context](scope)
+		now := ioc.GetDependency[clock.Service](scope).Now()
+
+		virtualServerFilter := repositories.NewVirtualServerFilter()
+		virtualServers, _, err := dbContext.VirtualServers().List(ctx, virtualServerFilter)
+		if err != nil {
+			return fmt.Errorf("listing virtual servers: %w", err)
+		}
+
+		for _, virtualServer := range virtualServers {
+			ldapProviderFilter := repositories.NewLdapProviderFilter().VirtualServerId(virtualServer.Id())
+			ldapProviders, err := dbContext.LdapProviders().List(ctx, ldapProviderFilter)
+			if err != nil {
+				return fmt.Errorf("listing ldap providers: %w", err)
+			}
+
+			for _, ldapProvider := range ldapProviders {
+				if !ldapProvider.SyncDue(now) {
+					continue
+				}
+
+				err = syncLdapProvider(ctx, scope, virtualServer, ldapProvider)
+				if err != nil {
+					// an unreachable directory must not hold up the others
+					logging.Logger.Errorf("syncing ldap provider %s of virtual server %s: %v", ldapProvider.Name(), virtualServer.Name(), err)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+func syncLdapProvider(ctx context.Context, dp *ioc.DependencyProvider, virtualServer *repositories.VirtualServer, ldapProvider *repositories.LdapProvider) error {
+	scope := dp.NewScope()
+	defer utils.PanicOnError(scope.Close, "failed to close scope")
+
+	ctx = middlewares.ContextWithScope(ctx, scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	response, err := mediatr.Send[*commands.SyncLdapProviderResponse](ctx, m, commands.SyncLdapProvider{
+		VirtualServerName: virtualServer.Name(),
+		LdapProviderId:    ldapProvider.Id(),
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Infof(
+		"synced ldap provider %s: %d users created, %d updated, %d skipped",
+		ldapProvider.Name(),
+		response.UsersCreated,
+		response.UsersUpdated,
+		response.UsersSkipped,
+	)
+
+	return nil
+}
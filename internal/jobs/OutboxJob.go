@@ -9,7 +9,9 @@ import (
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/services/outbox"
 	"github.com/The127/Keyline/utils"
+	"time"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 )
 
@@ -19,8 +21,9 @@ func OutboxSendingJob(dp *ioc.DependencyProvider) JobFn {
 		defer utils.PanicOnError(scope.Close, "failed to close scope")
 		ctx = middlewares.ContextWithScope(ctx, scope)
 		dbContext := ioc.GetDependency[database.Context](scope)
+		clockService := ioc.GetDependency[clock.Service](scope)
 
-		filter := repositories.NewOutboxMessageFilter()
+		filter := repositories.NewOutboxMessageFilter().DueAt(clockService.Now())
 		outboxMessages, err := dbContext.OutboxMessages().List(ctx, filter)
 		if err != nil {
 			return fmt.Errorf("failed to list outbox messages: %w", err)
@@ -32,6 +35,12 @@ func OutboxSendingJob(dp *ioc.DependencyProvider) JobFn {
 				// we don't want to stop the whole job if one message fails
 				// failed messages will be retried later
 				logging.Logger.Errorf("failed handling message: %v", err)
+				recordFailure(message, err, clockService.Now(), dbContext)
+			}
+
+			err = dbContext.SaveChanges(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to save changes: %w", err)
 			}
 		}
 
@@ -52,3 +61,16 @@ func handleMessage(ctx context.Context, message *repositories.OutboxMessage, dbC
 
 	return nil
 }
+
+// recordFailure schedules the message for another attempt, or dead letters
+// it once it has used up its attempts. Dead lettered messages are kept with
+// their last error.
+func recordFailure(message *repositories.OutboxMessage, err error, now time.Time, dbContext database.Context) {
+	message.RecordFailure(err, now)
+	if message.Exhausted() {
+		logging.Logger.Errorf("dead lettering outbox message %s of type %s after %d attempts: %v", message.Id(), message.Type(), message.Attempts(), err)
+		message.DeadLetter(now)
+	}
+
+	dbContext.OutboxMessages().Update(message)
+}
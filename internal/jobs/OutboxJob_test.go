@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"errors"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/mocks"
+	"github.com/The127/Keyline/internal/repositories"
+	repoMocks "github.com/The127/Keyline/internal/repositories/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type OutboxJobSuite struct {
+	suite.Suite
+}
+
+func TestOutboxJobSuite(t *testing.T) {
+	t.Parallel()
+	logging.Init()
+	suite.Run(t, new(OutboxJobSuite))
+}
+
+func (s *OutboxJobSuite) newMessage() *repositories.OutboxMessage {
+	message, err := repositories.NewOutboxMessage(&messages.SendEmailMessage{To: "user@mail"})
+	s.Require().NoError(err)
+	return message
+}
+
+func (s *OutboxJobSuite) TestSchedulesFailedMessageForRetry() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+	message := s.newMessage()
+
+	outboxMessageRepository := repoMocks.NewMockOutboxMessageRepository(ctrl)
+	outboxMessageRepository.EXPECT().Update(message)
+	dbContext := mocks.NewMockContext(ctrl)
+	dbContext.EXPECT().OutboxMessages().Return(outboxMessageRepository).AnyTimes()
+
+	// act
+	recordFailure(message, errors.New("unreachable"), now, dbContext)
+
+	// assert
+	s.Equal(1, message.Attempts())
+	s.Require().NotNil(message.NextAttemptAt())
+	s.True(message.NextAttemptAt().After(now))
+	s.Equal("unreachable", *message.LastError())
+}
+
+func (s *OutboxJobSuite) TestBacksOffBetweenAttempts() {
+	// arrange
+	now := time.Now()
+	message := s.newMessage()
+
+	// act
+	message.RecordFailure(errors.New("unreachable"), now)
+	firstDelay := message.NextAttemptAt().Sub(now)
+	message.RecordFailure(errors.New("unreachable"), now)
+	secondDelay := message.NextAttemptAt().Sub(now)
+
+	// assert
+	s.Equal(2*firstDelay, secondDelay)
+}
+
+func (s *OutboxJobSuite) TestDeadLettersExhaustedScimMessage() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+	message, err := repositories.NewOutboxMessage(&messages.ScimProvisionMessage{Username: "user"})
+	s.Require().NoError(err)
+	for range repositories.MaxOutboxMessageAttempts - 1 {
+		message.RecordFailure(errors.New("unreachable"), now)
+	}
+
+	outboxMessageRepository := repoMocks.NewMockOutboxMessageRepository(ctrl)
+	outboxMessageRepository.EXPECT().Update(message)
+	dbContext := mocks.NewMockContext(ctrl)
+	dbContext.EXPECT().OutboxMessages().Return(outboxMessageRepository).AnyTimes()
+
+	// act
+	recordFailure(message, errors.New("unreachable"), now, dbContext)
+
+	// assert
+	s.True(message.Exhausted())
+	s.Require().NotNil(message.DeadLetteredAt())
+	s.Nil(message.NextAttemptAt())
+	s.Equal("unreachable", *message.LastError())
+}
+
+func (s *OutboxJobSuite) TestRetriesMailMessageWithoutLimit() {
+	// arrange
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	now := time.Now()
+	message := s.newMessage()
+	for range repositories.MaxOutboxMessageAttempts {
+		message.RecordFailure(errors.New("unreachable"), now)
+	}
+
+	outboxMessageRepository := repoMocks.NewMockOutboxMessageRepository(ctrl)
+	outboxMessageRepository.EXPECT().Update(message)
+	dbContext := mocks.NewMockContext(ctrl)
+	dbContext.EXPECT().OutboxMessages().Return(outboxMessageRepository).AnyTimes()
+
+	// act
+	recordFailure(message, errors.New("unreachable"), now, dbContext)
+
+	// assert
+	s.False(message.Exhausted())
+	s.Nil(message.DeadLetteredAt())
+	s.Require().NotNil(message.NextAttemptAt())
+}
@@ -3,6 +3,8 @@ package jsonTypes
 import (
 	"time"
 
+	"github.com/The127/Keyline/internal/repositories"
+
 	"github.com/google/uuid"
 )
 
@@ -19,6 +21,10 @@ type CodeInfo struct {
 	// verification is required at /token.
 	CodeChallenge       string
 	CodeChallengeMethod string
+	// Acr is the level of the acr_values the session satisfied, it is
+	// empty if none were requested.
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 func NewCodeInfo(
@@ -31,6 +37,8 @@ func NewCodeInfo(
 	redirectUri string,
 	codeChallenge string,
 	codeChallengeMethod string,
+	acr string,
+	authenticationMethods []repositories.AuthenticationMethod,
 ) CodeInfo {
 	return CodeInfo{
 		VirtualServerName:   virtualServerName,
@@ -42,5 +50,8 @@ func NewCodeInfo(
 		RedirectUri:         redirectUri,
 		CodeChallenge:       codeChallenge,
 		CodeChallengeMethod: codeChallengeMethod,
+
+		Acr:                   acr,
+		AuthenticationMethods: authenticationMethods,
 	}
 }
@@ -1,5 +1,9 @@
 package jsonTypes
 
+import (
+	"github.com/The127/Keyline/internal/repositories"
+)
+
 type DeviceCodeStatus string
 
 const (
@@ -15,4 +19,7 @@ type DeviceCodeInfo struct {
 	Status            string
 	UserId            string
 	UserCode          string
+	// AuthenticationMethods are the methods the user completed when
+	// approving the device.
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
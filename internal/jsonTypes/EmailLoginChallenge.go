@@ -0,0 +1,17 @@
+package jsonTypes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailLoginChallenge is what was sent to the user for an email login. It
+// is stored under the token of the login session it belongs to, the user
+// proves access to their mailbox with either the code or the link token.
+type EmailLoginChallenge struct {
+	UserId    uuid.UUID `json:"userId"`
+	Code      string    `json:"code"`
+	LinkToken string    `json:"linkToken"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,13 @@
+package jsonTypes
+
+import "github.com/google/uuid"
+
+// IdentityProviderLoginState is kept while the user is away at an upstream
+// identity provider and is looked up again by the state parameter on the
+// callback.
+type IdentityProviderLoginState struct {
+	LoginSessionToken  string    `json:"loginSessionToken"`
+	IdentityProviderId uuid.UUID `json:"identityProviderId"`
+	CodeVerifier       string    `json:"codeVerifier"`
+	Nonce              string    `json:"nonce"`
+}
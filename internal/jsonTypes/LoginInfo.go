@@ -14,22 +14,76 @@ const (
 	LoginStepEmailVerification    LoginStep = "emailVerification"
 	LoginStepOnboardTotp          LoginStep = "onboardTotp"
 	LoginStepVerifyTotp           LoginStep = "verifyTotp"
+	LoginStepSelectSecondFactor   LoginStep = "selectSecondFactor"
+	LoginStepVerifyPasskey        LoginStep = "verifyPasskey"
+	LoginStepVerifySms            LoginStep = "verifySms"
 	LoginStepPasskey              LoginStep = "passkey"
+	LoginStepEmailLogin           LoginStep = "emailLogin"
+	LoginStepIdentityProvider     LoginStep = "identityProvider"
 	LoginStepFinish               LoginStep = "finish"
+
+	// LoginStepStepUp starts a login for a user that already has a session
+	// but has to complete more methods for the requested acr. It is never
+	// shown to the user, DetermineNextLoginStep moves past it right away.
+	LoginStepStepUp LoginStep = "stepUp"
+)
+
+// SecondFactor is a factor a user can verify after the password.
+type SecondFactor string
+
+const (
+	SecondFactorTotp    SecondFactor = "totp"
+	SecondFactorPasskey SecondFactor = "passkey"
+	SecondFactorSms     SecondFactor = "sms"
 )
 
 type LoginInfo struct {
 	Step                     LoginStep `json:"step"`
+	ApplicationId            uuid.UUID `json:"applicationId"`
 	ApplicationDisplayName   string    `json:"applicationDisplayName"`
 	VirtualServerDisplayName string    `json:"virtualServerDisplayName"`
 	VirtualServerName        string    `json:"virtualServerName"`
 	VirtualServerId          uuid.UUID `json:"virtualServerId"`
 	RegistrationEnabled      bool      `json:"registrationEnabled"`
+	EmailLoginEnabled        bool      `json:"emailLoginEnabled"`
+	TrustDeviceEnabled       bool      `json:"trustDeviceEnabled"`
 	UserId                   uuid.UUID `json:"userId"`
 	OriginalUrl              string    `json:"originalUrl"`
 	TotpSecret               string    `json:"totpSecret"`
 	DeviceCode               string    `json:"deviceCode"`
 	FailedPasswordAttempts   int       `json:"failedPasswordAttempts"`
+	// SecondFactors are the factors the user has set up, SecondFactor is
+	// the one they picked to verify.
+	SecondFactors []SecondFactor `json:"secondFactors"`
+	SecondFactor  SecondFactor   `json:"secondFactor"`
+	// AuthenticationMethods are the methods the user completed so far,
+	// RequiredAuthenticationMethods the ones the requested acr needs.
+	AuthenticationMethods         []repositories.AuthenticationMethod `json:"authenticationMethods"`
+	RequiredAuthenticationMethods []repositories.AuthenticationMethod `json:"requiredAuthenticationMethods"`
+	// TrustedDeviceId is the trusted device the browser presented, its
+	// logins skip the second factor.
+	TrustedDeviceId uuid.UUID `json:"trustedDeviceId"`
+	// ClientIp and UserAgent are taken from the request that verified the
+	// first factor, MFA rules are evaluated against them.
+	ClientIp  string `json:"clientIp"`
+	UserAgent string `json:"userAgent"`
+	// PasswordExpired tells why the temporaryPassword step asks for a new
+	// password, the max age rule of the virtual server forces it.
+	PasswordExpired bool `json:"passwordExpired"`
+	// UpstreamMultiFactor tells whether the upstream identity provider of
+	// a brokered login reported a login with more than one factor.
+	UpstreamMultiFactor bool `json:"upstreamMultiFactor"`
+}
+
+// MissingAuthenticationMethods returns the methods the user still has to
+// complete to reach the requested acr.
+func (l *LoginInfo) MissingAuthenticationMethods() []repositories.AuthenticationMethod {
+	return repositories.MissingAuthenticationMethods(l.RequiredAuthenticationMethods, l.AuthenticationMethods)
+}
+
+// AddAuthenticationMethod records that the user completed the method.
+func (l *LoginInfo) AddAuthenticationMethod(method repositories.AuthenticationMethod) {
+	l.AuthenticationMethods = repositories.AddAuthenticationMethod(l.AuthenticationMethods, method)
 }
 
 func NewLoginInfo(virtualServer *repositories.VirtualServer, application *repositories.Application, originalUrl string) LoginInfo {
@@ -39,6 +93,9 @@ func NewLoginInfo(virtualServer *repositories.VirtualServer, application *reposi
 		VirtualServerName:        virtualServer.Name(),
 		VirtualServerId:          virtualServer.Id(),
 		RegistrationEnabled:      virtualServer.EnableRegistration(),
+		EmailLoginEnabled:        virtualServer.EnableEmailLogin(),
+		TrustDeviceEnabled:       virtualServer.TrustedDeviceDays() > 0,
+		ApplicationId:            application.Id(),
 		ApplicationDisplayName:   application.DisplayName(),
 		OriginalUrl:              originalUrl,
 	}
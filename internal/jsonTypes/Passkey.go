@@ -12,4 +12,7 @@ type PasskeyLoginChallenge struct {
 	Id                uuid.UUID `json:"id"`
 	Challenge         string    `json:"challenge"`
 	LoginSessionToken string    `json:"loginSessionToken"`
+	// UserId is set if the user was known when the login started, it is
+	// nil for logins with discoverable credentials.
+	UserId uuid.UUID `json:"userId"`
 }
@@ -1,12 +1,23 @@
 package jsonTypes
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/google/uuid"
+)
 
 type RefreshTokenInfo struct {
 	VirtualServerName string
 	UserId            uuid.UUID
 	GrantedScopes     []string
 	ClientId          string
+	IssuedAt          time.Time
+	// Acr and AuthenticationMethods are kept so that refreshed tokens
+	// report the same login as the original ones.
+	Acr                   string
+	AuthenticationMethods []repositories.AuthenticationMethod
 }
 
 func NewRefreshTokenInfo(
@@ -14,11 +25,18 @@ func NewRefreshTokenInfo(
 	clientId string,
 	userId uuid.UUID,
 	grantedScopes []string,
+	issuedAt time.Time,
+	acr string,
+	authenticationMethods []repositories.AuthenticationMethod,
 ) RefreshTokenInfo {
 	return RefreshTokenInfo{
 		VirtualServerName: virtualServerName,
 		ClientId:          clientId,
 		UserId:            userId,
 		GrantedScopes:     grantedScopes,
+		IssuedAt:          issuedAt,
+
+		Acr:                   acr,
+		AuthenticationMethods: authenticationMethods,
 	}
 }
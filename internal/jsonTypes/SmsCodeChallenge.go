@@ -0,0 +1,17 @@
+package jsonTypes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SmsCodeChallenge is a code that was sent to a phone number. It is stored
+// under the login session token when the SMS second factor is verified and
+// under the user id when a phone number is registered.
+type SmsCodeChallenge struct {
+	UserId      uuid.UUID `json:"userId"`
+	PhoneNumber string    `json:"phoneNumber"`
+	Code        string    `json:"code"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
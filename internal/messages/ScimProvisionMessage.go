@@ -0,0 +1,27 @@
+package messages
+
+import (
+	"encoding/json"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// ScimProvisionMessage asks for one user to be brought up to date at a
+// provisioning target. The delivery reads the current state of the user, so
+// messages may be delivered in any order. Username is kept for users that
+// are deleted by then.
+type ScimProvisionMessage struct {
+	VirtualServerId      uuid.UUID `json:"virtualServerId"`
+	ProvisioningTargetId uuid.UUID `json:"provisioningTargetId"`
+	UserId               uuid.UUID `json:"userId"`
+	Username             string    `json:"username"`
+}
+
+func (m *ScimProvisionMessage) OutboxMessageType() repositories.OutboxMessageType {
+	return repositories.ScimProvisionOutboxMessageType
+}
+
+func (m *ScimProvisionMessage) Serialize() ([]byte, error) {
+	return json.Marshal(m)
+}
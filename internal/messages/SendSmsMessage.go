@@ -0,0 +1,22 @@
+package messages
+
+import (
+	"encoding/json"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type SendSmsMessage struct {
+	VirtualServerId uuid.UUID `json:"virtualServerId"`
+	To              string    `json:"to"`
+	Body            string    `json:"body"`
+}
+
+func (m *SendSmsMessage) OutboxMessageType() repositories.OutboxMessageType {
+	return repositories.SendSmsOutboxMessageType
+}
+
+func (m *SendSmsMessage) Serialize() ([]byte, error) {
+	return json.Marshal(m)
+}
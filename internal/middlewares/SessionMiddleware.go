@@ -19,6 +19,8 @@ type CurrentSession struct {
 	userId    uuid.UUID
 	sessionId uuid.UUID
 	createdAt time.Time
+
+	authenticationMethods []string
 }
 
 func (s *CurrentSession) UserId() uuid.UUID {
@@ -33,6 +35,12 @@ func (s *CurrentSession) CreatedAt() time.Time {
 	return s.createdAt
 }
 
+// AuthenticationMethods returns the methods the user completed in the
+// login that created the session.
+func (s *CurrentSession) AuthenticationMethods() []string {
+	return s.authenticationMethods
+}
+
 type currentSessionCtxKeyType string
 
 const (
@@ -95,6 +103,8 @@ func SessionMiddleware() mux.MiddlewareFunc {
 					userId:    session.userId,
 					sessionId: tokenId,
 					createdAt: session.createdAt,
+
+					authenticationMethods: session.authenticationMethods,
 				}
 				r = r.WithContext(ContextWithSession(r.Context(), currentSession))
 			}
@@ -133,12 +143,12 @@ func DeleteSession(w http.ResponseWriter, r *http.Request, vsName string) error
 	return nil
 }
 
-func CreateSession(w http.ResponseWriter, r *http.Request, vsName string, userId uuid.UUID) error {
+func CreateSession(w http.ResponseWriter, r *http.Request, vsName string, userId uuid.UUID, authenticationMethods []string) error {
 	ctx := r.Context()
 	scope := GetScope(ctx)
 
 	sessionService := ioc.GetDependency[SessionService](scope)
-	sessionToken, err := sessionService.NewSession(ctx, vsName, userId)
+	sessionToken, err := sessionService.NewSession(ctx, vsName, userId, authenticationMethods)
 	if err != nil {
 		return fmt.Errorf("creating session: %w", err)
 	}
@@ -153,6 +163,16 @@ func ClearSessionCookie(w http.ResponseWriter, vsName string) {
 	setCookie(w, GetSessionCookieName(vsName), "", -1)
 }
 
+// GetTrustedDeviceCookieName is the cookie that marks a browser the user
+// chose to trust, it outlives the session on purpose.
+func GetTrustedDeviceCookieName(realmName string) string {
+	return fmt.Sprintf("keylineTrustedDevice_%s", realmName)
+}
+
+func SetTrustedDeviceCookie(w http.ResponseWriter, vsName string, value string, maxAge int) {
+	setCookie(w, GetTrustedDeviceCookieName(vsName), value, maxAge)
+}
+
 func setCookie(w http.ResponseWriter, name string, value string, maxAge int) {
 	cookie := http.Cookie{
 		Name:     name,
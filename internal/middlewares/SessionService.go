@@ -12,13 +12,18 @@ type Session struct {
 	userId       uuid.UUID
 	hashedSecret string
 	createdAt    time.Time
+
+	// authenticationMethods are the "amr" values of the login that created
+	// the session
+	authenticationMethods []string
 }
 
-func NewSession(userId uuid.UUID, hashedSecret string, createdAt time.Time) *Session {
+func NewSession(userId uuid.UUID, hashedSecret string, createdAt time.Time, authenticationMethods []string) *Session {
 	return &Session{
-		userId:       userId,
-		hashedSecret: hashedSecret,
-		createdAt:    createdAt,
+		userId:                userId,
+		hashedSecret:          hashedSecret,
+		createdAt:             createdAt,
+		authenticationMethods: authenticationMethods,
 	}
 }
 
@@ -30,8 +35,12 @@ func (s *Session) HashedSecret() string {
 	return s.hashedSecret
 }
 
+func (s *Session) AuthenticationMethods() []string {
+	return s.authenticationMethods
+}
+
 type SessionService interface {
 	GetSession(ctx context.Context, virtualServerName string, id uuid.UUID) (*Session, error)
-	NewSession(ctx context.Context, virtualServerName string, userId uuid.UUID) (*utils.SplitToken, error)
+	NewSession(ctx context.Context, virtualServerName string, userId uuid.UUID, authenticationMethods []string) (*utils.SplitToken, error)
 	DeleteSession(ctx context.Context, virtualServerName string, id uuid.UUID) error
 }
@@ -11,9 +11,9 @@ package mocks
 
 import (
 	context "context"
-	repositories "github.com/The127/Keyline/internal/repositories"
 	reflect "reflect"
 
+	repositories "github.com/The127/Keyline/internal/repositories"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -111,6 +111,20 @@ func (mr *MockContextMockRecorder) Files() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Files", reflect.TypeOf((*MockContext)(nil).Files))
 }
 
+// GroupMembers mocks base method.
+func (m *MockContext) GroupMembers() repositories.GroupMemberRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GroupMembers")
+	ret0, _ := ret[0].(repositories.GroupMemberRepository)
+	return ret0
+}
+
+// GroupMembers indicates an expected call of GroupMembers.
+func (mr *MockContextMockRecorder) GroupMembers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupMembers", reflect.TypeOf((*MockContext)(nil).GroupMembers))
+}
+
 // GroupRoles mocks base method.
 func (m *MockContext) GroupRoles() repositories.GroupRoleRepository {
 	m.ctrl.T.Helper()
@@ -139,6 +153,34 @@ func (mr *MockContextMockRecorder) Groups() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Groups", reflect.TypeOf((*MockContext)(nil).Groups))
 }
 
+// IdentityProviders mocks base method.
+func (m *MockContext) IdentityProviders() repositories.IdentityProviderRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdentityProviders")
+	ret0, _ := ret[0].(repositories.IdentityProviderRepository)
+	return ret0
+}
+
+// IdentityProviders indicates an expected call of IdentityProviders.
+func (mr *MockContextMockRecorder) IdentityProviders() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdentityProviders", reflect.TypeOf((*MockContext)(nil).IdentityProviders))
+}
+
+// LdapProviders mocks base method.
+func (m *MockContext) LdapProviders() repositories.LdapProviderRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LdapProviders")
+	ret0, _ := ret[0].(repositories.LdapProviderRepository)
+	return ret0
+}
+
+// LdapProviders indicates an expected call of LdapProviders.
+func (mr *MockContextMockRecorder) LdapProviders() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LdapProviders", reflect.TypeOf((*MockContext)(nil).LdapProviders))
+}
+
 // OutboxMessages mocks base method.
 func (m *MockContext) OutboxMessages() repositories.OutboxMessageRepository {
 	m.ctrl.T.Helper()
@@ -181,6 +223,20 @@ func (mr *MockContextMockRecorder) Projects() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Projects", reflect.TypeOf((*MockContext)(nil).Projects))
 }
 
+// ProvisioningTargets mocks base method.
+func (m *MockContext) ProvisioningTargets() repositories.ProvisioningTargetRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProvisioningTargets")
+	ret0, _ := ret[0].(repositories.ProvisioningTargetRepository)
+	return ret0
+}
+
+// ProvisioningTargets indicates an expected call of ProvisioningTargets.
+func (mr *MockContextMockRecorder) ProvisioningTargets() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProvisioningTargets", reflect.TypeOf((*MockContext)(nil).ProvisioningTargets))
+}
+
 // ResourceServerScopes mocks base method.
 func (m *MockContext) ResourceServerScopes() repositories.ResourceServerScopeRepository {
 	m.ctrl.T.Helper()
@@ -251,6 +307,20 @@ func (mr *MockContextMockRecorder) Sessions() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sessions", reflect.TypeOf((*MockContext)(nil).Sessions))
 }
 
+// SigningKeys mocks base method.
+func (m *MockContext) SigningKeys() repositories.SigningKeyRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SigningKeys")
+	ret0, _ := ret[0].(repositories.SigningKeyRepository)
+	return ret0
+}
+
+// SigningKeys indicates an expected call of SigningKeys.
+func (mr *MockContextMockRecorder) SigningKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SigningKeys", reflect.TypeOf((*MockContext)(nil).SigningKeys))
+}
+
 // Templates mocks base method.
 func (m *MockContext) Templates() repositories.TemplateRepository {
 	m.ctrl.T.Helper()
@@ -0,0 +1,71 @@
+package password
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+
+	"github.com/The127/ioc"
+)
+
+// breachedPolicy rejects passwords that appear in known data breaches at
+// least Threshold times. By default passwords are accepted if the breaches
+// can not be looked up, an unreachable api must not keep users from setting
+// one. FailClosed rejects them instead.
+type breachedPolicy struct {
+	Threshold  int  `json:"threshold"`
+	FailClosed bool `json:"failClosed"`
+}
+
+func (p *breachedPolicy) GetPasswordRuleType() repositories.PasswordRuleType {
+	return repositories.PasswordRuleTypeBreached
+}
+
+func (p *breachedPolicy) Serialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize breached rule: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+func (p *breachedPolicy) Validate(string) error {
+	return nil
+}
+
+func (p *breachedPolicy) ValidateForUser(ctx context.Context, password string, _ *repositories.User) error {
+	scope := middlewares.GetScope(ctx)
+	checker := ioc.GetDependency[services.BreachedPasswordChecker](scope)
+
+	breachCount, err := checker.BreachCount(ctx, password)
+	switch {
+	case errors.Is(err, services.ErrBreachedPasswordsNotConfigured):
+		logging.Logger.Warnf("breached password rule is set up but %v", err)
+		return p.lookupFailed()
+
+	case err != nil:
+		logging.Logger.Warnf("failed to look up breached password: %v", err)
+		return p.lookupFailed()
+	}
+
+	return p.check(breachCount)
+}
+
+func (p *breachedPolicy) lookupFailed() error {
+	if p.FailClosed {
+		return errors.New("password could not be checked against known data breaches")
+	}
+	return nil
+}
+
+func (p *breachedPolicy) check(breachCount int) error {
+	if breachCount >= p.Threshold {
+		return fmt.Errorf("password appeared in %d known data breaches", breachCount)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+	"testing"
+
+	"github.com/The127/ioc"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingBreachedPasswordChecker struct {
+}
+
+func (c *failingBreachedPasswordChecker) BreachCount(context.Context, string) (int, error) {
+	return 0, errors.New("range api unavailable")
+}
+
+func TestBreachedPolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		breachCount int
+		threshold   int
+		wantErr     bool
+	}{
+		{
+			name:        "not breached",
+			breachCount: 0,
+			threshold:   1,
+			wantErr:     false,
+		},
+		{
+			name:        "breached",
+			breachCount: 1,
+			threshold:   1,
+			wantErr:     true,
+		},
+		{
+			name:        "below threshold",
+			breachCount: 9,
+			threshold:   10,
+			wantErr:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			testee := breachedPolicy{
+				Threshold: tt.threshold,
+			}
+
+			// act
+			err := testee.check(tt.breachCount)
+
+			// assert
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBreachedPolicy_LookupFails(t *testing.T) {
+	t.Parallel()
+	logging.Init()
+
+	tests := []struct {
+		name       string
+		failClosed bool
+		wantErr    bool
+	}{
+		{
+			name:       "fail open",
+			failClosed: false,
+			wantErr:    false,
+		},
+		{
+			name:       "fail closed",
+			failClosed: true,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			dc := ioc.NewDependencyCollection()
+			ioc.RegisterTransient(dc, func(_ *ioc.DependencyProvider) services.BreachedPasswordChecker {
+				return &failingBreachedPasswordChecker{}
+			})
+			scope := dc.BuildProvider()
+			ctx := middlewares.ContextWithScope(t.Context(), scope)
+
+			testee := breachedPolicy{
+				Threshold:  1,
+				FailClosed: tt.failClosed,
+			}
+
+			// act
+			err := testee.ValidateForUser(ctx, "password", nil)
+
+			// assert
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeserializePolicy_BreachedThreshold(t *testing.T) {
+	t.Parallel()
+
+	_, err := DeserializePolicy(repositories.PasswordRuleTypeBreached, []byte(`{"threshold":0}`))
+	require.ErrorIs(t, err, utils.ErrHttpBadRequest)
+
+	_, err = DeserializePolicy(repositories.PasswordRuleTypeBreached, []byte(`{"threshold":1}`))
+	require.NoError(t, err)
+}
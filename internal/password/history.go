@@ -0,0 +1,75 @@
+package password
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+)
+
+// historyPolicy rejects the current password and the ones before it, Count
+// includes the current password.
+type historyPolicy struct {
+	Count int `json:"count"`
+}
+
+func (p *historyPolicy) GetPasswordRuleType() repositories.PasswordRuleType {
+	return repositories.PasswordRuleTypeHistory
+}
+
+func (p *historyPolicy) Serialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize history rule: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+func (p *historyPolicy) Validate(string) error {
+	return nil
+}
+
+func (p *historyPolicy) ValidateForUser(ctx context.Context, password string, user *repositories.User) error {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypePassword)
+	credential, err := dbContext.Credentials().FirstOrNil(ctx, credentialFilter)
+	if err != nil {
+		return fmt.Errorf("failed to get password credential: %w", err)
+	}
+	if credential == nil {
+		return nil
+	}
+
+	details, err := credential.PasswordDetails()
+	if err != nil {
+		return fmt.Errorf("failed to get password details: %w", err)
+	}
+
+	if p.reuses(password, details) {
+		return fmt.Errorf("password must not be one of the last %d passwords", p.Count)
+	}
+	return nil
+}
+
+func (p *historyPolicy) reuses(password string, details *repositories.CredentialPasswordDetails) bool {
+	hashes := append([]string{details.HashedPassword}, details.History...)
+	if len(hashes) > p.Count {
+		hashes = hashes[:p.Count]
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && utils.CompareHash(password, hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryPolicy_Reuses(t *testing.T) {
+	t.Parallel()
+
+	details := &repositories.CredentialPasswordDetails{
+		HashedPassword: utils.HashPassword("current"),
+		History: []string{
+			utils.HashPassword("previous"),
+			utils.HashPassword("oldest"),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		count  int
+		reuses bool
+	}{
+		{
+			name:   "current password",
+			input:  "current",
+			count:  1,
+			reuses: true,
+		},
+		{
+			name:   "previous password",
+			input:  "previous",
+			count:  2,
+			reuses: true,
+		},
+		{
+			name:   "password older than count",
+			input:  "oldest",
+			count:  2,
+			reuses: false,
+		},
+		{
+			name:   "new password",
+			input:  "new",
+			count:  3,
+			reuses: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			testee := historyPolicy{
+				Count: tt.count,
+			}
+
+			// act
+			reuses := testee.reuses(tt.input, details)
+
+			// assert
+			assert.Equal(t, tt.reuses, reuses)
+		})
+	}
+}
+
+func TestDeserializePolicy_HistoryCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name:    "zero",
+			input:   `{"count":0}`,
+			wantErr: true,
+		},
+		{
+			name:    "within bounds",
+			input:   `{"count":5}`,
+			wantErr: false,
+		},
+		{
+			name:    "more than is kept",
+			input:   `{"count":25}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// act
+			_, err := DeserializePolicy(repositories.PasswordRuleTypeHistory, []byte(tt.input))
+
+			// assert
+			if tt.wantErr {
+				require.ErrorIs(t, err, utils.ErrHttpBadRequest)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package password
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+)
+
+// maxAgePolicy forces users to change their password after MaxAgeDays, it
+// does not restrict new passwords.
+type maxAgePolicy struct {
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+func (p *maxAgePolicy) GetPasswordRuleType() repositories.PasswordRuleType {
+	return repositories.PasswordRuleTypeMaxAge
+}
+
+func (p *maxAgePolicy) Serialize() ([]byte, error) {
+	jsonBytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize max age rule: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+func (p *maxAgePolicy) Validate(string) error {
+	return nil
+}
+
+func (p *maxAgePolicy) IsExpired(changedAt time.Time, now time.Time) bool {
+	return !now.Before(changedAt.Add(time.Duration(p.MaxAgeDays) * 24 * time.Hour))
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxAgePolicy_IsExpired(t *testing.T) {
+	t.Parallel()
+
+	changedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		expired bool
+	}{
+		{
+			name:    "just changed",
+			now:     changedAt,
+			expired: false,
+		},
+		{
+			name:    "a day before max age",
+			now:     changedAt.AddDate(0, 0, 29),
+			expired: false,
+		},
+		{
+			name:    "max age reached",
+			now:     changedAt.AddDate(0, 0, 30),
+			expired: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			testee := maxAgePolicy{
+				MaxAgeDays: 30,
+			}
+
+			// act
+			expired := testee.IsExpired(changedAt, tt.now)
+
+			// assert
+			assert.Equal(t, tt.expired, expired)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package password
 import (
 	"testing"
 
+	"github.com/The127/Keyline/internal/repositories"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,3 +57,14 @@ func TestMaxLengthPolicy_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestDeserializePolicy_MaxLength(t *testing.T) {
+	t.Parallel()
+
+	// act
+	policy, err := DeserializePolicy(repositories.PasswordRuleTypeMaxLength, []byte(`{"maxLength":64}`))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, &maxLengthPolicy{MaxLength: 64}, policy)
+}
@@ -3,6 +3,8 @@ package password
 import (
 	"testing"
 
+	"github.com/The127/Keyline/internal/repositories"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -73,3 +75,14 @@ func TestLengthPolicy_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestDeserializePolicy_MinLength(t *testing.T) {
+	t.Parallel()
+
+	// act
+	policy, err := DeserializePolicy(repositories.PasswordRuleTypeMinLength, []byte(`{"minLength":8}`))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, &minLengthPolicy{MinLength: 8}, policy)
+}
@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	repositories "github.com/The127/Keyline/internal/repositories"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,16 +41,31 @@ func (m *MockValidator) EXPECT() *MockValidatorMockRecorder {
 	return m.recorder
 }
 
+// IsExpired mocks base method.
+func (m *MockValidator) IsExpired(ctx context.Context, user *repositories.User, credential *repositories.Credential) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsExpired", ctx, user, credential)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsExpired indicates an expected call of IsExpired.
+func (mr *MockValidatorMockRecorder) IsExpired(ctx, user, credential any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsExpired", reflect.TypeOf((*MockValidator)(nil).IsExpired), ctx, user, credential)
+}
+
 // Validate mocks base method.
-func (m *MockValidator) Validate(ctx context.Context, password string) error {
+func (m *MockValidator) Validate(ctx context.Context, password string, user *repositories.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Validate", ctx, password)
+	ret := m.ctrl.Call(m, "Validate", ctx, password, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Validate indicates an expected call of Validate.
-func (mr *MockValidatorMockRecorder) Validate(ctx, password any) *gomock.Call {
+func (mr *MockValidatorMockRecorder) Validate(ctx, password, user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockValidator)(nil).Validate), ctx, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockValidator)(nil).Validate), ctx, password, user)
 }
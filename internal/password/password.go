@@ -8,13 +8,20 @@ import (
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 )
 
 //go:generate mockgen -destination=./mock/mock_validator.go -package=mock . Validator
 type Validator interface {
-	Validate(ctx context.Context, password string) error
+	// Validate checks a new password of the user against the password
+	// rules of their virtual server.
+	Validate(ctx context.Context, password string, user *repositories.User) error
+	// IsExpired tells whether the max age rule of the virtual server of the
+	// user forces them to change the password of the credential.
+	IsExpired(ctx context.Context, user *repositories.User, credential *repositories.Credential) (bool, error)
 }
 
 type validator struct{}
@@ -23,22 +30,11 @@ func NewValidator() Validator {
 	return &validator{}
 }
 
-func (v *validator) Validate(ctx context.Context, password string) error {
+func (v *validator) Validate(ctx context.Context, password string, user *repositories.User) error {
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
-	vsName, err := middlewares.GetVirtualServerName(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get virtual server name: %w", err)
-	}
-
-	virtualServerFilter := repositories.NewVirtualServerFilter().Name(vsName)
-	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
-	if err != nil {
-		return fmt.Errorf("failed to get virtual server: %w", err)
-	}
-
-	passwordRuleFilter := repositories.NewPasswordRuleFilter().VirtualServerId(virtualServer.Id())
+	passwordRuleFilter := repositories.NewPasswordRuleFilter().VirtualServerId(user.VirtualServerId())
 	passwordRules, err := dbContext.PasswordRules().List(ctx, passwordRuleFilter)
 	if err != nil {
 		return fmt.Errorf("failed to get password rules: %w", err)
@@ -63,17 +59,66 @@ func (v *validator) Validate(ctx context.Context, password string) error {
 		if err != nil {
 			aggregateErr = append(aggregateErr, err)
 		}
+
+		if userPolicy, ok := rule.(UserPolicy); ok {
+			err := userPolicy.ValidateForUser(ctx, password, user)
+			if err != nil {
+				aggregateErr = append(aggregateErr, err)
+			}
+		}
 	}
 
 	return errors.Join(aggregateErr...)
 }
 
+func (v *validator) IsExpired(ctx context.Context, user *repositories.User, credential *repositories.Credential) (bool, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	passwordRuleFilter := repositories.NewPasswordRuleFilter().
+		VirtualServerId(user.VirtualServerId()).
+		Type(repositories.PasswordRuleTypeMaxAge)
+	passwordRule, err := dbContext.PasswordRules().FirstOrNil(ctx, passwordRuleFilter)
+	if err != nil {
+		return false, fmt.Errorf("failed to get max age rule: %w", err)
+	}
+	if passwordRule == nil {
+		return false, nil
+	}
+
+	var rule maxAgePolicy
+	err = json.Unmarshal(passwordRule.Details(), &rule)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal max age rule: %w", err)
+	}
+
+	details, err := credential.PasswordDetails()
+	if err != nil {
+		return false, fmt.Errorf("failed to get password details: %w", err)
+	}
+
+	changedAt := credential.AuditCreatedAt()
+	if details.ChangedAt != nil {
+		changedAt = *details.ChangedAt
+	}
+
+	return rule.IsExpired(changedAt, clockService.Now()), nil
+}
+
 //go:generate mockgen -destination=./mock/mock_policy.go -package=mock . Policy
 type Policy interface {
 	repositories.PasswordRuleDetails
 	Validate(password string) error
 }
 
+// UserPolicy is implemented by policies that also check the password
+// against the user it is for, like their previous passwords, or that need
+// services of the scope.
+type UserPolicy interface {
+	ValidateForUser(ctx context.Context, password string, user *repositories.User) error
+}
+
 func DeserializePolicy(ruleType repositories.PasswordRuleType, jsonBytes []byte) (Policy, error) {
 	switch ruleType {
 	case repositories.PasswordRuleTypeMinLength:
@@ -82,6 +127,7 @@ func DeserializePolicy(ruleType repositories.PasswordRuleType, jsonBytes []byte)
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal min length rule: %w", err)
 		}
+		return &minLengthRule, nil
 
 	case repositories.PasswordRuleTypeMaxLength:
 		var maxLengthRule maxLengthPolicy
@@ -89,6 +135,7 @@ func DeserializePolicy(ruleType repositories.PasswordRuleType, jsonBytes []byte)
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal max length rule: %w", err)
 		}
+		return &maxLengthRule, nil
 
 	case repositories.PasswordRuleTypeDigits:
 		var numberRule minimumNumbersPolicy
@@ -122,9 +169,40 @@ func DeserializePolicy(ruleType repositories.PasswordRuleType, jsonBytes []byte)
 		}
 		return &specialRule, nil
 
+	case repositories.PasswordRuleTypeHistory:
+		var historyRule historyPolicy
+		err := json.Unmarshal(jsonBytes, &historyRule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history rule: %w", err)
+		}
+		if historyRule.Count < 1 || historyRule.Count > repositories.MaxPasswordHistory {
+			return nil, fmt.Errorf("history rule count must be between 1 and %d: %w", repositories.MaxPasswordHistory, utils.ErrHttpBadRequest)
+		}
+		return &historyRule, nil
+
+	case repositories.PasswordRuleTypeMaxAge:
+		var maxAgeRule maxAgePolicy
+		err := json.Unmarshal(jsonBytes, &maxAgeRule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal max age rule: %w", err)
+		}
+		if maxAgeRule.MaxAgeDays < 1 {
+			return nil, fmt.Errorf("max age rule days must be at least 1: %w", utils.ErrHttpBadRequest)
+		}
+		return &maxAgeRule, nil
+
+	case repositories.PasswordRuleTypeBreached:
+		var breachedRule breachedPolicy
+		err := json.Unmarshal(jsonBytes, &breachedRule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal breached rule: %w", err)
+		}
+		if breachedRule.Threshold < 1 {
+			return nil, fmt.Errorf("breached rule threshold must be at least 1: %w", utils.ErrHttpBadRequest)
+		}
+		return &breachedRule, nil
+
 	default:
 		return nil, fmt.Errorf("unknown password rule type: %s", ruleType)
 	}
-
-	panic("unreachable")
 }
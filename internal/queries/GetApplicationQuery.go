@@ -50,6 +50,11 @@ type GetApplicationResult struct {
 	AccessTokenHeaderType string
 	DeviceFlowEnabled     bool
 	SigningAlgorithm      *config.SigningAlgorithm
+	SamlEntityId          *string
+	SamlAcsUrl            *string
+	SamlSloUrl            *string
+	SamlCertificate       *string
+	AcrLevels             repositories.AcrLevels
 	CreatedAt             time.Time
 	UpdatedAt             time.Time
 }
@@ -98,6 +103,11 @@ func HandleGetApplication(ctx context.Context, query GetApplication) (*GetApplic
 		AccessTokenHeaderType: application.AccessTokenHeaderType(),
 		DeviceFlowEnabled:     application.DeviceFlowEnabled(),
 		SigningAlgorithm:      application.SigningAlgorithm(),
+		SamlEntityId:          application.SamlEntityId(),
+		SamlAcsUrl:            application.SamlAcsUrl(),
+		SamlSloUrl:            application.SamlSloUrl(),
+		SamlCertificate:       application.SamlCertificate(),
+		AcrLevels:             application.AcrLevels(),
 		CreatedAt:             application.AuditCreatedAt(),
 		UpdatedAt:             application.AuditUpdatedAt(),
 	}, nil
@@ -0,0 +1,90 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetIdentityProvider struct {
+	VirtualServerName  string
+	IdentityProviderId uuid.UUID
+}
+
+func (a GetIdentityProvider) LogRequest() bool {
+	return true
+}
+
+func (a GetIdentityProvider) LogResponse() bool {
+	return false
+}
+
+func (a GetIdentityProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.IdentityProviderView)
+}
+
+func (a GetIdentityProvider) GetRequestName() string {
+	return "GetIdentityProvider"
+}
+
+type GetIdentityProviderResponse struct {
+	Id               uuid.UUID
+	Name             string
+	DisplayName      string
+	Issuer           string
+	ClientId         string
+	Scopes           []string
+	AuthorizationUrl *string
+	TokenUrl         *string
+	UserinfoUrl      *string
+	ClaimMapping     repositories.IdentityProviderClaimMapping
+	LinkByEmail      bool
+	TrustUpstreamMfa bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func HandleGetIdentityProvider(ctx context.Context, query GetIdentityProvider) (*GetIdentityProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.IdentityProviderId)
+	identityProvider, err := dbContext.IdentityProviders().FirstOrErr(ctx, identityProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity provider: %w", err)
+	}
+
+	return &GetIdentityProviderResponse{
+		Id:               identityProvider.Id(),
+		Name:             identityProvider.Name(),
+		DisplayName:      identityProvider.DisplayName(),
+		Issuer:           identityProvider.Issuer(),
+		ClientId:         identityProvider.ClientId(),
+		Scopes:           identityProvider.Scopes(),
+		AuthorizationUrl: identityProvider.AuthorizationUrl(),
+		TokenUrl:         identityProvider.TokenUrl(),
+		UserinfoUrl:      identityProvider.UserinfoUrl(),
+		ClaimMapping:     identityProvider.ClaimMapping(),
+		LinkByEmail:      identityProvider.LinkByEmail(),
+		TrustUpstreamMfa: identityProvider.TrustUpstreamMfa(),
+		CreatedAt:        identityProvider.AuditCreatedAt(),
+		UpdatedAt:        identityProvider.AuditUpdatedAt(),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetLdapProvider struct {
+	VirtualServerName string
+	LdapProviderId    uuid.UUID
+}
+
+func (a GetLdapProvider) LogRequest() bool {
+	return true
+}
+
+func (a GetLdapProvider) LogResponse() bool {
+	return false
+}
+
+func (a GetLdapProvider) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderView)
+}
+
+func (a GetLdapProvider) GetRequestName() string {
+	return "GetLdapProvider"
+}
+
+type GetLdapProviderResponse struct {
+	Id               uuid.UUID
+	Name             string
+	Vendor           repositories.LdapVendor
+	ConnectionUrl    string
+	StartTls         bool
+	BindDn           string
+	UsersDn          string
+	UserFilter       string
+	GroupsDn         *string
+	GroupFilter      string
+	AttributeMapping repositories.LdapAttributeMapping
+	EditMode         repositories.LdapEditMode
+	SyncInterval     time.Duration
+	LastSyncAt       *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func HandleGetLdapProvider(ctx context.Context, query GetLdapProvider) (*GetLdapProviderResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.LdapProviderId)
+	ldapProvider, err := dbContext.LdapProviders().FirstOrErr(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap provider: %w", err)
+	}
+
+	return &GetLdapProviderResponse{
+		Id:               ldapProvider.Id(),
+		Name:             ldapProvider.Name(),
+		Vendor:           ldapProvider.Vendor(),
+		ConnectionUrl:    ldapProvider.ConnectionUrl(),
+		StartTls:         ldapProvider.StartTls(),
+		BindDn:           ldapProvider.BindDn(),
+		UsersDn:          ldapProvider.UsersDn(),
+		UserFilter:       ldapProvider.UserFilter(),
+		GroupsDn:         ldapProvider.GroupsDn(),
+		GroupFilter:      ldapProvider.GroupFilter(),
+		AttributeMapping: ldapProvider.AttributeMapping(),
+		EditMode:         ldapProvider.EditMode(),
+		SyncInterval:     ldapProvider.SyncInterval(),
+		LastSyncAt:       ldapProvider.LastSyncAt(),
+		CreatedAt:        ldapProvider.AuditCreatedAt(),
+		UpdatedAt:        ldapProvider.AuditUpdatedAt(),
+	}, nil
+}
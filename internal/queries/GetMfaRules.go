@@ -0,0 +1,52 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+)
+
+type GetMfaRules struct {
+	VirtualServerName string
+}
+
+func (a GetMfaRules) LogRequest() bool {
+	return false
+}
+
+func (a GetMfaRules) LogResponse() bool {
+	return false
+}
+
+func (a GetMfaRules) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerView)
+}
+
+func (a GetMfaRules) GetRequestName() string {
+	return "GetMfaRules"
+}
+
+type GetMfaRulesResponse struct {
+	Rules repositories.MfaRules
+}
+
+func HandleGetMfaRules(ctx context.Context, query GetMfaRules) (*GetMfaRulesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	return &GetMfaRulesResponse{
+		Rules: virtualServer.MfaRules(),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetProvisioningTarget struct {
+	VirtualServerName string
+	ProjectSlug       string
+	ApplicationId     uuid.UUID
+}
+
+func (a GetProvisioningTarget) LogRequest() bool {
+	return true
+}
+
+func (a GetProvisioningTarget) LogResponse() bool {
+	return false
+}
+
+func (a GetProvisioningTarget) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ApplicationView)
+}
+
+func (a GetProvisioningTarget) GetRequestName() string {
+	return "GetProvisioningTarget"
+}
+
+type GetProvisioningTargetResponse struct {
+	Id               uuid.UUID
+	Url              string
+	AuthType         repositories.ProvisioningAuthType
+	AuthUsername     *string
+	AttributeMapping repositories.ProvisioningAttributeMapping
+	Enabled          bool
+	LastSyncAt       *time.Time
+	LastError        *string
+	LastErrorAt      *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func HandleGetProvisioningTarget(ctx context.Context, query GetProvisioningTarget) (*GetProvisioningTargetResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	projectFilter := repositories.NewProjectFilter().VirtualServerId(virtualServer.Id()).Slug(query.ProjectSlug)
+	project, err := dbContext.Projects().FirstOrErr(ctx, projectFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	applicationFilter := repositories.NewApplicationFilter().
+		VirtualServerId(virtualServer.Id()).
+		ProjectId(project.Id()).
+		Id(query.ApplicationId)
+	application, err := dbContext.Applications().FirstOrErr(ctx, applicationFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting application: %w", err)
+	}
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(virtualServer.Id()).
+		ApplicationId(application.Id())
+	target, err := dbContext.ProvisioningTargets().FirstOrErr(ctx, targetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting provisioning target: %w", err)
+	}
+
+	return &GetProvisioningTargetResponse{
+		Id:               target.Id(),
+		Url:              target.Url(),
+		AuthType:         target.AuthType(),
+		AuthUsername:     target.AuthUsername(),
+		AttributeMapping: target.AttributeMapping(),
+		Enabled:          target.Enabled(),
+		LastSyncAt:       target.LastSyncAt(),
+		LastError:        target.LastError(),
+		LastErrorAt:      target.LastErrorAt(),
+		CreatedAt:        target.AuditCreatedAt(),
+		UpdatedAt:        target.AuditUpdatedAt(),
+	}, nil
+}
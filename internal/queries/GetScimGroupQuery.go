@@ -0,0 +1,107 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetScimGroup struct {
+	VirtualServerName string
+	GroupId           uuid.UUID
+	ExcludeMembers    bool
+}
+
+func (a GetScimGroup) LogRequest() bool {
+	return true
+}
+
+func (a GetScimGroup) LogResponse() bool {
+	return false
+}
+
+func (a GetScimGroup) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a GetScimGroup) GetRequestName() string {
+	return "GetScimGroup"
+}
+
+type ScimGroupResult struct {
+	Id          uuid.UUID
+	DisplayName string
+	Members     []ScimMemberReference
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type ScimMemberReference struct {
+	Id       uuid.UUID
+	Username string
+}
+
+func HandleGetScimGroup(ctx context.Context, query GetScimGroup) (*ScimGroupResult, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.GroupId)
+	group, err := dbContext.Groups().FirstOrErr(ctx, groupFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting group: %w", err)
+	}
+
+	return mapScimGroup(ctx, group, query.ExcludeMembers)
+}
+
+func mapScimGroup(ctx context.Context, group *repositories.Group, excludeMembers bool) (*ScimGroupResult, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	result := &ScimGroupResult{
+		Id:          group.Id(),
+		DisplayName: group.Name(),
+		CreatedAt:   group.AuditCreatedAt(),
+		UpdatedAt:   group.AuditUpdatedAt(),
+	}
+	if excludeMembers {
+		return result, nil
+	}
+
+	members, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().GroupId(group.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing group members: %w", err)
+	}
+
+	result.Members = make([]ScimMemberReference, 0, len(members))
+	for _, member := range members {
+		user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Id(member.UserId()))
+		if err != nil {
+			return nil, fmt.Errorf("getting member: %w", err)
+		}
+		result.Members = append(result.Members, ScimMemberReference{
+			Id:       user.Id(),
+			Username: user.Username(),
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetScimUser struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a GetScimUser) LogRequest() bool {
+	return true
+}
+
+func (a GetScimUser) LogResponse() bool {
+	return false
+}
+
+func (a GetScimUser) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a GetScimUser) GetRequestName() string {
+	return "GetScimUser"
+}
+
+type ScimUserResult struct {
+	Id          uuid.UUID
+	Username    string
+	DisplayName string
+	Email       string
+	Active      bool
+	Groups      []ScimGroupReference
+	// Roles are the direct role assignments outside the system project as
+	// "projectSlug:roleName".
+	Roles     []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ScimGroupReference struct {
+	Id   uuid.UUID
+	Name string
+}
+
+func HandleGetScimUser(ctx context.Context, query GetScimUser) (*ScimUserResult, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		ServiceUser(false).
+		Id(query.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	return mapScimUser(ctx, user)
+}
+
+func mapScimUser(ctx context.Context, user *repositories.User) (*ScimUserResult, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	memberships, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().UserId(user.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("listing group memberships: %w", err)
+	}
+
+	groups := make([]ScimGroupReference, 0, len(memberships))
+	for _, membership := range memberships {
+		group, err := dbContext.Groups().FirstOrErr(ctx, repositories.NewGroupFilter().Id(membership.GroupId()))
+		if err != nil {
+			return nil, fmt.Errorf("getting group: %w", err)
+		}
+		groups = append(groups, ScimGroupReference{
+			Id:   group.Id(),
+			Name: group.Name(),
+		})
+	}
+
+	assignmentFilter := repositories.NewUserRoleAssignmentFilter().
+		UserId(user.Id()).
+		IncludeRole()
+	assignments, _, err := dbContext.UserRoleAssignments().List(ctx, assignmentFilter)
+	if err != nil {
+		return nil, fmt.Errorf("listing role assignments: %w", err)
+	}
+
+	var roles []string
+	for _, assignment := range assignments {
+		roleInfo := assignment.RoleInfo()
+		if assignment.GroupId() != nil || roleInfo == nil || roleInfo.ProjectSlug == repositories.SystemProjectSlug {
+			continue
+		}
+		roles = append(roles, fmt.Sprintf("%s:%s", roleInfo.ProjectSlug, roleInfo.Name))
+	}
+
+	return &ScimUserResult{
+		Id:          user.Id(),
+		Username:    user.Username(),
+		DisplayName: user.DisplayName(),
+		Email:       user.PrimaryEmail(),
+		Active:      !user.Disabled(),
+		Groups:      groups,
+		Roles:       roles,
+		CreatedAt:   user.AuditCreatedAt(),
+		UpdatedAt:   user.AuditUpdatedAt(),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type GetUserLockout struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a GetUserLockout) LogRequest() bool {
+	return true
+}
+
+func (a GetUserLockout) LogResponse() bool {
+	return false
+}
+
+func (a GetUserLockout) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.UserView)
+}
+
+func (a GetUserLockout) GetRequestName() string {
+	return "GetUserLockout"
+}
+
+type GetUserLockoutResponse struct {
+	Locked         bool
+	Permanent      bool
+	LockedUntil    *time.Time
+	FailedAttempts int
+	Lockouts       int
+}
+
+func HandleGetUserLockout(ctx context.Context, query GetUserLockout) (*GetUserLockoutResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	lockoutService := ioc.GetDependency[services.LockoutService](scope)
+	state, err := lockoutService.GetUserState(ctx, user.Id())
+	if err != nil {
+		return nil, fmt.Errorf("getting lockout state: %w", err)
+	}
+	if state == nil {
+		return &GetUserLockoutResponse{}, nil
+	}
+
+	clockService := ioc.GetDependency[clock.Service](scope)
+	return &GetUserLockoutResponse{
+		Locked:         state.Locked(clockService.Now()),
+		Permanent:      state.Permanent,
+		LockedUntil:    state.LockedUntil,
+		FailedAttempts: state.FailedAttempts,
+		Lockouts:       state.Lockouts,
+	}, nil
+}
@@ -42,8 +42,13 @@ type GetVirtualServerResponse struct {
 	RegistrationEnabled         bool
 	Require2fa                  bool
 	RequireEmailVerification    bool
+	EnableEmailLogin            bool
+	TrustedDeviceDays           int
 	PrimarySigningAlgorithm     config.SigningAlgorithm
 	AdditionalSigningAlgorithms []config.SigningAlgorithm
+	KeyRotationPolicy           repositories.KeyRotationPolicy
+	PasskeyPolicy               repositories.PasskeyPolicy
+	LockoutPolicy               repositories.LockoutPolicy
 	CreatedAt                   time.Time
 	UpdatedAt                   time.Time
 }
@@ -65,8 +70,13 @@ func HandleGetVirtualServerQuery(ctx context.Context, command GetVirtualServerQu
 		RegistrationEnabled:         virtualServer.EnableRegistration(),
 		Require2fa:                  virtualServer.Require2fa(),
 		RequireEmailVerification:    virtualServer.RequireEmailVerification(),
+		EnableEmailLogin:            virtualServer.EnableEmailLogin(),
+		TrustedDeviceDays:           virtualServer.TrustedDeviceDays(),
 		PrimarySigningAlgorithm:     virtualServer.PrimarySigningAlgorithm(),
 		AdditionalSigningAlgorithms: virtualServer.AdditionalSigningAlgorithms(),
+		KeyRotationPolicy:           virtualServer.KeyRotationPolicy(),
+		PasskeyPolicy:               virtualServer.PasskeyPolicy(),
+		LockoutPolicy:               virtualServer.LockoutPolicy(),
 		CreatedAt:                   virtualServer.AuditCreatedAt(),
 		UpdatedAt:                   virtualServer.AuditUpdatedAt(),
 	}, nil
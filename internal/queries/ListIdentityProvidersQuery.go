@@ -0,0 +1,78 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ListIdentityProviders struct {
+	VirtualServerName string
+}
+
+func (a ListIdentityProviders) LogRequest() bool {
+	return false
+}
+
+func (a ListIdentityProviders) LogResponse() bool {
+	return false
+}
+
+func (a ListIdentityProviders) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.IdentityProviderView)
+}
+
+func (a ListIdentityProviders) GetRequestName() string {
+	return "ListIdentityProviders"
+}
+
+type ListIdentityProvidersResponse struct {
+	Items []ListIdentityProvidersResponseItem
+}
+
+type ListIdentityProvidersResponseItem struct {
+	Id          uuid.UUID
+	Name        string
+	DisplayName string
+	Issuer      string
+}
+
+func HandleListIdentityProviders(ctx context.Context, query ListIdentityProviders) (*ListIdentityProvidersResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	identityProviderFilter := repositories.NewIdentityProviderFilter().
+		VirtualServerId(virtualServer.Id())
+	identityProviders, err := dbContext.IdentityProviders().List(ctx, identityProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity providers: %w", err)
+	}
+
+	items := utils.MapSlice(identityProviders, func(x *repositories.IdentityProvider) ListIdentityProvidersResponseItem {
+		return ListIdentityProvidersResponseItem{
+			Id:          x.Id(),
+			Name:        x.Name(),
+			DisplayName: x.DisplayName(),
+			Issuer:      x.Issuer(),
+		}
+	})
+
+	return &ListIdentityProvidersResponse{
+		Items: items,
+	}, nil
+}
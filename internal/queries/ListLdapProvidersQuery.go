@@ -0,0 +1,83 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ListLdapProviders struct {
+	VirtualServerName string
+}
+
+func (a ListLdapProviders) LogRequest() bool {
+	return false
+}
+
+func (a ListLdapProviders) LogResponse() bool {
+	return false
+}
+
+func (a ListLdapProviders) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.LdapProviderView)
+}
+
+func (a ListLdapProviders) GetRequestName() string {
+	return "ListLdapProviders"
+}
+
+type ListLdapProvidersResponse struct {
+	Items []ListLdapProvidersResponseItem
+}
+
+type ListLdapProvidersResponseItem struct {
+	Id            uuid.UUID
+	Name          string
+	Vendor        repositories.LdapVendor
+	ConnectionUrl string
+	EditMode      repositories.LdapEditMode
+	LastSyncAt    *time.Time
+}
+
+func HandleListLdapProviders(ctx context.Context, query ListLdapProviders) (*ListLdapProvidersResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	ldapProviderFilter := repositories.NewLdapProviderFilter().
+		VirtualServerId(virtualServer.Id())
+	ldapProviders, err := dbContext.LdapProviders().List(ctx, ldapProviderFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting ldap providers: %w", err)
+	}
+
+	items := utils.MapSlice(ldapProviders, func(x *repositories.LdapProvider) ListLdapProvidersResponseItem {
+		return ListLdapProvidersResponseItem{
+			Id:            x.Id(),
+			Name:          x.Name(),
+			Vendor:        x.Vendor(),
+			ConnectionUrl: x.ConnectionUrl(),
+			EditMode:      x.EditMode(),
+			LastSyncAt:    x.LastSyncAt(),
+		}
+	})
+
+	return &ListLdapProvidersResponse{
+		Items: items,
+	}, nil
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
-	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/The127/ioc"
 
@@ -41,7 +41,14 @@ type ListPasskeysResponse struct {
 }
 
 type ListPasskeysResponseItem struct {
-	Id uuid.UUID
+	Id         uuid.UUID
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	SignCount  uint32
+
+	Aaguid            uuid.UUID
+	AttestationFormat string
 }
 
 func HandleListPasskeys(ctx context.Context, query ListPasskeys) (*ListPasskeysResponse, error) {
@@ -70,11 +77,24 @@ func HandleListPasskeys(ctx context.Context, query ListPasskeys) (*ListPasskeysR
 		return nil, fmt.Errorf("getting credentials: %w", err)
 	}
 
-	items := utils.MapSlice(credentials, func(x *repositories.Credential) ListPasskeysResponseItem {
-		return ListPasskeysResponseItem{
-			Id: x.Id(),
+	items := make([]ListPasskeysResponseItem, 0, len(credentials))
+	for _, credential := range credentials {
+		details, err := credential.WebauthnDetails()
+		if err != nil {
+			return nil, err
 		}
-	})
+
+		items = append(items, ListPasskeysResponseItem{
+			Id:         credential.Id(),
+			Name:       details.Name,
+			CreatedAt:  credential.AuditCreatedAt(),
+			LastUsedAt: details.LastUsedAt,
+			SignCount:  details.SignCount,
+
+			Aaguid:            details.Aaguid,
+			AttestationFormat: details.AttestationFormat,
+		})
+	}
 
 	return &ListPasskeysResponse{
 		PagedResponse: NewPagedResponse(items, len(credentials)),
@@ -0,0 +1,105 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// ListProvisioningTargets gives an overview of the delivery status of all
+// provisioning targets of a virtual server.
+type ListProvisioningTargets struct {
+	VirtualServerName string
+}
+
+func (a ListProvisioningTargets) LogRequest() bool {
+	return false
+}
+
+func (a ListProvisioningTargets) LogResponse() bool {
+	return false
+}
+
+func (a ListProvisioningTargets) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ApplicationView)
+}
+
+func (a ListProvisioningTargets) GetRequestName() string {
+	return "ListProvisioningTargets"
+}
+
+type ListProvisioningTargetsResponse struct {
+	Items []ListProvisioningTargetsResponseItem
+}
+
+type ListProvisioningTargetsResponseItem struct {
+	Id              uuid.UUID
+	ApplicationId   uuid.UUID
+	ApplicationName string
+	Url             string
+	Enabled         bool
+	LastSyncAt      *time.Time
+	LastError       *string
+	LastErrorAt     *time.Time
+}
+
+func HandleListProvisioningTargets(ctx context.Context, query ListProvisioningTargets) (*ListProvisioningTargetsResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(virtualServer.Id())
+	targets, err := dbContext.ProvisioningTargets().List(ctx, targetFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting provisioning targets: %w", err)
+	}
+
+	applicationNames := make(map[uuid.UUID]string, len(targets))
+	if len(targets) > 0 {
+		applicationFilter := repositories.NewApplicationFilter().
+			VirtualServerId(virtualServer.Id()).
+			Ids(utils.MapSlice(targets, func(x *repositories.ProvisioningTarget) uuid.UUID {
+				return x.ApplicationId()
+			}))
+		applications, _, err := dbContext.Applications().List(ctx, applicationFilter)
+		if err != nil {
+			return nil, fmt.Errorf("getting applications: %w", err)
+		}
+		for _, application := range applications {
+			applicationNames[application.Id()] = application.Name()
+		}
+	}
+
+	items := utils.MapSlice(targets, func(x *repositories.ProvisioningTarget) ListProvisioningTargetsResponseItem {
+		return ListProvisioningTargetsResponseItem{
+			Id:              x.Id(),
+			ApplicationId:   x.ApplicationId(),
+			ApplicationName: applicationNames[x.ApplicationId()],
+			Url:             x.Url(),
+			Enabled:         x.Enabled(),
+			LastSyncAt:      x.LastSyncAt(),
+			LastError:       x.LastError(),
+			LastErrorAt:     x.LastErrorAt(),
+		}
+	})
+
+	return &ListProvisioningTargetsResponse{
+		Items: items,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+
+	"github.com/The127/ioc"
+)
+
+type ListScimGroups struct {
+	PagedQuery
+	VirtualServerName string
+	Filter            scim.Filter
+	ExcludeMembers    bool
+}
+
+func (a ListScimGroups) LogRequest() bool {
+	return true
+}
+
+func (a ListScimGroups) LogResponse() bool {
+	return false
+}
+
+func (a ListScimGroups) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a ListScimGroups) GetRequestName() string {
+	return "ListScimGroups"
+}
+
+type ListScimGroupsResponse struct {
+	PagedResponse[ScimGroupResult]
+}
+
+func HandleListScimGroups(ctx context.Context, query ListScimGroups) (*ListScimGroupsResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	groupFilter := repositories.NewGroupFilter().
+		VirtualServerId(virtualServer.Id()).
+		Pagination(query.Page, query.PageSize).
+		Order("audit_created_at", "asc")
+	groupFilter, err = applyScimGroupFilter(groupFilter, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, total, err := dbContext.Groups().List(ctx, groupFilter)
+	if err != nil {
+		return nil, fmt.Errorf("searching groups: %w", err)
+	}
+
+	items := make([]ScimGroupResult, 0, len(groups))
+	for _, group := range groups {
+		item, err := mapScimGroup(ctx, group, query.ExcludeMembers)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return &ListScimGroupsResponse{
+		PagedResponse: NewPagedResponse(items, total),
+	}, nil
+}
+
+func applyScimGroupFilter(groupFilter *repositories.GroupFilter, filter scim.Filter) (*repositories.GroupFilter, error) {
+	for _, comparison := range filter {
+		switch strings.ToLower(comparison.Attribute) {
+		case "displayname":
+			value, err := scimEqualString(comparison)
+			if err != nil {
+				return nil, err
+			}
+			groupFilter = groupFilter.Name(value)
+
+		case "id":
+			id, err := scimEqualId(comparison)
+			if err != nil {
+				return nil, err
+			}
+			groupFilter = groupFilter.Id(id)
+
+		case "meta.lastmodified":
+			updatedAfter, err := scimUpdatedAfter(comparison)
+			if err != nil {
+				return nil, err
+			}
+			groupFilter = groupFilter.UpdatedAfter(updatedAfter)
+
+		default:
+			return nil, unsupportedScimFilter(comparison)
+		}
+	}
+
+	return groupFilter, nil
+}
@@ -0,0 +1,182 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ListScimUsers struct {
+	PagedQuery
+	VirtualServerName string
+	Filter            scim.Filter
+}
+
+func (a ListScimUsers) LogRequest() bool {
+	return true
+}
+
+func (a ListScimUsers) LogResponse() bool {
+	return false
+}
+
+func (a ListScimUsers) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.ScimProvision)
+}
+
+func (a ListScimUsers) GetRequestName() string {
+	return "ListScimUsers"
+}
+
+type ListScimUsersResponse struct {
+	PagedResponse[ScimUserResult]
+}
+
+func HandleListScimUsers(ctx context.Context, query ListScimUsers) (*ListScimUsersResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		ServiceUser(false).
+		Pagination(query.Page, query.PageSize).
+		Order("audit_created_at", "asc")
+	userFilter, err = applyScimUserFilter(userFilter, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := dbContext.Users().List(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("searching users: %w", err)
+	}
+
+	items := make([]ScimUserResult, 0, len(users))
+	for _, user := range users {
+		item, err := mapScimUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return &ListScimUsersResponse{
+		PagedResponse: NewPagedResponse(items, total),
+	}, nil
+}
+
+// applyScimUserFilter translates the comparisons the repository filters can
+// express. Anything else is rejected rather than silently ignored, as a
+// provisioning client would take a broader result for a match.
+func applyScimUserFilter(userFilter *repositories.UserFilter, filter scim.Filter) (*repositories.UserFilter, error) {
+	for _, comparison := range filter {
+		switch strings.ToLower(comparison.Attribute) {
+		case "username":
+			value, err := scimEqualString(comparison)
+			if err != nil {
+				return nil, err
+			}
+			userFilter = userFilter.Username(value)
+
+		case "emails", "emails.value":
+			value, err := scimEqualString(comparison)
+			if err != nil {
+				return nil, err
+			}
+			userFilter = userFilter.PrimaryEmail(value)
+
+		case "id":
+			id, err := scimEqualId(comparison)
+			if err != nil {
+				return nil, err
+			}
+			userFilter = userFilter.Id(id)
+
+		case "meta.lastmodified":
+			updatedAfter, err := scimUpdatedAfter(comparison)
+			if err != nil {
+				return nil, err
+			}
+			userFilter = userFilter.UpdatedAfter(updatedAfter)
+
+		default:
+			return nil, unsupportedScimFilter(comparison)
+		}
+	}
+
+	return userFilter, nil
+}
+
+func unsupportedScimFilter(comparison scim.Comparison) error {
+	return scim.NewError(
+		http.StatusBadRequest,
+		scim.ErrorTypeInvalidFilter,
+		fmt.Sprintf("filtering on %s %s is not supported", comparison.Attribute, comparison.Operator),
+	)
+}
+
+func scimEqualString(comparison scim.Comparison) (string, error) {
+	value, ok := comparison.Value.(string)
+	if comparison.Operator != scim.OperatorEqual || !ok {
+		return "", unsupportedScimFilter(comparison)
+	}
+	return value, nil
+}
+
+// scimEqualId parses the value of an id comparison. Ids that are not uuids
+// cannot exist and match nothing.
+func scimEqualId(comparison scim.Comparison) (uuid.UUID, error) {
+	value, err := scimEqualString(comparison)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, nil
+	}
+	return id, nil
+}
+
+// scimUpdatedAfter turns a gt or ge comparison on meta.lastModified into the
+// strict lower bound used by the repository filters. Timestamps are stored
+// with microsecond precision.
+func scimUpdatedAfter(comparison scim.Comparison) (time.Time, error) {
+	value, ok := comparison.Value.(string)
+	if !ok {
+		return time.Time{}, unsupportedScimFilter(comparison)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, scim.NewError(http.StatusBadRequest, scim.ErrorTypeInvalidFilter, fmt.Sprintf("invalid timestamp %q", value))
+	}
+
+	switch comparison.Operator {
+	case scim.OperatorGreaterThan:
+		return timestamp, nil
+	case scim.OperatorGreaterOrEqual:
+		return timestamp.Add(-time.Microsecond), nil
+	default:
+		return time.Time{}, unsupportedScimFilter(comparison)
+	}
+}
@@ -0,0 +1,98 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+type ListSigningKeysQuery struct {
+	VirtualServerName string
+}
+
+func (a ListSigningKeysQuery) LogRequest() bool {
+	return true
+}
+
+func (a ListSigningKeysQuery) LogResponse() bool {
+	return false
+}
+
+func (a ListSigningKeysQuery) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.PermissionBasedPolicy(ctx, permissions.VirtualServerView)
+}
+
+func (a ListSigningKeysQuery) GetRequestName() string {
+	return "ListSigningKeysQuery"
+}
+
+type ListSigningKeysResponse struct {
+	Items []ListSigningKeysResponseItem
+}
+
+type ListSigningKeysResponseItem struct {
+	Kid         string
+	Algorithm   config.SigningAlgorithm
+	State       services.KeyState
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RotatesAt   time.Time
+	ExpiresAt   time.Time
+	VerifyOnly  bool
+}
+
+func HandleListSigningKeysQuery(ctx context.Context, query ListSigningKeysQuery) (*ListSigningKeysResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	keyService := ioc.GetDependency[services.KeyService](scope)
+	keyPairs, err := keyService.GetAllKeys(virtualServer.Name())
+	if err != nil {
+		return nil, fmt.Errorf("getting keys: %w", err)
+	}
+
+	now := ioc.GetDependency[clock.Service](scope).Now()
+
+	items := make([]ListSigningKeysResponseItem, len(keyPairs))
+	for i, keyPair := range keyPairs {
+		items[i] = ListSigningKeysResponseItem{
+			Kid:         keyPair.GetKid(),
+			Algorithm:   keyPair.Algorithm(),
+			State:       services.KeyPairState(keyPairs, keyPair, now),
+			CreatedAt:   keyPair.CreatedAt(),
+			ActivatesAt: keyPair.ActivatesAt(),
+			RotatesAt:   keyPair.RotatesAt(),
+			ExpiresAt:   keyPair.ExpiresAt(),
+			VerifyOnly:  keyPair.VerifyOnly(),
+		}
+	}
+
+	slices.SortFunc(items, func(a, b ListSigningKeysResponseItem) int {
+		if a.Algorithm != b.Algorithm {
+			return strings.Compare(string(a.Algorithm), string(b.Algorithm))
+		}
+		return b.ActivatesAt.Compare(a.ActivatesAt)
+	})
+
+	return &ListSigningKeysResponse{
+		Items: items,
+	}, nil
+}
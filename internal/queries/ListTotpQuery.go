@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ListTotp struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a ListTotp) LogRequest() bool {
+	return false
+}
+
+func (a ListTotp) LogResponse() bool {
+	return false
+}
+
+func (a ListTotp) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.TotpView)
+}
+
+func (a ListTotp) GetRequestName() string {
+	return "ListTotp"
+}
+
+type ListTotpResponse struct {
+	PagedResponse[ListTotpResponseItem]
+}
+
+// ListTotpResponseItem describes an authenticator, the secret is never
+// returned.
+type ListTotpResponseItem struct {
+	Id        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+func HandleListTotp(ctx context.Context, query ListTotp) (*ListTotpResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeTotp)
+	credentials, err := dbContext.Credentials().List(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting credentials: %w", err)
+	}
+
+	items := make([]ListTotpResponseItem, 0, len(credentials))
+	for _, credential := range credentials {
+		details, err := credential.TotpDetails()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, ListTotpResponseItem{
+			Id:        credential.Id(),
+			Name:      details.Name,
+			CreatedAt: credential.AuditCreatedAt(),
+		})
+	}
+
+	return &ListTotpResponse{
+		PagedResponse: NewPagedResponse(items, len(credentials)),
+	}, nil
+}
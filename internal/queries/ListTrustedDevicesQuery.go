@@ -0,0 +1,98 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/authentication/permissions"
+	"github.com/The127/Keyline/internal/behaviours"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"time"
+
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+type ListTrustedDevices struct {
+	VirtualServerName string
+	UserId            uuid.UUID
+}
+
+func (a ListTrustedDevices) LogRequest() bool {
+	return false
+}
+
+func (a ListTrustedDevices) LogResponse() bool {
+	return false
+}
+
+func (a ListTrustedDevices) IsAllowed(ctx context.Context) (behaviours.PolicyResult, error) {
+	return behaviours.OwnershipOrPermissionBasedPolicy(ctx, a.UserId, permissions.TrustedDeviceView)
+}
+
+func (a ListTrustedDevices) GetRequestName() string {
+	return "ListTrustedDevices"
+}
+
+type ListTrustedDevicesResponse struct {
+	PagedResponse[ListTrustedDevicesResponseItem]
+}
+
+// ListTrustedDevicesResponseItem describes a browser that skips the second
+// factor, expired ones are listed until they are revoked.
+type ListTrustedDevicesResponseItem struct {
+	Id         uuid.UUID
+	Name       string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+}
+
+func HandleListTrustedDevices(ctx context.Context, query ListTrustedDevices) (*ListTrustedDevicesResponse, error) {
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServerFilter := repositories.NewVirtualServerFilter().Name(query.VirtualServerName)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, virtualServerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(virtualServer.Id()).
+		Id(query.UserId)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	credentialFilter := repositories.NewCredentialFilter().
+		UserId(user.Id()).
+		Type(repositories.CredentialTypeTrustedDevice)
+	credentials, err := dbContext.Credentials().List(ctx, credentialFilter)
+	if err != nil {
+		return nil, fmt.Errorf("getting credentials: %w", err)
+	}
+
+	items := make([]ListTrustedDevicesResponseItem, 0, len(credentials))
+	for _, credential := range credentials {
+		details, err := credential.TrustedDeviceDetails()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, ListTrustedDevicesResponseItem{
+			Id:         credential.Id(),
+			Name:       details.Name,
+			CreatedAt:  credential.AuditCreatedAt(),
+			ExpiresAt:  details.ExpiresAt,
+			LastUsedAt: details.LastUsedAt,
+		})
+	}
+
+	return &ListTrustedDevicesResponse{
+		PagedResponse: NewPagedResponse(items, len(credentials)),
+	}, nil
+}
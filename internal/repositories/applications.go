@@ -16,6 +16,7 @@ type ApplicationType string
 const (
 	ApplicationTypePublic       ApplicationType = "public"
 	ApplicationTypeConfidential ApplicationType = "confidential"
+	ApplicationTypeSaml         ApplicationType = "saml"
 )
 
 type ApplicationChange int
@@ -30,6 +31,11 @@ const (
 	ApplicationChangeSystemApplication
 	ApplicationChangeDeviceFlowEnabled
 	ApplicationChangeSigningAlgorithm
+	ApplicationChangeSamlEntityId
+	ApplicationChangeSamlAcsUrl
+	ApplicationChangeSamlSloUrl
+	ApplicationChangeSamlCertificate
+	ApplicationChangeAcrLevels
 )
 
 type Application struct {
@@ -55,6 +61,15 @@ type Application struct {
 	deviceFlowEnabled bool
 
 	signingAlgorithm *config.SigningAlgorithm
+
+	samlEntityId *string
+	samlAcsUrl   *string
+	samlSloUrl   *string
+	// samlCertificate is the pem encoded certificate the service provider
+	// signs its requests with.
+	samlCertificate *string
+
+	acrLevels AcrLevels
 }
 
 func NewApplication(virtualServerId uuid.UUID, projectId uuid.UUID, name string, displayName string, type_ ApplicationType, redirectUris []string) *Application {
@@ -69,6 +84,7 @@ func NewApplication(virtualServerId uuid.UUID, projectId uuid.UUID, name string,
 		redirectUris:           redirectUris,
 		postLogoutRedirectUris: []string{},
 		accessTokenHeaderType:  "at+jwt",
+		acrLevels:              AcrLevels{},
 	}
 }
 
@@ -87,6 +103,11 @@ func NewApplicationFromDB(
 	accessTokenHeaderType string,
 	deviceFlowEnabled bool,
 	signingAlgorithm *config.SigningAlgorithm,
+	samlEntityId *string,
+	samlAcsUrl *string,
+	samlSloUrl *string,
+	samlCertificate *string,
+	acrLevels AcrLevels,
 ) *Application {
 	return &Application{
 		BaseModel:              base,
@@ -104,6 +125,11 @@ func NewApplicationFromDB(
 		accessTokenHeaderType:  accessTokenHeaderType,
 		deviceFlowEnabled:      deviceFlowEnabled,
 		signingAlgorithm:       signingAlgorithm,
+		samlEntityId:           samlEntityId,
+		samlAcsUrl:             samlAcsUrl,
+		samlSloUrl:             samlSloUrl,
+		samlCertificate:        samlCertificate,
+		acrLevels:              acrLevels,
 	}
 }
 
@@ -244,6 +270,53 @@ func (a *Application) SetSigningAlgorithm(alg *config.SigningAlgorithm) {
 	a.TrackChange(ApplicationChangeSigningAlgorithm)
 }
 
+func (a *Application) SamlEntityId() *string {
+	return a.samlEntityId
+}
+
+func (a *Application) SetSamlEntityId(samlEntityId *string) {
+	a.samlEntityId = samlEntityId
+	a.TrackChange(ApplicationChangeSamlEntityId)
+}
+
+func (a *Application) SamlAcsUrl() *string {
+	return a.samlAcsUrl
+}
+
+func (a *Application) SetSamlAcsUrl(samlAcsUrl *string) {
+	a.samlAcsUrl = samlAcsUrl
+	a.TrackChange(ApplicationChangeSamlAcsUrl)
+}
+
+func (a *Application) SamlSloUrl() *string {
+	return a.samlSloUrl
+}
+
+func (a *Application) SetSamlSloUrl(samlSloUrl *string) {
+	a.samlSloUrl = samlSloUrl
+	a.TrackChange(ApplicationChangeSamlSloUrl)
+}
+
+func (a *Application) SamlCertificate() *string {
+	return a.samlCertificate
+}
+
+func (a *Application) SetSamlCertificate(samlCertificate *string) {
+	a.samlCertificate = samlCertificate
+	a.TrackChange(ApplicationChangeSamlCertificate)
+}
+
+// AcrLevels are the acr values the application can request with
+// acr_values, each names the authentication methods it requires.
+func (a *Application) AcrLevels() AcrLevels {
+	return a.acrLevels
+}
+
+func (a *Application) SetAcrLevels(acrLevels AcrLevels) {
+	a.acrLevels = acrLevels
+	a.TrackChange(ApplicationChangeAcrLevels)
+}
+
 type ApplicationFilter struct {
 	PagingInfo
 	OrderInfo
@@ -252,6 +325,7 @@ type ApplicationFilter struct {
 	ids             *[]uuid.UUID
 	virtualServerId *uuid.UUID
 	projectId       *uuid.UUID
+	samlEntityId    *string
 	searchFilter    *SearchFilter
 }
 
@@ -368,6 +442,20 @@ func (f *ApplicationFilter) GetVirtualServerId() uuid.UUID {
 	return utils.ZeroIfNil(f.virtualServerId)
 }
 
+func (f *ApplicationFilter) SamlEntityId(samlEntityId string) *ApplicationFilter {
+	filter := f.Clone()
+	filter.samlEntityId = &samlEntityId
+	return filter
+}
+
+func (f *ApplicationFilter) HasSamlEntityId() bool {
+	return f.samlEntityId != nil
+}
+
+func (f *ApplicationFilter) GetSamlEntityId() string {
+	return utils.ZeroIfNil(f.samlEntityId)
+}
+
 func (f *ApplicationFilter) Ids(ids []uuid.UUID) *ApplicationFilter {
 	fiter := f.Clone()
 	fiter.ids = &ids
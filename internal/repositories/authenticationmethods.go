@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/The127/Keyline/utils"
+	"slices"
+)
+
+// AuthenticationMethod is a method a user proved their identity with during
+// a login. The values are the ones registered for the "amr" claim in
+// RFC 8176, except for email which RFC 8176 has no value for.
+type AuthenticationMethod string
+
+const (
+	AuthenticationMethodPassword    AuthenticationMethod = "pwd"
+	AuthenticationMethodOtp         AuthenticationMethod = "otp"
+	AuthenticationMethodSms         AuthenticationMethod = "sms"
+	AuthenticationMethodHardwareKey AuthenticationMethod = "hwk"
+	AuthenticationMethodSoftwareKey AuthenticationMethod = "swk"
+
+	// AuthenticationMethodEmail is a link or code sent by mail. It only
+	// proves access to the mailbox and is kept apart from otp, so that it
+	// does not stand in for an authenticator.
+	AuthenticationMethodEmail AuthenticationMethod = "email"
+
+	// AuthenticationMethodMultiFactor is never completed on its own, it is
+	// reported and required for logins with at least two methods.
+	AuthenticationMethodMultiFactor AuthenticationMethod = "mfa"
+)
+
+func (m AuthenticationMethod) Validate() error {
+	switch m {
+	case AuthenticationMethodPassword,
+		AuthenticationMethodOtp,
+		AuthenticationMethodSms,
+		AuthenticationMethodHardwareKey,
+		AuthenticationMethodSoftwareKey,
+		AuthenticationMethodEmail,
+		AuthenticationMethodMultiFactor:
+		return nil
+
+	default:
+		return fmt.Errorf("unknown authentication method %q: %w", m, utils.ErrHttpBadRequest)
+	}
+}
+
+// AddAuthenticationMethod returns the methods with the method added if it
+// was not completed before.
+func AddAuthenticationMethod(methods []AuthenticationMethod, method AuthenticationMethod) []AuthenticationMethod {
+	if slices.Contains(methods, method) {
+		return methods
+	}
+	return append(methods, method)
+}
+
+// AmrClaim returns the "amr" claim for the completed methods.
+func AmrClaim(methods []AuthenticationMethod) []string {
+	amr := make([]string, 0, len(methods)+1)
+	for _, method := range methods {
+		amr = append(amr, string(method))
+	}
+	if len(methods) >= 2 {
+		amr = append(amr, string(AuthenticationMethodMultiFactor))
+	}
+	return amr
+}
+
+// MissingAuthenticationMethods returns the required methods that were not
+// completed yet.
+func MissingAuthenticationMethods(required []AuthenticationMethod, completed []AuthenticationMethod) []AuthenticationMethod {
+	var missing []AuthenticationMethod
+	for _, method := range required {
+		if method == AuthenticationMethodMultiFactor {
+			if len(completed) < 2 {
+				missing = append(missing, method)
+			}
+			continue
+		}
+
+		if !slices.Contains(completed, method) {
+			missing = append(missing, method)
+		}
+	}
+	return missing
+}
+
+// AcrLevel maps an "acr" value an application can request to the methods a
+// login needs to complete to reach it.
+type AcrLevel struct {
+	Acr     string                 `json:"acr"`
+	Methods []AuthenticationMethod `json:"methods"`
+}
+
+// AcrLevels are the acr values an application knows, stored as a json
+// column.
+type AcrLevels []AcrLevel
+
+func (l AcrLevels) Validate() error {
+	seen := make(map[string]bool, len(l))
+	for _, level := range l {
+		if level.Acr == "" {
+			return fmt.Errorf("acr must not be empty: %w", utils.ErrHttpBadRequest)
+		}
+		if seen[level.Acr] {
+			return fmt.Errorf("acr %q is defined twice: %w", level.Acr, utils.ErrHttpBadRequest)
+		}
+		seen[level.Acr] = true
+
+		for _, method := range level.Methods {
+			err := method.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Find returns the level of the acr value or nil if the application does
+// not know it.
+func (l AcrLevels) Find(acr string) *AcrLevel {
+	for i := range l {
+		if l[i].Acr == acr {
+			return &l[i]
+		}
+	}
+	return nil
+}
+
+func (l AcrLevels) Value() (driver.Value, error) {
+	if l == nil {
+		return json.Marshal(AcrLevels{})
+	}
+	return json.Marshal(l)
+}
+
+func (l *AcrLevels) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for acr levels failed")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
@@ -2,18 +2,22 @@ package repositories
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/The127/Keyline/internal/change"
 	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 var (
-	ErrWrongCredentialCast = errors.New("wrong credential cast")
+	ErrWrongCredentialCast         = errors.New("wrong credential cast")
+	ErrWebauthnSignCountRegression = errors.New("webauthn signature counter did not increase")
 )
 
 type CredentialChange int
@@ -109,15 +113,75 @@ func (c *Credential) ServiceUserKeyDetails() (*CredentialServiceUserKey, error)
 	return nil, fmt.Errorf("expected service user key credential, got %s: %w", c._type, ErrWrongCredentialCast)
 }
 
+func (c *Credential) IdentityProviderDetails() (*CredentialIdentityProviderDetails, error) {
+	details, ok := c.details.(*CredentialIdentityProviderDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected identity provider credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
+func (c *Credential) LdapDetails() (*CredentialLdapDetails, error) {
+	details, ok := c.details.(*CredentialLdapDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected ldap credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
+func (c *Credential) PhoneDetails() (*CredentialPhoneDetails, error) {
+	details, ok := c.details.(*CredentialPhoneDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected phone credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
+func (c *Credential) RecoveryCodeDetails() (*CredentialRecoveryCodeDetails, error) {
+	details, ok := c.details.(*CredentialRecoveryCodeDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected recovery code credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
+func (c *Credential) TrustedDeviceDetails() (*CredentialTrustedDeviceDetails, error) {
+	details, ok := c.details.(*CredentialTrustedDeviceDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected trusted device credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
+func (c *Credential) KnownDeviceDetails() (*CredentialKnownDeviceDetails, error) {
+	details, ok := c.details.(*CredentialKnownDeviceDetails)
+	if ok {
+		return details, nil
+	}
+
+	return nil, fmt.Errorf("expected known device credential, got %s: %w", c._type, ErrWrongCredentialCast)
+}
+
 // CredentialType represents a credential type.
 // Use the following constants: CredentialTypePassword
 type CredentialType string
 
 const (
-	CredentialTypePassword       CredentialType = "password"
-	CredentialTypeTotp           CredentialType = "totp"
-	CredentialTypeServiceUserKey CredentialType = "service_user_key"
-	CredentialTypeWebauthn       CredentialType = "webauthn"
+	CredentialTypePassword         CredentialType = "password"
+	CredentialTypeTotp             CredentialType = "totp"
+	CredentialTypeServiceUserKey   CredentialType = "service_user_key"
+	CredentialTypeWebauthn         CredentialType = "webauthn"
+	CredentialTypeIdentityProvider CredentialType = "identity_provider"
+	CredentialTypeLdap             CredentialType = "ldap"
+	CredentialTypePhone            CredentialType = "phone"
+	CredentialTypeRecoveryCode     CredentialType = "recovery_code"
+	CredentialTypeTrustedDevice    CredentialType = "trusted_device"
+	CredentialTypeKnownDevice      CredentialType = "known_device"
 )
 
 type CredentialDetails interface {
@@ -146,9 +210,32 @@ func (d *CredentialServiceUserKey) Scan(value any) error {
 	return json.Unmarshal(bytes, &d)
 }
 
+// MaxPasswordHistory is how many previous password hashes are kept for the
+// history rule.
+const MaxPasswordHistory = 24
+
 type CredentialPasswordDetails struct {
 	HashedPassword string `json:"hashedPassword"`
 	Temporary      bool   `json:"temporary"`
+	// ChangedAt is when the password was set, it is missing for passwords
+	// that were set before it was recorded.
+	ChangedAt *time.Time `json:"changedAt,omitempty"`
+	// History holds the hashes of the previous passwords, newest first.
+	History []string `json:"history,omitempty"`
+}
+
+// ChangePassword replaces the hashed password and moves the current one
+// into the history.
+func (d *CredentialPasswordDetails) ChangePassword(hashedPassword string, now time.Time) {
+	if d.HashedPassword != "" {
+		d.History = append([]string{d.HashedPassword}, d.History...)
+		if len(d.History) > MaxPasswordHistory {
+			d.History = d.History[:MaxPasswordHistory]
+		}
+	}
+
+	d.HashedPassword = hashedPassword
+	d.ChangedAt = &now
 }
 
 func (d *CredentialPasswordDetails) CredentialDetailType() CredentialType {
@@ -172,6 +259,39 @@ type CredentialWebauthnDetails struct {
 	CredentialId       string `json:"credentialId"`
 	PublicKeyAlgorithm int    `json:"publicKeyAlgorithm"`
 	PublicKey          []byte `json:"publicKey"`
+	// Name is chosen by the user to tell their passkeys apart.
+	Name string `json:"name,omitempty"`
+	// SignCount is the signature counter of the authenticator at the
+	// last successful login, authenticators that do not implement a
+	// counter always report 0.
+	SignCount  uint32     `json:"signCount"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	// Aaguid identifies the authenticator model, it is only trustworthy
+	// if AttestationFormat is not "none".
+	Aaguid            uuid.UUID `json:"aaguid"`
+	AttestationFormat string    `json:"attestationFormat,omitempty"`
+}
+
+// AuthenticationMethod returns the "amr" value of a login with the passkey.
+// Only attested passkeys are known to live on a hardware authenticator.
+func (d *CredentialWebauthnDetails) AuthenticationMethod() AuthenticationMethod {
+	if d.AttestationFormat == "" || d.AttestationFormat == "none" {
+		return AuthenticationMethodSoftwareKey
+	}
+	return AuthenticationMethodHardwareKey
+}
+
+// RecordUse remembers a successful login with the passkey. A counter that
+// did not increase hints at a cloned authenticator, so it is rejected unless
+// the authenticator does not implement a counter at all.
+func (d *CredentialWebauthnDetails) RecordUse(signCount uint32, usedAt time.Time) error {
+	if (signCount != 0 || d.SignCount != 0) && signCount <= d.SignCount {
+		return fmt.Errorf("got %d after %d: %w", signCount, d.SignCount, ErrWebauthnSignCountRegression)
+	}
+
+	d.SignCount = signCount
+	d.LastUsedAt = &usedAt
+	return nil
 }
 
 func (d *CredentialWebauthnDetails) CredentialDetailType() CredentialType {
@@ -195,6 +315,8 @@ type CredentialTotpDetails struct {
 	Secret    string `json:"secret"`
 	Digits    int    `json:"digits"`
 	Algorithm int    `json:"algorithm"`
+	// Name is chosen by the user to tell their authenticators apart.
+	Name string `json:"name,omitempty"`
 }
 
 func (d *CredentialTotpDetails) CredentialDetailType() CredentialType {
@@ -214,13 +336,208 @@ func (d *CredentialTotpDetails) Scan(value any) error {
 	return json.Unmarshal(bytes, &d)
 }
 
+// CredentialIdentityProviderDetails links a user to their account at an
+// upstream identity provider.
+type CredentialIdentityProviderDetails struct {
+	CredentialId       string    `json:"credentialId"`
+	IdentityProviderId uuid.UUID `json:"identityProviderId"`
+	Subject            string    `json:"subject"`
+}
+
+// NewCredentialIdentityProviderDetails builds the link details. The
+// credential id combines provider and subject so a link can be looked up
+// with CredentialFilter.DetailsId.
+func NewCredentialIdentityProviderDetails(identityProviderId uuid.UUID, subject string) *CredentialIdentityProviderDetails {
+	return &CredentialIdentityProviderDetails{
+		CredentialId:       IdentityProviderCredentialId(identityProviderId, subject),
+		IdentityProviderId: identityProviderId,
+		Subject:            subject,
+	}
+}
+
+func IdentityProviderCredentialId(identityProviderId uuid.UUID, subject string) string {
+	return identityProviderId.String() + ":" + subject
+}
+
+func (d *CredentialIdentityProviderDetails) CredentialDetailType() CredentialType {
+	return CredentialTypeIdentityProvider
+}
+
+func (d *CredentialIdentityProviderDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialIdentityProviderDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
+// CredentialLdapDetails links a user to their entry in an LDAP directory.
+// Passwords of such users are verified with a bind against the directory.
+type CredentialLdapDetails struct {
+	CredentialId   string    `json:"credentialId"`
+	LdapProviderId uuid.UUID `json:"ldapProviderId"`
+	ExternalId     string    `json:"externalId"`
+	Dn             string    `json:"dn"`
+}
+
+// NewCredentialLdapDetails builds the link details. The credential id
+// combines provider and external id so a link can be looked up with
+// CredentialFilter.DetailsId.
+func NewCredentialLdapDetails(ldapProviderId uuid.UUID, externalId string, dn string) *CredentialLdapDetails {
+	return &CredentialLdapDetails{
+		CredentialId:   LdapCredentialId(ldapProviderId, externalId),
+		LdapProviderId: ldapProviderId,
+		ExternalId:     externalId,
+		Dn:             dn,
+	}
+}
+
+func LdapCredentialId(ldapProviderId uuid.UUID, externalId string) string {
+	return ldapProviderId.String() + ":" + externalId
+}
+
+func (d *CredentialLdapDetails) CredentialDetailType() CredentialType {
+	return CredentialTypeLdap
+}
+
+func (d *CredentialLdapDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialLdapDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
+// CredentialPhoneDetails is a phone number the user proved to receive text
+// messages on, codes of the SMS second factor are sent to it.
+type CredentialPhoneDetails struct {
+	PhoneNumber string    `json:"phoneNumber"`
+	VerifiedAt  time.Time `json:"verifiedAt"`
+}
+
+func (d *CredentialPhoneDetails) CredentialDetailType() CredentialType {
+	return CredentialTypePhone
+}
+
+func (d *CredentialPhoneDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialPhoneDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
+// CredentialRecoveryCodeDetails is a single use code that replaces the
+// TOTP code when the authenticator is lost. Each code is a credential of
+// its own, so that using one is deleting it.
+type CredentialRecoveryCodeDetails struct {
+	HashedCode string `json:"hashedCode"`
+}
+
+func (d *CredentialRecoveryCodeDetails) CredentialDetailType() CredentialType {
+	return CredentialTypeRecoveryCode
+}
+
+func (d *CredentialRecoveryCodeDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialRecoveryCodeDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
+// CredentialTrustedDeviceDetails is a browser the user chose to trust after
+// passing the second factor. Logins from it skip the second factor until it
+// expires.
+type CredentialTrustedDeviceDetails struct {
+	Name       string     `json:"name"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func (d *CredentialTrustedDeviceDetails) CredentialDetailType() CredentialType {
+	return CredentialTypeTrustedDevice
+}
+
+func (d *CredentialTrustedDeviceDetails) IsExpired(now time.Time) bool {
+	return !now.Before(d.ExpiresAt)
+}
+
+func (d *CredentialTrustedDeviceDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialTrustedDeviceDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
+// CredentialKnownDeviceDetails is a user agent the user finished a login
+// with, MFA rules can ask for the second factor on new ones. Unlike a
+// trusted device it proves nothing and never skips a factor.
+type CredentialKnownDeviceDetails struct {
+	CredentialId string    `json:"credentialId"`
+	Name         string    `json:"name"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+}
+
+// KnownDeviceCredentialId hashes the user agent, so that a known device
+// can be looked up with CredentialFilter.DetailsId.
+func KnownDeviceCredentialId(userAgent string) string {
+	hash := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(hash[:])
+}
+
+func (d *CredentialKnownDeviceDetails) CredentialDetailType() CredentialType {
+	return CredentialTypeKnownDevice
+}
+
+func (d *CredentialKnownDeviceDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *CredentialKnownDeviceDetails) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for credential failed")
+	}
+
+	return json.Unmarshal(bytes, &d)
+}
+
 type CredentialFilter struct {
-	id              *uuid.UUID
-	userId          *uuid.UUID
-	_type           *CredentialType
-	detailId        *string
-	detailKid       *string
-	detailPublicKey *string
+	id                       *uuid.UUID
+	userId                   *uuid.UUID
+	_type                    *CredentialType
+	detailId                 *string
+	detailKid                *string
+	detailPublicKey          *string
+	detailIdentityProviderId *uuid.UUID
+	detailLdapProviderId     *uuid.UUID
 }
 
 func NewCredentialFilter() *CredentialFilter {
@@ -302,6 +619,34 @@ func (f *CredentialFilter) GetDetailKid() string {
 	return utils.ZeroIfNil(f.detailKid)
 }
 
+func (f *CredentialFilter) DetailIdentityProviderId(identityProviderId uuid.UUID) *CredentialFilter {
+	filter := f.Clone()
+	filter.detailIdentityProviderId = &identityProviderId
+	return filter
+}
+
+func (f *CredentialFilter) HasDetailIdentityProviderId() bool {
+	return f.detailIdentityProviderId != nil
+}
+
+func (f *CredentialFilter) GetDetailIdentityProviderId() uuid.UUID {
+	return utils.ZeroIfNil(f.detailIdentityProviderId)
+}
+
+func (f *CredentialFilter) DetailLdapProviderId(ldapProviderId uuid.UUID) *CredentialFilter {
+	filter := f.Clone()
+	filter.detailLdapProviderId = &ldapProviderId
+	return filter
+}
+
+func (f *CredentialFilter) HasDetailLdapProviderId() bool {
+	return f.detailLdapProviderId != nil
+}
+
+func (f *CredentialFilter) GetDetailLdapProviderId() uuid.UUID {
+	return utils.ZeroIfNil(f.detailLdapProviderId)
+}
+
 func (f *CredentialFilter) DetailsId(id string) *CredentialFilter {
 	filter := f.Clone()
 	filter.detailId = &id
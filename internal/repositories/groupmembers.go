@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/google/uuid"
+)
+
+type GroupMember struct {
+	BaseModel
+
+	groupId uuid.UUID
+	userId  uuid.UUID
+}
+
+func NewGroupMember(groupId uuid.UUID, userId uuid.UUID) *GroupMember {
+	return &GroupMember{
+		BaseModel: NewBaseModel(),
+		groupId:   groupId,
+		userId:    userId,
+	}
+}
+
+func NewGroupMemberFromDB(base BaseModel, groupId uuid.UUID, userId uuid.UUID) *GroupMember {
+	return &GroupMember{
+		BaseModel: base,
+		groupId:   groupId,
+		userId:    userId,
+	}
+}
+
+func (g *GroupMember) GroupId() uuid.UUID {
+	return g.groupId
+}
+
+func (g *GroupMember) UserId() uuid.UUID {
+	return g.userId
+}
+
+type GroupMemberFilter struct {
+	groupId *uuid.UUID
+	userId  *uuid.UUID
+}
+
+func NewGroupMemberFilter() *GroupMemberFilter {
+	return &GroupMemberFilter{}
+}
+
+func (f *GroupMemberFilter) Clone() *GroupMemberFilter {
+	clone := *f
+	return &clone
+}
+
+func (f *GroupMemberFilter) GroupId(groupId uuid.UUID) *GroupMemberFilter {
+	filter := f.Clone()
+	filter.groupId = &groupId
+	return filter
+}
+
+func (f *GroupMemberFilter) HasGroupId() bool {
+	return f.groupId != nil
+}
+
+func (f *GroupMemberFilter) GetGroupId() uuid.UUID {
+	return utils.ZeroIfNil(f.groupId)
+}
+
+func (f *GroupMemberFilter) UserId(userId uuid.UUID) *GroupMemberFilter {
+	filter := f.Clone()
+	filter.userId = &userId
+	return filter
+}
+
+func (f *GroupMemberFilter) HasUserId() bool {
+	return f.userId != nil
+}
+
+func (f *GroupMemberFilter) GetUserId() uuid.UUID {
+	return utils.ZeroIfNil(f.userId)
+}
+
+//go:generate mockgen -destination=./mocks/groupmember_repository.go -package=mocks Keyline/internal/repositories GroupMemberRepository
+type GroupMemberRepository interface {
+	FirstOrNil(ctx context.Context, filter *GroupMemberFilter) (*GroupMember, error)
+	List(ctx context.Context, filter *GroupMemberFilter) ([]*GroupMember, error)
+	Insert(groupMember *GroupMember)
+	Delete(id uuid.UUID)
+}
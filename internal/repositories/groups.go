@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"time"
+
 	"github.com/The127/Keyline/internal/change"
 	"github.com/The127/Keyline/utils"
 
@@ -81,6 +83,7 @@ type GroupFilter struct {
 	name            *string
 	virtualServerId *uuid.UUID
 	id              *uuid.UUID
+	updatedAfter    *time.Time
 	searchFilter    *SearchFilter
 }
 
@@ -183,6 +186,21 @@ func (f *GroupFilter) GetId() uuid.UUID {
 	return utils.ZeroIfNil(f.id)
 }
 
+// UpdatedAfter matches groups changed strictly after the given time.
+func (f *GroupFilter) UpdatedAfter(updatedAfter time.Time) *GroupFilter {
+	filter := f.Clone()
+	filter.updatedAfter = &updatedAfter
+	return filter
+}
+
+func (f *GroupFilter) HasUpdatedAfter() bool {
+	return f.updatedAfter != nil
+}
+
+func (f *GroupFilter) GetUpdatedAfter() time.Time {
+	return utils.ZeroIfNil(f.updatedAfter)
+}
+
 //go:generate mockgen -destination=./mocks/group_repository.go -package=mocks Keyline/internal/repositories GroupRepository
 type GroupRepository interface {
 	FirstOrErr(ctx context.Context, filter *GroupFilter) (*Group, error)
@@ -0,0 +1,350 @@
+package repositories
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/google/uuid"
+)
+
+type IdentityProviderChange int
+
+const (
+	IdentityProviderChangeDisplayName IdentityProviderChange = iota
+	IdentityProviderChangeIssuer
+	IdentityProviderChangeClientId
+	IdentityProviderChangeClientSecret
+	IdentityProviderChangeScopes
+	IdentityProviderChangeAuthorizationUrl
+	IdentityProviderChangeTokenUrl
+	IdentityProviderChangeUserinfoUrl
+	IdentityProviderChangeClaimMapping
+	IdentityProviderChangeLinkByEmail
+	IdentityProviderChangeTrustUpstreamMfa
+)
+
+// IdentityProviderClaimMapping names the upstream claims that are used to
+// identify and provision a brokered user.
+type IdentityProviderClaimMapping struct {
+	Subject       string `json:"subject"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"emailVerified"`
+	DisplayName   string `json:"displayName"`
+}
+
+// DefaultIdentityProviderClaimMapping returns the standard OIDC claim names.
+func DefaultIdentityProviderClaimMapping() IdentityProviderClaimMapping {
+	return IdentityProviderClaimMapping{
+		Subject:       "sub",
+		Username:      "preferred_username",
+		Email:         "email",
+		EmailVerified: "email_verified",
+		DisplayName:   "name",
+	}
+}
+
+// WithDefaults fills every unset claim name with its standard OIDC counterpart.
+func (m IdentityProviderClaimMapping) WithDefaults() IdentityProviderClaimMapping {
+	defaults := DefaultIdentityProviderClaimMapping()
+	if m.Subject == "" {
+		m.Subject = defaults.Subject
+	}
+	if m.Username == "" {
+		m.Username = defaults.Username
+	}
+	if m.Email == "" {
+		m.Email = defaults.Email
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = defaults.EmailVerified
+	}
+	if m.DisplayName == "" {
+		m.DisplayName = defaults.DisplayName
+	}
+	return m
+}
+
+// IdentityProvider is an upstream OIDC/OAuth2 provider users of a virtual
+// server can sign in with.
+type IdentityProvider struct {
+	BaseModel
+	change.List[IdentityProviderChange]
+
+	virtualServerId uuid.UUID
+
+	name        string
+	displayName string
+
+	issuer       string
+	clientId     string
+	clientSecret string
+	scopes       []string
+
+	authorizationUrl *string
+	tokenUrl         *string
+	userinfoUrl      *string
+
+	claimMapping     IdentityProviderClaimMapping
+	linkByEmail      bool
+	trustUpstreamMfa bool
+}
+
+func NewIdentityProvider(virtualServerId uuid.UUID, name string, displayName string, issuer string, clientId string, clientSecret string, scopes []string) *IdentityProvider {
+	return &IdentityProvider{
+		BaseModel:       NewBaseModel(),
+		List:            change.NewChanges[IdentityProviderChange](),
+		virtualServerId: virtualServerId,
+		name:            name,
+		displayName:     displayName,
+		issuer:          issuer,
+		clientId:        clientId,
+		clientSecret:    clientSecret,
+		scopes:          scopes,
+		claimMapping:    DefaultIdentityProviderClaimMapping(),
+	}
+}
+
+func NewIdentityProviderFromDB(
+	base BaseModel,
+	virtualServerId uuid.UUID,
+	name string,
+	displayName string,
+	issuer string,
+	clientId string,
+	clientSecret string,
+	scopes []string,
+	authorizationUrl *string,
+	tokenUrl *string,
+	userinfoUrl *string,
+	claimMapping IdentityProviderClaimMapping,
+	linkByEmail bool,
+	trustUpstreamMfa bool,
+) *IdentityProvider {
+	return &IdentityProvider{
+		BaseModel:        base,
+		List:             change.NewChanges[IdentityProviderChange](),
+		virtualServerId:  virtualServerId,
+		name:             name,
+		displayName:      displayName,
+		issuer:           issuer,
+		clientId:         clientId,
+		clientSecret:     clientSecret,
+		scopes:           scopes,
+		authorizationUrl: authorizationUrl,
+		tokenUrl:         tokenUrl,
+		userinfoUrl:      userinfoUrl,
+		claimMapping:     claimMapping,
+		linkByEmail:      linkByEmail,
+		trustUpstreamMfa: trustUpstreamMfa,
+	}
+}
+
+func (p *IdentityProvider) VirtualServerId() uuid.UUID {
+	return p.virtualServerId
+}
+
+func (p *IdentityProvider) Name() string {
+	return p.name
+}
+
+func (p *IdentityProvider) DisplayName() string {
+	return p.displayName
+}
+
+func (p *IdentityProvider) SetDisplayName(displayName string) {
+	if p.displayName == displayName {
+		return
+	}
+
+	p.displayName = displayName
+	p.TrackChange(IdentityProviderChangeDisplayName)
+}
+
+func (p *IdentityProvider) Issuer() string {
+	return p.issuer
+}
+
+func (p *IdentityProvider) SetIssuer(issuer string) {
+	if p.issuer == issuer {
+		return
+	}
+
+	p.issuer = issuer
+	p.TrackChange(IdentityProviderChangeIssuer)
+}
+
+func (p *IdentityProvider) ClientId() string {
+	return p.clientId
+}
+
+func (p *IdentityProvider) SetClientId(clientId string) {
+	if p.clientId == clientId {
+		return
+	}
+
+	p.clientId = clientId
+	p.TrackChange(IdentityProviderChangeClientId)
+}
+
+func (p *IdentityProvider) ClientSecret() string {
+	return p.clientSecret
+}
+
+func (p *IdentityProvider) SetClientSecret(clientSecret string) {
+	if p.clientSecret == clientSecret {
+		return
+	}
+
+	p.clientSecret = clientSecret
+	p.TrackChange(IdentityProviderChangeClientSecret)
+}
+
+func (p *IdentityProvider) Scopes() []string {
+	return p.scopes
+}
+
+func (p *IdentityProvider) SetScopes(scopes []string) {
+	p.scopes = scopes
+	p.TrackChange(IdentityProviderChangeScopes)
+}
+
+func (p *IdentityProvider) AuthorizationUrl() *string {
+	return p.authorizationUrl
+}
+
+func (p *IdentityProvider) SetAuthorizationUrl(authorizationUrl *string) {
+	p.authorizationUrl = authorizationUrl
+	p.TrackChange(IdentityProviderChangeAuthorizationUrl)
+}
+
+func (p *IdentityProvider) TokenUrl() *string {
+	return p.tokenUrl
+}
+
+func (p *IdentityProvider) SetTokenUrl(tokenUrl *string) {
+	p.tokenUrl = tokenUrl
+	p.TrackChange(IdentityProviderChangeTokenUrl)
+}
+
+func (p *IdentityProvider) UserinfoUrl() *string {
+	return p.userinfoUrl
+}
+
+func (p *IdentityProvider) SetUserinfoUrl(userinfoUrl *string) {
+	p.userinfoUrl = userinfoUrl
+	p.TrackChange(IdentityProviderChangeUserinfoUrl)
+}
+
+func (p *IdentityProvider) ClaimMapping() IdentityProviderClaimMapping {
+	return p.claimMapping
+}
+
+func (p *IdentityProvider) SetClaimMapping(claimMapping IdentityProviderClaimMapping) {
+	if p.claimMapping == claimMapping {
+		return
+	}
+
+	p.claimMapping = claimMapping
+	p.TrackChange(IdentityProviderChangeClaimMapping)
+}
+
+// LinkByEmail reports whether a brokered login may be linked to an existing
+// local user with the same email address when the upstream marks the email
+// as verified.
+func (p *IdentityProvider) LinkByEmail() bool {
+	return p.linkByEmail
+}
+
+func (p *IdentityProvider) SetLinkByEmail(linkByEmail bool) {
+	if p.linkByEmail == linkByEmail {
+		return
+	}
+
+	p.linkByEmail = linkByEmail
+	p.TrackChange(IdentityProviderChangeLinkByEmail)
+}
+
+// TrustUpstreamMfa reports whether an upstream login that reports more than
+// one factor in its id token stands in for a local second factor. Any
+// provider can claim that, so it is off unless an admin trusts the provider.
+func (p *IdentityProvider) TrustUpstreamMfa() bool {
+	return p.trustUpstreamMfa
+}
+
+func (p *IdentityProvider) SetTrustUpstreamMfa(trustUpstreamMfa bool) {
+	if p.trustUpstreamMfa == trustUpstreamMfa {
+		return
+	}
+
+	p.trustUpstreamMfa = trustUpstreamMfa
+	p.TrackChange(IdentityProviderChangeTrustUpstreamMfa)
+}
+
+type IdentityProviderFilter struct {
+	virtualServerId *uuid.UUID
+	id              *uuid.UUID
+	name            *string
+}
+
+func NewIdentityProviderFilter() *IdentityProviderFilter {
+	return &IdentityProviderFilter{}
+}
+
+func (f *IdentityProviderFilter) Clone() *IdentityProviderFilter {
+	clone := *f
+	return &clone
+}
+
+func (f *IdentityProviderFilter) VirtualServerId(virtualServerId uuid.UUID) *IdentityProviderFilter {
+	filter := f.Clone()
+	filter.virtualServerId = &virtualServerId
+	return filter
+}
+
+func (f *IdentityProviderFilter) HasVirtualServerId() bool {
+	return f.virtualServerId != nil
+}
+
+func (f *IdentityProviderFilter) GetVirtualServerId() uuid.UUID {
+	return utils.ZeroIfNil(f.virtualServerId)
+}
+
+func (f *IdentityProviderFilter) Id(id uuid.UUID) *IdentityProviderFilter {
+	filter := f.Clone()
+	filter.id = &id
+	return filter
+}
+
+func (f *IdentityProviderFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *IdentityProviderFilter) GetId() uuid.UUID {
+	return utils.ZeroIfNil(f.id)
+}
+
+func (f *IdentityProviderFilter) Name(name string) *IdentityProviderFilter {
+	filter := f.Clone()
+	filter.name = &name
+	return filter
+}
+
+func (f *IdentityProviderFilter) HasName() bool {
+	return f.name != nil
+}
+
+func (f *IdentityProviderFilter) GetName() string {
+	return utils.ZeroIfNil(f.name)
+}
+
+//go:generate mockgen -destination=./mocks/identityprovider_repository.go -package=mocks Keyline/internal/repositories IdentityProviderRepository
+type IdentityProviderRepository interface {
+	FirstOrErr(ctx context.Context, filter *IdentityProviderFilter) (*IdentityProvider, error)
+	FirstOrNil(ctx context.Context, filter *IdentityProviderFilter) (*IdentityProvider, error)
+	List(ctx context.Context, filter *IdentityProviderFilter) ([]*IdentityProvider, error)
+	Insert(identityProvider *IdentityProvider)
+	Update(identityProvider *IdentityProvider)
+	Delete(id uuid.UUID)
+}
@@ -0,0 +1,450 @@
+package repositories
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LdapProviderChange int
+
+const (
+	LdapProviderChangeConnectionUrl LdapProviderChange = iota
+	LdapProviderChangeStartTls
+	LdapProviderChangeBindDn
+	LdapProviderChangeBindPassword
+	LdapProviderChangeUsersDn
+	LdapProviderChangeUserFilter
+	LdapProviderChangeGroupsDn
+	LdapProviderChangeGroupFilter
+	LdapProviderChangeAttributeMapping
+	LdapProviderChangeEditMode
+	LdapProviderChangeSyncInterval
+	LdapProviderChangeLastSyncAt
+)
+
+type LdapVendor string
+
+const (
+	LdapVendorGeneric         LdapVendor = "generic"
+	LdapVendorActiveDirectory LdapVendor = "activeDirectory"
+)
+
+// LdapEditMode decides whether Keyline may write changes back to the
+// directory.
+type LdapEditMode string
+
+const (
+	// LdapEditModeReadOnly rejects password and profile changes of
+	// federated users.
+	LdapEditModeReadOnly LdapEditMode = "readOnly"
+	// LdapEditModeWritable writes password and profile changes of federated
+	// users back to the directory.
+	LdapEditModeWritable LdapEditMode = "writable"
+)
+
+// LdapAttributeMapping names the directory attributes that are mapped onto
+// users and groups.
+type LdapAttributeMapping struct {
+	ExternalId  string `json:"externalId"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName"`
+	GroupName   string `json:"groupName"`
+	GroupMember string `json:"groupMember"`
+}
+
+// DefaultLdapAttributeMapping returns the attribute names commonly used by
+// the given vendor.
+func DefaultLdapAttributeMapping(vendor LdapVendor) LdapAttributeMapping {
+	if vendor == LdapVendorActiveDirectory {
+		return LdapAttributeMapping{
+			ExternalId:  "objectGUID",
+			Username:    "sAMAccountName",
+			Email:       "mail",
+			DisplayName: "displayName",
+			GroupName:   "cn",
+			GroupMember: "member",
+		}
+	}
+
+	return LdapAttributeMapping{
+		ExternalId:  "entryUUID",
+		Username:    "uid",
+		Email:       "mail",
+		DisplayName: "cn",
+		GroupName:   "cn",
+		GroupMember: "member",
+	}
+}
+
+// WithDefaults fills every unset attribute name with the vendor default.
+func (m LdapAttributeMapping) WithDefaults(vendor LdapVendor) LdapAttributeMapping {
+	defaults := DefaultLdapAttributeMapping(vendor)
+	if m.ExternalId == "" {
+		m.ExternalId = defaults.ExternalId
+	}
+	if m.Username == "" {
+		m.Username = defaults.Username
+	}
+	if m.Email == "" {
+		m.Email = defaults.Email
+	}
+	if m.DisplayName == "" {
+		m.DisplayName = defaults.DisplayName
+	}
+	if m.GroupName == "" {
+		m.GroupName = defaults.GroupName
+	}
+	if m.GroupMember == "" {
+		m.GroupMember = defaults.GroupMember
+	}
+	return m
+}
+
+// DefaultLdapUserFilter returns the search filter that selects user entries
+// for the given vendor.
+func DefaultLdapUserFilter(vendor LdapVendor) string {
+	if vendor == LdapVendorActiveDirectory {
+		return "(&(objectCategory=person)(objectClass=user))"
+	}
+	return "(objectClass=inetOrgPerson)"
+}
+
+// DefaultLdapGroupFilter returns the search filter that selects group
+// entries for the given vendor.
+func DefaultLdapGroupFilter(vendor LdapVendor) string {
+	if vendor == LdapVendorActiveDirectory {
+		return "(objectClass=group)"
+	}
+	return "(objectClass=groupOfNames)"
+}
+
+// LdapProvider federates the users of a virtual server with an LDAP
+// directory or Active Directory.
+type LdapProvider struct {
+	BaseModel
+	change.List[LdapProviderChange]
+
+	virtualServerId uuid.UUID
+
+	name   string
+	vendor LdapVendor
+
+	connectionUrl string
+	startTls      bool
+	bindDn        string
+	bindPassword  string
+
+	usersDn    string
+	userFilter string
+
+	// groupsDn is nil when group memberships are not synchronized
+	groupsDn    *string
+	groupFilter string
+
+	attributeMapping LdapAttributeMapping
+	editMode         LdapEditMode
+
+	// syncInterval of zero disables the scheduled synchronization
+	syncInterval time.Duration
+	lastSyncAt   *time.Time
+}
+
+func NewLdapProvider(virtualServerId uuid.UUID, name string, vendor LdapVendor, connectionUrl string, bindDn string, bindPassword string, usersDn string) *LdapProvider {
+	return &LdapProvider{
+		BaseModel:        NewBaseModel(),
+		List:             change.NewChanges[LdapProviderChange](),
+		virtualServerId:  virtualServerId,
+		name:             name,
+		vendor:           vendor,
+		connectionUrl:    connectionUrl,
+		bindDn:           bindDn,
+		bindPassword:     bindPassword,
+		usersDn:          usersDn,
+		userFilter:       DefaultLdapUserFilter(vendor),
+		groupFilter:      DefaultLdapGroupFilter(vendor),
+		attributeMapping: DefaultLdapAttributeMapping(vendor),
+		editMode:         LdapEditModeReadOnly,
+	}
+}
+
+func NewLdapProviderFromDB(
+	base BaseModel,
+	virtualServerId uuid.UUID,
+	name string,
+	vendor LdapVendor,
+	connectionUrl string,
+	startTls bool,
+	bindDn string,
+	bindPassword string,
+	usersDn string,
+	userFilter string,
+	groupsDn *string,
+	groupFilter string,
+	attributeMapping LdapAttributeMapping,
+	editMode LdapEditMode,
+	syncInterval time.Duration,
+	lastSyncAt *time.Time,
+) *LdapProvider {
+	return &LdapProvider{
+		BaseModel:        base,
+		List:             change.NewChanges[LdapProviderChange](),
+		virtualServerId:  virtualServerId,
+		name:             name,
+		vendor:           vendor,
+		connectionUrl:    connectionUrl,
+		startTls:         startTls,
+		bindDn:           bindDn,
+		bindPassword:     bindPassword,
+		usersDn:          usersDn,
+		userFilter:       userFilter,
+		groupsDn:         groupsDn,
+		groupFilter:      groupFilter,
+		attributeMapping: attributeMapping,
+		editMode:         editMode,
+		syncInterval:     syncInterval,
+		lastSyncAt:       lastSyncAt,
+	}
+}
+
+func (p *LdapProvider) VirtualServerId() uuid.UUID {
+	return p.virtualServerId
+}
+
+func (p *LdapProvider) Name() string {
+	return p.name
+}
+
+func (p *LdapProvider) Vendor() LdapVendor {
+	return p.vendor
+}
+
+func (p *LdapProvider) ConnectionUrl() string {
+	return p.connectionUrl
+}
+
+func (p *LdapProvider) SetConnectionUrl(connectionUrl string) {
+	if p.connectionUrl == connectionUrl {
+		return
+	}
+
+	p.connectionUrl = connectionUrl
+	p.TrackChange(LdapProviderChangeConnectionUrl)
+}
+
+func (p *LdapProvider) StartTls() bool {
+	return p.startTls
+}
+
+func (p *LdapProvider) SetStartTls(startTls bool) {
+	if p.startTls == startTls {
+		return
+	}
+
+	p.startTls = startTls
+	p.TrackChange(LdapProviderChangeStartTls)
+}
+
+func (p *LdapProvider) BindDn() string {
+	return p.bindDn
+}
+
+func (p *LdapProvider) SetBindDn(bindDn string) {
+	if p.bindDn == bindDn {
+		return
+	}
+
+	p.bindDn = bindDn
+	p.TrackChange(LdapProviderChangeBindDn)
+}
+
+func (p *LdapProvider) BindPassword() string {
+	return p.bindPassword
+}
+
+func (p *LdapProvider) SetBindPassword(bindPassword string) {
+	if p.bindPassword == bindPassword {
+		return
+	}
+
+	p.bindPassword = bindPassword
+	p.TrackChange(LdapProviderChangeBindPassword)
+}
+
+func (p *LdapProvider) UsersDn() string {
+	return p.usersDn
+}
+
+func (p *LdapProvider) SetUsersDn(usersDn string) {
+	if p.usersDn == usersDn {
+		return
+	}
+
+	p.usersDn = usersDn
+	p.TrackChange(LdapProviderChangeUsersDn)
+}
+
+func (p *LdapProvider) UserFilter() string {
+	return p.userFilter
+}
+
+func (p *LdapProvider) SetUserFilter(userFilter string) {
+	if p.userFilter == userFilter {
+		return
+	}
+
+	p.userFilter = userFilter
+	p.TrackChange(LdapProviderChangeUserFilter)
+}
+
+func (p *LdapProvider) GroupsDn() *string {
+	return p.groupsDn
+}
+
+func (p *LdapProvider) SetGroupsDn(groupsDn *string) {
+	p.groupsDn = groupsDn
+	p.TrackChange(LdapProviderChangeGroupsDn)
+}
+
+func (p *LdapProvider) GroupFilter() string {
+	return p.groupFilter
+}
+
+func (p *LdapProvider) SetGroupFilter(groupFilter string) {
+	if p.groupFilter == groupFilter {
+		return
+	}
+
+	p.groupFilter = groupFilter
+	p.TrackChange(LdapProviderChangeGroupFilter)
+}
+
+func (p *LdapProvider) AttributeMapping() LdapAttributeMapping {
+	return p.attributeMapping
+}
+
+func (p *LdapProvider) SetAttributeMapping(attributeMapping LdapAttributeMapping) {
+	if p.attributeMapping == attributeMapping {
+		return
+	}
+
+	p.attributeMapping = attributeMapping
+	p.TrackChange(LdapProviderChangeAttributeMapping)
+}
+
+func (p *LdapProvider) EditMode() LdapEditMode {
+	return p.editMode
+}
+
+func (p *LdapProvider) SetEditMode(editMode LdapEditMode) {
+	if p.editMode == editMode {
+		return
+	}
+
+	p.editMode = editMode
+	p.TrackChange(LdapProviderChangeEditMode)
+}
+
+func (p *LdapProvider) SyncInterval() time.Duration {
+	return p.syncInterval
+}
+
+func (p *LdapProvider) SetSyncInterval(syncInterval time.Duration) {
+	if p.syncInterval == syncInterval {
+		return
+	}
+
+	p.syncInterval = syncInterval
+	p.TrackChange(LdapProviderChangeSyncInterval)
+}
+
+func (p *LdapProvider) LastSyncAt() *time.Time {
+	return p.lastSyncAt
+}
+
+func (p *LdapProvider) SetLastSyncAt(lastSyncAt time.Time) {
+	p.lastSyncAt = &lastSyncAt
+	p.TrackChange(LdapProviderChangeLastSyncAt)
+}
+
+// SyncDue reports whether the scheduled synchronization should run at now.
+func (p *LdapProvider) SyncDue(now time.Time) bool {
+	if p.syncInterval <= 0 {
+		return false
+	}
+	if p.lastSyncAt == nil {
+		return true
+	}
+	return !now.Before(p.lastSyncAt.Add(p.syncInterval))
+}
+
+type LdapProviderFilter struct {
+	virtualServerId *uuid.UUID
+	id              *uuid.UUID
+	name            *string
+}
+
+func NewLdapProviderFilter() *LdapProviderFilter {
+	return &LdapProviderFilter{}
+}
+
+func (f *LdapProviderFilter) Clone() *LdapProviderFilter {
+	clone := *f
+	return &clone
+}
+
+func (f *LdapProviderFilter) VirtualServerId(virtualServerId uuid.UUID) *LdapProviderFilter {
+	filter := f.Clone()
+	filter.virtualServerId = &virtualServerId
+	return filter
+}
+
+func (f *LdapProviderFilter) HasVirtualServerId() bool {
+	return f.virtualServerId != nil
+}
+
+func (f *LdapProviderFilter) GetVirtualServerId() uuid.UUID {
+	return utils.ZeroIfNil(f.virtualServerId)
+}
+
+func (f *LdapProviderFilter) Id(id uuid.UUID) *LdapProviderFilter {
+	filter := f.Clone()
+	filter.id = &id
+	return filter
+}
+
+func (f *LdapProviderFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *LdapProviderFilter) GetId() uuid.UUID {
+	return utils.ZeroIfNil(f.id)
+}
+
+func (f *LdapProviderFilter) Name(name string) *LdapProviderFilter {
+	filter := f.Clone()
+	filter.name = &name
+	return filter
+}
+
+func (f *LdapProviderFilter) HasName() bool {
+	return f.name != nil
+}
+
+func (f *LdapProviderFilter) GetName() string {
+	return utils.ZeroIfNil(f.name)
+}
+
+//go:generate mockgen -destination=./mocks/ldapprovider_repository.go -package=mocks Keyline/internal/repositories LdapProviderRepository
+type LdapProviderRepository interface {
+	FirstOrErr(ctx context.Context, filter *LdapProviderFilter) (*LdapProvider, error)
+	FirstOrNil(ctx context.Context, filter *LdapProviderFilter) (*LdapProvider, error)
+	List(ctx context.Context, filter *LdapProviderFilter) ([]*LdapProvider, error)
+	Insert(ldapProvider *LdapProvider)
+	Update(ldapProvider *LdapProvider)
+	Delete(id uuid.UUID)
+}
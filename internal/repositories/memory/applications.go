@@ -45,6 +45,9 @@ func (r *ApplicationRepository) matches(a *repositories.Application, filter *rep
 	if filter.HasProjectId() && a.ProjectId() != filter.GetProjectId() {
 		return false
 	}
+	if filter.HasSamlEntityId() && utils.ZeroIfNil(a.SamlEntityId()) != filter.GetSamlEntityId() {
+		return false
+	}
 	if filter.HasSearch() {
 		sf := filter.GetSearch()
 		if !matchesSearch(a.Name(), sf) && !matchesSearch(a.DisplayName(), sf) {
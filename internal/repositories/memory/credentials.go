@@ -37,7 +37,7 @@ func (r *CredentialRepository) matches(c *repositories.Credential, filter *repos
 	if filter.HasType() && c.Type() != filter.GetType() {
 		return false
 	}
-	if filter.HasDetailKid() || filter.HasDetailPublicKey() || filter.HasDetailsId() {
+	if filter.HasDetailKid() || filter.HasDetailPublicKey() || filter.HasDetailsId() || filter.HasDetailIdentityProviderId() || filter.HasDetailLdapProviderId() {
 		// marshal details to JSON to do field-level matching
 		detailJson, err := json.Marshal(c.Details())
 		if err != nil {
@@ -66,6 +66,18 @@ func (r *CredentialRepository) matches(c *repositories.Credential, filter *repos
 				return false
 			}
 		}
+		if filter.HasDetailIdentityProviderId() {
+			id, _ := detailMap["identityProviderId"].(string)
+			if id != filter.GetDetailIdentityProviderId().String() {
+				return false
+			}
+		}
+		if filter.HasDetailLdapProviderId() {
+			id, _ := detailMap["ldapProviderId"].(string)
+			if id != filter.GetDetailLdapProviderId().String() {
+				return false
+			}
+		}
 	}
 	return true
 }
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/repositories"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type GroupMemberRepository struct {
+	store         map[uuid.UUID]*repositories.GroupMember
+	mu            *sync.RWMutex
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewGroupMemberRepository(store map[uuid.UUID]*repositories.GroupMember, mu *sync.RWMutex, changeTracker *change.Tracker, entityType int) *GroupMemberRepository {
+	return &GroupMemberRepository{
+		store:         store,
+		mu:            mu,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *GroupMemberRepository) matches(m *repositories.GroupMember, filter *repositories.GroupMemberFilter) bool {
+	if filter.HasGroupId() && m.GroupId() != filter.GetGroupId() {
+		return false
+	}
+	if filter.HasUserId() && m.UserId() != filter.GetUserId() {
+		return false
+	}
+	return true
+}
+
+func (r *GroupMemberRepository) filtered(filter *repositories.GroupMemberFilter) []*repositories.GroupMember {
+	var result []*repositories.GroupMember
+	for _, m := range r.store {
+		if r.matches(m, filter) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func (r *GroupMemberRepository) FirstOrNil(_ context.Context, filter *repositories.GroupMemberFilter) (*repositories.GroupMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.filtered(filter)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[0], nil
+}
+
+func (r *GroupMemberRepository) List(_ context.Context, filter *repositories.GroupMemberFilter) ([]*repositories.GroupMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filtered(filter), nil
+}
+
+func (r *GroupMemberRepository) Insert(groupMember *repositories.GroupMember) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, groupMember))
+}
+
+func (r *GroupMemberRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
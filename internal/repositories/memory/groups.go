@@ -36,6 +36,9 @@ func (r *GroupRepository) matches(g *repositories.Group, filter *repositories.Gr
 	if filter.HasVirtualServerId() && g.VirtualServerId() != filter.GetVirtualServerId() {
 		return false
 	}
+	if filter.HasUpdatedAfter() && !g.AuditUpdatedAt().After(filter.GetUpdatedAfter()) {
+		return false
+	}
 	if filter.HasSearch() {
 		sf := filter.GetSearch()
 		if !matchesSearch(g.Name(), sf) && !matchesSearch(g.Description(), sf) {
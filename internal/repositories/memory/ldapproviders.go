@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type LdapProviderRepository struct {
+	store         map[uuid.UUID]*repositories.LdapProvider
+	mu            *sync.RWMutex
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewLdapProviderRepository(store map[uuid.UUID]*repositories.LdapProvider, mu *sync.RWMutex, changeTracker *change.Tracker, entityType int) *LdapProviderRepository {
+	return &LdapProviderRepository{
+		store:         store,
+		mu:            mu,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *LdapProviderRepository) matches(p *repositories.LdapProvider, filter *repositories.LdapProviderFilter) bool {
+	if filter.HasVirtualServerId() && p.VirtualServerId() != filter.GetVirtualServerId() {
+		return false
+	}
+	if filter.HasId() && p.Id() != filter.GetId() {
+		return false
+	}
+	if filter.HasName() && p.Name() != filter.GetName() {
+		return false
+	}
+	return true
+}
+
+func (r *LdapProviderRepository) filtered(filter *repositories.LdapProviderFilter) []*repositories.LdapProvider {
+	var result []*repositories.LdapProvider
+	for _, p := range r.store {
+		if r.matches(p, filter) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (r *LdapProviderRepository) FirstOrErr(ctx context.Context, filter *repositories.LdapProviderFilter) (*repositories.LdapProvider, error) {
+	result, err := r.FirstOrNil(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, utils.ErrLdapProviderNotFound
+	}
+	return result, nil
+}
+
+func (r *LdapProviderRepository) FirstOrNil(_ context.Context, filter *repositories.LdapProviderFilter) (*repositories.LdapProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.filtered(filter)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[0], nil
+}
+
+func (r *LdapProviderRepository) List(_ context.Context, filter *repositories.LdapProviderFilter) ([]*repositories.LdapProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filtered(filter), nil
+}
+
+func (r *LdapProviderRepository) Insert(ldapProvider *repositories.LdapProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, ldapProvider))
+}
+
+func (r *LdapProviderRepository) Update(ldapProvider *repositories.LdapProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, ldapProvider))
+}
+
+func (r *LdapProviderRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
@@ -34,6 +34,12 @@ func (r *OutboxMessageRepository) List(_ context.Context, filter *repositories.O
 		if filter.HasId() && m.Id() != filter.GetId() {
 			continue
 		}
+		if filter.HasDueAt() && m.NextAttemptAt() != nil && m.NextAttemptAt().After(filter.GetDueAt()) {
+			continue
+		}
+		if filter.HasDueAt() && m.DeadLetteredAt() != nil {
+			continue
+		}
 		result = append(result, m)
 	}
 	return result, nil
@@ -43,6 +49,10 @@ func (r *OutboxMessageRepository) Insert(outboxMessage *repositories.OutboxMessa
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, outboxMessage))
 }
 
+func (r *OutboxMessageRepository) Update(outboxMessage *repositories.OutboxMessage) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, outboxMessage))
+}
+
 func (r *OutboxMessageRepository) Delete(id uuid.UUID) {
 	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
 }
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type ProvisioningTargetRepository struct {
+	store         map[uuid.UUID]*repositories.ProvisioningTarget
+	mu            *sync.RWMutex
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewProvisioningTargetRepository(store map[uuid.UUID]*repositories.ProvisioningTarget, mu *sync.RWMutex, changeTracker *change.Tracker, entityType int) *ProvisioningTargetRepository {
+	return &ProvisioningTargetRepository{
+		store:         store,
+		mu:            mu,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ProvisioningTargetRepository) matches(t *repositories.ProvisioningTarget, filter *repositories.ProvisioningTargetFilter) bool {
+	if filter.HasVirtualServerId() && t.VirtualServerId() != filter.GetVirtualServerId() {
+		return false
+	}
+	if filter.HasApplicationId() && t.ApplicationId() != filter.GetApplicationId() {
+		return false
+	}
+	if filter.HasId() && t.Id() != filter.GetId() {
+		return false
+	}
+	if filter.HasEnabled() && t.Enabled() != filter.GetEnabled() {
+		return false
+	}
+	return true
+}
+
+func (r *ProvisioningTargetRepository) filtered(filter *repositories.ProvisioningTargetFilter) []*repositories.ProvisioningTarget {
+	var result []*repositories.ProvisioningTarget
+	for _, t := range r.store {
+		if r.matches(t, filter) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (r *ProvisioningTargetRepository) FirstOrErr(ctx context.Context, filter *repositories.ProvisioningTargetFilter) (*repositories.ProvisioningTarget, error) {
+	result, err := r.FirstOrNil(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, utils.ErrProvisioningTargetNotFound
+	}
+	return result, nil
+}
+
+func (r *ProvisioningTargetRepository) FirstOrNil(_ context.Context, filter *repositories.ProvisioningTargetFilter) (*repositories.ProvisioningTarget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.filtered(filter)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[0], nil
+}
+
+func (r *ProvisioningTargetRepository) List(_ context.Context, filter *repositories.ProvisioningTargetFilter) ([]*repositories.ProvisioningTarget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filtered(filter), nil
+}
+
+func (r *ProvisioningTargetRepository) Insert(provisioningTarget *repositories.ProvisioningTarget) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, provisioningTarget))
+}
+
+func (r *ProvisioningTargetRepository) Update(provisioningTarget *repositories.ProvisioningTarget) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, provisioningTarget))
+}
+
+func (r *ProvisioningTargetRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
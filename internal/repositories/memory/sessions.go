@@ -62,6 +62,19 @@ func (r *SessionRepository) FirstOrNil(_ context.Context, filter *repositories.S
 	return nil, nil
 }
 
+func (r *SessionRepository) List(_ context.Context, filter *repositories.SessionFilter) ([]*repositories.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*repositories.Session
+	for _, s := range r.store {
+		if r.matches(s, filter) {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
 func (r *SessionRepository) Insert(session *repositories.Session) {
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, session))
 }
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/repositories"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type SigningKeyRepository struct {
+	store         map[uuid.UUID]*repositories.SigningKey
+	mu            *sync.RWMutex
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewSigningKeyRepository(store map[uuid.UUID]*repositories.SigningKey, mu *sync.RWMutex, changeTracker *change.Tracker, entityType int) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		store:         store,
+		mu:            mu,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *SigningKeyRepository) matches(k *repositories.SigningKey, filter *repositories.SigningKeyFilter) bool {
+	if filter.HasVirtualServerName() && k.VirtualServerName() != filter.GetVirtualServerName() {
+		return false
+	}
+	if filter.HasAlgorithm() && k.Algorithm() != filter.GetAlgorithm() {
+		return false
+	}
+	if filter.HasKid() && k.Kid() != filter.GetKid() {
+		return false
+	}
+	return true
+}
+
+func (r *SigningKeyRepository) filtered(filter *repositories.SigningKeyFilter) []*repositories.SigningKey {
+	var result []*repositories.SigningKey
+	for _, k := range r.store {
+		if r.matches(k, filter) {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+func (r *SigningKeyRepository) FirstOrNil(_ context.Context, filter *repositories.SigningKeyFilter) (*repositories.SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.filtered(filter)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items[0], nil
+}
+
+func (r *SigningKeyRepository) List(_ context.Context, filter *repositories.SigningKeyFilter) ([]*repositories.SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filtered(filter), nil
+}
+
+func (r *SigningKeyRepository) Insert(signingKey *repositories.SigningKey) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, signingKey))
+}
+
+func (r *SigningKeyRepository) Update(signingKey *repositories.SigningKey) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, signingKey))
+}
+
+func (r *SigningKeyRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
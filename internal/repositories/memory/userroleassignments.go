@@ -112,3 +112,7 @@ func (r *UserRoleAssignmentRepository) List(_ context.Context, filter *repositor
 func (r *UserRoleAssignmentRepository) Insert(userRoleAssignment *repositories.UserRoleAssignment) {
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, userRoleAssignment))
 }
+
+func (r *UserRoleAssignmentRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
@@ -36,9 +36,15 @@ func (r *UserRepository) matches(u *repositories.User, filter *repositories.User
 	if filter.HasUsername() && u.Username() != filter.GetUsername() {
 		return false
 	}
+	if filter.HasPrimaryEmail() && u.PrimaryEmail() != filter.GetPrimaryEmail() {
+		return false
+	}
 	if filter.HasServiceUser() && u.IsServiceUser() != filter.GetServiceUser() {
 		return false
 	}
+	if filter.HasUpdatedAfter() && !u.AuditUpdatedAt().After(filter.GetUpdatedAfter()) {
+		return false
+	}
 	if filter.HasSearch() {
 		sf := filter.GetSearch()
 		if !matchesSearch(u.Username(), sf) && !matchesSearch(u.DisplayName(), sf) {
@@ -99,3 +105,7 @@ func (r *UserRepository) Insert(user *repositories.User) {
 func (r *UserRepository) Update(user *repositories.User) {
 	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, user))
 }
+
+func (r *UserRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
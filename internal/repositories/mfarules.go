@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/The127/Keyline/utils"
+	"net/netip"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+type MfaRuleAction string
+
+const (
+	MfaRuleActionRequire MfaRuleAction = "require"
+	MfaRuleActionSkip    MfaRuleAction = "skip"
+)
+
+// MfaRule requires or skips the second factor for the logins that match
+// all of its conditions. Conditions that are not set match every login, a
+// rule without conditions matches all of them.
+type MfaRule struct {
+	Action MfaRuleAction `json:"action"`
+	// Projects are the slugs of the projects of the application the user
+	// logs in to.
+	Projects []string `json:"projects,omitempty"`
+	// Cidrs are the networks the user logs in from.
+	Cidrs []string `json:"cidrs,omitempty"`
+	// RoleIds match users that have any of the roles.
+	RoleIds []uuid.UUID `json:"roleIds,omitempty"`
+	// NewDevice matches logins from a user agent the user never finished
+	// a login with.
+	NewDevice bool `json:"newDevice,omitempty"`
+}
+
+// MfaLogin holds what the conditions of MfaRules are evaluated against.
+type MfaLogin struct {
+	ProjectSlug string
+	ClientIp    string
+	RoleIds     []uuid.UUID
+	NewDevice   bool
+}
+
+func (r MfaRule) Validate() error {
+	switch r.Action {
+	case MfaRuleActionRequire, MfaRuleActionSkip:
+	default:
+		return fmt.Errorf("unknown mfa rule action %q: %w", r.Action, utils.ErrHttpBadRequest)
+	}
+
+	for _, cidr := range r.Cidrs {
+		_, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid cidr %q: %w", cidr, utils.ErrHttpBadRequest)
+		}
+	}
+	return nil
+}
+
+func (r MfaRule) Matches(login MfaLogin) bool {
+	if len(r.Projects) > 0 && !slices.Contains(r.Projects, login.ProjectSlug) {
+		return false
+	}
+
+	if len(r.Cidrs) > 0 && !r.matchesClientIp(login.ClientIp) {
+		return false
+	}
+
+	if len(r.RoleIds) > 0 && !slices.ContainsFunc(r.RoleIds, func(roleId uuid.UUID) bool {
+		return slices.Contains(login.RoleIds, roleId)
+	}) {
+		return false
+	}
+
+	if r.NewDevice && !login.NewDevice {
+		return false
+	}
+
+	return true
+}
+
+func (r MfaRule) matchesClientIp(clientIp string) bool {
+	addr, err := netip.ParseAddr(clientIp)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range r.Cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// MfaRules are evaluated in order, the first matching rule decides whether
+// a login needs a second factor. They are stored as a json column.
+type MfaRules []MfaRule
+
+func (r MfaRules) Validate() error {
+	for _, rule := range r {
+		err := rule.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the action of the first rule the login matches, ok is
+// false if none matches.
+func (r MfaRules) Evaluate(login MfaLogin) (action MfaRuleAction, ok bool) {
+	for _, rule := range r {
+		if rule.Matches(login) {
+			return rule.Action, true
+		}
+	}
+	return "", false
+}
+
+// UsesNewDevice tells whether a rule depends on the device, so that the
+// known devices of a user only need to be looked up then.
+func (r MfaRules) UsesNewDevice() bool {
+	return slices.ContainsFunc(r, func(rule MfaRule) bool {
+		return rule.NewDevice
+	})
+}
+
+// UsesRoles tells whether a rule depends on the roles of the user.
+func (r MfaRules) UsesRoles() bool {
+	return slices.ContainsFunc(r, func(rule MfaRule) bool {
+		return len(rule.RoleIds) > 0
+	})
+}
+
+func (r MfaRules) Value() (driver.Value, error) {
+	if r == nil {
+		return json.Marshal(MfaRules{})
+	}
+	return json.Marshal(r)
+}
+
+func (r *MfaRules) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("type assertion for mfa rules failed")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
@@ -80,3 +80,15 @@ func (mr *MockOutboxMessageRepositoryMockRecorder) List(ctx, filter any) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOutboxMessageRepository)(nil).List), ctx, filter)
 }
+
+// Update mocks base method.
+func (m *MockOutboxMessageRepository) Update(outboxMessage *repositories.OutboxMessage) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Update", outboxMessage)
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockOutboxMessageRepositoryMockRecorder) Update(outboxMessage any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockOutboxMessageRepository)(nil).Update), outboxMessage)
+}
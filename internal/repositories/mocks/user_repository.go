@@ -14,6 +14,7 @@ import (
 	repositories "github.com/The127/Keyline/internal/repositories"
 	reflect "reflect"
 
+	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,6 +42,18 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockUserRepository) Delete(id uuid.UUID) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Delete", id)
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUserRepositoryMockRecorder) Delete(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepository)(nil).Delete), id)
+}
+
 // FirstOrErr mocks base method.
 func (m *MockUserRepository) FirstOrErr(ctx context.Context, filter *repositories.UserFilter) (*repositories.User, error) {
 	m.ctrl.T.Helper()
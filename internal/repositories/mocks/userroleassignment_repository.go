@@ -14,6 +14,7 @@ import (
 	repositories "github.com/The127/Keyline/internal/repositories"
 	reflect "reflect"
 
+	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,6 +42,18 @@ func (m *MockUserRoleAssignmentRepository) EXPECT() *MockUserRoleAssignmentRepos
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockUserRoleAssignmentRepository) Delete(id uuid.UUID) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Delete", id)
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUserRoleAssignmentRepositoryMockRecorder) Delete(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRoleAssignmentRepository)(nil).Delete), id)
+}
+
 // Insert mocks base method.
 func (m *MockUserRoleAssignmentRepository) Insert(userRoleAssignment *repositories.UserRoleAssignment) {
 	m.ctrl.T.Helper()
@@ -3,7 +3,9 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"github.com/The127/Keyline/internal/change"
 	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -11,7 +13,37 @@ import (
 type OutboxMessageType string
 
 const (
-	SendMailOutboxMessageType OutboxMessageType = "send_mail"
+	SendMailOutboxMessageType      OutboxMessageType = "send_mail"
+	ScimProvisionOutboxMessageType OutboxMessageType = "scim_provision"
+	SendSmsOutboxMessageType       OutboxMessageType = "send_sms"
+)
+
+// MaxOutboxMessageAttempts is the number of failed deliveries after which a
+// SCIM provisioning message is dead lettered.
+const MaxOutboxMessageAttempts = 10
+
+// MaxAttempts returns the number of failed deliveries after which messages
+// of the type are dead lettered, zero means they are retried forever.
+func (t OutboxMessageType) MaxAttempts() int {
+	switch t {
+	case ScimProvisionOutboxMessageType:
+		return MaxOutboxMessageAttempts
+
+	default:
+		return 0
+	}
+}
+
+const (
+	outboxRetryBaseDelay = 30 * time.Second
+	outboxRetryMaxDelay  = time.Hour
+)
+
+type OutboxMessageChange int
+
+const (
+	OutboxMessageChangeAttempts OutboxMessageChange = iota
+	OutboxMessageChangeDeadLetteredAt
 )
 
 type OutboxMessageDetails interface {
@@ -21,9 +53,18 @@ type OutboxMessageDetails interface {
 
 type OutboxMessage struct {
 	BaseModel
+	change.List[OutboxMessageChange]
 
 	_type   OutboxMessageType
 	details []byte
+
+	attempts      int
+	nextAttemptAt *time.Time
+	lastError     *string
+
+	// deadLetteredAt is set once the message was given up, it is kept
+	// for inspection but no longer delivered
+	deadLetteredAt *time.Time
 }
 
 func (m *OutboxMessage) Type() OutboxMessageType {
@@ -34,6 +75,53 @@ func (m *OutboxMessage) Details() []byte {
 	return m.details
 }
 
+func (m *OutboxMessage) Attempts() int {
+	return m.attempts
+}
+
+func (m *OutboxMessage) NextAttemptAt() *time.Time {
+	return m.nextAttemptAt
+}
+
+func (m *OutboxMessage) LastError() *string {
+	return m.lastError
+}
+
+// RecordFailure counts a failed delivery and schedules the next attempt with
+// an exponential backoff.
+func (m *OutboxMessage) RecordFailure(err error, now time.Time) {
+	m.attempts++
+
+	delay := outboxRetryBaseDelay << (m.attempts - 1)
+	if delay <= 0 || delay > outboxRetryMaxDelay {
+		delay = outboxRetryMaxDelay
+	}
+	nextAttemptAt := now.Add(delay)
+	m.nextAttemptAt = &nextAttemptAt
+
+	lastError := err.Error()
+	m.lastError = &lastError
+
+	m.TrackChange(OutboxMessageChangeAttempts)
+}
+
+func (m *OutboxMessage) DeadLetteredAt() *time.Time {
+	return m.deadLetteredAt
+}
+
+// Exhausted reports whether the message has failed too often to be retried.
+func (m *OutboxMessage) Exhausted() bool {
+	maxAttempts := m._type.MaxAttempts()
+	return maxAttempts > 0 && m.attempts >= maxAttempts
+}
+
+// DeadLetter gives up on the message, it is no longer due for delivery.
+func (m *OutboxMessage) DeadLetter(now time.Time) {
+	m.deadLetteredAt = &now
+	m.nextAttemptAt = nil
+	m.TrackChange(OutboxMessageChangeDeadLetteredAt)
+}
+
 func NewOutboxMessage(details OutboxMessageDetails) (*OutboxMessage, error) {
 	serializedDetails, err := details.Serialize()
 	if err != nil {
@@ -42,21 +130,28 @@ func NewOutboxMessage(details OutboxMessageDetails) (*OutboxMessage, error) {
 
 	return &OutboxMessage{
 		BaseModel: NewBaseModel(),
+		List:      change.NewChanges[OutboxMessageChange](),
 		_type:     details.OutboxMessageType(),
 		details:   serializedDetails,
 	}, nil
 }
 
-func NewOutboxMessageFromDB(base BaseModel, _type OutboxMessageType, details []byte) *OutboxMessage {
+func NewOutboxMessageFromDB(base BaseModel, _type OutboxMessageType, details []byte, attempts int, nextAttemptAt *time.Time, lastError *string, deadLetteredAt *time.Time) *OutboxMessage {
 	return &OutboxMessage{
-		BaseModel: base,
-		_type:     _type,
-		details:   details,
+		BaseModel:      base,
+		List:           change.NewChanges[OutboxMessageChange](),
+		_type:          _type,
+		details:        details,
+		attempts:       attempts,
+		nextAttemptAt:  nextAttemptAt,
+		lastError:      lastError,
+		deadLetteredAt: deadLetteredAt,
 	}
 }
 
 type OutboxMessageFilter struct {
-	id *uuid.UUID
+	id    *uuid.UUID
+	dueAt *time.Time
 }
 
 func NewOutboxMessageFilter() *OutboxMessageFilter {
@@ -82,9 +177,26 @@ func (f *OutboxMessageFilter) GetId() uuid.UUID {
 	return utils.ZeroIfNil(f.id)
 }
 
+// DueAt restricts the filter to messages that are not dead lettered and
+// whose next attempt is not scheduled after the given time.
+func (f *OutboxMessageFilter) DueAt(dueAt time.Time) *OutboxMessageFilter {
+	filter := f.Clone()
+	filter.dueAt = &dueAt
+	return filter
+}
+
+func (f *OutboxMessageFilter) HasDueAt() bool {
+	return f.dueAt != nil
+}
+
+func (f *OutboxMessageFilter) GetDueAt() time.Time {
+	return utils.ZeroIfNil(f.dueAt)
+}
+
 //go:generate mockgen -destination=./mocks/outboxmessage_repository.go -package=mocks Keyline/internal/repositories OutboxMessageRepository
 type OutboxMessageRepository interface {
 	List(ctx context.Context, filter *OutboxMessageFilter) ([]*OutboxMessage, error)
 	Insert(outboxMessage *OutboxMessage)
+	Update(outboxMessage *OutboxMessage)
 	Delete(id uuid.UUID)
 }
@@ -18,6 +18,9 @@ const (
 	PasswordRuleTypeUpperCase PasswordRuleType = "upper_case"
 	PasswordRuleTypeDigits    PasswordRuleType = "digits"
 	PasswordRuleTypeSpecial   PasswordRuleType = "special"
+	PasswordRuleTypeHistory   PasswordRuleType = "history"
+	PasswordRuleTypeMaxAge    PasswordRuleType = "max_age"
+	PasswordRuleTypeBreached  PasswordRuleType = "breached"
 )
 
 type PasswordRuleChange int
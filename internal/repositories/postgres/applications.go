@@ -32,6 +32,11 @@ type postgresApplication struct {
 	accessTokenHeaderType  string
 	deviceFlowEnabled      bool
 	signingAlgorithm       sql.NullString
+	samlEntityId           sql.NullString
+	samlAcsUrl             sql.NullString
+	samlSloUrl             sql.NullString
+	samlCertificate        sql.NullString
+	acrLevels              repositories.AcrLevels
 }
 
 func mapApplication(a *repositories.Application) *postgresApplication {
@@ -50,6 +55,11 @@ func mapApplication(a *repositories.Application) *postgresApplication {
 		accessTokenHeaderType:  a.AccessTokenHeaderType(),
 		deviceFlowEnabled:      a.DeviceFlowEnabled(),
 		signingAlgorithm:       pghelpers.WrapStringPointer(utils.MapPtr(a.SigningAlgorithm(), func(alg config.SigningAlgorithm) string { return string(alg) })),
+		samlEntityId:           pghelpers.WrapStringPointer(a.SamlEntityId()),
+		samlAcsUrl:             pghelpers.WrapStringPointer(a.SamlAcsUrl()),
+		samlSloUrl:             pghelpers.WrapStringPointer(a.SamlSloUrl()),
+		samlCertificate:        pghelpers.WrapStringPointer(a.SamlCertificate()),
+		acrLevels:              a.AcrLevels(),
 	}
 }
 
@@ -69,6 +79,11 @@ func (a *postgresApplication) Map() *repositories.Application {
 		a.accessTokenHeaderType,
 		a.deviceFlowEnabled,
 		utils.MapPtr(pghelpers.UnwrapNullString(a.signingAlgorithm), func(s string) config.SigningAlgorithm { return config.SigningAlgorithm(s) }),
+		pghelpers.UnwrapNullString(a.samlEntityId),
+		pghelpers.UnwrapNullString(a.samlAcsUrl),
+		pghelpers.UnwrapNullString(a.samlSloUrl),
+		pghelpers.UnwrapNullString(a.samlCertificate),
+		a.acrLevels,
 	)
 }
 
@@ -92,6 +107,11 @@ func (a *postgresApplication) scan(row pghelpers.Row, additionalPtrs ...any) err
 		&a.accessTokenHeaderType,
 		&a.deviceFlowEnabled,
 		&a.signingAlgorithm,
+		&a.samlEntityId,
+		&a.samlAcsUrl,
+		&a.samlSloUrl,
+		&a.samlCertificate,
+		&a.acrLevels,
 	}
 
 	ptrs = append(ptrs, additionalPtrs...)
@@ -132,6 +152,11 @@ func (r *ApplicationRepository) selectQuery(filter *repositories.ApplicationFilt
 		"access_token_header_type",
 		"device_flow_enabled",
 		"signing_algorithm",
+		"saml_entity_id",
+		"saml_acs_url",
+		"saml_slo_url",
+		"saml_certificate",
+		"acr_levels",
 	).From("applications")
 
 	if filter.HasName() {
@@ -154,6 +179,10 @@ func (r *ApplicationRepository) selectQuery(filter *repositories.ApplicationFilt
 		s.Where(s.Equal("project_id", filter.GetProjectId()))
 	}
 
+	if filter.HasSamlEntityId() {
+		s.Where(s.Equal("saml_entity_id", filter.GetSamlEntityId()))
+	}
+
 	if filter.HasSearch() {
 		term := filter.GetSearch().Term()
 		s.Where(s.Or(
@@ -257,6 +286,11 @@ func (r *ApplicationRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, a
 			"access_token_header_type",
 			"device_flow_enabled",
 			"signing_algorithm",
+			"saml_entity_id",
+			"saml_acs_url",
+			"saml_slo_url",
+			"saml_certificate",
+			"acr_levels",
 		).
 		Values(
 			mapped.id,
@@ -275,6 +309,11 @@ func (r *ApplicationRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, a
 			mapped.accessTokenHeaderType,
 			mapped.deviceFlowEnabled,
 			mapped.signingAlgorithm,
+			mapped.samlEntityId,
+			mapped.samlAcsUrl,
+			mapped.samlSloUrl,
+			mapped.samlCertificate,
+			mapped.acrLevels,
 		).
 		Returning("xmin")
 
@@ -337,6 +376,21 @@ func (r *ApplicationRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, a
 		case repositories.ApplicationChangeSigningAlgorithm:
 			s.SetMore(s.Assign("signing_algorithm", mapped.signingAlgorithm))
 
+		case repositories.ApplicationChangeSamlEntityId:
+			s.SetMore(s.Assign("saml_entity_id", mapped.samlEntityId))
+
+		case repositories.ApplicationChangeSamlAcsUrl:
+			s.SetMore(s.Assign("saml_acs_url", mapped.samlAcsUrl))
+
+		case repositories.ApplicationChangeSamlSloUrl:
+			s.SetMore(s.Assign("saml_slo_url", mapped.samlSloUrl))
+
+		case repositories.ApplicationChangeSamlCertificate:
+			s.SetMore(s.Assign("saml_certificate", mapped.samlCertificate))
+
+		case repositories.ApplicationChangeAcrLevels:
+			s.SetMore(s.Assign("acr_levels", mapped.acrLevels))
+
 		default:
 			return fmt.Errorf("updating field %v is not supported", field)
 		}
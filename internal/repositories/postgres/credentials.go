@@ -74,6 +74,54 @@ func (c *postgresCredential) Map() (*repositories.Credential, error) {
 		}
 		details = &webauthn
 
+	case repositories.CredentialTypeIdentityProvider:
+		var identityProvider repositories.CredentialIdentityProviderDetails
+		err := json.Unmarshal(c.details, &identityProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal identity provider details: %w", err)
+		}
+		details = &identityProvider
+
+	case repositories.CredentialTypeLdap:
+		var ldap repositories.CredentialLdapDetails
+		err := json.Unmarshal(c.details, &ldap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ldap details: %w", err)
+		}
+		details = &ldap
+
+	case repositories.CredentialTypePhone:
+		var phone repositories.CredentialPhoneDetails
+		err := json.Unmarshal(c.details, &phone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal phone details: %w", err)
+		}
+		details = &phone
+
+	case repositories.CredentialTypeRecoveryCode:
+		var recoveryCode repositories.CredentialRecoveryCodeDetails
+		err := json.Unmarshal(c.details, &recoveryCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal recovery code details: %w", err)
+		}
+		details = &recoveryCode
+
+	case repositories.CredentialTypeTrustedDevice:
+		var trustedDevice repositories.CredentialTrustedDeviceDetails
+		err := json.Unmarshal(c.details, &trustedDevice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trusted device details: %w", err)
+		}
+		details = &trustedDevice
+
+	case repositories.CredentialTypeKnownDevice:
+		var knownDevice repositories.CredentialKnownDeviceDetails
+		err := json.Unmarshal(c.details, &knownDevice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal known device details: %w", err)
+		}
+		details = &knownDevice
+
 	default:
 		return nil, fmt.Errorf("unsupported credential type: %s", c.type_)
 	}
@@ -151,6 +199,14 @@ func (r *CredentialRepository) selectQuery(filter *repositories.CredentialFilter
 		s.Where(s.Equal("details->>'kid'", filter.GetDetailKid()))
 	}
 
+	if filter.HasDetailIdentityProviderId() {
+		s.Where(s.Equal("details->>'identityProviderId'", filter.GetDetailIdentityProviderId().String()))
+	}
+
+	if filter.HasDetailLdapProviderId() {
+		s.Where(s.Equal("details->>'ldapProviderId'", filter.GetDetailLdapProviderId().String()))
+	}
+
 	return s
 }
 
@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type postgresGroupMember struct {
+	postgresBaseModel
+	groupId uuid.UUID
+	userId  uuid.UUID
+}
+
+func mapGroupMember(groupMember *repositories.GroupMember) *postgresGroupMember {
+	return &postgresGroupMember{
+		postgresBaseModel: mapBase(groupMember.BaseModel),
+		groupId:           groupMember.GroupId(),
+		userId:            groupMember.UserId(),
+	}
+}
+
+func (g *postgresGroupMember) Map() *repositories.GroupMember {
+	return repositories.NewGroupMemberFromDB(
+		g.MapBase(),
+		g.groupId,
+		g.userId,
+	)
+}
+
+func (g *postgresGroupMember) scan(row pghelpers.Row) error {
+	return row.Scan(
+		&g.id,
+		&g.auditCreatedAt,
+		&g.auditUpdatedAt,
+		&g.xmin,
+		&g.groupId,
+		&g.userId,
+	)
+}
+
+type GroupMemberRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewGroupMemberRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *GroupMemberRepository {
+	return &GroupMemberRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *GroupMemberRepository) selectQuery(filter *repositories.GroupMemberFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"id",
+		"audit_created_at",
+		"audit_updated_at",
+		"xmin",
+		"group_id",
+		"user_id",
+	).From("group_members")
+
+	if filter.HasGroupId() {
+		s.Where(s.Equal("group_id", filter.GetGroupId()))
+	}
+
+	if filter.HasUserId() {
+		s.Where(s.Equal("user_id", filter.GetUserId()))
+	}
+
+	return s
+}
+
+func (r *GroupMemberRepository) FirstOrNil(ctx context.Context, filter *repositories.GroupMemberFilter) (*repositories.GroupMember, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	groupMember := &postgresGroupMember{}
+	err := groupMember.scan(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return groupMember.Map(), nil
+}
+
+func (r *GroupMemberRepository) List(ctx context.Context, filter *repositories.GroupMemberFilter) ([]*repositories.GroupMember, error) {
+	s := r.selectQuery(filter)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var result []*repositories.GroupMember
+	for rows.Next() {
+		groupMember := &postgresGroupMember{}
+		err := groupMember.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		result = append(result, groupMember.Map())
+	}
+
+	return result, nil
+}
+
+func (r *GroupMemberRepository) Insert(groupMember *repositories.GroupMember) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, groupMember))
+}
+
+func (r *GroupMemberRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, groupMember *repositories.GroupMember) error {
+	mapped := mapGroupMember(groupMember)
+
+	s := sqlbuilder.InsertInto("group_members").
+		Cols(
+			"id",
+			"audit_created_at",
+			"audit_updated_at",
+			"group_id",
+			"user_id",
+		).
+		Values(
+			mapped.id,
+			mapped.auditCreatedAt,
+			mapped.auditUpdatedAt,
+			mapped.groupId,
+			mapped.userId,
+		).
+		Returning("xmin")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	groupMember.SetVersion(xmin)
+	return nil
+}
+
+func (r *GroupMemberRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *GroupMemberRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("group_members")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
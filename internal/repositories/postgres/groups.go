@@ -93,6 +93,10 @@ func (r *GroupRepository) selectQuery(filter *repositories.GroupFilter) *sqlbuil
 		s.Where(s.Equal("virtual_server_id", filter.GetVirtualServerId()))
 	}
 
+	if filter.HasUpdatedAfter() {
+		s.Where(s.GreaterThan("audit_updated_at", filter.GetUpdatedAfter()))
+	}
+
 	if filter.HasSearch() {
 		term := filter.GetSearch().Term()
 		s.Where(s.Or(
@@ -0,0 +1,377 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
+)
+
+type postgresIdentityProvider struct {
+	postgresBaseModel
+	virtualServerId  uuid.UUID
+	name             string
+	displayName      string
+	issuer           string
+	clientId         string
+	clientSecret     string
+	scopes           pq.StringArray
+	authorizationUrl sql.NullString
+	tokenUrl         sql.NullString
+	userinfoUrl      sql.NullString
+	claimMapping     []byte
+	linkByEmail      bool
+	trustUpstreamMfa bool
+}
+
+func mapIdentityProvider(identityProvider *repositories.IdentityProvider) (*postgresIdentityProvider, error) {
+	claimMapping, err := json.Marshal(identityProvider.ClaimMapping())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal claim mapping: %w", err)
+	}
+
+	return &postgresIdentityProvider{
+		postgresBaseModel: mapBase(identityProvider.BaseModel),
+		virtualServerId:   identityProvider.VirtualServerId(),
+		name:              identityProvider.Name(),
+		displayName:       identityProvider.DisplayName(),
+		issuer:            identityProvider.Issuer(),
+		clientId:          identityProvider.ClientId(),
+		clientSecret:      identityProvider.ClientSecret(),
+		scopes:            identityProvider.Scopes(),
+		authorizationUrl:  pghelpers.WrapStringPointer(identityProvider.AuthorizationUrl()),
+		tokenUrl:          pghelpers.WrapStringPointer(identityProvider.TokenUrl()),
+		userinfoUrl:       pghelpers.WrapStringPointer(identityProvider.UserinfoUrl()),
+		claimMapping:      claimMapping,
+		linkByEmail:       identityProvider.LinkByEmail(),
+		trustUpstreamMfa:  identityProvider.TrustUpstreamMfa(),
+	}, nil
+}
+
+func (p *postgresIdentityProvider) Map() (*repositories.IdentityProvider, error) {
+	var claimMapping repositories.IdentityProviderClaimMapping
+	err := json.Unmarshal(p.claimMapping, &claimMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal claim mapping: %w", err)
+	}
+
+	return repositories.NewIdentityProviderFromDB(
+		p.MapBase(),
+		p.virtualServerId,
+		p.name,
+		p.displayName,
+		p.issuer,
+		p.clientId,
+		p.clientSecret,
+		p.scopes,
+		pghelpers.UnwrapNullString(p.authorizationUrl),
+		pghelpers.UnwrapNullString(p.tokenUrl),
+		pghelpers.UnwrapNullString(p.userinfoUrl),
+		claimMapping,
+		p.linkByEmail,
+		p.trustUpstreamMfa,
+	), nil
+}
+
+func (p *postgresIdentityProvider) scan(row pghelpers.Row, additionalPtrs ...any) error {
+	ptrs := []any{
+		&p.id,
+		&p.auditCreatedAt,
+		&p.auditUpdatedAt,
+		&p.xmin,
+		&p.virtualServerId,
+		&p.name,
+		&p.displayName,
+		&p.issuer,
+		&p.clientId,
+		&p.clientSecret,
+		&p.scopes,
+		&p.authorizationUrl,
+		&p.tokenUrl,
+		&p.userinfoUrl,
+		&p.claimMapping,
+		&p.linkByEmail,
+		&p.trustUpstreamMfa,
+	}
+
+	ptrs = append(ptrs, additionalPtrs...)
+
+	return row.Scan(ptrs...)
+}
+
+type IdentityProviderRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewIdentityProviderRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *IdentityProviderRepository {
+	return &IdentityProviderRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *IdentityProviderRepository) selectQuery(filter *repositories.IdentityProviderFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"id",
+		"audit_created_at",
+		"audit_updated_at",
+		"xmin",
+		"virtual_server_id",
+		"name",
+		"display_name",
+		"issuer",
+		"client_id",
+		"client_secret",
+		"scopes",
+		"authorization_url",
+		"token_url",
+		"userinfo_url",
+		"claim_mapping",
+		"link_by_email",
+		"trust_upstream_mfa",
+	).From("identity_providers")
+
+	if filter.HasVirtualServerId() {
+		s.Where(s.Equal("virtual_server_id", filter.GetVirtualServerId()))
+	}
+
+	if filter.HasId() {
+		s.Where(s.Equal("id", filter.GetId()))
+	}
+
+	if filter.HasName() {
+		s.Where(s.Equal("name", filter.GetName()))
+	}
+
+	return s
+}
+
+func (r *IdentityProviderRepository) List(ctx context.Context, filter *repositories.IdentityProviderFilter) ([]*repositories.IdentityProvider, error) {
+	s := r.selectQuery(filter)
+	s.OrderBy("name")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var result []*repositories.IdentityProvider
+	for rows.Next() {
+		identityProvider := &postgresIdentityProvider{}
+		err := identityProvider.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		mapped, err := identityProvider.Map()
+		if err != nil {
+			return nil, fmt.Errorf("mapping identity provider: %w", err)
+		}
+		result = append(result, mapped)
+	}
+
+	return result, nil
+}
+
+func (r *IdentityProviderRepository) FirstOrNil(ctx context.Context, filter *repositories.IdentityProviderFilter) (*repositories.IdentityProvider, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	identityProvider := &postgresIdentityProvider{}
+	err := identityProvider.scan(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return identityProvider.Map()
+}
+
+func (r *IdentityProviderRepository) FirstOrErr(ctx context.Context, filter *repositories.IdentityProviderFilter) (*repositories.IdentityProvider, error) {
+	identityProvider, err := r.FirstOrNil(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if identityProvider == nil {
+		return nil, utils.ErrIdentityProviderNotFound
+	}
+	return identityProvider, nil
+}
+
+func (r *IdentityProviderRepository) Insert(identityProvider *repositories.IdentityProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, identityProvider))
+}
+
+func (r *IdentityProviderRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, identityProvider *repositories.IdentityProvider) error {
+	mapped, err := mapIdentityProvider(identityProvider)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.InsertInto("identity_providers").
+		Cols(
+			"id",
+			"audit_created_at",
+			"audit_updated_at",
+			"virtual_server_id",
+			"name",
+			"display_name",
+			"issuer",
+			"client_id",
+			"client_secret",
+			"scopes",
+			"authorization_url",
+			"token_url",
+			"userinfo_url",
+			"claim_mapping",
+			"link_by_email",
+			"trust_upstream_mfa",
+		).
+		Values(
+			mapped.id,
+			mapped.auditCreatedAt,
+			mapped.auditUpdatedAt,
+			mapped.virtualServerId,
+			mapped.name,
+			mapped.displayName,
+			mapped.issuer,
+			mapped.clientId,
+			mapped.clientSecret,
+			mapped.scopes,
+			mapped.authorizationUrl,
+			mapped.tokenUrl,
+			mapped.userinfoUrl,
+			mapped.claimMapping,
+			mapped.linkByEmail,
+			mapped.trustUpstreamMfa,
+		).
+		Returning("xmin")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	identityProvider.SetVersion(xmin)
+	identityProvider.ClearChanges()
+	return nil
+}
+
+func (r *IdentityProviderRepository) Update(identityProvider *repositories.IdentityProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, identityProvider))
+}
+
+func (r *IdentityProviderRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, identityProvider *repositories.IdentityProvider) error {
+	if !identityProvider.HasChanges() {
+		return nil
+	}
+
+	mapped, err := mapIdentityProvider(identityProvider)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.Update("identity_providers")
+	s.Where(s.Equal("id", mapped.id))
+	s.Where(s.Equal("xmin", mapped.xmin))
+
+	for _, field := range identityProvider.GetChanges() {
+		switch field {
+		case repositories.IdentityProviderChangeDisplayName:
+			s.SetMore(s.Assign("display_name", mapped.displayName))
+
+		case repositories.IdentityProviderChangeIssuer:
+			s.SetMore(s.Assign("issuer", mapped.issuer))
+
+		case repositories.IdentityProviderChangeClientId:
+			s.SetMore(s.Assign("client_id", mapped.clientId))
+
+		case repositories.IdentityProviderChangeClientSecret:
+			s.SetMore(s.Assign("client_secret", mapped.clientSecret))
+
+		case repositories.IdentityProviderChangeScopes:
+			s.SetMore(s.Assign("scopes", mapped.scopes))
+
+		case repositories.IdentityProviderChangeAuthorizationUrl:
+			s.SetMore(s.Assign("authorization_url", mapped.authorizationUrl))
+
+		case repositories.IdentityProviderChangeTokenUrl:
+			s.SetMore(s.Assign("token_url", mapped.tokenUrl))
+
+		case repositories.IdentityProviderChangeUserinfoUrl:
+			s.SetMore(s.Assign("userinfo_url", mapped.userinfoUrl))
+
+		case repositories.IdentityProviderChangeClaimMapping:
+			s.SetMore(s.Assign("claim_mapping", mapped.claimMapping))
+
+		case repositories.IdentityProviderChangeLinkByEmail:
+			s.SetMore(s.Assign("link_by_email", mapped.linkByEmail))
+
+		case repositories.IdentityProviderChangeTrustUpstreamMfa:
+			s.SetMore(s.Assign("trust_upstream_mfa", mapped.trustUpstreamMfa))
+
+		default:
+			return fmt.Errorf("updating field %v is not supported", field)
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	identityProvider.SetVersion(xmin)
+	identityProvider.ClearChanges()
+	return nil
+}
+
+func (r *IdentityProviderRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *IdentityProviderRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("identity_providers")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
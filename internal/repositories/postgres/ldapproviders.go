@@ -0,0 +1,394 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type postgresLdapProvider struct {
+	postgresBaseModel
+	virtualServerId     uuid.UUID
+	name                string
+	vendor              string
+	connectionUrl       string
+	startTls            bool
+	bindDn              string
+	bindPassword        string
+	usersDn             string
+	userFilter          string
+	groupsDn            sql.NullString
+	groupFilter         string
+	attributeMapping    []byte
+	editMode            string
+	syncIntervalSeconds int64
+	lastSyncAt          *time.Time
+}
+
+func mapLdapProvider(ldapProvider *repositories.LdapProvider) (*postgresLdapProvider, error) {
+	attributeMapping, err := json.Marshal(ldapProvider.AttributeMapping())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attribute mapping: %w", err)
+	}
+
+	return &postgresLdapProvider{
+		postgresBaseModel:   mapBase(ldapProvider.BaseModel),
+		virtualServerId:     ldapProvider.VirtualServerId(),
+		name:                ldapProvider.Name(),
+		vendor:              string(ldapProvider.Vendor()),
+		connectionUrl:       ldapProvider.ConnectionUrl(),
+		startTls:            ldapProvider.StartTls(),
+		bindDn:              ldapProvider.BindDn(),
+		bindPassword:        ldapProvider.BindPassword(),
+		usersDn:             ldapProvider.UsersDn(),
+		userFilter:          ldapProvider.UserFilter(),
+		groupsDn:            pghelpers.WrapStringPointer(ldapProvider.GroupsDn()),
+		groupFilter:         ldapProvider.GroupFilter(),
+		attributeMapping:    attributeMapping,
+		editMode:            string(ldapProvider.EditMode()),
+		syncIntervalSeconds: int64(ldapProvider.SyncInterval() / time.Second),
+		lastSyncAt:          ldapProvider.LastSyncAt(),
+	}, nil
+}
+
+func (p *postgresLdapProvider) Map() (*repositories.LdapProvider, error) {
+	var attributeMapping repositories.LdapAttributeMapping
+	err := json.Unmarshal(p.attributeMapping, &attributeMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attribute mapping: %w", err)
+	}
+
+	return repositories.NewLdapProviderFromDB(
+		p.MapBase(),
+		p.virtualServerId,
+		p.name,
+		repositories.LdapVendor(p.vendor),
+		p.connectionUrl,
+		p.startTls,
+		p.bindDn,
+		p.bindPassword,
+		p.usersDn,
+		p.userFilter,
+		pghelpers.UnwrapNullString(p.groupsDn),
+		p.groupFilter,
+		attributeMapping,
+		repositories.LdapEditMode(p.editMode),
+		time.Duration(p.syncIntervalSeconds)*time.Second,
+		p.lastSyncAt,
+	), nil
+}
+
+func (p *postgresLdapProvider) scan(row pghelpers.Row, additionalPtrs ...any) error {
+	ptrs := []any{
+		&p.id,
+		&p.auditCreatedAt,
+		&p.auditUpdatedAt,
+		&p.xmin,
+		&p.virtualServerId,
+		&p.name,
+		&p.vendor,
+		&p.connectionUrl,
+		&p.startTls,
+		&p.bindDn,
+		&p.bindPassword,
+		&p.usersDn,
+		&p.userFilter,
+		&p.groupsDn,
+		&p.groupFilter,
+		&p.attributeMapping,
+		&p.editMode,
+		&p.syncIntervalSeconds,
+		&p.lastSyncAt,
+	}
+
+	ptrs = append(ptrs, additionalPtrs...)
+
+	return row.Scan(ptrs...)
+}
+
+type LdapProviderRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewLdapProviderRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *LdapProviderRepository {
+	return &LdapProviderRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *LdapProviderRepository) selectQuery(filter *repositories.LdapProviderFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"id",
+		"audit_created_at",
+		"audit_updated_at",
+		"xmin",
+		"virtual_server_id",
+		"name",
+		"vendor",
+		"connection_url",
+		"start_tls",
+		"bind_dn",
+		"bind_password",
+		"users_dn",
+		"user_filter",
+		"groups_dn",
+		"group_filter",
+		"attribute_mapping",
+		"edit_mode",
+		"sync_interval_seconds",
+		"last_sync_at",
+	).From("ldap_providers")
+
+	if filter.HasVirtualServerId() {
+		s.Where(s.Equal("virtual_server_id", filter.GetVirtualServerId()))
+	}
+
+	if filter.HasId() {
+		s.Where(s.Equal("id", filter.GetId()))
+	}
+
+	if filter.HasName() {
+		s.Where(s.Equal("name", filter.GetName()))
+	}
+
+	return s
+}
+
+func (r *LdapProviderRepository) List(ctx context.Context, filter *repositories.LdapProviderFilter) ([]*repositories.LdapProvider, error) {
+	s := r.selectQuery(filter)
+	s.OrderBy("name")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var result []*repositories.LdapProvider
+	for rows.Next() {
+		ldapProvider := &postgresLdapProvider{}
+		err := ldapProvider.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		mapped, err := ldapProvider.Map()
+		if err != nil {
+			return nil, fmt.Errorf("mapping ldap provider: %w", err)
+		}
+		result = append(result, mapped)
+	}
+
+	return result, nil
+}
+
+func (r *LdapProviderRepository) FirstOrNil(ctx context.Context, filter *repositories.LdapProviderFilter) (*repositories.LdapProvider, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	ldapProvider := &postgresLdapProvider{}
+	err := ldapProvider.scan(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return ldapProvider.Map()
+}
+
+func (r *LdapProviderRepository) FirstOrErr(ctx context.Context, filter *repositories.LdapProviderFilter) (*repositories.LdapProvider, error) {
+	ldapProvider, err := r.FirstOrNil(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if ldapProvider == nil {
+		return nil, utils.ErrLdapProviderNotFound
+	}
+	return ldapProvider, nil
+}
+
+func (r *LdapProviderRepository) Insert(ldapProvider *repositories.LdapProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, ldapProvider))
+}
+
+func (r *LdapProviderRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, ldapProvider *repositories.LdapProvider) error {
+	mapped, err := mapLdapProvider(ldapProvider)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.InsertInto("ldap_providers").
+		Cols(
+			"id",
+			"audit_created_at",
+			"audit_updated_at",
+			"virtual_server_id",
+			"name",
+			"vendor",
+			"connection_url",
+			"start_tls",
+			"bind_dn",
+			"bind_password",
+			"users_dn",
+			"user_filter",
+			"groups_dn",
+			"group_filter",
+			"attribute_mapping",
+			"edit_mode",
+			"sync_interval_seconds",
+			"last_sync_at",
+		).
+		Values(
+			mapped.id,
+			mapped.auditCreatedAt,
+			mapped.auditUpdatedAt,
+			mapped.virtualServerId,
+			mapped.name,
+			mapped.vendor,
+			mapped.connectionUrl,
+			mapped.startTls,
+			mapped.bindDn,
+			mapped.bindPassword,
+			mapped.usersDn,
+			mapped.userFilter,
+			mapped.groupsDn,
+			mapped.groupFilter,
+			mapped.attributeMapping,
+			mapped.editMode,
+			mapped.syncIntervalSeconds,
+			mapped.lastSyncAt,
+		).
+		Returning("xmin")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	ldapProvider.SetVersion(xmin)
+	ldapProvider.ClearChanges()
+	return nil
+}
+
+func (r *LdapProviderRepository) Update(ldapProvider *repositories.LdapProvider) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, ldapProvider))
+}
+
+func (r *LdapProviderRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, ldapProvider *repositories.LdapProvider) error {
+	if !ldapProvider.HasChanges() {
+		return nil
+	}
+
+	mapped, err := mapLdapProvider(ldapProvider)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.Update("ldap_providers")
+	s.Where(s.Equal("id", mapped.id))
+	s.Where(s.Equal("xmin", mapped.xmin))
+
+	for _, field := range ldapProvider.GetChanges() {
+		switch field {
+		case repositories.LdapProviderChangeConnectionUrl:
+			s.SetMore(s.Assign("connection_url", mapped.connectionUrl))
+
+		case repositories.LdapProviderChangeStartTls:
+			s.SetMore(s.Assign("start_tls", mapped.startTls))
+
+		case repositories.LdapProviderChangeBindDn:
+			s.SetMore(s.Assign("bind_dn", mapped.bindDn))
+
+		case repositories.LdapProviderChangeBindPassword:
+			s.SetMore(s.Assign("bind_password", mapped.bindPassword))
+
+		case repositories.LdapProviderChangeUsersDn:
+			s.SetMore(s.Assign("users_dn", mapped.usersDn))
+
+		case repositories.LdapProviderChangeUserFilter:
+			s.SetMore(s.Assign("user_filter", mapped.userFilter))
+
+		case repositories.LdapProviderChangeGroupsDn:
+			s.SetMore(s.Assign("groups_dn", mapped.groupsDn))
+
+		case repositories.LdapProviderChangeGroupFilter:
+			s.SetMore(s.Assign("group_filter", mapped.groupFilter))
+
+		case repositories.LdapProviderChangeAttributeMapping:
+			s.SetMore(s.Assign("attribute_mapping", mapped.attributeMapping))
+
+		case repositories.LdapProviderChangeEditMode:
+			s.SetMore(s.Assign("edit_mode", mapped.editMode))
+
+		case repositories.LdapProviderChangeSyncInterval:
+			s.SetMore(s.Assign("sync_interval_seconds", mapped.syncIntervalSeconds))
+
+		case repositories.LdapProviderChangeLastSyncAt:
+			s.SetMore(s.Assign("last_sync_at", mapped.lastSyncAt))
+
+		default:
+			return fmt.Errorf("updating field %v is not supported", field)
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	ldapProvider.SetVersion(xmin)
+	ldapProvider.ClearChanges()
+	return nil
+}
+
+func (r *LdapProviderRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *LdapProviderRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("ldap_providers")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
 	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
@@ -16,8 +17,12 @@ import (
 
 type postgresOutboxMessage struct {
 	postgresBaseModel
-	type_   string
-	details []byte
+	type_          string
+	details        []byte
+	attempts       int
+	nextAttemptAt  *time.Time
+	lastError      sql.NullString
+	deadLetteredAt *time.Time
 }
 
 func mapOutboxMessage(m *repositories.OutboxMessage) *postgresOutboxMessage {
@@ -25,6 +30,10 @@ func mapOutboxMessage(m *repositories.OutboxMessage) *postgresOutboxMessage {
 		postgresBaseModel: mapBase(m.BaseModel),
 		type_:             string(m.Type()),
 		details:           m.Details(),
+		attempts:          m.Attempts(),
+		nextAttemptAt:     m.NextAttemptAt(),
+		lastError:         pghelpers.WrapStringPointer(m.LastError()),
+		deadLetteredAt:    m.DeadLetteredAt(),
 	}
 }
 
@@ -33,6 +42,10 @@ func (m *postgresOutboxMessage) Map() *repositories.OutboxMessage {
 		m.MapBase(),
 		repositories.OutboxMessageType(m.type_),
 		m.details,
+		m.attempts,
+		m.nextAttemptAt,
+		pghelpers.UnwrapNullString(m.lastError),
+		m.deadLetteredAt,
 	)
 }
 
@@ -44,6 +57,10 @@ func (m *postgresOutboxMessage) scan(row pghelpers.Row, additionalPtrs ...any) e
 		&m.xmin,
 		&m.type_,
 		&m.details,
+		&m.attempts,
+		&m.nextAttemptAt,
+		&m.lastError,
+		&m.deadLetteredAt,
 	}
 
 	ptrs = append(ptrs, additionalPtrs...)
@@ -73,17 +90,30 @@ func (r *OutboxMessageRepository) selectQuery(filter *repositories.OutboxMessage
 		"xmin",
 		"type",
 		"details",
+		"attempts",
+		"next_attempt_at",
+		"last_error",
+		"dead_lettered_at",
 	).From("outbox_messages")
 
 	if filter.HasId() {
 		s.Where(s.Equal("id", filter.GetId()))
 	}
 
+	if filter.HasDueAt() {
+		s.Where(s.IsNull("dead_lettered_at"))
+		s.Where(s.Or(
+			s.IsNull("next_attempt_at"),
+			s.LessEqualThan("next_attempt_at", filter.GetDueAt()),
+		))
+	}
+
 	return s
 }
 
 func (r *OutboxMessageRepository) List(ctx context.Context, filter *repositories.OutboxMessageFilter) ([]*repositories.OutboxMessage, error) {
 	s := r.selectQuery(filter)
+	s.OrderBy("audit_created_at")
 
 	query, args := s.Build()
 	logging.Logger.Debug("executing sql: ", query)
@@ -144,6 +174,57 @@ func (r *OutboxMessageRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx,
 	return nil
 }
 
+func (r *OutboxMessageRepository) Update(outboxMessage *repositories.OutboxMessage) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, outboxMessage))
+}
+
+func (r *OutboxMessageRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, outboxMessage *repositories.OutboxMessage) error {
+	if !outboxMessage.HasChanges() {
+		return nil
+	}
+
+	mapped := mapOutboxMessage(outboxMessage)
+
+	s := sqlbuilder.Update("outbox_messages")
+	s.Where(s.Equal("id", mapped.id))
+	s.Where(s.Equal("xmin", mapped.xmin))
+
+	for _, field := range outboxMessage.GetChanges() {
+		switch field {
+		case repositories.OutboxMessageChangeAttempts:
+			s.SetMore(
+				s.Assign("attempts", mapped.attempts),
+				s.Assign("next_attempt_at", mapped.nextAttemptAt),
+				s.Assign("last_error", mapped.lastError),
+			)
+
+		case repositories.OutboxMessageChangeDeadLetteredAt:
+			s.SetMore(
+				s.Assign("dead_lettered_at", mapped.deadLetteredAt),
+				s.Assign("next_attempt_at", mapped.nextAttemptAt),
+			)
+
+		default:
+			return fmt.Errorf("updating field %v is not supported", field)
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	outboxMessage.SetVersion(xmin)
+	outboxMessage.ClearChanges()
+	return nil
+}
+
 func (r *OutboxMessageRepository) Delete(id uuid.UUID) {
 	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
 }
@@ -0,0 +1,357 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type postgresProvisioningTarget struct {
+	postgresBaseModel
+	virtualServerId  uuid.UUID
+	applicationId    uuid.UUID
+	url              string
+	authType         string
+	authUsername     sql.NullString
+	authSecret       string
+	attributeMapping []byte
+	enabled          bool
+	lastSyncAt       *time.Time
+	lastError        sql.NullString
+	lastErrorAt      *time.Time
+}
+
+func mapProvisioningTarget(provisioningTarget *repositories.ProvisioningTarget) (*postgresProvisioningTarget, error) {
+	attributeMapping, err := json.Marshal(provisioningTarget.AttributeMapping())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attribute mapping: %w", err)
+	}
+
+	return &postgresProvisioningTarget{
+		postgresBaseModel: mapBase(provisioningTarget.BaseModel),
+		virtualServerId:   provisioningTarget.VirtualServerId(),
+		applicationId:     provisioningTarget.ApplicationId(),
+		url:               provisioningTarget.Url(),
+		authType:          string(provisioningTarget.AuthType()),
+		authUsername:      pghelpers.WrapStringPointer(provisioningTarget.AuthUsername()),
+		authSecret:        provisioningTarget.AuthSecret(),
+		attributeMapping:  attributeMapping,
+		enabled:           provisioningTarget.Enabled(),
+		lastSyncAt:        provisioningTarget.LastSyncAt(),
+		lastError:         pghelpers.WrapStringPointer(provisioningTarget.LastError()),
+		lastErrorAt:       provisioningTarget.LastErrorAt(),
+	}, nil
+}
+
+func (t *postgresProvisioningTarget) Map() (*repositories.ProvisioningTarget, error) {
+	var attributeMapping repositories.ProvisioningAttributeMapping
+	err := json.Unmarshal(t.attributeMapping, &attributeMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attribute mapping: %w", err)
+	}
+
+	return repositories.NewProvisioningTargetFromDB(
+		t.MapBase(),
+		t.virtualServerId,
+		t.applicationId,
+		t.url,
+		repositories.ProvisioningAuthType(t.authType),
+		pghelpers.UnwrapNullString(t.authUsername),
+		t.authSecret,
+		attributeMapping,
+		t.enabled,
+		t.lastSyncAt,
+		pghelpers.UnwrapNullString(t.lastError),
+		t.lastErrorAt,
+	), nil
+}
+
+func (t *postgresProvisioningTarget) scan(row pghelpers.Row, additionalPtrs ...any) error {
+	ptrs := []any{
+		&t.id,
+		&t.auditCreatedAt,
+		&t.auditUpdatedAt,
+		&t.xmin,
+		&t.virtualServerId,
+		&t.applicationId,
+		&t.url,
+		&t.authType,
+		&t.authUsername,
+		&t.authSecret,
+		&t.attributeMapping,
+		&t.enabled,
+		&t.lastSyncAt,
+		&t.lastError,
+		&t.lastErrorAt,
+	}
+
+	ptrs = append(ptrs, additionalPtrs...)
+
+	return row.Scan(ptrs...)
+}
+
+type ProvisioningTargetRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewProvisioningTargetRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *ProvisioningTargetRepository {
+	return &ProvisioningTargetRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *ProvisioningTargetRepository) selectQuery(filter *repositories.ProvisioningTargetFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"id",
+		"audit_created_at",
+		"audit_updated_at",
+		"xmin",
+		"virtual_server_id",
+		"application_id",
+		"url",
+		"auth_type",
+		"auth_username",
+		"auth_secret",
+		"attribute_mapping",
+		"enabled",
+		"last_sync_at",
+		"last_error",
+		"last_error_at",
+	).From("provisioning_targets")
+
+	if filter.HasVirtualServerId() {
+		s.Where(s.Equal("virtual_server_id", filter.GetVirtualServerId()))
+	}
+
+	if filter.HasApplicationId() {
+		s.Where(s.Equal("application_id", filter.GetApplicationId()))
+	}
+
+	if filter.HasId() {
+		s.Where(s.Equal("id", filter.GetId()))
+	}
+
+	if filter.HasEnabled() {
+		s.Where(s.Equal("enabled", filter.GetEnabled()))
+	}
+
+	return s
+}
+
+func (r *ProvisioningTargetRepository) List(ctx context.Context, filter *repositories.ProvisioningTargetFilter) ([]*repositories.ProvisioningTarget, error) {
+	s := r.selectQuery(filter)
+	s.OrderBy("audit_created_at")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var result []*repositories.ProvisioningTarget
+	for rows.Next() {
+		provisioningTarget := &postgresProvisioningTarget{}
+		err := provisioningTarget.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		mapped, err := provisioningTarget.Map()
+		if err != nil {
+			return nil, fmt.Errorf("mapping provisioning target: %w", err)
+		}
+		result = append(result, mapped)
+	}
+
+	return result, nil
+}
+
+func (r *ProvisioningTargetRepository) FirstOrNil(ctx context.Context, filter *repositories.ProvisioningTargetFilter) (*repositories.ProvisioningTarget, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	provisioningTarget := &postgresProvisioningTarget{}
+	err := provisioningTarget.scan(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return provisioningTarget.Map()
+}
+
+func (r *ProvisioningTargetRepository) FirstOrErr(ctx context.Context, filter *repositories.ProvisioningTargetFilter) (*repositories.ProvisioningTarget, error) {
+	provisioningTarget, err := r.FirstOrNil(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if provisioningTarget == nil {
+		return nil, utils.ErrProvisioningTargetNotFound
+	}
+	return provisioningTarget, nil
+}
+
+func (r *ProvisioningTargetRepository) Insert(provisioningTarget *repositories.ProvisioningTarget) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, provisioningTarget))
+}
+
+func (r *ProvisioningTargetRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, provisioningTarget *repositories.ProvisioningTarget) error {
+	mapped, err := mapProvisioningTarget(provisioningTarget)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.InsertInto("provisioning_targets").
+		Cols(
+			"id",
+			"audit_created_at",
+			"audit_updated_at",
+			"virtual_server_id",
+			"application_id",
+			"url",
+			"auth_type",
+			"auth_username",
+			"auth_secret",
+			"attribute_mapping",
+			"enabled",
+			"last_sync_at",
+			"last_error",
+			"last_error_at",
+		).
+		Values(
+			mapped.id,
+			mapped.auditCreatedAt,
+			mapped.auditUpdatedAt,
+			mapped.virtualServerId,
+			mapped.applicationId,
+			mapped.url,
+			mapped.authType,
+			mapped.authUsername,
+			mapped.authSecret,
+			mapped.attributeMapping,
+			mapped.enabled,
+			mapped.lastSyncAt,
+			mapped.lastError,
+			mapped.lastErrorAt,
+		).
+		Returning("xmin")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	provisioningTarget.SetVersion(xmin)
+	provisioningTarget.ClearChanges()
+	return nil
+}
+
+func (r *ProvisioningTargetRepository) Update(provisioningTarget *repositories.ProvisioningTarget) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, provisioningTarget))
+}
+
+func (r *ProvisioningTargetRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, provisioningTarget *repositories.ProvisioningTarget) error {
+	if !provisioningTarget.HasChanges() {
+		return nil
+	}
+
+	mapped, err := mapProvisioningTarget(provisioningTarget)
+	if err != nil {
+		return err
+	}
+
+	s := sqlbuilder.Update("provisioning_targets")
+	s.Where(s.Equal("id", mapped.id))
+	s.Where(s.Equal("xmin", mapped.xmin))
+
+	for _, field := range provisioningTarget.GetChanges() {
+		switch field {
+		case repositories.ProvisioningTargetChangeUrl:
+			s.SetMore(s.Assign("url", mapped.url))
+
+		case repositories.ProvisioningTargetChangeAuth:
+			s.SetMore(
+				s.Assign("auth_type", mapped.authType),
+				s.Assign("auth_username", mapped.authUsername),
+				s.Assign("auth_secret", mapped.authSecret),
+			)
+
+		case repositories.ProvisioningTargetChangeAttributeMapping:
+			s.SetMore(s.Assign("attribute_mapping", mapped.attributeMapping))
+
+		case repositories.ProvisioningTargetChangeEnabled:
+			s.SetMore(s.Assign("enabled", mapped.enabled))
+
+		case repositories.ProvisioningTargetChangeStatus:
+			s.SetMore(
+				s.Assign("last_sync_at", mapped.lastSyncAt),
+				s.Assign("last_error", mapped.lastError),
+				s.Assign("last_error_at", mapped.lastErrorAt),
+			)
+
+		default:
+			return fmt.Errorf("updating field %v is not supported", field)
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err = row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	provisioningTarget.SetVersion(xmin)
+	provisioningTarget.ClearChanges()
+	return nil
+}
+
+func (r *ProvisioningTargetRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *ProvisioningTargetRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("provisioning_targets")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
 )
 
 type postgresSession struct {
@@ -23,9 +24,16 @@ type postgresSession struct {
 	hashedToken     string
 	expiresAt       time.Time
 	lastUsedAt      *time.Time
+
+	authenticationMethods pq.StringArray
 }
 
 func mapSession(session *repositories.Session) *postgresSession {
+	authenticationMethods := make(pq.StringArray, 0, len(session.AuthenticationMethods()))
+	for _, method := range session.AuthenticationMethods() {
+		authenticationMethods = append(authenticationMethods, string(method))
+	}
+
 	return &postgresSession{
 		postgresBaseModel: mapBase(session.BaseModel),
 		virtualServerId:   session.VirtualServerId(),
@@ -33,6 +41,8 @@ func mapSession(session *repositories.Session) *postgresSession {
 		hashedToken:       session.HashedToken(),
 		expiresAt:         session.ExpiresAt(),
 		lastUsedAt:        session.LastUsedAt(),
+
+		authenticationMethods: authenticationMethods,
 	}
 }
 
@@ -44,6 +54,7 @@ func (s *postgresSession) Map() *repositories.Session {
 		s.hashedToken,
 		s.expiresAt,
 		s.lastUsedAt,
+		utils.MapSlice(s.authenticationMethods, func(m string) repositories.AuthenticationMethod { return repositories.AuthenticationMethod(m) }),
 	)
 }
 
@@ -58,6 +69,7 @@ func (s *postgresSession) scan(row pghelpers.Row, additionalPtrs ...any) error {
 		&s.hashedToken,
 		&s.expiresAt,
 		&s.lastUsedAt,
+		&s.authenticationMethods,
 	}
 
 	ptrs = append(ptrs, additionalPtrs...)
@@ -90,6 +102,7 @@ func (r *SessionRepository) selectQuery(filter *repositories.SessionFilter) *sql
 		"hashed_token",
 		"expires_at",
 		"last_used_at",
+		"authentication_methods",
 	).From("sessions")
 
 	if filter.HasId() {
@@ -139,6 +152,31 @@ func (r *SessionRepository) FirstOrNil(ctx context.Context, filter *repositories
 	return session.Map(), nil
 }
 
+func (r *SessionRepository) List(ctx context.Context, filter *repositories.SessionFilter) ([]*repositories.Session, error) {
+	s := r.selectQuery(filter)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var sessions []*repositories.Session
+	for rows.Next() {
+		session := &postgresSession{}
+		err := session.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		sessions = append(sessions, session.Map())
+	}
+
+	return sessions, nil
+}
+
 func (r *SessionRepository) Insert(session *repositories.Session) {
 	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, session))
 }
@@ -156,6 +194,7 @@ func (r *SessionRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, sessi
 			"hashed_token",
 			"expires_at",
 			"last_used_at",
+			"authentication_methods",
 		).
 		Values(
 			mapped.id,
@@ -166,6 +205,7 @@ func (r *SessionRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, sessi
 			mapped.hashedToken,
 			mapped.expiresAt,
 			mapped.lastUsedAt,
+			mapped.authenticationMethods,
 		).
 		Returning("xmin")
 
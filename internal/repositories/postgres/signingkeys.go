@@ -0,0 +1,313 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/internal/logging"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type postgresSigningKey struct {
+	postgresBaseModel
+	virtualServerName   string
+	algorithm           string
+	kid                 string
+	encryptedPrivateKey []byte
+	wrappedDataKey      []byte
+	masterKeyId         string
+	createdAt           time.Time
+	activatesAt         time.Time
+	rotatesAt           time.Time
+	expiresAt           time.Time
+	verifyOnly          bool
+}
+
+func mapSigningKey(signingKey *repositories.SigningKey) *postgresSigningKey {
+	return &postgresSigningKey{
+		postgresBaseModel:   mapBase(signingKey.BaseModel),
+		virtualServerName:   signingKey.VirtualServerName(),
+		algorithm:           string(signingKey.Algorithm()),
+		kid:                 signingKey.Kid(),
+		encryptedPrivateKey: signingKey.EncryptedPrivateKey(),
+		wrappedDataKey:      signingKey.WrappedDataKey(),
+		masterKeyId:         signingKey.MasterKeyId(),
+		createdAt:           signingKey.CreatedAt(),
+		activatesAt:         signingKey.ActivatesAt(),
+		rotatesAt:           signingKey.RotatesAt(),
+		expiresAt:           signingKey.ExpiresAt(),
+		verifyOnly:          signingKey.VerifyOnly(),
+	}
+}
+
+func (k *postgresSigningKey) Map() *repositories.SigningKey {
+	return repositories.NewSigningKeyFromDB(
+		k.MapBase(),
+		k.virtualServerName,
+		config.SigningAlgorithm(k.algorithm),
+		k.kid,
+		k.encryptedPrivateKey,
+		k.wrappedDataKey,
+		k.masterKeyId,
+		k.createdAt,
+		k.activatesAt,
+		k.rotatesAt,
+		k.expiresAt,
+		k.verifyOnly,
+	)
+}
+
+func (k *postgresSigningKey) scan(row pghelpers.Row, additionalPtrs ...any) error {
+	ptrs := []any{
+		&k.id,
+		&k.auditCreatedAt,
+		&k.auditUpdatedAt,
+		&k.xmin,
+		&k.virtualServerName,
+		&k.algorithm,
+		&k.kid,
+		&k.encryptedPrivateKey,
+		&k.wrappedDataKey,
+		&k.masterKeyId,
+		&k.createdAt,
+		&k.activatesAt,
+		&k.rotatesAt,
+		&k.expiresAt,
+		&k.verifyOnly,
+	}
+
+	ptrs = append(ptrs, additionalPtrs...)
+
+	return row.Scan(ptrs...)
+}
+
+type SigningKeyRepository struct {
+	db            *sql.DB
+	changeTracker *change.Tracker
+	entityType    int
+}
+
+func NewSigningKeyRepository(db *sql.DB, changeTracker *change.Tracker, entityType int) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		db:            db,
+		changeTracker: changeTracker,
+		entityType:    entityType,
+	}
+}
+
+func (r *SigningKeyRepository) selectQuery(filter *repositories.SigningKeyFilter) *sqlbuilder.SelectBuilder {
+	s := sqlbuilder.Select(
+		"id",
+		"audit_created_at",
+		"audit_updated_at",
+		"xmin",
+		"virtual_server_name",
+		"algorithm",
+		"kid",
+		"encrypted_private_key",
+		"wrapped_data_key",
+		"master_key_id",
+		"created_at",
+		"activates_at",
+		"rotates_at",
+		"expires_at",
+		"verify_only",
+	).From("signing_keys")
+
+	if filter.HasVirtualServerName() {
+		s.Where(s.Equal("virtual_server_name", filter.GetVirtualServerName()))
+	}
+
+	if filter.HasAlgorithm() {
+		s.Where(s.Equal("algorithm", string(filter.GetAlgorithm())))
+	}
+
+	if filter.HasKid() {
+		s.Where(s.Equal("kid", filter.GetKid()))
+	}
+
+	return s
+}
+
+func (r *SigningKeyRepository) List(ctx context.Context, filter *repositories.SigningKeyFilter) ([]*repositories.SigningKey, error) {
+	s := r.selectQuery(filter)
+	s.OrderBy("created_at")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying db: %w", err)
+	}
+	defer utils.PanicOnError(rows.Close, "closing rows")
+
+	var result []*repositories.SigningKey
+	for rows.Next() {
+		signingKey := &postgresSigningKey{}
+		err := signingKey.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		result = append(result, signingKey.Map())
+	}
+
+	return result, nil
+}
+
+func (r *SigningKeyRepository) FirstOrNil(ctx context.Context, filter *repositories.SigningKeyFilter) (*repositories.SigningKey, error) {
+	s := r.selectQuery(filter)
+	s.Limit(1)
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	signingKey := &postgresSigningKey{}
+	err := signingKey.scan(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return signingKey.Map(), nil
+}
+
+func (r *SigningKeyRepository) Insert(signingKey *repositories.SigningKey) {
+	r.changeTracker.Add(change.NewEntry(change.Added, r.entityType, signingKey))
+}
+
+func (r *SigningKeyRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, signingKey *repositories.SigningKey) error {
+	mapped := mapSigningKey(signingKey)
+
+	s := sqlbuilder.InsertInto("signing_keys").
+		Cols(
+			"id",
+			"audit_created_at",
+			"audit_updated_at",
+			"virtual_server_name",
+			"algorithm",
+			"kid",
+			"encrypted_private_key",
+			"wrapped_data_key",
+			"master_key_id",
+			"created_at",
+			"activates_at",
+			"rotates_at",
+			"expires_at",
+			"verify_only",
+		).
+		Values(
+			mapped.id,
+			mapped.auditCreatedAt,
+			mapped.auditUpdatedAt,
+			mapped.virtualServerName,
+			mapped.algorithm,
+			mapped.kid,
+			mapped.encryptedPrivateKey,
+			mapped.wrappedDataKey,
+			mapped.masterKeyId,
+			mapped.createdAt,
+			mapped.activatesAt,
+			mapped.rotatesAt,
+			mapped.expiresAt,
+			mapped.verifyOnly,
+		).
+		Returning("xmin")
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	signingKey.SetVersion(xmin)
+	signingKey.ClearChanges()
+	return nil
+}
+
+func (r *SigningKeyRepository) Update(signingKey *repositories.SigningKey) {
+	r.changeTracker.Add(change.NewEntry(change.Updated, r.entityType, signingKey))
+}
+
+func (r *SigningKeyRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, signingKey *repositories.SigningKey) error {
+	if !signingKey.HasChanges() {
+		return nil
+	}
+
+	mapped := mapSigningKey(signingKey)
+
+	s := sqlbuilder.Update("signing_keys")
+	s.Where(s.Equal("id", mapped.id))
+	s.Where(s.Equal("xmin", mapped.xmin))
+
+	for _, field := range signingKey.GetChanges() {
+		switch field {
+		case repositories.SigningKeyChangeSchedule:
+			s.SetMore(
+				s.Assign("created_at", mapped.createdAt),
+				s.Assign("activates_at", mapped.activatesAt),
+				s.Assign("rotates_at", mapped.rotatesAt),
+				s.Assign("expires_at", mapped.expiresAt),
+				s.Assign("verify_only", mapped.verifyOnly),
+			)
+
+		case repositories.SigningKeyChangeEncryption:
+			s.SetMore(
+				s.Assign("encrypted_private_key", mapped.encryptedPrivateKey),
+				s.Assign("wrapped_data_key", mapped.wrappedDataKey),
+				s.Assign("master_key_id", mapped.masterKeyId),
+			)
+
+		default:
+			return fmt.Errorf("updating field %v is not supported", field)
+		}
+	}
+
+	s.Returning("xmin")
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	row := tx.QueryRowContext(ctx, query, args...)
+
+	var xmin uint32
+	err := row.Scan(&xmin)
+	if err != nil {
+		return fmt.Errorf("scanning row: %w", err)
+	}
+
+	signingKey.SetVersion(xmin)
+	signingKey.ClearChanges()
+	return nil
+}
+
+func (r *SigningKeyRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *SigningKeyRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("signing_keys")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
@@ -224,3 +224,21 @@ func (r *UserRoleAssignmentRepository) ExecuteInsert(ctx context.Context, tx *sq
 	userRoleAssignment.SetVersion(xmin)
 	return nil
 }
+
+func (r *UserRoleAssignmentRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *UserRoleAssignmentRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("user_role_assignments")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
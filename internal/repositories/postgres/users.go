@@ -17,25 +17,29 @@ import (
 
 type postgresUser struct {
 	postgresBaseModel
-	virtualServerId uuid.UUID
-	username        string
-	displayName     string
-	primaryEmail    string
-	emailVerified   bool
-	serviceUser     bool
-	metadata        string
+	virtualServerId      uuid.UUID
+	username             string
+	displayName          string
+	primaryEmail         string
+	emailVerified        bool
+	serviceUser          bool
+	disabled             bool
+	require2faOnboarding bool
+	metadata             string
 }
 
 func mapUser(m *repositories.User) *postgresUser {
 	return &postgresUser{
-		postgresBaseModel: mapBase(m.BaseModel),
-		virtualServerId:   m.VirtualServerId(),
-		username:          m.Username(),
-		displayName:       m.DisplayName(),
-		primaryEmail:      m.PrimaryEmail(),
-		emailVerified:     m.EmailVerified(),
-		serviceUser:       m.IsServiceUser(),
-		metadata:          m.Metadata(),
+		postgresBaseModel:    mapBase(m.BaseModel),
+		virtualServerId:      m.VirtualServerId(),
+		username:             m.Username(),
+		displayName:          m.DisplayName(),
+		primaryEmail:         m.PrimaryEmail(),
+		emailVerified:        m.EmailVerified(),
+		serviceUser:          m.IsServiceUser(),
+		disabled:             m.Disabled(),
+		require2faOnboarding: m.Require2faOnboarding(),
+		metadata:             m.Metadata(),
 	}
 }
 
@@ -48,6 +52,8 @@ func (u *postgresUser) Map() *repositories.User {
 		u.primaryEmail,
 		u.emailVerified,
 		u.serviceUser,
+		u.disabled,
+		u.require2faOnboarding,
 		u.metadata,
 	)
 }
@@ -64,6 +70,8 @@ func (u *postgresUser) scan(row pghelpers.Row, filter *repositories.UserFilter,
 		&u.primaryEmail,
 		&u.emailVerified,
 		&u.serviceUser,
+		&u.disabled,
+		&u.require2faOnboarding,
 		&u.metadata,
 	}
 
@@ -102,6 +110,8 @@ func (r *UserRepository) selectQuery(filter *repositories.UserFilter) *sqlbuilde
 		"primary_email",
 		"email_verified",
 		"service_user",
+		"disabled",
+		"require_2fa_onboarding",
 		"metadata",
 	).From("users")
 
@@ -112,6 +122,9 @@ func (r *UserRepository) selectQuery(filter *repositories.UserFilter) *sqlbuilde
 	if filter.HasUsername() {
 		s.Where(s.Equal("username", filter.GetUsername()))
 	}
+	if filter.HasPrimaryEmail() {
+		s.Where(s.Equal("primary_email", filter.GetPrimaryEmail()))
+	}
 
 	if filter.HasVirtualServerId() {
 		s.Where(s.Equal("virtual_server_id", filter.GetVirtualServerId()))
@@ -125,6 +138,10 @@ func (r *UserRepository) selectQuery(filter *repositories.UserFilter) *sqlbuilde
 		s.Where(s.Equal("service_user", filter.GetServiceUser()))
 	}
 
+	if filter.HasUpdatedAfter() {
+		s.Where(s.GreaterThan("audit_updated_at", filter.GetUpdatedAfter()))
+	}
+
 	if filter.HasSearch() {
 		term := filter.GetSearch().Term()
 		s.Where(s.Or(
@@ -219,6 +236,8 @@ func (r *UserRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, user *re
 		"primary_email",
 		"email_verified",
 		"service_user",
+		"disabled",
+		"require_2fa_onboarding",
 		"metadata",
 	}
 
@@ -238,6 +257,8 @@ func (r *UserRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx, user *re
 		mapped.primaryEmail,
 		mapped.emailVerified,
 		mapped.serviceUser,
+		mapped.disabled,
+		mapped.require2faOnboarding,
 		mapped.metadata,
 	}
 
@@ -287,6 +308,15 @@ func (r *UserRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, user *re
 		case repositories.UserChangeEmailVerified:
 			s.SetMore(s.Assign("email_verified", mapped.emailVerified))
 
+		case repositories.UserChangePrimaryEmail:
+			s.SetMore(s.Assign("primary_email", mapped.primaryEmail))
+
+		case repositories.UserChangeDisabled:
+			s.SetMore(s.Assign("disabled", mapped.disabled))
+
+		case repositories.UserChangeRequire2faOnboarding:
+			s.SetMore(s.Assign("require_2fa_onboarding", mapped.require2faOnboarding))
+
 		default:
 			return fmt.Errorf("updating field %v is not supported", field)
 		}
@@ -310,3 +340,21 @@ func (r *UserRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx, user *re
 	user.ClearChanges()
 	return nil
 }
+
+func (r *UserRepository) Delete(id uuid.UUID) {
+	r.changeTracker.Add(change.NewEntry(change.Deleted, r.entityType, id))
+}
+
+func (r *UserRepository) ExecuteDelete(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	s := sqlbuilder.DeleteFrom("users")
+	s.Where(s.Equal("id", id))
+
+	query, args := s.Build()
+	logging.Logger.Debug("executing sql: ", query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("executing delete: %w", err)
+	}
+
+	return nil
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/The127/Keyline/internal/repositories"
 	"github.com/The127/Keyline/internal/repositories/postgres/pghelpers"
 	"github.com/The127/Keyline/utils"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/lib/pq"
 )
@@ -23,8 +25,23 @@ type postgresVirtualServer struct {
 	enableRegistration          bool
 	require2fa                  bool
 	requireEmailVerification    bool
+	enableEmailLogin            bool
+	trustedDeviceDays           int
+	mfaRules                    repositories.MfaRules
 	primarySigningAlgorithm     string
 	additionalSigningAlgorithms pq.StringArray
+	keyRotateAfterSeconds       int64
+	keyExpireAfterSeconds       int64
+	keyPrePublishLeadSeconds    int64
+	passkeyAllowedAaguids       pq.StringArray
+	passkeyRequireAttestation   bool
+	passkeyUserVerification     string
+	lockoutMaxFailedAttempts    int
+	lockoutMaxFailedAttemptsIp  int
+	lockoutDurationSeconds      int64
+	lockoutMaxDurationSeconds   int64
+	lockoutPermanentAfter       int
+	lockoutNotifyUser           bool
 }
 
 func mapVirtualServer(virtualServer *repositories.VirtualServer) *postgresVirtualServer {
@@ -32,6 +49,10 @@ func mapVirtualServer(virtualServer *repositories.VirtualServer) *postgresVirtua
 	for i, a := range virtualServer.AdditionalSigningAlgorithms() {
 		additional[i] = string(a)
 	}
+	allowedAaguids := make(pq.StringArray, len(virtualServer.PasskeyPolicy().AllowedAaguids))
+	for i, aaguid := range virtualServer.PasskeyPolicy().AllowedAaguids {
+		allowedAaguids[i] = aaguid.String()
+	}
 	return &postgresVirtualServer{
 		postgresBaseModel:           mapBase(virtualServer.BaseModel),
 		displayName:                 virtualServer.DisplayName(),
@@ -39,8 +60,23 @@ func mapVirtualServer(virtualServer *repositories.VirtualServer) *postgresVirtua
 		enableRegistration:          virtualServer.EnableRegistration(),
 		require2fa:                  virtualServer.Require2fa(),
 		requireEmailVerification:    virtualServer.RequireEmailVerification(),
+		enableEmailLogin:            virtualServer.EnableEmailLogin(),
+		trustedDeviceDays:           virtualServer.TrustedDeviceDays(),
+		mfaRules:                    virtualServer.MfaRules(),
 		primarySigningAlgorithm:     string(virtualServer.PrimarySigningAlgorithm()),
 		additionalSigningAlgorithms: additional,
+		keyRotateAfterSeconds:       int64(virtualServer.KeyRotationPolicy().RotateAfter / time.Second),
+		keyExpireAfterSeconds:       int64(virtualServer.KeyRotationPolicy().ExpireAfter / time.Second),
+		keyPrePublishLeadSeconds:    int64(virtualServer.KeyRotationPolicy().PrePublishLead / time.Second),
+		passkeyAllowedAaguids:       allowedAaguids,
+		passkeyRequireAttestation:   virtualServer.PasskeyPolicy().RequireAttestation,
+		passkeyUserVerification:     string(virtualServer.PasskeyPolicy().UserVerification),
+		lockoutMaxFailedAttempts:    virtualServer.LockoutPolicy().MaxFailedAttempts,
+		lockoutMaxFailedAttemptsIp:  virtualServer.LockoutPolicy().MaxFailedAttemptsPerIp,
+		lockoutDurationSeconds:      int64(virtualServer.LockoutPolicy().LockoutDuration / time.Second),
+		lockoutMaxDurationSeconds:   int64(virtualServer.LockoutPolicy().MaxLockoutDuration / time.Second),
+		lockoutPermanentAfter:       virtualServer.LockoutPolicy().PermanentLockoutAfter,
+		lockoutNotifyUser:           virtualServer.LockoutPolicy().NotifyUser,
 	}
 }
 
@@ -52,8 +88,29 @@ func (s *postgresVirtualServer) Map() *repositories.VirtualServer {
 		s.enableRegistration,
 		s.require2fa,
 		s.requireEmailVerification,
+		s.enableEmailLogin,
+		s.trustedDeviceDays,
+		s.mfaRules,
 		s.primarySigningAlgorithm,
 		[]string(s.additionalSigningAlgorithms),
+		repositories.KeyRotationPolicy{
+			RotateAfter:    time.Duration(s.keyRotateAfterSeconds) * time.Second,
+			ExpireAfter:    time.Duration(s.keyExpireAfterSeconds) * time.Second,
+			PrePublishLead: time.Duration(s.keyPrePublishLeadSeconds) * time.Second,
+		},
+		repositories.PasskeyPolicy{
+			AllowedAaguids:     utils.MapSlice(s.passkeyAllowedAaguids, uuid.MustParse),
+			RequireAttestation: s.passkeyRequireAttestation,
+			UserVerification:   repositories.PasskeyUserVerification(s.passkeyUserVerification),
+		},
+		repositories.LockoutPolicy{
+			MaxFailedAttempts:      s.lockoutMaxFailedAttempts,
+			MaxFailedAttemptsPerIp: s.lockoutMaxFailedAttemptsIp,
+			LockoutDuration:        time.Duration(s.lockoutDurationSeconds) * time.Second,
+			MaxLockoutDuration:     time.Duration(s.lockoutMaxDurationSeconds) * time.Second,
+			PermanentLockoutAfter:  s.lockoutPermanentAfter,
+			NotifyUser:             s.lockoutNotifyUser,
+		},
 	)
 }
 
@@ -68,8 +125,23 @@ func (s *postgresVirtualServer) scan(row pghelpers.Row, additionalPtrs ...any) e
 		&s.enableRegistration,
 		&s.require2fa,
 		&s.requireEmailVerification,
+		&s.enableEmailLogin,
+		&s.trustedDeviceDays,
+		&s.mfaRules,
 		&s.primarySigningAlgorithm,
 		&s.additionalSigningAlgorithms,
+		&s.keyRotateAfterSeconds,
+		&s.keyExpireAfterSeconds,
+		&s.keyPrePublishLeadSeconds,
+		&s.passkeyAllowedAaguids,
+		&s.passkeyRequireAttestation,
+		&s.passkeyUserVerification,
+		&s.lockoutMaxFailedAttempts,
+		&s.lockoutMaxFailedAttemptsIp,
+		&s.lockoutDurationSeconds,
+		&s.lockoutMaxDurationSeconds,
+		&s.lockoutPermanentAfter,
+		&s.lockoutNotifyUser,
 	}
 
 	ptrs = append(ptrs, additionalPtrs...)
@@ -107,8 +179,23 @@ func (r *VirtualServerRepository) selectQuery(filter *repositories.VirtualServer
 		"enable_registration",
 		"require_2fa",
 		"require_email_verification",
+		"enable_email_login",
+		"trusted_device_days",
+		"mfa_rules",
 		"primary_signing_algorithm",
 		"additional_signing_algorithms",
+		"key_rotate_after_seconds",
+		"key_expire_after_seconds",
+		"key_pre_publish_lead_seconds",
+		"passkey_allowed_aaguids",
+		"passkey_require_attestation",
+		"passkey_user_verification",
+		"lockout_max_failed_attempts",
+		"lockout_max_failed_attempts_per_ip",
+		"lockout_duration_seconds",
+		"lockout_max_duration_seconds",
+		"lockout_permanent_after",
+		"lockout_notify_user",
 	).From("virtual_servers")
 
 	if filter.HasName() {
@@ -207,8 +294,23 @@ func (r *VirtualServerRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx,
 			"display_name",
 			"enable_registration",
 			"require_2fa",
+			"enable_email_login",
+			"trusted_device_days",
+			"mfa_rules",
 			"primary_signing_algorithm",
 			"additional_signing_algorithms",
+			"key_rotate_after_seconds",
+			"key_expire_after_seconds",
+			"key_pre_publish_lead_seconds",
+			"passkey_allowed_aaguids",
+			"passkey_require_attestation",
+			"passkey_user_verification",
+			"lockout_max_failed_attempts",
+			"lockout_max_failed_attempts_per_ip",
+			"lockout_duration_seconds",
+			"lockout_max_duration_seconds",
+			"lockout_permanent_after",
+			"lockout_notify_user",
 		).
 		Values(
 			mapped.id,
@@ -218,8 +320,23 @@ func (r *VirtualServerRepository) ExecuteInsert(ctx context.Context, tx *sql.Tx,
 			mapped.displayName,
 			mapped.enableRegistration,
 			mapped.require2fa,
+			mapped.enableEmailLogin,
+			mapped.trustedDeviceDays,
+			mapped.mfaRules,
 			mapped.primarySigningAlgorithm,
 			mapped.additionalSigningAlgorithms,
+			mapped.keyRotateAfterSeconds,
+			mapped.keyExpireAfterSeconds,
+			mapped.keyPrePublishLeadSeconds,
+			mapped.passkeyAllowedAaguids,
+			mapped.passkeyRequireAttestation,
+			mapped.passkeyUserVerification,
+			mapped.lockoutMaxFailedAttempts,
+			mapped.lockoutMaxFailedAttemptsIp,
+			mapped.lockoutDurationSeconds,
+			mapped.lockoutMaxDurationSeconds,
+			mapped.lockoutPermanentAfter,
+			mapped.lockoutNotifyUser,
 		).
 		Returning("xmin")
 
@@ -267,12 +384,39 @@ func (r *VirtualServerRepository) ExecuteUpdate(ctx context.Context, tx *sql.Tx,
 		case repositories.VirtualServerChangeRequireEmailVerification:
 			s.SetMore(s.Assign("require_email_verification", mapped.requireEmailVerification))
 
+		case repositories.VirtualServerChangeEnableEmailLogin:
+			s.SetMore(s.Assign("enable_email_login", mapped.enableEmailLogin))
+
+		case repositories.VirtualServerChangeTrustedDeviceDays:
+			s.SetMore(s.Assign("trusted_device_days", mapped.trustedDeviceDays))
+
+		case repositories.VirtualServerChangeMfaRules:
+			s.SetMore(s.Assign("mfa_rules", mapped.mfaRules))
+
 		case repositories.VirtualServerChangePrimarySigningAlgorithm:
 			s.SetMore(s.Assign("primary_signing_algorithm", mapped.primarySigningAlgorithm))
 
 		case repositories.VirtualServerChangeAdditionalSigningAlgorithms:
 			s.SetMore(s.Assign("additional_signing_algorithms", mapped.additionalSigningAlgorithms))
 
+		case repositories.VirtualServerChangeKeyRotationPolicy:
+			s.SetMore(s.Assign("key_rotate_after_seconds", mapped.keyRotateAfterSeconds))
+			s.SetMore(s.Assign("key_expire_after_seconds", mapped.keyExpireAfterSeconds))
+			s.SetMore(s.Assign("key_pre_publish_lead_seconds", mapped.keyPrePublishLeadSeconds))
+
+		case repositories.VirtualServerChangePasskeyPolicy:
+			s.SetMore(s.Assign("passkey_allowed_aaguids", mapped.passkeyAllowedAaguids))
+			s.SetMore(s.Assign("passkey_require_attestation", mapped.passkeyRequireAttestation))
+			s.SetMore(s.Assign("passkey_user_verification", mapped.passkeyUserVerification))
+
+		case repositories.VirtualServerChangeLockoutPolicy:
+			s.SetMore(s.Assign("lockout_max_failed_attempts", mapped.lockoutMaxFailedAttempts))
+			s.SetMore(s.Assign("lockout_max_failed_attempts_per_ip", mapped.lockoutMaxFailedAttemptsIp))
+			s.SetMore(s.Assign("lockout_duration_seconds", mapped.lockoutDurationSeconds))
+			s.SetMore(s.Assign("lockout_max_duration_seconds", mapped.lockoutMaxDurationSeconds))
+			s.SetMore(s.Assign("lockout_permanent_after", mapped.lockoutPermanentAfter))
+			s.SetMore(s.Assign("lockout_notify_user", mapped.lockoutNotifyUser))
+
 		default:
 			return fmt.Errorf("updating field %v is not supported", field)
 		}
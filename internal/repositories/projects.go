@@ -39,8 +39,12 @@ func NewProject(virtualServerId uuid.UUID, slug string, name string, description
 	}
 }
 
+// SystemProjectSlug is the slug of the project every virtual server keeps its
+// internal roles and applications in.
+const SystemProjectSlug = "system"
+
 func NewSystemProject(virtualServerId uuid.UUID) *Project {
-	project := NewProject(virtualServerId, "system", "System Project", "Keyline internal project for system internal resources.")
+	project := NewProject(virtualServerId, SystemProjectSlug, "System Project", "Keyline internal project for system internal resources.")
 	project.systemProject = true
 	return project
 }
@@ -0,0 +1,321 @@
+package repositories
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/utils"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ProvisioningTargetChange int
+
+const (
+	ProvisioningTargetChangeUrl ProvisioningTargetChange = iota
+	ProvisioningTargetChangeAuth
+	ProvisioningTargetChangeAttributeMapping
+	ProvisioningTargetChangeEnabled
+	ProvisioningTargetChangeStatus
+)
+
+type ProvisioningAuthType string
+
+const (
+	ProvisioningAuthTypeBearer ProvisioningAuthType = "bearer"
+	ProvisioningAuthTypeBasic  ProvisioningAuthType = "basic"
+)
+
+// ProvisioningSource names a user attribute that can be pushed to a
+// provisioning target.
+type ProvisioningSource string
+
+const (
+	ProvisioningSourceId          ProvisioningSource = "id"
+	ProvisioningSourceUsername    ProvisioningSource = "username"
+	ProvisioningSourceDisplayName ProvisioningSource = "displayName"
+	ProvisioningSourceEmail       ProvisioningSource = "email"
+	ProvisioningSourceActive      ProvisioningSource = "active"
+	// ProvisioningSourceRoles are the names of the roles the user holds in
+	// the project of the application.
+	ProvisioningSourceRoles ProvisioningSource = "roles"
+)
+
+func (s ProvisioningSource) Valid() bool {
+	switch s {
+	case ProvisioningSourceId,
+		ProvisioningSourceUsername,
+		ProvisioningSourceDisplayName,
+		ProvisioningSourceEmail,
+		ProvisioningSourceActive,
+		ProvisioningSourceRoles:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProvisioningAttributeMapping maps SCIM attribute paths of the downstream
+// user resource onto the user attributes they are filled from.
+type ProvisioningAttributeMapping map[string]ProvisioningSource
+
+// DefaultProvisioningAttributeMapping returns the mapping used when a target
+// does not configure one.
+func DefaultProvisioningAttributeMapping() ProvisioningAttributeMapping {
+	return ProvisioningAttributeMapping{
+		"externalId":                   ProvisioningSourceId,
+		"userName":                     ProvisioningSourceUsername,
+		"displayName":                  ProvisioningSourceDisplayName,
+		`emails[type eq "work"].value`: ProvisioningSourceEmail,
+		"active":                       ProvisioningSourceActive,
+		"roles":                        ProvisioningSourceRoles,
+	}
+}
+
+// ProvisioningTarget pushes the users of an application to a downstream
+// SCIM service provider.
+type ProvisioningTarget struct {
+	BaseModel
+	change.List[ProvisioningTargetChange]
+
+	virtualServerId uuid.UUID
+	applicationId   uuid.UUID
+
+	url string
+
+	authType     ProvisioningAuthType
+	authUsername *string
+	authSecret   string
+
+	attributeMapping ProvisioningAttributeMapping
+	enabled          bool
+
+	lastSyncAt  *time.Time
+	lastError   *string
+	lastErrorAt *time.Time
+}
+
+func NewProvisioningTarget(virtualServerId uuid.UUID, applicationId uuid.UUID, url string, authType ProvisioningAuthType, authSecret string) *ProvisioningTarget {
+	return &ProvisioningTarget{
+		BaseModel:        NewBaseModel(),
+		List:             change.NewChanges[ProvisioningTargetChange](),
+		virtualServerId:  virtualServerId,
+		applicationId:    applicationId,
+		url:              url,
+		authType:         authType,
+		authSecret:       authSecret,
+		attributeMapping: DefaultProvisioningAttributeMapping(),
+		enabled:          true,
+	}
+}
+
+func NewProvisioningTargetFromDB(
+	base BaseModel,
+	virtualServerId uuid.UUID,
+	applicationId uuid.UUID,
+	url string,
+	authType ProvisioningAuthType,
+	authUsername *string,
+	authSecret string,
+	attributeMapping ProvisioningAttributeMapping,
+	enabled bool,
+	lastSyncAt *time.Time,
+	lastError *string,
+	lastErrorAt *time.Time,
+) *ProvisioningTarget {
+	return &ProvisioningTarget{
+		BaseModel:        base,
+		List:             change.NewChanges[ProvisioningTargetChange](),
+		virtualServerId:  virtualServerId,
+		applicationId:    applicationId,
+		url:              url,
+		authType:         authType,
+		authUsername:     authUsername,
+		authSecret:       authSecret,
+		attributeMapping: attributeMapping,
+		enabled:          enabled,
+		lastSyncAt:       lastSyncAt,
+		lastError:        lastError,
+		lastErrorAt:      lastErrorAt,
+	}
+}
+
+func (t *ProvisioningTarget) VirtualServerId() uuid.UUID {
+	return t.virtualServerId
+}
+
+func (t *ProvisioningTarget) ApplicationId() uuid.UUID {
+	return t.applicationId
+}
+
+func (t *ProvisioningTarget) Url() string {
+	return t.url
+}
+
+func (t *ProvisioningTarget) SetUrl(url string) {
+	if t.url == url {
+		return
+	}
+
+	t.url = url
+	t.TrackChange(ProvisioningTargetChangeUrl)
+}
+
+func (t *ProvisioningTarget) AuthType() ProvisioningAuthType {
+	return t.authType
+}
+
+func (t *ProvisioningTarget) AuthUsername() *string {
+	return t.authUsername
+}
+
+func (t *ProvisioningTarget) AuthSecret() string {
+	return t.authSecret
+}
+
+// SetAuth replaces the credentials. The username is only used by basic
+// authentication.
+func (t *ProvisioningTarget) SetAuth(authType ProvisioningAuthType, authUsername *string, authSecret string) {
+	t.authType = authType
+	t.authUsername = authUsername
+	t.authSecret = authSecret
+	t.TrackChange(ProvisioningTargetChangeAuth)
+}
+
+func (t *ProvisioningTarget) AttributeMapping() ProvisioningAttributeMapping {
+	return t.attributeMapping
+}
+
+func (t *ProvisioningTarget) SetAttributeMapping(attributeMapping ProvisioningAttributeMapping) {
+	if maps.Equal(t.attributeMapping, attributeMapping) {
+		return
+	}
+
+	t.attributeMapping = attributeMapping
+	t.TrackChange(ProvisioningTargetChangeAttributeMapping)
+}
+
+func (t *ProvisioningTarget) Enabled() bool {
+	return t.enabled
+}
+
+func (t *ProvisioningTarget) SetEnabled(enabled bool) {
+	if t.enabled == enabled {
+		return
+	}
+
+	t.enabled = enabled
+	t.TrackChange(ProvisioningTargetChangeEnabled)
+}
+
+func (t *ProvisioningTarget) LastSyncAt() *time.Time {
+	return t.lastSyncAt
+}
+
+func (t *ProvisioningTarget) LastError() *string {
+	return t.lastError
+}
+
+func (t *ProvisioningTarget) LastErrorAt() *time.Time {
+	return t.lastErrorAt
+}
+
+// RecordSuccess notes a successful delivery and clears the last error.
+func (t *ProvisioningTarget) RecordSuccess(now time.Time) {
+	t.lastSyncAt = &now
+	t.lastError = nil
+	t.lastErrorAt = nil
+	t.TrackChange(ProvisioningTargetChangeStatus)
+}
+
+// RecordError notes a failed delivery. The time of the last successful
+// delivery is kept.
+func (t *ProvisioningTarget) RecordError(err error, now time.Time) {
+	lastError := err.Error()
+	t.lastError = &lastError
+	t.lastErrorAt = &now
+	t.TrackChange(ProvisioningTargetChangeStatus)
+}
+
+type ProvisioningTargetFilter struct {
+	virtualServerId *uuid.UUID
+	applicationId   *uuid.UUID
+	id              *uuid.UUID
+	enabled         *bool
+}
+
+func NewProvisioningTargetFilter() *ProvisioningTargetFilter {
+	return &ProvisioningTargetFilter{}
+}
+
+func (f *ProvisioningTargetFilter) Clone() *ProvisioningTargetFilter {
+	clone := *f
+	return &clone
+}
+
+func (f *ProvisioningTargetFilter) VirtualServerId(virtualServerId uuid.UUID) *ProvisioningTargetFilter {
+	filter := f.Clone()
+	filter.virtualServerId = &virtualServerId
+	return filter
+}
+
+func (f *ProvisioningTargetFilter) HasVirtualServerId() bool {
+	return f.virtualServerId != nil
+}
+
+func (f *ProvisioningTargetFilter) GetVirtualServerId() uuid.UUID {
+	return utils.ZeroIfNil(f.virtualServerId)
+}
+
+func (f *ProvisioningTargetFilter) ApplicationId(applicationId uuid.UUID) *ProvisioningTargetFilter {
+	filter := f.Clone()
+	filter.applicationId = &applicationId
+	return filter
+}
+
+func (f *ProvisioningTargetFilter) HasApplicationId() bool {
+	return f.applicationId != nil
+}
+
+func (f *ProvisioningTargetFilter) GetApplicationId() uuid.UUID {
+	return utils.ZeroIfNil(f.applicationId)
+}
+
+func (f *ProvisioningTargetFilter) Id(id uuid.UUID) *ProvisioningTargetFilter {
+	filter := f.Clone()
+	filter.id = &id
+	return filter
+}
+
+func (f *ProvisioningTargetFilter) HasId() bool {
+	return f.id != nil
+}
+
+func (f *ProvisioningTargetFilter) GetId() uuid.UUID {
+	return utils.ZeroIfNil(f.id)
+}
+
+func (f *ProvisioningTargetFilter) Enabled(enabled bool) *ProvisioningTargetFilter {
+	filter := f.Clone()
+	filter.enabled = &enabled
+	return filter
+}
+
+func (f *ProvisioningTargetFilter) HasEnabled() bool {
+	return f.enabled != nil
+}
+
+func (f *ProvisioningTargetFilter) GetEnabled() bool {
+	return utils.ZeroIfNil(f.enabled)
+}
+
+//go:generate mockgen -destination=./mocks/provisioningtarget_repository.go -package=mocks Keyline/internal/repositories ProvisioningTargetRepository
+type ProvisioningTargetRepository interface {
+	FirstOrErr(ctx context.Context, filter *ProvisioningTargetFilter) (*ProvisioningTarget, error)
+	FirstOrNil(ctx context.Context, filter *ProvisioningTargetFilter) (*ProvisioningTarget, error)
+	List(ctx context.Context, filter *ProvisioningTargetFilter) ([]*ProvisioningTarget, error)
+	Insert(provisioningTarget *ProvisioningTarget)
+	Update(provisioningTarget *ProvisioningTarget)
+	Delete(id uuid.UUID)
+}
@@ -24,27 +24,33 @@ type Session struct {
 	hashedToken     string
 	expiresAt       time.Time
 	lastUsedAt      *time.Time
+
+	// authenticationMethods were completed in the login that created the
+	// session
+	authenticationMethods []AuthenticationMethod
 }
 
-func NewSession(virtualServerId uuid.UUID, userId uuid.UUID, expiresAt time.Time) *Session {
+func NewSession(virtualServerId uuid.UUID, userId uuid.UUID, expiresAt time.Time, authenticationMethods []AuthenticationMethod) *Session {
 	return &Session{
-		BaseModel:       NewBaseModel(),
-		List:            change.NewChanges[SessionChange](),
-		virtualServerId: virtualServerId,
-		userId:          userId,
-		expiresAt:       expiresAt,
+		BaseModel:             NewBaseModel(),
+		List:                  change.NewChanges[SessionChange](),
+		virtualServerId:       virtualServerId,
+		userId:                userId,
+		expiresAt:             expiresAt,
+		authenticationMethods: authenticationMethods,
 	}
 }
 
-func NewSessionFromDB(base BaseModel, virtualServerId uuid.UUID, userId uuid.UUID, hashedToken string, expiresAt time.Time, lastUsedAt *time.Time) *Session {
+func NewSessionFromDB(base BaseModel, virtualServerId uuid.UUID, userId uuid.UUID, hashedToken string, expiresAt time.Time, lastUsedAt *time.Time, authenticationMethods []AuthenticationMethod) *Session {
 	return &Session{
-		BaseModel:       base,
-		List:            change.NewChanges[SessionChange](),
-		virtualServerId: virtualServerId,
-		userId:          userId,
-		hashedToken:     hashedToken,
-		expiresAt:       expiresAt,
-		lastUsedAt:      lastUsedAt,
+		BaseModel:             base,
+		List:                  change.NewChanges[SessionChange](),
+		virtualServerId:       virtualServerId,
+		userId:                userId,
+		hashedToken:           hashedToken,
+		expiresAt:             expiresAt,
+		lastUsedAt:            lastUsedAt,
+		authenticationMethods: authenticationMethods,
 	}
 }
 
@@ -60,6 +66,10 @@ func (s *Session) ExpiresAt() time.Time {
 	return s.expiresAt
 }
 
+func (s *Session) AuthenticationMethods() []AuthenticationMethod {
+	return s.authenticationMethods
+}
+
 func (s *Session) LastUsedAt() *time.Time {
 	return s.lastUsedAt
 }
@@ -145,6 +155,7 @@ func (f *SessionFilter) GetId() uuid.UUID {
 type SessionRepository interface {
 	FirstOrErr(ctx context.Context, filter *SessionFilter) (*Session, error)
 	FirstOrNil(ctx context.Context, filter *SessionFilter) (*Session, error)
+	List(ctx context.Context, filter *SessionFilter) ([]*Session, error)
 	Insert(session *Session)
 	Delete(id uuid.UUID)
 }
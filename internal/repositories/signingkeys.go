@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/change"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SigningKeyChange int
+
+const (
+	SigningKeyChangeSchedule SigningKeyChange = iota
+	SigningKeyChangeEncryption
+)
+
+// SigningKey is a key pair of the database key store. The private key is
+// encrypted with a data key of its own, which in turn is wrapped with the
+// master key identified by masterKeyId.
+type SigningKey struct {
+	BaseModel
+	change.List[SigningKeyChange]
+
+	virtualServerName string
+	algorithm         config.SigningAlgorithm
+	kid               string
+
+	encryptedPrivateKey []byte
+	wrappedDataKey      []byte
+	masterKeyId         string
+
+	createdAt   time.Time
+	activatesAt time.Time
+	rotatesAt   time.Time
+	expiresAt   time.Time
+	verifyOnly  bool
+}
+
+func NewSigningKey(virtualServerName string, algorithm config.SigningAlgorithm, kid string) *SigningKey {
+	return &SigningKey{
+		BaseModel:         NewBaseModel(),
+		List:              change.NewChanges[SigningKeyChange](),
+		virtualServerName: virtualServerName,
+		algorithm:         algorithm,
+		kid:               kid,
+	}
+}
+
+func NewSigningKeyFromDB(
+	base BaseModel,
+	virtualServerName string,
+	algorithm config.SigningAlgorithm,
+	kid string,
+	encryptedPrivateKey []byte,
+	wrappedDataKey []byte,
+	masterKeyId string,
+	createdAt time.Time,
+	activatesAt time.Time,
+	rotatesAt time.Time,
+	expiresAt time.Time,
+	verifyOnly bool,
+) *SigningKey {
+	return &SigningKey{
+		BaseModel:           base,
+		List:                change.NewChanges[SigningKeyChange](),
+		virtualServerName:   virtualServerName,
+		algorithm:           algorithm,
+		kid:                 kid,
+		encryptedPrivateKey: encryptedPrivateKey,
+		wrappedDataKey:      wrappedDataKey,
+		masterKeyId:         masterKeyId,
+		createdAt:           createdAt,
+		activatesAt:         activatesAt,
+		rotatesAt:           rotatesAt,
+		expiresAt:           expiresAt,
+		verifyOnly:          verifyOnly,
+	}
+}
+
+func (k *SigningKey) VirtualServerName() string {
+	return k.virtualServerName
+}
+
+func (k *SigningKey) Algorithm() config.SigningAlgorithm {
+	return k.algorithm
+}
+
+func (k *SigningKey) Kid() string {
+	return k.kid
+}
+
+func (k *SigningKey) EncryptedPrivateKey() []byte {
+	return k.encryptedPrivateKey
+}
+
+func (k *SigningKey) WrappedDataKey() []byte {
+	return k.wrappedDataKey
+}
+
+func (k *SigningKey) MasterKeyId() string {
+	return k.masterKeyId
+}
+
+// SetEncryption replaces the encrypted private key and the wrapped data
+// key, e.g. after the data key was re-wrapped with a new master key.
+func (k *SigningKey) SetEncryption(encryptedPrivateKey []byte, wrappedDataKey []byte, masterKeyId string) {
+	k.encryptedPrivateKey = encryptedPrivateKey
+	k.wrappedDataKey = wrappedDataKey
+	k.masterKeyId = masterKeyId
+	k.TrackChange(SigningKeyChangeEncryption)
+}
+
+func (k *SigningKey) CreatedAt() time.Time {
+	return k.createdAt
+}
+
+func (k *SigningKey) ActivatesAt() time.Time {
+	return k.activatesAt
+}
+
+func (k *SigningKey) RotatesAt() time.Time {
+	return k.rotatesAt
+}
+
+func (k *SigningKey) ExpiresAt() time.Time {
+	return k.expiresAt
+}
+
+func (k *SigningKey) VerifyOnly() bool {
+	return k.verifyOnly
+}
+
+func (k *SigningKey) SetSchedule(createdAt time.Time, activatesAt time.Time, rotatesAt time.Time, expiresAt time.Time, verifyOnly bool) {
+	if k.createdAt.Equal(createdAt) &&
+		k.activatesAt.Equal(activatesAt) &&
+		k.rotatesAt.Equal(rotatesAt) &&
+		k.expiresAt.Equal(expiresAt) &&
+		k.verifyOnly == verifyOnly {
+		return
+	}
+
+	k.createdAt = createdAt
+	k.activatesAt = activatesAt
+	k.rotatesAt = rotatesAt
+	k.expiresAt = expiresAt
+	k.verifyOnly = verifyOnly
+	k.TrackChange(SigningKeyChangeSchedule)
+}
+
+type SigningKeyFilter struct {
+	virtualServerName *string
+	algorithm         *config.SigningAlgorithm
+	kid               *string
+}
+
+func NewSigningKeyFilter() *SigningKeyFilter {
+	return &SigningKeyFilter{}
+}
+
+func (f *SigningKeyFilter) Clone() *SigningKeyFilter {
+	clone := *f
+	return &clone
+}
+
+func (f *SigningKeyFilter) VirtualServerName(virtualServerName string) *SigningKeyFilter {
+	filter := f.Clone()
+	filter.virtualServerName = &virtualServerName
+	return filter
+}
+
+func (f *SigningKeyFilter) HasVirtualServerName() bool {
+	return f.virtualServerName != nil
+}
+
+func (f *SigningKeyFilter) GetVirtualServerName() string {
+	return utils.ZeroIfNil(f.virtualServerName)
+}
+
+func (f *SigningKeyFilter) Algorithm(algorithm config.SigningAlgorithm) *SigningKeyFilter {
+	filter := f.Clone()
+	filter.algorithm = &algorithm
+	return filter
+}
+
+func (f *SigningKeyFilter) HasAlgorithm() bool {
+	return f.algorithm != nil
+}
+
+func (f *SigningKeyFilter) GetAlgorithm() config.SigningAlgorithm {
+	return utils.ZeroIfNil(f.algorithm)
+}
+
+func (f *SigningKeyFilter) Kid(kid string) *SigningKeyFilter {
+	filter := f.Clone()
+	filter.kid = &kid
+	return filter
+}
+
+func (f *SigningKeyFilter) HasKid() bool {
+	return f.kid != nil
+}
+
+func (f *SigningKeyFilter) GetKid() string {
+	return utils.ZeroIfNil(f.kid)
+}
+
+//go:generate mockgen -destination=./mocks/signingkey_repository.go -package=mocks Keyline/internal/repositories SigningKeyRepository
+type SigningKeyRepository interface {
+	FirstOrNil(ctx context.Context, filter *SigningKeyFilter) (*SigningKey, error)
+	List(ctx context.Context, filter *SigningKeyFilter) ([]*SigningKey, error)
+	Insert(signingKey *SigningKey)
+	Update(signingKey *SigningKey)
+	Delete(id uuid.UUID)
+}
@@ -11,6 +11,10 @@ type TemplateType string
 
 const (
 	EmailVerificationMailTemplate TemplateType = "email_verification"
+	AccountLockedMailTemplate     TemplateType = "account_locked"
+	PasswordResetMailTemplate     TemplateType = "password_reset"
+	EmailLoginMailTemplate        TemplateType = "email_login"
+	SmsCodeTemplate               TemplateType = "sms_code"
 )
 
 type Template struct {
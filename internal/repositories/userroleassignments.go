@@ -151,4 +151,5 @@ func (f *UserRoleAssignmentFilter) GetIncludeRole() bool {
 type UserRoleAssignmentRepository interface {
 	List(ctx context.Context, filter *UserRoleAssignmentFilter) ([]*UserRoleAssignment, int, error)
 	Insert(userRoleAssignment *UserRoleAssignment)
+	Delete(id uuid.UUID)
 }
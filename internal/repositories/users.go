@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/The127/Keyline/internal/change"
 	"github.com/The127/Keyline/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +15,9 @@ const (
 	UserChangeDisplayName UserChange = iota
 	UserChangeEmailVerified
 	UserChangeMetadata
+	UserChangePrimaryEmail
+	UserChangeDisabled
+	UserChangeRequire2faOnboarding
 )
 
 type User struct {
@@ -30,6 +34,13 @@ type User struct {
 
 	serviceUser bool
 
+	// disabled users keep their data but can no longer obtain tokens
+	disabled bool
+
+	// require2faOnboarding makes the user onboard a second factor at the
+	// next login, it is set when an admin resets their second factors
+	require2faOnboarding bool
+
 	metadata string
 }
 
@@ -70,17 +81,19 @@ func NewServiceUser(username string, virtualServerId uuid.UUID) *User {
 	}
 }
 
-func NewUserFromDB(base BaseModel, virtualServerId uuid.UUID, username string, displayName string, primaryEmail string, emailVerified bool, serviceUser bool, metadata string) *User {
+func NewUserFromDB(base BaseModel, virtualServerId uuid.UUID, username string, displayName string, primaryEmail string, emailVerified bool, serviceUser bool, disabled bool, require2faOnboarding bool, metadata string) *User {
 	return &User{
-		BaseModel:       base,
-		List:            change.NewChanges[UserChange](),
-		virtualServerId: virtualServerId,
-		username:        username,
-		displayName:     displayName,
-		primaryEmail:    primaryEmail,
-		emailVerified:   emailVerified,
-		serviceUser:     serviceUser,
-		metadata:        metadata,
+		BaseModel:            base,
+		List:                 change.NewChanges[UserChange](),
+		virtualServerId:      virtualServerId,
+		username:             username,
+		displayName:          displayName,
+		primaryEmail:         primaryEmail,
+		emailVerified:        emailVerified,
+		serviceUser:          serviceUser,
+		disabled:             disabled,
+		require2faOnboarding: require2faOnboarding,
+		metadata:             metadata,
 	}
 }
 
@@ -113,6 +126,15 @@ func (m *User) PrimaryEmail() string {
 	return m.primaryEmail
 }
 
+func (m *User) SetPrimaryEmail(primaryEmail string) {
+	if m.primaryEmail == primaryEmail {
+		return
+	}
+
+	m.primaryEmail = primaryEmail
+	m.TrackChange(UserChangePrimaryEmail)
+}
+
 func (m *User) EmailVerified() bool {
 	return m.emailVerified
 }
@@ -126,6 +148,32 @@ func (m *User) SetEmailVerified(emailVerified bool) {
 	m.TrackChange(UserChangeEmailVerified)
 }
 
+func (m *User) Disabled() bool {
+	return m.disabled
+}
+
+func (m *User) SetDisabled(disabled bool) {
+	if m.disabled == disabled {
+		return
+	}
+
+	m.disabled = disabled
+	m.TrackChange(UserChangeDisabled)
+}
+
+func (m *User) Require2faOnboarding() bool {
+	return m.require2faOnboarding
+}
+
+func (m *User) SetRequire2faOnboarding(require2faOnboarding bool) {
+	if m.require2faOnboarding == require2faOnboarding {
+		return
+	}
+
+	m.require2faOnboarding = require2faOnboarding
+	m.TrackChange(UserChangeRequire2faOnboarding)
+}
+
 func (m *User) Metadata() string {
 	return m.metadata
 }
@@ -145,7 +193,9 @@ type UserFilter struct {
 	virtualServerId *uuid.UUID
 	id              *uuid.UUID
 	username        *string
+	primaryEmail    *string
 	serviceUser     *bool
+	updatedAfter    *time.Time
 	searchFilter    *SearchFilter
 	includeMetadata bool
 }
@@ -215,6 +265,35 @@ func (f *UserFilter) GetUsername() string {
 	return utils.ZeroIfNil(f.username)
 }
 
+func (f *UserFilter) PrimaryEmail(primaryEmail string) *UserFilter {
+	filter := f.Clone()
+	filter.primaryEmail = &primaryEmail
+	return filter
+}
+
+func (f *UserFilter) HasPrimaryEmail() bool {
+	return f.primaryEmail != nil
+}
+
+func (f *UserFilter) GetPrimaryEmail() string {
+	return utils.ZeroIfNil(f.primaryEmail)
+}
+
+// UpdatedAfter matches users changed strictly after the given time.
+func (f *UserFilter) UpdatedAfter(updatedAfter time.Time) *UserFilter {
+	filter := f.Clone()
+	filter.updatedAfter = &updatedAfter
+	return filter
+}
+
+func (f *UserFilter) HasUpdatedAfter() bool {
+	return f.updatedAfter != nil
+}
+
+func (f *UserFilter) GetUpdatedAfter() time.Time {
+	return utils.ZeroIfNil(f.updatedAfter)
+}
+
 func (f *UserFilter) IncludeMetadata() *UserFilter {
 	filter := f.Clone()
 	filter.includeMetadata = true
@@ -280,4 +359,5 @@ type UserRepository interface {
 	List(ctx context.Context, filter *UserFilter) ([]*User, int, error)
 	Insert(user *User)
 	Update(user *User)
+	Delete(id uuid.UUID)
 }
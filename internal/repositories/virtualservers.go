@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
+	"fmt"
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/change"
 	"github.com/The127/Keyline/utils"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -18,8 +21,151 @@ const (
 	VirtualServerChangeRequireEmailVerification
 	VirtualServerChangePrimarySigningAlgorithm
 	VirtualServerChangeAdditionalSigningAlgorithms
+	VirtualServerChangeKeyRotationPolicy
+	VirtualServerChangePasskeyPolicy
+	VirtualServerChangeLockoutPolicy
+	VirtualServerChangeEnableEmailLogin
+	VirtualServerChangeTrustedDeviceDays
+	VirtualServerChangeMfaRules
 )
 
+// KeyRotationPolicy controls the lifetime of the signing keys of a virtual
+// server. A key signs tokens for RotateAfter and stays published in the JWKS
+// until ExpireAfter so that tokens it signed can still be verified. Its
+// successor is published PrePublishLead before it takes over, giving relying
+// parties time to pick it up.
+type KeyRotationPolicy struct {
+	RotateAfter    time.Duration
+	ExpireAfter    time.Duration
+	PrePublishLead time.Duration
+}
+
+func DefaultKeyRotationPolicy() KeyRotationPolicy {
+	return KeyRotationPolicy{
+		RotateAfter:    time.Hour * 24 * 20,
+		ExpireAfter:    time.Hour * 24 * 30,
+		PrePublishLead: 0,
+	}
+}
+
+// Validate makes sure retired keys outlive the tokens they signed and that
+// the successor is not published before the current key became active.
+func (p KeyRotationPolicy) Validate() error {
+	if p.RotateAfter <= 0 {
+		return fmt.Errorf("rotate after must be positive: %w", utils.ErrHttpBadRequest)
+	}
+	if p.ExpireAfter <= p.RotateAfter {
+		return fmt.Errorf("expire after must be longer than rotate after: %w", utils.ErrHttpBadRequest)
+	}
+	if p.PrePublishLead < 0 || p.PrePublishLead >= p.RotateAfter {
+		return fmt.Errorf("pre-publish lead must be between zero and rotate after: %w", utils.ErrHttpBadRequest)
+	}
+	return nil
+}
+
+type PasskeyUserVerification string
+
+const (
+	PasskeyUserVerificationRequired    PasskeyUserVerification = "required"
+	PasskeyUserVerificationPreferred   PasskeyUserVerification = "preferred"
+	PasskeyUserVerificationDiscouraged PasskeyUserVerification = "discouraged"
+)
+
+// PasskeyPolicy restricts the authenticators users of a virtual server can
+// register passkeys with. Attested registrations are verified against the
+// trust anchors of the FIDO metadata service. Only attested AAGUIDs can be
+// trusted, so a non-empty AllowedAaguids implies RequireAttestation.
+type PasskeyPolicy struct {
+	AllowedAaguids     []uuid.UUID
+	RequireAttestation bool
+	UserVerification   PasskeyUserVerification
+}
+
+func DefaultPasskeyPolicy() PasskeyPolicy {
+	return PasskeyPolicy{
+		AllowedAaguids:     []uuid.UUID{},
+		RequireAttestation: false,
+		UserVerification:   PasskeyUserVerificationPreferred,
+	}
+}
+
+func (p PasskeyPolicy) Validate() error {
+	switch p.UserVerification {
+	case PasskeyUserVerificationRequired, PasskeyUserVerificationPreferred, PasskeyUserVerificationDiscouraged:
+		return nil
+	default:
+		return fmt.Errorf("unknown user verification %q: %w", p.UserVerification, utils.ErrHttpBadRequest)
+	}
+}
+
+// AttestationRequired reports whether registrations need an attestation
+// that chains to a trust anchor.
+func (p PasskeyPolicy) AttestationRequired() bool {
+	return p.RequireAttestation || len(p.AllowedAaguids) > 0
+}
+
+// AllowsAaguid reports whether authenticators with the given AAGUID may be
+// used, an empty allow-list allows all of them.
+func (p PasskeyPolicy) AllowsAaguid(aaguid uuid.UUID) bool {
+	return len(p.AllowedAaguids) == 0 || slices.Contains(p.AllowedAaguids, aaguid)
+}
+
+func (p PasskeyPolicy) Equal(other PasskeyPolicy) bool {
+	return slices.Equal(p.AllowedAaguids, other.AllowedAaguids) &&
+		p.RequireAttestation == other.RequireAttestation &&
+		p.UserVerification == other.UserVerification
+}
+
+// LockoutPolicy throttles password guessing across login sessions. Failed
+// password attempts are counted per user and per source IP. A user is
+// locked out for LockoutDuration after MaxFailedAttempts, every further
+// lockout doubles the duration up to MaxLockoutDuration. The
+// PermanentLockoutAfter-th lockout is permanent, the user then stays
+// locked until an admin unlocks them. Zero values disable the respective
+// limit.
+type LockoutPolicy struct {
+	MaxFailedAttempts      int
+	MaxFailedAttemptsPerIp int
+	LockoutDuration        time.Duration
+	MaxLockoutDuration     time.Duration
+	PermanentLockoutAfter  int
+	NotifyUser             bool
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailedAttempts:      0,
+		MaxFailedAttemptsPerIp: 0,
+		LockoutDuration:        time.Minute,
+		MaxLockoutDuration:     time.Hour,
+		PermanentLockoutAfter:  0,
+		NotifyUser:             false,
+	}
+}
+
+func (p LockoutPolicy) Validate() error {
+	if p.MaxFailedAttempts < 0 || p.MaxFailedAttemptsPerIp < 0 || p.PermanentLockoutAfter < 0 {
+		return fmt.Errorf("lockout thresholds must not be negative: %w", utils.ErrHttpBadRequest)
+	}
+	if p.LockoutDuration <= 0 {
+		return fmt.Errorf("lockout duration must be positive: %w", utils.ErrHttpBadRequest)
+	}
+	if p.MaxLockoutDuration < p.LockoutDuration {
+		return fmt.Errorf("max lockout duration must not be shorter than the lockout duration: %w", utils.ErrHttpBadRequest)
+	}
+	return nil
+}
+
+// LockoutDurationFor returns how long the given lockout of a user lasts,
+// starting with 1 for the first one.
+func (p LockoutPolicy) LockoutDurationFor(lockout int) time.Duration {
+	duration := p.LockoutDuration
+	for i := 1; i < lockout && duration < p.MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, p.MaxLockoutDuration)
+}
+
 type VirtualServer struct {
 	BaseModel
 	change.List[VirtualServerChange]
@@ -30,9 +176,16 @@ type VirtualServer struct {
 	enableRegistration       bool
 	require2fa               bool
 	requireEmailVerification bool
+	enableEmailLogin         bool
+	trustedDeviceDays        int
+	mfaRules                 MfaRules
 
 	primarySigningAlgorithm     config.SigningAlgorithm
 	additionalSigningAlgorithms []config.SigningAlgorithm
+
+	keyRotationPolicy KeyRotationPolicy
+	passkeyPolicy     PasskeyPolicy
+	lockoutPolicy     LockoutPolicy
 }
 
 func NewVirtualServer(name string, displayName string) *VirtualServer {
@@ -42,10 +195,14 @@ func NewVirtualServer(name string, displayName string) *VirtualServer {
 		name:               name,
 		displayName:        displayName,
 		enableRegistration: false,
+		keyRotationPolicy:  DefaultKeyRotationPolicy(),
+		passkeyPolicy:      DefaultPasskeyPolicy(),
+		lockoutPolicy:      DefaultLockoutPolicy(),
+		mfaRules:           MfaRules{},
 	}
 }
 
-func NewVirtualServerFromDB(base BaseModel, name string, displayName string, enableRegistration bool, require2fa bool, requireEmailVerification bool, primarySigningAlgorithm string, additionalSigningAlgorithms []string) *VirtualServer {
+func NewVirtualServerFromDB(base BaseModel, name string, displayName string, enableRegistration bool, require2fa bool, requireEmailVerification bool, enableEmailLogin bool, trustedDeviceDays int, mfaRules MfaRules, primarySigningAlgorithm string, additionalSigningAlgorithms []string, keyRotationPolicy KeyRotationPolicy, passkeyPolicy PasskeyPolicy, lockoutPolicy LockoutPolicy) *VirtualServer {
 	additional := make([]config.SigningAlgorithm, len(additionalSigningAlgorithms))
 	for i, a := range additionalSigningAlgorithms {
 		additional[i] = config.SigningAlgorithm(a)
@@ -58,8 +215,14 @@ func NewVirtualServerFromDB(base BaseModel, name string, displayName string, ena
 		enableRegistration:          enableRegistration,
 		require2fa:                  require2fa,
 		requireEmailVerification:    requireEmailVerification,
+		enableEmailLogin:            enableEmailLogin,
+		trustedDeviceDays:           trustedDeviceDays,
+		mfaRules:                    mfaRules,
 		primarySigningAlgorithm:     config.SigningAlgorithm(primarySigningAlgorithm),
 		additionalSigningAlgorithms: additional,
+		keyRotationPolicy:           keyRotationPolicy,
+		passkeyPolicy:               passkeyPolicy,
+		lockoutPolicy:               lockoutPolicy,
 	}
 }
 
@@ -119,6 +282,47 @@ func (m *VirtualServer) SetRequireEmailVerification(requireEmailVerification boo
 	m.TrackChange(VirtualServerChangeRequireEmailVerification)
 }
 
+// EnableEmailLogin offers to sign in with a link or code sent by email
+// instead of the password.
+func (m *VirtualServer) EnableEmailLogin() bool {
+	return m.enableEmailLogin
+}
+
+func (m *VirtualServer) SetEnableEmailLogin(enableEmailLogin bool) {
+	if m.enableEmailLogin == enableEmailLogin {
+		return
+	}
+
+	m.enableEmailLogin = enableEmailLogin
+	m.TrackChange(VirtualServerChangeEnableEmailLogin)
+}
+
+// TrustedDeviceDays is how long a browser the user chose to trust skips the
+// second factor, 0 disables trusting browsers.
+func (m *VirtualServer) TrustedDeviceDays() int {
+	return m.trustedDeviceDays
+}
+
+func (m *VirtualServer) SetTrustedDeviceDays(trustedDeviceDays int) {
+	if m.trustedDeviceDays == trustedDeviceDays {
+		return
+	}
+
+	m.trustedDeviceDays = trustedDeviceDays
+	m.TrackChange(VirtualServerChangeTrustedDeviceDays)
+}
+
+// MfaRules decide per login whether a second factor is needed, Require2fa
+// applies to the logins no rule matches.
+func (m *VirtualServer) MfaRules() MfaRules {
+	return m.mfaRules
+}
+
+func (m *VirtualServer) SetMfaRules(mfaRules MfaRules) {
+	m.mfaRules = mfaRules
+	m.TrackChange(VirtualServerChangeMfaRules)
+}
+
 func (m *VirtualServer) PrimarySigningAlgorithm() config.SigningAlgorithm {
 	return m.primarySigningAlgorithm
 }
@@ -140,6 +344,42 @@ func (m *VirtualServer) SetAdditionalSigningAlgorithms(algs []config.SigningAlgo
 	m.TrackChange(VirtualServerChangeAdditionalSigningAlgorithms)
 }
 
+func (m *VirtualServer) KeyRotationPolicy() KeyRotationPolicy {
+	return m.keyRotationPolicy
+}
+
+func (m *VirtualServer) SetKeyRotationPolicy(policy KeyRotationPolicy) {
+	if m.keyRotationPolicy == policy {
+		return
+	}
+	m.keyRotationPolicy = policy
+	m.TrackChange(VirtualServerChangeKeyRotationPolicy)
+}
+
+func (m *VirtualServer) PasskeyPolicy() PasskeyPolicy {
+	return m.passkeyPolicy
+}
+
+func (m *VirtualServer) SetPasskeyPolicy(policy PasskeyPolicy) {
+	if m.passkeyPolicy.Equal(policy) {
+		return
+	}
+	m.passkeyPolicy = policy
+	m.TrackChange(VirtualServerChangePasskeyPolicy)
+}
+
+func (m *VirtualServer) LockoutPolicy() LockoutPolicy {
+	return m.lockoutPolicy
+}
+
+func (m *VirtualServer) SetLockoutPolicy(policy LockoutPolicy) {
+	if m.lockoutPolicy == policy {
+		return
+	}
+	m.lockoutPolicy = policy
+	m.TrackChange(VirtualServerChangeLockoutPolicy)
+}
+
 func (m *VirtualServer) HasSigningAlgorithm(alg config.SigningAlgorithm) bool {
 	for _, a := range m.AllSigningAlgorithms() {
 		if a == alg {
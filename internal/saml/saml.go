@@ -0,0 +1,670 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/utils"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	NamespaceProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	NamespaceAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	NamespaceMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	NamespaceDsig      = "http://www.w3.org/2000/09/xmldsig#"
+
+	BindingHttpRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHttpPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	NameIdFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	NameIdFormatPersistent  = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+
+	StatusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	StatusRequester = "urn:oasis:names:tc:SAML:2.0:status:Requester"
+
+	AuthnContextPasswordProtectedTransport = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+
+	attributeNameFormatBasic  = "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+	subjectConfirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// clockSkew is subtracted from NotBefore so that service providers with
+	// slightly drifting clocks still accept freshly issued assertions, and
+	// is allowed on the timestamps of their requests the same way.
+	clockSkew = 30 * time.Second
+
+	// requestValidity is how long after its IssueInstant a request of a
+	// service provider is accepted.
+	requestValidity = 5 * time.Minute
+)
+
+type AuthnRequest struct {
+	XMLName                     xml.Name  `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string    `xml:"ID,attr"`
+	Version                     string    `xml:"Version,attr"`
+	IssueInstant                time.Time `xml:"IssueInstant,attr"`
+	Destination                 string    `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string    `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string    `xml:"ProtocolBinding,attr"`
+	ForceAuthn                  bool      `xml:"ForceAuthn,attr"`
+	IsPassive                   bool      `xml:"IsPassive,attr"`
+	Issuer                      string    `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameIDPolicy                *struct {
+		Format string `xml:"Format,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+}
+
+type LogoutRequest struct {
+	XMLName      xml.Name  `xml:"urn:oasis:names:tc:SAML:2.0:protocol LogoutRequest"`
+	ID           string    `xml:"ID,attr"`
+	Version      string    `xml:"Version,attr"`
+	IssueInstant time.Time `xml:"IssueInstant,attr"`
+	NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+	Destination  string    `xml:"Destination,attr"`
+	Issuer       string    `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameID       string    `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+	SessionIndex []string  `xml:"urn:oasis:names:tc:SAML:2.0:protocol SessionIndex"`
+}
+
+// DecodeRedirect decodes a message sent with the HTTP-Redirect binding
+// (base64 encoded, raw DEFLATE compressed).
+func DecodeRedirect(value string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64: %w", err)
+	}
+
+	// closing fails on corrupt streams, the read below already reports that
+	reader := flate.NewReader(bytes.NewReader(compressed))
+	defer reader.Close() //nolint:errcheck
+
+	// limit the inflated size, saml requests are small and we do not want to
+	// be an easy target for decompression bombs
+	data, err := io.ReadAll(io.LimitReader(reader, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("inflating: %w", err)
+	}
+
+	return data, nil
+}
+
+// EncodeRedirect encodes a message for the HTTP-Redirect binding.
+func EncodeRedirect(data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("creating flate writer: %w", err)
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		return "", fmt.Errorf("deflating: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("closing flate writer: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodePost decodes a message sent with the HTTP-POST binding.
+func DecodePost(value string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64: %w", err)
+	}
+	return data, nil
+}
+
+func ParseAuthnRequest(data []byte) (*AuthnRequest, error) {
+	var request AuthnRequest
+	err := xml.Unmarshal(data, &request)
+	if err != nil {
+		return nil, fmt.Errorf("parsing authn request: %w", err)
+	}
+
+	if request.ID == "" {
+		return nil, fmt.Errorf("authn request has no id")
+	}
+
+	if request.Version != "2.0" {
+		return nil, fmt.Errorf("unsupported saml version %q", request.Version)
+	}
+
+	return &request, nil
+}
+
+func ParseLogoutRequest(data []byte) (*LogoutRequest, error) {
+	var request LogoutRequest
+	err := xml.Unmarshal(data, &request)
+	if err != nil {
+		return nil, fmt.Errorf("parsing logout request: %w", err)
+	}
+
+	if request.ID == "" {
+		return nil, fmt.Errorf("logout request has no id")
+	}
+
+	if request.Version != "2.0" {
+		return nil, fmt.Errorf("unsupported saml version %q", request.Version)
+	}
+
+	return &request, nil
+}
+
+// Validate checks that the logout request is addressed to the given single
+// logout url and is still fresh, so that a captured request can neither be
+// replayed later nor be sent to another identity provider.
+func (r *LogoutRequest) Validate(destination string, now time.Time) error {
+	if r.Destination != destination {
+		return fmt.Errorf("logout request is addressed to %q", r.Destination)
+	}
+
+	if r.IssueInstant.IsZero() {
+		return fmt.Errorf("logout request has no issue instant")
+	}
+	if r.IssueInstant.After(now.Add(clockSkew)) {
+		return fmt.Errorf("logout request was issued in the future")
+	}
+	if now.After(r.IssueInstant.Add(requestValidity + clockSkew)) {
+		return fmt.Errorf("logout request has expired")
+	}
+	if !r.NotOnOrAfter.IsZero() && !now.Before(r.NotOnOrAfter.Add(clockSkew)) {
+		return fmt.Errorf("logout request has expired")
+	}
+
+	return nil
+}
+
+// ParseCertificate parses the PEM encoded signing certificate of a service
+// provider.
+func ParseCertificate(value string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("expected a pem encoded certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	return certificate, nil
+}
+
+// redirectSignatureAlgorithms maps the SigAlg values of the HTTP-Redirect
+// binding to the algorithms we accept, sha1 is deliberately missing.
+var redirectSignatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":   x509.SHA256WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":   x509.SHA384WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":   x509.SHA512WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256": x509.ECDSAWithSHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384": x509.ECDSAWithSHA384,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512": x509.ECDSAWithSHA512,
+}
+
+// VerifyRedirectSignature checks the signature of a message sent with the
+// HTTP-Redirect binding. The signature covers the query parameters as the
+// sender encoded them, so it has to be checked against the raw query.
+func VerifyRedirectSignature(rawQuery string, field string, certificate *x509.Certificate) error {
+	params := map[string]string{}
+	for _, part := range strings.Split(rawQuery, "&") {
+		name, value, _ := strings.Cut(part, "=")
+		if _, ok := params[name]; ok {
+			return fmt.Errorf("duplicate query parameter %s", name)
+		}
+		params[name] = value
+	}
+
+	message, ok := params[field]
+	if !ok {
+		return fmt.Errorf("missing %s", field)
+	}
+
+	rawSigAlg, ok := params["SigAlg"]
+	if !ok {
+		return fmt.Errorf("message is not signed")
+	}
+
+	sigAlg, err := url.QueryUnescape(rawSigAlg)
+	if err != nil {
+		return fmt.Errorf("decoding SigAlg: %w", err)
+	}
+
+	algorithm, ok := redirectSignatureAlgorithms[sigAlg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %q", sigAlg)
+	}
+
+	rawSignature, err := url.QueryUnescape(params["Signature"])
+	if err != nil {
+		return fmt.Errorf("decoding Signature: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(rawSignature)
+	if err != nil {
+		return fmt.Errorf("decoding Signature: %w", err)
+	}
+
+	signed := field + "=" + message
+	if relayState, ok := params["RelayState"]; ok {
+		signed += "&RelayState=" + relayState
+	}
+	signed += "&SigAlg=" + rawSigAlg
+
+	err = certificate.CheckSignature(algorithm, []byte(signed), signature)
+	if err != nil {
+		return fmt.Errorf("verifying signature: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyPostSignature checks the enveloped signature of a message sent with
+// the HTTP-POST binding. It returns only the signed content, so that nothing
+// outside of the signature is ever parsed.
+func VerifyPostSignature(data []byte, certificate *x509.Certificate) ([]byte, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing xml: %w", err)
+	}
+
+	root := doc.Root()
+	if root == nil {
+		return nil, fmt.Errorf("message is empty")
+	}
+
+	validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{certificate},
+	})
+
+	validated, err := validationContext.Validate(root)
+	if err != nil {
+		return nil, fmt.Errorf("verifying signature: %w", err)
+	}
+
+	return serialize(validated)
+}
+
+// NewId returns a random identifier usable as an xs:ID. IDs must not start
+// with a digit, hence the prefix.
+func NewId() string {
+	return "_" + hex.EncodeToString(utils.GetSecureRandomBytes(20))
+}
+
+// Certificate returns a DER encoded self-signed certificate for the given
+// RSA key. Service providers expect the signing key to be wrapped in a
+// certificate, so we derive one from the key material. The certificate is
+// deterministic for a given key and validity window, so the metadata stays
+// stable across requests and instances.
+func Certificate(commonName string, signer crypto.Signer, notBefore time.Time, notAfter time.Time) ([]byte, error) {
+	publicKey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("saml signing requires an rsa key, got %T", signer.Public())
+	}
+
+	serialHash := sha256.Sum256(publicKey.N.Bytes())
+	serial := new(big.Int).SetBytes(serialHash[:16])
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		Issuer:                pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore.UTC().Truncate(time.Second),
+		NotAfter:              notAfter.UTC().Truncate(time.Second),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(nil, template, template, publicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate: %w", err)
+	}
+
+	return der, nil
+}
+
+type Signer struct {
+	signer      crypto.Signer
+	certificate []byte
+}
+
+func NewSigner(signer crypto.Signer, certificate []byte) *Signer {
+	return &Signer{
+		signer:      signer,
+		certificate: certificate,
+	}
+}
+
+func (s *Signer) Certificate() []byte {
+	return s.certificate
+}
+
+// sign signs the element with an enveloped signature and moves the
+// signature directly after the Issuer element as required by the schema.
+func (s *Signer) sign(el *etree.Element) (*etree.Element, error) {
+	signingContext, err := dsig.NewSigningContext(s.signer, [][]byte{s.certificate})
+	if err != nil {
+		return nil, fmt.Errorf("creating signing context: %w", err)
+	}
+
+	// exclusive canonicalization is what service providers expect, and it
+	// keeps the signature valid once the assertion is embedded in a response
+	signingContext.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	err = signingContext.SetSignatureMethod(dsig.RSASHA256SignatureMethod)
+	if err != nil {
+		return nil, fmt.Errorf("setting signature method: %w", err)
+	}
+
+	signed, err := signingContext.SignEnveloped(el)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+
+	// SignEnveloped appends the signature to the child slice without
+	// parenting it, so it has to be moved by hand
+	signature := signed.Child[len(signed.Child)-1]
+	signed.Child = signed.Child[:len(signed.Child)-1]
+	signed.InsertChildAt(1, signature)
+
+	return signed, nil
+}
+
+type ResponseParams struct {
+	IdpEntityId  string
+	SpEntityId   string
+	Destination  string
+	InResponseTo string
+
+	NameId       string
+	NameIdFormat string
+	SessionIndex string
+
+	IssuedAt     time.Time
+	AuthnInstant time.Time
+	Validity     time.Duration
+
+	Attributes map[string]any
+}
+
+// BuildResponse creates a samlp:Response containing a single signed
+// assertion.
+func (s *Signer) BuildResponse(params ResponseParams) ([]byte, error) {
+	issueInstant := formatTime(params.IssuedAt)
+	notBefore := formatTime(params.IssuedAt.Add(-clockSkew))
+	notOnOrAfter := formatTime(params.IssuedAt.Add(params.Validity))
+
+	nameIdFormat := params.NameIdFormat
+	if nameIdFormat == "" {
+		nameIdFormat = NameIdFormatUnspecified
+	}
+
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", NamespaceAssertion)
+	assertion.CreateAttr("ID", NewId())
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", issueInstant)
+	assertion.CreateElement("saml:Issuer").SetText(params.IdpEntityId)
+
+	subject := assertion.CreateElement("saml:Subject")
+	nameId := subject.CreateElement("saml:NameID")
+	nameId.CreateAttr("Format", nameIdFormat)
+	nameId.CreateAttr("SPNameQualifier", params.SpEntityId)
+	nameId.SetText(params.NameId)
+
+	subjectConfirmation := subject.CreateElement("saml:SubjectConfirmation")
+	subjectConfirmation.CreateAttr("Method", subjectConfirmationBearer)
+	subjectConfirmationData := subjectConfirmation.CreateElement("saml:SubjectConfirmationData")
+	if params.InResponseTo != "" {
+		subjectConfirmationData.CreateAttr("InResponseTo", params.InResponseTo)
+	}
+	subjectConfirmationData.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	subjectConfirmationData.CreateAttr("Recipient", params.Destination)
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", notBefore)
+	conditions.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	conditions.CreateElement("saml:AudienceRestriction").
+		CreateElement("saml:Audience").
+		SetText(params.SpEntityId)
+
+	authnStatement := assertion.CreateElement("saml:AuthnStatement")
+	authnStatement.CreateAttr("AuthnInstant", formatTime(params.AuthnInstant))
+	authnStatement.CreateAttr("SessionIndex", params.SessionIndex)
+	authnStatement.CreateElement("saml:AuthnContext").
+		CreateElement("saml:AuthnContextClassRef").
+		SetText(AuthnContextPasswordProtectedTransport)
+
+	if len(params.Attributes) > 0 {
+		attributeStatement := assertion.CreateElement("saml:AttributeStatement")
+		addAttributes(attributeStatement, params.Attributes)
+	}
+
+	signedAssertion, err := s.sign(assertion)
+	if err != nil {
+		return nil, fmt.Errorf("signing assertion: %w", err)
+	}
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", NamespaceProtocol)
+	response.CreateAttr("xmlns:saml", NamespaceAssertion)
+	response.CreateAttr("ID", NewId())
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", issueInstant)
+	response.CreateAttr("Destination", params.Destination)
+	if params.InResponseTo != "" {
+		response.CreateAttr("InResponseTo", params.InResponseTo)
+	}
+	response.CreateElement("saml:Issuer").SetText(params.IdpEntityId)
+	response.CreateElement("samlp:Status").
+		CreateElement("samlp:StatusCode").
+		CreateAttr("Value", StatusSuccess)
+	response.AddChild(signedAssertion)
+
+	return serialize(response)
+}
+
+type LogoutResponseParams struct {
+	IdpEntityId  string
+	Destination  string
+	InResponseTo string
+	IssuedAt     time.Time
+	Status       string
+}
+
+// BuildLogoutResponse creates a signed samlp:LogoutResponse.
+func (s *Signer) BuildLogoutResponse(params LogoutResponseParams) ([]byte, error) {
+	status := params.Status
+	if status == "" {
+		status = StatusSuccess
+	}
+
+	response := etree.NewElement("samlp:LogoutResponse")
+	response.CreateAttr("xmlns:samlp", NamespaceProtocol)
+	response.CreateAttr("xmlns:saml", NamespaceAssertion)
+	response.CreateAttr("ID", NewId())
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", formatTime(params.IssuedAt))
+	response.CreateAttr("Destination", params.Destination)
+	response.CreateAttr("InResponseTo", params.InResponseTo)
+	response.CreateElement("saml:Issuer").SetText(params.IdpEntityId)
+	response.CreateElement("samlp:Status").
+		CreateElement("samlp:StatusCode").
+		CreateAttr("Value", status)
+
+	signed, err := s.sign(response)
+	if err != nil {
+		return nil, fmt.Errorf("signing logout response: %w", err)
+	}
+
+	return serialize(signed)
+}
+
+type MetadataParams struct {
+	EntityId   string
+	SsoUrl     string
+	SloUrl     string
+	ValidUntil time.Time
+}
+
+// BuildMetadata creates the IdP EntityDescriptor advertising the signing
+// certificate and the supported bindings.
+func (s *Signer) BuildMetadata(params MetadataParams) ([]byte, error) {
+	entityDescriptor := etree.NewElement("md:EntityDescriptor")
+	entityDescriptor.CreateAttr("xmlns:md", NamespaceMetadata)
+	entityDescriptor.CreateAttr("xmlns:ds", NamespaceDsig)
+	entityDescriptor.CreateAttr("entityID", params.EntityId)
+	entityDescriptor.CreateAttr("validUntil", formatTime(params.ValidUntil))
+
+	idpDescriptor := entityDescriptor.CreateElement("md:IDPSSODescriptor")
+	idpDescriptor.CreateAttr("protocolSupportEnumeration", NamespaceProtocol)
+	idpDescriptor.CreateAttr("WantAuthnRequestsSigned", "false")
+
+	keyDescriptor := idpDescriptor.CreateElement("md:KeyDescriptor")
+	keyDescriptor.CreateAttr("use", "signing")
+	keyDescriptor.CreateElement("ds:KeyInfo").
+		CreateElement("ds:X509Data").
+		CreateElement("ds:X509Certificate").
+		SetText(base64.StdEncoding.EncodeToString(s.certificate))
+
+	for _, binding := range []string{BindingHttpRedirect, BindingHttpPost} {
+		sloService := idpDescriptor.CreateElement("md:SingleLogoutService")
+		sloService.CreateAttr("Binding", binding)
+		sloService.CreateAttr("Location", params.SloUrl)
+	}
+
+	idpDescriptor.CreateElement("md:NameIDFormat").SetText(NameIdFormatPersistent)
+	idpDescriptor.CreateElement("md:NameIDFormat").SetText(NameIdFormatUnspecified)
+
+	for _, binding := range []string{BindingHttpRedirect, BindingHttpPost} {
+		ssoService := idpDescriptor.CreateElement("md:SingleSignOnService")
+		ssoService.CreateAttr("Binding", binding)
+		ssoService.CreateAttr("Location", params.SsoUrl)
+	}
+
+	return serialize(entityDescriptor)
+}
+
+func addAttributes(parent *etree.Element, attributes map[string]any) {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := attributeValues(attributes[name])
+		if len(values) == 0 {
+			continue
+		}
+
+		attribute := parent.CreateElement("saml:Attribute")
+		attribute.CreateAttr("Name", name)
+		attribute.CreateAttr("NameFormat", attributeNameFormatBasic)
+		for _, value := range values {
+			attribute.CreateElement("saml:AttributeValue").SetText(value)
+		}
+	}
+}
+
+// attributeValues flattens a mapped claim into saml attribute values. Lists
+// become multi-valued attributes, objects are serialized as json.
+func attributeValues(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, attributeValues(item)...)
+		}
+		return result
+	case map[string]any:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return []string{string(encoded)}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func serialize(el *etree.Element) ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+	doc.WriteSettings.CanonicalEndTags = true
+
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("serializing xml: %w", err)
+	}
+
+	return data, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+var postFormTemplate = template.Must(template.New("saml-post").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Redirecting...</title></head>
+<body onload="document.forms[0].submit()">
+<noscript><p>JavaScript is disabled. Click the button below to continue.</p></noscript>
+<form method="post" action="{{.Url}}">
+<input type="hidden" name="{{.FieldName}}" value="{{.Value}}">
+{{if .RelayState}}<input type="hidden" name="RelayState" value="{{.RelayState}}">{{end}}
+<noscript><input type="submit" value="Continue"></noscript>
+</form>
+</body>
+</html>`))
+
+// WritePostForm delivers a message via the HTTP-POST binding by rendering an
+// auto-submitting html form.
+func WritePostForm(w http.ResponseWriter, url string, fieldName string, message []byte, relayState string) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.WriteHeader(http.StatusOK)
+
+	return postFormTemplate.Execute(w, struct {
+		Url        string
+		FieldName  string
+		Value      string
+		RelayState string
+	}{
+		Url:        url,
+		FieldName:  fieldName,
+		Value:      base64.StdEncoding.EncodeToString(message),
+		RelayState: relayState,
+	})
+}
@@ -0,0 +1,367 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Now()
+	certificate, err := Certificate("https://idp.example.com/saml/test", privateKey, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+
+	return NewSigner(privateKey, certificate)
+}
+
+func validate(t *testing.T, signer *Signer, el *etree.Element) *etree.Element {
+	t.Helper()
+
+	certificate, err := x509.ParseCertificate(signer.Certificate())
+	require.NoError(t, err)
+
+	validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{certificate},
+	})
+
+	validated, err := validationContext.Validate(el)
+	require.NoError(t, err)
+	return validated
+}
+
+func TestRedirectEncodingRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	message := []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_1" Version="2.0"/>`)
+
+	// act
+	encoded, err := EncodeRedirect(message)
+	require.NoError(t, err)
+	decoded, err := DecodeRedirect(encoded)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, message, decoded)
+}
+
+func TestDecodeRedirectRejectsCorruptInput(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	encoded := base64.StdEncoding.EncodeToString([]byte("not a deflate stream"))
+
+	// act
+	decoded, err := DecodeRedirect(encoded)
+
+	// assert
+	require.Error(t, err)
+	require.Nil(t, decoded)
+}
+
+func TestParseAuthnRequest(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	data := []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_abc" Version="2.0" IssueInstant="2025-01-01T00:00:00Z" AssertionConsumerServiceURL="https://sp.example.com/acs"><saml:Issuer>https://sp.example.com</saml:Issuer></samlp:AuthnRequest>`)
+
+	// act
+	request, err := ParseAuthnRequest(data)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "_abc", request.ID)
+	require.Equal(t, "https://sp.example.com", request.Issuer)
+	require.Equal(t, "https://sp.example.com/acs", request.AssertionConsumerServiceURL)
+}
+
+func TestParseAuthnRequestRejectsWrongVersion(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	data := []byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_abc" Version="1.1"/>`)
+
+	// act
+	_, err := ParseAuthnRequest(data)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestBuildResponseSignsAssertion(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+
+	// act
+	response, err := signer.BuildResponse(ResponseParams{
+		IdpEntityId:  "https://idp.example.com/saml/test",
+		SpEntityId:   "https://sp.example.com",
+		Destination:  "https://sp.example.com/acs",
+		InResponseTo: "_request",
+		NameId:       "user-id",
+		NameIdFormat: NameIdFormatPersistent,
+		SessionIndex: "session-id",
+		IssuedAt:     time.Now(),
+		AuthnInstant: time.Now(),
+		Validity:     5 * time.Minute,
+		Attributes: map[string]any{
+			"roles": []any{"admin", "user"},
+			"email": "user@example.com",
+		},
+	})
+	require.NoError(t, err)
+
+	// assert
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(response))
+	require.Equal(t, "_request", doc.Root().SelectAttrValue("InResponseTo", ""))
+
+	assertion := doc.Root().FindElement("./Assertion")
+	require.NotNil(t, assertion)
+
+	// the signature has to follow the issuer directly
+	require.Equal(t, "Signature", assertion.ChildElements()[1].Tag)
+
+	validated := validate(t, signer, assertion)
+	require.Equal(t, "user-id", validated.FindElement("./Subject/NameID").Text())
+	require.Len(t, validated.FindElements("./AttributeStatement/Attribute[@Name='roles']/AttributeValue"), 2)
+}
+
+func TestBuildLogoutResponseIsSigned(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+
+	// act
+	response, err := signer.BuildLogoutResponse(LogoutResponseParams{
+		IdpEntityId:  "https://idp.example.com/saml/test",
+		Destination:  "https://sp.example.com/slo",
+		InResponseTo: "_logout",
+		IssuedAt:     time.Now(),
+	})
+	require.NoError(t, err)
+
+	// assert
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(response))
+	validated := validate(t, signer, doc.Root())
+	require.Equal(t, StatusSuccess, validated.FindElement("./Status/StatusCode").SelectAttrValue("Value", ""))
+}
+
+func TestCertificateIsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	notBefore := time.Now()
+	notAfter := notBefore.Add(time.Hour)
+
+	// act
+	first, err := Certificate("cn", privateKey, notBefore, notAfter)
+	require.NoError(t, err)
+	second, err := Certificate("cn", privateKey, notBefore, notAfter)
+	require.NoError(t, err)
+
+	// assert
+	require.Equal(t, first, second)
+}
+
+const testLogoutRequest = `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_logout" Version="2.0" IssueInstant="2025-01-01T00:00:00Z"><saml:Issuer>https://sp.example.com</saml:Issuer><saml:NameID>user-id</saml:NameID><samlp:SessionIndex>session-id</samlp:SessionIndex></samlp:LogoutRequest>`
+
+func redirectQuery(t *testing.T, signer *Signer, relayState string) string {
+	t.Helper()
+
+	encoded, err := EncodeRedirect([]byte(testLogoutRequest))
+	require.NoError(t, err)
+
+	query := "SAMLRequest=" + url.QueryEscape(encoded) +
+		"&RelayState=" + url.QueryEscape(relayState) +
+		"&SigAlg=" + url.QueryEscape("http://www.w3.org/2001/04/xmldsig-more#rsa-sha256")
+
+	digest := sha256.Sum256([]byte(query))
+	signature, err := signer.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+
+	return query + "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+}
+
+func TestVerifyRedirectSignature(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+	certificate, err := x509.ParseCertificate(signer.Certificate())
+	require.NoError(t, err)
+	query := redirectQuery(t, signer, "state")
+
+	// act
+	err = VerifyRedirectSignature(query, "SAMLRequest", certificate)
+
+	// assert
+	require.NoError(t, err)
+}
+
+func TestVerifyRedirectSignatureRejectsTamperedQuery(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+	certificate, err := x509.ParseCertificate(signer.Certificate())
+	require.NoError(t, err)
+	query := strings.Replace(redirectQuery(t, signer, "state"), "RelayState=state", "RelayState=other", 1)
+
+	// act
+	err = VerifyRedirectSignature(query, "SAMLRequest", certificate)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestVerifyRedirectSignatureRejectsUnsignedQuery(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+	certificate, err := x509.ParseCertificate(signer.Certificate())
+	require.NoError(t, err)
+	encoded, err := EncodeRedirect([]byte(testLogoutRequest))
+	require.NoError(t, err)
+
+	// act
+	err = VerifyRedirectSignature("SAMLRequest="+url.QueryEscape(encoded), "SAMLRequest", certificate)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestVerifyPostSignature(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+	certificate, err := x509.ParseCertificate(signer.Certificate())
+	require.NoError(t, err)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(testLogoutRequest))
+	signed, err := signer.sign(doc.Root())
+	require.NoError(t, err)
+	data, err := serialize(signed)
+	require.NoError(t, err)
+
+	// act
+	verified, err := VerifyPostSignature(data, certificate)
+
+	// assert
+	require.NoError(t, err)
+	request, err := ParseLogoutRequest(verified)
+	require.NoError(t, err)
+	require.Equal(t, "https://sp.example.com", request.Issuer)
+	require.Equal(t, []string{"session-id"}, request.SessionIndex)
+}
+
+func TestVerifyPostSignatureRejectsOtherCertificate(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	signer := newTestSigner(t)
+	other, err := x509.ParseCertificate(newTestSigner(t).Certificate())
+	require.NoError(t, err)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(testLogoutRequest))
+	signed, err := signer.sign(doc.Root())
+	require.NoError(t, err)
+	data, err := serialize(signed)
+	require.NoError(t, err)
+
+	// act
+	_, err = VerifyPostSignature(data, other)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestVerifyPostSignatureRejectsUnsignedMessage(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	certificate, err := x509.ParseCertificate(newTestSigner(t).Certificate())
+	require.NoError(t, err)
+
+	// act
+	_, err = VerifyPostSignature([]byte(testLogoutRequest), certificate)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestLogoutRequestValidate(t *testing.T) {
+	t.Parallel()
+
+	const sloUrl = "https://idp.example.com/saml/test/slo"
+	issuedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		request LogoutRequest
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "fresh", request: LogoutRequest{Destination: sloUrl, IssueInstant: issuedAt}, now: issuedAt.Add(time.Minute)},
+		{name: "other destination", request: LogoutRequest{Destination: "https://other.example.com/slo", IssueInstant: issuedAt}, now: issuedAt, wantErr: true},
+		{name: "no destination", request: LogoutRequest{IssueInstant: issuedAt}, now: issuedAt, wantErr: true},
+		{name: "no issue instant", request: LogoutRequest{Destination: sloUrl}, now: issuedAt, wantErr: true},
+		{name: "stale", request: LogoutRequest{Destination: sloUrl, IssueInstant: issuedAt}, now: issuedAt.Add(time.Hour), wantErr: true},
+		{name: "issued in the future", request: LogoutRequest{Destination: sloUrl, IssueInstant: issuedAt.Add(time.Hour)}, now: issuedAt, wantErr: true},
+		{name: "past not on or after", request: LogoutRequest{Destination: sloUrl, IssueInstant: issuedAt, NotOnOrAfter: issuedAt.Add(time.Minute)}, now: issuedAt.Add(2 * time.Minute), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.request.Validate(sloUrl, tt.now)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseLogoutRequestReadsNotOnOrAfter(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	data := strings.Replace(testLogoutRequest, `IssueInstant=`, `NotOnOrAfter="2025-01-01T00:05:00Z" IssueInstant=`, 1)
+
+	// act
+	request, err := ParseLogoutRequest([]byte(data))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 5, 0, 0, time.UTC), request.NotOnOrAfter.UTC())
+}
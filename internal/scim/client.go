@@ -0,0 +1,199 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxResponseSize bounds the responses read from a service provider.
+const maxResponseSize = 1 << 20
+
+var ErrUnexpectedResponse = errors.New("unexpected response from service provider")
+
+// Client pushes users to the SCIM endpoint of a downstream service provider.
+type Client struct {
+	// BaseUrl is the SCIM base URL, without the /Users suffix.
+	BaseUrl    string
+	HttpClient *http.Client
+	// Authorize adds the credentials to every request.
+	Authorize func(request *http.Request)
+}
+
+// ValidatePath reports whether path is an attribute path that BuildResource
+// can write.
+func ValidatePath(path string) error {
+	_, err := parsePath(path)
+	return err
+}
+
+// EqualFilter returns a filter matching resources whose attribute equals
+// value.
+func EqualFilter(attribute string, value string) string {
+	quoted, _ := json.Marshal(value)
+	return fmt.Sprintf("%s eq %s", attribute, quoted)
+}
+
+// BuildResource assembles a resource from attribute paths and their values.
+// Paths are applied in sorted order so that the result does not depend on
+// map iteration. Nil values are left out.
+func BuildResource(schema string, attributes map[string]any) (map[string]any, error) {
+	resource := map[string]any{
+		"schemas": []any{schema},
+	}
+
+	paths := make([]string, 0, len(attributes))
+	for path := range attributes {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		if attributes[path] == nil {
+			continue
+		}
+
+		value, err := json.Marshal(attributes[path])
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s: %w", path, err)
+		}
+
+		err = ApplyPatch(resource, []PatchOperation{{
+			Op:    "add",
+			Path:  path,
+			Value: value,
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("setting %s: %w", path, err)
+		}
+	}
+
+	return resource, nil
+}
+
+// FindUser returns the id of the first user matching filter, or an empty
+// string if there is none.
+func (c *Client) FindUser(ctx context.Context, filter string) (string, error) {
+	query := url.Values{
+		"filter":     {filter},
+		"attributes": {"id"},
+		"count":      {"1"},
+	}
+
+	var response ListResponse[struct {
+		Id string `json:"id"`
+	}]
+	err := c.do(ctx, http.MethodGet, "/Users?"+query.Encode(), nil, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.Resources) == 0 {
+		return "", nil
+	}
+	return response.Resources[0].Id, nil
+}
+
+// CreateUser creates the user and returns the id assigned by the service
+// provider.
+func (c *Client) CreateUser(ctx context.Context, resource map[string]any) (string, error) {
+	var created struct {
+		Id string `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/Users", resource, &created)
+	if err != nil {
+		return "", err
+	}
+	if created.Id == "" {
+		return "", fmt.Errorf("%w: created user has no id", ErrUnexpectedResponse)
+	}
+	return created.Id, nil
+}
+
+func (c *Client) ReplaceUser(ctx context.Context, id string, resource map[string]any) error {
+	resource["id"] = id
+	return c.do(ctx, http.MethodPut, "/Users/"+url.PathEscape(id), resource, nil)
+}
+
+// DeleteUser deletes the user. Users that are already gone are not an error.
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/Users/"+url.PathEscape(id), nil, nil)
+	var scimErr *Error
+	if errors.As(err, &scimErr) && scimErr.Status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseUrl, "/")+path, reader)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	request.Header.Set("Accept", ContentType)
+	if body != nil {
+		request.Header.Set("Content-Type", ContentType)
+	}
+	if c.Authorize != nil {
+		c.Authorize(request)
+	}
+
+	response, err := c.HttpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return remoteError(method, path, response.StatusCode, data)
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return fmt.Errorf("%w: decoding response: %w", ErrUnexpectedResponse, err)
+	}
+	return nil
+}
+
+// remoteError turns an error response into an *Error, keeping the detail
+// reported by the service provider if there is one.
+func remoteError(method string, path string, status int, data []byte) error {
+	var body struct {
+		ScimType ErrorType `json:"scimType"`
+		Detail   string    `json:"detail"`
+	}
+	_ = json.Unmarshal(data, &body)
+
+	detail := body.Detail
+	if detail == "" {
+		detail = http.StatusText(status)
+	}
+
+	route, _, _ := strings.Cut(path, "?")
+	return NewError(status, body.ScimType, fmt.Sprintf("%s %s: %s", method, route, detail))
+}
@@ -0,0 +1,125 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildResource(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	attributes := map[string]any{
+		"userName":                     "alice",
+		`emails[type eq "work"].value`: "alice@example.com",
+		"active":                       false,
+		"roles":                        []map[string]any{{"value": "editor"}},
+		"displayName":                  nil,
+	}
+
+	// act
+	resource, err := BuildResource(SchemaUser, attributes)
+
+	// assert
+	require.NoError(t, err)
+	encoded, err := json.Marshal(resource)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice",
+		"emails": [{"type": "work", "value": "alice@example.com"}],
+		"active": false,
+		"roles": [{"value": "editor"}]
+	}`, string(encoded))
+}
+
+func TestEqualFilterQuotesValue(t *testing.T) {
+	t.Parallel()
+
+	// act
+	filter := EqualFilter("userName", `a"b`)
+
+	// assert
+	parsed, err := ParseFilter(filter)
+	require.NoError(t, err)
+	require.Equal(t, Filter{{Attribute: "userName", Operator: OperatorEqual, Value: `a"b`}}, parsed)
+}
+
+func TestClientCreatesAndFindsUsers(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		switch r.Method {
+		case http.MethodGet:
+			require.Equal(t, `externalId eq "42"`, r.URL.Query().Get("filter"))
+			_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "remote-1"}]}`))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "remote-2"}`))
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		BaseUrl:    server.URL + "/scim/v2/",
+		HttpClient: server.Client(),
+		Authorize: func(request *http.Request) {
+			request.Header.Set("Authorization", "Bearer secret")
+		},
+	}
+
+	// act
+	foundId, findErr := client.FindUser(context.Background(), EqualFilter("externalId", "42"))
+	createdId, createErr := client.CreateUser(context.Background(), map[string]any{"userName": "alice"})
+	deleteErr := client.DeleteUser(context.Background(), "remote-3")
+
+	// assert
+	require.NoError(t, findErr)
+	require.Equal(t, "remote-1", foundId)
+	require.NoError(t, createErr)
+	require.Equal(t, "remote-2", createdId)
+	require.NoError(t, deleteErr, "deleting a missing user is not an error")
+	require.Equal(t, []string{
+		"GET /scim/v2/Users",
+		"POST /scim/v2/Users",
+		"DELETE /scim/v2/Users/remote-3",
+	}, requests)
+}
+
+func TestClientReportsServiceProviderErrors(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"status": "409", "scimType": "uniqueness", "detail": "userName taken"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		BaseUrl:    server.URL,
+		HttpClient: server.Client(),
+	}
+
+	// act
+	_, err := client.CreateUser(context.Background(), map[string]any{"userName": "alice"})
+
+	// assert
+	var scimErr *Error
+	require.ErrorAs(t, err, &scimErr)
+	require.Equal(t, http.StatusConflict, scimErr.Status)
+	require.Equal(t, ErrorTypeUniqueness, scimErr.ScimType)
+	require.Contains(t, scimErr.Detail, "userName taken")
+}
@@ -0,0 +1,223 @@
+package scim
+
+// Supported is the generic "supported" flag of the service provider config.
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+type BulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type FilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkSupport            `json:"bulk"`
+	Filter                FilterSupport          `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	Etag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  Meta                   `json:"meta"`
+}
+
+// MaxBulkPayloadSize is the largest bulk request body accepted in bytes.
+const MaxBulkPayloadSize = 1 << 20
+
+// MaxResults is the page size used when a list request has no count, and
+// the largest count accepted.
+const MaxResults = 200
+
+func NewServiceProviderConfig(location string) ServiceProviderConfig {
+	return ServiceProviderConfig{
+		Schemas: []string{SchemaServiceProviderConfig},
+		Patch:   Supported{Supported: true},
+		Bulk: BulkSupport{
+			Supported:      true,
+			MaxOperations:  MaxBulkOperations,
+			MaxPayloadSize: MaxBulkPayloadSize,
+		},
+		Filter: FilterSupport{
+			Supported:  true,
+			MaxResults: MaxResults,
+		},
+		ChangePassword: Supported{Supported: false},
+		Sort:           Supported{Supported: false},
+		Etag:           Supported{Supported: false},
+		AuthenticationSchemes: []AuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "OAuth Bearer Token",
+				Description: "Access token of a service user holding the scim-provisioner role",
+				Primary:     true,
+			},
+		},
+		Meta: Meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     location,
+		},
+	}
+}
+
+type ResourceType struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Endpoint    string   `json:"endpoint"`
+	Description string   `json:"description"`
+	Schema      string   `json:"schema"`
+	Meta        Meta     `json:"meta"`
+}
+
+// NewResourceTypes describes the User and Group endpoints below baseUrl.
+func NewResourceTypes(baseUrl string) []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{SchemaResourceType},
+			Id:          ResourceTypeUser,
+			Name:        ResourceTypeUser,
+			Endpoint:    "/Users",
+			Description: "User Account",
+			Schema:      SchemaUser,
+			Meta: Meta{
+				ResourceType: "ResourceType",
+				Location:     baseUrl + "/ResourceTypes/" + ResourceTypeUser,
+			},
+		},
+		{
+			Schemas:     []string{SchemaResourceType},
+			Id:          ResourceTypeGroup,
+			Name:        ResourceTypeGroup,
+			Endpoint:    "/Groups",
+			Description: "Group",
+			Schema:      SchemaGroup,
+			Meta: Meta{
+				ResourceType: "ResourceType",
+				Location:     baseUrl + "/ResourceTypes/" + ResourceTypeGroup,
+			},
+		},
+	}
+}
+
+type SchemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []SchemaAttribute `json:"subAttributes,omitempty"`
+}
+
+type Schema struct {
+	Schemas     []string          `json:"schemas"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        Meta              `json:"meta"`
+}
+
+func attribute(name string, attributeType string, mutability string) SchemaAttribute {
+	return SchemaAttribute{
+		Name:       name,
+		Type:       attributeType,
+		Mutability: mutability,
+		Returned:   "default",
+		Uniqueness: "none",
+	}
+}
+
+func multiValued(name string, mutability string, subAttributes ...SchemaAttribute) SchemaAttribute {
+	a := attribute(name, "complex", mutability)
+	a.MultiValued = true
+	a.SubAttributes = subAttributes
+	return a
+}
+
+// NewSchemas describes the attributes of users and groups that are mapped
+// onto Keyline entities.
+func NewSchemas(baseUrl string) []Schema {
+	userName := attribute("userName", "string", "immutable")
+	userName.Required = true
+	userName.Uniqueness = "server"
+
+	groupDisplayName := attribute("displayName", "string", "readWrite")
+	groupDisplayName.Required = true
+	groupDisplayName.Uniqueness = "server"
+
+	return []Schema{
+		{
+			Schemas:     []string{SchemaSchema},
+			Id:          SchemaUser,
+			Name:        ResourceTypeUser,
+			Description: "User Account",
+			Attributes: []SchemaAttribute{
+				userName,
+				attribute("displayName", "string", "readWrite"),
+				{
+					Name:       "name",
+					Type:       "complex",
+					Mutability: "readWrite",
+					Returned:   "default",
+					Uniqueness: "none",
+					SubAttributes: []SchemaAttribute{
+						attribute("formatted", "string", "readWrite"),
+						attribute("familyName", "string", "readWrite"),
+						attribute("givenName", "string", "readWrite"),
+					},
+				},
+				attribute("active", "boolean", "readWrite"),
+				multiValued("emails", "readWrite",
+					attribute("value", "string", "readWrite"),
+					attribute("type", "string", "readWrite"),
+					attribute("primary", "boolean", "readWrite"),
+				),
+				multiValued("groups", "readOnly",
+					attribute("value", "string", "readOnly"),
+					attribute("display", "string", "readOnly"),
+				),
+				multiValued("roles", "readWrite",
+					attribute("value", "string", "readWrite"),
+				),
+			},
+			Meta: Meta{
+				ResourceType: "Schema",
+				Location:     baseUrl + "/Schemas/" + SchemaUser,
+			},
+		},
+		{
+			Schemas:     []string{SchemaSchema},
+			Id:          SchemaGroup,
+			Name:        ResourceTypeGroup,
+			Description: "Group",
+			Attributes: []SchemaAttribute{
+				groupDisplayName,
+				multiValued("members", "readWrite",
+					attribute("value", "string", "immutable"),
+					attribute("display", "string", "readOnly"),
+				),
+			},
+			Meta: Meta{
+				ResourceType: "Schema",
+				Location:     baseUrl + "/Schemas/" + SchemaGroup,
+			},
+		},
+	}
+}
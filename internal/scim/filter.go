@@ -0,0 +1,328 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Operator is a comparison operator of the SCIM filter grammar.
+type Operator string
+
+const (
+	OperatorEqual          Operator = "eq"
+	OperatorNotEqual       Operator = "ne"
+	OperatorContains       Operator = "co"
+	OperatorStartsWith     Operator = "sw"
+	OperatorEndsWith       Operator = "ew"
+	OperatorPresent        Operator = "pr"
+	OperatorGreaterThan    Operator = "gt"
+	OperatorGreaterOrEqual Operator = "ge"
+	OperatorLessThan       Operator = "lt"
+	OperatorLessOrEqual    Operator = "le"
+)
+
+var operators = map[string]Operator{
+	"eq": OperatorEqual,
+	"ne": OperatorNotEqual,
+	"co": OperatorContains,
+	"sw": OperatorStartsWith,
+	"ew": OperatorEndsWith,
+	"pr": OperatorPresent,
+	"gt": OperatorGreaterThan,
+	"ge": OperatorGreaterOrEqual,
+	"lt": OperatorLessThan,
+	"le": OperatorLessOrEqual,
+}
+
+// Comparison is a single attribute expression such as userName eq "alice".
+// Value is a string, bool, float64 or nil.
+type Comparison struct {
+	Attribute string
+	Operator  Operator
+	Value     any
+}
+
+// Filter is a conjunction of comparisons. Disjunctions, negation and grouping
+// are not supported because none of the repository filters they would be
+// translated into can express them.
+type Filter []Comparison
+
+func invalidFilter(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, ErrorTypeInvalidFilter, fmt.Sprintf(format, args...))
+}
+
+// ParseFilter parses the filter query parameter of a list request. An empty
+// string yields an empty filter.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter Filter
+	for len(tokens) > 0 {
+		if len(filter) > 0 {
+			if !strings.EqualFold(tokens[0].text, "and") || tokens[0].quoted {
+				return nil, invalidFilter("expected \"and\" but got %q", tokens[0].text)
+			}
+			tokens = tokens[1:]
+		}
+
+		var comparison Comparison
+		comparison, tokens, err = parseComparison(tokens)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, comparison)
+	}
+
+	return filter, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			return nil, invalidFilter("grouping and value paths are not supported")
+
+		case r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, invalidFilter("unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+func parseComparison(tokens []token) (Comparison, []token, error) {
+	if len(tokens) < 2 || tokens[0].quoted {
+		return Comparison{}, nil, invalidFilter("expected an attribute and an operator")
+	}
+
+	attribute := tokens[0].text
+	switch strings.ToLower(attribute) {
+	case "not", "or":
+		return Comparison{}, nil, invalidFilter("%q is not supported", attribute)
+	}
+
+	operator, ok := operators[strings.ToLower(tokens[1].text)]
+	if !ok || tokens[1].quoted {
+		return Comparison{}, nil, invalidFilter("unknown operator %q", tokens[1].text)
+	}
+
+	if operator == OperatorPresent {
+		return Comparison{Attribute: attribute, Operator: operator}, tokens[2:], nil
+	}
+
+	if len(tokens) < 3 {
+		return Comparison{}, nil, invalidFilter("missing value for %s %s", attribute, operator)
+	}
+
+	value, err := parseValue(tokens[2])
+	if err != nil {
+		return Comparison{}, nil, err
+	}
+
+	return Comparison{Attribute: attribute, Operator: operator, Value: value}, tokens[3:], nil
+}
+
+func parseValue(t token) (any, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, invalidFilter("invalid value %q", t.text)
+	}
+	return number, nil
+}
+
+// Matches evaluates the filter against a resource in its generic JSON form.
+// Attribute names are compared case-insensitively, string values
+// case-sensitively. A comparison against a multi-valued attribute matches if
+// any of its entries matches.
+func (f Filter) Matches(resource map[string]any) bool {
+	for _, comparison := range f {
+		if !comparison.matches(resource) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Comparison) matches(resource map[string]any) bool {
+	values := lookupAttribute(resource, c.Attribute)
+
+	if c.Operator == OperatorPresent {
+		for _, value := range values {
+			if value != nil && value != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, value := range values {
+		if compare(value, c.Operator, c.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupAttribute resolves a dotted attribute path. Multi-valued attributes
+// are flattened, and a bare multi-valued attribute stands for the value
+// sub-attribute of its entries.
+func lookupAttribute(resource map[string]any, path string) []any {
+	current := []any{resource}
+
+	for _, part := range strings.Split(path, ".") {
+		var next []any
+		for _, item := range current {
+			object, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			value, ok := getAttribute(object, part)
+			if !ok {
+				continue
+			}
+			if list, ok := value.([]any); ok {
+				next = append(next, list...)
+			} else {
+				next = append(next, value)
+			}
+		}
+		current = next
+	}
+
+	for i, item := range current {
+		if object, ok := item.(map[string]any); ok {
+			current[i], _ = getAttribute(object, "value")
+		}
+	}
+
+	return current
+}
+
+func getAttribute(object map[string]any, name string) (any, bool) {
+	key, ok := attributeKey(object, name)
+	if !ok {
+		return nil, false
+	}
+	return object[key], true
+}
+
+func attributeKey(object map[string]any, name string) (string, bool) {
+	if _, ok := object[name]; ok {
+		return name, true
+	}
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func compare(actual any, operator Operator, expected any) bool {
+	switch expectedValue := expected.(type) {
+	case nil:
+		equal := actual == nil
+		return (operator == OperatorEqual && equal) || (operator == OperatorNotEqual && !equal)
+
+	case bool:
+		actualValue, ok := actual.(bool)
+		if !ok {
+			return operator == OperatorNotEqual
+		}
+		equal := actualValue == expectedValue
+		return (operator == OperatorEqual && equal) || (operator == OperatorNotEqual && !equal)
+
+	case float64:
+		actualValue, ok := actual.(float64)
+		if !ok {
+			return operator == OperatorNotEqual
+		}
+		return compareOrdered(actualValue, operator, expectedValue)
+
+	case string:
+		actualValue, ok := actual.(string)
+		if !ok {
+			return operator == OperatorNotEqual
+		}
+		switch operator {
+		case OperatorContains:
+			return strings.Contains(actualValue, expectedValue)
+		case OperatorStartsWith:
+			return strings.HasPrefix(actualValue, expectedValue)
+		case OperatorEndsWith:
+			return strings.HasSuffix(actualValue, expectedValue)
+		default:
+			return compareOrdered(actualValue, operator, expectedValue)
+		}
+	}
+
+	return false
+}
+
+func compareOrdered[T string | float64](actual T, operator Operator, expected T) bool {
+	switch operator {
+	case OperatorEqual:
+		return actual == expected
+	case OperatorNotEqual:
+		return actual != expected
+	case OperatorGreaterThan:
+		return actual > expected
+	case OperatorGreaterOrEqual:
+		return actual >= expected
+	case OperatorLessThan:
+		return actual < expected
+	case OperatorLessOrEqual:
+		return actual <= expected
+	default:
+		return false
+	}
+}
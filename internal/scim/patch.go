@@ -0,0 +1,394 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type patchPath struct {
+	attribute    string
+	filter       Filter
+	subAttribute string
+}
+
+func invalidPath(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, ErrorTypeInvalidPath, fmt.Sprintf(format, args...))
+}
+
+func invalidValue(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, ErrorTypeInvalidValue, fmt.Sprintf(format, args...))
+}
+
+// parsePath parses the attribute paths accepted in PATCH operations:
+// attr, attr.subAttr, attr[filter] and attr[filter].subAttr, optionally
+// prefixed with the schema urn.
+func parsePath(path string) (patchPath, error) {
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		bracket := strings.Index(path, "[")
+		if bracket < 0 {
+			bracket = len(path)
+		}
+		path = path[strings.LastIndex(path[:bracket], ":")+1:]
+	}
+
+	open := strings.Index(path, "[")
+	if open < 0 {
+		attribute, subAttribute, _ := strings.Cut(path, ".")
+		if attribute == "" {
+			return patchPath{}, invalidPath("empty path")
+		}
+		return patchPath{attribute: attribute, subAttribute: subAttribute}, nil
+	}
+
+	end := strings.LastIndex(path, "]")
+	if end < open || open == 0 {
+		return patchPath{}, invalidPath("malformed path %q", path)
+	}
+
+	filter, err := ParseFilter(path[open+1 : end])
+	if err != nil {
+		return patchPath{}, invalidPath("malformed value filter in %q", path)
+	}
+	if len(filter) == 0 {
+		return patchPath{}, invalidPath("empty value filter in %q", path)
+	}
+
+	rest := path[end+1:]
+	if rest != "" && !strings.HasPrefix(rest, ".") {
+		return patchPath{}, invalidPath("malformed path %q", path)
+	}
+
+	return patchPath{
+		attribute:    path[:open],
+		filter:       filter,
+		subAttribute: strings.TrimPrefix(rest, "."),
+	}, nil
+}
+
+// Patch applies the operations of a PATCH request to a copy of resource and
+// returns it. The resource is round-tripped through its JSON form, so
+// attributes unknown to T are dropped.
+func Patch[T any](resource *T, operations []PatchOperation) (*T, error) {
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("encoding resource: %w", err)
+	}
+
+	var generic map[string]any
+	err = json.Unmarshal(encoded, &generic)
+	if err != nil {
+		return nil, fmt.Errorf("decoding resource: %w", err)
+	}
+
+	err = ApplyPatch(generic, operations)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err = json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("encoding patched resource: %w", err)
+	}
+
+	var patched T
+	err = json.Unmarshal(encoded, &patched)
+	if err != nil {
+		return nil, invalidValue("patched resource is invalid: %v", err)
+	}
+
+	return &patched, nil
+}
+
+// ApplyPatch applies the operations of a PATCH request to a resource in its
+// generic JSON form.
+func ApplyPatch(resource map[string]any, operations []PatchOperation) error {
+	for _, operation := range operations {
+		var value any
+		if len(operation.Value) > 0 {
+			err := json.Unmarshal(operation.Value, &value)
+			if err != nil {
+				return invalidValue("invalid value: %v", err)
+			}
+		}
+
+		op := strings.ToLower(operation.Op)
+		switch op {
+		case "add", "replace", "remove":
+		default:
+			return invalidValue("unknown operation %q", operation.Op)
+		}
+
+		if operation.Path == "" {
+			err := applyWithoutPath(resource, op, value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		path, err := parsePath(operation.Path)
+		if err != nil {
+			return err
+		}
+
+		err = applyAtPath(resource, op, path, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyWithoutPath handles operations that carry the changed attributes as
+// an object in their value.
+func applyWithoutPath(resource map[string]any, op string, value any) error {
+	if op == "remove" {
+		return NewError(http.StatusBadRequest, ErrorTypeNoTarget, "remove requires a path")
+	}
+
+	object, ok := value.(map[string]any)
+	if !ok {
+		return invalidValue("%s without a path requires an object value", op)
+	}
+
+	for key, attributeValue := range object {
+		path, err := parsePath(key)
+		if err != nil {
+			return err
+		}
+
+		err = applyAtPath(resource, op, path, attributeValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyAtPath(resource map[string]any, op string, path patchPath, value any) error {
+	if path.filter != nil {
+		return applyToFilteredEntries(resource, op, path, value)
+	}
+
+	if path.subAttribute != "" {
+		key := resolveKey(resource, path.attribute)
+		object, _ := resource[key].(map[string]any)
+		if object == nil {
+			if op == "remove" {
+				return nil
+			}
+			object = make(map[string]any)
+			resource[key] = object
+		}
+		return applyToAttribute(object, op, path.subAttribute, value)
+	}
+
+	return applyToAttribute(resource, op, path.attribute, value)
+}
+
+func applyToAttribute(object map[string]any, op string, name string, value any) error {
+	key := resolveKey(object, name)
+	existing, exists := object[key]
+	existingList, isList := existing.([]any)
+
+	switch op {
+	case "add":
+		if isList {
+			object[key] = appendEntries(existingList, value)
+			return nil
+		}
+		if valueObject, ok := value.(map[string]any); ok {
+			if existingObject, ok := existing.(map[string]any); ok {
+				for k, v := range valueObject {
+					existingObject[resolveKey(existingObject, k)] = v
+				}
+				return nil
+			}
+		}
+		object[key] = normalizeValue(key, value)
+
+	case "replace":
+		object[key] = normalizeValue(key, value)
+
+	case "remove":
+		if !exists {
+			return nil
+		}
+		if isList && value != nil {
+			object[key] = removeEntries(existingList, value)
+			return nil
+		}
+		delete(object, key)
+	}
+
+	return nil
+}
+
+func applyToFilteredEntries(resource map[string]any, op string, path patchPath, value any) error {
+	key := resolveKey(resource, path.attribute)
+	var entries []any
+	if existing, ok := resource[key]; ok {
+		list, ok := existing.([]any)
+		if !ok {
+			return invalidPath("%q is not multi-valued", path.attribute)
+		}
+		entries = list
+	}
+
+	matched := false
+	result := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		object, ok := entry.(map[string]any)
+		if !ok || !path.filter.Matches(object) {
+			result = append(result, entry)
+			continue
+		}
+
+		matched = true
+		if op == "remove" && path.subAttribute == "" {
+			continue
+		}
+
+		err := applyToEntry(object, op, path.subAttribute, value)
+		if err != nil {
+			return err
+		}
+		result = append(result, object)
+	}
+
+	// clients commonly add e.g. emails[type eq "work"].value before any
+	// such entry exists, so a missing entry is created from the filter
+	if !matched && op != "remove" {
+		object, ok := entryFromFilter(path.filter)
+		if !ok {
+			return NewError(http.StatusBadRequest, ErrorTypeNoTarget, fmt.Sprintf("no entry of %q matches the filter", path.attribute))
+		}
+
+		err := applyToEntry(object, op, path.subAttribute, value)
+		if err != nil {
+			return err
+		}
+		result = append(result, object)
+	}
+
+	resource[key] = result
+	return nil
+}
+
+func applyToEntry(entry map[string]any, op string, subAttribute string, value any) error {
+	if subAttribute != "" {
+		return applyToAttribute(entry, op, subAttribute, value)
+	}
+
+	object, ok := value.(map[string]any)
+	if !ok {
+		return invalidValue("a filtered path without sub-attribute requires an object value")
+	}
+	if op == "replace" {
+		for key := range entry {
+			delete(entry, key)
+		}
+	}
+	for key, attributeValue := range object {
+		entry[resolveKey(entry, key)] = attributeValue
+	}
+
+	return nil
+}
+
+// entryFromFilter builds the entry described by a filter made up of eq
+// comparisons only.
+func entryFromFilter(filter Filter) (map[string]any, bool) {
+	entry := make(map[string]any)
+	for _, comparison := range filter {
+		if comparison.Operator != OperatorEqual || strings.Contains(comparison.Attribute, ".") {
+			return nil, false
+		}
+		entry[comparison.Attribute] = comparison.Value
+	}
+	return entry, true
+}
+
+func appendEntries(entries []any, value any) []any {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	for _, v := range values {
+		if containsEntry(entries, v) {
+			continue
+		}
+		entries = append(entries, v)
+	}
+
+	return entries
+}
+
+// removeEntries drops the entries whose value matches one of the given
+// entries. This is how some clients remove group members without a filter.
+func removeEntries(entries []any, value any) []any {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	result := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		if containsEntry(values, entry) {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+func containsEntry(entries []any, entry any) bool {
+	needle := entryValue(entry)
+	for _, e := range entries {
+		if entryValue(e) == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func entryValue(entry any) any {
+	if object, ok := entry.(map[string]any); ok {
+		value, _ := getAttribute(object, "value")
+		return value
+	}
+	return entry
+}
+
+func resolveKey(object map[string]any, name string) string {
+	if key, ok := attributeKey(object, name); ok {
+		return key
+	}
+	return name
+}
+
+// normalizeValue accepts "True"/"False" for active, which some clients send
+// as strings.
+func normalizeValue(key string, value any) any {
+	if !strings.EqualFold(key, "active") {
+		return value
+	}
+
+	if s, ok := value.(string); ok {
+		switch strings.ToLower(s) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	}
+
+	return value
+}
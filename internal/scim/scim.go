@@ -0,0 +1,251 @@
+// Package scim implements the protocol side of SCIM 2.0 (RFC 7643/7644):
+// resource and message types, filter parsing, PATCH application and a client
+// for downstream service providers. Mapping resources onto Keyline entities
+// is left to the queries, commands and the outbox.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/scim+json"
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaBulkRequest           = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	SchemaBulkResponse          = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+// MaxBulkOperations is the number of operations accepted in one bulk request.
+const MaxBulkOperations = 100
+
+// ErrorType is the scimType detail error keyword of RFC 7644 section 3.12.
+type ErrorType string
+
+const (
+	ErrorTypeInvalidFilter ErrorType = "invalidFilter"
+	ErrorTypeInvalidSyntax ErrorType = "invalidSyntax"
+	ErrorTypeInvalidPath   ErrorType = "invalidPath"
+	ErrorTypeInvalidValue  ErrorType = "invalidValue"
+	ErrorTypeNoTarget      ErrorType = "noTarget"
+	ErrorTypeMutability    ErrorType = "mutability"
+	ErrorTypeUniqueness    ErrorType = "uniqueness"
+	ErrorTypeTooMany       ErrorType = "tooMany"
+)
+
+// Error is a SCIM error response. It is also returned as a Go error by the
+// parsers in this package so handlers can pass it through unchanged.
+type Error struct {
+	Status   int
+	ScimType ErrorType
+	Detail   string
+}
+
+func NewError(status int, scimType ErrorType, detail string) *Error {
+	return &Error{
+		Status:   status,
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return fmt.Sprintf("%s: %s", e.ScimType, e.Detail)
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string  `json:"schemas"`
+		Status   string    `json:"status"`
+		ScimType ErrorType `json:"scimType,omitempty"`
+		Detail   string    `json:"detail,omitempty"`
+	}{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(e.Status),
+		ScimType: e.ScimType,
+		Detail:   e.Detail,
+	})
+}
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+// MultiValued is an entry of a multi-valued attribute such as emails,
+// groups, roles or members.
+type MultiValued struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+type User struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id,omitempty"`
+	ExternalId  string        `json:"externalId,omitempty"`
+	UserName    string        `json:"userName"`
+	Name        *Name         `json:"name,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Active      *bool         `json:"active,omitempty"`
+	Emails      []MultiValued `json:"emails,omitempty"`
+	Groups      []MultiValued `json:"groups,omitempty"`
+	Roles       []MultiValued `json:"roles,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+// IsActive treats a missing active attribute as active.
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// PrimaryEmail picks the entry flagged primary, then the work address, then
+// the first one.
+func (u *User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	for _, email := range u.Emails {
+		if strings.EqualFold(email.Type, "work") {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// ResolvedDisplayName falls back from displayName to the name parts and
+// finally the userName, as not every client sends a displayName.
+func (u *User) ResolvedDisplayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+		full := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+		if full != "" {
+			return full
+		}
+	}
+	return u.UserName
+}
+
+type Group struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id,omitempty"`
+	ExternalId  string        `json:"externalId,omitempty"`
+	DisplayName string        `json:"displayName"`
+	Members     []MultiValued `json:"members,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+func NewListResponse[T any](resources []T, totalResults int, startIndex int) ListResponse[T] {
+	if resources == nil {
+		resources = []T{}
+	}
+	return ListResponse[T]{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: totalResults,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+type BulkOperation struct {
+	Method  string          `json:"method"`
+	BulkId  string          `json:"bulkId,omitempty"`
+	Version string          `json:"version,omitempty"`
+	Path    string          `json:"path"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type BulkResponse struct {
+	Schemas    []string                `json:"schemas"`
+	Operations []BulkOperationResponse `json:"Operations"`
+}
+
+type BulkOperationResponse struct {
+	Method   string `json:"method"`
+	BulkId   string `json:"bulkId,omitempty"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	Response any    `json:"response,omitempty"`
+}
+
+// Pagination converts the 1-based startIndex and count of a list request into
+// a page number and size, capping count at maxCount. Offsets that do not
+// fall on a page boundary are rounded down to the start of their page, the
+// returned startIndex reflects that.
+func Pagination(startIndex int, count int, maxCount int) (page int, size int, actualStartIndex int) {
+	if count <= 0 || count > maxCount {
+		count = maxCount
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	page = (startIndex-1)/count + 1
+	return page, count, (page-1)*count + 1
+}
@@ -0,0 +1,237 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/The127/Keyline/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	input := `userName eq "alice" and meta.lastModified gt "2024-01-01T00:00:00Z" AND active EQ true and title pr`
+
+	// act
+	filter, err := ParseFilter(input)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, Filter{
+		{Attribute: "userName", Operator: OperatorEqual, Value: "alice"},
+		{Attribute: "meta.lastModified", Operator: OperatorGreaterThan, Value: "2024-01-01T00:00:00Z"},
+		{Attribute: "active", Operator: OperatorEqual, Value: true},
+		{Attribute: "title", Operator: OperatorPresent},
+	}, filter)
+}
+
+func TestParseFilterUnescapesStrings(t *testing.T) {
+	t.Parallel()
+
+	// act
+	filter, err := ParseFilter(`displayName eq "say \"hi\""`)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, `say "hi"`, filter[0].Value)
+}
+
+func TestParseFilterRejectsUnsupportedSyntax(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		`userName eq "a" or userName eq "b"`,
+		`not (userName eq "a")`,
+		`emails[type eq "work"]`,
+		`userName is "a"`,
+		`userName eq`,
+		`userName eq "a`,
+		`userName eq alice`,
+	} {
+		// act
+		_, err := ParseFilter(input)
+
+		// assert
+		var scimErr *Error
+		require.ErrorAsf(t, err, &scimErr, "input %q", input)
+		require.Equal(t, ErrorTypeInvalidFilter, scimErr.ScimType)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	resource := map[string]any{
+		"userName": "alice",
+		"emails": []any{
+			map[string]any{"value": "alice@example.com", "type": "work"},
+		},
+		"meta": map[string]any{"lastModified": "2024-06-01T00:00:00Z"},
+	}
+
+	// act & assert
+	require.True(t, utils.Unwrap(ParseFilter(`USERNAME eq "alice"`)).Matches(resource))
+	require.True(t, utils.Unwrap(ParseFilter(`emails eq "alice@example.com"`)).Matches(resource))
+	require.True(t, utils.Unwrap(ParseFilter(`emails.type eq "work" and meta.lastModified gt "2024-01-01T00:00:00Z"`)).Matches(resource))
+	require.False(t, utils.Unwrap(ParseFilter(`userName eq "Alice"`)).Matches(resource))
+	require.False(t, utils.Unwrap(ParseFilter(`displayName pr`)).Matches(resource))
+}
+
+func patchOperations(t *testing.T, raw string) []PatchOperation {
+	t.Helper()
+
+	var request PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(raw), &request))
+	return request.Operations
+}
+
+func TestPatchUserPaths(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	user := &User{
+		Schemas:  []string{SchemaUser},
+		Id:       "1",
+		UserName: "alice",
+		Emails:   []MultiValued{{Value: "old@example.com", Type: "work"}},
+	}
+	operations := patchOperations(t, `{"Operations": [
+		{"op": "Replace", "path": "emails[type eq \"work\"].value", "value": "new@example.com"},
+		{"op": "add", "path": "name.givenName", "value": "Alice"},
+		{"op": "replace", "path": "active", "value": "False"},
+		{"op": "add", "path": "roles", "value": [{"value": "app:reader"}]}
+	]}`)
+
+	// act
+	patched, err := Patch(user, operations)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "new@example.com", patched.PrimaryEmail())
+	require.Equal(t, "Alice", patched.Name.GivenName)
+	require.False(t, patched.IsActive())
+	require.Equal(t, []MultiValued{{Value: "app:reader"}}, patched.Roles)
+	require.Equal(t, "old@example.com", user.Emails[0].Value)
+}
+
+func TestPatchUserWithoutPath(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	user := &User{Schemas: []string{SchemaUser}, UserName: "alice", DisplayName: "Alice"}
+	operations := patchOperations(t, `{"Operations": [
+		{"op": "replace", "value": {"displayName": "Alice Example", "active": false}}
+	]}`)
+
+	// act
+	patched, err := Patch(user, operations)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "Alice Example", patched.DisplayName)
+	require.False(t, patched.IsActive())
+}
+
+func TestPatchCreatesMissingFilteredEntry(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	user := &User{Schemas: []string{SchemaUser}, UserName: "alice"}
+	operations := patchOperations(t, `{"Operations": [
+		{"op": "add", "path": "emails[type eq \"work\"].value", "value": "alice@example.com"}
+	]}`)
+
+	// act
+	patched, err := Patch(user, operations)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, []MultiValued{{Value: "alice@example.com", Type: "work"}}, patched.Emails)
+}
+
+func TestPatchGroupMembers(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	group := &Group{
+		Schemas:     []string{SchemaGroup},
+		DisplayName: "staff",
+		Members:     []MultiValued{{Value: "a"}, {Value: "b"}, {Value: "c"}},
+	}
+	operations := patchOperations(t, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "c"}, {"value": "d"}]},
+		{"op": "remove", "path": "members[value eq \"a\"]"},
+		{"op": "remove", "path": "members", "value": [{"value": "b"}]}
+	]}`)
+
+	// act
+	patched, err := Patch(group, operations)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, []MultiValued{{Value: "c"}, {Value: "d"}}, patched.Members)
+}
+
+func TestPatchRejectsInvalidOperations(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{
+		`{"Operations": [{"op": "move", "path": "displayName", "value": "x"}]}`,
+		`{"Operations": [{"op": "remove"}]}`,
+		`{"Operations": [{"op": "replace", "value": "x"}]}`,
+		`{"Operations": [{"op": "replace", "path": "emails[type eq]", "value": "x"}]}`,
+	} {
+		// act
+		_, err := Patch(&Group{DisplayName: "staff"}, patchOperations(t, raw))
+
+		// assert
+		var scimErr *Error
+		require.ErrorAsf(t, err, &scimErr, "request %s", raw)
+		require.Equal(t, http.StatusBadRequest, scimErr.Status)
+	}
+}
+
+func TestErrorMarshalsStatusAsString(t *testing.T) {
+	t.Parallel()
+
+	// act
+	encoded, err := json.Marshal(NewError(http.StatusBadRequest, ErrorTypeInvalidFilter, "bad"))
+
+	// assert
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:Error"],
+		"status": "400",
+		"scimType": "invalidFilter",
+		"detail": "bad"
+	}`, string(encoded))
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		startIndex, count              int
+		page, size, expectedStartIndex int
+	}{
+		{startIndex: 0, count: 0, page: 1, size: MaxResults, expectedStartIndex: 1},
+		{startIndex: 1, count: 10, page: 1, size: 10, expectedStartIndex: 1},
+		{startIndex: 11, count: 10, page: 2, size: 10, expectedStartIndex: 11},
+		{startIndex: 15, count: 10, page: 2, size: 10, expectedStartIndex: 11},
+	}
+
+	for _, c := range cases {
+		// act
+		page, size, startIndex := Pagination(c.startIndex, c.count, MaxResults)
+
+		// assert
+		require.Equal(t, c.page, page)
+		require.Equal(t, c.size, size)
+		require.Equal(t, c.expectedStartIndex, startIndex)
+	}
+}
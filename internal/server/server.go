@@ -65,6 +65,15 @@ func Serve(dp *ioc.DependencyProvider, serverConfig config.ServerConfig) {
 	oidcRouter.HandleFunc("/activate", handlers.GetActivatePage).Methods(http.MethodGet)
 	oidcRouter.HandleFunc("/activate", handlers.PostActivatePage).Methods(http.MethodPost)
 	oidcRouter.HandleFunc("/activate/success", handlers.ActivateSuccess).Methods(http.MethodGet)
+	oidcRouter.HandleFunc("/identity-providers/{identityProviderName}/callback", handlers.IdentityProviderCallback).Methods(http.MethodGet)
+
+	samlRouter := r.PathPrefix("/saml/{virtualServerName}").Subrouter()
+	samlRouter.Use(middlewares.VirtualServerMiddleware())
+	samlRouter.Use(middlewares.SessionMiddleware())
+	samlRouter.HandleFunc("/metadata", handlers.SamlMetadata).Methods(http.MethodGet)
+	samlRouter.HandleFunc("/sso", handlers.SamlSingleSignOn).Methods(http.MethodGet, http.MethodPost)
+	samlRouter.HandleFunc("/sso/init", handlers.SamlIdpInitiatedSignOn).Methods(http.MethodGet)
+	samlRouter.HandleFunc("/slo", handlers.SamlSingleLogout).Methods(http.MethodGet, http.MethodPost)
 
 	loginRouter := r.PathPrefix("/logins").Subrouter()
 
@@ -82,10 +91,16 @@ func Serve(dp *ioc.DependencyProvider, serverConfig config.ServerConfig) {
 	loginRouter.HandleFunc("/{loginToken}/resend-email-verification", handlers.ResendEmailVerification).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/verify-email", handlers.VerifyEmailToken).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/onboard-totp", handlers.OnboardTotp).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/select-second-factor", handlers.SelectSecondFactor).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/verify-totp", handlers.VerifyTotp).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/sms/send", handlers.SendLoginSmsCode).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/verify-sms", handlers.VerifySms).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/finish-login", handlers.FinishLogin).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/passkey/start", handlers.StartPasskeyLogin).Methods(http.MethodPost, http.MethodOptions)
 	loginRouter.HandleFunc("/{loginToken}/passkey/finish", handlers.FinishPasskeyLogin).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/email-login/start", handlers.StartEmailLogin).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/email-login/verify", handlers.VerifyEmailLogin).Methods(http.MethodPost, http.MethodOptions)
+	loginRouter.HandleFunc("/{loginToken}/identity-providers/{identityProviderName}", handlers.BeginIdentityProviderLogin).Methods(http.MethodGet)
 
 	if config.C.Server.ApiPort == 0 {
 		mapApiRoutes(r)
@@ -158,16 +173,25 @@ func mapApiRoutes(r *mux.Router) {
 	vsApiRouter.HandleFunc("/public-info", handlers.GetVirtualServerPublicInfo).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/health", handlers.VirtualServerHealth).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("", handlers.PatchVirtualServer).Methods(http.MethodPatch, http.MethodOptions)
+	vsApiRouter.HandleFunc("/keys", handlers.ListSigningKeys).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/keys/rotate", handlers.RotateSigningKeys).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/keys/import", handlers.ImportSigningKey).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/keys/{kid}/revoke", handlers.RevokeSigningKey).Methods(http.MethodPost, http.MethodOptions)
 
 	vsApiRouter.HandleFunc("/password-policies/rules", handlers.ListPasswordRules).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/password-policies/rules/{ruleType}", handlers.CreatePasswordRule).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/password-policies/rules/{ruleType}", handlers.UpdatePasswordRule).Methods(http.MethodPut, http.MethodOptions)
 
+	vsApiRouter.HandleFunc("/mfa-policy/rules", handlers.GetMfaRules).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/mfa-policy/rules", handlers.UpdateMfaRules).Methods(http.MethodPut, http.MethodOptions)
+
 	vsApiRouter.HandleFunc("/templates", handlers.ListTemplates).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/templates/{templateType}", handlers.GetTemplate).Methods(http.MethodGet, http.MethodOptions)
 
 	vsApiRouter.HandleFunc("/users/register", handlers.RegisterUser).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users/verify-email", handlers.VerifyEmail).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/forgot-password", handlers.ForgotPassword).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/reset-password", handlers.ResetPassword).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users", handlers.ListUsers).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users", handlers.CreateUser).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users/{userId}", handlers.GetUserById).Methods(http.MethodGet, http.MethodOptions)
@@ -185,9 +209,37 @@ func mapApiRoutes(r *mux.Router) {
 	vsApiRouter.HandleFunc("/users/{userId}/passkeys/register/start", handlers.PasskeyCreateChallenge).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users/{userId}/passkeys/register/finish", handlers.PasskeyValidateCreateChallengeResponse).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/users/{userId}/passkeys", handlers.ListPasskeys).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/passkeys/{passkeyId}", handlers.PatchPasskey).Methods(http.MethodPatch, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/passkeys/{passkeyId}", handlers.DeletePasskey).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/phone/register/start", handlers.StartPhoneRegistration).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/phone/register/finish", handlers.FinishPhoneRegistration).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/recovery-codes", handlers.RegenerateRecoveryCodes).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/totp", handlers.ListTotp).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/totp/{totpId}", handlers.PatchTotp).Methods(http.MethodPatch, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/totp/{totpId}", handlers.DeleteTotp).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/trusted-devices", handlers.ListTrustedDevices).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/trusted-devices/{trustedDeviceId}", handlers.DeleteTrustedDevice).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/reset-2fa", handlers.ResetSecondFactors).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/lockout", handlers.GetUserLockout).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/users/{userId}/lockout", handlers.UnlockUser).Methods(http.MethodDelete, http.MethodOptions)
 
 	vsApiRouter.HandleFunc("/groups", handlers.ListGroups).Methods(http.MethodGet, http.MethodOptions)
 
+	vsApiRouter.HandleFunc("/identity-providers", handlers.ListIdentityProviders).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/identity-providers", handlers.CreateIdentityProvider).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/identity-providers/{identityProviderId}", handlers.GetIdentityProvider).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/identity-providers/{identityProviderId}", handlers.PatchIdentityProvider).Methods(http.MethodPatch, http.MethodOptions)
+	vsApiRouter.HandleFunc("/identity-providers/{identityProviderId}", handlers.DeleteIdentityProvider).Methods(http.MethodDelete, http.MethodOptions)
+
+	vsApiRouter.HandleFunc("/ldap-providers", handlers.ListLdapProviders).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/ldap-providers", handlers.CreateLdapProvider).Methods(http.MethodPost, http.MethodOptions)
+	vsApiRouter.HandleFunc("/ldap-providers/{ldapProviderId}", handlers.GetLdapProvider).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/ldap-providers/{ldapProviderId}", handlers.PatchLdapProvider).Methods(http.MethodPatch, http.MethodOptions)
+	vsApiRouter.HandleFunc("/ldap-providers/{ldapProviderId}", handlers.DeleteLdapProvider).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/ldap-providers/{ldapProviderId}/sync", handlers.SyncLdapProvider).Methods(http.MethodPost, http.MethodOptions)
+
+	vsApiRouter.HandleFunc("/provisioning-targets", handlers.ListProvisioningTargets).Methods(http.MethodGet, http.MethodOptions)
+
 	vsApiRouter.HandleFunc("/projects", handlers.CreateProject).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/projects", handlers.ListProjects).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/projects/{projectSlug}", handlers.GetProject).Methods(http.MethodGet, http.MethodOptions)
@@ -205,6 +257,10 @@ func mapApiRoutes(r *mux.Router) {
 	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}", handlers.GetApplication).Methods(http.MethodGet, http.MethodOptions)
 	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}", handlers.PatchApplication).Methods(http.MethodPatch, http.MethodOptions)
 	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}", handlers.DeleteApplication).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}/provisioning", handlers.GetProvisioningTarget).Methods(http.MethodGet, http.MethodOptions)
+	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}/provisioning", handlers.SetProvisioningTarget).Methods(http.MethodPut, http.MethodOptions)
+	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}/provisioning", handlers.DeleteProvisioningTarget).Methods(http.MethodDelete, http.MethodOptions)
+	vsApiRouter.HandleFunc("/projects/{projectSlug}/applications/{appId}/provisioning/resync", handlers.ResyncProvisioningTarget).Methods(http.MethodPost, http.MethodOptions)
 
 	vsApiRouter.HandleFunc("/projects/{projectSlug}/resource-servers", handlers.CreateResourceServer).Methods(http.MethodPost, http.MethodOptions)
 	vsApiRouter.HandleFunc("/projects/{projectSlug}/resource-servers", handlers.ListResourceServers).Methods(http.MethodGet, http.MethodOptions)
@@ -216,6 +272,30 @@ func mapApiRoutes(r *mux.Router) {
 
 	vsApiRouter.HandleFunc("/audit", handlers.ListAuditLog).Methods(http.MethodGet, http.MethodOptions)
 
+	scimRouter := r.PathPrefix("/scim/v2/{virtualServerName}").Subrouter()
+
+	scimRouter.Use(middlewares.VirtualServerMiddleware())
+	scimRouter.Use(authentication.Middleware())
+
+	scimRouter.HandleFunc("/ServiceProviderConfig", handlers.GetScimServiceProviderConfig).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/ResourceTypes", handlers.GetScimResourceTypes).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Schemas", handlers.GetScimSchemas).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Bulk", handlers.ScimBulk).Methods(http.MethodPost)
+
+	scimRouter.HandleFunc("/Users", handlers.ListScimUsers).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Users", handlers.CreateScimUser).Methods(http.MethodPost)
+	scimRouter.HandleFunc("/Users/{id}", handlers.GetScimUser).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Users/{id}", handlers.ReplaceScimUser).Methods(http.MethodPut)
+	scimRouter.HandleFunc("/Users/{id}", handlers.PatchScimUser).Methods(http.MethodPatch)
+	scimRouter.HandleFunc("/Users/{id}", handlers.DeleteScimUser).Methods(http.MethodDelete)
+
+	scimRouter.HandleFunc("/Groups", handlers.ListScimGroups).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Groups", handlers.CreateScimGroup).Methods(http.MethodPost)
+	scimRouter.HandleFunc("/Groups/{id}", handlers.GetScimGroup).Methods(http.MethodGet)
+	scimRouter.HandleFunc("/Groups/{id}", handlers.ReplaceScimGroup).Methods(http.MethodPut)
+	scimRouter.HandleFunc("/Groups/{id}", handlers.PatchScimGroup).Methods(http.MethodPatch)
+	scimRouter.HandleFunc("/Groups/{id}", handlers.DeleteScimGroup).Methods(http.MethodDelete)
+
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 }
 
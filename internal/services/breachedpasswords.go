@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // the breach lists are indexed by sha-1
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const breachedPasswordsApiTimeout = 10 * time.Second
+
+var ErrBreachedPasswordsNotConfigured = errors.New("no breached passwords lookup is configured")
+
+// BreachedPasswordChecker tells how often a password appears in known data
+// breaches.
+type BreachedPasswordChecker interface {
+	BreachCount(ctx context.Context, password string) (int, error)
+}
+
+type disabledBreachedPasswordChecker struct {
+}
+
+func NewDisabledBreachedPasswordChecker() BreachedPasswordChecker {
+	return &disabledBreachedPasswordChecker{}
+}
+
+func (c *disabledBreachedPasswordChecker) BreachCount(context.Context, string) (int, error) {
+	return 0, ErrBreachedPasswordsNotConfigured
+}
+
+type apiBreachedPasswordChecker struct {
+	url    string
+	client *http.Client
+}
+
+// NewApiBreachedPasswordChecker queries a k-anonymity range api like the one
+// of Have I Been Pwned at url. Only the first five hex digits of the sha-1
+// of the password are sent, the api answers with the suffixes of all hashes
+// starting with them.
+func NewApiBreachedPasswordChecker(url string) BreachedPasswordChecker {
+	return &apiBreachedPasswordChecker{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: breachedPasswordsApiTimeout},
+	}
+}
+
+func (c *apiBreachedPasswordChecker) BreachCount(ctx context.Context, password string) (int, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/range/"+prefix, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating range request: %w", err)
+	}
+	// padding hides the number of suffixes from anyone watching
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("calling range api: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("range api answered with status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lineSuffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		breachCount, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("parsing breach count: %w", err)
+		}
+		return breachCount, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading range response: %w", err)
+	}
+
+	return 0, nil
+}
+
+// breachIndexMagic starts breached password index files. The records after
+// it hold the first breachIndexPrefixSize bytes of the sha-1 of a password
+// and its breach count, sorted by the prefix. Prefixes of this size keep the
+// file small, a password is practically never mistaken for another one.
+const (
+	breachIndexMagic      = "KLBI0001"
+	breachIndexPrefixSize = 8
+	breachIndexRecordSize = breachIndexPrefixSize + 4
+)
+
+type indexBreachedPasswordChecker struct {
+	file    *os.File
+	records int64
+}
+
+// NewIndexBreachedPasswordChecker looks passwords up in an index file
+// written by WriteBreachIndex, nothing leaves the server.
+func NewIndexBreachedPasswordChecker(path string) (BreachedPasswordChecker, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening breached passwords index: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("getting breached passwords index size: %w", err)
+	}
+
+	magic := make([]byte, len(breachIndexMagic))
+	_, err = file.ReadAt(magic, 0)
+	if err != nil || string(magic) != breachIndexMagic {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s is not a breached passwords index", path)
+	}
+
+	size := info.Size() - int64(len(breachIndexMagic))
+	if size%breachIndexRecordSize != 0 {
+		_ = file.Close()
+		return nil, fmt.Errorf("breached passwords index %s is truncated", path)
+	}
+
+	return &indexBreachedPasswordChecker{
+		file:    file,
+		records: size / breachIndexRecordSize,
+	}, nil
+}
+
+func (c *indexBreachedPasswordChecker) BreachCount(_ context.Context, password string) (int, error) {
+	key := breachIndexKey(sha1.Sum([]byte(password))) //nolint:gosec
+
+	var readErr error
+	record := make([]byte, breachIndexRecordSize)
+	readRecord := func(i int64) uint64 {
+		_, err := c.file.ReadAt(record, int64(len(breachIndexMagic))+i*breachIndexRecordSize)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		return binary.BigEndian.Uint64(record[:breachIndexPrefixSize])
+	}
+
+	i := int64(sort.Search(int(c.records), func(i int) bool {
+		return readRecord(int64(i)) >= key
+	}))
+	if readErr != nil {
+		return 0, fmt.Errorf("reading breached passwords index: %w", readErr)
+	}
+	if i == c.records {
+		return 0, nil
+	}
+
+	found := readRecord(i)
+	if readErr != nil {
+		return 0, fmt.Errorf("reading breached passwords index: %w", readErr)
+	}
+	if found != key {
+		return 0, nil
+	}
+
+	return int(binary.BigEndian.Uint32(record[breachIndexPrefixSize:])), nil
+}
+
+// WriteBreachIndex reads sha-1 hashes with their breach counts, one
+// "HASH:COUNT" per line and sorted by hash like the downloads of Have I Been
+// Pwned, and writes the index NewIndexBreachedPasswordChecker reads. It
+// returns the number of records written.
+func WriteBreachIndex(r io.Reader, w io.Writer) (int, error) {
+	writer := bufio.NewWriter(w)
+	_, err := writer.WriteString(breachIndexMagic)
+	if err != nil {
+		return 0, fmt.Errorf("writing index header: %w", err)
+	}
+
+	written := 0
+	pending := false
+	var pendingKey uint64
+	var pendingCount uint64
+
+	flush := func() error {
+		record := make([]byte, breachIndexRecordSize)
+		binary.BigEndian.PutUint64(record, pendingKey)
+		binary.BigEndian.PutUint32(record[breachIndexPrefixSize:], uint32(min(pendingCount, math.MaxUint32)))
+		_, err := writer.Write(record)
+		written++
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, count, ok := strings.Cut(line, ":")
+		if !ok {
+			return written, fmt.Errorf("line %d: missing breach count", lineNumber)
+		}
+
+		hashBytes, err := hex.DecodeString(hash)
+		if err != nil || len(hashBytes) != sha1.Size {
+			return written, fmt.Errorf("line %d: invalid sha-1 hash", lineNumber)
+		}
+
+		breachCount, err := strconv.ParseUint(count, 10, 64)
+		if err != nil {
+			return written, fmt.Errorf("line %d: invalid breach count: %w", lineNumber, err)
+		}
+
+		key := breachIndexKey([sha1.Size]byte(hashBytes))
+		switch {
+		case pending && key < pendingKey:
+			return written, fmt.Errorf("line %d: hashes are not sorted", lineNumber)
+
+		// hashes that share the prefix are merged
+		case pending && key == pendingKey:
+			pendingCount += breachCount
+
+		default:
+			if pending {
+				err := flush()
+				if err != nil {
+					return written, fmt.Errorf("writing index record: %w", err)
+				}
+			}
+			pending = true
+			pendingKey = key
+			pendingCount = breachCount
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return written, fmt.Errorf("reading hash list: %w", err)
+	}
+
+	if pending {
+		err := flush()
+		if err != nil {
+			return written, fmt.Errorf("writing index record: %w", err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return written, fmt.Errorf("writing index: %w", err)
+	}
+
+	return written, nil
+}
+
+func breachIndexKey(hash [sha1.Size]byte) uint64 {
+	return binary.BigEndian.Uint64(hash[:breachIndexPrefixSize])
+}
+
+func sha1Hex(password string) string {
+	hash := sha1.Sum([]byte(password)) //nolint:gosec
+	return strings.ToUpper(hex.EncodeToString(hash[:]))
+}
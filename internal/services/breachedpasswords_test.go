@@ -0,0 +1,120 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+const breachedPasswordSuffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestApiBreachedPasswordChecker_SendsOnlyThePrefix(t *testing.T) {
+	t.Parallel()
+
+	var path, padding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		padding = r.Header.Get("Add-Padding")
+		_, _ = w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + breachedPasswordSuffix + ":9659365\r\n00D4F6E8FA6EECAD2A3AA415EEC418D38EC:0\r\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	checker := NewApiBreachedPasswordChecker(server.URL + "/")
+
+	count, err := checker.BreachCount(t.Context(), "password")
+	require.NoError(t, err)
+	assert.Equal(t, 9659365, count)
+	assert.Equal(t, "/range/5BAA6", path)
+	assert.Equal(t, "true", padding)
+
+	count, err = checker.BreachCount(t.Context(), "not breached")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestApiBreachedPasswordChecker_FailsOnErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	checker := NewApiBreachedPasswordChecker(server.URL)
+
+	_, err := checker.BreachCount(t.Context(), "password")
+	assert.Error(t, err)
+}
+
+func writeBreachIndexFile(t *testing.T, hashList string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "breached.idx")
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	_, err = WriteBreachIndex(strings.NewReader(hashList), f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return file
+}
+
+func TestIndexBreachedPasswordChecker_LooksUpCounts(t *testing.T) {
+	t.Parallel()
+
+	file := writeBreachIndexFile(t, strings.Join([]string{
+		"000000005AD76BD555C1D6D771DE417A4B87E4B4:4",
+		"5BAA6" + breachedPasswordSuffix + ":9659365",
+		// shares the prefix with the hash above, the counts are added
+		"5BAA61E4C9B93F3FFFFFFFFFFFFFFFFFFFFFFFFF:5",
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+	}, "\n"))
+
+	checker, err := NewIndexBreachedPasswordChecker(file)
+	require.NoError(t, err)
+
+	count, err := checker.BreachCount(t.Context(), "password")
+	require.NoError(t, err)
+	assert.Equal(t, 9659370, count)
+
+	count, err = checker.BreachCount(t.Context(), "not breached")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestIndexBreachedPasswordChecker_EmptyIndex(t *testing.T) {
+	t.Parallel()
+
+	checker, err := NewIndexBreachedPasswordChecker(writeBreachIndexFile(t, ""))
+	require.NoError(t, err)
+
+	count, err := checker.BreachCount(t.Context(), "password")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestIndexBreachedPasswordChecker_RejectsOtherFiles(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "hashes.txt")
+	require.NoError(t, os.WriteFile(file, []byte("5BAA6"+breachedPasswordSuffix+":9659365\n"), 0o600))
+
+	_, err := NewIndexBreachedPasswordChecker(file)
+	assert.Error(t, err)
+}
+
+func TestWriteBreachIndex_RejectsUnsortedHashes(t *testing.T) {
+	t.Parallel()
+
+	_, err := WriteBreachIndex(strings.NewReader(strings.Join([]string{
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+		"000000005AD76BD555C1D6D771DE417A4B87E4B4:4",
+	}, "\n")), &strings.Builder{})
+	assert.ErrorContains(t, err, "not sorted")
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/repositories"
+)
+
+// databaseKeyStore keeps key pairs in the application database so that all
+// instances of a deployment share them without a shared directory or vault.
+// Private keys are envelope encrypted, see KeyEncryption.
+type databaseKeyStore struct {
+	dbFactory  database.Factory
+	encryption *KeyEncryption
+}
+
+func NewDatabaseKeyStore(dbFactory database.Factory, encryption *KeyEncryption) KeyStore {
+	return &databaseKeyStore{
+		dbFactory:  dbFactory,
+		encryption: encryption,
+	}
+}
+
+// signingKeyAdditionalData binds an encrypted private key to its row, so
+// that ciphertexts cannot be swapped between keys.
+func signingKeyAdditionalData(virtualServerName string, algorithm config.SigningAlgorithm, kid string) []byte {
+	return fmt.Appendf(nil, "%s:%s:%s", virtualServerName, algorithm, kid)
+}
+
+func (d *databaseKeyStore) decrypt(signingKey *repositories.SigningKey) (KeyPair, error) {
+	serializedPrivateKey, err := d.encryption.Decrypt(
+		signingKey.EncryptedPrivateKey(),
+		signingKey.WrappedDataKey(),
+		signingKey.MasterKeyId(),
+		signingKeyAdditionalData(signingKey.VirtualServerName(), signingKey.Algorithm(), signingKey.Kid()),
+	)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("decrypting key %s: %w", signingKey.Kid(), err)
+	}
+
+	strategy := GetKeyStrategy(signingKey.Algorithm())
+	privateKey, publicKey, err := strategy.Import(string(serializedPrivateKey))
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("importing key pair: %w", err)
+	}
+
+	return KeyPair{
+		algorithm:   signingKey.Algorithm(),
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		kid:         signingKey.Kid(),
+		createdAt:   signingKey.CreatedAt(),
+		activatesAt: signingKey.ActivatesAt(),
+		rotatesAt:   signingKey.RotatesAt(),
+		expiresAt:   signingKey.ExpiresAt(),
+		verifyOnly:  signingKey.VerifyOnly(),
+	}, nil
+}
+
+func (d *databaseKeyStore) list(filter *repositories.SigningKeyFilter) ([]KeyPair, error) {
+	ctx := context.Background()
+	dbContext, err := d.dbFactory.NewContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating db context: %w", err)
+	}
+
+	signingKeys, err := dbContext.SigningKeys().List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("listing signing keys: %w", err)
+	}
+
+	keyPairs := make([]KeyPair, 0, len(signingKeys))
+	for _, signingKey := range signingKeys {
+		keyPair, err := d.decrypt(signingKey)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, keyPair)
+	}
+
+	return keyPairs, nil
+}
+
+func (d *databaseKeyStore) Get(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (*KeyPair, error) {
+	keyPairs, err := d.list(repositories.NewSigningKeyFilter().
+		VirtualServerName(virtualServerName).
+		Algorithm(algorithm).
+		Kid(kid))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyPairs) == 0 {
+		return nil, nil
+	}
+
+	return &keyPairs[0], nil
+}
+
+func (d *databaseKeyStore) GetAll(virtualServerName string) ([]KeyPair, error) {
+	return d.list(repositories.NewSigningKeyFilter().VirtualServerName(virtualServerName))
+}
+
+func (d *databaseKeyStore) GetAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) ([]KeyPair, error) {
+	return d.list(repositories.NewSigningKeyFilter().VirtualServerName(virtualServerName).Algorithm(algorithm))
+}
+
+// Add stores a new key pair or updates the schedule of a known one. The
+// private key of a stored key pair never changes.
+func (d *databaseKeyStore) Add(virtualServerName string, keyPair KeyPair) error {
+	ctx := context.Background()
+	dbContext, err := d.dbFactory.NewContext(ctx)
+	if err != nil {
+		return fmt.Errorf("creating db context: %w", err)
+	}
+
+	filter := repositories.NewSigningKeyFilter().
+		VirtualServerName(virtualServerName).
+		Algorithm(keyPair.algorithm).
+		Kid(keyPair.GetKid())
+	signingKey, err := dbContext.SigningKeys().FirstOrNil(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("getting signing key: %w", err)
+	}
+
+	if signingKey == nil {
+		strategy := GetKeyStrategy(keyPair.algorithm)
+		serializedPrivateKey, err := strategy.Export(keyPair.privateKey)
+		if err != nil {
+			return fmt.Errorf("exporting key pair: %w", err)
+		}
+
+		encryptedPrivateKey, wrappedDataKey, masterKeyId, err := d.encryption.Encrypt(
+			[]byte(serializedPrivateKey),
+			signingKeyAdditionalData(virtualServerName, keyPair.algorithm, keyPair.GetKid()),
+		)
+		if err != nil {
+			return fmt.Errorf("encrypting key pair: %w", err)
+		}
+
+		signingKey = repositories.NewSigningKey(virtualServerName, keyPair.algorithm, keyPair.GetKid())
+		signingKey.SetEncryption(encryptedPrivateKey, wrappedDataKey, masterKeyId)
+		signingKey.SetSchedule(keyPair.CreatedAt(), keyPair.ActivatesAt(), keyPair.RotatesAt(), keyPair.ExpiresAt(), keyPair.VerifyOnly())
+		dbContext.SigningKeys().Insert(signingKey)
+	} else {
+		signingKey.SetSchedule(keyPair.CreatedAt(), keyPair.ActivatesAt(), keyPair.RotatesAt(), keyPair.ExpiresAt(), keyPair.VerifyOnly())
+		dbContext.SigningKeys().Update(signingKey)
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("saving signing key: %w", err)
+	}
+
+	return nil
+}
+
+func (d *databaseKeyStore) remove(filter *repositories.SigningKeyFilter) error {
+	ctx := context.Background()
+	dbContext, err := d.dbFactory.NewContext(ctx)
+	if err != nil {
+		return fmt.Errorf("creating db context: %w", err)
+	}
+
+	signingKeys, err := dbContext.SigningKeys().List(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("listing signing keys: %w", err)
+	}
+
+	for _, signingKey := range signingKeys {
+		dbContext.SigningKeys().Delete(signingKey.Id())
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("deleting signing keys: %w", err)
+	}
+
+	return nil
+}
+
+func (d *databaseKeyStore) Remove(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error {
+	return d.remove(repositories.NewSigningKeyFilter().
+		VirtualServerName(virtualServerName).
+		Algorithm(algorithm).
+		Kid(kid))
+}
+
+func (d *databaseKeyStore) RemoveAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) error {
+	return d.remove(repositories.NewSigningKeyFilter().VirtualServerName(virtualServerName).Algorithm(algorithm))
+}
+
+// RewrapSigningKeys re-wraps the data keys of all signing keys that are not
+// wrapped with the current master key yet. It returns the number of keys it
+// re-wrapped.
+func RewrapSigningKeys(ctx context.Context, dbFactory database.Factory, encryption *KeyEncryption) (int, error) {
+	dbContext, err := dbFactory.NewContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("creating db context: %w", err)
+	}
+
+	signingKeys, err := dbContext.SigningKeys().List(ctx, repositories.NewSigningKeyFilter())
+	if err != nil {
+		return 0, fmt.Errorf("listing signing keys: %w", err)
+	}
+
+	rewrapped := 0
+	for _, signingKey := range signingKeys {
+		if signingKey.MasterKeyId() == encryption.CurrentMasterKeyId() {
+			continue
+		}
+
+		wrappedDataKey, masterKeyId, err := encryption.Rewrap(signingKey.WrappedDataKey(), signingKey.MasterKeyId())
+		if err != nil {
+			return 0, fmt.Errorf("re-wrapping key %s of virtual server %s: %w", signingKey.Kid(), signingKey.VirtualServerName(), err)
+		}
+
+		signingKey.SetEncryption(signingKey.EncryptedPrivateKey(), wrappedDataKey, masterKeyId)
+		dbContext.SigningKeys().Update(signingKey)
+		rewrapped++
+	}
+
+	err = dbContext.SaveChanges(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("saving signing keys: %w", err)
+	}
+
+	return rewrapped, nil
+}
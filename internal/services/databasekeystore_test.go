@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/database/memory"
+	"github.com/The127/Keyline/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatabaseKeyStore(t *testing.T, masterKey string, previousMasterKeys ...string) (KeyStore, database.Factory) {
+	t.Helper()
+
+	encryption, err := NewKeyEncryption(masterKey, previousMasterKeys)
+	require.NoError(t, err)
+
+	dbFactory := database.NewDbFactory(memory.NewMemoryDatabase())
+	return NewDatabaseKeyStore(dbFactory, encryption), dbFactory
+}
+
+func TestDatabaseKeyStore_AddAndGet(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	testee, dbFactory := newTestDatabaseKeyStore(t, newTestMasterKey(t))
+	clockService, _ := clock.NewMockClock(time.Now())
+	keyPair, err := GetKeyStrategy(config.SigningAlgorithmES256).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+
+	// act
+	err = testee.Add("vs", keyPair)
+	require.NoError(t, err)
+
+	// assert
+	stored, err := testee.Get("vs", config.SigningAlgorithmES256, keyPair.GetKid())
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, keyPair.PrivateKey(), stored.PrivateKey())
+	require.True(t, keyPair.RotatesAt().Equal(stored.RotatesAt()))
+
+	all, err := testee.GetAll("vs")
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+
+	missing, err := testee.Get("other", config.SigningAlgorithmES256, keyPair.GetKid())
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	dbContext, err := dbFactory.NewContext(context.Background())
+	require.NoError(t, err)
+	signingKey, err := dbContext.SigningKeys().FirstOrNil(context.Background(), repositories.NewSigningKeyFilter().Kid(keyPair.GetKid()))
+	require.NoError(t, err)
+	exported, err := GetKeyStrategy(config.SigningAlgorithmES256).Export(keyPair.PrivateKey())
+	require.NoError(t, err)
+	require.NotContains(t, string(signingKey.EncryptedPrivateKey()), exported)
+}
+
+func TestDatabaseKeyStore_AddUpdatesSchedule(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	testee, _ := newTestDatabaseKeyStore(t, newTestMasterKey(t))
+	now := time.Now()
+	clockService, _ := clock.NewMockClock(now)
+	keyPair, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+	require.NoError(t, testee.Add("vs", keyPair))
+
+	// act
+	keyPair.rotatesAt = now
+	err = testee.Add("vs", keyPair)
+
+	// assert
+	require.NoError(t, err)
+	keyPairs, err := testee.GetAllForAlgorithm("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 1)
+	require.True(t, now.Equal(keyPairs[0].RotatesAt()))
+}
+
+func TestDatabaseKeyStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	testee, _ := newTestDatabaseKeyStore(t, newTestMasterKey(t))
+	clockService, _ := clock.NewMockClock(time.Now())
+	for range 2 {
+		keyPair, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+		require.NoError(t, err)
+		require.NoError(t, testee.Add("vs", keyPair))
+	}
+	keyPairs, err := testee.GetAll("vs")
+	require.NoError(t, err)
+
+	// act
+	err = testee.Remove("vs", config.SigningAlgorithmEdDSA, keyPairs[0].GetKid())
+	require.NoError(t, err)
+
+	// assert
+	remaining, err := testee.GetAll("vs")
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	require.Equal(t, keyPairs[1].GetKid(), remaining[0].GetKid())
+
+	err = testee.RemoveAllForAlgorithm("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	remaining, err = testee.GetAll("vs")
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+}
+
+func TestRewrapSigningKeys(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	oldMasterKey := newTestMasterKey(t)
+	store, dbFactory := newTestDatabaseKeyStore(t, oldMasterKey)
+	clockService, _ := clock.NewMockClock(time.Now())
+	keyPair, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+	require.NoError(t, store.Add("vs", keyPair))
+
+	newMasterKey := newTestMasterKey(t)
+	encryption, err := NewKeyEncryption(newMasterKey, []string{oldMasterKey})
+	require.NoError(t, err)
+
+	// act
+	rewrapped, err := RewrapSigningKeys(context.Background(), dbFactory, encryption)
+	require.NoError(t, err)
+
+	// assert
+	require.Equal(t, 1, rewrapped)
+
+	withoutOldKey, err := NewKeyEncryption(newMasterKey, nil)
+	require.NoError(t, err)
+	stored, err := NewDatabaseKeyStore(dbFactory, withoutOldKey).Get("vs", config.SigningAlgorithmEdDSA, keyPair.GetKid())
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, keyPair.PrivateKey(), stored.PrivateKey())
+
+	rewrapped, err = RewrapSigningKeys(context.Background(), dbFactory, encryption)
+	require.NoError(t, err)
+	require.Equal(t, 0, rewrapped)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/middlewares"
+	"strconv"
 	"sync"
 	"time"
 
@@ -31,7 +32,17 @@ func WithExpiration(expiration time.Duration) Option {
 
 type Store interface {
 	Set(ctx context.Context, key string, value string, opts ...Option) error
+	// SetIfAbsent sets the value only if the key is not set yet and tells
+	// whether it did, so that only one caller can ever set it.
+	SetIfAbsent(ctx context.Context, key string, value string, opts ...Option) (bool, error)
 	Get(ctx context.Context, key string) (string, error)
+	// GetAndDelete returns the value and deletes it in one step, so that
+	// only one caller can ever get it.
+	GetAndDelete(ctx context.Context, key string) (string, error)
+	// Increment adds one to the counter and returns the new value in one
+	// step, a missing counter starts at zero. The expiration is renewed on
+	// every increment, zero keeps the counter forever.
+	Increment(ctx context.Context, key string, expiration time.Duration) (int64, error)
 	Delete(ctx context.Context, key string) error
 }
 
@@ -82,6 +93,36 @@ func (m *memoryStore) Set(ctx context.Context, key string, value string, opts ..
 	return nil
 }
 
+func (m *memoryStore) SetIfAbsent(ctx context.Context, key string, value string, opts ...Option) (bool, error) {
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	item := memoryStoreItem{
+		value: value,
+	}
+
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Expiration != 0 {
+		item.expiration = now.Add(options.Expiration)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.data[key]
+	if ok && !existing.IsExpired(now) {
+		return false, nil
+	}
+
+	m.data[key] = item
+	return true, nil
+}
+
 func (m *memoryStore) Get(ctx context.Context, key string) (string, error) {
 	scope := middlewares.GetScope(ctx)
 	clockService := ioc.GetDependency[clock.Service](scope)
@@ -107,6 +148,55 @@ func (m *memoryStore) Get(ctx context.Context, key string) (string, error) {
 	return item.value, nil
 }
 
+func (m *memoryStore) GetAndDelete(ctx context.Context, key string) (string, error) {
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	delete(m.data, key)
+
+	if item.IsExpired(clockService.Now()) {
+		return "", ErrNotFound
+	}
+
+	return item.value, nil
+}
+
+func (m *memoryStore) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var counter int64
+	item, ok := m.data[key]
+	if ok && !item.IsExpired(now) {
+		parsed, err := strconv.ParseInt(item.value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value of %s is not a counter: %w", key, err)
+		}
+		counter = parsed
+	}
+	counter++
+
+	item = memoryStoreItem{
+		value: strconv.FormatInt(counter, 10),
+	}
+	if expiration != 0 {
+		item.expiration = now.Add(expiration)
+	}
+	m.data[key] = item
+	return counter, nil
+}
+
 func (m *memoryStore) Delete(_ context.Context, key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -130,6 +220,15 @@ func (r *redisKvStore) Set(ctx context.Context, key string, value string, opts .
 	return client.Set(ctx, key, value, options.Expiration).Err()
 }
 
+func (r *redisKvStore) SetIfAbsent(ctx context.Context, key string, value string, opts ...Option) (bool, error) {
+	client := newRedisClient()
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return client.SetNX(ctx, key, value, options.Expiration).Result()
+}
+
 func (r *redisKvStore) Get(ctx context.Context, key string) (string, error) {
 	client := newRedisClient()
 	result, err := client.Get(ctx, key).Result()
@@ -139,6 +238,30 @@ func (r *redisKvStore) Get(ctx context.Context, key string) (string, error) {
 	return result, err
 }
 
+func (r *redisKvStore) GetAndDelete(ctx context.Context, key string) (string, error) {
+	client := newRedisClient()
+	result, err := client.GetDel(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return result, err
+}
+
+// incrementScript increments the counter and renews its expiration in one
+// step, INCR alone keeps the expiration of the previous increment.
+var incrementScript = redis.NewScript(`
+local counter = redis.call("INCR", KEYS[1])
+if tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return counter
+`)
+
+func (r *redisKvStore) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	client := newRedisClient()
+	return incrementScript.Run(ctx, client, []string{key}, expiration.Milliseconds()).Int64()
+}
+
 func (r *redisKvStore) Delete(ctx context.Context, key string) error {
 	client := newRedisClient()
 	err := client.Del(ctx, key).Err()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/utils"
+	"sync"
 	"testing"
 	"time"
 
@@ -90,3 +91,132 @@ func (s *MemoryStoreSuite) TestGetExpired() {
 	s.Equal(ErrNotFound, err)
 	s.Empty(got)
 }
+
+func (s *MemoryStoreSuite) TestGetAndDelete() {
+	// arrange
+	ctx, _ := s.createContext()
+	store := NewMemoryStore()
+
+	err := store.Set(ctx, "key", "value")
+	s.Require().NoError(err)
+
+	// act
+	first, firstErr := store.GetAndDelete(ctx, "key")
+	second, secondErr := store.GetAndDelete(ctx, "key")
+
+	// assert
+	s.Require().NoError(firstErr)
+	s.Equal("value", first)
+	s.Equal(ErrNotFound, secondErr)
+	s.Empty(second)
+}
+
+func (s *MemoryStoreSuite) TestGetAndDeleteExpired() {
+	// arrange
+	ctx, setTime := s.createContext()
+	store := NewMemoryStore()
+
+	err := store.Set(ctx, "key", "value", WithExpiration(time.Second))
+	s.Require().NoError(err)
+
+	setTime(time.Now().Add(time.Second * 2))
+
+	// act
+	got, err := store.GetAndDelete(ctx, "key")
+
+	// assert
+	s.Equal(ErrNotFound, err)
+	s.Empty(got)
+}
+
+func (s *MemoryStoreSuite) TestIncrement() {
+	// arrange
+	ctx, setTime := s.createContext()
+	store := NewMemoryStore()
+
+	// act
+	first, err := store.Increment(ctx, "key", time.Second)
+	s.Require().NoError(err)
+	second, err := store.Increment(ctx, "key", time.Second)
+	s.Require().NoError(err)
+
+	setTime(time.Now().Add(time.Second * 2))
+	afterExpiry, err := store.Increment(ctx, "key", time.Second)
+	s.Require().NoError(err)
+
+	// assert
+	s.Equal(int64(1), first)
+	s.Equal(int64(2), second)
+	s.Equal(int64(1), afterExpiry)
+}
+
+func (s *MemoryStoreSuite) TestIncrementConcurrently() {
+	// arrange
+	ctx, _ := s.createContext()
+	store := NewMemoryStore()
+
+	// act
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() {
+			_, err := store.Increment(ctx, "key", time.Minute)
+			s.NoError(err)
+		})
+	}
+	wg.Wait()
+
+	// assert
+	got, err := store.Get(ctx, "key")
+	s.Require().NoError(err)
+	s.Equal("100", got)
+}
+
+func (s *MemoryStoreSuite) TestSetIfAbsent() {
+	// arrange
+	ctx, setTime := s.createContext()
+	store := NewMemoryStore()
+
+	// act
+	first, err := store.SetIfAbsent(ctx, "key", "first", WithExpiration(time.Second))
+	s.Require().NoError(err)
+	second, err := store.SetIfAbsent(ctx, "key", "second", WithExpiration(time.Second))
+	s.Require().NoError(err)
+	got, err := store.Get(ctx, "key")
+	s.Require().NoError(err)
+
+	setTime(time.Now().Add(time.Second * 2))
+	afterExpiry, err := store.SetIfAbsent(ctx, "key", "third", WithExpiration(time.Second))
+	s.Require().NoError(err)
+
+	// assert
+	s.True(first)
+	s.False(second)
+	s.Equal("first", got)
+	s.True(afterExpiry)
+}
+
+func (s *MemoryStoreSuite) TestSetIfAbsentConcurrently() {
+	// arrange
+	ctx, _ := s.createContext()
+	store := NewMemoryStore()
+
+	// act
+	var mu sync.Mutex
+	var set int
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() {
+			ok, err := store.SetIfAbsent(ctx, "key", "value", WithExpiration(time.Minute))
+			s.NoError(err)
+			if ok {
+				mu.Lock()
+				set++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	// assert
+	s.Equal(1, set)
+}
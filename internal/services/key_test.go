@@ -1,7 +1,14 @@
 package services
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
 	"testing"
 	"time"
 
@@ -18,6 +25,12 @@ func TestKeyStrategy(t *testing.T) {
 		alg  config.SigningAlgorithm
 	}{
 		{"RS256", config.SigningAlgorithmRS256},
+		{"RS384", config.SigningAlgorithmRS384},
+		{"RS512", config.SigningAlgorithmRS512},
+		{"PS256", config.SigningAlgorithmPS256},
+		{"ES256", config.SigningAlgorithmES256},
+		{"ES384", config.SigningAlgorithmES384},
+		{"ES512", config.SigningAlgorithmES512},
 		{"EdDSA", config.SigningAlgorithmEdDSA},
 	}
 
@@ -31,7 +44,7 @@ func TestKeyStrategy(t *testing.T) {
 			strategy := GetKeyStrategy(test.alg)
 
 			// act
-			keyPair, err := strategy.Generate(clockService)
+			keyPair, err := strategy.Generate(clockService, repositories.DefaultKeyRotationPolicy())
 			require.NoError(t, err)
 
 			exported, err := strategy.Export(keyPair.PrivateKey())
@@ -44,3 +57,316 @@ func TestKeyStrategy(t *testing.T) {
 		})
 	}
 }
+
+func TestECPublicKeyCoordinates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		curve elliptic.Curve
+		crv   string
+		size  int
+	}{
+		{"P-256", elliptic.P256(), "P-256", 32},
+		{"P-384", elliptic.P384(), "P-384", 48},
+		{"P-521", elliptic.P521(), "P-521", 66},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			privateKey, err := ecdsa.GenerateKey(test.curve, rand.Reader)
+			require.NoError(t, err)
+
+			// act
+			crv, x, y, err := ECPublicKeyCoordinates(&privateKey.PublicKey)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, test.crv, crv)
+			require.Len(t, x, test.size)
+			require.Len(t, y, test.size)
+		})
+	}
+}
+
+func TestECDSAKeyStrategy_KidIsStable(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	strategy := GetKeyStrategy(config.SigningAlgorithmES256)
+
+	keyPair, err := strategy.Generate(clockService, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+
+	exported, err := strategy.Export(keyPair.PrivateKey())
+	require.NoError(t, err)
+
+	_, importedPub, err := strategy.Import(exported)
+	require.NoError(t, err)
+
+	// act
+	kid, err := computeECDSAPublicKeyKid(importedPub.(*ecdsa.PublicKey))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, keyPair.GetKid(), kid)
+}
+
+func TestECDSAKeyStrategy_RejectsOtherCurve(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	keyPair, err := GetKeyStrategy(config.SigningAlgorithmES384).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+
+	exported, err := GetKeyStrategy(config.SigningAlgorithmES384).Export(keyPair.PrivateKey())
+	require.NoError(t, err)
+
+	// act
+	_, _, err = GetKeyStrategy(config.SigningAlgorithmES256).Import(exported)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestKeyService_Rotation(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	start := time.Now()
+	clockService, clockSetter := clock.NewMockClock(start)
+	policy := repositories.KeyRotationPolicy{
+		RotateAfter:    time.Hour * 24 * 10,
+		ExpireAfter:    time.Hour * 24 * 20,
+		PrePublishLead: time.Hour * 24,
+	}
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	current, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+
+	next, err := testee.PrePublish(clockService, "vs", config.SigningAlgorithmEdDSA, policy, current.RotatesAt())
+	require.NoError(t, err)
+
+	// act & assert: the pre-published key is published but does not sign yet
+	active, err := testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, current.GetKid(), active.GetKid())
+
+	keyPairs, err := testee.GetAllKeys("vs")
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 2)
+	require.Equal(t, KeyStateNext, KeyPairState(keyPairs, next, clockService.Now()))
+
+	// act & assert: the successor takes over when the current key rotates
+	clockSetter(current.RotatesAt())
+	active, err = testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, next.GetKid(), active.GetKid())
+	require.Equal(t, KeyStateRetired, KeyPairState(keyPairs, current, clockService.Now()))
+
+	// retired keys still verify the tokens they signed
+	verificationKey, err := testee.GetVerificationKey("vs", config.SigningAlgorithmEdDSA, current.GetKid())
+	require.NoError(t, err)
+	require.Equal(t, current.PublicKey(), verificationKey.PublicKey())
+}
+
+func TestKeyService_RotateDropsPendingKeys(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	current, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+	_, err = testee.PrePublish(clockService, "vs", config.SigningAlgorithmEdDSA, policy, current.RotatesAt())
+	require.NoError(t, err)
+
+	// act
+	rotated, err := testee.Rotate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+
+	// assert
+	require.NoError(t, err)
+
+	keyPairs, err := testee.GetAllKeys("vs")
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 2)
+	require.Equal(t, KeyStateActive, KeyPairState(keyPairs, rotated, clockService.Now()))
+	require.Equal(t, KeyStateRetired, KeyPairState(keyPairs, current, clockService.Now()))
+
+	active, err := testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, rotated.GetKid(), active.GetKid())
+}
+
+func TestKeyService_RevokedKeyNoLongerVerifies(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	keyPair, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, repositories.DefaultKeyRotationPolicy())
+	require.NoError(t, err)
+	_, err = testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+
+	// act
+	err = testee.Revoke("vs", config.SigningAlgorithmEdDSA, keyPair.GetKid())
+
+	// assert
+	require.NoError(t, err)
+
+	_, err = testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.Error(t, err)
+
+	_, err = testee.GetVerificationKey("vs", config.SigningAlgorithmEdDSA, keyPair.GetKid())
+	require.Error(t, err)
+}
+
+func TestKeyService_KeyRevokedOnAnotherInstanceNoLongerSigns(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	// both instances share the store but have their own cache
+	store := NewMemoryKeyStore()
+	instance := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), store, clockService)
+	otherInstance := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), store, clockService)
+
+	revoked, err := instance.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+	cached, err := instance.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, revoked.GetKid(), cached.GetKid())
+
+	replacement, err := otherInstance.Rotate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+
+	// act
+	err = otherInstance.Revoke("vs", config.SigningAlgorithmEdDSA, revoked.GetKid())
+	require.NoError(t, err)
+
+	// assert
+	active, err := instance.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, replacement.GetKid(), active.GetKid())
+}
+
+func TestKeyService_ImportVerifyOnlyKey(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	active, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+
+	_, legacyKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	serialized, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Export(legacyKey)
+	require.NoError(t, err)
+
+	// act
+	imported, err := testee.Import(clockService, "vs", config.SigningAlgorithmEdDSA, policy, ImportedKey{
+		Kid:        "legacy",
+		PrivateKey: serialized,
+		NotBefore:  utils.Ptr(clockService.Now().Add(time.Hour)),
+		NotAfter:   clockService.Now().Add(time.Hour * 24),
+		VerifyOnly: true,
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.True(t, imported.VerifyOnly())
+
+	keyPairs, err := testee.GetAllKeys("vs")
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 2)
+	require.Equal(t, KeyStateRetired, KeyPairState(keyPairs, imported, clockService.Now()))
+
+	signingKey, err := testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, active.GetKid(), signingKey.GetKid())
+
+	verificationKey, err := testee.GetVerificationKey("vs", config.SigningAlgorithmEdDSA, "legacy")
+	require.NoError(t, err)
+	require.Equal(t, legacyKey.Public(), verificationKey.PublicKey())
+}
+
+func TestKeyService_ImportedSigningKeyTakesOver(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, timeSetter := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	_, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+
+	_, legacyKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	serialized, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Export(legacyKey)
+	require.NoError(t, err)
+
+	notAfter := clockService.Now().Add(time.Hour * 24)
+
+	// act
+	imported, err := testee.Import(clockService, "vs", config.SigningAlgorithmEdDSA, policy, ImportedKey{
+		Kid:        "legacy",
+		PrivateKey: serialized,
+		NotBefore:  utils.Ptr(clockService.Now().Add(time.Minute)),
+		NotAfter:   notAfter,
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, notAfter, imported.RotatesAt())
+
+	timeSetter(clockService.Now().Add(time.Minute))
+	signingKey, err := testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, "legacy", signingKey.GetKid())
+}
+
+func TestKeyService_ImportRejectsKnownKid(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	clockService, _ := clock.NewMockClock(time.Now())
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), NewMemoryKeyStore(), clockService)
+
+	keyPair, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+	serialized, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Export(keyPair.PrivateKey())
+	require.NoError(t, err)
+
+	// act
+	_, err = testee.Import(clockService, "vs", config.SigningAlgorithmEdDSA, policy, ImportedKey{
+		Kid:        keyPair.GetKid(),
+		PrivateKey: serialized,
+		NotAfter:   clockService.Now().Add(time.Hour),
+	})
+
+	// assert
+	require.ErrorIs(t, err, utils.ErrSigningKeyExists)
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// masterKeySize is the size of the master key-encryption key and of the
+// data keys it wraps (AES-256).
+const masterKeySize = 32
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newMasterKey(encoded string) (masterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return masterKey{}, fmt.Errorf("decoding master key: %w", err)
+	}
+	if len(key) != masterKeySize {
+		return masterKey{}, fmt.Errorf("master key must be %d bytes, got %d", masterKeySize, len(key))
+	}
+
+	aead, err := newAead(key)
+	if err != nil {
+		return masterKey{}, err
+	}
+
+	hash := sha256.Sum256(key)
+	return masterKey{
+		id:   hex.EncodeToString(hash[:8]),
+		aead: aead,
+	}, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// KeyEncryption implements envelope encryption for private keys at rest:
+// every key is encrypted with a random data key, and the data key is
+// wrapped with the master key. Rotating the master key therefore only
+// requires re-wrapping the data keys, see Rewrap.
+type KeyEncryption struct {
+	current  masterKey
+	previous map[string]masterKey
+}
+
+// NewKeyEncryption creates a KeyEncryption from base64 encoded 256-bit
+// master keys. Previous master keys are only used for decryption.
+func NewKeyEncryption(currentMasterKey string, previousMasterKeys []string) (*KeyEncryption, error) {
+	current, err := newMasterKey(currentMasterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]masterKey, len(previousMasterKeys))
+	for i, encoded := range previousMasterKeys {
+		key, err := newMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("previous master key %d: %w", i, err)
+		}
+		previous[key.id] = key
+	}
+
+	return &KeyEncryption{
+		current:  current,
+		previous: previous,
+	}, nil
+}
+
+// CurrentMasterKeyId identifies the master key new data keys are wrapped
+// with.
+func (e *KeyEncryption) CurrentMasterKeyId() string {
+	return e.current.id
+}
+
+func (e *KeyEncryption) masterKey(masterKeyId string) (masterKey, error) {
+	if masterKeyId == e.current.id {
+		return e.current, nil
+	}
+
+	key, ok := e.previous[masterKeyId]
+	if !ok {
+		return masterKey{}, fmt.Errorf("unknown master key %s", masterKeyId)
+	}
+
+	return key, nil
+}
+
+// Encrypt encrypts the plaintext with a new data key and wraps the data key
+// with the current master key. The additional data is authenticated but not
+// encrypted and must be passed to Decrypt unchanged.
+func (e *KeyEncryption) Encrypt(plaintext []byte, additionalData []byte) (ciphertext []byte, wrappedDataKey []byte, masterKeyId string, err error) {
+	dataKey := make([]byte, masterKeySize)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("generating data key: %w", err)
+	}
+
+	aead, err := newAead(dataKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	ciphertext, err = seal(aead, plaintext, additionalData)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("encrypting: %w", err)
+	}
+
+	wrappedDataKey, err = seal(e.current.aead, dataKey, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	return ciphertext, wrappedDataKey, e.current.id, nil
+}
+
+func (e *KeyEncryption) unwrap(wrappedDataKey []byte, masterKeyId string) ([]byte, error) {
+	key, err := e.masterKey(masterKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := open(key.aead, wrappedDataKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// Decrypt reverses Encrypt. The data key may be wrapped with the current or
+// any of the previous master keys.
+func (e *KeyEncryption) Decrypt(ciphertext []byte, wrappedDataKey []byte, masterKeyId string, additionalData []byte) ([]byte, error) {
+	dataKey, err := e.unwrap(wrappedDataKey, masterKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAead(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(aead, ciphertext, additionalData)
+}
+
+// Rewrap wraps a data key with the current master key. The encrypted
+// private key itself stays untouched.
+func (e *KeyEncryption) Rewrap(wrappedDataKey []byte, masterKeyId string) ([]byte, string, error) {
+	dataKey, err := e.unwrap(wrappedDataKey, masterKeyId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rewrapped, err := seal(e.current.aead, dataKey, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	return rewrapped, e.current.id, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMasterKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, masterKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestKeyEncryption_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	testee, err := NewKeyEncryption(newTestMasterKey(t), nil)
+	require.NoError(t, err)
+
+	// act
+	ciphertext, wrappedDataKey, masterKeyId, err := testee.Encrypt([]byte("private key"), []byte("aad"))
+	require.NoError(t, err)
+	plaintext, err := testee.Decrypt(ciphertext, wrappedDataKey, masterKeyId, []byte("aad"))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "private key", string(plaintext))
+	require.Equal(t, testee.CurrentMasterKeyId(), masterKeyId)
+	require.NotContains(t, string(ciphertext), "private key")
+}
+
+func TestKeyEncryption_RejectsOtherAdditionalData(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	testee, err := NewKeyEncryption(newTestMasterKey(t), nil)
+	require.NoError(t, err)
+	ciphertext, wrappedDataKey, masterKeyId, err := testee.Encrypt([]byte("private key"), []byte("aad"))
+	require.NoError(t, err)
+
+	// act
+	_, err = testee.Decrypt(ciphertext, wrappedDataKey, masterKeyId, []byte("other"))
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestKeyEncryption_RewrapWithNewMasterKey(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	oldMasterKey := newTestMasterKey(t)
+	old, err := NewKeyEncryption(oldMasterKey, nil)
+	require.NoError(t, err)
+	ciphertext, wrappedDataKey, oldMasterKeyId, err := old.Encrypt([]byte("private key"), nil)
+	require.NoError(t, err)
+
+	testee, err := NewKeyEncryption(newTestMasterKey(t), []string{oldMasterKey})
+	require.NoError(t, err)
+
+	// act
+	rewrapped, masterKeyId, err := testee.Rewrap(wrappedDataKey, oldMasterKeyId)
+	require.NoError(t, err)
+
+	// assert
+	require.Equal(t, testee.CurrentMasterKeyId(), masterKeyId)
+	require.NotEqual(t, oldMasterKeyId, masterKeyId)
+
+	withoutOldKey, err := NewKeyEncryption(newTestMasterKey(t), nil)
+	require.NoError(t, err)
+	_, err = withoutOldKey.Decrypt(ciphertext, wrappedDataKey, oldMasterKeyId, nil)
+	require.Error(t, err)
+
+	plaintext, err := testee.Decrypt(ciphertext, rewrapped, masterKeyId, nil)
+	require.NoError(t, err)
+	require.Equal(t, "private key", string(plaintext))
+}
+
+func TestKeyEncryption_RejectsInvalidMasterKey(t *testing.T) {
+	t.Parallel()
+
+	// act
+	_, err := NewKeyEncryption(base64.StdEncoding.EncodeToString([]byte("too short")), nil)
+
+	// assert
+	require.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// minImportedRsaKeyBits is the smallest RSA modulus accepted for imported
+// keys.
+const minImportedRsaKeyBits = 2048
+
+// decodePrivateKey parses a private key given either as a PEM block
+// (PKCS #1, SEC 1 or PKCS #8) or as a JWK.
+func decodePrivateKey(serializedPrivateKey string) (any, error) {
+	trimmed := strings.TrimSpace(serializedPrivateKey)
+	if strings.HasPrefix(trimmed, "{") {
+		return decodeJwkPrivateKey([]byte(trimmed))
+	}
+
+	block, _ := pem.Decode([]byte(trimmed))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS1 private key: %w", err)
+		}
+		return key, nil
+
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing EC private key: %w", err)
+		}
+		return key, nil
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS8 private key: %w", err)
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %s", block.Type)
+	}
+}
+
+type privateJwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+
+	// RSA
+	N  string `json:"n"`
+	E  string `json:"e"`
+	D  string `json:"d"`
+	P  string `json:"p"`
+	Q  string `json:"q"`
+	Dp string `json:"dp"`
+	Dq string `json:"dq"`
+	Qi string `json:"qi"`
+
+	// EC and OKP
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+func decodeJwkPrivateKey(data []byte) (any, error) {
+	var jwk privateJwk
+	err := json.Unmarshal(data, &jwk)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling jwk: %w", err)
+	}
+
+	if jwk.D == "" {
+		return nil, fmt.Errorf("jwk does not contain a private key")
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return decodeRsaJwk(jwk)
+	case "EC":
+		return decodeEcJwk(jwk)
+	case "OKP":
+		return decodeOkpJwk(jwk)
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type %q", jwk.Kty)
+	}
+}
+
+func decodeJwkInt(name string, value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding jwk member %s: %w", name, err)
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing jwk member %s", name)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func decodeRsaJwk(jwk privateJwk) (*rsa.PrivateKey, error) {
+	members := map[string]string{"n": jwk.N, "e": jwk.E, "d": jwk.D, "p": jwk.P, "q": jwk.Q}
+	values := make(map[string]*big.Int, len(members))
+	for name, value := range members {
+		i, err := decodeJwkInt(name, value)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = i
+	}
+
+	if !values["e"].IsInt64() || values["e"].Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid rsa public exponent")
+	}
+
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: values["n"],
+			E: int(values["e"].Int64()),
+		},
+		D:      values["d"],
+		Primes: []*big.Int{values["p"], values["q"]},
+	}
+
+	err := key.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("validating rsa key: %w", err)
+	}
+	key.Precompute()
+
+	return key, nil
+}
+
+func decodeEcJwk(jwk privateJwk) (*ecdsa.PrivateKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported jwk curve %q", jwk.Crv)
+	}
+
+	d, err := base64.RawURLEncoding.DecodeString(jwk.D)
+	if err != nil {
+		return nil, fmt.Errorf("decoding jwk member d: %w", err)
+	}
+
+	key, err := ecdsa.ParseRawPrivateKey(curve, d)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ec key: %w", err)
+	}
+
+	_, x, y, err := ECPublicKeyCoordinates(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.X != base64.RawURLEncoding.EncodeToString(x) || jwk.Y != base64.RawURLEncoding.EncodeToString(y) {
+		return nil, fmt.Errorf("jwk public key does not match the private key")
+	}
+
+	return key, nil
+}
+
+func decodeOkpJwk(jwk privateJwk) (ed25519.PrivateKey, error) {
+	if jwk.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported jwk curve %q", jwk.Crv)
+	}
+
+	seed, err := base64.RawURLEncoding.DecodeString(jwk.D)
+	if err != nil {
+		return nil, fmt.Errorf("decoding jwk member d: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid ed25519 seed length %d", len(seed))
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+	if jwk.X != base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)) {
+		return nil, fmt.Errorf("jwk public key does not match the private key")
+	}
+
+	return key, nil
+}
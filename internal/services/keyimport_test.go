@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/The127/Keyline/config"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeJwkInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func privateKeyJwk(t *testing.T, privateKey any) string {
+	t.Helper()
+
+	var jwk map[string]string
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		jwk = map[string]string{
+			"kty": "RSA",
+			"n":   encodeJwkInt(privateKey.N),
+			"e":   encodeJwkInt(big.NewInt(int64(privateKey.E))),
+			"d":   encodeJwkInt(privateKey.D),
+			"p":   encodeJwkInt(privateKey.Primes[0]),
+			"q":   encodeJwkInt(privateKey.Primes[1]),
+		}
+
+	case *ecdsa.PrivateKey:
+		crv, x, y, err := ECPublicKeyCoordinates(&privateKey.PublicKey)
+		require.NoError(t, err)
+		d, err := privateKey.Bytes()
+		require.NoError(t, err)
+		jwk = map[string]string{
+			"kty": "EC",
+			"crv": crv,
+			"x":   base64.RawURLEncoding.EncodeToString(x),
+			"y":   base64.RawURLEncoding.EncodeToString(y),
+			"d":   base64.RawURLEncoding.EncodeToString(d),
+		}
+
+	case ed25519.PrivateKey:
+		jwk = map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+			"d":   base64.RawURLEncoding.EncodeToString(privateKey.Seed()),
+		}
+
+	default:
+		t.Fatalf("unsupported key type %T", privateKey)
+	}
+
+	b, err := json.Marshal(jwk)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestKeyStrategy_ImportJwk(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		alg        config.SigningAlgorithm
+		privateKey any
+	}{
+		{"RSA", config.SigningAlgorithmPS256, rsaKey},
+		{"EC", config.SigningAlgorithmES384, ecKey},
+		{"OKP", config.SigningAlgorithmEdDSA, edKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			strategy := GetKeyStrategy(test.alg)
+
+			// act
+			privateKey, _, err := strategy.Import(privateKeyJwk(t, test.privateKey))
+
+			// assert
+			require.NoError(t, err)
+			require.True(t, test.privateKey.(interface{ Equal(crypto.PrivateKey) bool }).Equal(privateKey))
+		})
+	}
+}
+
+func TestKeyStrategy_ImportPkcs8Rsa(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	serialized := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	// act
+	privateKey, _, err := GetKeyStrategy(config.SigningAlgorithmRS256).Import(serialized)
+
+	// assert
+	require.NoError(t, err)
+	require.True(t, rsaKey.Equal(privateKey))
+}
+
+func TestKeyStrategy_ImportRejectsWeakRsaKey(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	serialized := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+
+	// act
+	_, _, err = GetKeyStrategy(config.SigningAlgorithmRS256).Import(serialized)
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestKeyStrategy_ImportRejectsMismatchingJwk(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var jwk map[string]string
+	require.NoError(t, json.Unmarshal([]byte(privateKeyJwk(t, edKey)), &jwk))
+	jwk["x"] = base64.RawURLEncoding.EncodeToString(otherPublicKey)
+	serialized, err := json.Marshal(jwk)
+	require.NoError(t, err)
+
+	// act
+	_, _, err = GetKeyStrategy(config.SigningAlgorithmEdDSA).Import(string(serialized))
+
+	// assert
+	require.Error(t, err)
+}
+
+func TestKeyStrategy_ImportRejectsPublicJwk(t *testing.T) {
+	t.Parallel()
+
+	// act
+	_, _, err := GetKeyStrategy(config.SigningAlgorithmEdDSA).Import(`{"kty":"OKP","crv":"Ed25519","x":"abc"}`)
+
+	// assert
+	require.Error(t, err)
+}
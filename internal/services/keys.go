@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -14,6 +16,7 @@ import (
 	"fmt"
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/repositories"
 	"os"
 	"path/filepath"
 	"strings"
@@ -27,15 +30,27 @@ import (
 )
 
 type KeyAlgorithmStrategy interface {
-	Generate(clockService clock.Service) (KeyPair, error)
+	Generate(clockService clock.Service, policy repositories.KeyRotationPolicy) (KeyPair, error)
 	Import(serializedPrivateKey string) (any, any, error)
 	Export(privateKey any) (string, error)
 }
 
 func GetKeyStrategy(algorithm config.SigningAlgorithm) KeyAlgorithmStrategy {
 	switch algorithm {
-	case config.SigningAlgorithmRS256:
-		return &RSAKeyStrategy{}
+	case config.SigningAlgorithmRS256,
+		config.SigningAlgorithmRS384,
+		config.SigningAlgorithmRS512,
+		config.SigningAlgorithmPS256:
+		return &RSAKeyStrategy{algorithm: algorithm}
+
+	case config.SigningAlgorithmES256:
+		return &ECDSAKeyStrategy{algorithm: algorithm, curve: elliptic.P256()}
+
+	case config.SigningAlgorithmES384:
+		return &ECDSAKeyStrategy{algorithm: algorithm, curve: elliptic.P384()}
+
+	case config.SigningAlgorithmES512:
+		return &ECDSAKeyStrategy{algorithm: algorithm, curve: elliptic.P521()}
 
 	case config.SigningAlgorithmEdDSA:
 		return &EdDSAKeyStrategy{}
@@ -45,9 +60,13 @@ func GetKeyStrategy(algorithm config.SigningAlgorithm) KeyAlgorithmStrategy {
 	}
 }
 
-type RSAKeyStrategy struct{}
+// RSAKeyStrategy generates the keys of the RSA based algorithms. RSASSA-PSS
+// uses the same key material as PKCS #1 v1.5, only the signature differs.
+type RSAKeyStrategy struct {
+	algorithm config.SigningAlgorithm
+}
 
-func (s *RSAKeyStrategy) Generate(service clock.Service) (KeyPair, error) {
+func (s *RSAKeyStrategy) Generate(service clock.Service, policy repositories.KeyRotationPolicy) (KeyPair, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("generating key pair: %w", err)
@@ -62,13 +81,14 @@ func (s *RSAKeyStrategy) Generate(service clock.Service) (KeyPair, error) {
 	now := service.Now()
 
 	return KeyPair{
-		algorithm:  config.SigningAlgorithmRS256,
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		kid:        kid,
-		createdAt:  now, // TODO: use virtual server config for rotate and expires
-		rotatesAt:  now.Add(time.Hour * 24 * 20),
-		expiresAt:  now.Add(time.Hour * 24 * 30),
+		algorithm:   s.algorithm,
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		kid:         kid,
+		createdAt:   now,
+		activatesAt: now,
+		rotatesAt:   now.Add(policy.RotateAfter),
+		expiresAt:   now.Add(policy.ExpireAfter),
 	}, nil
 }
 
@@ -103,17 +123,20 @@ func bigIntToBytes(i int) []byte {
 }
 
 func (s *RSAKeyStrategy) Import(serializedPrivateKey string) (any, any, error) {
-	block, _ := pem.Decode([]byte(serializedPrivateKey))
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
-		return nil, nil, fmt.Errorf("failed to decode PEM block containing RSA private key")
+	key, err := decodePrivateKey(serializedPrivateKey)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, nil, fmt.Errorf("parsing PKCS1 private key: %w", err)
+	rsaPrivateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("not an RSA private key")
+	}
+	if rsaPrivateKey.N.BitLen() < minImportedRsaKeyBits {
+		return nil, nil, fmt.Errorf("RSA keys need at least %d bits, got %d", minImportedRsaKeyBits, rsaPrivateKey.N.BitLen())
 	}
 
-	return key, &key.PublicKey, nil
+	return rsaPrivateKey, &rsaPrivateKey.PublicKey, nil
 }
 
 func (s *RSAKeyStrategy) Export(privateKey any) (string, error) {
@@ -131,9 +154,113 @@ func (s *RSAKeyStrategy) Export(privateKey any) (string, error) {
 	return string(pem.EncodeToMemory(pemBlock)), nil
 }
 
+type ECDSAKeyStrategy struct {
+	algorithm config.SigningAlgorithm
+	curve     elliptic.Curve
+}
+
+func (s *ECDSAKeyStrategy) Generate(clockService clock.Service, policy repositories.KeyRotationPolicy) (KeyPair, error) {
+	privateKey, err := ecdsa.GenerateKey(s.curve, rand.Reader)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("generating key pair: %w", err)
+	}
+
+	kid, err := computeECDSAPublicKeyKid(&privateKey.PublicKey)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("computing kid: %w", err)
+	}
+
+	now := clockService.Now()
+
+	return KeyPair{
+		algorithm:   s.algorithm,
+		publicKey:   &privateKey.PublicKey,
+		privateKey:  privateKey,
+		kid:         kid,
+		createdAt:   now,
+		activatesAt: now,
+		rotatesAt:   now.Add(policy.RotateAfter),
+		expiresAt:   now.Add(policy.ExpireAfter),
+	}, nil
+}
+
+// ECPublicKeyCoordinates returns the curve name and the x and y coordinates
+// of an EC public key as used in JWKs. The coordinates are padded to the
+// size of the curve.
+func ECPublicKeyCoordinates(publicKey *ecdsa.PublicKey) (string, []byte, []byte, error) {
+	ecdhPublicKey, err := publicKey.ECDH()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("converting public key: %w", err)
+	}
+
+	// uncompressed point: 0x04 || x || y
+	point := ecdhPublicKey.Bytes()
+	size := (len(point) - 1) / 2
+	return publicKey.Curve.Params().Name, point[1 : 1+size], point[1+size:], nil
+}
+
+func computeECDSAPublicKeyKid(publicKey *ecdsa.PublicKey) (string, error) {
+	crv, x, y, err := ECPublicKeyCoordinates(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	// RFC 7638: the required members in lexicographic order
+	jwk := map[string]string{
+		"crv": crv,
+		"kty": "EC",
+		"x":   base64.RawURLEncoding.EncodeToString(x),
+		"y":   base64.RawURLEncoding.EncodeToString(y),
+	}
+
+	b, err := json.Marshal(jwk)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+func (s *ECDSAKeyStrategy) Export(privateKey any) (string, error) {
+	ecdsaPrivateKey, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("invalid private key type, expected *ecdsa.PrivateKey got %T", privateKey)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(ecdsaPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("marshalling private key: %w", err)
+	}
+
+	pemBlock := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}
+
+	return string(pem.EncodeToMemory(pemBlock)), nil
+}
+
+func (s *ECDSAKeyStrategy) Import(serializedPrivateKey string) (any, any, error) {
+	key, err := decodePrivateKey(serializedPrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ecdsaPrivateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("not an ECDSA private key")
+	}
+	if ecdsaPrivateKey.Curve != s.curve {
+		return nil, nil, fmt.Errorf("expected a %s key for %s, got %s", s.curve.Params().Name, s.algorithm, ecdsaPrivateKey.Curve.Params().Name)
+	}
+
+	return ecdsaPrivateKey, &ecdsaPrivateKey.PublicKey, nil
+}
+
 type EdDSAKeyStrategy struct{}
 
-func (s *EdDSAKeyStrategy) Generate(clockService clock.Service) (KeyPair, error) {
+func (s *EdDSAKeyStrategy) Generate(clockService clock.Service, policy repositories.KeyRotationPolicy) (KeyPair, error) {
 	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("generating key pair: %w", err)
@@ -144,13 +271,14 @@ func (s *EdDSAKeyStrategy) Generate(clockService clock.Service) (KeyPair, error)
 	now := clockService.Now()
 
 	return KeyPair{
-		algorithm:  config.SigningAlgorithmEdDSA,
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		kid:        kid,
-		createdAt:  now, // TODO: use virtual server config for rotate and expires
-		rotatesAt:  now.Add(time.Hour * 24 * 20),
-		expiresAt:  now.Add(time.Hour * 24 * 30),
+		algorithm:   config.SigningAlgorithmEdDSA,
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		kid:         kid,
+		createdAt:   now,
+		activatesAt: now,
+		rotatesAt:   now.Add(policy.RotateAfter),
+		expiresAt:   now.Add(policy.ExpireAfter),
 	}, nil
 }
 
@@ -179,14 +307,9 @@ func (s *EdDSAKeyStrategy) Export(privateKey any) (string, error) {
 }
 
 func (s *EdDSAKeyStrategy) Import(serializedPrivateKey string) (any, any, error) {
-	block, _ := pem.Decode([]byte(serializedPrivateKey))
-	if block == nil {
-		return nil, nil, fmt.Errorf("failed to decode PEM block")
-	}
-
-	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	key, err := decodePrivateKey(serializedPrivateKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing PKCS8 private key: %w", err)
+		return nil, nil, err
 	}
 
 	ed25519PrivateKey, ok := key.(ed25519.PrivateKey)
@@ -198,6 +321,13 @@ func (s *EdDSAKeyStrategy) Import(serializedPrivateKey string) (any, any, error)
 	return ed25519PrivateKey, publicKey, nil
 }
 
+// KeyGenerator is implemented by key stores whose private keys cannot be
+// exported and therefore have to be generated by the store itself. The
+// returned key pair is stored with Add once its schedule is set.
+type KeyGenerator interface {
+	GenerateKey(virtualServerName string, algorithm config.SigningAlgorithm, createdAt time.Time) (KeyPair, error)
+}
+
 //go:generate mockgen -destination=./mocks/key_store.go -package=mocks Keyline/internal/services KeyStore
 type KeyStore interface {
 	Get(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (*KeyPair, error)
@@ -306,12 +436,14 @@ func (v *vaultKeyStore) Add(virtualServerName string, keyPair KeyPair) error {
 	}
 
 	data, err := json.Marshal(keyPairJson{
-		Algorithm:  string(keyPair.algorithm),
-		PrivateKey: serializedPrivateKey,
-		Kid:        keyPair.kid,
-		CreatedAt:  keyPair.createdAt,
-		RotatesAt:  keyPair.rotatesAt,
-		ExpiresAt:  keyPair.expiresAt,
+		Algorithm:   string(keyPair.algorithm),
+		PrivateKey:  serializedPrivateKey,
+		Kid:         keyPair.kid,
+		CreatedAt:   keyPair.createdAt,
+		ActivatesAt: keyPair.activatesAt,
+		RotatesAt:   keyPair.rotatesAt,
+		ExpiresAt:   keyPair.expiresAt,
+		VerifyOnly:  keyPair.verifyOnly,
 	})
 	if err != nil {
 		return fmt.Errorf("marshaling key pair: %w", err)
@@ -355,13 +487,15 @@ func (v *vaultKeyStore) Get(virtualServerName string, algorithm config.SigningAl
 	}
 
 	return &KeyPair{
-		algorithm:  config.SigningAlgorithm(dto.Algorithm),
-		publicKey:  pub,
-		privateKey: priv,
-		kid:        dto.Kid,
-		createdAt:  dto.CreatedAt,
-		rotatesAt:  dto.RotatesAt,
-		expiresAt:  dto.ExpiresAt,
+		algorithm:   config.SigningAlgorithm(dto.Algorithm),
+		publicKey:   pub,
+		privateKey:  priv,
+		kid:         dto.Kid,
+		createdAt:   dto.CreatedAt,
+		activatesAt: dto.ActivatesAt,
+		rotatesAt:   dto.RotatesAt,
+		expiresAt:   dto.ExpiresAt,
+		verifyOnly:  dto.VerifyOnly,
 	}, nil
 }
 
@@ -442,12 +576,14 @@ func NewDirectoryKeyStore() KeyStore {
 }
 
 type keyPairJson struct {
-	Algorithm  string    `json:"algorithm"`
-	PrivateKey string    `json:"private_key"`
-	Kid        string    `json:"kid"`
-	CreatedAt  time.Time `json:"created_at"`
-	RotatesAt  time.Time `json:"rotates_at"`
-	ExpiresAt  time.Time `json:"expires_at"`
+	Algorithm   string    `json:"algorithm"`
+	PrivateKey  string    `json:"private_key"`
+	Kid         string    `json:"kid"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at,omitzero"`
+	RotatesAt   time.Time `json:"rotates_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	VerifyOnly  bool      `json:"verify_only,omitempty"`
 }
 
 func (d *directoryKeyStore) Serialize(keyPair KeyPair) ([]byte, error) {
@@ -458,12 +594,14 @@ func (d *directoryKeyStore) Serialize(keyPair KeyPair) ([]byte, error) {
 	}
 
 	dto := keyPairJson{
-		Algorithm:  string(keyPair.algorithm),
-		PrivateKey: serializedPrivateKey,
-		Kid:        keyPair.kid,
-		CreatedAt:  keyPair.createdAt,
-		RotatesAt:  keyPair.rotatesAt,
-		ExpiresAt:  keyPair.expiresAt,
+		Algorithm:   string(keyPair.algorithm),
+		PrivateKey:  serializedPrivateKey,
+		Kid:         keyPair.kid,
+		CreatedAt:   keyPair.createdAt,
+		ActivatesAt: keyPair.activatesAt,
+		RotatesAt:   keyPair.rotatesAt,
+		ExpiresAt:   keyPair.expiresAt,
+		VerifyOnly:  keyPair.verifyOnly,
 	}
 
 	bytes, err := json.Marshal(dto)
@@ -488,12 +626,14 @@ func (d *directoryKeyStore) Deserialize(data []byte) (KeyPair, error) {
 	}
 
 	return KeyPair{
-		algorithm:  config.SigningAlgorithm(dto.Algorithm),
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		createdAt:  dto.CreatedAt,
-		rotatesAt:  dto.RotatesAt,
-		expiresAt:  dto.ExpiresAt,
+		algorithm:   config.SigningAlgorithm(dto.Algorithm),
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		createdAt:   dto.CreatedAt,
+		activatesAt: dto.ActivatesAt,
+		rotatesAt:   dto.RotatesAt,
+		expiresAt:   dto.ExpiresAt,
+		verifyOnly:  dto.VerifyOnly,
 	}, nil
 }
 
@@ -594,13 +734,15 @@ func (d *directoryKeyStore) GetAllForAlgorithm(virtualServerName string, algorit
 		}
 
 		keyPairs = append(keyPairs, KeyPair{
-			algorithm:  algorithm,
-			publicKey:  publicKey,
-			privateKey: privateKey,
-			kid:        importedJson.Kid,
-			createdAt:  importedJson.CreatedAt,
-			rotatesAt:  importedJson.RotatesAt,
-			expiresAt:  importedJson.ExpiresAt,
+			algorithm:   algorithm,
+			publicKey:   publicKey,
+			privateKey:  privateKey,
+			kid:         importedJson.Kid,
+			createdAt:   importedJson.CreatedAt,
+			activatesAt: importedJson.ActivatesAt,
+			rotatesAt:   importedJson.RotatesAt,
+			expiresAt:   importedJson.ExpiresAt,
+			verifyOnly:  importedJson.VerifyOnly,
 		})
 	}
 
@@ -647,13 +789,15 @@ func (d *directoryKeyStore) Get(virtualServerName string, algorithm config.Signi
 	}
 
 	return &KeyPair{
-		algorithm:  algorithm,
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		kid:        importedJson.Kid,
-		createdAt:  importedJson.CreatedAt,
-		rotatesAt:  importedJson.RotatesAt,
-		expiresAt:  importedJson.ExpiresAt,
+		algorithm:   algorithm,
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		kid:         importedJson.Kid,
+		createdAt:   importedJson.CreatedAt,
+		activatesAt: importedJson.ActivatesAt,
+		rotatesAt:   importedJson.RotatesAt,
+		expiresAt:   importedJson.ExpiresAt,
+		verifyOnly:  importedJson.VerifyOnly,
 	}, nil
 }
 
@@ -666,7 +810,19 @@ type KeyCacheKey struct {
 	Algorithm         config.SigningAlgorithm
 }
 
-type KeyCache caching.Cache[KeyCacheKey, KeyPair]
+// keyCacheTtl bounds how long another instance may keep signing with a key
+// that was rotated. Revoked keys are never signed with, GetKey checks that a
+// cached key is still stored.
+const keyCacheTtl = time.Minute
+
+// KeyCacheEntry is the active key of an algorithm and the time it has to be
+// looked up again.
+type KeyCacheEntry struct {
+	keyPair    KeyPair
+	validUntil time.Time
+}
+
+type KeyCache caching.Cache[KeyCacheKey, KeyCacheEntry]
 
 type KeyPair struct {
 	algorithm  config.SigningAlgorithm
@@ -674,8 +830,15 @@ type KeyPair struct {
 	privateKey any
 	kid        string
 	createdAt  time.Time
-	rotatesAt  time.Time
-	expiresAt  time.Time
+	// activatesAt is in the future for pre-published keys.
+	activatesAt time.Time
+	rotatesAt   time.Time
+	expiresAt   time.Time
+	// verifyOnly keys are published but never sign tokens.
+	verifyOnly bool
+	// signer signs with a private key that never leaves an external key
+	// store, privateKey is nil then.
+	signer crypto.Signer
 }
 
 func (k *KeyPair) GetKid() string {
@@ -683,17 +846,16 @@ func (k *KeyPair) GetKid() string {
 }
 
 func (k *KeyPair) PublicKeyBytes() []byte {
-	switch k.algorithm {
-	case config.SigningAlgorithmEdDSA:
-		return k.publicKey.(ed25519.PublicKey)
+	switch publicKey := k.publicKey.(type) {
+	case ed25519.PublicKey:
+		return publicKey
 
-	case config.SigningAlgorithmRS256:
-		var rsaPubKey = k.publicKey.(*rsa.PublicKey)
-		rsaPubKeyBytes, err := x509.MarshalPKIXPublicKey(rsaPubKey)
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
 		if err != nil {
 			panic(fmt.Errorf("marshaling public key: %w", err))
 		}
-		return rsaPubKeyBytes
+		return publicKeyBytes
 	default:
 		panic(fmt.Sprintf("not implemented for algorithm: %s", k.algorithm))
 	}
@@ -703,17 +865,37 @@ func (k *KeyPair) PrivateKey() any {
 	return k.privateKey
 }
 
+// Signer returns a crypto.Signer for the private key, which works for keys
+// held in memory as well as for keys that cannot be exported.
+func (k *KeyPair) Signer() (crypto.Signer, error) {
+	if k.signer != nil {
+		return k.signer, nil
+	}
+
+	signer, ok := k.privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key %s cannot be used for signing", k.kid)
+	}
+
+	return signer, nil
+}
+
 func (k *KeyPair) Algorithm() config.SigningAlgorithm {
 	return k.algorithm
 }
 
 func (k *KeyPair) PrivateKeyBytes() []byte {
-	switch k.algorithm {
-	case config.SigningAlgorithmEdDSA:
-		return k.privateKey.(ed25519.PrivateKey)
-	case config.SigningAlgorithmRS256:
-		rsaKey := k.privateKey.(*rsa.PrivateKey)
-		return x509.MarshalPKCS1PrivateKey(rsaKey)
+	switch privateKey := k.privateKey.(type) {
+	case ed25519.PrivateKey:
+		return privateKey
+	case *rsa.PrivateKey:
+		return x509.MarshalPKCS1PrivateKey(privateKey)
+	case *ecdsa.PrivateKey:
+		privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			panic(fmt.Errorf("marshaling private key: %w", err))
+		}
+		return privateKeyBytes
 	default:
 		panic(fmt.Sprintf("not implemented for algorithm: %s", k.algorithm))
 	}
@@ -727,6 +909,15 @@ func (k *KeyPair) CreatedAt() time.Time {
 	return k.createdAt
 }
 
+// ActivatesAt returns when the key starts signing tokens. Keys stored before
+// keys could be pre-published are active from their creation.
+func (k *KeyPair) ActivatesAt() time.Time {
+	if k.activatesAt.IsZero() {
+		return k.createdAt
+	}
+	return k.activatesAt
+}
+
 func (k *KeyPair) RotatesAt() time.Time {
 	return k.rotatesAt
 }
@@ -735,60 +926,310 @@ func (k *KeyPair) ExpiresAt() time.Time {
 	return k.expiresAt
 }
 
+func (k *KeyPair) VerifyOnly() bool {
+	return k.verifyOnly
+}
+
+// activateAt moves the schedule of a new key so that it starts signing at
+// activatesAt.
+func (k *KeyPair) activateAt(activatesAt time.Time, policy repositories.KeyRotationPolicy) {
+	k.activatesAt = activatesAt
+	k.rotatesAt = activatesAt.Add(policy.RotateAfter)
+	k.expiresAt = activatesAt.Add(policy.ExpireAfter)
+}
+
+type KeyState string
+
+const (
+	// KeyStateNext keys are published but do not sign tokens yet.
+	KeyStateNext KeyState = "next"
+	// KeyStateActive keys sign new tokens.
+	KeyStateActive KeyState = "active"
+	// KeyStateRetired keys are only published to verify tokens they signed.
+	KeyStateRetired KeyState = "retired"
+)
+
+// ActiveKeyPair returns the key of an algorithm that signs tokens, which is
+// the most recently activated one. Keys activated at the same time are
+// ordered by their rotation, a rotated key has its rotation moved to the
+// time it was replaced. Verify-only keys are never active.
+func ActiveKeyPair(keyPairs []KeyPair, algorithm config.SigningAlgorithm, now time.Time) (KeyPair, bool) {
+	var active KeyPair
+	found := false
+	for _, keyPair := range keyPairs {
+		if keyPair.algorithm != algorithm || keyPair.verifyOnly || keyPair.ActivatesAt().After(now) {
+			continue
+		}
+		if !found || keyPair.ActivatesAt().After(active.ActivatesAt()) ||
+			keyPair.ActivatesAt().Equal(active.ActivatesAt()) && keyPair.rotatesAt.After(active.rotatesAt) {
+			active = keyPair
+			found = true
+		}
+	}
+	return active, found
+}
+
+// NextKeyPair returns the pre-published successor of the active key of an
+// algorithm.
+func NextKeyPair(keyPairs []KeyPair, algorithm config.SigningAlgorithm, now time.Time) (KeyPair, bool) {
+	var next KeyPair
+	found := false
+	for _, keyPair := range keyPairs {
+		if keyPair.algorithm != algorithm || keyPair.verifyOnly || !keyPair.ActivatesAt().After(now) {
+			continue
+		}
+		if !found || keyPair.ActivatesAt().Before(next.ActivatesAt()) {
+			next = keyPair
+			found = true
+		}
+	}
+	return next, found
+}
+
+func KeyPairState(keyPairs []KeyPair, keyPair KeyPair, now time.Time) KeyState {
+	if keyPair.verifyOnly {
+		return KeyStateRetired
+	}
+
+	if keyPair.ActivatesAt().After(now) {
+		return KeyStateNext
+	}
+
+	active, ok := ActiveKeyPair(keyPairs, keyPair.algorithm, now)
+	if ok && active.kid == keyPair.kid {
+		return KeyStateActive
+	}
+
+	return KeyStateRetired
+}
+
+// ImportedKey is a private key brought from another issuer, given as PEM or
+// JWK.
+type ImportedKey struct {
+	Kid        string
+	PrivateKey string
+	// NotBefore defaults to the time of the import.
+	NotBefore  *time.Time
+	NotAfter   time.Time
+	VerifyOnly bool
+}
+
 //go:generate mockgen -destination=./mocks/key_service.go -package=mocks Keyline/internal/services KeyService
 type KeyService interface {
-	Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm) (KeyPair, error)
+	// Generate creates a key that signs tokens right away.
+	Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (KeyPair, error)
+	// PrePublish creates a key that is published in the JWKS now and starts
+	// signing tokens at activatesAt.
+	PrePublish(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, activatesAt time.Time) (KeyPair, error)
+	// Rotate replaces the active key of an algorithm immediately. Pending
+	// pre-published keys are dropped, the previous key is retired.
+	Rotate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (KeyPair, error)
+	// Import stores an existing private key under the given kid. The key is
+	// published from notBefore until notAfter, verify-only keys never sign
+	// tokens.
+	Import(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, key ImportedKey) (KeyPair, error)
+	// Revoke removes a key so that it is neither used for signing nor
+	// published anymore.
+	Revoke(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error
 	GetKey(virtualServerName string, algorithm config.SigningAlgorithm) (KeyPair, error)
+	// GetVerificationKey returns the published key a token was signed with.
+	// Tokens without a kid are verified with the active key.
+	GetVerificationKey(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (KeyPair, error)
 	GetAllKeys(virtualServerName string) ([]KeyPair, error)
 }
 
 type keyServiceImpl struct {
-	cache KeyCache
-	store KeyStore
+	cache        KeyCache
+	store        KeyStore
+	clockService clock.Service
 }
 
-func NewKeyService(cache KeyCache, store KeyStore) KeyService {
+func NewKeyService(cache KeyCache, store KeyStore, clockService clock.Service) KeyService {
 	return &keyServiceImpl{
-		cache: cache,
-		store: store,
+		cache:        cache,
+		store:        store,
+		clockService: clockService,
 	}
 }
 
-func (s *keyServiceImpl) Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm) (KeyPair, error) {
-	strategy := GetKeyStrategy(algorithm)
-	keyPair, err := strategy.Generate(clockService)
+func (s *keyServiceImpl) Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (KeyPair, error) {
+	return s.generate(clockService, virtualServerName, algorithm, policy, clockService.Now())
+}
+
+func (s *keyServiceImpl) PrePublish(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, activatesAt time.Time) (KeyPair, error) {
+	return s.generate(clockService, virtualServerName, algorithm, policy, activatesAt)
+}
+
+func (s *keyServiceImpl) generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, activatesAt time.Time) (KeyPair, error) {
+	var keyPair KeyPair
+	var err error
+	if generator, ok := s.store.(KeyGenerator); ok {
+		keyPair, err = generator.GenerateKey(virtualServerName, algorithm, clockService.Now())
+	} else {
+		keyPair, err = GetKeyStrategy(algorithm).Generate(clockService, policy)
+	}
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("generating key pair: %w", err)
 	}
+	keyPair.activateAt(activatesAt, policy)
+
+	err = s.store.Add(virtualServerName, keyPair)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("storing key pair: %w", err)
+	}
+
+	s.cache.Clear()
+	return keyPair, nil
+}
+
+func (s *keyServiceImpl) Rotate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (KeyPair, error) {
+	keyPairs, err := s.store.GetAllForAlgorithm(virtualServerName, algorithm)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("getting key pairs: %w", err)
+	}
+
+	now := clockService.Now()
+	for _, keyPair := range keyPairs {
+		switch KeyPairState(keyPairs, keyPair, now) {
+		case KeyStateNext:
+			err = s.store.Remove(virtualServerName, algorithm, keyPair.GetKid())
+			if err != nil {
+				return KeyPair{}, fmt.Errorf("removing pending key pair: %w", err)
+			}
+
+		case KeyStateActive:
+			keyPair.rotatesAt = now
+			err = s.store.Add(virtualServerName, keyPair)
+			if err != nil {
+				return KeyPair{}, fmt.Errorf("retiring key pair: %w", err)
+			}
+		}
+	}
+
+	return s.Generate(clockService, virtualServerName, algorithm, policy)
+}
+
+func (s *keyServiceImpl) Import(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, key ImportedKey) (KeyPair, error) {
+	if _, ok := s.store.(KeyGenerator); ok {
+		return KeyPair{}, fmt.Errorf("the key store does not support importing keys: %w", utils.ErrHttpBadRequest)
+	}
+
+	existing, err := s.store.Get(virtualServerName, algorithm, key.Kid)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("getting key pair: %w", err)
+	}
+	if existing != nil {
+		return KeyPair{}, fmt.Errorf("key %s already exists: %w", key.Kid, utils.ErrSigningKeyExists)
+	}
+
+	strategy := GetKeyStrategy(algorithm)
+	privateKey, publicKey, err := strategy.Import(key.PrivateKey)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("importing key: %w: %w", err, utils.ErrHttpBadRequest)
+	}
+
+	now := clockService.Now()
+	notBefore := now
+	if key.NotBefore != nil {
+		notBefore = *key.NotBefore
+	}
+
+	// an imported signing key is replaced like a generated one, but not
+	// after its validity window ends
+	rotatesAt := notBefore
+	if !key.VerifyOnly {
+		rotatesAt = notBefore.Add(policy.RotateAfter)
+		if rotatesAt.After(key.NotAfter) {
+			rotatesAt = key.NotAfter
+		}
+	}
+
+	keyPair := KeyPair{
+		algorithm:   algorithm,
+		publicKey:   publicKey,
+		privateKey:  privateKey,
+		kid:         key.Kid,
+		createdAt:   now,
+		activatesAt: notBefore,
+		rotatesAt:   rotatesAt,
+		expiresAt:   key.NotAfter,
+		verifyOnly:  key.VerifyOnly,
+	}
 
 	err = s.store.Add(virtualServerName, keyPair)
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("storing key pair: %w", err)
 	}
 
+	s.cache.Clear()
 	return keyPair, nil
 }
 
+func (s *keyServiceImpl) Revoke(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error {
+	err := s.store.Remove(virtualServerName, algorithm, kid)
+	if err != nil {
+		return fmt.Errorf("removing key pair: %w", err)
+	}
+
+	s.cache.Clear()
+	return nil
+}
+
 func (s *keyServiceImpl) GetKey(virtualServerName string, algorithm config.SigningAlgorithm) (KeyPair, error) {
+	now := s.clockService.Now()
+
 	cacheKey := KeyCacheKey{VirtualServerName: virtualServerName, Algorithm: algorithm}
-	keyPair, ok := s.cache.TryGet(cacheKey)
-	if !ok {
-		keyPairs, err := s.store.GetAllForAlgorithm(virtualServerName, algorithm)
+	entry, ok := s.cache.TryGet(cacheKey)
+	if ok && now.Before(entry.validUntil) {
+		// the cache is per instance, a key revoked on another instance
+		// must not sign anything anymore
+		stored, err := s.store.Get(virtualServerName, algorithm, entry.keyPair.GetKid())
 		if err != nil {
-			return KeyPair{}, fmt.Errorf("getting key pairs: %w", err)
+			return KeyPair{}, fmt.Errorf("getting key pair: %w", err)
 		}
-
-		if len(keyPairs) == 0 {
-			return KeyPair{}, fmt.Errorf("no signing keys for virtual server %s: %w", virtualServerName, utils.ErrHttpServiceUnavailable)
+		if stored != nil {
+			return entry.keyPair, nil
 		}
+	}
+
+	keyPairs, err := s.store.GetAllForAlgorithm(virtualServerName, algorithm)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("getting key pairs: %w", err)
+	}
 
-		keyPair = keyPairs[0]
-		s.cache.Put(cacheKey, keyPair)
+	keyPair, ok := ActiveKeyPair(keyPairs, algorithm, now)
+	if !ok {
+		return KeyPair{}, fmt.Errorf("no signing keys for virtual server %s: %w", virtualServerName, utils.ErrHttpServiceUnavailable)
 	}
 
+	validUntil := now.Add(keyCacheTtl)
+	if next, ok := NextKeyPair(keyPairs, algorithm, now); ok && next.ActivatesAt().Before(validUntil) {
+		validUntil = next.ActivatesAt()
+	}
+
+	s.cache.Put(cacheKey, KeyCacheEntry{
+		keyPair:    keyPair,
+		validUntil: validUntil,
+	})
 	return keyPair, nil
 }
 
+func (s *keyServiceImpl) GetVerificationKey(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (KeyPair, error) {
+	if kid == "" {
+		return s.GetKey(virtualServerName, algorithm)
+	}
+
+	keyPair, err := s.store.Get(virtualServerName, algorithm, kid)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("getting key pair: %w", err)
+	}
+	if keyPair == nil {
+		return KeyPair{}, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return *keyPair, nil
+}
+
 func (s *keyServiceImpl) GetAllKeys(virtualServerName string) ([]KeyPair, error) {
 	return s.store.GetAll(virtualServerName)
 }
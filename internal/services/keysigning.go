@@ -0,0 +1,90 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"math/big"
+)
+
+// jwsSignerOpts returns the hash and signer options of a JWS algorithm
+// (RFC 7518 section 3). EdDSA signs the message itself, so its hash is 0.
+func jwsSignerOpts(algorithm config.SigningAlgorithm) (crypto.Hash, crypto.SignerOpts, error) {
+	switch algorithm {
+	case config.SigningAlgorithmRS256, config.SigningAlgorithmES256:
+		return crypto.SHA256, crypto.SHA256, nil
+
+	case config.SigningAlgorithmRS384, config.SigningAlgorithmES384:
+		return crypto.SHA384, crypto.SHA384, nil
+
+	case config.SigningAlgorithmRS512, config.SigningAlgorithmES512:
+		return crypto.SHA512, crypto.SHA512, nil
+
+	case config.SigningAlgorithmPS256:
+		return crypto.SHA256, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+
+	case config.SigningAlgorithmEdDSA:
+		return 0, crypto.Hash(0), nil
+
+	default:
+		return 0, nil, fmt.Errorf("not implemented for algorithm: %s", algorithm)
+	}
+}
+
+// Sign creates the JWS signature of the signing input, i.e. of the encoded
+// header and payload of a token.
+func (k *KeyPair) Sign(signingInput []byte) ([]byte, error) {
+	signer, err := k.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, opts, err := jwsSignerOpts(k.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := signingInput
+	if hash != 0 {
+		hasher := hash.New()
+		hasher.Write(signingInput)
+		digest = hasher.Sum(nil)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+
+	publicKey, ok := k.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return signature, nil
+	}
+
+	return ecdsaSignatureToJws(signature, publicKey)
+}
+
+// ecdsaSignatureToJws converts an ASN.1 encoded ECDSA signature, as returned
+// by crypto.Signer, into the fixed size r || s encoding of JWS.
+func ecdsaSignatureToJws(signature []byte, publicKey *ecdsa.PublicKey) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(signature, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ecdsa signature: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after ecdsa signature")
+	}
+
+	size := (publicKey.Curve.Params().BitSize + 7) / 8
+	jws := make([]byte, 2*size)
+	parsed.R.FillBytes(jws[:size])
+	parsed.S.FillBytes(jws[size:])
+	return jws, nil
+}
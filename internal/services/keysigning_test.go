@@ -0,0 +1,54 @@
+package services
+
+import (
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/repositories"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// signTestJwt signs a token the way the token endpoint does and parses it
+// again with the public key.
+func signTestJwt(t *testing.T, keyPair KeyPair) {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(string(keyPair.Algorithm())), jwt.MapClaims{"sub": "user"})
+	signingString, err := token.SigningString()
+	require.NoError(t, err)
+
+	signature, err := keyPair.Sign([]byte(signingString))
+	require.NoError(t, err)
+	signed := signingString + "." + token.EncodeSegment(signature)
+
+	parsed, err := jwt.Parse(signed, func(token *jwt.Token) (any, error) {
+		return keyPair.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{string(keyPair.Algorithm())}))
+	require.NoError(t, err)
+	require.True(t, parsed.Valid)
+	require.Equal(t, 3, len(strings.Split(signed, ".")))
+}
+
+func TestKeyPair_Sign(t *testing.T) {
+	t.Parallel()
+
+	clockService, _ := clock.NewMockClock(time.Now())
+
+	for _, alg := range config.SupportedSigningAlgorithms {
+		t.Run(string(alg), func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			keyPair, err := GetKeyStrategy(alg).Generate(clockService, repositories.DefaultKeyRotationPolicy())
+			require.NoError(t, err)
+
+			// act & assert
+			signTestJwt(t, keyPair)
+		})
+	}
+}
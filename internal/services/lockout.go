@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"strconv"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// lockoutStateRetention is how long a lockout state is kept after its last
+// lockout ended, so that repeated lockouts keep backing off.
+const lockoutStateRetention = time.Hour * 24
+
+var ErrAccountLocked = fmt.Errorf("account is locked: %w", utils.ErrHttpTooManyRequests)
+var ErrIpThrottled = fmt.Errorf("too many failed attempts from this address: %w", utils.ErrHttpTooManyRequests)
+
+// LockoutState counts the failed password attempts of a user or a source
+// IP since the last lockout.
+type LockoutState struct {
+	FailedAttempts int
+	Lockouts       int
+	LockedUntil    *time.Time
+	Permanent      bool
+}
+
+func (s *LockoutState) Locked(now time.Time) bool {
+	return s.Permanent || (s.LockedUntil != nil && now.Before(*s.LockedUntil))
+}
+
+// lockoutRecord is what is stored for the last lockout. The failed
+// attempts are counted separately with an atomic increment, so parallel
+// attempts cannot overwrite each other's count.
+type lockoutRecord struct {
+	// Failures is the counter at the lockout, failed attempts since then
+	// are the ones above it.
+	Failures    int64      `json:"failures"`
+	Lockouts    int        `json:"lockouts"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// expiration returns how long the record has to be kept, zero means
+// forever. Only permanent lockouts have no end.
+func (r *lockoutRecord) expiration(now time.Time) time.Duration {
+	if r.LockedUntil == nil {
+		return 0
+	}
+	if r.LockedUntil.After(now) {
+		return r.LockedUntil.Sub(now) + lockoutStateRetention
+	}
+	return lockoutStateRetention
+}
+
+// LockoutService throttles password guessing across login sessions. The
+// counters are kept in the key value store so that they are shared by all
+// instances.
+type LockoutService interface {
+	CheckUser(ctx context.Context, userId uuid.UUID) error
+	CheckIp(ctx context.Context, virtualServerId uuid.UUID, ip string) error
+	// RecordFailure counts a failed attempt against the source IP and, if
+	// known, the user. It returns the state of the user and whether the
+	// attempt locked them out.
+	RecordFailure(ctx context.Context, policy repositories.LockoutPolicy, virtualServerId uuid.UUID, userId uuid.UUID, ip string) (*LockoutState, bool, error)
+	RecordSuccess(ctx context.Context, userId uuid.UUID) error
+	// GetUserState returns nil if the user has no failed attempts.
+	GetUserState(ctx context.Context, userId uuid.UUID) (*LockoutState, error)
+	ClearUserState(ctx context.Context, userId uuid.UUID) error
+}
+
+type lockoutService struct {
+}
+
+func NewLockoutService() LockoutService {
+	return &lockoutService{}
+}
+
+func userLockoutKey(userId uuid.UUID) string {
+	return fmt.Sprintf("lockout:user:%s", userId)
+}
+
+func ipLockoutKey(virtualServerId uuid.UUID, ip string) string {
+	return fmt.Sprintf("lockout:ip:%s:%s", virtualServerId, ip)
+}
+
+func failuresKey(lockoutKey string) string {
+	return lockoutKey + ":failures"
+}
+
+// permanentKey is kept apart from the record, so that a temporary lockout
+// written by a parallel attempt cannot lift a permanent one.
+func permanentKey(lockoutKey string) string {
+	return lockoutKey + ":permanent"
+}
+
+func (s *lockoutService) CheckUser(ctx context.Context, userId uuid.UUID) error {
+	return s.check(ctx, userLockoutKey(userId), ErrAccountLocked)
+}
+
+func (s *lockoutService) CheckIp(ctx context.Context, virtualServerId uuid.UUID, ip string) error {
+	return s.check(ctx, ipLockoutKey(virtualServerId, ip), ErrIpThrottled)
+}
+
+func (s *lockoutService) check(ctx context.Context, key string, lockedErr error) error {
+	state, err := s.getState(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	scope := middlewares.GetScope(ctx)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	if state != nil && state.Locked(clockService.Now()) {
+		return lockedErr
+	}
+
+	return nil
+}
+
+func (s *lockoutService) RecordFailure(ctx context.Context, policy repositories.LockoutPolicy, virtualServerId uuid.UUID, userId uuid.UUID, ip string) (*LockoutState, bool, error) {
+	if policy.MaxFailedAttemptsPerIp > 0 {
+		// source IPs are only ever locked out temporarily
+		_, _, err := s.recordFailure(ctx, ipLockoutKey(virtualServerId, ip), policy, policy.MaxFailedAttemptsPerIp, 0)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if userId == uuid.Nil || policy.MaxFailedAttempts == 0 {
+		return nil, false, nil
+	}
+
+	return s.recordFailure(ctx, userLockoutKey(userId), policy, policy.MaxFailedAttempts, policy.PermanentLockoutAfter)
+}
+
+// recordFailure counts a failed attempt and locks on every
+// maxFailedAttempts-th one. The permanentAfter-th lockout is permanent,
+// zero disables permanent lockouts. It reports whether the attempt caused
+// a lockout.
+func (s *lockoutService) recordFailure(ctx context.Context, key string, policy repositories.LockoutPolicy, maxFailedAttempts int, permanentAfter int) (*LockoutState, bool, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	// the counter outlives the record of the longest lockout, so that
+	// repeated lockouts keep backing off
+	failures, err := kvStore.Increment(ctx, failuresKey(key), policy.MaxLockoutDuration+lockoutStateRetention)
+	if err != nil {
+		return nil, false, fmt.Errorf("counting failed attempt: %w", err)
+	}
+
+	// every count is handed out once, so exactly one of several parallel
+	// attempts reaches the limit
+	if failures%int64(maxFailedAttempts) != 0 {
+		state, err := s.getState(ctx, key)
+		return state, false, err
+	}
+
+	record := lockoutRecord{
+		Failures: failures,
+		Lockouts: int(failures / int64(maxFailedAttempts)),
+	}
+	if permanentAfter > 0 && record.Lockouts >= permanentAfter {
+		err = kvStore.Set(ctx, permanentKey(key), "true")
+		if err != nil {
+			return nil, false, fmt.Errorf("storing permanent lockout: %w", err)
+		}
+	} else {
+		lockedUntil := now.Add(policy.LockoutDurationFor(record.Lockouts))
+		record.LockedUntil = &lockedUntil
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, fmt.Errorf("encoding lockout record: %w", err)
+	}
+
+	err = kvStore.Set(ctx, key, string(value), keyValue.WithExpiration(record.expiration(now)))
+	if err != nil {
+		return nil, false, fmt.Errorf("storing lockout record: %w", err)
+	}
+
+	state, err := s.getState(ctx, key)
+	return state, true, err
+}
+
+// RecordSuccess forgets the failed attempts of a user once a login is
+// finished with every factor it needed. The counter of the
+// source IP is kept, otherwise an attacker could reset it by logging into
+// an account of their own in between.
+func (s *lockoutService) RecordSuccess(ctx context.Context, userId uuid.UUID) error {
+	return s.ClearUserState(ctx, userId)
+}
+
+func (s *lockoutService) GetUserState(ctx context.Context, userId uuid.UUID) (*LockoutState, error) {
+	return s.getState(ctx, userLockoutKey(userId))
+}
+
+func (s *lockoutService) ClearUserState(ctx context.Context, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+
+	key := userLockoutKey(userId)
+	for _, k := range []string{key, failuresKey(key), permanentKey(key)} {
+		err := kvStore.Delete(ctx, k)
+		if err != nil && !errors.Is(err, keyValue.ErrNotFound) {
+			return fmt.Errorf("deleting lockout state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getState combines the failure counter, the record of the last lockout
+// and the permanent lockout flag. It returns nil if there is none of them.
+func (s *lockoutService) getState(ctx context.Context, key string) (*LockoutState, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+
+	var failures int64
+	value, err := kvStore.Get(ctx, failuresKey(key))
+	switch {
+	case errors.Is(err, keyValue.ErrNotFound):
+
+	case err != nil:
+		return nil, fmt.Errorf("getting failed attempts: %w", err)
+
+	default:
+		failures, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("decoding failed attempts: %w", err)
+		}
+	}
+
+	var record lockoutRecord
+	value, err = kvStore.Get(ctx, key)
+	switch {
+	case errors.Is(err, keyValue.ErrNotFound):
+
+	case err != nil:
+		return nil, fmt.Errorf("getting lockout record: %w", err)
+
+	default:
+		err = json.Unmarshal([]byte(value), &record)
+		if err != nil {
+			return nil, fmt.Errorf("decoding lockout record: %w", err)
+		}
+	}
+
+	permanent := true
+	_, err = kvStore.Get(ctx, permanentKey(key))
+	switch {
+	case errors.Is(err, keyValue.ErrNotFound):
+		permanent = false
+
+	case err != nil:
+		return nil, fmt.Errorf("getting permanent lockout: %w", err)
+	}
+
+	if failures == 0 && record.Lockouts == 0 && !permanent {
+		return nil, nil
+	}
+
+	state := &LockoutState{
+		FailedAttempts: int(max(failures-record.Failures, 0)),
+		Lockouts:       record.Lockouts,
+		Permanent:      permanent,
+	}
+	// a permanent lockout that a parallel attempt recorded outranks the
+	// temporary one
+	if !permanent {
+		state.LockedUntil = record.LockedUntil
+	}
+	return state, nil
+}
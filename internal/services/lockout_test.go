@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LockoutServiceSuite struct {
+	suite.Suite
+}
+
+func TestLockoutServiceSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(LockoutServiceSuite))
+}
+
+func (s *LockoutServiceSuite) createContext() (context.Context, clock.TimeSetterFn, time.Time) {
+	dc := ioc.NewDependencyCollection()
+
+	now := time.Now()
+	clockService, timeSetter := clock.NewMockClock(now)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	store := keyValue.NewMemoryStore()
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) keyValue.Store {
+		return store
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope), timeSetter, now
+}
+
+func testLockoutPolicy() repositories.LockoutPolicy {
+	return repositories.LockoutPolicy{
+		MaxFailedAttempts:      3,
+		MaxFailedAttemptsPerIp: 5,
+		LockoutDuration:        time.Minute,
+		MaxLockoutDuration:     time.Minute * 3,
+		PermanentLockoutAfter:  4,
+	}
+}
+
+func (s *LockoutServiceSuite) fail(ctx context.Context, service LockoutService, virtualServerId uuid.UUID, userId uuid.UUID, times int) bool {
+	locked := false
+	for range times {
+		_, l, err := service.RecordFailure(ctx, testLockoutPolicy(), virtualServerId, userId, "203.0.113.7")
+		s.Require().NoError(err)
+		locked = locked || l
+	}
+	return locked
+}
+
+func (s *LockoutServiceSuite) TestLocksUserWithBackoff() {
+	// arrange
+	ctx, timeSetter, now := s.createContext()
+	service := NewLockoutService()
+	virtualServerId, userId := uuid.New(), uuid.New()
+
+	// act & assert
+	s.False(s.fail(ctx, service, virtualServerId, userId, 2))
+	s.Require().NoError(service.CheckUser(ctx, userId))
+
+	s.True(s.fail(ctx, service, virtualServerId, userId, 1))
+	s.ErrorIs(service.CheckUser(ctx, userId), ErrAccountLocked)
+
+	now = now.Add(time.Minute)
+	timeSetter(now)
+	s.Require().NoError(service.CheckUser(ctx, userId))
+
+	// the second lockout lasts twice as long
+	s.True(s.fail(ctx, service, virtualServerId, userId, 3))
+	timeSetter(now.Add(time.Minute))
+	s.ErrorIs(service.CheckUser(ctx, userId), ErrAccountLocked)
+	timeSetter(now.Add(time.Minute * 2))
+	s.Require().NoError(service.CheckUser(ctx, userId))
+}
+
+func (s *LockoutServiceSuite) TestLocksUserPermanently() {
+	// arrange
+	ctx, timeSetter, now := s.createContext()
+	service := NewLockoutService()
+	virtualServerId, userId := uuid.New(), uuid.New()
+
+	// act
+	for i := range testLockoutPolicy().PermanentLockoutAfter {
+		now = now.Add(time.Hour)
+		timeSetter(now)
+		s.True(s.fail(ctx, service, virtualServerId, userId, 3), "lockout %d", i+1)
+	}
+
+	// assert
+	timeSetter(now.Add(time.Hour * 24 * 365))
+	s.ErrorIs(service.CheckUser(ctx, userId), ErrAccountLocked)
+
+	state, err := service.GetUserState(ctx, userId)
+	s.Require().NoError(err)
+	s.True(state.Permanent)
+	s.Equal(4, state.Lockouts)
+
+	s.Require().NoError(service.ClearUserState(ctx, userId))
+	s.Require().NoError(service.CheckUser(ctx, userId))
+}
+
+func (s *LockoutServiceSuite) TestCountsParallelFailures() {
+	// arrange
+	ctx, _, _ := s.createContext()
+	service := NewLockoutService()
+	virtualServerId, userId := uuid.New(), uuid.New()
+	policy := testLockoutPolicy()
+	policy.PermanentLockoutAfter = 0
+
+	// the scope creates the store on first use, which must not race
+	ioc.GetDependency[keyValue.Store](middlewares.GetScope(ctx))
+
+	// act
+	var lockouts atomic.Int32
+	var wg sync.WaitGroup
+	for range 30 {
+		wg.Go(func() {
+			_, locked, err := service.RecordFailure(ctx, policy, virtualServerId, userId, "203.0.113.7")
+			s.NoError(err)
+			if locked {
+				lockouts.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+
+	// assert
+	s.Equal(int32(10), lockouts.Load())
+	state, err := service.GetUserState(ctx, userId)
+	s.Require().NoError(err)
+	s.Equal(10, state.Lockouts)
+	s.Equal(0, state.FailedAttempts)
+	s.ErrorIs(service.CheckUser(ctx, userId), ErrAccountLocked)
+	s.ErrorIs(service.CheckIp(ctx, virtualServerId, "203.0.113.7"), ErrIpThrottled)
+}
+
+func (s *LockoutServiceSuite) TestSuccessResetsUser() {
+	// arrange
+	ctx, _, _ := s.createContext()
+	service := NewLockoutService()
+	virtualServerId, userId := uuid.New(), uuid.New()
+
+	// act
+	s.fail(ctx, service, virtualServerId, userId, 2)
+	s.Require().NoError(service.RecordSuccess(ctx, userId))
+
+	// assert
+	state, err := service.GetUserState(ctx, userId)
+	s.Require().NoError(err)
+	s.Nil(state)
+	s.False(s.fail(ctx, service, virtualServerId, userId, 2))
+}
+
+func (s *LockoutServiceSuite) TestThrottlesIpAcrossUsers() {
+	// arrange
+	ctx, _, _ := s.createContext()
+	service := NewLockoutService()
+	virtualServerId := uuid.New()
+
+	// act
+	for range 5 {
+		s.fail(ctx, service, virtualServerId, uuid.New(), 1)
+	}
+
+	// assert
+	s.ErrorIs(service.CheckIp(ctx, virtualServerId, "203.0.113.7"), ErrIpThrottled)
+	s.Require().NoError(service.CheckIp(ctx, virtualServerId, "198.51.100.1"))
+	s.Require().NoError(service.CheckIp(ctx, uuid.New(), "203.0.113.7"))
+}
+
+func (s *LockoutServiceSuite) TestDisabledPolicy() {
+	// arrange
+	ctx, _, _ := s.createContext()
+	service := NewLockoutService()
+	virtualServerId, userId := uuid.New(), uuid.New()
+	policy := repositories.DefaultLockoutPolicy()
+
+	// act
+	for range 100 {
+		_, locked, err := service.RecordFailure(ctx, policy, virtualServerId, userId, "203.0.113.7")
+		s.Require().NoError(err)
+		s.False(locked)
+	}
+
+	// assert
+	s.Require().NoError(service.CheckUser(ctx, userId))
+	s.Require().NoError(service.CheckIp(ctx, virtualServerId, "203.0.113.7"))
+}
+
+func TestLockoutPolicy_LockoutDurationFor(t *testing.T) {
+	t.Parallel()
+
+	policy := testLockoutPolicy()
+	durations := []time.Duration{time.Minute, time.Minute * 2, time.Minute * 3, time.Minute * 3}
+	for i, expected := range durations {
+		assert.Equal(t, expected, policy.LockoutDurationFor(i+1), "lockout %d", i+1)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+)
+
+// MailResendCooldown is the minimum time between two mails of the same
+// kind to the same user.
+const MailResendCooldown = time.Minute
+
+// MaxMailsPerWindow caps the mails of the same kind to the same user
+// within mailThrottleWindow, so that a user's inbox cannot be flooded by
+// requesting mails over and over.
+const MaxMailsPerWindow = 5
+
+const mailThrottleWindow = time.Hour
+
+var ErrMailThrottled = fmt.Errorf("too many mails were requested, please try again later: %w", utils.ErrHttpTooManyRequests)
+
+// MailThrottle limits how often mails that a user can trigger without
+// being signed in are sent, text messages with codes count the same way.
+// The counters are kept in the key value store so that they are shared by
+// all instances, each is taken in one step so that concurrent requests
+// cannot both pass. Mails are counted in fixed windows.
+type MailThrottle interface {
+	// Acquire counts a mail of the given kind to the user. It fails with
+	// ErrMailThrottled if the mail must not be sent.
+	Acquire(ctx context.Context, mailType repositories.TemplateType, userId uuid.UUID) error
+}
+
+type mailThrottle struct {
+}
+
+func NewMailThrottle() MailThrottle {
+	return &mailThrottle{}
+}
+
+func mailThrottleKey(mailType repositories.TemplateType, userId uuid.UUID) string {
+	return fmt.Sprintf("mail_throttle:%s:%s", mailType, userId)
+}
+
+func (t *mailThrottle) Acquire(ctx context.Context, mailType repositories.TemplateType, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+	now := clockService.Now()
+
+	key := mailThrottleKey(mailType, userId)
+
+	acquired, err := kvStore.SetIfAbsent(ctx, key+":cooldown", "1", keyValue.WithExpiration(MailResendCooldown))
+	if err != nil {
+		return fmt.Errorf("starting mail cooldown: %w", err)
+	}
+	if !acquired {
+		return ErrMailThrottled
+	}
+
+	windowKey := fmt.Sprintf("%s:%d", key, now.Truncate(mailThrottleWindow).Unix())
+	count, err := kvStore.Increment(ctx, windowKey, mailThrottleWindow)
+	if err != nil {
+		return fmt.Errorf("counting mail: %w", err)
+	}
+	if count > MaxMailsPerWindow {
+		return ErrMailThrottled
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailThrottleSuite struct {
+	suite.Suite
+}
+
+func TestMailThrottleSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(MailThrottleSuite))
+}
+
+func (s *MailThrottleSuite) createContext() (context.Context, clock.TimeSetterFn, time.Time) {
+	dc := ioc.NewDependencyCollection()
+
+	// the windows are fixed, start at the beginning of one
+	now := time.Now().Truncate(mailThrottleWindow)
+	clockService, timeSetter := clock.NewMockClock(now)
+	ioc.RegisterTransient(dc, func(dp *ioc.DependencyProvider) clock.Service {
+		return clockService
+	})
+
+	store := keyValue.NewMemoryStore()
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) keyValue.Store {
+		return store
+	})
+
+	scope := dc.BuildProvider()
+	s.T().Cleanup(func() {
+		utils.PanicOnError(scope.Close, "closing scope")
+	})
+
+	return middlewares.ContextWithScope(s.T().Context(), scope), timeSetter, now
+}
+
+func (s *MailThrottleSuite) TestEnforcesCooldown() {
+	// arrange
+	ctx, timeSetter, now := s.createContext()
+	throttle := NewMailThrottle()
+	userId := uuid.New()
+
+	// act & assert
+	s.Require().NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId))
+	s.ErrorIs(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId), ErrMailThrottled)
+
+	// other kinds of mails and other users are counted separately
+	s.NoError(throttle.Acquire(ctx, repositories.EmailVerificationMailTemplate, userId))
+	s.NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, uuid.New()))
+
+	timeSetter(now.Add(MailResendCooldown + time.Second))
+	s.NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId))
+}
+
+func (s *MailThrottleSuite) TestCapsMailsPerWindow() {
+	// arrange
+	ctx, timeSetter, now := s.createContext()
+	throttle := NewMailThrottle()
+	userId := uuid.New()
+
+	// act & assert
+	for i := range MaxMailsPerWindow {
+		timeSetter(now.Add(time.Duration(i) * (MailResendCooldown + time.Second)))
+		s.Require().NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId))
+	}
+
+	timeSetter(now.Add(time.Duration(MaxMailsPerWindow) * (MailResendCooldown + time.Second)))
+	s.ErrorIs(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId), ErrMailThrottled)
+
+	timeSetter(now.Add(mailThrottleWindow))
+	s.NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId))
+}
+
+func (s *MailThrottleSuite) TestLetsOneOfConcurrentMailsThrough() {
+	// arrange
+	ctx, _, _ := s.createContext()
+	throttle := NewMailThrottle()
+	userId := uuid.New()
+
+	// resolves the dependencies before the scope is shared
+	s.Require().NoError(throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, uuid.New()))
+
+	// act
+	var mu sync.Mutex
+	var sent int
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			err := throttle.Acquire(ctx, repositories.EmailLoginMailTemplate, userId)
+			if err == nil {
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	// assert
+	s.Equal(1, sent)
+}
@@ -10,10 +10,12 @@
 package mocks
 
 import (
-	config "github.com/The127/Keyline/config"
-	services "github.com/The127/Keyline/internal/services"
 	reflect "reflect"
+	time "time"
 
+	config "github.com/The127/Keyline/config"
+	repositories "github.com/The127/Keyline/internal/repositories"
+	services "github.com/The127/Keyline/internal/services"
 	clock "github.com/The127/go-clock"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -43,18 +45,18 @@ func (m *MockKeyService) EXPECT() *MockKeyServiceMockRecorder {
 }
 
 // Generate mocks base method.
-func (m *MockKeyService) Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm) (services.KeyPair, error) {
+func (m *MockKeyService) Generate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (services.KeyPair, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Generate", clockService, virtualServerName, algorithm)
+	ret := m.ctrl.Call(m, "Generate", clockService, virtualServerName, algorithm, policy)
 	ret0, _ := ret[0].(services.KeyPair)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Generate indicates an expected call of Generate.
-func (mr *MockKeyServiceMockRecorder) Generate(clockService, virtualServerName, algorithm any) *gomock.Call {
+func (mr *MockKeyServiceMockRecorder) Generate(clockService, virtualServerName, algorithm, policy any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockKeyService)(nil).Generate), clockService, virtualServerName, algorithm)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockKeyService)(nil).Generate), clockService, virtualServerName, algorithm, policy)
 }
 
 // GetAllKeys mocks base method.
@@ -86,3 +88,77 @@ func (mr *MockKeyServiceMockRecorder) GetKey(virtualServerName, algorithm any) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKey", reflect.TypeOf((*MockKeyService)(nil).GetKey), virtualServerName, algorithm)
 }
+
+// GetVerificationKey mocks base method.
+func (m *MockKeyService) GetVerificationKey(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (services.KeyPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerificationKey", virtualServerName, algorithm, kid)
+	ret0, _ := ret[0].(services.KeyPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerificationKey indicates an expected call of GetVerificationKey.
+func (mr *MockKeyServiceMockRecorder) GetVerificationKey(virtualServerName, algorithm, kid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerificationKey", reflect.TypeOf((*MockKeyService)(nil).GetVerificationKey), virtualServerName, algorithm, kid)
+}
+
+// Import mocks base method.
+func (m *MockKeyService) Import(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, key services.ImportedKey) (services.KeyPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", clockService, virtualServerName, algorithm, policy, key)
+	ret0, _ := ret[0].(services.KeyPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockKeyServiceMockRecorder) Import(clockService, virtualServerName, algorithm, policy, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockKeyService)(nil).Import), clockService, virtualServerName, algorithm, policy, key)
+}
+
+// PrePublish mocks base method.
+func (m *MockKeyService) PrePublish(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy, activatesAt time.Time) (services.KeyPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrePublish", clockService, virtualServerName, algorithm, policy, activatesAt)
+	ret0, _ := ret[0].(services.KeyPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrePublish indicates an expected call of PrePublish.
+func (mr *MockKeyServiceMockRecorder) PrePublish(clockService, virtualServerName, algorithm, policy, activatesAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrePublish", reflect.TypeOf((*MockKeyService)(nil).PrePublish), clockService, virtualServerName, algorithm, policy, activatesAt)
+}
+
+// Revoke mocks base method.
+func (m *MockKeyService) Revoke(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", virtualServerName, algorithm, kid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockKeyServiceMockRecorder) Revoke(virtualServerName, algorithm, kid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockKeyService)(nil).Revoke), virtualServerName, algorithm, kid)
+}
+
+// Rotate mocks base method.
+func (m *MockKeyService) Rotate(clockService clock.Service, virtualServerName string, algorithm config.SigningAlgorithm, policy repositories.KeyRotationPolicy) (services.KeyPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", clockService, virtualServerName, algorithm, policy)
+	ret0, _ := ret[0].(services.KeyPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockKeyServiceMockRecorder) Rotate(clockService, virtualServerName, algorithm, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockKeyService)(nil).Rotate), clockService, virtualServerName, algorithm, policy)
+}
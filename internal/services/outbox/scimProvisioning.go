@@ -0,0 +1,205 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	db "github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/scim"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+)
+
+const scimProvisioningTimeout = 10 * time.Second
+
+// deliverScimProvision brings one user up to date at a provisioning target
+// and records the outcome on the target. The error is returned as well so
+// that the message is retried.
+func deliverScimProvision(ctx context.Context, message *repositories.OutboxMessage) error {
+	var details messages.ScimProvisionMessage
+	err := json.Unmarshal(message.Details(), &details)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal scim provision message details: %w", err)
+	}
+
+	scope := middlewares.GetScope(ctx)
+	dbContext := ioc.GetDependency[db.Context](scope)
+	now := ioc.GetDependency[clock.Service](scope).Now()
+
+	targetFilter := repositories.NewProvisioningTargetFilter().
+		VirtualServerId(details.VirtualServerId).
+		Id(details.ProvisioningTargetId)
+	target, err := dbContext.ProvisioningTargets().FirstOrNil(ctx, targetFilter)
+	if err != nil {
+		return fmt.Errorf("getting provisioning target: %w", err)
+	}
+	if target == nil || !target.Enabled() {
+		// the target was removed or paused after the message was queued
+		return nil
+	}
+
+	syncErr := syncScimUser(ctx, dbContext, target, details)
+	if syncErr != nil {
+		target.RecordError(syncErr, now)
+	} else {
+		target.RecordSuccess(now)
+	}
+	dbContext.ProvisioningTargets().Update(target)
+
+	return syncErr
+}
+
+// syncScimUser creates, replaces or deletes the user at the target. Users
+// without a role in the project of the application are not provisioned.
+func syncScimUser(ctx context.Context, dbContext db.Context, target *repositories.ProvisioningTarget, details messages.ScimProvisionMessage) error {
+	applicationFilter := repositories.NewApplicationFilter().
+		VirtualServerId(target.VirtualServerId()).
+		Id(target.ApplicationId())
+	application, err := dbContext.Applications().FirstOrErr(ctx, applicationFilter)
+	if err != nil {
+		return fmt.Errorf("getting application: %w", err)
+	}
+
+	projectFilter := repositories.NewProjectFilter().
+		VirtualServerId(target.VirtualServerId()).
+		Id(application.ProjectId())
+	project, err := dbContext.Projects().FirstOrErr(ctx, projectFilter)
+	if err != nil {
+		return fmt.Errorf("getting project: %w", err)
+	}
+
+	userFilter := repositories.NewUserFilter().
+		VirtualServerId(target.VirtualServerId()).
+		Id(details.UserId)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	if err != nil {
+		return fmt.Errorf("getting user: %w", err)
+	}
+
+	var roles []string
+	if user != nil {
+		assignmentFilter := repositories.NewUserRoleAssignmentFilter().
+			UserId(user.Id()).
+			IncludeRole()
+		assignments, _, err := dbContext.UserRoleAssignments().List(ctx, assignmentFilter)
+		if err != nil {
+			return fmt.Errorf("listing role assignments: %w", err)
+		}
+
+		for _, assignment := range assignments {
+			roleInfo := assignment.RoleInfo()
+			if roleInfo != nil && roleInfo.ProjectSlug == project.Slug() && !slices.Contains(roles, roleInfo.Name) {
+				roles = append(roles, roleInfo.Name)
+			}
+		}
+		slices.Sort(roles)
+	}
+
+	client := newScimClient(target)
+	mapping := target.AttributeMapping()
+
+	remoteId, err := client.FindUser(ctx, lookupFilter(mapping, details))
+	if err != nil {
+		return fmt.Errorf("looking up user: %w", err)
+	}
+
+	if user == nil || user.IsServiceUser() || len(roles) == 0 {
+		if remoteId == "" {
+			return nil
+		}
+		err = client.DeleteUser(ctx, remoteId)
+		if err != nil {
+			return fmt.Errorf("deleting user: %w", err)
+		}
+		return nil
+	}
+
+	resource, err := scim.BuildResource(scim.SchemaUser, mappedAttributes(mapping, user, roles))
+	if err != nil {
+		return fmt.Errorf("building user resource: %w", err)
+	}
+
+	if remoteId == "" {
+		_, err = client.CreateUser(ctx, resource)
+		if err != nil {
+			return fmt.Errorf("creating user: %w", err)
+		}
+		return nil
+	}
+
+	err = client.ReplaceUser(ctx, remoteId, resource)
+	if err != nil {
+		return fmt.Errorf("replacing user: %w", err)
+	}
+	return nil
+}
+
+func newScimClient(target *repositories.ProvisioningTarget) *scim.Client {
+	return &scim.Client{
+		BaseUrl:    target.Url(),
+		HttpClient: &http.Client{Timeout: scimProvisioningTimeout},
+		Authorize: func(request *http.Request) {
+			switch target.AuthType() {
+			case repositories.ProvisioningAuthTypeBasic:
+				username := ""
+				if target.AuthUsername() != nil {
+					username = *target.AuthUsername()
+				}
+				request.SetBasicAuth(username, target.AuthSecret())
+
+			default:
+				request.Header.Set("Authorization", "Bearer "+target.AuthSecret())
+			}
+		},
+	}
+}
+
+// lookupFilter finds the remote user by the attribute holding the Keyline id
+// if the mapping has one, and by the username otherwise. The username is
+// taken from the message so that deleted users can still be found.
+func lookupFilter(mapping repositories.ProvisioningAttributeMapping, details messages.ScimProvisionMessage) string {
+	usernameAttribute := "userName"
+	for attribute, source := range mapping {
+		switch source {
+		case repositories.ProvisioningSourceId:
+			return scim.EqualFilter(attribute, details.UserId.String())
+		case repositories.ProvisioningSourceUsername:
+			usernameAttribute = attribute
+		}
+	}
+	return scim.EqualFilter(usernameAttribute, details.Username)
+}
+
+func mappedAttributes(mapping repositories.ProvisioningAttributeMapping, user *repositories.User, roles []string) map[string]any {
+	attributes := make(map[string]any, len(mapping))
+	for attribute, source := range mapping {
+		switch source {
+		case repositories.ProvisioningSourceId:
+			attributes[attribute] = user.Id().String()
+		case repositories.ProvisioningSourceUsername:
+			attributes[attribute] = user.Username()
+		case repositories.ProvisioningSourceDisplayName:
+			attributes[attribute] = user.DisplayName()
+		case repositories.ProvisioningSourceEmail:
+			if user.PrimaryEmail() != "" {
+				attributes[attribute] = user.PrimaryEmail()
+			}
+		case repositories.ProvisioningSourceActive:
+			attributes[attribute] = !user.Disabled()
+		case repositories.ProvisioningSourceRoles:
+			values := make([]map[string]any, 0, len(roles))
+			for _, role := range roles {
+				values = append(values, map[string]any{"value": role})
+			}
+			attributes[attribute] = values
+		}
+	}
+	return attributes
+}
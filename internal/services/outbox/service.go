@@ -59,6 +59,24 @@ func (m *messageBroker) Distribute(ctx context.Context, message *repositories.Ou
 
 		return nil
 
+	case repositories.ScimProvisionOutboxMessageType:
+		return deliverScimProvision(ctx, message)
+
+	case repositories.SendSmsOutboxMessageType:
+		var sendSmsDetails messages.SendSmsMessage
+		err := json.Unmarshal(message.Details(), &sendSmsDetails)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal send sms message details: %w", err)
+		}
+
+		smsProvider := ioc.GetDependency[services.SmsProvider](scope)
+		err = smsProvider.Send(ctx, sendSmsDetails.To, sendSmsDetails.Body)
+		if err != nil {
+			return fmt.Errorf("failed to send sms: %w", err)
+		}
+
+		return nil
+
 	default:
 		return fmt.Errorf("unsupported message type: %s", message.Type())
 	}
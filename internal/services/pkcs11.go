@@ -0,0 +1,650 @@
+//go:build cgo
+
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS #11 v3.0 constants for EdDSA that the pkcs11 package does not know
+// yet.
+const (
+	ckkEcEdwards           = 0x00000040
+	ckmEcEdwardsKeyPairGen = 0x00001055
+	ckmEddsa               = 0x00001057
+)
+
+var (
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidP256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384    = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidP521    = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// pkcs1DigestInfoPrefixes are the DER encoded DigestInfo headers that
+// CKM_RSA_PKCS expects in front of the digest.
+var pkcs1DigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pkcs11KeyStore generates and signs with keys inside an HSM. The key
+// objects of a key pair share a random CKA_ID, and the schedule of the key
+// pair is kept in a data object on the token, labeled with the kid.
+type pkcs11KeyStore struct {
+	// mu serializes all calls, the session must not be used concurrently
+	mu          sync.Mutex
+	ctx         *pkcs11.Ctx
+	session     pkcs11.SessionHandle
+	labelPrefix string
+}
+
+func NewPkcs11KeyStore(modulePath string, slot uint, pin string, labelPrefix string) (KeyStore, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("loading pkcs11 module %s", modulePath)
+	}
+
+	// the module may already be initialized by another key store of the
+	// process, e.g. in tests
+	err := ctx.Initialize()
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		return nil, fmt.Errorf("initializing pkcs11 module: %w", err)
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("opening pkcs11 session: %w", err)
+	}
+
+	err = ctx.Login(session, pkcs11.CKU_USER, pin)
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return nil, fmt.Errorf("logging in to pkcs11 token: %w", err)
+	}
+
+	return &pkcs11KeyStore{
+		ctx:         ctx,
+		session:     session,
+		labelPrefix: labelPrefix,
+	}, nil
+}
+
+type pkcs11KeyPairJson struct {
+	Algorithm   string    `json:"algorithm"`
+	Kid         string    `json:"kid"`
+	KeyId       string    `json:"key_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at"`
+	RotatesAt   time.Time `json:"rotates_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	VerifyOnly  bool      `json:"verify_only,omitempty"`
+}
+
+// applicationLabel groups the objects of an algorithm of a virtual server.
+func (p *pkcs11KeyStore) applicationLabel(virtualServerName string, algorithm config.SigningAlgorithm) string {
+	return fmt.Sprintf("%s%s/%s", p.labelPrefix, virtualServerName, algorithm)
+}
+
+func (p *pkcs11KeyStore) metadataLabel(virtualServerName string, algorithm config.SigningAlgorithm, kid string) string {
+	return fmt.Sprintf("%s/%s", p.applicationLabel(virtualServerName, algorithm), kid)
+}
+
+func (p *pkcs11KeyStore) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	err := p.ctx.FindObjectsInit(p.session, template)
+	if err != nil {
+		return nil, fmt.Errorf("finding objects: %w", err)
+	}
+
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := p.ctx.FindObjects(p.session, 100)
+		if err != nil {
+			_ = p.ctx.FindObjectsFinal(p.session)
+			return nil, fmt.Errorf("finding objects: %w", err)
+		}
+		if len(found) == 0 {
+			break
+		}
+		handles = append(handles, found...)
+	}
+
+	err = p.ctx.FindObjectsFinal(p.session)
+	if err != nil {
+		return nil, fmt.Errorf("finding objects: %w", err)
+	}
+
+	return handles, nil
+}
+
+func (p *pkcs11KeyStore) findKeyObject(class uint, keyId []byte) (pkcs11.ObjectHandle, error) {
+	handles, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(handles) != 1 {
+		return 0, fmt.Errorf("expected one key object with id %x, found %d", keyId, len(handles))
+	}
+
+	return handles[0], nil
+}
+
+func pkcs11KeyTemplates(algorithm config.SigningAlgorithm) (*pkcs11.Mechanism, []*pkcs11.Attribute, uint, error) {
+	var curve asn1.ObjectIdentifier
+	switch algorithm {
+	case config.SigningAlgorithmRS256,
+		config.SigningAlgorithmRS384,
+		config.SigningAlgorithmRS512,
+		config.SigningAlgorithmPS256:
+		return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil),
+			[]*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 4096),
+				pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+			},
+			pkcs11.CKK_RSA,
+			nil
+
+	case config.SigningAlgorithmES256:
+		curve = oidP256
+	case config.SigningAlgorithmES384:
+		curve = oidP384
+	case config.SigningAlgorithmES512:
+		curve = oidP521
+
+	case config.SigningAlgorithmEdDSA:
+		params, err := asn1.Marshal(oidEd25519)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return pkcs11.NewMechanism(ckmEcEdwardsKeyPairGen, nil),
+			[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)},
+			ckkEcEdwards,
+			nil
+
+	default:
+		return nil, nil, 0, fmt.Errorf("not implemented for algorithm: %s", algorithm)
+	}
+
+	params, err := asn1.Marshal(curve)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil),
+		[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)},
+		pkcs11.CKK_EC,
+		nil
+}
+
+func ecdsaCurve(algorithm config.SigningAlgorithm) elliptic.Curve {
+	switch algorithm {
+	case config.SigningAlgorithmES256:
+		return elliptic.P256()
+	case config.SigningAlgorithmES384:
+		return elliptic.P384()
+	default:
+		return elliptic.P521()
+	}
+}
+
+// readPublicKey reads the public key object and computes its kid like for
+// generated keys.
+func (p *pkcs11KeyStore) readPublicKey(algorithm config.SigningAlgorithm, handle pkcs11.ObjectHandle) (any, string, error) {
+	switch algorithm {
+	case config.SigningAlgorithmRS256,
+		config.SigningAlgorithmRS384,
+		config.SigningAlgorithmRS512,
+		config.SigningAlgorithmPS256:
+		attributes, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("reading rsa public key: %w", err)
+		}
+
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attributes[0].Value),
+			E: int(new(big.Int).SetBytes(attributes[1].Value).Int64()),
+		}
+		kid, err := computeRSAPublicKeyKid(publicKey)
+		return publicKey, kid, err
+
+	default:
+		attributes, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("reading ec point: %w", err)
+		}
+
+		// the point is a DER encoded OCTET STRING, some modules return
+		// the raw point instead
+		point := attributes[0].Value
+		var unwrapped []byte
+		rest, err := asn1.Unmarshal(point, &unwrapped)
+		if err == nil && len(rest) == 0 {
+			point = unwrapped
+		}
+
+		if algorithm == config.SigningAlgorithmEdDSA {
+			if len(point) != ed25519.PublicKeySize {
+				return nil, "", fmt.Errorf("invalid ed25519 public key length %d", len(point))
+			}
+			publicKey := ed25519.PublicKey(point)
+			return publicKey, computeEdCSAPublicKeyKid(publicKey), nil
+		}
+
+		publicKey, err := ecdsa.ParseUncompressedPublicKey(ecdsaCurve(algorithm), point)
+		if err != nil {
+			return nil, "", fmt.Errorf("parsing ec point: %w", err)
+		}
+		kid, err := computeECDSAPublicKeyKid(publicKey)
+		return publicKey, kid, err
+	}
+}
+
+// GenerateKey creates a new non-extractable key pair on the token.
+func (p *pkcs11KeyStore) GenerateKey(virtualServerName string, algorithm config.SigningAlgorithm, createdAt time.Time) (KeyPair, error) {
+	mechanism, publicAttributes, keyType, err := pkcs11KeyTemplates(algorithm)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	keyId := make([]byte, 16)
+	_, err = rand.Read(keyId)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("generating key id: %w", err)
+	}
+
+	label := p.applicationLabel(virtualServerName, algorithm)
+	publicTemplate := append([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+	}, publicAttributes...)
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	publicHandle, _, err := p.ctx.GenerateKeyPair(p.session, []*pkcs11.Mechanism{mechanism}, publicTemplate, privateTemplate)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("generating key pair: %w", err)
+	}
+
+	publicKey, kid, err := p.readPublicKey(algorithm, publicHandle)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	return KeyPair{
+		algorithm:   algorithm,
+		publicKey:   publicKey,
+		kid:         kid,
+		createdAt:   createdAt,
+		activatesAt: createdAt,
+		signer:      &pkcs11Signer{store: p, keyId: keyId, publicKey: publicKey},
+	}, nil
+}
+
+// Add stores the schedule of a key pair created by GenerateKey.
+func (p *pkcs11KeyStore) Add(virtualServerName string, keyPair KeyPair) error {
+	signer, ok := keyPair.signer.(*pkcs11Signer)
+	if !ok {
+		return fmt.Errorf("the pkcs11 key store only stores keys it generated")
+	}
+
+	data, err := json.Marshal(pkcs11KeyPairJson{
+		Algorithm:   string(keyPair.algorithm),
+		Kid:         keyPair.kid,
+		KeyId:       hex.EncodeToString(signer.keyId),
+		CreatedAt:   keyPair.createdAt,
+		ActivatesAt: keyPair.activatesAt,
+		RotatesAt:   keyPair.rotatesAt,
+		ExpiresAt:   keyPair.expiresAt,
+		VerifyOnly:  keyPair.verifyOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling key pair: %w", err)
+	}
+
+	label := p.metadataLabel(virtualServerName, keyPair.algorithm, keyPair.kid)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// data objects are replaced, not all modules allow changing CKA_VALUE
+	existing, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.ctx.CreateObject(p.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_APPLICATION, p.applicationLabel(virtualServerName, keyPair.algorithm)),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, data),
+	})
+	if err != nil {
+		return fmt.Errorf("storing key pair: %w", err)
+	}
+
+	for _, handle := range existing {
+		err = p.ctx.DestroyObject(p.session, handle)
+		if err != nil {
+			return fmt.Errorf("replacing key pair: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *pkcs11KeyStore) readMetadata(handle pkcs11.ObjectHandle) (pkcs11KeyPairJson, []byte, error) {
+	attributes, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return pkcs11KeyPairJson{}, nil, fmt.Errorf("reading key pair: %w", err)
+	}
+
+	var dto pkcs11KeyPairJson
+	err = json.Unmarshal(attributes[0].Value, &dto)
+	if err != nil {
+		return pkcs11KeyPairJson{}, nil, fmt.Errorf("unmarshaling key pair: %w", err)
+	}
+
+	keyId, err := hex.DecodeString(dto.KeyId)
+	if err != nil {
+		return pkcs11KeyPairJson{}, nil, fmt.Errorf("decoding key id: %w", err)
+	}
+
+	return dto, keyId, nil
+}
+
+func (p *pkcs11KeyStore) keyPair(handle pkcs11.ObjectHandle) (KeyPair, error) {
+	dto, keyId, err := p.readMetadata(handle)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	publicHandle, err := p.findKeyObject(pkcs11.CKO_PUBLIC_KEY, keyId)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	algorithm := config.SigningAlgorithm(dto.Algorithm)
+	publicKey, _, err := p.readPublicKey(algorithm, publicHandle)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	return KeyPair{
+		algorithm:   algorithm,
+		publicKey:   publicKey,
+		kid:         dto.Kid,
+		createdAt:   dto.CreatedAt,
+		activatesAt: dto.ActivatesAt,
+		rotatesAt:   dto.RotatesAt,
+		expiresAt:   dto.ExpiresAt,
+		verifyOnly:  dto.VerifyOnly,
+		signer:      &pkcs11Signer{store: p, keyId: keyId, publicKey: publicKey},
+	}, nil
+}
+
+func (p *pkcs11KeyStore) Get(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (*KeyPair, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handles, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.metadataLabel(virtualServerName, algorithm, kid)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	keyPair, err := p.keyPair(handles[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyPair, nil
+}
+
+func (p *pkcs11KeyStore) findMetadata(virtualServerName string, algorithm config.SigningAlgorithm) ([]pkcs11.ObjectHandle, error) {
+	return p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_APPLICATION, p.applicationLabel(virtualServerName, algorithm)),
+	})
+}
+
+func (p *pkcs11KeyStore) GetAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) ([]KeyPair, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handles, err := p.findMetadata(virtualServerName, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPairs := make([]KeyPair, 0, len(handles))
+	for _, handle := range handles {
+		keyPair, err := p.keyPair(handle)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, keyPair)
+	}
+
+	return keyPairs, nil
+}
+
+func (p *pkcs11KeyStore) GetAll(virtualServerName string) ([]KeyPair, error) {
+	var keyPairs []KeyPair
+	for _, alg := range config.SupportedSigningAlgorithms {
+		algKeyPairs, err := p.GetAllForAlgorithm(virtualServerName, alg)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, algKeyPairs...)
+	}
+	return keyPairs, nil
+}
+
+// destroy removes the key objects of a key pair and its data object.
+func (p *pkcs11KeyStore) destroy(handle pkcs11.ObjectHandle) error {
+	_, keyId, err := p.readMetadata(handle)
+	if err != nil {
+		return err
+	}
+
+	keyHandles, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyId),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, keyHandle := range append(keyHandles, handle) {
+		err = p.ctx.DestroyObject(p.session, keyHandle)
+		if err != nil {
+			return fmt.Errorf("destroying object: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *pkcs11KeyStore) Remove(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handles, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.metadataLabel(virtualServerName, algorithm, kid)),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, handle := range handles {
+		err = p.destroy(handle)
+		if err != nil {
+			return fmt.Errorf("removing key %s: %w", kid, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *pkcs11KeyStore) RemoveAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handles, err := p.findMetadata(virtualServerName, algorithm)
+	if err != nil {
+		return err
+	}
+
+	for _, handle := range handles {
+		err = p.destroy(handle)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pkcs11Signer signs with a private key on the token. It returns the same
+// signature formats as the crypto.Signer implementations of the standard
+// library.
+type pkcs11Signer struct {
+	store     *pkcs11KeyStore
+	keyId     []byte
+	publicKey any
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func pkcs11HashMechanism(hash crypto.Hash) (uint, uint, error) {
+	switch hash {
+	case crypto.SHA256:
+		return pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256, nil
+	case crypto.SHA384:
+		return pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384, nil
+	case crypto.SHA512:
+		return pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512, nil
+	default:
+		return 0, 0, fmt.Errorf("unsupported hash %v", hash)
+	}
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism *pkcs11.Mechanism
+	data := digest
+
+	switch s.publicKey.(type) {
+	case *rsa.PublicKey:
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			if pssOpts.SaltLength != rsa.PSSSaltLengthEqualsHash {
+				return nil, fmt.Errorf("unsupported pss salt length %d", pssOpts.SaltLength)
+			}
+			hashMechanism, mgf, err := pkcs11HashMechanism(opts.HashFunc())
+			if err != nil {
+				return nil, err
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pkcs11.NewPSSParams(hashMechanism, mgf, uint(opts.HashFunc().Size())))
+		} else {
+			prefix, ok := pkcs1DigestInfoPrefixes[opts.HashFunc()]
+			if !ok {
+				return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+			data = append(append([]byte{}, prefix...), digest...)
+		}
+
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+
+	case ed25519.PublicKey:
+		mechanism = pkcs11.NewMechanism(ckmEddsa, nil)
+
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", s.publicKey)
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	privateHandle, err := s.store.findKeyObject(pkcs11.CKO_PRIVATE_KEY, s.keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.ctx.SignInit(s.store.session, []*pkcs11.Mechanism{mechanism}, privateHandle)
+	if err != nil {
+		return nil, fmt.Errorf("initializing signing: %w", err)
+	}
+
+	signature, err := s.store.ctx.Sign(s.store.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+
+	if _, ok := s.publicKey.(*ecdsa.PublicKey); ok {
+		// CKM_ECDSA returns r || s
+		size := len(signature) / 2
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			R: new(big.Int).SetBytes(signature[:size]),
+			S: new(big.Int).SetBytes(signature[size:]),
+		})
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package services
+
+import "fmt"
+
+// NewPkcs11KeyStore needs cgo to load the PKCS #11 module of the HSM.
+func NewPkcs11KeyStore(modulePath string, slot uint, pin string, labelPrefix string) (KeyStore, error) {
+	return nil, fmt.Errorf("the pkcs11 key store requires a build with cgo enabled")
+}
@@ -0,0 +1,253 @@
+//go:build cgo
+
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/repositories"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+
+	"github.com/google/uuid"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+const testPkcs11Pin = "1234"
+
+var softHsmModulePaths = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+// testSoftHsm is a SoftHSM token shared by all tests of the process, the
+// configuration of SoftHSM is only read once per process.
+var testSoftHsm struct {
+	once   sync.Once
+	module string
+	slot   uint
+	err    error
+}
+
+func findSoftHsmModule() string {
+	if module := os.Getenv("KEYLINE_TEST_PKCS11_MODULE"); module != "" {
+		return module
+	}
+
+	for _, path := range softHsmModulePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// initSoftHsmToken initializes a token in a temporary token directory and
+// sets its user PIN.
+func initSoftHsmToken(module string) (uint, error) {
+	dir, err := os.MkdirTemp("", "keyline-softhsm-")
+	if err != nil {
+		return 0, err
+	}
+
+	tokenDir := filepath.Join(dir, "tokens")
+	err = os.Mkdir(tokenDir, 0o700)
+	if err != nil {
+		return 0, err
+	}
+
+	configPath := filepath.Join(dir, "softhsm2.conf")
+	err = os.WriteFile(configPath, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokenDir)), 0o600)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Setenv("SOFTHSM2_CONF", configPath)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return 0, fmt.Errorf("loading %s", module)
+	}
+
+	err = ctx.Initialize()
+	if err != nil {
+		return 0, err
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	if len(slots) == 0 {
+		return 0, fmt.Errorf("no free softhsm slot")
+	}
+
+	err = ctx.InitToken(slots[0], testPkcs11Pin, "keyline-test")
+	if err != nil {
+		return 0, err
+	}
+
+	// softhsm moves the initialized token to a new slot
+	slots, err = ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(info.Label) != "keyline-test" {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = ctx.CloseSession(session) }()
+
+		err = ctx.Login(session, pkcs11.CKU_SO, testPkcs11Pin)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = ctx.Logout(session) }()
+
+		err = ctx.InitPIN(session, testPkcs11Pin)
+		if err != nil {
+			return 0, err
+		}
+
+		return slot, nil
+	}
+
+	return 0, fmt.Errorf("initialized token not found")
+}
+
+// newTestPkcs11KeyStore runs against SoftHSM and skips the test if it is not
+// installed. Every key store uses a label prefix of its own.
+func newTestPkcs11KeyStore(t *testing.T) KeyStore {
+	t.Helper()
+
+	module := findSoftHsmModule()
+	if module == "" {
+		t.Skip("softhsm is not installed")
+	}
+
+	testSoftHsm.once.Do(func() {
+		testSoftHsm.module = module
+		testSoftHsm.slot, testSoftHsm.err = initSoftHsmToken(module)
+	})
+	require.NoError(t, testSoftHsm.err)
+
+	keyStore, err := NewPkcs11KeyStore(testSoftHsm.module, testSoftHsm.slot, testPkcs11Pin, "test-"+uuid.NewString()+"/")
+	require.NoError(t, err)
+	return keyStore
+}
+
+func TestPkcs11KeyStore_SignsWithEveryAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range config.SupportedSigningAlgorithms {
+		t.Run(string(alg), func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			keyStore := newTestPkcs11KeyStore(t)
+			clockService, _ := clock.NewMockClock(time.Now())
+			testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+
+			// act
+			_, err := testee.Generate(clockService, "vs", alg, repositories.DefaultKeyRotationPolicy())
+			require.NoError(t, err)
+
+			// assert
+			keyPair, err := testee.GetKey("vs", alg)
+			require.NoError(t, err)
+			require.Nil(t, keyPair.PrivateKey())
+			signTestJwt(t, keyPair)
+		})
+	}
+}
+
+func TestPkcs11KeyStore_Rotation(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	keyStore := newTestPkcs11KeyStore(t)
+	now := time.Now()
+	clockService, setTime := clock.NewMockClock(now)
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	first, err := testee.Generate(clockService, "vs", config.SigningAlgorithmES256, policy)
+	require.NoError(t, err)
+	setTime(now.Add(time.Minute))
+
+	// act
+	second, err := testee.Rotate(clockService, "vs", config.SigningAlgorithmES256, policy)
+	require.NoError(t, err)
+
+	// assert
+	require.NotEqual(t, first.GetKid(), second.GetKid())
+
+	active, err := testee.GetKey("vs", config.SigningAlgorithmES256)
+	require.NoError(t, err)
+	require.Equal(t, second.GetKid(), active.GetKid())
+	signTestJwt(t, active)
+
+	retired, err := testee.GetVerificationKey("vs", config.SigningAlgorithmES256, first.GetKid())
+	require.NoError(t, err)
+	require.Equal(t, first.PublicKey(), retired.PublicKey())
+
+	err = testee.Revoke("vs", config.SigningAlgorithmES256, first.GetKid())
+	require.NoError(t, err)
+	keyPairs, err := keyStore.GetAll("vs")
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 1)
+	require.Equal(t, second.GetKid(), keyPairs[0].GetKid())
+
+	err = keyStore.RemoveAllForAlgorithm("vs", config.SigningAlgorithmES256)
+	require.NoError(t, err)
+	keyPairs, err = keyStore.GetAll("vs")
+	require.NoError(t, err)
+	require.Empty(t, keyPairs)
+}
+
+func TestPkcs11KeyStore_RejectsImports(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	keyStore := newTestPkcs11KeyStore(t)
+	clockService, _ := clock.NewMockClock(time.Now())
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// act
+	_, err = testee.Import(clockService, "vs", config.SigningAlgorithmEdDSA, repositories.DefaultKeyRotationPolicy(), ImportedKey{
+		Kid:        "imported",
+		PrivateKey: privateKeyJwk(t, edKey),
+		NotAfter:   time.Now().Add(time.Hour),
+	})
+
+	// assert
+	require.Error(t, err)
+}
@@ -23,15 +23,16 @@ type sessionService struct {
 }
 
 type sessionTokenValue struct {
-	UserId       uuid.UUID `json:"userId"`
-	HashedSecret string    `json:"hashedSecret"`
+	UserId                uuid.UUID `json:"userId"`
+	HashedSecret          string    `json:"hashedSecret"`
+	AuthenticationMethods []string  `json:"authenticationMethods"`
 }
 
 func NewSessionService() middlewares.SessionService {
 	return &sessionService{}
 }
 
-func (s *sessionService) NewSession(ctx context.Context, virtualServerName string, userId uuid.UUID) (*utils.SplitToken, error) {
+func (s *sessionService) NewSession(ctx context.Context, virtualServerName string, userId uuid.UUID, authenticationMethods []string) (*utils.SplitToken, error) {
 	scope := middlewares.GetScope(ctx)
 	dbContext := ioc.GetDependency[database.Context](scope)
 
@@ -44,7 +45,14 @@ func (s *sessionService) NewSession(ctx context.Context, virtualServerName strin
 	clockService := ioc.GetDependency[clock.Service](scope)
 	now := clockService.Now()
 
-	session := repositories.NewSession(virtualServer.Id(), userId, now.Add(time.Hour*24*30))
+	session := repositories.NewSession(
+		virtualServer.Id(),
+		userId,
+		now.Add(time.Hour*24*30),
+		utils.MapSlice(authenticationMethods, func(method string) repositories.AuthenticationMethod {
+			return repositories.AuthenticationMethod(method)
+		}),
+	)
 	token := session.GenerateToken()
 	dbContext.Sessions().Insert(session)
 
@@ -69,8 +77,9 @@ func (s *sessionService) GetSession(ctx context.Context, virtualServerName strin
 
 		if dbSession != nil {
 			tokenValue := sessionTokenValue{
-				UserId:       dbSession.UserId(),
-				HashedSecret: dbSession.HashedSecret(),
+				UserId:                dbSession.UserId(),
+				HashedSecret:          dbSession.HashedSecret(),
+				AuthenticationMethods: dbSession.AuthenticationMethods(),
 			}
 
 			valueBytes, err := json.Marshal(tokenValue)
@@ -83,7 +92,7 @@ func (s *sessionService) GetSession(ctx context.Context, virtualServerName strin
 				return nil, fmt.Errorf("storing session token in kv: %w", err)
 			}
 
-			return middlewares.NewSession(dbSession.UserId(), dbSession.HashedSecret(), clockService.Now()), nil
+			return middlewares.NewSession(dbSession.UserId(), dbSession.HashedSecret(), clockService.Now(), dbSession.AuthenticationMethods()), nil
 		} else {
 			return nil, nil
 		}
@@ -99,7 +108,7 @@ func (s *sessionService) GetSession(ctx context.Context, virtualServerName strin
 		return nil, fmt.Errorf("decoding token from cache: %w", err)
 	}
 
-	return middlewares.NewSession(tokenValue.UserId, tokenValue.HashedSecret, clockService.Now()), nil
+	return middlewares.NewSession(tokenValue.UserId, tokenValue.HashedSecret, clockService.Now(), tokenValue.AuthenticationMethods), nil
 }
 
 func (s *sessionService) DeleteSession(ctx context.Context, virtualServerName string, id uuid.UUID) error {
@@ -162,7 +171,10 @@ func (s *sessionService) loadSessionFromDatabase(ctx context.Context, virtualSer
 	}
 
 	clockService := ioc.GetDependency[clock.Service](scope)
-	return middlewares.NewSession(dbSession.UserId(), dbSession.HashedToken(), clockService.Now()), nil
+	authenticationMethods := utils.MapSlice(dbSession.AuthenticationMethods(), func(method repositories.AuthenticationMethod) string {
+		return string(method)
+	})
+	return middlewares.NewSession(dbSession.UserId(), dbSession.HashedToken(), clockService.Now(), authenticationMethods), nil
 }
 
 func getCacheKey(virtualServerName string, sessionId uuid.UUID) string {
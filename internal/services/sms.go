@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/logging"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const smsWebhookTimeout = 10 * time.Second
+
+var ErrSmsNotConfigured = errors.New("no sms provider is configured")
+
+// SmsProvider sends text messages, e.g. the codes of the SMS second factor.
+type SmsProvider interface {
+	// Enabled is false if no provider is configured, the SMS second factor
+	// is not offered then.
+	Enabled() bool
+	Send(ctx context.Context, to string, body string) error
+}
+
+type disabledSmsProvider struct {
+}
+
+func NewDisabledSmsProvider() SmsProvider {
+	return &disabledSmsProvider{}
+}
+
+func (p *disabledSmsProvider) Enabled() bool {
+	return false
+}
+
+func (p *disabledSmsProvider) Send(context.Context, string, string) error {
+	return ErrSmsNotConfigured
+}
+
+type smsWebhookRequest struct {
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+type webhookSmsProvider struct {
+	config config.SmsWebhookConfig
+	client *http.Client
+}
+
+// NewWebhookSmsProvider posts every message to the configured url and
+// leaves the delivery to whatever is listening there.
+func NewWebhookSmsProvider(webhookConfig config.SmsWebhookConfig) SmsProvider {
+	return &webhookSmsProvider{
+		config: webhookConfig,
+		client: &http.Client{Timeout: smsWebhookTimeout},
+	}
+}
+
+func (p *webhookSmsProvider) Enabled() bool {
+	return true
+}
+
+func (p *webhookSmsProvider) Send(ctx context.Context, to string, body string) error {
+	payload, err := json.Marshal(smsWebhookRequest{To: to, Body: body})
+	if err != nil {
+		return fmt.Errorf("encoding sms webhook request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("creating sms webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range p.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling sms webhook: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms webhook answered with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type logSmsProvider struct {
+	file string
+	mu   sync.Mutex
+}
+
+// NewLogSmsProvider appends every message as a JSON line to the file, or
+// writes it to the log if no file is given. It is meant for local testing.
+func NewLogSmsProvider(file string) SmsProvider {
+	return &logSmsProvider{
+		file: file,
+	}
+}
+
+func (p *logSmsProvider) Enabled() bool {
+	return true
+}
+
+func (p *logSmsProvider) Send(_ context.Context, to string, body string) error {
+	if p.file == "" {
+		logging.Logger.Infof("sms to %s: %s", to, body)
+		return nil
+	}
+
+	line, err := json.Marshal(smsWebhookRequest{To: to, Body: body})
+	if err != nil {
+		return fmt.Errorf("encoding sms: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening sms log file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("writing sms log file: %w", err)
+	}
+
+	return nil
+}
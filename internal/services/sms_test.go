@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/The127/Keyline/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSmsProvider_PostsMessage(t *testing.T) {
+	t.Parallel()
+
+	var received smsWebhookRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewWebhookSmsProvider(config.SmsWebhookConfig{
+		Url:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+
+	err := provider.Send(t.Context(), "+15555550100", "Your code is 123456")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authorization)
+	assert.Equal(t, smsWebhookRequest{To: "+15555550100", Body: "Your code is 123456"}, received)
+}
+
+func TestWebhookSmsProvider_FailsOnErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewWebhookSmsProvider(config.SmsWebhookConfig{Url: server.URL})
+
+	err := provider.Send(t.Context(), "+15555550100", "Your code is 123456")
+	assert.Error(t, err)
+}
+
+func TestLogSmsProvider_AppendsToFile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "sms.log")
+	provider := NewLogSmsProvider(file)
+
+	require.NoError(t, provider.Send(t.Context(), "+15555550100", "first"))
+	require.NoError(t, provider.Send(t.Context(), "+15555550101", "second"))
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+
+	var last smsWebhookRequest
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &last))
+	assert.Equal(t, smsWebhookRequest{To: "+15555550101", Body: "second"}, last)
+}
+
+func TestDisabledSmsProvider(t *testing.T) {
+	t.Parallel()
+
+	provider := NewDisabledSmsProvider()
+
+	assert.False(t, provider.Enabled())
+	assert.ErrorIs(t, provider.Send(t.Context(), "+15555550100", "code"), ErrSmsNotConfigured)
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/The127/Keyline/utils"
 	"time"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
+
+	"github.com/google/uuid"
 )
 
 type TokenType string
@@ -22,8 +25,16 @@ const (
 	OidcRefreshTokenTokenType  TokenType = "oidc_refresh_token"
 	OidcDeviceCodeTokenType    TokenType = "oidc_device_code"
 	OidcUserCodeTokenType      TokenType = "oidc_user_code"
+	PasswordResetTokenType     TokenType = "password_reset"
+	EmailLoginTokenType        TokenType = "email_login"
+	SmsLoginCodeTokenType      TokenType = "sms_login_code"
+	SmsOnboardingCodeTokenType TokenType = "sms_onboarding_code"
 )
 
+// refreshTokenRevocationRetention outlives every refresh token, a revocation
+// is only forgotten once all the tokens it covers have expired.
+const refreshTokenRevocationRetention = time.Hour * 24 * 30
+
 func (t TokenType) Key(token string) string {
 	return fmt.Sprintf("%s:%s", t, token)
 }
@@ -34,8 +45,18 @@ type TokenService interface {
 	GenerateAndStoreToken(ctx context.Context, tokenType TokenType, value string, expiration time.Duration) (string, error)
 	UpdateToken(ctx context.Context, tokenType TokenType, token string, value string, expiration time.Duration) error
 	GetToken(ctx context.Context, tokenType TokenType, token string) (string, error)
+	// TakeToken returns the value of a single use token and deletes it in
+	// one step, concurrent callers cannot both take the same token.
+	TakeToken(ctx context.Context, tokenType TokenType, token string) (string, error)
 	DeleteToken(ctx context.Context, tokenType TokenType, token string) error
 	StoreToken(ctx context.Context, tokenType TokenType, token string, value string, expiration time.Duration) error
+	// RevokeRefreshTokens invalidates all refresh tokens issued to the user
+	// up to now. Refresh tokens are not indexed by user, so the time of the
+	// revocation is stored and checked when a token is redeemed.
+	RevokeRefreshTokens(ctx context.Context, userId uuid.UUID) error
+	// RefreshTokensRevokedAt returns nil if the refresh tokens of the user
+	// were never revoked.
+	RefreshTokensRevokedAt(ctx context.Context, userId uuid.UUID) (*time.Time, error)
 }
 
 type tokenService struct {
@@ -88,6 +109,22 @@ func (t *tokenService) GetToken(ctx context.Context, tokenType TokenType, token
 	return token, nil
 }
 
+func (t *tokenService) TakeToken(ctx context.Context, tokenType TokenType, token string) (string, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+
+	value, err := kvStore.GetAndDelete(ctx, tokenType.Key(token))
+	switch {
+	case errors.Is(err, keyValue.ErrNotFound):
+		return "", ErrTokenNotFound
+
+	case err != nil:
+		return "", fmt.Errorf("taking token from kv: %w", err)
+	}
+
+	return value, nil
+}
+
 func (t *tokenService) DeleteToken(ctx context.Context, tokenType TokenType, token string) error {
 	scope := middlewares.GetScope(ctx)
 	kvStore := ioc.GetDependency[keyValue.Store](scope)
@@ -115,3 +152,42 @@ func (t *tokenService) StoreToken(ctx context.Context, tokenType TokenType, toke
 
 	return nil
 }
+
+func refreshTokenRevocationKey(userId uuid.UUID) string {
+	return fmt.Sprintf("refresh_token_revocation:%s", userId)
+}
+
+func (t *tokenService) RevokeRefreshTokens(ctx context.Context, userId uuid.UUID) error {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+	clockService := ioc.GetDependency[clock.Service](scope)
+
+	revokedAt := clockService.Now().UTC().Format(time.RFC3339Nano)
+	err := kvStore.Set(ctx, refreshTokenRevocationKey(userId), revokedAt, keyValue.WithExpiration(refreshTokenRevocationRetention))
+	if err != nil {
+		return fmt.Errorf("storing refresh token revocation in kv: %w", err)
+	}
+
+	return nil
+}
+
+func (t *tokenService) RefreshTokensRevokedAt(ctx context.Context, userId uuid.UUID) (*time.Time, error) {
+	scope := middlewares.GetScope(ctx)
+	kvStore := ioc.GetDependency[keyValue.Store](scope)
+
+	value, err := kvStore.Get(ctx, refreshTokenRevocationKey(userId))
+	switch {
+	case errors.Is(err, keyValue.ErrNotFound):
+		return nil, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("getting refresh token revocation from kv: %w", err)
+	}
+
+	revokedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing refresh token revocation: %w", err)
+	}
+
+	return &revokedAt, nil
+}
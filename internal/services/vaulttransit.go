@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// vaultTransitKeyStore keeps the private keys in Vault/OpenBao Transit,
+// where they cannot be exported. Every algorithm of a virtual server maps to
+// one Transit key, and every key pair to one version of it. Transit has no
+// room for the schedule of a key pair, so it is kept in the KV mount.
+type vaultTransitKeyStore struct {
+	client       *vault.Client
+	mountPath    string
+	transitMount string
+	prefix       string
+}
+
+func NewVaultTransitKeyStore(addr, token, mountPath, transitMount, prefix string) (KeyStore, error) {
+	c := vault.DefaultConfig()
+	c.Address = addr
+
+	client, err := vault.NewClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("creating vault client: %w", err)
+	}
+
+	client.SetToken(token)
+
+	return &vaultTransitKeyStore{
+		client:       client,
+		mountPath:    mountPath,
+		transitMount: transitMount,
+		prefix:       prefix,
+	}, nil
+}
+
+type transitKeyPairJson struct {
+	Algorithm   string    `json:"algorithm"`
+	Kid         string    `json:"kid"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at"`
+	RotatesAt   time.Time `json:"rotates_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	VerifyOnly  bool      `json:"verify_only,omitempty"`
+}
+
+func transitKeyType(algorithm config.SigningAlgorithm) (string, error) {
+	switch algorithm {
+	case config.SigningAlgorithmRS256,
+		config.SigningAlgorithmRS384,
+		config.SigningAlgorithmRS512,
+		config.SigningAlgorithmPS256:
+		return "rsa-4096", nil
+
+	case config.SigningAlgorithmES256:
+		return "ecdsa-p256", nil
+
+	case config.SigningAlgorithmES384:
+		return "ecdsa-p384", nil
+
+	case config.SigningAlgorithmES512:
+		return "ecdsa-p521", nil
+
+	case config.SigningAlgorithmEdDSA:
+		return "ed25519", nil
+
+	default:
+		return "", fmt.Errorf("not implemented for algorithm: %s", algorithm)
+	}
+}
+
+func (v *vaultTransitKeyStore) transitKeyName(virtualServerName string, algorithm config.SigningAlgorithm) string {
+	// transit key names are a single path segment
+	name := fmt.Sprintf("%s%s-%s", v.prefix, virtualServerName, strings.ToLower(string(algorithm)))
+	return strings.ReplaceAll(name, "/", "-")
+}
+
+func (v *vaultTransitKeyStore) metadataPath(virtualServerName string, algorithm config.SigningAlgorithm, kid string) string {
+	return fmt.Sprintf("%s%s/%s/%s", v.prefix, virtualServerName, algorithm, kid)
+}
+
+type transitKeyVersion struct {
+	publicKey any
+	kid       string
+}
+
+// readTransitKey returns the versions of a Transit key that still exist,
+// or nil if the key does not exist.
+func (v *vaultTransitKeyStore) readTransitKey(name string, algorithm config.SigningAlgorithm) (map[int]transitKeyVersion, int, error) {
+	secret, err := v.client.Logical().Read(fmt.Sprintf("%s/keys/%s", v.transitMount, name))
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading transit key: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, 0, nil
+	}
+
+	latestVersion, err := parseVaultInt(secret.Data["latest_version"])
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing latest version: %w", err)
+	}
+
+	keys, ok := secret.Data["keys"].(map[string]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid transit key format")
+	}
+
+	versions := make(map[int]transitKeyVersion, len(keys))
+	for versionString, rawVersion := range keys {
+		version, err := strconv.Atoi(versionString)
+		if err != nil {
+			return nil, 0, fmt.Errorf("parsing key version: %w", err)
+		}
+
+		versionData, ok := rawVersion.(map[string]any)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid transit key version format")
+		}
+
+		encodedPublicKey, _ := versionData["public_key"].(string)
+		publicKey, kid, err := parseTransitPublicKey(algorithm, encodedPublicKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("parsing public key of version %d: %w", version, err)
+		}
+
+		versions[version] = transitKeyVersion{
+			publicKey: publicKey,
+			kid:       kid,
+		}
+	}
+
+	return versions, latestVersion, nil
+}
+
+func parseVaultInt(value any) (int, error) {
+	switch value := value.(type) {
+	case json.Number:
+		i, err := value.Int64()
+		return int(i), err
+	case float64:
+		return int(value), nil
+	case int:
+		return value, nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", value)
+	}
+}
+
+// parseTransitPublicKey parses a Transit public key, a PEM block for RSA and
+// ECDSA or the base64 encoded key for Ed25519, and computes its kid like
+// for generated keys.
+func parseTransitPublicKey(algorithm config.SigningAlgorithm, encoded string) (any, string, error) {
+	if algorithm == config.SigningAlgorithmEdDSA {
+		publicKey, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "", fmt.Errorf("decoding ed25519 public key: %w", err)
+		}
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, "", fmt.Errorf("invalid ed25519 public key length %d", len(publicKey))
+		}
+		return ed25519.PublicKey(publicKey), computeEdCSAPublicKeyKid(publicKey), nil
+	}
+
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, "", fmt.Errorf("failed to decode PEM block")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing public key: %w", err)
+	}
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		kid, err := computeRSAPublicKeyKid(publicKey)
+		return publicKey, kid, err
+
+	case *ecdsa.PublicKey:
+		kid, err := computeECDSAPublicKeyKid(publicKey)
+		return publicKey, kid, err
+
+	default:
+		return nil, "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// GenerateKey creates the Transit key of the algorithm or rotates it to a
+// new version.
+func (v *vaultTransitKeyStore) GenerateKey(virtualServerName string, algorithm config.SigningAlgorithm, createdAt time.Time) (KeyPair, error) {
+	name := v.transitKeyName(virtualServerName, algorithm)
+
+	versions, _, err := v.readTransitKey(name, algorithm)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	if versions == nil {
+		keyType, err := transitKeyType(algorithm)
+		if err != nil {
+			return KeyPair{}, err
+		}
+
+		_, err = v.client.Logical().Write(fmt.Sprintf("%s/keys/%s", v.transitMount, name), map[string]any{
+			"type":       keyType,
+			"exportable": false,
+		})
+		if err != nil {
+			return KeyPair{}, fmt.Errorf("creating transit key: %w", err)
+		}
+	} else {
+		_, err = v.client.Logical().Write(fmt.Sprintf("%s/keys/%s/rotate", v.transitMount, name), nil)
+		if err != nil {
+			return KeyPair{}, fmt.Errorf("rotating transit key: %w", err)
+		}
+	}
+
+	versions, latestVersion, err := v.readTransitKey(name, algorithm)
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	version, ok := versions[latestVersion]
+	if !ok {
+		return KeyPair{}, fmt.Errorf("transit key %s has no version %d", name, latestVersion)
+	}
+
+	return KeyPair{
+		algorithm:   algorithm,
+		publicKey:   version.publicKey,
+		kid:         version.kid,
+		createdAt:   createdAt,
+		activatesAt: createdAt,
+		signer:      v.signer(name, latestVersion, version.publicKey),
+	}, nil
+}
+
+func (v *vaultTransitKeyStore) signer(name string, version int, publicKey any) *transitSigner {
+	return &transitSigner{
+		client:    v.client,
+		path:      fmt.Sprintf("%s/sign/%s", v.transitMount, name),
+		version:   version,
+		publicKey: publicKey,
+	}
+}
+
+// Add stores the schedule of a key pair created by GenerateKey.
+func (v *vaultTransitKeyStore) Add(virtualServerName string, keyPair KeyPair) error {
+	signer, ok := keyPair.signer.(*transitSigner)
+	if !ok {
+		return fmt.Errorf("the vault transit key store only stores keys it generated")
+	}
+
+	data, err := json.Marshal(transitKeyPairJson{
+		Algorithm:   string(keyPair.algorithm),
+		Kid:         keyPair.kid,
+		Version:     signer.version,
+		CreatedAt:   keyPair.createdAt,
+		ActivatesAt: keyPair.activatesAt,
+		RotatesAt:   keyPair.rotatesAt,
+		ExpiresAt:   keyPair.expiresAt,
+		VerifyOnly:  keyPair.verifyOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling key pair: %w", err)
+	}
+
+	path := v.metadataPath(virtualServerName, keyPair.algorithm, keyPair.kid)
+	_, err = v.client.KVv2(v.mountPath).Put(context.Background(), path, map[string]any{
+		"data": string(data),
+	})
+	if err != nil {
+		return fmt.Errorf("storing key in vault: %w", err)
+	}
+
+	return nil
+}
+
+func (v *vaultTransitKeyStore) readMetadata(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (*transitKeyPairJson, error) {
+	secret, err := v.client.KVv2(v.mountPath).Get(context.Background(), v.metadataPath(virtualServerName, algorithm, kid))
+	if err != nil {
+		if errors.Is(err, vault.ErrSecretNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading key from vault: %w", err)
+	}
+
+	rawData, ok := secret.Data["data"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid vault data format")
+	}
+
+	var dto transitKeyPairJson
+	if err := json.Unmarshal([]byte(rawData), &dto); err != nil {
+		return nil, fmt.Errorf("unmarshaling key pair: %w", err)
+	}
+
+	return &dto, nil
+}
+
+func (v *vaultTransitKeyStore) listKids(virtualServerName string, algorithm config.SigningAlgorithm) ([]string, error) {
+	metadataPath := fmt.Sprintf("%s/metadata/%s%s/%s", v.mountPath, v.prefix, virtualServerName, algorithm)
+
+	secretList, err := v.client.Logical().List(metadataPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing keys in vault: %w", err)
+	}
+	if secretList == nil || secretList.Data == nil {
+		return nil, nil
+	}
+
+	keysRaw, ok := secretList.Data["keys"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	kids := make([]string, 0, len(keysRaw))
+	for _, k := range keysRaw {
+		if kid, ok := k.(string); ok {
+			kids = append(kids, kid)
+		}
+	}
+
+	return kids, nil
+}
+
+func (v *vaultTransitKeyStore) keyPair(name string, versions map[int]transitKeyVersion, dto *transitKeyPairJson) (KeyPair, error) {
+	version, ok := versions[dto.Version]
+	if !ok || version.kid != dto.Kid {
+		return KeyPair{}, fmt.Errorf("transit key %s has no version %d with kid %s", name, dto.Version, dto.Kid)
+	}
+
+	return KeyPair{
+		algorithm:   config.SigningAlgorithm(dto.Algorithm),
+		publicKey:   version.publicKey,
+		kid:         dto.Kid,
+		createdAt:   dto.CreatedAt,
+		activatesAt: dto.ActivatesAt,
+		rotatesAt:   dto.RotatesAt,
+		expiresAt:   dto.ExpiresAt,
+		verifyOnly:  dto.VerifyOnly,
+		signer:      v.signer(name, dto.Version, version.publicKey),
+	}, nil
+}
+
+func (v *vaultTransitKeyStore) Get(virtualServerName string, algorithm config.SigningAlgorithm, kid string) (*KeyPair, error) {
+	dto, err := v.readMetadata(virtualServerName, algorithm, kid)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil {
+		return nil, nil
+	}
+
+	name := v.transitKeyName(virtualServerName, algorithm)
+	versions, _, err := v.readTransitKey(name, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair, err := v.keyPair(name, versions, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyPair, nil
+}
+
+func (v *vaultTransitKeyStore) GetAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) ([]KeyPair, error) {
+	kids, err := v.listKids(virtualServerName, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(kids) == 0 {
+		return nil, nil
+	}
+
+	name := v.transitKeyName(virtualServerName, algorithm)
+	versions, _, err := v.readTransitKey(name, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPairs := make([]KeyPair, 0, len(kids))
+	for _, kid := range kids {
+		dto, err := v.readMetadata(virtualServerName, algorithm, kid)
+		if err != nil {
+			return nil, err
+		}
+		if dto == nil {
+			continue
+		}
+
+		keyPair, err := v.keyPair(name, versions, dto)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, keyPair)
+	}
+
+	return keyPairs, nil
+}
+
+func (v *vaultTransitKeyStore) GetAll(virtualServerName string) ([]KeyPair, error) {
+	var allKeys []KeyPair
+	for _, alg := range config.SupportedSigningAlgorithms {
+		algKeys, err := v.GetAllForAlgorithm(virtualServerName, alg)
+		if err != nil {
+			return nil, err
+		}
+		allKeys = append(allKeys, algKeys...)
+	}
+	return allKeys, nil
+}
+
+// Remove forgets the key pair and raises the minimum version of the Transit
+// key to the oldest version that is still in use, so that Transit refuses
+// the versions of removed key pairs as well.
+func (v *vaultTransitKeyStore) Remove(virtualServerName string, algorithm config.SigningAlgorithm, kid string) error {
+	err := v.client.KVv2(v.mountPath).DeleteMetadata(context.Background(), v.metadataPath(virtualServerName, algorithm, kid))
+	if err != nil {
+		return fmt.Errorf("deleting key from vault: %w", err)
+	}
+
+	remaining, err := v.GetAllForAlgorithm(virtualServerName, algorithm)
+	if err != nil {
+		return fmt.Errorf("listing remaining keys: %w", err)
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	minVersion := remaining[0].signer.(*transitSigner).version
+	for _, keyPair := range remaining[1:] {
+		minVersion = min(minVersion, keyPair.signer.(*transitSigner).version)
+	}
+
+	name := v.transitKeyName(virtualServerName, algorithm)
+	_, err = v.client.Logical().Write(fmt.Sprintf("%s/keys/%s/config", v.transitMount, name), map[string]any{
+		"min_decryption_version": minVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("updating transit key config: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveAllForAlgorithm forgets all key pairs of the algorithm and deletes
+// the Transit key.
+func (v *vaultTransitKeyStore) RemoveAllForAlgorithm(virtualServerName string, algorithm config.SigningAlgorithm) error {
+	kids, err := v.listKids(virtualServerName, algorithm)
+	if err != nil {
+		return fmt.Errorf("listing keys for removal: %w", err)
+	}
+	for _, kid := range kids {
+		err := v.client.KVv2(v.mountPath).DeleteMetadata(context.Background(), v.metadataPath(virtualServerName, algorithm, kid))
+		if err != nil {
+			return fmt.Errorf("removing key %s: %w", kid, err)
+		}
+	}
+
+	name := v.transitKeyName(virtualServerName, algorithm)
+	versions, _, err := v.readTransitKey(name, algorithm)
+	if err != nil {
+		return err
+	}
+	if versions == nil {
+		return nil
+	}
+
+	_, err = v.client.Logical().Write(fmt.Sprintf("%s/keys/%s/config", v.transitMount, name), map[string]any{
+		"deletion_allowed": true,
+	})
+	if err != nil {
+		return fmt.Errorf("allowing deletion of transit key: %w", err)
+	}
+
+	_, err = v.client.Logical().Delete(fmt.Sprintf("%s/keys/%s", v.transitMount, name))
+	if err != nil {
+		return fmt.Errorf("deleting transit key: %w", err)
+	}
+
+	return nil
+}
+
+// transitSigner signs digests with one version of a Transit key. It returns
+// the same signature formats as the crypto.Signer implementations of the
+// standard library.
+type transitSigner struct {
+	client    *vault.Client
+	path      string
+	version   int
+	publicKey any
+}
+
+func (s *transitSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func transitHashAlgorithm(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA256:
+		return "sha2-256", nil
+	case crypto.SHA384:
+		return "sha2-384", nil
+	case crypto.SHA512:
+		return "sha2-512", nil
+	default:
+		return "", fmt.Errorf("unsupported hash %v", hash)
+	}
+}
+
+func (s *transitSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	data := map[string]any{
+		"input":       base64.StdEncoding.EncodeToString(digest),
+		"key_version": s.version,
+	}
+
+	// Ed25519 signs the message itself
+	if opts.HashFunc() != 0 {
+		hashAlgorithm, err := transitHashAlgorithm(opts.HashFunc())
+		if err != nil {
+			return nil, err
+		}
+		data["prehashed"] = true
+		data["hash_algorithm"] = hashAlgorithm
+		data["marshaling_algorithm"] = "asn1"
+
+		if _, ok := s.publicKey.(*rsa.PublicKey); ok {
+			data["signature_algorithm"] = "pkcs1v15"
+			if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+				if pssOpts.SaltLength != rsa.PSSSaltLengthEqualsHash {
+					return nil, fmt.Errorf("unsupported pss salt length %d", pssOpts.SaltLength)
+				}
+				data["signature_algorithm"] = "pss"
+				data["salt_length"] = "hash"
+			}
+		}
+	}
+
+	secret, err := s.client.Logical().Write(s.path, data)
+	if err != nil {
+		return nil, fmt.Errorf("signing with transit: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("empty transit sign response")
+	}
+
+	// vault:v<version>:<base64 signature>
+	signature, _ := secret.Data["signature"].(string)
+	parts := strings.Split(signature, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid transit signature format")
+	}
+
+	return base64.StdEncoding.DecodeString(parts[2])
+}
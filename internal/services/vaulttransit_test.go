@@ -0,0 +1,356 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/The127/go-clock"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault implements the parts of the KV v2 and Transit APIs the vault
+// transit key store uses.
+type fakeVault struct {
+	mu          sync.Mutex
+	kv          map[string]map[string]any
+	transitKeys map[string]*fakeTransitKey
+}
+
+type fakeTransitKey struct {
+	keyType         string
+	versions        map[int]crypto.Signer
+	minVersion      int
+	deletionAllowed bool
+}
+
+func newFakeVault(t *testing.T) string {
+	t.Helper()
+
+	v := &fakeVault{
+		kv:          make(map[string]map[string]any),
+		transitKeys: make(map[string]*fakeTransitKey),
+	}
+	server := httptest.NewServer(v)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func (v *fakeVault) respond(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var body map[string]any
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case strings.HasPrefix(path, "secret/data/"):
+		v.serveKvData(w, r, strings.TrimPrefix(path, "secret/data/"), body)
+	case strings.HasPrefix(path, "secret/metadata/"):
+		v.serveKvMetadata(w, r, strings.TrimPrefix(path, "secret/metadata/"))
+	case strings.HasPrefix(path, "transit/keys/"):
+		v.serveTransitKey(w, r, strings.TrimPrefix(path, "transit/keys/"), body)
+	case strings.HasPrefix(path, "transit/sign/"):
+		v.serveTransitSign(w, strings.TrimPrefix(path, "transit/sign/"), body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (v *fakeVault) serveKvData(w http.ResponseWriter, r *http.Request, path string, body map[string]any) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		v.kv[path] = body["data"].(map[string]any)
+		v.respond(w, map[string]any{"version": 1, "created_time": time.Now().Format(time.RFC3339), "deletion_time": ""})
+
+	case http.MethodGet:
+		data, ok := v.kv[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		v.respond(w, map[string]any{
+			"data":     data,
+			"metadata": map[string]any{"version": 1, "created_time": time.Now().Format(time.RFC3339), "deletion_time": ""},
+		})
+	}
+}
+
+func (v *fakeVault) serveKvMetadata(w http.ResponseWriter, r *http.Request, path string) {
+	switch r.Method {
+	case http.MethodDelete:
+		delete(v.kv, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodGet:
+		prefix := path + "/"
+		var keys []string
+		for key := range v.kv {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, strings.TrimPrefix(key, prefix))
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		v.respond(w, map[string]any{"keys": keys})
+	}
+}
+
+func generateFakeTransitKey(keyType string) crypto.Signer {
+	var signer crypto.Signer
+	var err error
+	switch keyType {
+	case "rsa-4096":
+		// a smaller key keeps the tests fast
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa-p256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ecdsa-p521":
+		signer, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "ed25519":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		panic("unsupported key type " + keyType)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}
+
+func (v *fakeVault) serveTransitKey(w http.ResponseWriter, r *http.Request, path string, body map[string]any) {
+	name, action, _ := strings.Cut(path, "/")
+	key := v.transitKeys[name]
+
+	switch {
+	case r.Method == http.MethodGet && key == nil,
+		r.Method != http.MethodGet && action != "" && key == nil:
+		w.WriteHeader(http.StatusNotFound)
+
+	case r.Method == http.MethodGet:
+		keys := make(map[string]any)
+		latest := 0
+		for version, signer := range key.versions {
+			latest = max(latest, version)
+			if version < key.minVersion {
+				continue
+			}
+
+			var publicKey string
+			if edPublicKey, ok := signer.Public().(ed25519.PublicKey); ok {
+				publicKey = base64.StdEncoding.EncodeToString(edPublicKey)
+			} else {
+				der, _ := x509.MarshalPKIXPublicKey(signer.Public())
+				publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			}
+			keys[strconv.Itoa(version)] = map[string]any{"public_key": publicKey}
+		}
+		v.respond(w, map[string]any{"latest_version": latest, "type": key.keyType, "keys": keys})
+
+	case r.Method == http.MethodDelete:
+		if !key.deletionAllowed {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delete(v.transitKeys, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "":
+		keyType := body["type"].(string)
+		v.transitKeys[name] = &fakeTransitKey{
+			keyType:    keyType,
+			versions:   map[int]crypto.Signer{1: generateFakeTransitKey(keyType)},
+			minVersion: 1,
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "rotate":
+		key.versions[len(key.versions)+1] = generateFakeTransitKey(key.keyType)
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "config":
+		if minVersion, ok := body["min_decryption_version"].(float64); ok {
+			key.minVersion = int(minVersion)
+		}
+		if deletionAllowed, ok := body["deletion_allowed"].(bool); ok {
+			key.deletionAllowed = deletionAllowed
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (v *fakeVault) serveTransitSign(w http.ResponseWriter, name string, body map[string]any) {
+	key := v.transitKeys[name]
+	version := int(body["key_version"].(float64))
+	if key == nil || version < key.minVersion || key.versions[version] == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	signer := key.versions[version]
+
+	input, _ := base64.StdEncoding.DecodeString(body["input"].(string))
+
+	var opts crypto.SignerOpts = crypto.Hash(0)
+	switch body["hash_algorithm"] {
+	case "sha2-256":
+		opts = crypto.SHA256
+	case "sha2-384":
+		opts = crypto.SHA384
+	case "sha2-512":
+		opts = crypto.SHA512
+	}
+	if body["signature_algorithm"] == "pss" {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: opts.HashFunc()}
+	}
+
+	signature, err := signer.Sign(rand.Reader, input, opts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	v.respond(w, map[string]any{
+		"signature":   fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(signature)),
+		"key_version": version,
+	})
+}
+
+// newTestVaultTransitKeyStore runs against the Vault dev server in VAULT_ADDR
+// (with the transit engine enabled at "transit") if set, and against a fake
+// of the API otherwise.
+func newTestVaultTransitKeyStore(t *testing.T) KeyStore {
+	t.Helper()
+
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" {
+		addr, token = newFakeVault(t), "token"
+	}
+
+	keyStore, err := NewVaultTransitKeyStore(addr, token, "secret", "transit", "test-"+uuid.NewString()+"/")
+	require.NoError(t, err)
+	return keyStore
+}
+
+func TestVaultTransitKeyStore_SignsWithEveryAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range config.SupportedSigningAlgorithms {
+		t.Run(string(alg), func(t *testing.T) {
+			t.Parallel()
+
+			// arrange
+			keyStore := newTestVaultTransitKeyStore(t)
+			clockService, _ := clock.NewMockClock(time.Now())
+			testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+
+			// act
+			_, err := testee.Generate(clockService, "vs", alg, repositories.DefaultKeyRotationPolicy())
+			require.NoError(t, err)
+
+			// assert
+			keyPair, err := testee.GetKey("vs", alg)
+			require.NoError(t, err)
+			require.Nil(t, keyPair.PrivateKey())
+			signTestJwt(t, keyPair)
+		})
+	}
+}
+
+func TestVaultTransitKeyStore_RotationMapsToKeyVersions(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	keyStore := newTestVaultTransitKeyStore(t)
+	now := time.Now()
+	clockService, setTime := clock.NewMockClock(now)
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+	policy := repositories.DefaultKeyRotationPolicy()
+
+	first, err := testee.Generate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+	setTime(now.Add(time.Minute))
+
+	// act
+	second, err := testee.Rotate(clockService, "vs", config.SigningAlgorithmEdDSA, policy)
+	require.NoError(t, err)
+
+	// assert
+	require.NotEqual(t, first.GetKid(), second.GetKid())
+	require.Equal(t, 1, second.signer.(*transitSigner).version-first.signer.(*transitSigner).version)
+
+	active, err := testee.GetKey("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	require.Equal(t, second.GetKid(), active.GetKid())
+	signTestJwt(t, active)
+
+	retired, err := testee.GetVerificationKey("vs", config.SigningAlgorithmEdDSA, first.GetKid())
+	require.NoError(t, err)
+	require.Equal(t, first.PublicKey(), retired.PublicKey())
+
+	err = testee.Revoke("vs", config.SigningAlgorithmEdDSA, first.GetKid())
+	require.NoError(t, err)
+	keyPairs, err := keyStore.GetAll("vs")
+	require.NoError(t, err)
+	require.Len(t, keyPairs, 1)
+	require.Equal(t, second.GetKid(), keyPairs[0].GetKid())
+
+	err = keyStore.RemoveAllForAlgorithm("vs", config.SigningAlgorithmEdDSA)
+	require.NoError(t, err)
+	keyPairs, err = keyStore.GetAll("vs")
+	require.NoError(t, err)
+	require.Empty(t, keyPairs)
+}
+
+func TestVaultTransitKeyStore_RejectsImports(t *testing.T) {
+	t.Parallel()
+
+	// arrange
+	keyStore := newTestVaultTransitKeyStore(t)
+	clockService, _ := clock.NewMockClock(time.Now())
+	testee := NewKeyService(caching.NewMemoryCache[KeyCacheKey, KeyCacheEntry](), keyStore, clockService)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// act
+	_, err = testee.Import(clockService, "vs", config.SigningAlgorithmEdDSA, repositories.DefaultKeyRotationPolicy(), ImportedKey{
+		Kid:        "imported",
+		PrivateKey: privateKeyJwk(t, edKey),
+		NotAfter:   time.Now().Add(time.Hour),
+	})
+
+	// assert
+	require.Error(t, err)
+}
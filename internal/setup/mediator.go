@@ -18,10 +18,16 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(m, queries.HandleGetVirtualServerQuery)
 	mediatr.RegisterHandler(m, commands.HandleCreateVirtualServer)
 	mediatr.RegisterHandler(m, commands.HandlePatchVirtualServer)
+	mediatr.RegisterHandler(m, queries.HandleListSigningKeysQuery)
+	mediatr.RegisterHandler(m, commands.HandleRotateSigningKeys)
+	mediatr.RegisterHandler(m, commands.HandleRevokeSigningKey)
+	mediatr.RegisterHandler(m, commands.HandleImportSigningKey)
 
 	mediatr.RegisterHandler(m, queries.HandleListPasswordRules)
 	mediatr.RegisterHandler(m, commands.HandleCreatePasswordRule)
 	mediatr.RegisterHandler(m, commands.HandleUpdatePasswordRule)
+	mediatr.RegisterHandler(m, queries.HandleGetMfaRules)
+	mediatr.RegisterHandler(m, commands.HandleUpdateMfaRules)
 
 	mediatr.RegisterHandler(m, queries.HandleListTemplates)
 	mediatr.RegisterHandler(m, queries.HandleGetTemplate)
@@ -29,6 +35,8 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(m, commands.HandleRegisterUser)
 	mediatr.RegisterHandler(m, commands.HandleCreateUser)
 	mediatr.RegisterHandler(m, commands.HandleVerifyEmail)
+	mediatr.RegisterHandler(m, commands.HandleRequestPasswordReset)
+	mediatr.RegisterHandler(m, commands.HandleResetPassword)
 	mediatr.RegisterHandler(m, commands.HandleSetPassword)
 	mediatr.RegisterHandler(m, queries.HandleGetUserQuery)
 	mediatr.RegisterHandler(m, commands.HandlePatchUser)
@@ -42,6 +50,18 @@ func Mediator(dc *ioc.DependencyCollection) {
 	mediatr.RegisterHandler(m, commands.HandlePatchUserMetadata)
 	mediatr.RegisterHandler(m, commands.HandlePatchUserAppMetadata)
 	mediatr.RegisterHandler(m, queries.HandleListPasskeys)
+	mediatr.RegisterHandler(m, commands.HandleDeletePasskey)
+	mediatr.RegisterHandler(m, commands.HandlePatchPasskey)
+	mediatr.RegisterHandler(m, queries.HandleGetUserLockout)
+	mediatr.RegisterHandler(m, commands.HandleUnlockUser)
+	mediatr.RegisterHandler(m, commands.HandleRegenerateRecoveryCodes)
+	mediatr.RegisterHandler(m, commands.HandleConsumeRecoveryCode)
+	mediatr.RegisterHandler(m, queries.HandleListTotp)
+	mediatr.RegisterHandler(m, commands.HandlePatchTotp)
+	mediatr.RegisterHandler(m, commands.HandleDeleteTotp)
+	mediatr.RegisterHandler(m, queries.HandleListTrustedDevices)
+	mediatr.RegisterHandler(m, commands.HandleDeleteTrustedDevice)
+	mediatr.RegisterHandler(m, commands.HandleResetSecondFactors)
 
 	mediatr.RegisterHandler(m, commands.HandleCreateResourceServer)
 	mediatr.RegisterHandler(m, commands.HandlePatchResourceServer)
@@ -73,9 +93,43 @@ func Mediator(dc *ioc.DependencyCollection) {
 
 	mediatr.RegisterHandler(m, queries.HandleListGroups)
 
+	mediatr.RegisterHandler(m, commands.HandleCreateIdentityProvider)
+	mediatr.RegisterHandler(m, commands.HandlePatchIdentityProvider)
+	mediatr.RegisterHandler(m, commands.HandleDeleteIdentityProvider)
+	mediatr.RegisterHandler(m, queries.HandleListIdentityProviders)
+	mediatr.RegisterHandler(m, queries.HandleGetIdentityProvider)
+
+	mediatr.RegisterHandler(m, commands.HandleCreateLdapProvider)
+	mediatr.RegisterHandler(m, commands.HandlePatchLdapProvider)
+	mediatr.RegisterHandler(m, commands.HandleDeleteLdapProvider)
+	mediatr.RegisterHandler(m, commands.HandleSyncLdapProvider)
+	mediatr.RegisterHandler(m, commands.HandleImportLdapUser)
+	mediatr.RegisterHandler(m, queries.HandleListLdapProviders)
+	mediatr.RegisterHandler(m, queries.HandleGetLdapProvider)
+
+	mediatr.RegisterHandler(m, commands.HandleCreateScimUser)
+	mediatr.RegisterHandler(m, commands.HandleReplaceScimUser)
+	mediatr.RegisterHandler(m, commands.HandleDeleteScimUser)
+	mediatr.RegisterHandler(m, commands.HandleCreateScimGroup)
+	mediatr.RegisterHandler(m, commands.HandleReplaceScimGroup)
+	mediatr.RegisterHandler(m, commands.HandleDeleteScimGroup)
+	mediatr.RegisterHandler(m, queries.HandleListScimUsers)
+	mediatr.RegisterHandler(m, queries.HandleGetScimUser)
+	mediatr.RegisterHandler(m, queries.HandleListScimGroups)
+	mediatr.RegisterHandler(m, queries.HandleGetScimGroup)
+
+	mediatr.RegisterHandler(m, commands.HandleSetProvisioningTarget)
+	mediatr.RegisterHandler(m, commands.HandleDeleteProvisioningTarget)
+	mediatr.RegisterHandler(m, commands.HandleResyncProvisioningTarget)
+	mediatr.RegisterHandler(m, queries.HandleGetProvisioningTarget)
+	mediatr.RegisterHandler(m, queries.HandleListProvisioningTargets)
+
 	mediatr.RegisterHandler(m, queries.HandleListAuditEntries)
 
 	mediatr.RegisterEventHandler(m, events.QueueEmailVerificationJobOnUserCreatedEvent)
+	mediatr.RegisterEventHandler(m, events.QueueProvisioningOnUserCreatedEvent)
+	mediatr.RegisterEventHandler(m, events.QueueProvisioningOnUserUpdatedEvent)
+	mediatr.RegisterEventHandler(m, events.QueueProvisioningOnUserDeletedEvent)
 
 	mediatr.RegisterBehaviour(m, behaviours.PolicyBehaviour)
 	mediatr.RegisterBehaviour(m, behaviours.SaveChangesBehaviour)
@@ -5,19 +5,22 @@ import (
 	"github.com/The127/Keyline/config"
 	"github.com/The127/Keyline/internal/behaviours"
 	"github.com/The127/Keyline/internal/caching"
+	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/password"
 	"github.com/The127/Keyline/internal/services"
 	"github.com/The127/Keyline/internal/services/audit"
 	"github.com/The127/Keyline/internal/services/claimsMapping"
 	"github.com/The127/Keyline/internal/services/keyValue"
+	"github.com/The127/Keyline/internal/webauthn"
 
+	"github.com/The127/go-clock"
 	"github.com/The127/ioc"
 )
 
 func KeyServices(dc *ioc.DependencyCollection, keyStoreConfig config.KeyStoreConfig) {
 	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.KeyCache {
-		return caching.NewMemoryCache[services.KeyCacheKey, services.KeyPair]()
+		return caching.NewMemoryCache[services.KeyCacheKey, services.KeyCacheEntry]()
 	})
 	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.KeyStore {
 		switch keyStoreConfig.Mode {
@@ -39,6 +42,41 @@ func KeyServices(dc *ioc.DependencyCollection, keyStoreConfig config.KeyStoreCon
 			}
 			return keyStore
 
+		case config.KeyStoreModeVaultTransit:
+			keyStore, err := services.NewVaultTransitKeyStore(
+				keyStoreConfig.Vault.Address,
+				keyStoreConfig.Vault.Token,
+				keyStoreConfig.Vault.Mount,
+				keyStoreConfig.Vault.TransitMount,
+				keyStoreConfig.Vault.Prefix,
+			)
+			if err != nil {
+				panic(fmt.Errorf("creating vault transit key store: %w", err))
+			}
+			return keyStore
+
+		case config.KeyStoreModePkcs11:
+			keyStore, err := services.NewPkcs11KeyStore(
+				keyStoreConfig.Pkcs11.ModulePath,
+				keyStoreConfig.Pkcs11.Slot,
+				keyStoreConfig.Pkcs11.Pin,
+				keyStoreConfig.Pkcs11.LabelPrefix,
+			)
+			if err != nil {
+				panic(fmt.Errorf("creating pkcs11 key store: %w", err))
+			}
+			return keyStore
+
+		case config.KeyStoreModeDatabase:
+			encryption, err := services.NewKeyEncryption(
+				keyStoreConfig.Database.MasterKey,
+				keyStoreConfig.Database.PreviousMasterKeys,
+			)
+			if err != nil {
+				panic(fmt.Errorf("creating key store encryption: %w", err))
+			}
+			return services.NewDatabaseKeyStore(ioc.GetDependency[database.Factory](dp), encryption)
+
 		default:
 			panic("not implemented")
 		}
@@ -47,6 +85,7 @@ func KeyServices(dc *ioc.DependencyCollection, keyStoreConfig config.KeyStoreCon
 		return services.NewKeyService(
 			ioc.GetDependency[services.KeyCache](dp),
 			ioc.GetDependency[services.KeyStore](dp),
+			ioc.GetDependency[clock.Service](dp),
 		)
 	})
 }
@@ -67,12 +106,70 @@ func Services(dc *ioc.DependencyCollection) {
 	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) middlewares.SessionService {
 		return services.NewSessionService()
 	})
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.LockoutService {
+		return services.NewLockoutService()
+	})
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.MailThrottle {
+		return services.NewMailThrottle()
+	})
 	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) behaviours.AuditLogger {
 		return audit.NewDbAuditLogger()
 	})
 	ioc.RegisterScoped(dc, func(_ *ioc.DependencyProvider) password.Validator {
 		return password.NewValidator()
 	})
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) webauthn.TrustStore {
+		trustStore, err := webauthn.LoadTrustStore(
+			config.C.Webauthn.MdsBlobFile,
+			config.C.Webauthn.MdsRootCertificateFile,
+			config.C.Webauthn.AppleRootCertificateFile,
+			ioc.GetDependency[clock.Service](dp).Now(),
+		)
+		if err != nil {
+			panic(fmt.Errorf("loading webauthn trust anchors: %w", err))
+		}
+		return trustStore
+	})
+}
+
+func Sms(dc *ioc.DependencyCollection, smsConfig config.SmsConfig) {
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.SmsProvider {
+		switch smsConfig.Mode {
+		case config.SmsModeNone:
+			return services.NewDisabledSmsProvider()
+
+		case config.SmsModeLog:
+			return services.NewLogSmsProvider(smsConfig.Log.File)
+
+		case config.SmsModeWebhook:
+			return services.NewWebhookSmsProvider(smsConfig.Webhook)
+
+		default:
+			panic("sms mode missing or not supported")
+		}
+	})
+}
+
+func BreachedPasswords(dc *ioc.DependencyCollection, breachedPasswordsConfig config.BreachedPasswordsConfig) {
+	ioc.RegisterSingleton(dc, func(dp *ioc.DependencyProvider) services.BreachedPasswordChecker {
+		switch breachedPasswordsConfig.Mode {
+		case config.BreachedPasswordsModeNone:
+			return services.NewDisabledBreachedPasswordChecker()
+
+		case config.BreachedPasswordsModeApi:
+			return services.NewApiBreachedPasswordChecker(breachedPasswordsConfig.Api.Url)
+
+		case config.BreachedPasswordsModeOffline:
+			checker, err := services.NewIndexBreachedPasswordChecker(breachedPasswordsConfig.Offline.IndexFile)
+			if err != nil {
+				panic(fmt.Errorf("loading breached passwords index: %w", err))
+			}
+			return checker
+
+		default:
+			panic("breached passwords mode missing or not supported")
+		}
+	})
 }
 
 func Caching(dc *ioc.DependencyCollection, mode config.CacheMode) {
@@ -0,0 +1,61 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type appleStatement struct {
+	X5c [][]byte `cbor:"x5c"`
+}
+
+type appleNonceExtension struct {
+	Nonce []byte `asn1:"tag:1,explicit"`
+}
+
+// verifyApple verifies an Apple anonymous attestation, see
+// https://www.w3.org/TR/webauthn-3/#sctn-apple-anonymous-attestation.
+func verifyApple(raw cbor.RawMessage, attestation *Attestation, clientDataHash []byte) error {
+	var statement appleStatement
+	err := cbor.Unmarshal(raw, &statement)
+	if err != nil {
+		return fmt.Errorf("%w: decoding apple statement: %w", ErrInvalidAttestation, err)
+	}
+
+	trustPath, err := parseTrustPath(statement.X5c)
+	if err != nil {
+		return err
+	}
+	certificate := trustPath[0]
+
+	nonce := sha256.Sum256(signedData(attestation, clientDataHash))
+
+	var extensionNonce []byte
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(oidAppleNonce) {
+			continue
+		}
+
+		var value appleNonceExtension
+		_, err := asn1.Unmarshal(extension.Value, &value)
+		if err != nil {
+			return fmt.Errorf("%w: malformed apple nonce extension: %w", ErrInvalidAttestation, err)
+		}
+		extensionNonce = value.Nonce
+	}
+	if !bytes.Equal(extensionNonce, nonce[:]) {
+		return fmt.Errorf("%w: apple nonce does not match", ErrInvalidAttestation)
+	}
+
+	if !publicKeysEqual(certificate.PublicKey, attestation.PublicKey) {
+		return fmt.Errorf("%w: certificate key does not match the credential key", ErrInvalidAttestation)
+	}
+
+	attestation.Type = AttestationTypeAnonCa
+	attestation.TrustPath = trustPath
+	return nil
+}
@@ -0,0 +1,198 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	AttestationFormatNone    = "none"
+	AttestationFormatPacked  = "packed"
+	AttestationFormatFidoU2f = "fido-u2f"
+	AttestationFormatTpm     = "tpm"
+	AttestationFormatApple   = "apple"
+)
+
+type AttestationType string
+
+const (
+	// AttestationTypeNone means the authenticator did not attest anything.
+	AttestationTypeNone AttestationType = "none"
+	// AttestationTypeSelf means the credential key signed its own
+	// attestation, which proves nothing about the authenticator.
+	AttestationTypeSelf AttestationType = "self"
+	// AttestationTypeBasic means an attestation certificate shared by a
+	// batch of authenticators signed the attestation.
+	AttestationTypeBasic AttestationType = "basic"
+	// AttestationTypeAttCa means an attestation CA issued a certificate for
+	// the attestation key of the authenticator, e.g. for TPMs.
+	AttestationTypeAttCa AttestationType = "attca"
+	// AttestationTypeAnonCa means a CA issued a certificate for the
+	// credential key itself, e.g. for Apple devices.
+	AttestationTypeAnonCa AttestationType = "anonca"
+)
+
+var (
+	oidFidoGenCeAaguid        = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+	oidAppleNonce             = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}
+	oidTcgKpAikCertificate    = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+	oidSubjectAlternativeName = asn1.ObjectIdentifier{2, 5, 29, 17}
+)
+
+// AttestationObject is the CBOR structure returned by
+// navigator.credentials.create().
+type AttestationObject struct {
+	Format   string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+
+// Attestation is the result of a verified attestation statement. The trust
+// path still has to be checked against a TrustStore.
+type Attestation struct {
+	Format             string
+	Type               AttestationType
+	AuthData           *AuthenticatorData
+	PublicKey          crypto.PublicKey
+	PublicKeyAlgorithm int
+	// TrustPath holds the attestation certificate followed by its
+	// intermediates.
+	TrustPath []*x509.Certificate
+}
+
+// Trusted reports whether the attestation can be verified against a trust
+// anchor at all.
+func (a *Attestation) Trusted() bool {
+	return a.Type != AttestationTypeNone && a.Type != AttestationTypeSelf
+}
+
+func ParseAttestationObject(raw []byte) (*AttestationObject, error) {
+	var object AttestationObject
+	err := cbor.Unmarshal(raw, &object)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding attestation object: %w", ErrInvalidAttestation, err)
+	}
+
+	return &object, nil
+}
+
+// Verify parses the authenticator data and verifies the attestation
+// statement over it and the hash of the client data.
+func (o *AttestationObject) Verify(clientDataHash []byte) (*Attestation, error) {
+	authData, err := ParseAuthenticatorData(o.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialPublicKey == nil {
+		return nil, fmt.Errorf("%w: missing attested credential data", ErrInvalidAttestation)
+	}
+
+	publicKey, algorithm, err := ParseCosePublicKey(authData.CredentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(CredentialAlgorithms, algorithm) {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	attestation := &Attestation{
+		Format:             o.Format,
+		AuthData:           authData,
+		PublicKey:          publicKey,
+		PublicKeyAlgorithm: algorithm,
+	}
+
+	switch o.Format {
+	case AttestationFormatNone:
+		attestation.Type = AttestationTypeNone
+
+	case AttestationFormatPacked:
+		err = verifyPacked(o.AttStmt, attestation, clientDataHash)
+
+	case AttestationFormatFidoU2f:
+		err = verifyFidoU2f(o.AttStmt, attestation, clientDataHash)
+
+	case AttestationFormatTpm:
+		err = verifyTpm(o.AttStmt, attestation, clientDataHash)
+
+	case AttestationFormatApple:
+		err = verifyApple(o.AttStmt, attestation, clientDataHash)
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidAttestation, o.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attestation, nil
+}
+
+// parseTrustPath parses the x5c array of an attestation statement.
+func parseTrustPath(x5c [][]byte) ([]*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, fmt.Errorf("%w: empty x5c", ErrInvalidAttestation)
+	}
+
+	certificates := make([]*x509.Certificate, len(x5c))
+	for i, der := range x5c {
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing x5c certificate: %w", ErrInvalidAttestation, err)
+		}
+		certificates[i] = certificate
+	}
+
+	return certificates, nil
+}
+
+// certificateAaguid returns the AAGUID from the id-fido-gen-ce-aaguid
+// extension of an attestation certificate, or nil if it has none.
+func certificateAaguid(certificate *x509.Certificate) ([]byte, error) {
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(oidFidoGenCeAaguid) {
+			continue
+		}
+		if extension.Critical {
+			return nil, fmt.Errorf("%w: aaguid extension must not be critical", ErrInvalidAttestation)
+		}
+
+		var aaguid []byte
+		_, err := asn1.Unmarshal(extension.Value, &aaguid)
+		if err != nil || len(aaguid) != 16 {
+			return nil, fmt.Errorf("%w: malformed aaguid extension", ErrInvalidAttestation)
+		}
+		return aaguid, nil
+	}
+
+	return nil, nil
+}
+
+func verifyCertificateAaguid(certificate *x509.Certificate, attestation *Attestation) error {
+	aaguid, err := certificateAaguid(certificate)
+	if err != nil {
+		return err
+	}
+	if aaguid != nil && !bytes.Equal(aaguid, attestation.AuthData.Aaguid[:]) {
+		return fmt.Errorf("%w: aaguid of the certificate does not match the authenticator data", ErrInvalidAttestation)
+	}
+
+	return nil
+}
+
+func signedData(attestation *Attestation, clientDataHash []byte) []byte {
+	data := make([]byte, 0, len(attestation.AuthData.Raw)+len(clientDataHash))
+	data = append(data, attestation.AuthData.Raw...)
+	return append(data, clientDataHash...)
+}
+
+func publicKeysEqual(a crypto.PublicKey, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,499 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testRpId = "login.example.com"
+
+var testAaguid = uuid.MustParse("2fc0579f-8113-47ea-b116-bb5a8db9202a")
+
+type testCa struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCa(t *testing.T) *testCa {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Attestation Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCa{certificate: certificate, key: key}
+}
+
+// issue creates a leaf certificate for publicKey, the template is completed
+// with serial number and validity.
+func (ca *testCa) issue(t *testing.T, template *x509.Certificate, publicKey crypto.PublicKey) *x509.Certificate {
+	t.Helper()
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.BasicConstraintsValid = true
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, publicKey, ca.key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate
+}
+
+func aaguidExtension(t *testing.T, aaguid uuid.UUID) pkix.Extension {
+	t.Helper()
+
+	value, err := asn1.Marshal(aaguid[:])
+	require.NoError(t, err)
+	return pkix.Extension{Id: oidFidoGenCeAaguid, Value: value}
+}
+
+func packedTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			Country:            []string{"DE"},
+			Organization:       []string{"Keyline Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Keyline Test Authenticator",
+		},
+	}
+}
+
+func coseKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	encoded, err := cbor.Marshal(map[int]any{
+		1:  coseKeyTypeEc2,
+		3:  CoseAlgorithmES256,
+		-1: coseCurveP256,
+		-2: key.X.FillBytes(make([]byte, 32)),
+		-3: key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+	return encoded
+}
+
+func testAuthData(t *testing.T, flags byte, aaguid uuid.UUID, credentialId []byte, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	authData := append([]byte{}, RpIdHash(testRpId)...)
+	authData = append(authData, flags|flagAttestedCredentialData)
+	authData = binary.BigEndian.AppendUint32(authData, 3)
+	authData = append(authData, aaguid[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(credentialId)))
+	authData = append(authData, credentialId...)
+	return append(authData, coseKey(t, key)...)
+}
+
+func signEs256(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+
+	hash := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	return signature
+}
+
+func attestationObject(t *testing.T, format string, statement map[string]any, authData []byte) *AttestationObject {
+	t.Helper()
+
+	encoded, err := cbor.Marshal(map[string]any{
+		"fmt":      format,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	require.NoError(t, err)
+
+	object, err := ParseAttestationObject(encoded)
+	require.NoError(t, err)
+	return object
+}
+
+type attestationFixture struct {
+	credentialKey  *ecdsa.PrivateKey
+	credentialId   []byte
+	authData       []byte
+	clientDataHash []byte
+}
+
+func newAttestationFixture(t *testing.T, aaguid uuid.UUID) *attestationFixture {
+	t.Helper()
+
+	credentialKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialId := []byte("credential-id-1234")
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.create"}`))
+
+	return &attestationFixture{
+		credentialKey:  credentialKey,
+		credentialId:   credentialId,
+		authData:       testAuthData(t, flagUserPresent|flagUserVerified, aaguid, credentialId, credentialKey),
+		clientDataHash: clientDataHash[:],
+	}
+}
+
+func (f *attestationFixture) signedData() []byte {
+	return append(append([]byte{}, f.authData...), f.clientDataHash...)
+}
+
+func TestAttestationNone(t *testing.T) {
+	fixture := newAttestationFixture(t, uuid.Nil)
+	object := attestationObject(t, AttestationFormatNone, map[string]any{}, fixture.authData)
+
+	attestation, err := object.Verify(fixture.clientDataHash)
+	require.NoError(t, err)
+
+	require.Equal(t, AttestationTypeNone, attestation.Type)
+	require.False(t, attestation.Trusted())
+	require.Equal(t, fixture.credentialId, attestation.AuthData.CredentialId)
+	require.True(t, attestation.AuthData.UserPresent())
+	require.True(t, attestation.AuthData.UserVerified())
+	require.Equal(t, uint32(3), attestation.AuthData.SignCount)
+	require.True(t, fixture.credentialKey.PublicKey.Equal(attestation.PublicKey))
+	require.Equal(t, CoseAlgorithmES256, attestation.PublicKeyAlgorithm)
+}
+
+func TestAttestationPackedSelf(t *testing.T) {
+	fixture := newAttestationFixture(t, testAaguid)
+	object := attestationObject(t, AttestationFormatPacked, map[string]any{
+		"alg": CoseAlgorithmES256,
+		"sig": signEs256(t, fixture.credentialKey, fixture.signedData()),
+	}, fixture.authData)
+
+	attestation, err := object.Verify(fixture.clientDataHash)
+	require.NoError(t, err)
+	require.Equal(t, AttestationTypeSelf, attestation.Type)
+
+	err = newTestTrustStore(t, nil).Verify(attestation, time.Now())
+	require.ErrorIs(t, err, ErrUntrustedAttestation)
+}
+
+func TestAttestationPacked(t *testing.T) {
+	ca := newTestCa(t)
+	fixture := newAttestationFixture(t, testAaguid)
+
+	attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := packedTemplate()
+	template.ExtraExtensions = []pkix.Extension{aaguidExtension(t, testAaguid)}
+	certificate := ca.issue(t, template, &attestationKey.PublicKey)
+
+	statement := map[string]any{
+		"alg": CoseAlgorithmES256,
+		"sig": signEs256(t, attestationKey, fixture.signedData()),
+		"x5c": [][]byte{certificate.Raw},
+	}
+
+	t.Run("trusted", func(t *testing.T) {
+		object := attestationObject(t, AttestationFormatPacked, statement, fixture.authData)
+		attestation, err := object.Verify(fixture.clientDataHash)
+		require.NoError(t, err)
+		require.Equal(t, AttestationTypeBasic, attestation.Type)
+		require.Equal(t, testAaguid, attestation.AuthData.Aaguid)
+
+		err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+			testMetadataEntry(testAaguid.String(), nil, ca),
+		}}).Verify(attestation, time.Now())
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown authenticator", func(t *testing.T) {
+		object := attestationObject(t, AttestationFormatPacked, statement, fixture.authData)
+		attestation, err := object.Verify(fixture.clientDataHash)
+		require.NoError(t, err)
+
+		err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+			testMetadataEntry(uuid.NewString(), nil, ca),
+		}}).Verify(attestation, time.Now())
+		require.ErrorIs(t, err, ErrUntrustedAttestation)
+	})
+
+	t.Run("revoked authenticator", func(t *testing.T) {
+		object := attestationObject(t, AttestationFormatPacked, statement, fixture.authData)
+		attestation, err := object.Verify(fixture.clientDataHash)
+		require.NoError(t, err)
+
+		entry := testMetadataEntry(testAaguid.String(), nil, ca)
+		entry.StatusReports = []MetadataStatusReport{{Status: "FIDO_CERTIFIED"}, {Status: "REVOKED"}}
+		err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{entry}}).Verify(attestation, time.Now())
+		require.ErrorIs(t, err, ErrUntrustedAttestation)
+	})
+
+	t.Run("other root", func(t *testing.T) {
+		object := attestationObject(t, AttestationFormatPacked, statement, fixture.authData)
+		attestation, err := object.Verify(fixture.clientDataHash)
+		require.NoError(t, err)
+
+		err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+			testMetadataEntry(testAaguid.String(), nil, newTestCa(t)),
+		}}).Verify(attestation, time.Now())
+		require.ErrorIs(t, err, ErrUntrustedAttestation)
+	})
+
+	t.Run("aaguid mismatch", func(t *testing.T) {
+		otherFixture := newAttestationFixture(t, uuid.New())
+		object := attestationObject(t, AttestationFormatPacked, map[string]any{
+			"alg": CoseAlgorithmES256,
+			"sig": signEs256(t, attestationKey, otherFixture.signedData()),
+			"x5c": [][]byte{certificate.Raw},
+		}, otherFixture.authData)
+
+		_, err := object.Verify(otherFixture.clientDataHash)
+		require.ErrorIs(t, err, ErrInvalidAttestation)
+	})
+
+	t.Run("tampered client data", func(t *testing.T) {
+		object := attestationObject(t, AttestationFormatPacked, statement, fixture.authData)
+		tampered := sha256.Sum256([]byte("tampered"))
+
+		_, err := object.Verify(tampered[:])
+		require.ErrorIs(t, err, ErrInvalidAttestation)
+	})
+}
+
+func TestAttestationFidoU2f(t *testing.T) {
+	ca := newTestCa(t)
+	fixture := newAttestationFixture(t, uuid.Nil)
+
+	attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certificate := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "U2F Test"}}, &attestationKey.PublicKey)
+
+	data := []byte{0x00}
+	data = append(data, RpIdHash(testRpId)...)
+	data = append(data, fixture.clientDataHash...)
+	data = append(data, fixture.credentialId...)
+	data = append(data, 0x04)
+	data = append(data, fixture.credentialKey.X.FillBytes(make([]byte, 32))...)
+	data = append(data, fixture.credentialKey.Y.FillBytes(make([]byte, 32))...)
+
+	object := attestationObject(t, AttestationFormatFidoU2f, map[string]any{
+		"sig": signEs256(t, attestationKey, data),
+		"x5c": [][]byte{certificate.Raw},
+	}, fixture.authData)
+
+	attestation, err := object.Verify(fixture.clientDataHash)
+	require.NoError(t, err)
+	require.Equal(t, AttestationTypeBasic, attestation.Type)
+
+	keyIdentifier, err := subjectKeyIdentifier(certificate)
+	require.NoError(t, err)
+
+	err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+		testMetadataEntry("", []string{keyIdentifier}, ca),
+	}}).Verify(attestation, time.Now())
+	require.NoError(t, err)
+
+	err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+		testMetadataEntry(uuid.Nil.String(), nil, ca),
+	}}).Verify(attestation, time.Now())
+	require.ErrorIs(t, err, ErrUntrustedAttestation)
+}
+
+func TestAttestationApple(t *testing.T) {
+	ca := newTestCa(t)
+	fixture := newAttestationFixture(t, uuid.Nil)
+
+	nonce := sha256.Sum256(fixture.signedData())
+	value, err := asn1.Marshal(appleNonceExtension{Nonce: nonce[:]})
+	require.NoError(t, err)
+
+	certificate := ca.issue(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Apple Test"},
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleNonce, Value: value}},
+	}, &fixture.credentialKey.PublicKey)
+
+	object := attestationObject(t, AttestationFormatApple, map[string]any{
+		"x5c": [][]byte{certificate.Raw},
+	}, fixture.authData)
+
+	attestation, err := object.Verify(fixture.clientDataHash)
+	require.NoError(t, err)
+	require.Equal(t, AttestationTypeAnonCa, attestation.Type)
+
+	trustStore, err := NewTrustStore(nil, []*x509.Certificate{ca.certificate})
+	require.NoError(t, err)
+	require.NoError(t, trustStore.Verify(attestation, time.Now()))
+
+	err = newTestTrustStore(t, nil).Verify(attestation, time.Now())
+	require.ErrorIs(t, err, ErrUntrustedAttestation)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherCertificate := ca.issue(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Apple Test"},
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleNonce, Value: value}},
+	}, &otherKey.PublicKey)
+
+	object = attestationObject(t, AttestationFormatApple, map[string]any{
+		"x5c": [][]byte{otherCertificate.Raw},
+	}, fixture.authData)
+	_, err = object.Verify(fixture.clientDataHash)
+	require.ErrorIs(t, err, ErrInvalidAttestation)
+}
+
+func tpmPubArea(key *ecdsa.PrivateKey) []byte {
+	pubArea := binary.BigEndian.AppendUint16(nil, tpmAlgEcc)
+	pubArea = binary.BigEndian.AppendUint16(pubArea, tpmAlgSha256)
+	pubArea = binary.BigEndian.AppendUint32(pubArea, 0x00060472) // objectAttributes
+	pubArea = binary.BigEndian.AppendUint16(pubArea, 0)          // authPolicy
+	pubArea = binary.BigEndian.AppendUint16(pubArea, tpmAlgNull) // symmetric
+	pubArea = binary.BigEndian.AppendUint16(pubArea, tpmAlgNull) // scheme
+	pubArea = binary.BigEndian.AppendUint16(pubArea, tpmEccNistP256)
+	pubArea = binary.BigEndian.AppendUint16(pubArea, tpmAlgNull) // kdf
+	pubArea = binary.BigEndian.AppendUint16(pubArea, 32)
+	pubArea = append(pubArea, key.X.FillBytes(make([]byte, 32))...)
+	pubArea = binary.BigEndian.AppendUint16(pubArea, 32)
+	return append(pubArea, key.Y.FillBytes(make([]byte, 32))...)
+}
+
+func tpmCertInfo(extraData []byte, pubArea []byte) []byte {
+	nameHash := sha256.Sum256(pubArea)
+	name := binary.BigEndian.AppendUint16(nil, tpmAlgSha256)
+	name = append(name, nameHash[:]...)
+
+	certInfo := binary.BigEndian.AppendUint32(nil, tpmGeneratedValue)
+	certInfo = binary.BigEndian.AppendUint16(certInfo, tpmStAttestCertify)
+	certInfo = binary.BigEndian.AppendUint16(certInfo, 0) // qualifiedSigner
+	certInfo = binary.BigEndian.AppendUint16(certInfo, uint16(len(extraData)))
+	certInfo = append(certInfo, extraData...)
+	certInfo = append(certInfo, make([]byte, 17)...) // clockInfo
+	certInfo = append(certInfo, make([]byte, 8)...)  // firmwareVersion
+	certInfo = binary.BigEndian.AppendUint16(certInfo, uint16(len(name)))
+	certInfo = append(certInfo, name...)
+	return binary.BigEndian.AppendUint16(certInfo, 0) // qualifiedName
+}
+
+func TestAttestationTpm(t *testing.T) {
+	ca := newTestCa(t)
+	fixture := newAttestationFixture(t, testAaguid)
+
+	aikKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// TPM vendors identify the TPM in a directoryName of the SAN
+	san, err := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: []byte{0x30, 0x00}}})
+	require.NoError(t, err)
+	aikCertificate := ca.issue(t, &x509.Certificate{
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{oidTcgKpAikCertificate},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidSubjectAlternativeName, Critical: true, Value: san},
+			aaguidExtension(t, testAaguid),
+		},
+	}, &aikKey.PublicKey)
+
+	pubArea := tpmPubArea(fixture.credentialKey)
+	extraData := sha256.Sum256(fixture.signedData())
+	certInfo := tpmCertInfo(extraData[:], pubArea)
+
+	certInfoHash := sha256.Sum256(certInfo)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, aikKey, crypto.SHA256, certInfoHash[:])
+	require.NoError(t, err)
+
+	statement := map[string]any{
+		"ver":      "2.0",
+		"alg":      CoseAlgorithmRS256,
+		"x5c":      [][]byte{aikCertificate.Raw},
+		"sig":      signature,
+		"certInfo": certInfo,
+		"pubArea":  pubArea,
+	}
+
+	object := attestationObject(t, AttestationFormatTpm, statement, fixture.authData)
+	attestation, err := object.Verify(fixture.clientDataHash)
+	require.NoError(t, err)
+	require.Equal(t, AttestationTypeAttCa, attestation.Type)
+
+	err = newTestTrustStore(t, &MetadataBlob{Entries: []MetadataEntry{
+		testMetadataEntry(testAaguid.String(), nil, ca),
+	}}).Verify(attestation, time.Now())
+	require.NoError(t, err)
+
+	t.Run("pubArea of another key", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		tampered := map[string]any{}
+		for k, v := range statement {
+			tampered[k] = v
+		}
+		tampered["pubArea"] = tpmPubArea(otherKey)
+
+		object := attestationObject(t, AttestationFormatTpm, tampered, fixture.authData)
+		_, err = object.Verify(fixture.clientDataHash)
+		require.ErrorIs(t, err, ErrInvalidAttestation)
+	})
+
+	t.Run("certInfo of other data", func(t *testing.T) {
+		otherExtraData := sha256.Sum256([]byte("other"))
+		otherCertInfo := tpmCertInfo(otherExtraData[:], pubArea)
+		otherHash := sha256.Sum256(otherCertInfo)
+		otherSignature, err := rsa.SignPKCS1v15(rand.Reader, aikKey, crypto.SHA256, otherHash[:])
+		require.NoError(t, err)
+
+		tampered := map[string]any{}
+		for k, v := range statement {
+			tampered[k] = v
+		}
+		tampered["certInfo"] = otherCertInfo
+		tampered["sig"] = otherSignature
+
+		object := attestationObject(t, AttestationFormatTpm, tampered, fixture.authData)
+		_, err = object.Verify(fixture.clientDataHash)
+		require.ErrorIs(t, err, ErrInvalidAttestation)
+	})
+}
+
+func TestAttestationRejectsUnsupportedFormat(t *testing.T) {
+	fixture := newAttestationFixture(t, uuid.Nil)
+	object := attestationObject(t, "android-key", map[string]any{}, fixture.authData)
+
+	_, err := object.Verify(fixture.clientDataHash)
+	require.ErrorIs(t, err, ErrInvalidAttestation)
+}
+
+func TestClientDataVerify(t *testing.T) {
+	challenge := []byte("challenge-bytes")
+	clientData := &ClientData{
+		Type:      "webauthn.create",
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    "https://login.example.com",
+	}
+
+	require.NoError(t, clientData.Verify("webauthn.create", challenge, "https://login.example.com"))
+	require.Error(t, clientData.Verify("webauthn.get", challenge, "https://login.example.com"))
+	require.Error(t, clientData.Verify("webauthn.create", []byte("other"), "https://login.example.com"))
+	require.Error(t, clientData.Verify("webauthn.create", challenge, "https://evil.example.com"))
+}
@@ -0,0 +1,64 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type fidoU2fStatement struct {
+	Sig []byte   `cbor:"sig"`
+	X5c [][]byte `cbor:"x5c"`
+}
+
+// verifyFidoU2f verifies the attestation of a legacy U2F authenticator, see
+// https://www.w3.org/TR/webauthn-3/#sctn-fido-u2f-attestation.
+func verifyFidoU2f(raw cbor.RawMessage, attestation *Attestation, clientDataHash []byte) error {
+	var statement fidoU2fStatement
+	err := cbor.Unmarshal(raw, &statement)
+	if err != nil {
+		return fmt.Errorf("%w: decoding fido-u2f statement: %w", ErrInvalidAttestation, err)
+	}
+
+	if len(statement.X5c) != 1 {
+		return fmt.Errorf("%w: fido-u2f requires exactly one certificate", ErrInvalidAttestation)
+	}
+	trustPath, err := parseTrustPath(statement.X5c)
+	if err != nil {
+		return err
+	}
+	certificate := trustPath[0]
+
+	certificateKey, ok := certificate.PublicKey.(*ecdsa.PublicKey)
+	if !ok || certificateKey.Curve != elliptic.P256() {
+		return fmt.Errorf("%w: fido-u2f certificate key must be P-256", ErrInvalidAttestation)
+	}
+
+	credentialKey, ok := attestation.PublicKey.(*ecdsa.PublicKey)
+	if !ok || credentialKey.Curve != elliptic.P256() {
+		return fmt.Errorf("%w: fido-u2f credential key must be P-256", ErrInvalidAttestation)
+	}
+
+	// the U2F raw message format: a reserved byte, the application and
+	// challenge parameters, the key handle and the uncompressed point
+	authData := attestation.AuthData
+	data := make([]byte, 0, 1+32+len(clientDataHash)+len(authData.CredentialId)+65)
+	data = append(data, 0x00)
+	data = append(data, authData.RpIdHash...)
+	data = append(data, clientDataHash...)
+	data = append(data, authData.CredentialId...)
+	data = append(data, 0x04)
+	data = append(data, credentialKey.X.FillBytes(make([]byte, 32))...)
+	data = append(data, credentialKey.Y.FillBytes(make([]byte, 32))...)
+
+	err = VerifySignature(certificateKey, CoseAlgorithmES256, data, statement.Sig)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAttestation, err)
+	}
+
+	attestation.Type = AttestationTypeBasic
+	attestation.TrustPath = trustPath
+	return nil
+}
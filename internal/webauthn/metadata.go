@@ -0,0 +1,368 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var ErrUntrustedAttestation = fmt.Errorf("attestation is not trusted: %w", utils.ErrHttpBadRequest)
+
+// compromisedStatuses are the authenticator statuses of the metadata
+// service that make an authenticator unfit for registration.
+var compromisedStatuses = []string{
+	"REVOKED",
+	"USER_VERIFICATION_BYPASS",
+	"ATTESTATION_KEY_COMPROMISE",
+	"USER_KEY_REMOTE_COMPROMISE",
+	"USER_KEY_PHYSICAL_COMPROMISE",
+}
+
+// MetadataBlob is the payload of a FIDO metadata service BLOB, see
+// https://fidoalliance.org/specs/mds/fido-metadata-service-v3.0-ps-20210518.html.
+// Only the parts needed to verify attestations are decoded.
+type MetadataBlob struct {
+	LegalHeader string          `json:"legalHeader"`
+	No          int             `json:"no"`
+	NextUpdate  string          `json:"nextUpdate"`
+	Entries     []MetadataEntry `json:"entries"`
+}
+
+type MetadataEntry struct {
+	Aaguid                               string                 `json:"aaguid"`
+	AttestationCertificateKeyIdentifiers []string               `json:"attestationCertificateKeyIdentifiers"`
+	MetadataStatement                    MetadataStatement      `json:"metadataStatement"`
+	StatusReports                        []MetadataStatusReport `json:"statusReports"`
+}
+
+type MetadataStatement struct {
+	Description string `json:"description"`
+	// AttestationRootCertificates are base64 encoded DER certificates.
+	AttestationRootCertificates []string `json:"attestationRootCertificates"`
+}
+
+type MetadataStatusReport struct {
+	Status        string `json:"status"`
+	EffectiveDate string `json:"effectiveDate"`
+}
+
+// ParseMetadataBlob decodes a metadata BLOB. The BLOB is a JWS whose x5c
+// header has to chain to root, unless root is nil.
+func ParseMetadataBlob(blob []byte, root *x509.Certificate, now time.Time) (*MetadataBlob, error) {
+	parts := strings.Split(string(bytes.TrimSpace(blob)), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("metadata blob is not a compact JWS")
+	}
+
+	if root != nil {
+		err := verifyMetadataBlobSignature(parts, root, now)
+		if err != nil {
+			return nil, fmt.Errorf("verifying metadata blob: %w", err)
+		}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata blob payload: %w", err)
+	}
+
+	var metadata MetadataBlob
+	err = json.Unmarshal(payload, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata blob payload: %w", err)
+	}
+
+	return &metadata, nil
+}
+
+func verifyMetadataBlobSignature(parts []string, root *x509.Certificate, now time.Time) error {
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("decoding header: %w", err)
+	}
+
+	var header struct {
+		Alg string   `json:"alg"`
+		X5c []string `json:"x5c"`
+	}
+	err = json.Unmarshal(rawHeader, &header)
+	if err != nil {
+		return fmt.Errorf("decoding header: %w", err)
+	}
+	if len(header.X5c) == 0 {
+		return fmt.Errorf("header has no x5c")
+	}
+
+	certificates := make([]*x509.Certificate, len(header.X5c))
+	for i, encoded := range header.X5c {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decoding x5c: %w", err)
+		}
+		certificates[i], err = x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("parsing x5c: %w", err)
+		}
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err = certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("verifying x5c: %w", err)
+	}
+
+	method := jwt.GetSigningMethod(header.Alg)
+	if method == nil {
+		return fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	return method.Verify(parts[0]+"."+parts[1], signature, certificates[0].PublicKey)
+}
+
+// TrustStore verifies the trust path of an attestation against the trust
+// anchors of the authenticator that made it.
+type TrustStore interface {
+	Verify(attestation *Attestation, now time.Time) error
+}
+
+type trustAnchor struct {
+	description string
+	roots       *x509.CertPool
+	status      string
+}
+
+type metadataTrustStore struct {
+	byAaguid        map[uuid.UUID]*trustAnchor
+	byKeyIdentifier map[string]*trustAnchor
+	apple           *trustAnchor
+}
+
+// NewTrustStore creates a trust store for the authenticators of the given
+// metadata. Apple does not publish its anonymous attestation root in the
+// metadata service, so it is passed separately. Both may be empty, the
+// store then trusts no attestation of the respective kind.
+func NewTrustStore(metadata *MetadataBlob, appleRoots []*x509.Certificate) (TrustStore, error) {
+	store := &metadataTrustStore{
+		byAaguid:        make(map[uuid.UUID]*trustAnchor),
+		byKeyIdentifier: make(map[string]*trustAnchor),
+	}
+
+	if len(appleRoots) > 0 {
+		store.apple = &trustAnchor{
+			description: "Apple",
+			roots:       x509.NewCertPool(),
+		}
+		for _, root := range appleRoots {
+			store.apple.roots.AddCert(root)
+		}
+	}
+
+	if metadata == nil {
+		return store, nil
+	}
+
+	for _, entry := range metadata.Entries {
+		anchor := &trustAnchor{
+			description: entry.MetadataStatement.Description,
+			roots:       x509.NewCertPool(),
+		}
+		for _, encoded := range entry.MetadataStatement.AttestationRootCertificates {
+			der, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("decoding root certificate of %q: %w", anchor.description, err)
+			}
+			root, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("parsing root certificate of %q: %w", anchor.description, err)
+			}
+			anchor.roots.AddCert(root)
+		}
+		for _, report := range entry.StatusReports {
+			if slices.Contains(compromisedStatuses, report.Status) {
+				anchor.status = report.Status
+			}
+		}
+
+		if entry.Aaguid != "" {
+			aaguid, err := uuid.Parse(entry.Aaguid)
+			if err != nil {
+				return nil, fmt.Errorf("parsing aaguid of %q: %w", anchor.description, err)
+			}
+			store.byAaguid[aaguid] = anchor
+		}
+		for _, keyIdentifier := range entry.AttestationCertificateKeyIdentifiers {
+			store.byKeyIdentifier[strings.ToLower(keyIdentifier)] = anchor
+		}
+	}
+
+	return store, nil
+}
+
+// LoadTrustStore reads the metadata BLOB and the certificates from disk.
+// All paths are optional.
+func LoadTrustStore(metadataBlobFile string, metadataRootFile string, appleRootFile string, now time.Time) (TrustStore, error) {
+	var metadataRoot *x509.Certificate
+	if metadataRootFile != "" {
+		certificates, err := readCertificates(metadataRootFile)
+		if err != nil {
+			return nil, err
+		}
+		metadataRoot = certificates[0]
+	}
+
+	var metadata *MetadataBlob
+	if metadataBlobFile != "" {
+		blob, err := os.ReadFile(metadataBlobFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading metadata blob: %w", err)
+		}
+		metadata, err = ParseMetadataBlob(blob, metadataRoot, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var appleRoots []*x509.Certificate
+	if appleRootFile != "" {
+		var err error
+		appleRoots, err = readCertificates(appleRootFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewTrustStore(metadata, appleRoots)
+}
+
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading certificates: %w", err)
+	}
+
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate in %s: %w", path, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return certificates, nil
+}
+
+func (s *metadataTrustStore) Verify(attestation *Attestation, now time.Time) error {
+	if !attestation.Trusted() || len(attestation.TrustPath) == 0 {
+		return fmt.Errorf("%w: %s attestation has no trust path", ErrUntrustedAttestation, attestation.Type)
+	}
+	certificate := attestation.TrustPath[0]
+
+	var anchor *trustAnchor
+	switch attestation.Format {
+	case AttestationFormatApple:
+		anchor = s.apple
+
+	case AttestationFormatFidoU2f:
+		keyIdentifier, err := subjectKeyIdentifier(certificate)
+		if err != nil {
+			return err
+		}
+		anchor = s.byKeyIdentifier[keyIdentifier]
+
+	default:
+		anchor = s.byAaguid[attestation.AuthData.Aaguid]
+	}
+	if anchor == nil {
+		return fmt.Errorf("%w: authenticator %s is unknown", ErrUntrustedAttestation, attestation.AuthData.Aaguid)
+	}
+	if anchor.status != "" {
+		return fmt.Errorf("%w: authenticator %q is reported as %s", ErrUntrustedAttestation, anchor.description, anchor.status)
+	}
+
+	// TPM vendors identify the TPM in a critical subject alternative name
+	// holding only a directory name, which the x509 package does not handle
+	if attestation.Format == AttestationFormatTpm {
+		aikCertificate := *certificate
+		aikCertificate.UnhandledCriticalExtensions = slices.DeleteFunc(
+			slices.Clone(certificate.UnhandledCriticalExtensions),
+			oidSubjectAlternativeName.Equal,
+		)
+		certificate = &aikCertificate
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range attestation.TrustPath[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         anchor.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUntrustedAttestation, err)
+	}
+
+	return nil
+}
+
+// subjectKeyIdentifier computes the key identifier the metadata service
+// uses for U2F authenticators, the SHA-1 hash of the public key.
+func subjectKeyIdentifier(certificate *x509.Certificate) (string, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err := asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &publicKeyInfo)
+	if err != nil {
+		return "", fmt.Errorf("%w: parsing public key: %w", ErrInvalidAttestation, err)
+	}
+
+	hash := sha1.Sum(publicKeyInfo.PublicKey.Bytes)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,125 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTrustStore(t *testing.T, metadata *MetadataBlob) TrustStore {
+	t.Helper()
+
+	trustStore, err := NewTrustStore(metadata, nil)
+	require.NoError(t, err)
+	return trustStore
+}
+
+func testMetadataEntry(aaguid string, keyIdentifiers []string, ca *testCa) MetadataEntry {
+	return MetadataEntry{
+		Aaguid:                               aaguid,
+		AttestationCertificateKeyIdentifiers: keyIdentifiers,
+		MetadataStatement: MetadataStatement{
+			Description:                 "Test Authenticator",
+			AttestationRootCertificates: []string{base64.StdEncoding.EncodeToString(ca.certificate.Raw)},
+		},
+		StatusReports: []MetadataStatusReport{{Status: "FIDO_CERTIFIED_L1"}},
+	}
+}
+
+// signTestMetadataBlob signs a BLOB the way the metadata service does, with
+// a certificate issued by the root in the x5c header.
+func signTestMetadataBlob(t *testing.T, ca *testCa, entries []MetadataEntry) []byte {
+	t.Helper()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certificate := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "Test MDS Signer"}}, &signingKey.PublicKey)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"legalHeader": "test",
+		"no":          1,
+		"nextUpdate":  "2030-01-01",
+		"entries":     entries,
+	})
+	token.Header["x5c"] = []string{base64.StdEncoding.EncodeToString(certificate.Raw)}
+
+	blob, err := token.SignedString(signingKey)
+	require.NoError(t, err)
+	return []byte(blob)
+}
+
+func TestParseMetadataBlob(t *testing.T) {
+	mdsCa := newTestCa(t)
+	authenticatorCa := newTestCa(t)
+	blob := signTestMetadataBlob(t, mdsCa, []MetadataEntry{
+		testMetadataEntry(testAaguid.String(), nil, authenticatorCa),
+	})
+
+	t.Run("verified", func(t *testing.T) {
+		metadata, err := ParseMetadataBlob(blob, mdsCa.certificate, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, 1, metadata.No)
+		require.Len(t, metadata.Entries, 1)
+		require.Equal(t, testAaguid.String(), metadata.Entries[0].Aaguid)
+	})
+
+	t.Run("other root", func(t *testing.T) {
+		_, err := ParseMetadataBlob(blob, newTestCa(t).certificate, time.Now())
+		require.Error(t, err)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		parts := strings.Split(string(blob), ".")
+		otherParts := strings.Split(string(signTestMetadataBlob(t, mdsCa, nil)), ".")
+		tampered := parts[0] + "." + otherParts[1] + "." + parts[2]
+
+		_, err := ParseMetadataBlob([]byte(tampered), mdsCa.certificate, time.Now())
+		require.Error(t, err)
+	})
+
+	t.Run("unverified", func(t *testing.T) {
+		metadata, err := ParseMetadataBlob(blob, nil, time.Now())
+		require.NoError(t, err)
+		require.Len(t, metadata.Entries, 1)
+	})
+}
+
+func TestLoadTrustStore(t *testing.T) {
+	mdsCa := newTestCa(t)
+	authenticatorCa := newTestCa(t)
+	dir := t.TempDir()
+
+	blobFile := filepath.Join(dir, "blob.jwt")
+	require.NoError(t, os.WriteFile(blobFile, signTestMetadataBlob(t, mdsCa, []MetadataEntry{
+		testMetadataEntry(testAaguid.String(), nil, authenticatorCa),
+	}), 0o600))
+
+	rootFile := filepath.Join(dir, "root.pem")
+	require.NoError(t, os.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: mdsCa.certificate.Raw,
+	}), 0o600))
+
+	trustStore, err := LoadTrustStore(blobFile, rootFile, "", time.Now())
+	require.NoError(t, err)
+	require.Contains(t, trustStore.(*metadataTrustStore).byAaguid, testAaguid)
+
+	_, err = LoadTrustStore(blobFile, filepath.Join(dir, "missing.pem"), "", time.Now())
+	require.Error(t, err)
+
+	trustStore, err = LoadTrustStore("", "", "", time.Now())
+	require.NoError(t, err)
+	require.Empty(t, trustStore.(*metadataTrustStore).byAaguid)
+}
@@ -0,0 +1,72 @@
+package webauthn
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type packedStatement struct {
+	Alg int      `cbor:"alg"`
+	Sig []byte   `cbor:"sig"`
+	X5c [][]byte `cbor:"x5c"`
+}
+
+// verifyPacked verifies a packed attestation statement, see
+// https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation.
+func verifyPacked(raw cbor.RawMessage, attestation *Attestation, clientDataHash []byte) error {
+	var statement packedStatement
+	err := cbor.Unmarshal(raw, &statement)
+	if err != nil {
+		return fmt.Errorf("%w: decoding packed statement: %w", ErrInvalidAttestation, err)
+	}
+
+	data := signedData(attestation, clientDataHash)
+
+	if statement.X5c == nil {
+		// self attestation, the credential key signed the statement
+		if statement.Alg != attestation.PublicKeyAlgorithm {
+			return fmt.Errorf("%w: algorithm does not match the credential key", ErrInvalidAttestation)
+		}
+		err = VerifySignature(attestation.PublicKey, statement.Alg, data, statement.Sig)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidAttestation, err)
+		}
+
+		attestation.Type = AttestationTypeSelf
+		return nil
+	}
+
+	trustPath, err := parseTrustPath(statement.X5c)
+	if err != nil {
+		return err
+	}
+	certificate := trustPath[0]
+
+	err = VerifySignature(certificate.PublicKey, statement.Alg, data, statement.Sig)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAttestation, err)
+	}
+
+	if certificate.Version != 3 {
+		return fmt.Errorf("%w: attestation certificate must be version 3", ErrInvalidAttestation)
+	}
+	subject := certificate.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" ||
+		!slices.Contains(subject.OrganizationalUnit, "Authenticator Attestation") {
+		return fmt.Errorf("%w: attestation certificate subject is incomplete", ErrInvalidAttestation)
+	}
+	if certificate.IsCA {
+		return fmt.Errorf("%w: attestation certificate must not be a CA", ErrInvalidAttestation)
+	}
+
+	err = verifyCertificateAaguid(certificate, attestation)
+	if err != nil {
+		return err
+	}
+
+	attestation.Type = AttestationTypeBasic
+	attestation.TrustPath = trustPath
+	return nil
+}
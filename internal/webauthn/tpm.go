@@ -0,0 +1,315 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	tpmGeneratedValue  = 0xff544347
+	tpmStAttestCertify = 0x8017
+
+	tpmAlgRsa    = 0x0001
+	tpmAlgSha1   = 0x0004
+	tpmAlgSha256 = 0x000b
+	tpmAlgSha384 = 0x000c
+	tpmAlgSha512 = 0x000d
+	tpmAlgNull   = 0x0010
+	tpmAlgEcc    = 0x0023
+
+	tpmEccNistP256 = 0x0003
+	tpmEccNistP384 = 0x0004
+	tpmEccNistP521 = 0x0005
+)
+
+var errTpmTruncated = errors.New("truncated TPM structure")
+
+type tpmStatement struct {
+	Ver      string   `cbor:"ver"`
+	Alg      int      `cbor:"alg"`
+	X5c      [][]byte `cbor:"x5c"`
+	Sig      []byte   `cbor:"sig"`
+	CertInfo []byte   `cbor:"certInfo"`
+	PubArea  []byte   `cbor:"pubArea"`
+}
+
+// verifyTpm verifies a TPM attestation, see
+// https://www.w3.org/TR/webauthn-3/#sctn-tpm-attestation. The TPM certifies
+// the credential key with its attestation identity key, whose certificate
+// is issued by the CA of the TPM vendor.
+func verifyTpm(raw cbor.RawMessage, attestation *Attestation, clientDataHash []byte) error {
+	var statement tpmStatement
+	err := cbor.Unmarshal(raw, &statement)
+	if err != nil {
+		return fmt.Errorf("%w: decoding tpm statement: %w", ErrInvalidAttestation, err)
+	}
+
+	if statement.Ver != "2.0" {
+		return fmt.Errorf("%w: unsupported tpm version %q", ErrInvalidAttestation, statement.Ver)
+	}
+
+	pubArea, err := parseTpmPublic(statement.PubArea)
+	if err != nil {
+		return fmt.Errorf("%w: pubArea: %w", ErrInvalidAttestation, err)
+	}
+	if !publicKeysEqual(pubArea.publicKey, attestation.PublicKey) {
+		return fmt.Errorf("%w: pubArea does not match the credential key", ErrInvalidAttestation)
+	}
+
+	certInfo, err := parseTpmAttest(statement.CertInfo)
+	if err != nil {
+		return fmt.Errorf("%w: certInfo: %w", ErrInvalidAttestation, err)
+	}
+	if certInfo.magic != tpmGeneratedValue || certInfo.attestType != tpmStAttestCertify {
+		return fmt.Errorf("%w: certInfo is not a certification", ErrInvalidAttestation)
+	}
+
+	hash, err := coseAlgorithmHash(statement.Alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(signedData(attestation, clientDataHash))
+	if !bytes.Equal(certInfo.extraData, h.Sum(nil)) {
+		return fmt.Errorf("%w: certInfo does not cover the authenticator data", ErrInvalidAttestation)
+	}
+
+	nameHash, err := tpmAlgorithmHash(pubArea.nameAlg)
+	if err != nil {
+		return err
+	}
+	h = nameHash.New()
+	h.Write(statement.PubArea)
+	name := binary.BigEndian.AppendUint16(nil, pubArea.nameAlg)
+	name = append(name, h.Sum(nil)...)
+	if !bytes.Equal(certInfo.attestedName, name) {
+		return fmt.Errorf("%w: certInfo does not certify pubArea", ErrInvalidAttestation)
+	}
+
+	trustPath, err := parseTrustPath(statement.X5c)
+	if err != nil {
+		return err
+	}
+	certificate := trustPath[0]
+
+	err = VerifySignature(certificate.PublicKey, statement.Alg, statement.CertInfo, statement.Sig)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAttestation, err)
+	}
+
+	if certificate.Version != 3 {
+		return fmt.Errorf("%w: aik certificate must be version 3", ErrInvalidAttestation)
+	}
+	if len(certificate.Subject.Names) != 0 {
+		return fmt.Errorf("%w: aik certificate subject must be empty", ErrInvalidAttestation)
+	}
+	if !slices.ContainsFunc(certificate.Extensions, func(e pkix.Extension) bool { return e.Id.Equal(oidSubjectAlternativeName) }) {
+		return fmt.Errorf("%w: aik certificate is missing the subject alternative name", ErrInvalidAttestation)
+	}
+	if !slices.ContainsFunc(certificate.UnknownExtKeyUsage, oidTcgKpAikCertificate.Equal) {
+		return fmt.Errorf("%w: aik certificate is missing the tcg-kp-AIKCertificate usage", ErrInvalidAttestation)
+	}
+	if certificate.IsCA {
+		return fmt.Errorf("%w: aik certificate must not be a CA", ErrInvalidAttestation)
+	}
+
+	err = verifyCertificateAaguid(certificate, attestation)
+	if err != nil {
+		return err
+	}
+
+	attestation.Type = AttestationTypeAttCa
+	attestation.TrustPath = trustPath
+	return nil
+}
+
+func coseAlgorithmHash(algorithm int) (crypto.Hash, error) {
+	switch algorithm {
+	case CoseAlgorithmRS1:
+		return crypto.SHA1, nil
+	case CoseAlgorithmES256, CoseAlgorithmRS256, CoseAlgorithmPS256:
+		return crypto.SHA256, nil
+	case CoseAlgorithmES384, CoseAlgorithmRS384:
+		return crypto.SHA384, nil
+	case CoseAlgorithmES512, CoseAlgorithmRS512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+func tpmAlgorithmHash(algorithm uint16) (crypto.Hash, error) {
+	switch algorithm {
+	case tpmAlgSha1:
+		return crypto.SHA1, nil
+	case tpmAlgSha256:
+		return crypto.SHA256, nil
+	case tpmAlgSha384:
+		return crypto.SHA384, nil
+	case tpmAlgSha512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("%w: tpm hash algorithm %#x", ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+// tpmReader reads the big endian structures of the TPM 2.0 specification.
+type tpmReader struct {
+	data []byte
+	err  error
+}
+
+func (r *tpmReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errTpmTruncated
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *tpmReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *tpmReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// sized reads a TPM2B structure, a buffer prefixed with its size.
+func (r *tpmReader) sized() []byte {
+	return r.bytes(int(r.uint16()))
+}
+
+// scheme reads an algorithm id that is followed by a hash algorithm unless
+// it is TPM_ALG_NULL.
+func (r *tpmReader) scheme() {
+	if r.uint16() != tpmAlgNull {
+		r.uint16()
+	}
+}
+
+type tpmPublic struct {
+	nameAlg   uint16
+	publicKey crypto.PublicKey
+}
+
+// parseTpmPublic parses a TPMT_PUBLIC structure.
+func parseTpmPublic(data []byte) (*tpmPublic, error) {
+	r := &tpmReader{data: data}
+
+	keyType := r.uint16()
+	public := &tpmPublic{nameAlg: r.uint16()}
+	r.uint32() // objectAttributes
+	r.sized()  // authPolicy
+
+	// symmetric
+	if r.uint16() != tpmAlgNull {
+		r.uint16() // keyBits
+		r.uint16() // mode
+	}
+
+	switch keyType {
+	case tpmAlgRsa:
+		r.scheme()
+		r.uint16() // keyBits
+		exponent := r.uint32()
+		modulus := r.sized()
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		// an exponent of zero stands for the default exponent
+		if exponent == 0 {
+			exponent = 65537
+		}
+		public.publicKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(exponent),
+		}
+
+	case tpmAlgEcc:
+		r.scheme()
+		curveId := r.uint16()
+		r.scheme() // kdf
+		x := r.sized()
+		y := r.sized()
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		var curve elliptic.Curve
+		switch curveId {
+		case tpmEccNistP256:
+			curve = elliptic.P256()
+		case tpmEccNistP384:
+			curve = elliptic.P384()
+		case tpmEccNistP521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported tpm curve %#x", curveId)
+		}
+		public.publicKey = &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported tpm key type %#x", keyType)
+	}
+
+	return public, nil
+}
+
+type tpmAttest struct {
+	magic        uint32
+	attestType   uint16
+	extraData    []byte
+	attestedName []byte
+}
+
+// parseTpmAttest parses a TPMS_ATTEST structure holding TPMS_CERTIFY_INFO.
+func parseTpmAttest(data []byte) (*tpmAttest, error) {
+	r := &tpmReader{data: data}
+
+	attest := &tpmAttest{
+		magic:      r.uint32(),
+		attestType: r.uint16(),
+	}
+	r.sized() // qualifiedSigner
+	attest.extraData = r.sized()
+	r.bytes(17) // clockInfo
+	r.bytes(8)  // firmwareVersion
+	attest.attestedName = r.sized()
+	r.sized() // qualifiedName
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return attest, nil
+}
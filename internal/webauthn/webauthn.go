@@ -0,0 +1,332 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/The127/Keyline/utils"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	CoseAlgorithmES256   = -7
+	CoseAlgorithmEd25519 = -8
+	CoseAlgorithmES384   = -35
+	CoseAlgorithmES512   = -36
+	CoseAlgorithmPS256   = -37
+	CoseAlgorithmRS256   = -257
+	CoseAlgorithmRS384   = -258
+	CoseAlgorithmRS512   = -259
+	// CoseAlgorithmRS1 is only accepted for attestation signatures, some
+	// TPMs still sign their certify info with SHA-1.
+	CoseAlgorithmRS1 = -65535
+
+	coseKeyTypeOkp = 1
+	coseKeyTypeEc2 = 2
+	coseKeyTypeRsa = 3
+
+	coseCurveP256    = 1
+	coseCurveP384    = 2
+	coseCurveP521    = 3
+	coseCurveEd25519 = 6
+
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+	flagExtensionData          = 0x80
+)
+
+var (
+	ErrInvalidClientData        = fmt.Errorf("invalid client data: %w", utils.ErrHttpBadRequest)
+	ErrInvalidAuthenticatorData = fmt.Errorf("invalid authenticator data: %w", utils.ErrHttpBadRequest)
+	ErrInvalidAttestation       = fmt.Errorf("invalid attestation: %w", utils.ErrHttpBadRequest)
+	ErrUnsupportedAlgorithm     = fmt.Errorf("unsupported public key algorithm: %w", utils.ErrHttpBadRequest)
+	ErrSignatureInvalid         = errors.New("signature verification failed")
+)
+
+// CredentialAlgorithms are the algorithms accepted for credential keys,
+// they are the ones the login can verify assertions for.
+var CredentialAlgorithms = []int{
+	CoseAlgorithmES256,
+	CoseAlgorithmEd25519,
+	CoseAlgorithmPS256,
+	CoseAlgorithmRS256,
+}
+
+// ClientData is the JSON the browser collects and the authenticator signs
+// the hash of.
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func ParseClientData(raw []byte) (*ClientData, error) {
+	var clientData ClientData
+	err := json.Unmarshal(raw, &clientData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidClientData, err)
+	}
+
+	return &clientData, nil
+}
+
+// Verify checks the type of the ceremony, the challenge that was handed to
+// the browser and the origin the ceremony happened at.
+func (c *ClientData) Verify(ceremonyType string, challenge []byte, origin string) error {
+	if c.Type != ceremonyType {
+		return fmt.Errorf("%w: unexpected type %q", ErrInvalidClientData, c.Type)
+	}
+
+	challengeFromClient, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(c.Challenge, "="))
+	if err != nil {
+		return fmt.Errorf("%w: decoding challenge: %w", ErrInvalidClientData, err)
+	}
+	if !bytes.Equal(challengeFromClient, challenge) {
+		return fmt.Errorf("challenge mismatch: %w", utils.ErrHttpUnauthorized)
+	}
+
+	if c.Origin != origin {
+		return fmt.Errorf("clientData origin %q does not match expected origin: %w", c.Origin, utils.ErrHttpUnauthorized)
+	}
+
+	return nil
+}
+
+// AuthenticatorData is the data the authenticator signs. The attested
+// credential data is only present on registration.
+type AuthenticatorData struct {
+	Raw       []byte
+	RpIdHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	Aaguid              uuid.UUID
+	CredentialId        []byte
+	CredentialPublicKey []byte
+}
+
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidAuthenticatorData)
+	}
+
+	authData := &AuthenticatorData{
+		Raw:       raw,
+		RpIdHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if authData.Flags&flagAttestedCredentialData == 0 {
+		return authData, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidAuthenticatorData)
+	}
+	copy(authData.Aaguid[:], rest[:16])
+
+	credentialIdLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < credentialIdLength {
+		return nil, fmt.Errorf("%w: credential id too short", ErrInvalidAuthenticatorData)
+	}
+	authData.CredentialId = rest[:credentialIdLength]
+	rest = rest[credentialIdLength:]
+
+	var publicKey cbor.RawMessage
+	rest, err := cbor.UnmarshalFirst(rest, &publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %w", ErrInvalidAuthenticatorData, err)
+	}
+	authData.CredentialPublicKey = publicKey
+
+	if authData.Flags&flagExtensionData == 0 && len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrInvalidAuthenticatorData)
+	}
+
+	return authData, nil
+}
+
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&flagUserPresent != 0
+}
+
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&flagUserVerified != 0
+}
+
+// ParseCosePublicKey parses a COSE_Key into a public key and its algorithm.
+func ParseCosePublicKey(raw []byte) (crypto.PublicKey, int, error) {
+	var key map[int]cbor.RawMessage
+	err := cbor.Unmarshal(raw, &key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: decoding COSE key: %w", ErrInvalidAttestation, err)
+	}
+
+	var keyType, algorithm int
+	if err := decodeCoseField(key, 1, &keyType); err != nil {
+		return nil, 0, err
+	}
+	if err := decodeCoseField(key, 3, &algorithm); err != nil {
+		return nil, 0, err
+	}
+
+	switch keyType {
+	case coseKeyTypeEc2:
+		var curveId int
+		var x, y []byte
+		if err := decodeCoseField(key, -1, &curveId); err != nil {
+			return nil, 0, err
+		}
+		if err := decodeCoseField(key, -2, &x); err != nil {
+			return nil, 0, err
+		}
+		if err := decodeCoseField(key, -3, &y); err != nil {
+			return nil, 0, err
+		}
+
+		var curve elliptic.Curve
+		switch curveId {
+		case coseCurveP256:
+			curve = elliptic.P256()
+		case coseCurveP384:
+			curve = elliptic.P384()
+		case coseCurveP521:
+			curve = elliptic.P521()
+		default:
+			return nil, 0, fmt.Errorf("%w: curve %d", ErrUnsupportedAlgorithm, curveId)
+		}
+
+		publicKey := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, fmt.Errorf("%w: point is not on the curve", ErrInvalidAttestation)
+		}
+		return publicKey, algorithm, nil
+
+	case coseKeyTypeRsa:
+		var n, e []byte
+		if err := decodeCoseField(key, -1, &n); err != nil {
+			return nil, 0, err
+		}
+		if err := decodeCoseField(key, -2, &e); err != nil {
+			return nil, 0, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid RSA exponent", ErrInvalidAttestation)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, algorithm, nil
+
+	case coseKeyTypeOkp:
+		var curveId int
+		var x []byte
+		if err := decodeCoseField(key, -1, &curveId); err != nil {
+			return nil, 0, err
+		}
+		if err := decodeCoseField(key, -2, &x); err != nil {
+			return nil, 0, err
+		}
+		if curveId != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: curve %d", ErrUnsupportedAlgorithm, curveId)
+		}
+
+		return ed25519.PublicKey(x), algorithm, nil
+
+	default:
+		return nil, 0, fmt.Errorf("%w: key type %d", ErrUnsupportedAlgorithm, keyType)
+	}
+}
+
+func decodeCoseField(key map[int]cbor.RawMessage, label int, v any) error {
+	raw, ok := key[label]
+	if !ok {
+		return fmt.Errorf("%w: COSE key is missing label %d", ErrInvalidAttestation, label)
+	}
+
+	err := cbor.Unmarshal(raw, v)
+	if err != nil {
+		return fmt.Errorf("%w: COSE key label %d: %w", ErrInvalidAttestation, label, err)
+	}
+
+	return nil
+}
+
+// VerifySignature verifies a signature made with the given COSE algorithm.
+func VerifySignature(publicKey crypto.PublicKey, algorithm int, message []byte, signature []byte) error {
+	switch k := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if algorithm != CoseAlgorithmES256 && algorithm != CoseAlgorithmES384 && algorithm != CoseAlgorithmES512 {
+			return ErrUnsupportedAlgorithm
+		}
+		hash, _ := coseAlgorithmHash(algorithm)
+
+		h := hash.New()
+		h.Write(message)
+		if !ecdsa.VerifyASN1(k, h.Sum(nil), signature) {
+			return ErrSignatureInvalid
+		}
+
+	case *rsa.PublicKey:
+		if algorithm != CoseAlgorithmRS1 && algorithm != CoseAlgorithmRS256 && algorithm != CoseAlgorithmRS384 &&
+			algorithm != CoseAlgorithmRS512 && algorithm != CoseAlgorithmPS256 {
+			return ErrUnsupportedAlgorithm
+		}
+		hash, _ := coseAlgorithmHash(algorithm)
+
+		h := hash.New()
+		h.Write(message)
+		var err error
+		if algorithm == CoseAlgorithmPS256 {
+			err = rsa.VerifyPSS(k, hash, h.Sum(nil), signature, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), signature)
+		}
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+
+	case ed25519.PublicKey:
+		if algorithm != CoseAlgorithmEd25519 {
+			return ErrUnsupportedAlgorithm
+		}
+		if !ed25519.Verify(k, message, signature) {
+			return ErrSignatureInvalid
+		}
+
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+
+	return nil
+}
+
+// RpIdHash is the hash an authenticator puts at the start of the
+// authenticator data.
+func RpIdHash(rpId string) []byte {
+	hash := sha256.Sum256([]byte(rpId))
+	return hash[:]
+}
@@ -0,0 +1,12 @@
+package templates
+
+import _ "embed"
+
+//go:embed default_account_locked_template.txt
+var DefaultAccountLockedTemplate []byte
+
+type AccountLockedTemplateData struct {
+	// LockedUntil is empty for permanent lockouts.
+	LockedUntil string
+	Permanent   bool
+}
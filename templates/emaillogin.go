@@ -0,0 +1,12 @@
+package templates
+
+import _ "embed"
+
+//go:embed default_email_login_template.txt
+var DefaultEmailLoginTemplate []byte
+
+type EmailLoginTemplateData struct {
+	LoginLink string
+	Code      string
+	ExpiresIn string
+}
@@ -0,0 +1,11 @@
+package templates
+
+import _ "embed"
+
+//go:embed default_password_reset_template.txt
+var DefaultPasswordResetTemplate []byte
+
+type PasswordResetTemplateData struct {
+	ResetLink string
+	ExpiresIn string
+}
@@ -0,0 +1,11 @@
+package templates
+
+import _ "embed"
+
+//go:embed default_sms_code_template.txt
+var DefaultSmsCodeTemplate []byte
+
+type SmsCodeTemplateData struct {
+	Code      string
+	ExpiresIn string
+}
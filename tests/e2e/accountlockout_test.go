@@ -0,0 +1,214 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/The127/Keyline/client"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	lockoutAppName      = "test-lockout-app"
+	lockoutUserUsername = "test-lockout-user"
+	lockoutUserPassword = "correct-horse-battery-staple"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Account lockout ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userId uuid.UUID
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, serviceUserTokenSource)
+				var err error
+				userId, err = setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:        "test-vs",
+					LockoutMaxFailedAttempts: utils.Ptr(3),
+					LockoutDuration:          utils.Ptr(time.Minute),
+					LockoutNotifyUser:        utils.Ptr(true),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			mintLoginToken := func() string {
+				deviceResp, err := h.Client().Oidc().BeginDeviceFlow(h.Ctx(), lockoutAppName, "openid")
+				Expect(err).ToNot(HaveOccurred())
+				loginToken, err := h.Client().Oidc().PostActivate(h.Ctx(), deviceResp.UserCode)
+				Expect(err).ToNot(HaveOccurred())
+				return loginToken
+			}
+
+			expectTooManyRequests := func(err error) {
+				var apiErr client.ApiError
+				Expect(errors.As(err, &apiErr)).To(BeTrue(), "expected an api error, got %v", err)
+				Expect(apiErr.Code).To(Equal(http.StatusTooManyRequests))
+			}
+
+			It("locks the user across fresh login tokens", func() {
+				// one attempt per loginToken, so the per-token cap never
+				// kicks in
+				for i := 0; i < 3; i++ {
+					err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), lockoutUserUsername, fmt.Sprintf("wrong-%d", i))
+					Expect(err).To(MatchError("invalid credentials"))
+				}
+
+				// a locked account fails like an unknown username, even
+				// with the correct password
+				err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), lockoutUserUsername, lockoutUserPassword)
+				Expect(err).To(MatchError("invalid credentials"))
+
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "unknown-user", lockoutUserPassword)
+				Expect(err).To(MatchError("invalid credentials"))
+			})
+
+			It("reports the lockout to admins", func() {
+				lockout, err := h.Client().User().GetLockout(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lockout.Locked).To(BeTrue())
+				Expect(lockout.Permanent).To(BeFalse())
+				Expect(lockout.Lockouts).To(Equal(1))
+				Expect(lockout.LockedUntil).ToNot(BeNil())
+			})
+
+			It("lets admins lift the lockout", func() {
+				err := h.Client().User().Unlock(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+
+				lockout, err := h.Client().User().GetLockout(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lockout.Locked).To(BeFalse())
+				Expect(lockout.Lockouts).To(BeZero())
+
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), lockoutUserUsername, lockoutUserPassword)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("throttles a source address across usernames", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:             "test-vs",
+					LockoutMaxFailedAttempts:      utils.Ptr(0),
+					LockoutMaxFailedAttemptsPerIp: utils.Ptr(3),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				for i := 0; i < 3; i++ {
+					err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), fmt.Sprintf("victim-%d", i), "spray")
+					Expect(err).To(MatchError("invalid credentials"))
+				}
+
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), lockoutUserUsername, lockoutUserPassword)
+				expectTooManyRequests(err)
+			})
+		})
+	}
+}
+
+func setupAccountLockoutFixtures(scope *ioc.DependencyProvider) (uuid.UUID, error) {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	_, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: "test-vs",
+		Slug:              "lockout-project",
+		Name:              "Lockout Project",
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("saving project: %w", err)
+	}
+
+	appResp, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName:      "test-vs",
+		ProjectSlug:            "lockout-project",
+		Name:                   lockoutAppName,
+		DisplayName:            "Test Lockout App",
+		Type:                   repositories.ApplicationTypePublic,
+		RedirectUris:           []string{"http://localhost:9999/callback"},
+		PostLogoutRedirectUris: []string{},
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating application: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("saving application: %w", err)
+	}
+
+	_, err = mediatr.Send[*commands.PatchApplicationResponse](ctx, m, commands.PatchApplication{
+		VirtualServerName: "test-vs",
+		ProjectSlug:       "lockout-project",
+		ApplicationId:     appResp.Id,
+		DeviceFlowEnabled: utils.Ptr(true),
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("enabling device flow: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("saving device flow flag: %w", err)
+	}
+
+	if err := seedUserWithPassword(ctx, m, dbContext, lockoutUserUsername, lockoutUserPassword); err != nil {
+		return uuid.Nil, err
+	}
+
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, repositories.NewVirtualServerFilter().Name("test-vs"))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting virtual server: %w", err)
+	}
+	userFilter := repositories.NewUserFilter().VirtualServerId(virtualServer.Id()).Username(lockoutUserUsername)
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	return user.Id(), nil
+}
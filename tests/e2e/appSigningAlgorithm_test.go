@@ -4,9 +4,15 @@ package e2e
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
+	"strings"
 
 	"github.com/The127/Keyline/api"
 	"github.com/The127/Keyline/config"
@@ -46,13 +52,17 @@ func init() {
 				m := ioc.GetDependency[mediatr.Mediator](scope)
 				dbCtx := ioc.GetDependency[database.Context](scope)
 
-				// Create a VS with EdDSA primary + RS256 additional
+				// Create a VS with EdDSA primary + RS256, ES256 and PS256 additional
 				createVSResp, err := mediatr.Send[*commands.CreateVirtualServerResponse](ctx, m, commands.CreateVirtualServer{
-					Name:                        vsName,
-					DisplayName:                 "App Algorithm VS",
-					PrimarySigningAlgorithm:     config.SigningAlgorithmEdDSA,
-					AdditionalSigningAlgorithms: []config.SigningAlgorithm{config.SigningAlgorithmRS256},
-					EnableRegistration:          true,
+					Name:                    vsName,
+					DisplayName:             "App Algorithm VS",
+					PrimarySigningAlgorithm: config.SigningAlgorithmEdDSA,
+					AdditionalSigningAlgorithms: []config.SigningAlgorithm{
+						config.SigningAlgorithmRS256,
+						config.SigningAlgorithmES256,
+						config.SigningAlgorithmPS256,
+					},
+					EnableRegistration: true,
 				})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(dbCtx.SaveChanges(ctx)).To(Succeed())
@@ -71,6 +81,21 @@ func init() {
 				Expect(err).ToNot(HaveOccurred())
 				rs256AppId = rs256Resp.Id.String()
 
+				// Create apps with ES256 and PS256 overrides
+				for _, alg := range []config.SigningAlgorithm{config.SigningAlgorithmES256, config.SigningAlgorithmPS256} {
+					_, err = mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+						VirtualServerName:     vsName,
+						ProjectSlug:           createVSResp.SystemProjectSlug,
+						Name:                  strings.ToLower(string(alg)) + "-app",
+						DisplayName:           string(alg) + " App",
+						Type:                  "public",
+						RedirectUris:          []string{"http://localhost/callback"},
+						AccessTokenHeaderType: "at+jwt",
+						SigningAlgorithm:      utils.Ptr(alg),
+					})
+					Expect(err).ToNot(HaveOccurred())
+				}
+
 				// Create an app with no override (uses VS primary = EdDSA)
 				noOverrideResp, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
 					VirtualServerName:     vsName,
@@ -126,6 +151,18 @@ func init() {
 				Expect(alg).To(Equal("RS256"))
 			})
 
+			It("tokens issued for ES256 app are signed with ES256 and verify against the JWKS", func() {
+				token := issueTokenViaTokenExchange(h, vsName, "es256-app")
+				Expect(jwtAlgorithm(token)).To(Equal("ES256"))
+				verifyTokenAgainstJwks(h, vsName, token)
+			})
+
+			It("tokens issued for PS256 app are signed with PS256 and verify against the JWKS", func() {
+				token := issueTokenViaTokenExchange(h, vsName, "ps256-app")
+				Expect(jwtAlgorithm(token)).To(Equal("PS256"))
+				verifyTokenAgainstJwks(h, vsName, token)
+			})
+
 			It("tokens issued for app with no override use VS primary (EdDSA)", func() {
 				token := issueTokenViaTokenExchange(h, vsName, "default-app")
 				alg := jwtAlgorithm(token)
@@ -162,10 +199,10 @@ func init() {
 				m := ioc.GetDependency[mediatr.Mediator](scope)
 
 				// Try to remove RS256 from the VS — but rs256-app still uses it
-				empty := []config.SigningAlgorithm{}
+				remaining := []config.SigningAlgorithm{config.SigningAlgorithmES256, config.SigningAlgorithmPS256}
 				_, err := mediatr.Send[*commands.PatchVirtualServerResponse](ctx, m, commands.PatchVirtualServer{
 					VirtualServerName:           vsName,
-					AdditionalSigningAlgorithms: &empty,
+					AdditionalSigningAlgorithms: &remaining,
 				})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("RS256"))
@@ -189,6 +226,47 @@ func jwtAlgorithm(tokenString string) string {
 	return token.Method.Alg()
 }
 
+// verifyTokenAgainstJwks checks the signature of a JWT with the matching key
+// published in the JWKS of the virtual server.
+func verifyTokenAgainstJwks(h *harness, vsName, tokenString string) {
+	resp, err := http.Get(jwksURL(h, vsName))
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close() //nolint:errcheck
+
+	var jwks jwksResponse
+	Expect(json.NewDecoder(resp.Body).Decode(&jwks)).To(Succeed())
+
+	_, err = jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		for _, k := range jwks.Keys {
+			if k["kid"] != token.Header["kid"] {
+				continue
+			}
+			return jwkPublicKey(k)
+		}
+		return nil, fmt.Errorf("no key with kid %v", token.Header["kid"])
+	}, jwt.WithValidMethods([]string{jwtAlgorithm(tokenString)}), jwt.WithoutClaimsValidation())
+	Expect(err).ToNot(HaveOccurred())
+}
+
+// jwkPublicKey decodes the RSA and EC keys of a JWKS response.
+func jwkPublicKey(k map[string]any) (any, error) {
+	decode := func(name string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(k[name].(string))
+		Expect(err).ToNot(HaveOccurred())
+		return new(big.Int).SetBytes(b)
+	}
+
+	switch k["kty"] {
+	case "RSA":
+		return &rsa.PublicKey{N: decode("n"), E: int(decode("e").Int64())}, nil
+	case "EC":
+		curves := map[any]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		return &ecdsa.PublicKey{Curve: curves[k["crv"]], X: decode("x"), Y: decode("y")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", k["kty"])
+	}
+}
+
 // getApplicationFromAPI fetches an application via the admin API using the service user token.
 func getApplicationFromAPI(h *harness, vsName, projectSlug, appId string) api.GetApplicationResponseDto {
 	token := acquireTokenForServiceUser(h, serviceUserUsername, serviceUserKid, serviceUserPrivateKey)
@@ -0,0 +1,187 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/handlers"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	emailLoginLinkUsername = "test-email-login-link-user"
+	emailLoginLinkPassword = "correct-horse-battery-staple"
+)
+
+var (
+	emailLoginCodePattern  = regexp.MustCompile(`enter the code ([0-9]{6})`)
+	emailLoginTokenPattern = regexp.MustCompile(`emailLoginToken=([A-Za-z0-9_-]+)`)
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Email login ["+backend.name+"]", Ordered, func() {
+			var h *harness
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(seedEmailLoginLinkUser(h.Scope())).To(Succeed())
+
+				_, err = sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: "test-vs",
+					EnableEmailLogin:  utils.Ptr(true),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			post := func(loginToken string, path string, body any) int {
+				jsonBytes, err := json.Marshal(body)
+				Expect(err).ToNot(HaveOccurred())
+
+				url := fmt.Sprintf("%s/logins/%s/email-login/%s", h.ApiUrl(), loginToken, path)
+				resp, err := http.Post(url, "application/json", bytes.NewReader(jsonBytes))
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				return resp.StatusCode
+			}
+
+			loginState := func(loginToken string) handlers.GetLoginStateResponseDto {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var state handlers.GetLoginStateResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+				return state
+			}
+
+			It("offers the email login on the login page", func() {
+				loginToken := mintPasswordResetLoginToken(h)
+				Expect(loginState(loginToken).EmailLoginEnabled).To(BeTrue())
+			})
+
+			It("answers the same for unknown accounts and sends nothing", func() {
+				loginToken := mintPasswordResetLoginToken(h)
+				before := queuedMails(h, "Sign in")
+
+				status := post(loginToken, "start", map[string]string{"usernameOrEmail": "nobody@test.local"})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				Expect(queuedMails(h, "Sign in")).To(HaveLen(len(before)))
+			})
+
+			It("signs in with the code from the mail", func() {
+				loginToken := mintPasswordResetLoginToken(h)
+
+				status := post(loginToken, "start", map[string]string{"usernameOrEmail": lockoutUserUsername + "@test.local"})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				mails := queuedMails(h, "Sign in")
+				Expect(mails).ToNot(BeEmpty())
+				mail := mails[len(mails)-1]
+				Expect(mail.To).To(Equal(lockoutUserUsername + "@test.local"))
+
+				match := emailLoginCodePattern.FindStringSubmatch(mail.Body)
+				Expect(match).To(HaveLen(2))
+				code := match[1]
+
+				wrongCode := "000000"
+				if code == wrongCode {
+					wrongCode = "111111"
+				}
+				Expect(post(loginToken, "verify", map[string]string{"code": wrongCode})).To(Equal(http.StatusUnauthorized))
+
+				Expect(post(loginToken, "verify", map[string]string{"code": code})).To(Equal(http.StatusNoContent))
+				Expect(loginState(loginToken).Step).To(Equal("finish"))
+				Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+
+				// the code can only be used once
+				Expect(post(loginToken, "verify", map[string]string{"code": code})).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("throttles further mails to the same user", func() {
+				loginToken := mintPasswordResetLoginToken(h)
+				before := queuedMails(h, "Sign in")
+
+				status := post(loginToken, "start", map[string]string{"usernameOrEmail": lockoutUserUsername})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				Expect(queuedMails(h, "Sign in")).To(HaveLen(len(before)))
+			})
+
+			It("signs in with the link from the mail", func() {
+				loginToken := mintPasswordResetLoginToken(h)
+
+				status := post(loginToken, "start", map[string]string{"usernameOrEmail": emailLoginLinkUsername})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				// the mails of the earlier specs were queued at the same time
+				// on the mock clock, so pick the mail by recipient
+				mails := queuedMails(h, "Sign in")
+				idx := slices.IndexFunc(mails, func(mail messages.SendEmailMessage) bool {
+					return mail.To == emailLoginLinkUsername+"@test.local"
+				})
+				Expect(idx).ToNot(Equal(-1))
+				match := emailLoginTokenPattern.FindStringSubmatch(mails[idx].Body)
+				Expect(match).To(HaveLen(2))
+
+				Expect(post(loginToken, "verify", map[string]string{"token": match[1]})).To(Equal(http.StatusNoContent))
+				Expect(loginState(loginToken).Step).To(Equal("finish"))
+			})
+		})
+	}
+}
+
+func seedEmailLoginLinkUser(scope *ioc.DependencyProvider) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	return seedUserWithPassword(ctx, m, dbContext, emailLoginLinkUsername, emailLoginLinkPassword)
+}
@@ -189,6 +189,8 @@ func newE2eTestHarness(dbMode config.DatabaseMode, tokenSourceGenerator func(ctx
 	setup.KeyServices(dc, keyStoreConfig)
 
 	setup.Caching(dc, config.CacheModeMemory)
+	setup.Sms(dc, config.SmsConfig{Mode: config.SmsModeLog})
+	setup.BreachedPasswords(dc, config.BreachedPasswordsConfig{Mode: config.BreachedPasswordsModeNone})
 	setup.Services(dc)
 	setup.Mediator(dc)
 
@@ -0,0 +1,461 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// End-to-end test of identity brokering: "test-vs" brokers logins to a
+// second virtual server on the same in-process Keyline, which plays the
+// upstream OpenID provider.
+
+const (
+	brokerUpstreamVS       = "upstream-vs"
+	brokerUpstreamProject  = "upstream-project"
+	brokerUpstreamApp      = "downstream-keyline"
+	brokerUpstreamSecret   = "upstream-client-secret-for-e2e-do-not-reuse"
+	brokerUpstreamUser     = "upstream-user"
+	brokerUpstreamEmail    = "upstream-user@upstream.local"
+	brokerUpstreamPassword = "upstream-user-password-1"
+
+	brokerProviderName = "upstream"
+	brokerProject      = "broker-project"
+	brokerApp          = "broker-app"
+	brokerRedirect     = "http://localhost:9200/callback"
+	brokerPkceVerifier = "identity-brokering-verifier-padding-padding-padding-12"
+	brokerTotpSecret   = "KRSXG5CTMVRXEZLUKRSXG5CTMVRXEZLU"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Identity brokering ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var previousExternalUrl string
+
+			noRedirectClient := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			get := func(target string, cookie string) *http.Response {
+				req, err := http.NewRequest(http.MethodGet, target, nil)
+				Expect(err).ToNot(HaveOccurred())
+				if cookie != "" {
+					req.Header.Set("Cookie", cookie)
+				}
+				resp, err := noRedirectClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				body, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusFound), "GET %s: %s", target, body)
+				return resp
+			}
+
+			post := func(target string, body string) *http.Response {
+				resp, err := noRedirectClient.Post(target, "application/json", strings.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				return resp
+			}
+
+			absolute := func(location string) string {
+				if strings.HasPrefix(location, "http") {
+					return location
+				}
+				return h.ApiUrl() + location
+			}
+
+			loginTokenOf := func(resp *http.Response) string {
+				loc, err := url.Parse(resp.Header.Get("Location"))
+				Expect(err).ToNot(HaveOccurred())
+				token := loc.Query().Get("token")
+				Expect(token).ToNot(BeEmpty(), "no login token in %s", resp.Header.Get("Location"))
+				return token
+			}
+
+			cookieOf := func(resp *http.Response) string {
+				cookie := resp.Header.Get("Set-Cookie")
+				Expect(cookie).ToNot(BeEmpty())
+				return strings.SplitN(cookie, ";", 2)[0]
+			}
+
+			// finishLogin completes a login session on the given virtual
+			// server and returns the authorization code for the original
+			// /authorize request.
+			finishLogin := func(loginToken string) *url.URL {
+				resp := post(fmt.Sprintf("%s/logins/%s/finish-login", h.ApiUrl(), loginToken), "")
+				Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+				resp = get(absolute(resp.Header.Get("Location")), cookieOf(resp))
+				final, err := url.Parse(resp.Header.Get("Location"))
+				Expect(err).ToNot(HaveOccurred())
+				return final
+			}
+
+			// brokeredCallback signs in at the upstream virtual server for a
+			// login of test-vs and returns the response of the test-vs
+			// callback together with the test-vs login token.
+			brokeredCallback := func() (*http.Response, string) {
+				q := url.Values{}
+				q.Set("response_type", "code")
+				q.Set("client_id", brokerApp)
+				q.Set("redirect_uri", brokerRedirect)
+				q.Set("scope", "openid")
+				q.Set("state", "downstream-state")
+				q.Set("code_challenge", authCodePkceChallenge(brokerPkceVerifier))
+				q.Set("code_challenge_method", "S256")
+				resp := get(fmt.Sprintf("%s/oidc/test-vs/authorize?%s", h.ApiUrl(), q.Encode()), "")
+				loginToken := loginTokenOf(resp)
+
+				resp = get(fmt.Sprintf("%s/logins/%s/identity-providers/%s", h.ApiUrl(), loginToken, brokerProviderName), "")
+				stateCookie := cookieOf(resp)
+				upstreamAuthorize := resp.Header.Get("Location")
+				Expect(upstreamAuthorize).To(HavePrefix(fmt.Sprintf("%s/oidc/%s/authorize", h.ApiUrl(), brokerUpstreamVS)))
+
+				resp = get(upstreamAuthorize, "")
+				upstreamLoginToken := loginTokenOf(resp)
+
+				resp = post(
+					fmt.Sprintf("%s/logins/%s/verify-password", h.ApiUrl(), upstreamLoginToken),
+					fmt.Sprintf(`{"username":%q,"password":%q}`, brokerUpstreamUser, brokerUpstreamPassword),
+				)
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				callback := finishLogin(upstreamLoginToken)
+				Expect(callback.Path).To(Equal(fmt.Sprintf("/oidc/test-vs/identity-providers/%s/callback", brokerProviderName)))
+				Expect(callback.Query().Get("code")).ToNot(BeEmpty(), "upstream error: %s", callback.Query().Get("error"))
+
+				req, err := http.NewRequest(http.MethodGet, callback.String(), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Cookie", stateCookie)
+				resp, err = noRedirectClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				return resp, loginToken
+			}
+
+			// brokeredLoginStep returns the test-vs login token and the
+			// step the login is at after the upstream sign in.
+			brokeredLoginStep := func() (string, string) {
+				resp, loginToken := brokeredCallback()
+				Expect(resp.StatusCode).To(Equal(http.StatusFound))
+				Expect(loginTokenOf(resp)).To(Equal(loginToken))
+
+				stateResp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = stateResp.Body.Close() }()
+				Expect(stateResp.StatusCode).To(Equal(http.StatusOK))
+				return loginToken, readJSON(stateResp)["step"].(string)
+			}
+
+			// brokeredLogin signs in to test-vs through the upstream virtual
+			// server and returns the final redirect of test-vs.
+			brokeredLogin := func() *url.URL {
+				loginToken, step := brokeredLoginStep()
+				Expect(step).To(Equal("finish"))
+				return finishLogin(loginToken)
+			}
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+
+				// Redirect URIs and the upstream issuer are derived from the
+				// external url, which the harness leaves unset.
+				previousExternalUrl = config.C.Server.ExternalUrl
+				config.C.Server.ExternalUrl = h.ApiUrl()
+
+				Expect(setupIdentityBrokeringFixtures(h)).To(Succeed())
+			})
+
+			AfterAll(func() {
+				config.C.Server.ExternalUrl = previousExternalUrl
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("creates the brokered user just in time", func() {
+				final := brokeredLogin()
+				Expect(final.Query().Get("error")).To(BeEmpty(), final.Query().Get("error_description"))
+				Expect(final.Query().Get("code")).ToNot(BeEmpty())
+
+				user, link := brokeredUser(h)
+				Expect(user).ToNot(BeNil())
+				Expect(user.Username()).To(Equal(brokerUpstreamEmail))
+				Expect(user.DisplayName()).To(Equal("Upstream User"))
+				Expect(user.EmailVerified()).To(BeTrue())
+				Expect(link).To(HaveLen(1))
+			})
+
+			It("reuses the account link on the next login", func() {
+				firstUser, _ := brokeredUser(h)
+
+				final := brokeredLogin()
+				Expect(final.Query().Get("code")).ToNot(BeEmpty())
+
+				user, link := brokeredUser(h)
+				Expect(user.Id()).To(Equal(firstUser.Id()))
+				Expect(link).To(HaveLen(1))
+			})
+
+			It("asks the linked user for their second factor", func() {
+				user, _ := brokeredUser(h)
+				totpCredential := repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{
+					Secret:    brokerTotpSecret,
+					Digits:    int(otp.DigitsSix),
+					Algorithm: int(otp.AlgorithmSHA1),
+				})
+				withBrokeredUser(h, func(ctx context.Context, dbContext database.Context, _ *repositories.User) {
+					dbContext.Credentials().Insert(totpCredential)
+				})
+				DeferCleanup(func() {
+					withBrokeredUser(h, func(ctx context.Context, dbContext database.Context, _ *repositories.User) {
+						dbContext.Credentials().Delete(totpCredential.Id())
+					})
+				})
+
+				loginToken, step := brokeredLoginStep()
+				Expect(step).To(Equal("verifyTotp"))
+
+				code, err := totp.GenerateCode(brokerTotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				resp := post(fmt.Sprintf("%s/logins/%s/verify-totp", h.ApiUrl(), loginToken), fmt.Sprintf(`{"totpCode":%q}`, code))
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				final := finishLogin(loginToken)
+				Expect(final.Query().Get("code")).ToNot(BeEmpty())
+			})
+
+			It("applies the MFA rules to brokered logins", func() {
+				setRules := func(rules repositories.MfaRules) {
+					_, err := sendAsSystem[*commands.UpdateMfaRulesResponse](h, commands.UpdateMfaRules{
+						VirtualServerName: "test-vs",
+						Rules:             rules,
+					})
+					Expect(err).ToNot(HaveOccurred())
+				}
+				setRules(repositories.MfaRules{
+					{Action: repositories.MfaRuleActionRequire, Projects: []string{brokerProject}},
+				})
+				DeferCleanup(func() {
+					setRules(repositories.MfaRules{})
+				})
+
+				_, step := brokeredLoginStep()
+				Expect(step).To(Equal("onboardTotp"))
+			})
+
+			It("rejects a disabled linked user", func() {
+				withBrokeredUser(h, func(ctx context.Context, dbContext database.Context, user *repositories.User) {
+					user.SetDisabled(true)
+					dbContext.Users().Update(user)
+				})
+				DeferCleanup(func() {
+					withBrokeredUser(h, func(ctx context.Context, dbContext database.Context, user *repositories.User) {
+						user.SetDisabled(false)
+						dbContext.Users().Update(user)
+					})
+				})
+
+				resp, _ := brokeredCallback()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("rejects a callback without the state cookie", func() {
+				q := url.Values{}
+				q.Set("code", "some-code")
+				q.Set("state", "some-state")
+				resp, err := noRedirectClient.Get(fmt.Sprintf("%s/oidc/test-vs/identity-providers/%s/callback?%s", h.ApiUrl(), brokerProviderName, q.Encode()))
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	}
+}
+
+// brokeredUser returns the test-vs user created for the upstream user and
+// its account links.
+func brokeredUser(h *harness) (*repositories.User, []*repositories.Credential) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, repositories.NewVirtualServerFilter().Name("test-vs"))
+	Expect(err).ToNot(HaveOccurred())
+
+	userFilter := repositories.NewUserFilter().VirtualServerId(virtualServer.Id()).Username(brokerUpstreamEmail)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	Expect(err).ToNot(HaveOccurred())
+	if user == nil {
+		return nil, nil
+	}
+
+	linkFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypeIdentityProvider)
+	links, err := dbContext.Credentials().List(ctx, linkFilter)
+	Expect(err).ToNot(HaveOccurred())
+
+	return user, links
+}
+
+// withBrokeredUser passes the test-vs user created for the upstream user
+// to change and saves the changes.
+func withBrokeredUser(h *harness, change func(ctx context.Context, dbContext database.Context, user *repositories.User)) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	userFilter := repositories.NewUserFilter().Username(brokerUpstreamEmail)
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, repositories.NewVirtualServerFilter().Name("test-vs"))
+	Expect(err).ToNot(HaveOccurred())
+	user, err := dbContext.Users().FirstOrErr(ctx, userFilter.VirtualServerId(virtualServer.Id()))
+	Expect(err).ToNot(HaveOccurred())
+
+	change(ctx, dbContext, user)
+	Expect(dbContext.SaveChanges(ctx)).To(Succeed())
+}
+
+func setupIdentityBrokeringFixtures(h *harness) error {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	// upstream provider
+	if _, err := mediatr.Send[*commands.CreateVirtualServerResponse](ctx, m, commands.CreateVirtualServer{
+		Name:                    brokerUpstreamVS,
+		DisplayName:             "Upstream Virtual Server",
+		PrimarySigningAlgorithm: config.SigningAlgorithmEdDSA,
+	}); err != nil {
+		return fmt.Errorf("creating upstream virtual server: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: brokerUpstreamVS,
+		Slug:              brokerUpstreamProject,
+		Name:              "Upstream Project",
+	}); err != nil {
+		return fmt.Errorf("creating upstream project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	callbackUrl := fmt.Sprintf("%s/oidc/test-vs/identity-providers/%s/callback", h.ApiUrl(), brokerProviderName)
+	if _, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName:      brokerUpstreamVS,
+		ProjectSlug:            brokerUpstreamProject,
+		Name:                   brokerUpstreamApp,
+		DisplayName:            "Downstream Keyline",
+		Type:                   repositories.ApplicationTypeConfidential,
+		HashedSecret:           utils.Ptr(utils.CheapHash(brokerUpstreamSecret)),
+		RedirectUris:           []string{callbackUrl},
+		PostLogoutRedirectUris: []string{},
+	}); err != nil {
+		return fmt.Errorf("creating upstream application: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	userResponse, err := mediatr.Send[*commands.CreateUserResponse](ctx, m, commands.CreateUser{
+		VirtualServerName: brokerUpstreamVS,
+		Username:          brokerUpstreamUser,
+		DisplayName:       "Upstream User",
+		Email:             brokerUpstreamEmail,
+		EmailVerified:     true,
+	})
+	if err != nil {
+		return fmt.Errorf("creating upstream user: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(userResponse.Id, &repositories.CredentialPasswordDetails{
+		HashedPassword: utils.HashPassword(brokerUpstreamPassword),
+		Temporary:      false,
+	}))
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	// downstream relying party
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: "test-vs",
+		Slug:              brokerProject,
+		Name:              "Broker Project",
+	}); err != nil {
+		return fmt.Errorf("creating project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	if _, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName:      "test-vs",
+		ProjectSlug:            brokerProject,
+		Name:                   brokerApp,
+		DisplayName:            "Broker App",
+		Type:                   repositories.ApplicationTypePublic,
+		RedirectUris:           []string{brokerRedirect},
+		PostLogoutRedirectUris: []string{},
+	}); err != nil {
+		return fmt.Errorf("creating application: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	if _, err := mediatr.Send[*commands.CreateIdentityProviderResponse](ctx, m, commands.CreateIdentityProvider{
+		VirtualServerName: "test-vs",
+		Name:              brokerProviderName,
+		DisplayName:       "Upstream Keyline",
+		Issuer:            fmt.Sprintf("%s/oidc/%s", h.ApiUrl(), brokerUpstreamVS),
+		ClientId:          brokerUpstreamApp,
+		ClientSecret:      brokerUpstreamSecret,
+		Scopes:            []string{"openid", "profile", "email"},
+	}); err != nil {
+		return fmt.Errorf("creating identity provider: %w", err)
+	}
+
+	return dbContext.SaveChanges(ctx)
+}
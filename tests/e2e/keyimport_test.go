@@ -0,0 +1,131 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Signing key import ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var legacyKey *rsa.PrivateKey
+			const vsName = "key-import-vs"
+			const legacyKid = "legacy-rs256"
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+
+				_, err := sendAsSystem[*commands.CreateVirtualServerResponse](h, commands.CreateVirtualServer{
+					Name:                    vsName,
+					DisplayName:             "Key Import VS",
+					PrimarySigningAlgorithm: config.SigningAlgorithmEdDSA,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				legacyKey, err = rsa.GenerateKey(rand.Reader, 2048)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("rejects signing with an algorithm the virtual server does not use", func() {
+				_, err := sendAsSystem[*commands.ImportSigningKeyResponse](h, commands.ImportSigningKey{
+					VirtualServerName: vsName,
+					Kid:               legacyKid,
+					Algorithm:         config.SigningAlgorithmRS256,
+					PrivateKey:        pkcs1Pem(legacyKey),
+					NotAfter:          ioc.GetDependency[clock.Service](h.Scope()).Now().Add(time.Hour * 24 * 30),
+				})
+				Expect(err).To(MatchError(utils.ErrHttpBadRequest))
+			})
+
+			It("imports a verify-only key", func() {
+				response, err := sendAsSystem[*commands.ImportSigningKeyResponse](h, commands.ImportSigningKey{
+					VirtualServerName: vsName,
+					Kid:               legacyKid,
+					Algorithm:         config.SigningAlgorithmRS256,
+					PrivateKey:        pkcs1Pem(legacyKey),
+					NotAfter:          ioc.GetDependency[clock.Service](h.Scope()).Now().Add(time.Hour * 24 * 30),
+					VerifyOnly:        true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.State).To(Equal(services.KeyStateRetired))
+			})
+
+			It("rejects importing the same kid twice", func() {
+				_, err := sendAsSystem[*commands.ImportSigningKeyResponse](h, commands.ImportSigningKey{
+					VirtualServerName: vsName,
+					Kid:               legacyKid,
+					Algorithm:         config.SigningAlgorithmRS256,
+					PrivateKey:        pkcs1Pem(legacyKey),
+					NotAfter:          ioc.GetDependency[clock.Service](h.Scope()).Now().Add(time.Hour * 24 * 30),
+					VerifyOnly:        true,
+				})
+				Expect(err).To(MatchError(utils.ErrSigningKeyExists))
+			})
+
+			It("lists the imported key as verify-only", func() {
+				keys := listSigningKeys(h, vsName)
+				Expect(keys).To(HaveLen(2))
+				for _, key := range keys {
+					if key.Kid == legacyKid {
+						Expect(key.VerifyOnly).To(BeTrue())
+						Expect(key.State).To(Equal(services.KeyStateRetired))
+					} else {
+						Expect(key.State).To(Equal(services.KeyStateActive))
+					}
+				}
+			})
+
+			It("publishes the key so tokens of the previous issuer still verify", func() {
+				Expect(jwksKids(h, vsName)).To(ContainElement(legacyKid))
+
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "legacy-user"})
+				token.Header["kid"] = legacyKid
+				signed, err := token.SignedString(legacyKey)
+				Expect(err).ToNot(HaveOccurred())
+
+				verifyTokenAgainstJwks(h, vsName, signed)
+			})
+
+			It("keeps signing with the generated key", func() {
+				keyService := ioc.GetDependency[services.KeyService](h.Scope())
+				keyPair, err := keyService.GetKey(vsName, config.SigningAlgorithmEdDSA)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(keyPair.GetKid()).ToNot(Equal(legacyKid))
+			})
+		})
+	}
+}
+
+func pkcs1Pem(privateKey *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}))
+}
@@ -0,0 +1,198 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/jobs"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/services"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Signing key rotation ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var start time.Time
+			const vsName = "key-rotation-vs"
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				start = ioc.GetDependency[clock.Service](h.Scope()).Now()
+
+				_, err := sendAsSystem[*commands.CreateVirtualServerResponse](h, commands.CreateVirtualServer{
+					Name:                    vsName,
+					DisplayName:             "Key Rotation VS",
+					PrimarySigningAlgorithm: config.SigningAlgorithmEdDSA,
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("stores the rotation policy of the virtual server", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: vsName,
+					KeyRotateAfter:    utils.Ptr(time.Hour * 24 * 10),
+					KeyExpireAfter:    utils.Ptr(time.Hour * 24 * 15),
+					KeyPrePublishLead: utils.Ptr(time.Hour * 24 * 2),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				response, err := sendAsSystem[*queries.GetVirtualServerResponse](h, queries.GetVirtualServerQuery{
+					VirtualServerName: vsName,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.KeyRotationPolicy.RotateAfter).To(Equal(time.Hour * 24 * 10))
+				Expect(response.KeyRotationPolicy.ExpireAfter).To(Equal(time.Hour * 24 * 15))
+				Expect(response.KeyRotationPolicy.PrePublishLead).To(Equal(time.Hour * 24 * 2))
+			})
+
+			It("rejects a policy that expires keys before they rotate", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: vsName,
+					KeyExpireAfter:    utils.Ptr(time.Hour * 24 * 5),
+				})
+				Expect(err).To(MatchError(utils.ErrHttpBadRequest))
+			})
+
+			It("lists the initial key as active", func() {
+				keys := listSigningKeys(h, vsName)
+				Expect(keys).To(HaveLen(1))
+				Expect(keys[0].State).To(Equal(services.KeyStateActive))
+			})
+
+			It("forces an immediate rotation and retires the previous key", func() {
+				previous := listSigningKeys(h, vsName)[0]
+
+				response, err := sendAsSystem[*commands.RotateSigningKeysResponse](h, commands.RotateSigningKeys{
+					VirtualServerName: vsName,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Keys).To(HaveLen(1))
+
+				states := signingKeyStates(listSigningKeys(h, vsName))
+				Expect(states).To(HaveKeyWithValue(response.Keys[0].Kid, services.KeyStateActive))
+				Expect(states).To(HaveKeyWithValue(previous.Kid, services.KeyStateRetired))
+				Expect(jwksKids(h, vsName)).To(ConsistOf(response.Keys[0].Kid, previous.Kid))
+			})
+
+			It("drops a revoked key from the JWKS right away", func() {
+				var retired string
+				for kid, state := range signingKeyStates(listSigningKeys(h, vsName)) {
+					if state == services.KeyStateRetired {
+						retired = kid
+					}
+				}
+
+				response, err := sendAsSystem[*commands.RevokeSigningKeyResponse](h, commands.RevokeSigningKey{
+					VirtualServerName: vsName,
+					Kid:               retired,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.ReplacementKid).To(BeNil())
+				Expect(jwksKids(h, vsName)).ToNot(ContainElement(retired))
+			})
+
+			It("replaces a revoked active key", func() {
+				active := listSigningKeys(h, vsName)[0]
+				Expect(active.State).To(Equal(services.KeyStateActive))
+
+				response, err := sendAsSystem[*commands.RevokeSigningKeyResponse](h, commands.RevokeSigningKey{
+					VirtualServerName: vsName,
+					Kid:               active.Kid,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.ReplacementKid).ToNot(BeNil())
+				Expect(jwksKids(h, vsName)).To(ConsistOf(*response.ReplacementKid))
+			})
+
+			It("pre-publishes the successor and switches to it on rotation", func() {
+				active := listSigningKeys(h, vsName)[0]
+
+				h.SetTime(active.RotatesAt.Add(-time.Hour * 24))
+				runKeyRotateJob(h)
+
+				states := signingKeyStates(listSigningKeys(h, vsName))
+				Expect(states).To(HaveLen(2))
+				Expect(states).To(HaveKeyWithValue(active.Kid, services.KeyStateActive))
+				Expect(jwksKids(h, vsName)).To(HaveLen(2))
+
+				h.SetTime(active.RotatesAt.Add(time.Minute))
+				runKeyRotateJob(h)
+
+				states = signingKeyStates(listSigningKeys(h, vsName))
+				Expect(states).To(HaveLen(2))
+				Expect(states).To(HaveKeyWithValue(active.Kid, services.KeyStateRetired))
+				Expect(states).To(ContainElement(services.KeyStateActive))
+
+				h.SetTime(start)
+			})
+		})
+	}
+}
+
+func listSigningKeys(h *harness, vsName string) []queries.ListSigningKeysResponseItem {
+	response, err := sendAsSystem[*queries.ListSigningKeysResponse](h, queries.ListSigningKeysQuery{
+		VirtualServerName: vsName,
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return response.Items
+}
+
+func signingKeyStates(keys []queries.ListSigningKeysResponseItem) map[string]services.KeyState {
+	states := make(map[string]services.KeyState, len(keys))
+	for _, key := range keys {
+		states[key.Kid] = key.State
+	}
+	return states
+}
+
+func jwksKids(h *harness, vsName string) []string {
+	// the server is started in the background, so the first request may race it
+	var resp *http.Response
+	Eventually(func() error {
+		var err error
+		resp, err = http.Get(jwksURL(h, vsName))
+		return err
+	}).Should(Succeed())
+	defer resp.Body.Close() //nolint:errcheck
+
+	var jwks jwksResponse
+	Expect(json.NewDecoder(resp.Body).Decode(&jwks)).To(Succeed())
+
+	kids := make([]string, len(jwks.Keys))
+	for i, key := range jwks.Keys {
+		kids[i] = key["kid"].(string)
+	}
+	return kids
+}
+
+func runKeyRotateJob(h *harness) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	Expect(jobs.KeyRotateJob()(ctx)).To(Succeed())
+}
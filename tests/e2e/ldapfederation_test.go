@@ -0,0 +1,335 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/federation/ldaptest"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// End-to-end test of LDAP user federation against an in-process directory.
+
+const (
+	ldapAppName       = "ldap-app"
+	ldapServiceDn     = "cn=keyline,dc=example,dc=com"
+	ldapServiceSecret = "ldap-service-secret"
+	ldapUsersDn       = "ou=people,dc=example,dc=com"
+	ldapGroupsDn      = "ou=groups,dc=example,dc=com"
+
+	ldapAliceDn       = "uid=alice,ou=people,dc=example,dc=com"
+	ldapAlicePassword = "alice-directory-password"
+	ldapBobDn         = "uid=bob,ou=people,dc=example,dc=com"
+	ldapBobPassword   = "bob-directory-password"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("LDAP federation ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var directory *ldaptest.Server
+			var ldapProviderId uuid.UUID
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+
+				var err error
+				directory, err = ldaptest.Start()
+				Expect(err).ToNot(HaveOccurred())
+				seedLdapDirectory(directory)
+
+				ldapProviderId, err = setupLdapFederationFixtures(h, directory)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if directory != nil {
+					_ = directory.Close()
+				}
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			mintLoginToken := func() string {
+				deviceResp, err := h.Client().Oidc().BeginDeviceFlow(h.Ctx(), ldapAppName, "openid")
+				Expect(err).ToNot(HaveOccurred())
+				loginToken, err := h.Client().Oidc().PostActivate(h.Ctx(), deviceResp.UserCode)
+				Expect(err).ToNot(HaveOccurred())
+				return loginToken
+			}
+
+			It("rejects a wrong directory password without importing the user", func() {
+				err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "alice", "wrong")
+				Expect(err).To(HaveOccurred())
+
+				user, _ := ldapUser(h, "alice")
+				Expect(user).To(BeNil())
+			})
+
+			It("imports a directory user on the first login", func() {
+				err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "alice", ldapAlicePassword)
+				Expect(err).ToNot(HaveOccurred())
+
+				user, link := ldapUser(h, "alice")
+				Expect(user).ToNot(BeNil())
+				Expect(user.DisplayName()).To(Equal("Alice Example"))
+				Expect(user.PrimaryEmail()).To(Equal("alice@example.com"))
+				Expect(link).ToNot(BeNil())
+			})
+
+			It("checks the password of an imported user against the directory", func() {
+				err := h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "alice", ldapAlicePassword)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "alice", "wrong")
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("syncs users, groups and memberships", func() {
+				response, err := sendAsSystem[*commands.SyncLdapProviderResponse](h, commands.SyncLdapProvider{
+					VirtualServerName: "test-vs",
+					LdapProviderId:    ldapProviderId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.UsersCreated).To(Equal(1))
+				Expect(response.UsersUpdated).To(Equal(1))
+				Expect(response.GroupsCreated).To(Equal(1))
+				Expect(response.MembershipsAdded).To(Equal(2))
+
+				bob, _ := ldapUser(h, "bob")
+				Expect(bob).ToNot(BeNil())
+				Expect(ldapGroupMembers(h, "engineers")).To(HaveLen(2))
+			})
+
+			It("removes memberships that were removed in the directory", func() {
+				entry := directory.Entry("cn=engineers," + ldapGroupsDn)
+				directory.AddEntry(entry.Dn, map[string][]string{
+					"objectClass": entry.Attributes["objectClass"],
+					"cn":          entry.Attributes["cn"],
+					"member":      {ldapAliceDn},
+				})
+
+				response, err := sendAsSystem[*commands.SyncLdapProviderResponse](h, commands.SyncLdapProvider{
+					VirtualServerName: "test-vs",
+					LdapProviderId:    ldapProviderId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.MembershipsRemoved).To(Equal(1))
+				Expect(ldapGroupMembers(h, "engineers")).To(HaveLen(1))
+			})
+
+			It("rejects password changes while the provider is read-only", func() {
+				alice, _ := ldapUser(h, "alice")
+
+				_, err := sendAsSystem[*commands.SetPasswordResponse](h, commands.SetPassword{
+					UserId:      alice.Id(),
+					NewPassword: "changed-password",
+				})
+				Expect(err).To(MatchError(utils.ErrLdapProviderReadOnly))
+			})
+
+			It("writes password changes back when the provider is writable", func() {
+				_, err := sendAsSystem[*commands.PatchLdapProviderResponse](h, commands.PatchLdapProvider{
+					VirtualServerName: "test-vs",
+					LdapProviderId:    ldapProviderId,
+					EditMode:          utils.Ptr(repositories.LdapEditModeWritable),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				alice, _ := ldapUser(h, "alice")
+				_, err = sendAsSystem[*commands.SetPasswordResponse](h, commands.SetPassword{
+					UserId:      alice.Id(),
+					NewPassword: "changed-password",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(directory.Entry(ldapAliceDn).Attributes["userPassword"]).To(Equal([]string{"changed-password"}))
+
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), mintLoginToken(), "alice", "changed-password")
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	}
+}
+
+func seedLdapDirectory(directory *ldaptest.Server) {
+	directory.AddEntry(ldapServiceDn, map[string][]string{
+		"objectClass":  {"person"},
+		"userPassword": {ldapServiceSecret},
+	})
+	directory.AddEntry(ldapAliceDn, map[string][]string{
+		"objectClass":  {"inetOrgPerson"},
+		"entryUUID":    {"7d3f2b0a-1c5e-4e8f-9b6a-2f4c8d1e3a57"},
+		"uid":          {"alice"},
+		"mail":         {"alice@example.com"},
+		"cn":           {"Alice Example"},
+		"userPassword": {ldapAlicePassword},
+	})
+	directory.AddEntry(ldapBobDn, map[string][]string{
+		"objectClass":  {"inetOrgPerson"},
+		"entryUUID":    {"c2a9e4f1-5b7d-4a3c-8e6f-0d1b9a2c4e68"},
+		"uid":          {"bob"},
+		"mail":         {"bob@example.com"},
+		"cn":           {"Bob Example"},
+		"userPassword": {ldapBobPassword},
+	})
+	directory.AddEntry("cn=engineers,"+ldapGroupsDn, map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"engineers"},
+		"member":      {ldapAliceDn, ldapBobDn},
+	})
+}
+
+func sendAsSystem[TResponse any](h *harness, request any) (TResponse, error) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	return mediatr.Send[TResponse](ctx, m, request)
+}
+
+// ldapUser returns the test-vs user with the given username and its link to
+// the directory.
+func ldapUser(h *harness, username string) (*repositories.User, *repositories.Credential) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, repositories.NewVirtualServerFilter().Name("test-vs"))
+	Expect(err).ToNot(HaveOccurred())
+
+	userFilter := repositories.NewUserFilter().VirtualServerId(virtualServer.Id()).Username(username)
+	user, err := dbContext.Users().FirstOrNil(ctx, userFilter)
+	Expect(err).ToNot(HaveOccurred())
+	if user == nil {
+		return nil, nil
+	}
+
+	linkFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypeLdap)
+	link, err := dbContext.Credentials().FirstOrNil(ctx, linkFilter)
+	Expect(err).ToNot(HaveOccurred())
+
+	return user, link
+}
+
+func ldapGroupMembers(h *harness, groupName string) []*repositories.GroupMember {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	virtualServer, err := dbContext.VirtualServers().FirstOrErr(ctx, repositories.NewVirtualServerFilter().Name("test-vs"))
+	Expect(err).ToNot(HaveOccurred())
+
+	groupFilter := repositories.NewGroupFilter().VirtualServerId(virtualServer.Id()).Name(groupName)
+	group, err := dbContext.Groups().FirstOrErr(ctx, groupFilter)
+	Expect(err).ToNot(HaveOccurred())
+
+	members, err := dbContext.GroupMembers().List(ctx, repositories.NewGroupMemberFilter().GroupId(group.Id()))
+	Expect(err).ToNot(HaveOccurred())
+
+	return members
+}
+
+func setupLdapFederationFixtures(h *harness, directory *ldaptest.Server) (uuid.UUID, error) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: "test-vs",
+		Slug:              "ldap-project",
+		Name:              "LDAP Project",
+	}); err != nil {
+		return uuid.Nil, fmt.Errorf("creating project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	appResp, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName:      "test-vs",
+		ProjectSlug:            "ldap-project",
+		Name:                   ldapAppName,
+		DisplayName:            "LDAP App",
+		Type:                   repositories.ApplicationTypePublic,
+		RedirectUris:           []string{"http://localhost:9999/callback"},
+		PostLogoutRedirectUris: []string{},
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating application: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	if _, err := mediatr.Send[*commands.PatchApplicationResponse](ctx, m, commands.PatchApplication{
+		VirtualServerName: "test-vs",
+		ProjectSlug:       "ldap-project",
+		ApplicationId:     appResp.Id,
+		DeviceFlowEnabled: utils.Ptr(true),
+	}); err != nil {
+		return uuid.Nil, fmt.Errorf("enabling device flow: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	providerResp, err := mediatr.Send[*commands.CreateLdapProviderResponse](ctx, m, commands.CreateLdapProvider{
+		VirtualServerName: "test-vs",
+		Name:              "directory",
+		Vendor:            repositories.LdapVendorGeneric,
+		ConnectionUrl:     directory.Url(),
+		BindDn:            ldapServiceDn,
+		BindPassword:      ldapServiceSecret,
+		UsersDn:           ldapUsersDn,
+		GroupsDn:          utils.Ptr(ldapGroupsDn),
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("creating ldap provider: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	return providerResp.Id, nil
+}
@@ -0,0 +1,196 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	mfaRulesTotpUsername     = "test-mfa-rules-totp-user"
+	mfaRulesPasswordUsername = "test-mfa-rules-password-user"
+	mfaRulesPassword         = "correct-horse-battery-staple"
+	mfaRulesTotpSecret       = "KRSXG5CTMVRXEZLUKRSXG5CTMVRXEZLU"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("MFA rules ["+backend.name+"]", Ordered, func() {
+			var h *harness
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(seedMfaRulesUsers(h.Scope())).To(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			setRules := func(rules repositories.MfaRules) {
+				_, err := sendAsSystem[*commands.UpdateMfaRulesResponse](h, commands.UpdateMfaRules{
+					VirtualServerName: "test-vs",
+					Rules:             rules,
+				})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			// send posts to the login as the given browser, finish-login
+			// redirects to the application
+			send := func(loginToken string, path string, body string, userAgent string) *http.Response {
+				req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/logins/%s/%s", h.ApiUrl(), loginToken, path), strings.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("User-Agent", userAgent)
+
+				client := &http.Client{
+					CheckRedirect: func(*http.Request, []*http.Request) error {
+						return http.ErrUseLastResponse
+					},
+				}
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			post := func(loginToken string, path string, body string, userAgent string) {
+				Expect(send(loginToken, path, body, userAgent).StatusCode).To(Equal(http.StatusNoContent))
+			}
+
+			loginStep := func(username string, userAgent string) (string, string) {
+				loginToken := mintPasswordResetLoginToken(h)
+				post(loginToken, "verify-password", fmt.Sprintf(`{"username":%q,"password":%q}`, username, mfaRulesPassword), userAgent)
+
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				return loginToken, readJSON(resp)["step"].(string)
+			}
+
+			It("asks for the second factor the user has without rules", func() {
+				_, step := loginStep(mfaRulesTotpUsername, "browser-a")
+				Expect(step).To(Equal("verifyTotp"))
+			})
+
+			It("skips the second factor from a trusted network", func() {
+				setRules(repositories.MfaRules{
+					{Action: repositories.MfaRuleActionSkip, Cidrs: []string{"127.0.0.0/8", "::1/128"}},
+				})
+
+				_, step := loginStep(mfaRulesTotpUsername, "browser-a")
+				Expect(step).To(Equal("finish"))
+			})
+
+			It("ignores rules for other projects", func() {
+				setRules(repositories.MfaRules{
+					{Action: repositories.MfaRuleActionSkip, Projects: []string{"other-project"}},
+				})
+
+				_, step := loginStep(mfaRulesTotpUsername, "browser-a")
+				Expect(step).To(Equal("verifyTotp"))
+			})
+
+			It("requires onboarding for applications of a project", func() {
+				setRules(repositories.MfaRules{
+					{Action: repositories.MfaRuleActionRequire, Projects: []string{"lockout-project"}},
+				})
+
+				_, step := loginStep(mfaRulesPasswordUsername, "browser-a")
+				Expect(step).To(Equal("onboardTotp"))
+			})
+
+			It("requires the second factor only on new devices", func() {
+				setRules(repositories.MfaRules{
+					{Action: repositories.MfaRuleActionRequire, NewDevice: true},
+					{Action: repositories.MfaRuleActionSkip},
+				})
+
+				loginToken, step := loginStep(mfaRulesTotpUsername, "browser-a")
+				Expect(step).To(Equal("verifyTotp"))
+
+				code, err := totp.GenerateCode(mfaRulesTotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				post(loginToken, "verify-totp", fmt.Sprintf(`{"totpCode":%q}`, code), "browser-a")
+				Expect(send(loginToken, "finish-login", "", "browser-a").StatusCode).To(BeNumerically("<", http.StatusBadRequest))
+
+				_, step = loginStep(mfaRulesTotpUsername, "browser-a")
+				Expect(step).To(Equal("finish"))
+
+				_, step = loginStep(mfaRulesTotpUsername, "browser-b")
+				Expect(step).To(Equal("verifyTotp"))
+			})
+
+			It("returns the rules in order", func() {
+				response, err := sendAsSystem[*queries.GetMfaRulesResponse](h, queries.GetMfaRules{
+					VirtualServerName: "test-vs",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Rules).To(HaveLen(2))
+				Expect(response.Rules[0].NewDevice).To(BeTrue())
+				Expect(response.Rules[1].Action).To(Equal(repositories.MfaRuleActionSkip))
+			})
+		})
+	}
+}
+
+// seedMfaRulesUsers creates a user with a password and totp and a user with
+// only a password.
+func seedMfaRulesUsers(scope *ioc.DependencyProvider) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	for _, username := range []string{mfaRulesTotpUsername, mfaRulesPasswordUsername} {
+		err := seedUserWithPassword(ctx, m, dbContext, username, mfaRulesPassword)
+		if err != nil {
+			return err
+		}
+	}
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(mfaRulesTotpUsername))
+	if err != nil {
+		return fmt.Errorf("getting user %s: %w", mfaRulesTotpUsername, err)
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{
+		Secret:    mfaRulesTotpSecret,
+		Digits:    int(otp.DigitsSix),
+		Algorithm: int(otp.AlgorithmSHA1),
+	}))
+	return dbContext.SaveChanges(ctx)
+}
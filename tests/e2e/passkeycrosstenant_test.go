@@ -10,9 +10,11 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/The127/Keyline/config"
@@ -21,6 +23,7 @@ import (
 	"github.com/The127/Keyline/internal/database"
 	"github.com/The127/Keyline/internal/middlewares"
 	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/webauthn"
 
 	"github.com/The127/ioc"
 	"github.com/The127/mediatr"
@@ -76,6 +79,15 @@ func init() {
 				var err error
 				attackerKey, targetUserKey, err = setupPasskeyCrossTenantFixtures(h)
 				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
 			})
 
 			AfterAll(func() {
@@ -146,7 +158,7 @@ func init() {
 				clientDataBytes, err := json.Marshal(clientData)
 				Expect(err).ToNot(HaveOccurred())
 
-				authData := bytes.Repeat([]byte{0}, 37)
+				authData := passkeyTestAuthData(0)
 				cdHash := sha256.Sum256(clientDataBytes)
 				signed := append(append([]byte{}, authData...), cdHash[:]...)
 				signature := ed25519.Sign(key.privateKey, signed)
@@ -401,6 +413,19 @@ func generatePasskeyTestKey() (*passkeyTestKey, error) {
 	}, nil
 }
 
+// passkeyTestAuthData returns authenticator data for an assertion made for
+// the login UI with the user present.
+func passkeyTestAuthData(signCount uint32) []byte {
+	frontendUrl, err := url.Parse(passkeyFrontendOrigin)
+	Expect(err).ToNot(HaveOccurred())
+
+	authData := make([]byte, 37)
+	copy(authData, webauthn.RpIdHash(frontendUrl.Hostname()))
+	authData[32] = 0x01
+	binary.BigEndian.PutUint32(authData[33:], signCount)
+	return authData
+}
+
 func (k *passkeyTestKey) publicKeyDER() []byte {
 	der, err := x509.MarshalPKIXPublicKey(k.publicKey)
 	if err != nil {
@@ -0,0 +1,360 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	passkeyLifecycleUser     = "passkey-lifecycle-user"
+	passkeyLifecycleApp      = "passkey-lifecycle-app"
+	passkeyLifecycleURI      = "http://localhost:9101/passkey-callback"
+	passkeyLifecycleVerifier = "passkey-lifecycle-verifier-padding-padding-1234"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Passkey lifecycle ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userId uuid.UUID
+			var key *passkeyTestKey
+			var passkeyId uuid.UUID
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, serviceUserTokenSource)
+				config.C.Frontend.ExternalUrl = passkeyFrontendOrigin
+				var err error
+				userId, key, err = setupPasskeyLifecycleFixtures(h)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("lists the registered passkey", func() {
+				passkeys, err := h.Client().User().ListPasskeys(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passkeys.Items).To(HaveLen(1))
+				Expect(passkeys.Items[0].Name).To(Equal("Security key"))
+				Expect(passkeys.Items[0].LastUsedAt).To(BeNil())
+				passkeyId = passkeys.Items[0].Id
+			})
+
+			It("renames the passkey", func() {
+				err := h.Client().User().PatchPasskey(h.Ctx(), userId, passkeyId, api.PatchPasskeyRequestDto{
+					Name: utils.Ptr("Work laptop"),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				passkeys, err := h.Client().User().ListPasskeys(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passkeys.Items[0].Name).To(Equal("Work laptop"))
+			})
+
+			It("offers discoverable credentials when the user is not known yet", func() {
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 5, base64.RawURLEncoding.EncodeToString(userId[:]))
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				passkeys, err := h.Client().User().ListPasskeys(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passkeys.Items[0].SignCount).To(Equal(uint32(5)))
+				Expect(passkeys.Items[0].LastUsedAt).ToNot(BeNil())
+			})
+
+			It("rejects a user handle of another user", func() {
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				otherUser := uuid.New()
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 6, base64.RawURLEncoding.EncodeToString(otherUser[:]))
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("rejects a signature counter that did not increase", func() {
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 5, "")
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("rejects logins without user verification when the policy requires it", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:       h.VirtualServer(),
+					PasskeyUserVerification: utils.Ptr(repositories.PasskeyUserVerificationRequired),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 6, "")
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+				_, err = sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:       h.VirtualServer(),
+					PasskeyUserVerification: utils.Ptr(repositories.PasskeyUserVerificationPreferred),
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("rejects a second answer to the same challenge", func() {
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				otherUser := uuid.New()
+				body := startPasskeyLifecycleLogin(h, loginToken, key, 7, base64.RawURLEncoding.EncodeToString(otherUser[:]))
+				resp := postPasskeyLifecycleAssertion(h, loginToken, body)
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+				// the user handle is not signed, so the same assertion with
+				// the right one would pass if the challenge was still there
+				body = bytes.Replace(body, []byte(base64.RawURLEncoding.EncodeToString(otherUser[:])), []byte(base64.RawURLEncoding.EncodeToString(userId[:])), 1)
+				resp = postPasskeyLifecycleAssertion(h, loginToken, body)
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("rejects the passkey of a disabled user", func() {
+				setPasskeyLifecycleUserDisabled(h, userId, true)
+				DeferCleanup(func() {
+					setPasskeyLifecycleUserDisabled(h, userId, false)
+				})
+
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 8, base64.RawURLEncoding.EncodeToString(userId[:]))
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("deletes the passkey", func() {
+				err := h.Client().User().DeletePasskey(h.Ctx(), userId, passkeyId)
+				Expect(err).ToNot(HaveOccurred())
+
+				passkeys, err := h.Client().User().ListPasskeys(h.Ctx(), userId)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passkeys.Items).To(BeEmpty())
+
+				loginToken := mintPasskeyLifecycleLoginToken(h)
+				resp := finishPasskeyLifecycleLogin(h, loginToken, key, 7, "")
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).ToNot(Equal(http.StatusNoContent))
+			})
+		})
+	}
+}
+
+func setupPasskeyLifecycleFixtures(h *harness) (uuid.UUID, *passkeyTestKey, error) {
+	scope := h.Scope().NewScope()
+	defer scope.Close()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: h.VirtualServer(),
+		Slug:              "passkey-lifecycle-project",
+		Name:              "Passkey Lifecycle Project",
+	}); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, nil, err
+	}
+	if _, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName: h.VirtualServer(),
+		ProjectSlug:       "passkey-lifecycle-project",
+		Name:              passkeyLifecycleApp,
+		DisplayName:       "Passkey Lifecycle App",
+		Type:              repositories.ApplicationTypePublic,
+		RedirectUris:      []string{passkeyLifecycleURI},
+	}); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("app: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	userResp, err := mediatr.Send[*commands.CreateUserResponse](ctx, m, commands.CreateUser{
+		VirtualServerName: h.VirtualServer(),
+		DisplayName:       "Passkey Lifecycle User",
+		Username:          passkeyLifecycleUser,
+		Email:             passkeyLifecycleUser + "@test.local",
+		EmailVerified:     true,
+	})
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("user: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	key, err := generatePasskeyTestKey()
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	dbContext.Credentials().Insert(repositories.NewCredential(
+		userResp.Id,
+		&repositories.CredentialWebauthnDetails{
+			CredentialId:       key.credentialID,
+			PublicKeyAlgorithm: cosePublicKeyEd25519,
+			PublicKey:          key.publicKeyDER(),
+			Name:               "Security key",
+		},
+	))
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return userResp.Id, key, nil
+}
+
+// setPasskeyLifecycleUserDisabled disables or enables the user, there is
+// no command for it outside of SCIM.
+func setPasskeyLifecycleUserDisabled(h *harness, userId uuid.UUID, disabled bool) {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Id(userId))
+	Expect(err).ToNot(HaveOccurred())
+	user.SetDisabled(disabled)
+	dbContext.Users().Update(user)
+	Expect(dbContext.SaveChanges(ctx)).To(Succeed())
+}
+
+// mintPasskeyLifecycleLoginToken starts an authorization code flow and
+// returns the login token of the redirect to the login page.
+func mintPasskeyLifecycleLoginToken(h *harness) string {
+	httpClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	url := fmt.Sprintf(
+		"%s/oidc/%s/authorize?response_type=code&client_id=%s&"+
+			"redirect_uri=%s&scope=openid&state=s&nonce=n&"+
+			"code_challenge=%s&code_challenge_method=S256",
+		h.ApiUrl(), h.VirtualServer(), passkeyLifecycleApp, passkeyLifecycleURI,
+		authCodePkceChallenge(passkeyLifecycleVerifier),
+	)
+	resp, err := httpClient.Get(url)
+	Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+	loc := resp.Header.Get("Location")
+	idx := strings.Index(loc, "token=")
+	Expect(idx).ToNot(Equal(-1), "no login token in /authorize redirect: %s", loc)
+	token := loc[idx+len("token="):]
+	if amp := strings.Index(token, "&"); amp != -1 {
+		token = token[:amp]
+	}
+	return token
+}
+
+// finishPasskeyLifecycleLogin runs /passkey/start and /passkey/finish with
+// an assertion carrying the given signature counter and user handle.
+func finishPasskeyLifecycleLogin(h *harness, loginToken string, key *passkeyTestKey, signCount uint32, userHandle string) *http.Response {
+	return postPasskeyLifecycleAssertion(h, loginToken, startPasskeyLifecycleLogin(h, loginToken, key, signCount, userHandle))
+}
+
+// startPasskeyLifecycleLogin runs /passkey/start and returns the body of
+// the /passkey/finish request answering its challenge.
+func startPasskeyLifecycleLogin(h *harness, loginToken string, key *passkeyTestKey, signCount uint32, userHandle string) []byte {
+	startResp, err := http.Post(fmt.Sprintf("%s/logins/%s/passkey/start", h.ApiUrl(), loginToken), "", nil)
+	Expect(err).ToNot(HaveOccurred())
+	defer func() { _ = startResp.Body.Close() }()
+	Expect(startResp.StatusCode).To(Equal(http.StatusOK))
+
+	var startBody struct {
+		Id               uuid.UUID `json:"id"`
+		Challenge        string    `json:"challenge"`
+		AllowCredentials []string  `json:"allowCredentials"`
+	}
+	Expect(json.NewDecoder(startResp.Body).Decode(&startBody)).To(Succeed())
+	Expect(startBody.AllowCredentials).To(BeEmpty())
+
+	challengeBytes, err := base64.StdEncoding.DecodeString(startBody.Challenge)
+	Expect(err).ToNot(HaveOccurred())
+
+	clientDataBytes, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(challengeBytes),
+		"origin":    passkeyFrontendOrigin,
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	authData := passkeyTestAuthData(signCount)
+	cdHash := sha256.Sum256(clientDataBytes)
+	signature := ed25519.Sign(key.privateKey, append(append([]byte{}, authData...), cdHash[:]...))
+
+	bodyBytes, err := json.Marshal(map[string]any{
+		"id": startBody.Id,
+		"webauthnResponse": map[string]any{
+			"id":    key.credentialID,
+			"rawId": key.credentialID,
+			"response": map[string]any{
+				"clientDataJSON":    base64.StdEncoding.EncodeToString(clientDataBytes),
+				"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+				"signature":         base64.RawURLEncoding.EncodeToString(signature),
+				"userHandle":        userHandle,
+			},
+			"type": "public-key",
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return bodyBytes
+}
+
+func postPasskeyLifecycleAssertion(h *harness, loginToken string, bodyBytes []byte) *http.Response {
+	resp, err := http.Post(
+		fmt.Sprintf("%s/logins/%s/passkey/finish", h.ApiUrl(), loginToken),
+		"application/json",
+		bytes.NewReader(bodyBytes),
+	)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
@@ -0,0 +1,356 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	passkey2faApp        = "passkey-2fa-app"
+	passkey2faURI        = "http://localhost:9102/passkey-callback"
+	passkey2faVerifier   = "passkey-2fa-verifier-padding-padding-padding-1234"
+	passkey2faUser       = "passkey-2fa-user"
+	passkey2faTotpUser   = "passkey-2fa-totp-user"
+	passkey2faPassword   = "correct-horse-battery-staple"
+	passkey2faTotpSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Passkey as second factor ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userKey *passkeyTestKey
+			var totpUserKey *passkeyTestKey
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				config.C.Frontend.ExternalUrl = passkeyFrontendOrigin
+				var err error
+				userKey, totpUserKey, err = setupPasskeySecondFactorFixtures(h)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("asks for the passkey after the password", func() {
+				loginToken := mintPasskeySecondFactorLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, passkey2faUser, passkey2faPassword)).To(Succeed())
+
+				state := getPasskeySecondFactorLoginState(h, loginToken)
+				Expect(state.Step).To(Equal("verifyPasskey"))
+				Expect(state.SecondFactors).To(Equal([]string{"passkey"}))
+
+				resp := finishPasskeySecondFactor(h, loginToken, userKey, 1)
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("finish"))
+			})
+
+			It("rejects the passkey of another user", func() {
+				loginToken := mintPasskeySecondFactorLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, passkey2faUser, passkey2faPassword)).To(Succeed())
+
+				resp := finishPasskeySecondFactor(h, loginToken, totpUserKey, 1)
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("verifyPasskey"))
+			})
+
+			It("rejects a factor the user has not set up", func() {
+				loginToken := mintPasskeySecondFactorLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, passkey2faUser, passkey2faPassword)).To(Succeed())
+
+				resp := selectPasskeySecondFactor(h, loginToken, "totp")
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+
+			It("lets users with several factors pick one", func() {
+				loginToken := mintPasskeySecondFactorLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, passkey2faTotpUser, passkey2faPassword)).To(Succeed())
+
+				state := getPasskeySecondFactorLoginState(h, loginToken)
+				Expect(state.Step).To(Equal("selectSecondFactor"))
+				Expect(state.SecondFactors).To(ConsistOf("totp", "passkey"))
+
+				resp := selectPasskeySecondFactor(h, loginToken, "totp")
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("verifyTotp"))
+
+				// the security key turns up after all
+				resp = selectPasskeySecondFactor(h, loginToken, "passkey")
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("verifyPasskey"))
+
+				resp = finishPasskeySecondFactor(h, loginToken, totpUserKey, 1)
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("finish"))
+			})
+
+			It("still accepts TOTP from users with several factors", func() {
+				loginToken := mintPasskeySecondFactorLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, passkey2faTotpUser, passkey2faPassword)).To(Succeed())
+
+				resp := selectPasskeySecondFactor(h, loginToken, "totp")
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				code, err := totp.GenerateCode(passkey2faTotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				body, err := json.Marshal(map[string]string{"totpCode": code})
+				Expect(err).ToNot(HaveOccurred())
+				resp, err = http.Post(fmt.Sprintf("%s/logins/%s/verify-totp", h.ApiUrl(), loginToken), "application/json", bytes.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(getPasskeySecondFactorLoginState(h, loginToken).Step).To(Equal("finish"))
+			})
+		})
+	}
+}
+
+func setupPasskeySecondFactorFixtures(h *harness) (*passkeyTestKey, *passkeyTestKey, error) {
+	scope := h.Scope().NewScope()
+	defer scope.Close()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+	m := ioc.GetDependency[mediatr.Mediator](scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: h.VirtualServer(),
+		Slug:              "passkey-2fa-project",
+		Name:              "Passkey 2FA Project",
+	}); err != nil {
+		return nil, nil, fmt.Errorf("project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return nil, nil, err
+	}
+	if _, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName: h.VirtualServer(),
+		ProjectSlug:       "passkey-2fa-project",
+		Name:              passkey2faApp,
+		DisplayName:       "Passkey 2FA App",
+		Type:              repositories.ApplicationTypePublic,
+		RedirectUris:      []string{passkey2faURI},
+	}); err != nil {
+		return nil, nil, fmt.Errorf("app: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	createUser := func(username string) (uuid.UUID, *passkeyTestKey, error) {
+		userResp, err := mediatr.Send[*commands.CreateUserResponse](ctx, m, commands.CreateUser{
+			VirtualServerName: h.VirtualServer(),
+			DisplayName:       "Display " + username,
+			Username:          username,
+			Email:             username + "@test.local",
+			EmailVerified:     true,
+		})
+		if err != nil {
+			return uuid.Nil, nil, fmt.Errorf("user %s: %w", username, err)
+		}
+		if err := dbContext.SaveChanges(ctx); err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		key, err := generatePasskeyTestKey()
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		dbContext.Credentials().Insert(repositories.NewCredential(userResp.Id, &repositories.CredentialPasswordDetails{
+			HashedPassword: utils.HashPassword(passkey2faPassword),
+		}))
+		dbContext.Credentials().Insert(repositories.NewCredential(userResp.Id, &repositories.CredentialWebauthnDetails{
+			CredentialId:       key.credentialID,
+			PublicKeyAlgorithm: cosePublicKeyEd25519,
+			PublicKey:          key.publicKeyDER(),
+			Name:               "Security key",
+		}))
+		if err := dbContext.SaveChanges(ctx); err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		return userResp.Id, key, nil
+	}
+
+	_, userKey, err := createUser(passkey2faUser)
+	if err != nil {
+		return nil, nil, err
+	}
+	totpUserId, totpUserKey, err := createUser(passkey2faTotpUser)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(totpUserId, &repositories.CredentialTotpDetails{
+		Secret:    passkey2faTotpSecret,
+		Digits:    int(otp.DigitsSix),
+		Algorithm: int(otp.AlgorithmSHA1),
+	}))
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return userKey, totpUserKey, nil
+}
+
+func mintPasskeySecondFactorLoginToken(h *harness) string {
+	httpClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	url := fmt.Sprintf(
+		"%s/oidc/%s/authorize?response_type=code&client_id=%s&"+
+			"redirect_uri=%s&scope=openid&state=s&nonce=n&"+
+			"code_challenge=%s&code_challenge_method=S256",
+		h.ApiUrl(), h.VirtualServer(), passkey2faApp, passkey2faURI,
+		authCodePkceChallenge(passkey2faVerifier),
+	)
+	resp, err := httpClient.Get(url)
+	Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+	loc := resp.Header.Get("Location")
+	idx := strings.Index(loc, "token=")
+	Expect(idx).ToNot(Equal(-1), "no login token in /authorize redirect: %s", loc)
+	token := loc[idx+len("token="):]
+	if amp := strings.Index(token, "&"); amp != -1 {
+		token = token[:amp]
+	}
+	return token
+}
+
+type passkeySecondFactorLoginState struct {
+	Step          string   `json:"step"`
+	SecondFactors []string `json:"secondFactors"`
+}
+
+func getPasskeySecondFactorLoginState(h *harness, loginToken string) passkeySecondFactorLoginState {
+	resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	var state passkeySecondFactorLoginState
+	Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+	return state
+}
+
+func selectPasskeySecondFactor(h *harness, loginToken string, secondFactor string) *http.Response {
+	body, err := json.Marshal(map[string]string{"secondFactor": secondFactor})
+	Expect(err).ToNot(HaveOccurred())
+
+	resp, err := http.Post(
+		fmt.Sprintf("%s/logins/%s/select-second-factor", h.ApiUrl(), loginToken),
+		"application/json",
+		bytes.NewReader(body),
+	)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
+
+// finishPasskeySecondFactor runs /passkey/start and /passkey/finish for a
+// login whose user is already known from the password.
+func finishPasskeySecondFactor(h *harness, loginToken string, key *passkeyTestKey, signCount uint32) *http.Response {
+	startResp, err := http.Post(fmt.Sprintf("%s/logins/%s/passkey/start", h.ApiUrl(), loginToken), "", nil)
+	Expect(err).ToNot(HaveOccurred())
+	defer func() { _ = startResp.Body.Close() }()
+	Expect(startResp.StatusCode).To(Equal(http.StatusOK))
+
+	var startBody struct {
+		Id               uuid.UUID `json:"id"`
+		Challenge        string    `json:"challenge"`
+		AllowCredentials []string  `json:"allowCredentials"`
+	}
+	Expect(json.NewDecoder(startResp.Body).Decode(&startBody)).To(Succeed())
+	Expect(startBody.AllowCredentials).ToNot(BeEmpty())
+
+	challengeBytes, err := base64.StdEncoding.DecodeString(startBody.Challenge)
+	Expect(err).ToNot(HaveOccurred())
+
+	clientDataBytes, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(challengeBytes),
+		"origin":    passkeyFrontendOrigin,
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	authData := passkeyTestAuthData(signCount)
+	cdHash := sha256.Sum256(clientDataBytes)
+	signature := ed25519.Sign(key.privateKey, append(append([]byte{}, authData...), cdHash[:]...))
+
+	bodyBytes, err := json.Marshal(map[string]any{
+		"id": startBody.Id,
+		"webauthnResponse": map[string]any{
+			"id":    key.credentialID,
+			"rawId": key.credentialID,
+			"response": map[string]any{
+				"clientDataJSON":    base64.StdEncoding.EncodeToString(clientDataBytes),
+				"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+				"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			},
+			"type": "public-key",
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	resp, err := http.Post(
+		fmt.Sprintf("%s/logins/%s/passkey/finish", h.ApiUrl(), loginToken),
+		"application/json",
+		bytes.NewReader(bodyBytes),
+	)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
@@ -0,0 +1,159 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	passwordResetAppName  = "test-password-reset-app"
+	passwordResetUsername = "test-password-reset-user"
+	passwordResetPassword = "correct-horse-battery-staple"
+	passwordResetNew      = "another-correct-horse-battery"
+)
+
+var passwordResetTokenPattern = regexp.MustCompile(`reset-password\?token=([A-Za-z0-9_=-]+)`)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Forgot password ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var resetToken string
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			post := func(path string, body any) int {
+				jsonBytes, err := json.Marshal(body)
+				Expect(err).ToNot(HaveOccurred())
+
+				url := fmt.Sprintf("%s/api/virtual-servers/test-vs/users/%s", h.ApiUrl(), path)
+				resp, err := http.Post(url, "application/json", bytes.NewReader(jsonBytes))
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				return resp.StatusCode
+			}
+
+			It("answers the same for unknown accounts and sends nothing", func() {
+				before := passwordResetMails(h)
+
+				status := post("forgot-password", map[string]string{"usernameOrEmail": "nobody@test.local"})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				Expect(passwordResetMails(h)).To(HaveLen(len(before)))
+			})
+
+			It("mails a reset link to the user found by email", func() {
+				status := post("forgot-password", map[string]string{"usernameOrEmail": lockoutUserUsername + "@test.local"})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				mails := passwordResetMails(h)
+				Expect(mails).ToNot(BeEmpty())
+				mail := mails[len(mails)-1]
+				Expect(mail.To).To(Equal(lockoutUserUsername + "@test.local"))
+
+				match := passwordResetTokenPattern.FindStringSubmatch(mail.Body)
+				Expect(match).To(HaveLen(2))
+				resetToken = match[1]
+			})
+
+			It("rejects a password that fails the password policy", func() {
+				status := post("reset-password", map[string]string{"token": resetToken, "newPassword": "password"})
+				Expect(status).To(Equal(http.StatusBadRequest))
+			})
+
+			It("sets the new password with the token", func() {
+				status := post("reset-password", map[string]string{"token": resetToken, "newPassword": passwordResetNew})
+				Expect(status).To(Equal(http.StatusNoContent))
+
+				loginToken := mintPasswordResetLoginToken(h)
+				err := h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, lockoutUserUsername, lockoutUserPassword)
+				Expect(err).To(HaveOccurred())
+
+				loginToken = mintPasswordResetLoginToken(h)
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, lockoutUserUsername, passwordResetNew)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("accepts the token only once", func() {
+				status := post("reset-password", map[string]string{"token": resetToken, "newPassword": "yet-another-horse-battery"})
+				Expect(status).To(Equal(http.StatusBadRequest))
+			})
+		})
+	}
+}
+
+func mintPasswordResetLoginToken(h *harness) string {
+	deviceResp, err := h.Client().Oidc().BeginDeviceFlow(h.Ctx(), lockoutAppName, "openid")
+	Expect(err).ToNot(HaveOccurred())
+	loginToken, err := h.Client().Oidc().PostActivate(h.Ctx(), deviceResp.UserCode)
+	Expect(err).ToNot(HaveOccurred())
+	return loginToken
+}
+
+// passwordResetMails returns the queued password reset mails in the order
+// they were queued.
+func passwordResetMails(h *harness) []messages.SendEmailMessage {
+	return queuedMails(h, "Password reset")
+}
+
+// queuedMails returns the queued mails with the given subject in the order
+// they were queued.
+func queuedMails(h *harness, subject string) []messages.SendEmailMessage {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	outboxMessages, err := dbContext.OutboxMessages().List(ctx, repositories.NewOutboxMessageFilter())
+	Expect(err).ToNot(HaveOccurred())
+
+	var mails []messages.SendEmailMessage
+	for _, outboxMessage := range outboxMessages {
+		var mail messages.SendEmailMessage
+		if json.Unmarshal(outboxMessage.Details(), &mail) != nil || mail.Subject != subject {
+			continue
+		}
+		mails = append(mails, mail)
+	}
+	return mails
+}
@@ -0,0 +1,154 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	passwordRotationUsername = "test-password-rotation-user"
+	passwordRotationPassword = "correct-horse-battery-staple"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Password rotation ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			now := time.Now()
+			password := passwordRotationPassword
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(seedPasswordRotationUser(h.Scope())).To(Succeed())
+
+				_, err = sendAsSystem[*commands.CreatePasswordRuleResponse](h, commands.CreatePasswordRule{
+					VirtualServerName: "test-vs",
+					Type:              repositories.PasswordRuleTypeHistory,
+					Details:           map[string]any{"count": 2},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = sendAsSystem[*commands.CreatePasswordRuleResponse](h, commands.CreatePasswordRule{
+					VirtualServerName: "test-vs",
+					Type:              repositories.PasswordRuleTypeMaxAge,
+					Details:           map[string]any{"maxAgeDays": 30},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.SetTime(time.Now())
+					h.Close()
+				}
+			})
+
+			post := func(loginToken string, path string, body string) int {
+				resp, err := http.Post(fmt.Sprintf("%s/logins/%s/%s", h.ApiUrl(), loginToken, path), "application/json", strings.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				return resp.StatusCode
+			}
+
+			loginState := func(loginToken string) map[string]any {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				return readJSON(resp)
+			}
+
+			login := func() string {
+				loginToken := mintPasswordResetLoginToken(h)
+				status := post(loginToken, "verify-password", fmt.Sprintf(`{"username":%q,"password":%q}`, passwordRotationUsername, password))
+				Expect(status).To(Equal(http.StatusNoContent))
+				return loginToken
+			}
+
+			It("does not ask for a new password before it expires", func() {
+				h.SetTime(now.AddDate(0, 0, 29))
+
+				state := loginState(login())
+				Expect(state["step"]).To(Equal("finish"))
+				Expect(state["passwordExpired"]).To(BeFalse())
+			})
+
+			It("asks for a new password once it expired", func() {
+				h.SetTime(now.AddDate(0, 0, 31))
+
+				state := loginState(login())
+				Expect(state["step"]).To(Equal("temporaryPassword"))
+				Expect(state["passwordExpired"]).To(BeTrue())
+			})
+
+			It("rejects the current password as the new one", func() {
+				loginToken := login()
+
+				status := post(loginToken, "reset-temporary-password", fmt.Sprintf(`{"newPassword":%q}`, password))
+				Expect(status).To(Equal(http.StatusBadRequest))
+				Expect(loginState(loginToken)["step"]).To(Equal("temporaryPassword"))
+			})
+
+			It("continues the login with a new password", func() {
+				loginToken := login()
+
+				password = "a-brand-new-password-1"
+				status := post(loginToken, "reset-temporary-password", fmt.Sprintf(`{"newPassword":%q}`, password))
+				Expect(status).To(Equal(http.StatusNoContent))
+				Expect(loginState(loginToken)["step"]).To(Equal("finish"))
+
+				Expect(loginState(login())["step"]).To(Equal("finish"))
+			})
+
+			It("rejects the previous password once the new one expired", func() {
+				h.SetTime(now.AddDate(0, 0, 62))
+				loginToken := login()
+				Expect(loginState(loginToken)["step"]).To(Equal("temporaryPassword"))
+
+				status := post(loginToken, "reset-temporary-password", fmt.Sprintf(`{"newPassword":%q}`, passwordRotationPassword))
+				Expect(status).To(Equal(http.StatusBadRequest))
+
+				status = post(loginToken, "reset-temporary-password", `{"newPassword":"yet-another-password-2"}`)
+				Expect(status).To(Equal(http.StatusNoContent))
+			})
+		})
+	}
+}
+
+// seedPasswordRotationUser creates a user with a password.
+func seedPasswordRotationUser(scope *ioc.DependencyProvider) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	return seedUserWithPassword(ctx, m, dbContext, passwordRotationUsername, passwordRotationPassword)
+}
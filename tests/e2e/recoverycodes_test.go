@@ -0,0 +1,283 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/handlers"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	recoveryCodeUsername        = "test-recovery-code-user"
+	recoveryCodeOnboardUsername = "test-recovery-code-onboard-user"
+	recoveryCodePassword        = "correct-horse-battery-staple"
+	recoveryCodeTotpSecret      = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("TOTP recovery codes ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userId uuid.UUID
+			var recoveryCodes []string
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				userId, err = seedRecoveryCodeUsers(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				response, err := sendAsSystem[*commands.RegenerateRecoveryCodesResponse](h, commands.RegenerateRecoveryCodes{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				recoveryCodes = response.RecoveryCodes
+				Expect(recoveryCodes).To(HaveLen(commands.RecoveryCodeCount))
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			post := func(loginToken string, path string, body any) *http.Response {
+				jsonBytes, err := json.Marshal(body)
+				Expect(err).ToNot(HaveOccurred())
+
+				url := fmt.Sprintf("%s/logins/%s/%s", h.ApiUrl(), loginToken, path)
+				resp, err := http.Post(url, "application/json", bytes.NewReader(jsonBytes))
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			loginState := func(loginToken string) handlers.GetLoginStateResponseDto {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var state handlers.GetLoginStateResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+				return state
+			}
+
+			loginWithPassword := func(username string) string {
+				loginToken := mintPasswordResetLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, username, recoveryCodePassword)).To(Succeed())
+				return loginToken
+			}
+
+			It("signs in with a recovery code in place of the totp code", func() {
+				loginToken := loginWithPassword(recoveryCodeUsername)
+				Expect(loginState(loginToken).Step).To(Equal("verifyTotp"))
+
+				// codes are accepted no matter how they are typed
+				recoveryCode := strings.ToLower(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+				resp := post(loginToken, "verify-totp", map[string]string{"recoveryCode": recoveryCode})
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				Expect(loginState(loginToken).Step).To(Equal("finish"))
+				Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+			})
+
+			It("writes an audit event without the code", func() {
+				auditLogs := recoveryCodeAuditLogs(h, userId)
+				Expect(auditLogs).To(HaveLen(1))
+				Expect(auditLogs[0].Request()).ToNot(ContainSubstring(recoveryCodes[0]))
+				Expect(*auditLogs[0].Response()).To(ContainSubstring(fmt.Sprintf("%d", commands.RecoveryCodeCount-1)))
+			})
+
+			It("accepts each recovery code only once", func() {
+				loginToken := loginWithPassword(recoveryCodeUsername)
+
+				resp := post(loginToken, "verify-totp", map[string]string{"recoveryCode": recoveryCodes[0]})
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(loginState(loginToken).Step).To(Equal("verifyTotp"))
+
+				resp = post(loginToken, "verify-totp", map[string]string{"recoveryCode": recoveryCodes[1]})
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			})
+
+			It("locks the user after too many wrong recovery codes", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:        "test-vs",
+					LockoutMaxFailedAttempts: utils.Ptr(2),
+				})
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(func() {
+					_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+						VirtualServerName:        "test-vs",
+						LockoutMaxFailedAttempts: utils.Ptr(0),
+					})
+					Expect(err).ToNot(HaveOccurred())
+					_, err = sendAsSystem[*commands.UnlockUserResponse](h, commands.UnlockUser{
+						VirtualServerName: "test-vs",
+						UserId:            userId,
+					})
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				loginToken := loginWithPassword(recoveryCodeUsername)
+				for range 2 {
+					resp := post(loginToken, "verify-totp", map[string]string{"recoveryCode": "WRONG-CODE"})
+					Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				}
+
+				resp := post(loginToken, "verify-totp", map[string]string{"recoveryCode": recoveryCodes[2]})
+				Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			})
+
+			It("keeps counting wrong totp codes across correct passwords", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName:        "test-vs",
+					LockoutMaxFailedAttempts: utils.Ptr(2),
+				})
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(func() {
+					_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+						VirtualServerName:        "test-vs",
+						LockoutMaxFailedAttempts: utils.Ptr(0),
+					})
+					Expect(err).ToNot(HaveOccurred())
+					_, err = sendAsSystem[*commands.UnlockUserResponse](h, commands.UnlockUser{
+						VirtualServerName: "test-vs",
+						UserId:            userId,
+					})
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				// the password is right every time, only the second factor
+				// is guessed
+				for range 2 {
+					loginToken := loginWithPassword(recoveryCodeUsername)
+					resp := post(loginToken, "verify-totp", map[string]string{"totpCode": "abcdef"})
+					Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				}
+
+				loginToken := mintPasswordResetLoginToken(h)
+				err = h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, recoveryCodeUsername, recoveryCodePassword)
+				Expect(err).To(MatchError("invalid credentials"))
+			})
+
+			It("returns new recovery codes when totp is onboarded", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: "test-vs",
+					Require2fa:        utils.Ptr(true),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				loginToken := loginWithPassword(recoveryCodeOnboardUsername)
+				state := loginState(loginToken)
+				Expect(state.Step).To(Equal("onboardTotp"))
+
+				code, err := totp.GenerateCode(state.TotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+
+				resp := post(loginToken, "onboard-totp", map[string]string{"totpCode": code})
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var onboardResponse handlers.OnboardTotpResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&onboardResponse)).To(Succeed())
+				Expect(onboardResponse.RecoveryCodes).To(HaveLen(commands.RecoveryCodeCount))
+				Expect(loginState(loginToken).Step).To(Equal("finish"))
+			})
+		})
+	}
+}
+
+// recoveryCodeAuditLogs returns the audit events of recovery codes the
+// user consumed.
+func recoveryCodeAuditLogs(h *harness, userId uuid.UUID) []*repositories.AuditLog {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	auditLogs, _, err := dbContext.AuditLogs().List(ctx, repositories.NewAuditLogFilter().UserId(userId))
+	Expect(err).ToNot(HaveOccurred())
+
+	var consumed []*repositories.AuditLog
+	for _, auditLog := range auditLogs {
+		if auditLog.RequestType() == "ConsumeRecoveryCode" {
+			consumed = append(consumed, auditLog)
+		}
+	}
+	return consumed
+}
+
+// seedRecoveryCodeUsers creates a user with totp and one without, it
+// returns the id of the one with totp.
+func seedRecoveryCodeUsers(scope *ioc.DependencyProvider) (uuid.UUID, error) {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	err := seedUserWithPassword(ctx, m, dbContext, recoveryCodeUsername, recoveryCodePassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = seedUserWithPassword(ctx, m, dbContext, recoveryCodeOnboardUsername, recoveryCodePassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(recoveryCodeUsername))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting user %s: %w", recoveryCodeUsername, err)
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{
+		Secret:    recoveryCodeTotpSecret,
+		Digits:    int(otp.DigitsSix),
+		Algorithm: int(otp.AlgorithmSHA1),
+	}))
+	return user.Id(), dbContext.SaveChanges(ctx)
+}
@@ -0,0 +1,330 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/internal/services/outbox"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/go-clock"
+	"github.com/The127/ioc"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// End-to-end test of outbound SCIM provisioning against an in-process
+// service provider.
+
+const (
+	provisioningProjectSlug = "provisioning-project"
+	provisioningRoleName    = "provisioning-editor"
+	provisioningToken       = "downstream-token"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("SCIM outbound provisioning ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var provider *fakeScimProvider
+			var applicationId uuid.UUID
+			var roleId uuid.UUID
+			var userId uuid.UUID
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				provider = newFakeScimProvider()
+
+				_, err := sendAsSystem[*commands.CreateProjectResponse](h, commands.CreateProject{
+					VirtualServerName: h.VirtualServer(),
+					Slug:              provisioningProjectSlug,
+					Name:              "Provisioning",
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				role, err := sendAsSystem[*commands.CreateRoleResponse](h, commands.CreateRole{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					Name:              provisioningRoleName,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				roleId = role.Id
+
+				application, err := sendAsSystem[*commands.CreateApplicationResponse](h, commands.CreateApplication{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					Name:              "provisioned-app",
+					DisplayName:       "Provisioned App",
+					Type:              repositories.ApplicationTypePublic,
+					RedirectUris:      []string{"http://localhost/callback"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				applicationId = application.Id
+			})
+
+			AfterAll(func() {
+				if provider != nil {
+					provider.Close()
+				}
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("rejects a mapping without the username", func() {
+				_, err := sendAsSystem[*commands.SetProvisioningTargetResponse](h, commands.SetProvisioningTarget{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					ApplicationId:     applicationId,
+					Url:               provider.URL,
+					AuthType:          repositories.ProvisioningAuthTypeBearer,
+					AuthSecret:        utils.Ptr(provisioningToken),
+					AttributeMapping: repositories.ProvisioningAttributeMapping{
+						"displayName": repositories.ProvisioningSourceDisplayName,
+					},
+					Enabled: true,
+				})
+				Expect(err).To(MatchError(utils.ErrHttpBadRequest))
+			})
+
+			It("configures the provisioning target", func() {
+				response, err := sendAsSystem[*commands.SetProvisioningTargetResponse](h, commands.SetProvisioningTarget{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					ApplicationId:     applicationId,
+					Url:               provider.URL,
+					AuthType:          repositories.ProvisioningAuthTypeBearer,
+					AuthSecret:        utils.Ptr(provisioningToken),
+					Enabled:           true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Created).To(BeTrue())
+			})
+
+			It("does not push users without a role in the project", func() {
+				user, err := sendAsSystem[*commands.CreateUserResponse](h, commands.CreateUser{
+					VirtualServerName: h.VirtualServer(),
+					Username:          "provisioned-alice",
+					DisplayName:       "Alice",
+					Email:             "alice@example.com",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				userId = user.Id
+
+				Expect(deliverOutboxMessages(h)).To(Equal(1))
+				Expect(provider.Users()).To(BeEmpty())
+			})
+
+			It("pushes a user once they are assigned a role", func() {
+				_, err := sendAsSystem[*commands.AssignRoleToUserResponse](h, commands.AssignRoleToUser{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					UserId:            userId,
+					RoleId:            roleId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deliverOutboxMessages(h)).To(Equal(1))
+
+				users := provider.Users()
+				Expect(users).To(HaveLen(1))
+				Expect(users[0]["externalId"]).To(Equal(userId.String()))
+				Expect(users[0]["userName"]).To(Equal("provisioned-alice"))
+				Expect(users[0]["active"]).To(BeTrue())
+				Expect(users[0]["roles"]).To(ConsistOf(HaveKeyWithValue("value", provisioningRoleName)))
+			})
+
+			It("replaces the user when it changes", func() {
+				_, err := sendAsSystem[*commands.PatchUserResponse](h, commands.PatchUser{
+					VirtualServerName: h.VirtualServer(),
+					UserId:            userId,
+					DisplayName:       utils.Ptr("Alice Example"),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deliverOutboxMessages(h)).To(Equal(1))
+
+				users := provider.Users()
+				Expect(users).To(HaveLen(1))
+				Expect(users[0]["displayName"]).To(Equal("Alice Example"))
+			})
+
+			It("records failed deliveries and retries them later", func() {
+				provider.SetFailing(true)
+
+				response, err := sendAsSystem[*commands.ResyncProvisioningTargetResponse](h, commands.ResyncProvisioningTarget{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					ApplicationId:     applicationId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				// every user of the virtual server is queued, including the
+				// initial admin
+				Expect(response.QueuedUsers).To(BeNumerically(">=", 1))
+
+				Expect(deliverOutboxMessages(h)).To(Equal(response.QueuedUsers))
+
+				targets, err := sendAsSystem[*queries.ListProvisioningTargetsResponse](h, queries.ListProvisioningTargets{
+					VirtualServerName: h.VirtualServer(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets.Items).To(HaveLen(1))
+				Expect(targets.Items[0].LastError).ToNot(BeNil())
+				Expect(*targets.Items[0].LastError).To(ContainSubstring("Service Unavailable"))
+
+				// the message is not due yet
+				provider.SetFailing(false)
+				Expect(deliverOutboxMessages(h)).To(Equal(0))
+
+				h.SetTime(time.Now().Add(time.Hour))
+				Expect(deliverOutboxMessages(h)).To(Equal(response.QueuedUsers))
+
+				target, err := sendAsSystem[*queries.GetProvisioningTargetResponse](h, queries.GetProvisioningTarget{
+					VirtualServerName: h.VirtualServer(),
+					ProjectSlug:       provisioningProjectSlug,
+					ApplicationId:     applicationId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(target.LastError).To(BeNil())
+				Expect(target.LastSyncAt).ToNot(BeNil())
+			})
+		})
+	}
+}
+
+// deliverOutboxMessages hands the due provisioning messages to the
+// in-process broker like the outbox job does, as the harness does not deliver
+// them. It returns the number of messages attempted.
+func deliverOutboxMessages(h *harness) int {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+	now := ioc.GetDependency[clock.Service](scope).Now()
+
+	messages, err := dbContext.OutboxMessages().List(ctx, repositories.NewOutboxMessageFilter().DueAt(now))
+	Expect(err).ToNot(HaveOccurred())
+
+	attempted := 0
+	broker := outbox.NewMessageBroker()
+	for _, message := range messages {
+		if message.Type() != repositories.ScimProvisionOutboxMessageType {
+			continue
+		}
+		attempted++
+
+		err = broker.Distribute(ctx, message)
+		if err != nil {
+			message.RecordFailure(err, now)
+			dbContext.OutboxMessages().Update(message)
+		} else {
+			dbContext.OutboxMessages().Delete(message.Id())
+		}
+		Expect(dbContext.SaveChanges(ctx)).To(Succeed())
+	}
+
+	return attempted
+}
+
+// fakeScimProvider is a minimal SCIM service provider that keeps its users
+// in memory and only understands externalId filters.
+type fakeScimProvider struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	users   map[string]map[string]any
+	failing bool
+}
+
+func newFakeScimProvider() *fakeScimProvider {
+	provider := &fakeScimProvider{
+		users: map[string]map[string]any{},
+	}
+	provider.Server = httptest.NewServer(http.HandlerFunc(provider.serveHTTP))
+	return provider
+}
+
+func (p *fakeScimProvider) SetFailing(failing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing = failing
+}
+
+func (p *fakeScimProvider) Users() []map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	users := make([]map[string]any, 0, len(p.users))
+	for _, user := range p.users {
+		users = append(users, user)
+	}
+	return users
+}
+
+func (p *fakeScimProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+provisioningToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if p.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/Users/")
+
+	switch {
+	case r.Method == http.MethodGet:
+		var resources []map[string]any
+		_, externalId, _ := strings.Cut(r.URL.Query().Get("filter"), " eq ")
+		for _, user := range p.users {
+			if `"`+user["externalId"].(string)+`"` == externalId {
+				resources = append(resources, map[string]any{"id": user["id"]})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"totalResults": len(resources),
+			"Resources":    resources,
+		})
+
+	case r.Method == http.MethodPost:
+		var user map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&user)
+		user["id"] = uuid.New().String()
+		p.users[user["id"].(string)] = user
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(user)
+
+	case r.Method == http.MethodPut:
+		var user map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&user)
+		p.users[id] = user
+		_ = json.NewEncoder(w).Encode(user)
+
+	case r.Method == http.MethodDelete:
+		delete(p.users, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -26,6 +26,7 @@ func init() {
 			var h *harness
 			const multiAlgVS = "multi-alg-vs"
 			const patchTestVS = "patch-alg-vs"
+			const ecAlgVS = "ec-alg-vs"
 
 			BeforeAll(func() {
 				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
@@ -55,6 +56,17 @@ func init() {
 				})
 				Expect(err).ToNot(HaveOccurred())
 
+				_, err = mediatr.Send[*commands.CreateVirtualServerResponse](ctx, m, commands.CreateVirtualServer{
+					Name:                    ecAlgVS,
+					DisplayName:             "EC Algorithm VS",
+					PrimarySigningAlgorithm: config.SigningAlgorithmES256,
+					AdditionalSigningAlgorithms: []config.SigningAlgorithm{
+						config.SigningAlgorithmES512,
+						config.SigningAlgorithmPS256,
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
 				Expect(dbCtx.SaveChanges(ctx)).To(Succeed())
 			})
 
@@ -112,6 +124,35 @@ func init() {
 					Expect(rs256Key["e"]).ToNot(BeEmpty())
 				})
 
+				It("returns the correct JWK structure for ECDSA keys", func() {
+					resp, err := http.Get(jwksURL(h, ecAlgVS))
+					Expect(err).ToNot(HaveOccurred())
+					defer resp.Body.Close() //nolint:errcheck
+
+					var jwks jwksResponse
+					Expect(json.NewDecoder(resp.Body).Decode(&jwks)).To(Succeed())
+					Expect(jwkAlgs(jwks)).To(ConsistOf("ES256", "ES512", "PS256"))
+
+					es256Key := findJwkByAlg(jwks, "ES256")
+					Expect(es256Key).ToNot(BeNil())
+					Expect(es256Key["kty"]).To(Equal("EC"))
+					Expect(es256Key["crv"]).To(Equal("P-256"))
+					Expect(es256Key["use"]).To(Equal("sig"))
+					Expect(es256Key["kid"]).ToNot(BeEmpty())
+					Expect(es256Key["x"]).To(HaveLen(43))
+					Expect(es256Key["y"]).To(HaveLen(43))
+
+					es512Key := findJwkByAlg(jwks, "ES512")
+					Expect(es512Key).ToNot(BeNil())
+					Expect(es512Key["crv"]).To(Equal("P-521"))
+					Expect(es512Key["x"]).To(HaveLen(88))
+					Expect(es512Key["y"]).To(HaveLen(88))
+
+					ps256Key := findJwkByAlg(jwks, "PS256")
+					Expect(ps256Key).ToNot(BeNil())
+					Expect(ps256Key["kty"]).To(Equal("RSA"))
+				})
+
 				It("returns a single key for a single-algorithm VS", func() {
 					resp, err := http.Get(jwksURL(h, h.VirtualServer()))
 					Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,339 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/handlers"
+	"github.com/The127/Keyline/internal/messages"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	smsRegistrationUsername = "test-sms-registration-user"
+	smsLoginUsername        = "test-sms-login-user"
+	smsLockoutUsername      = "test-sms-lockout-user"
+	smsUserPassword         = "correct-horse-battery-staple"
+	smsRegistrationPhone    = "+4915100000001"
+	smsLoginPhone           = "+4915100000002"
+	smsLockoutPhone         = "+4915100000003"
+)
+
+var smsCodePattern = regexp.MustCompile(`code is ([0-9]{6})`)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("SMS second factor ["+backend.name+"]", Ordered, func() {
+			var h *harness
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(seedSmsUsers(h.Scope())).To(Succeed())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			post := func(url string, accessToken string, body any) int {
+				jsonBytes, err := json.Marshal(body)
+				Expect(err).ToNot(HaveOccurred())
+
+				req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonBytes))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				if accessToken != "" {
+					req.Header.Set("Authorization", "Bearer "+accessToken)
+				}
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				_ = resp.Body.Close()
+				return resp.StatusCode
+			}
+
+			loginState := func(loginToken string) handlers.GetLoginStateResponseDto {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var state handlers.GetLoginStateResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+				return state
+			}
+
+			lastSmsCode := func(to string) string {
+				sms := queuedSms(h, to)
+				Expect(sms).ToNot(BeEmpty())
+				match := smsCodePattern.FindStringSubmatch(sms[len(sms)-1].Body)
+				Expect(match).To(HaveLen(2))
+				return match[1]
+			}
+
+			Describe("phone registration", func() {
+				var accessToken string
+				var startUrl, finishUrl string
+
+				BeforeAll(func() {
+					deviceResp, err := h.Client().Oidc().BeginDeviceFlow(h.Ctx(), lockoutAppName, "openid")
+					Expect(err).ToNot(HaveOccurred())
+					loginToken, err := h.Client().Oidc().PostActivate(h.Ctx(), deviceResp.UserCode)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, smsRegistrationUsername, smsUserPassword)).To(Succeed())
+					Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+					tokenResp, err := h.Client().Oidc().PollDeviceToken(h.Ctx(), lockoutAppName, deviceResp.DeviceCode)
+					Expect(err).ToNot(HaveOccurred())
+					accessToken = tokenResp.AccessToken
+
+					userId := smsUserId(h, smsRegistrationUsername)
+					baseUrl := fmt.Sprintf("%s/api/virtual-servers/test-vs/users/%s/phone/register", h.ApiUrl(), userId)
+					startUrl = baseUrl + "/start"
+					finishUrl = baseUrl + "/finish"
+				})
+
+				It("rejects phone numbers that are not in E.164 format", func() {
+					status := post(startUrl, accessToken, map[string]string{"phoneNumber": "0151 000000"})
+					Expect(status).To(Equal(http.StatusBadRequest))
+				})
+
+				It("requires the user to be signed in", func() {
+					status := post(startUrl, "", map[string]string{"phoneNumber": smsRegistrationPhone})
+					Expect(status).To(Equal(http.StatusUnauthorized))
+				})
+
+				It("stores the phone number once the code is entered", func() {
+					status := post(startUrl, accessToken, map[string]string{"phoneNumber": smsRegistrationPhone})
+					Expect(status).To(Equal(http.StatusNoContent))
+					code := lastSmsCode(smsRegistrationPhone)
+
+					wrongCode := "000000"
+					if code == wrongCode {
+						wrongCode = "111111"
+					}
+					Expect(post(finishUrl, accessToken, map[string]string{"code": wrongCode})).To(Equal(http.StatusUnauthorized))
+					Expect(smsPhoneNumbers(h, smsRegistrationUsername)).To(BeEmpty())
+
+					Expect(post(finishUrl, accessToken, map[string]string{"code": code})).To(Equal(http.StatusNoContent))
+					Expect(smsPhoneNumbers(h, smsRegistrationUsername)).To(ConsistOf(smsRegistrationPhone))
+
+					// the code can only be used once
+					Expect(post(finishUrl, accessToken, map[string]string{"code": code})).To(Equal(http.StatusUnauthorized))
+				})
+			})
+
+			Describe("login", func() {
+				It("asks for the code after the password", func() {
+					loginToken := mintPasswordResetLoginToken(h)
+					Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, smsLoginUsername, smsUserPassword)).To(Succeed())
+
+					state := loginState(loginToken)
+					Expect(state.Step).To(Equal("verifySms"))
+					Expect(state.SecondFactors).To(ConsistOf("sms"))
+
+					// the login cannot be finished before the code is verified
+					Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).ToNot(Succeed())
+
+					sendUrl := fmt.Sprintf("%s/logins/%s/sms/send", h.ApiUrl(), loginToken)
+					verifyUrl := fmt.Sprintf("%s/logins/%s/verify-sms", h.ApiUrl(), loginToken)
+
+					Expect(post(sendUrl, "", nil)).To(Equal(http.StatusNoContent))
+					code := lastSmsCode(smsLoginPhone)
+
+					// a second code within the cooldown is refused
+					Expect(post(sendUrl, "", nil)).To(Equal(http.StatusTooManyRequests))
+
+					Expect(post(verifyUrl, "", map[string]string{"code": code})).To(Equal(http.StatusNoContent))
+					Expect(loginState(loginToken).Step).To(Equal("finish"))
+					Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+				})
+
+				It("counts wrong codes against the lockout", func() {
+					_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+						VirtualServerName:        "test-vs",
+						LockoutMaxFailedAttempts: utils.Ptr(2),
+					})
+					Expect(err).ToNot(HaveOccurred())
+					DeferCleanup(func() {
+						_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+							VirtualServerName:        "test-vs",
+							LockoutMaxFailedAttempts: utils.Ptr(0),
+						})
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					loginToken := mintPasswordResetLoginToken(h)
+					Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, smsLockoutUsername, smsUserPassword)).To(Succeed())
+
+					sendUrl := fmt.Sprintf("%s/logins/%s/sms/send", h.ApiUrl(), loginToken)
+					verifyUrl := fmt.Sprintf("%s/logins/%s/verify-sms", h.ApiUrl(), loginToken)
+
+					Expect(post(sendUrl, "", nil)).To(Equal(http.StatusNoContent))
+					code := lastSmsCode(smsLockoutPhone)
+
+					wrongCode := "000000"
+					if code == wrongCode {
+						wrongCode = "111111"
+					}
+					for range 2 {
+						Expect(post(verifyUrl, "", map[string]string{"code": wrongCode})).To(Equal(http.StatusUnauthorized))
+					}
+
+					// the right code does not help once the user is locked
+					Expect(post(verifyUrl, "", map[string]string{"code": code})).To(Equal(http.StatusTooManyRequests))
+				})
+
+				It("refuses to send codes in other steps", func() {
+					loginToken := mintPasswordResetLoginToken(h)
+
+					sendUrl := fmt.Sprintf("%s/logins/%s/sms/send", h.ApiUrl(), loginToken)
+					Expect(post(sendUrl, "", nil)).To(Equal(http.StatusUnauthorized))
+				})
+			})
+		})
+	}
+}
+
+// queuedSms returns the queued text messages to the phone number in the
+// order they were queued.
+func queuedSms(h *harness, to string) []messages.SendSmsMessage {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	outboxFilter := repositories.NewOutboxMessageFilter()
+	outboxMessages, err := dbContext.OutboxMessages().List(ctx, outboxFilter)
+	Expect(err).ToNot(HaveOccurred())
+
+	var sms []messages.SendSmsMessage
+	for _, outboxMessage := range outboxMessages {
+		if outboxMessage.Type() != repositories.SendSmsOutboxMessageType {
+			continue
+		}
+		var message messages.SendSmsMessage
+		Expect(json.Unmarshal(outboxMessage.Details(), &message)).To(Succeed())
+		if message.To == to {
+			sms = append(sms, message)
+		}
+	}
+	return sms
+}
+
+func smsUserId(h *harness, username string) string {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(username))
+	Expect(err).ToNot(HaveOccurred())
+	return user.Id().String()
+}
+
+// smsPhoneNumbers returns the phone numbers the user has registered.
+func smsPhoneNumbers(h *harness, username string) []string {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(username))
+	Expect(err).ToNot(HaveOccurred())
+
+	phoneFilter := repositories.NewCredentialFilter().UserId(user.Id()).Type(repositories.CredentialTypePhone)
+	credentials, err := dbContext.Credentials().List(ctx, phoneFilter)
+	Expect(err).ToNot(HaveOccurred())
+
+	var phoneNumbers []string
+	for _, credential := range credentials {
+		details, err := credential.PhoneDetails()
+		Expect(err).ToNot(HaveOccurred())
+		phoneNumbers = append(phoneNumbers, details.PhoneNumber)
+	}
+	return phoneNumbers
+}
+
+// seedSmsUsers creates a user without a phone number for the registration
+// and two with a verified phone number for the login and the lockout.
+func seedSmsUsers(scope *ioc.DependencyProvider) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	err := seedUserWithPassword(ctx, m, dbContext, smsRegistrationUsername, smsUserPassword)
+	if err != nil {
+		return err
+	}
+
+	err = seedUserWithPassword(ctx, m, dbContext, smsLoginUsername, smsUserPassword)
+	if err != nil {
+		return err
+	}
+
+	err = seedUserWithPassword(ctx, m, dbContext, smsLockoutUsername, smsUserPassword)
+	if err != nil {
+		return err
+	}
+
+	phoneNumbers := map[string]string{
+		smsLoginUsername:   smsLoginPhone,
+		smsLockoutUsername: smsLockoutPhone,
+	}
+	for username, phoneNumber := range phoneNumbers {
+		user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(username))
+		if err != nil {
+			return fmt.Errorf("getting user %s: %w", username, err)
+		}
+
+		dbContext.Credentials().Insert(repositories.NewCredential(user.Id(), &repositories.CredentialPhoneDetails{
+			PhoneNumber: phoneNumber,
+			VerifiedAt:  time.Now(),
+		}))
+	}
+	return dbContext.SaveChanges(ctx)
+}
@@ -0,0 +1,340 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	stepUpAppName      = "step-up-app"
+	stepUpRedirect     = "http://localhost:9000/step-up-callback"
+	stepUpUserName     = "step-up-user"
+	stepUpUserPassword = "step-up-user-password-1"
+	stepUpAcr          = "urn:keyline:transfer"
+	stepUpMfaAcr       = "urn:keyline:mfa"
+)
+
+// stepUpClient drives the authorization code flow with a cookie jar-less
+// client, the session cookie is carried over by hand.
+type stepUpClient struct {
+	serverUrl     string
+	httpClient    *http.Client
+	sessionCookie string
+}
+
+func newStepUpClient(serverUrl string) *stepUpClient {
+	return &stepUpClient{
+		serverUrl: serverUrl,
+		httpClient: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// authorize calls /authorize and returns the redirect location.
+func (c *stepUpClient) authorize(acrValues string, prompt string) *url.URL {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", stepUpAppName)
+	q.Set("redirect_uri", stepUpRedirect)
+	q.Set("scope", "openid")
+	q.Set("code_challenge", authCodePkceChallenge(authCodePkceVerifier))
+	q.Set("code_challenge_method", "S256")
+	if acrValues != "" {
+		q.Set("acr_values", acrValues)
+	}
+	if prompt != "" {
+		q.Set("prompt", prompt)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/oidc/test-vs/authorize?%s", c.serverUrl, q.Encode()), nil)
+	Expect(err).ToNot(HaveOccurred())
+	if c.sessionCookie != "" {
+		req.Header.Set("Cookie", c.sessionCookie)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	Expect(err).ToNot(HaveOccurred())
+	return location
+}
+
+func (c *stepUpClient) post(loginToken string, path string, body string) *http.Response {
+	resp, err := c.httpClient.Post(
+		fmt.Sprintf("%s/logins/%s/%s", c.serverUrl, loginToken, path),
+		"application/json",
+		strings.NewReader(body),
+	)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
+
+func (c *stepUpClient) loginState(loginToken string) map[string]any {
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/logins/%s", c.serverUrl, loginToken))
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	return readJSON(resp)
+}
+
+func (c *stepUpClient) finishLogin(loginToken string) {
+	resp := c.post(loginToken, "finish-login", "")
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+	cookieHdr := resp.Header.Get("Set-Cookie")
+	Expect(cookieHdr).ToNot(BeEmpty())
+	c.sessionCookie = strings.SplitN(cookieHdr, ";", 2)[0]
+}
+
+// idTokenClaims redeems the code and returns the claims of the id token.
+func (c *stepUpClient) idTokenClaims(code string) jwt.MapClaims {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", stepUpAppName)
+	form.Set("redirect_uri", stepUpRedirect)
+	form.Set("code_verifier", authCodePkceVerifier)
+
+	status, body, err := postToken(c.serverUrl, form)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(status).To(Equal(http.StatusOK), fmt.Sprintf("body: %v", body))
+
+	claims := jwt.MapClaims{}
+	_, _, err = new(jwt.Parser).ParseUnverified(body["id_token"].(string), claims)
+	Expect(err).ToNot(HaveOccurred())
+	return claims
+}
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Step-up authentication ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var c *stepUpClient
+			var totpSecret string
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				Expect(setupStepUpFixtures(h.Scope())).To(Succeed())
+				c = newStepUpClient(h.ApiUrl())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			It("reports the password in amr after a password login", func() {
+				loginToken := c.authorize("", "").Query().Get("token")
+				Expect(loginToken).ToNot(BeEmpty())
+
+				resp := c.post(loginToken, "verify-password", fmt.Sprintf(`{"username":%q,"password":%q}`, stepUpUserName, stepUpUserPassword))
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				c.finishLogin(loginToken)
+
+				code := c.authorize("", "").Query().Get("code")
+				Expect(code).ToNot(BeEmpty())
+
+				claims := c.idTokenClaims(code)
+				Expect(claims["amr"]).To(Equal([]any{"pwd"}))
+				Expect(claims).ToNot(HaveKey("acr"))
+			})
+
+			It("ignores acr values the application does not know", func() {
+				code := c.authorize("urn:unknown", "").Query().Get("code")
+				Expect(code).ToNot(BeEmpty())
+			})
+
+			It("rejects a request object with non-string acr values", func() {
+				requestObject, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+					"acr_values": 42,
+				}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				Expect(err).ToNot(HaveOccurred())
+
+				q := url.Values{}
+				q.Set("response_type", "code")
+				q.Set("client_id", stepUpAppName)
+				q.Set("redirect_uri", stepUpRedirect)
+				q.Set("scope", "openid")
+				q.Set("request", requestObject)
+
+				resp, err := c.httpClient.Get(fmt.Sprintf("%s/oidc/test-vs/authorize?%s", c.serverUrl, q.Encode()))
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+
+			It("fails prompt=none if the session does not reach the acr", func() {
+				location := c.authorize(stepUpAcr, "none")
+				Expect(location.Query().Get("error")).To(Equal("interaction_required"))
+			})
+
+			It("asks for the missing factor even though a session exists", func() {
+				location := c.authorize(stepUpAcr, "")
+				Expect(location.Query().Get("code")).To(BeEmpty())
+				loginToken := location.Query().Get("token")
+				Expect(loginToken).ToNot(BeEmpty())
+
+				// the user has no otp factor yet, so they onboard one
+				state := c.loginState(loginToken)
+				Expect(state["step"]).To(Equal("onboardTotp"))
+
+				totpSecret = state["totpSecret"].(string)
+				totpCode, err := totp.GenerateCode(totpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				resp := c.post(loginToken, "onboard-totp", fmt.Sprintf(`{"totpCode":%q}`, totpCode))
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				c.finishLogin(loginToken)
+
+				code := c.authorize(stepUpAcr, "").Query().Get("code")
+				Expect(code).ToNot(BeEmpty())
+
+				claims := c.idTokenClaims(code)
+				Expect(claims["acr"]).To(Equal(stepUpAcr))
+				Expect(claims["amr"]).To(Equal([]any{"pwd", "otp", "mfa"}))
+			})
+
+			It("asks for totp after an email login that needs a second factor", func() {
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: "test-vs",
+					EnableEmailLogin:  utils.Ptr(true),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				// a fresh client, so that the password session is not reused
+				c := newStepUpClient(h.ApiUrl())
+				loginToken := c.authorize(stepUpMfaAcr, "").Query().Get("token")
+				Expect(loginToken).ToNot(BeEmpty())
+
+				resp := c.post(loginToken, "email-login/start", fmt.Sprintf(`{"usernameOrEmail":%q}`, stepUpUserName))
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				mails := queuedMails(h, "Sign in")
+				Expect(mails).ToNot(BeEmpty())
+				match := emailLoginCodePattern.FindStringSubmatch(mails[len(mails)-1].Body)
+				Expect(match).To(HaveLen(2))
+
+				resp = c.post(loginToken, "email-login/verify", fmt.Sprintf(`{"code":%q}`, match[1]))
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				// the code from the mail does not count as the authenticator
+				Expect(c.loginState(loginToken)["step"]).To(Equal("verifyTotp"))
+
+				totpCode, err := totp.GenerateCode(totpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				resp = c.post(loginToken, "verify-totp", fmt.Sprintf(`{"totpCode":%q}`, totpCode))
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				c.finishLogin(loginToken)
+
+				code := c.authorize(stepUpMfaAcr, "").Query().Get("code")
+				Expect(code).ToNot(BeEmpty())
+
+				claims := c.idTokenClaims(code)
+				Expect(claims["acr"]).To(Equal(stepUpMfaAcr))
+				Expect(claims["amr"]).To(Equal([]any{"email", "otp", "mfa"}))
+			})
+		})
+	}
+}
+
+func setupStepUpFixtures(scope *ioc.DependencyProvider) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	if _, err := mediatr.Send[*commands.CreateProjectResponse](ctx, m, commands.CreateProject{
+		VirtualServerName: "test-vs",
+		Slug:              "step-up-project",
+		Name:              "Step-Up Project",
+	}); err != nil {
+		return fmt.Errorf("creating project: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	appResp, err := mediatr.Send[*commands.CreateApplicationResponse](ctx, m, commands.CreateApplication{
+		VirtualServerName:      "test-vs",
+		ProjectSlug:            "step-up-project",
+		Name:                   stepUpAppName,
+		DisplayName:            "Step-Up App",
+		Type:                   repositories.ApplicationTypePublic,
+		RedirectUris:           []string{stepUpRedirect},
+		PostLogoutRedirectUris: []string{},
+	})
+	if err != nil {
+		return fmt.Errorf("creating app: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	if _, err := mediatr.Send[*commands.PatchApplicationResponse](ctx, m, commands.PatchApplication{
+		VirtualServerName: "test-vs",
+		ProjectSlug:       "step-up-project",
+		ApplicationId:     appResp.Id,
+		AcrLevels: &repositories.AcrLevels{
+			{
+				Acr: stepUpAcr,
+				Methods: []repositories.AuthenticationMethod{
+					repositories.AuthenticationMethodPassword,
+					repositories.AuthenticationMethodOtp,
+				},
+			},
+			{
+				Acr:     stepUpMfaAcr,
+				Methods: []repositories.AuthenticationMethod{repositories.AuthenticationMethodMultiFactor},
+			},
+		},
+	}); err != nil {
+		return fmt.Errorf("setting acr levels: %w", err)
+	}
+	if err := dbContext.SaveChanges(ctx); err != nil {
+		return err
+	}
+
+	return seedUserWithPassword(ctx, m, dbContext, stepUpUserName, stepUpUserPassword)
+}
@@ -0,0 +1,285 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/The127/Keyline/api"
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/handlers"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/repositories"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpManagementUsername = "test-totp-management-user"
+	totpManagementPassword = "correct-horse-battery-staple"
+	totpManagementSecret1  = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+	totpManagementSecret2  = "KRSXG5CTMVRXEZLUKRSXG5CTMVRXEZLU"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("TOTP management ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userId uuid.UUID
+			var accessToken string
+			var totpUrl string
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				userId, err = seedTotpManagementUser(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = sendAsSystem[*commands.RegenerateRecoveryCodesResponse](h, commands.RegenerateRecoveryCodes{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				// the harness starts the server in the background
+				Eventually(func() error {
+					resp, err := http.Get(h.ApiUrl() + "/health")
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+					return err
+				}).Should(Succeed())
+
+				totpUrl = fmt.Sprintf("%s/api/virtual-servers/test-vs/users/%s/totp", h.ApiUrl(), userId)
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			send := func(method string, url string, body any) *http.Response {
+				var reader *bytes.Reader
+				if body != nil {
+					jsonBytes, err := json.Marshal(body)
+					Expect(err).ToNot(HaveOccurred())
+					reader = bytes.NewReader(jsonBytes)
+				} else {
+					reader = bytes.NewReader(nil)
+				}
+
+				req, err := http.NewRequest(method, url, reader)
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				if accessToken != "" {
+					req.Header.Set("Authorization", "Bearer "+accessToken)
+				}
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			loginState := func(loginToken string) handlers.GetLoginStateResponseDto {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var state handlers.GetLoginStateResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+				return state
+			}
+
+			listTotp := func() []api.ListTotpResponseDto {
+				resp := send(http.MethodGet, totpUrl, nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var list api.PagedListTotpResponseDto
+				Expect(json.NewDecoder(resp.Body).Decode(&list)).To(Succeed())
+				return list.Items
+			}
+
+			It("lets the user sign in with either authenticator", func() {
+				deviceResp, err := h.Client().Oidc().BeginDeviceFlow(h.Ctx(), lockoutAppName, "openid")
+				Expect(err).ToNot(HaveOccurred())
+				loginToken, err := h.Client().Oidc().PostActivate(h.Ctx(), deviceResp.UserCode)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, totpManagementUsername, totpManagementPassword)).To(Succeed())
+				Expect(loginState(loginToken).Step).To(Equal("verifyTotp"))
+
+				code, err := totp.GenerateCode(totpManagementSecret2, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				verifyUrl := fmt.Sprintf("%s/logins/%s/verify-totp", h.ApiUrl(), loginToken)
+				Expect(send(http.MethodPost, verifyUrl, map[string]string{"totpCode": code}).StatusCode).To(Equal(http.StatusNoContent))
+
+				Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+				tokenResp, err := h.Client().Oidc().PollDeviceToken(h.Ctx(), lockoutAppName, deviceResp.DeviceCode)
+				Expect(err).ToNot(HaveOccurred())
+				accessToken = tokenResp.AccessToken
+			})
+
+			It("lists and renames the authenticators of the user", func() {
+				authenticators := listTotp()
+				Expect(authenticators).To(HaveLen(2))
+
+				patchUrl := fmt.Sprintf("%s/%s", totpUrl, authenticators[0].Id)
+				resp := send(http.MethodPatch, patchUrl, map[string]string{"name": "  Work phone  "})
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				resp = send(http.MethodPatch, patchUrl, map[string]string{"name": " "})
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+				var names []string
+				for _, authenticator := range listTotp() {
+					names = append(names, authenticator.Name)
+				}
+				Expect(names).To(ContainElement("Work phone"))
+			})
+
+			It("keeps the recovery codes until the last authenticator is deleted", func() {
+				authenticators := listTotp()
+				Expect(authenticators).To(HaveLen(2))
+
+				resp := send(http.MethodDelete, fmt.Sprintf("%s/%s", totpUrl, authenticators[0].Id), nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(listTotp()).To(HaveLen(1))
+				Expect(totpManagementCredentials(h, userId, repositories.CredentialTypeRecoveryCode)).To(HaveLen(commands.RecoveryCodeCount))
+
+				resp = send(http.MethodDelete, fmt.Sprintf("%s/%s", totpUrl, authenticators[1].Id), nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(listTotp()).To(BeEmpty())
+				Expect(totpManagementCredentials(h, userId, repositories.CredentialTypeRecoveryCode)).To(BeEmpty())
+			})
+
+			It("does not let users reset their own second factors", func() {
+				resetUrl := fmt.Sprintf("%s/api/virtual-servers/test-vs/users/%s/reset-2fa", h.ApiUrl(), userId)
+				Expect(send(http.MethodPost, resetUrl, nil).StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("makes the user onboard a new authenticator after an admin reset", func() {
+				Expect(seedTotpManagementCredentials(h.Scope(), userId, true)).To(Succeed())
+
+				_, err := sendAsSystem[*commands.ResetSecondFactorsResponse](h, commands.ResetSecondFactors{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(totpManagementCredentials(h, userId, repositories.CredentialTypeTotp)).To(BeEmpty())
+				Expect(totpManagementCredentials(h, userId, repositories.CredentialTypePhone)).To(BeEmpty())
+
+				loginToken := mintPasswordResetLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, totpManagementUsername, totpManagementPassword)).To(Succeed())
+				state := loginState(loginToken)
+				Expect(state.Step).To(Equal("onboardTotp"))
+
+				code, err := totp.GenerateCode(state.TotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				onboardUrl := fmt.Sprintf("%s/logins/%s/onboard-totp", h.ApiUrl(), loginToken)
+				Expect(send(http.MethodPost, onboardUrl, map[string]string{"totpCode": code}).StatusCode).To(Equal(http.StatusOK))
+				Expect(h.Client().Oidc().FinishLogin(h.Ctx(), loginToken)).To(Succeed())
+
+				// the onboarding is only asked for once
+				authenticators := totpManagementCredentials(h, userId, repositories.CredentialTypeTotp)
+				Expect(authenticators).To(HaveLen(1))
+				_, err = sendAsSystem[*commands.DeleteTotpResponse](h, commands.DeleteTotp{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+					TotpId:            authenticators[0].Id(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				loginToken = mintPasswordResetLoginToken(h)
+				Expect(h.Client().Oidc().VerifyPassword(h.Ctx(), loginToken, totpManagementUsername, totpManagementPassword)).To(Succeed())
+				Expect(loginState(loginToken).Step).To(Equal("finish"))
+			})
+		})
+	}
+}
+
+// totpManagementCredentials returns the credentials of the type of the
+// user.
+func totpManagementCredentials(h *harness, userId uuid.UUID, credentialType repositories.CredentialType) []*repositories.Credential {
+	scope := h.Scope().NewScope()
+	defer func() { _ = scope.Close() }()
+
+	ctx := middlewares.ContextWithScope(context.Background(), scope)
+	dbContext := ioc.GetDependency[database.Context](scope)
+
+	credentialFilter := repositories.NewCredentialFilter().UserId(userId).Type(credentialType)
+	credentials, err := dbContext.Credentials().List(ctx, credentialFilter)
+	Expect(err).ToNot(HaveOccurred())
+	return credentials
+}
+
+// seedTotpManagementUser creates a user with two totp authenticators.
+func seedTotpManagementUser(scope *ioc.DependencyProvider) (uuid.UUID, error) {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	err := seedUserWithPassword(ctx, m, dbContext, totpManagementUsername, totpManagementPassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(totpManagementUsername))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting user %s: %w", totpManagementUsername, err)
+	}
+
+	return user.Id(), seedTotpManagementCredentials(subscope, user.Id(), false)
+}
+
+// seedTotpManagementCredentials gives the user two totp authenticators and
+// optionally a phone number.
+func seedTotpManagementCredentials(scope *ioc.DependencyProvider, userId uuid.UUID, withPhone bool) error {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := middlewares.ContextWithScope(context.Background(), subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	for _, secret := range []string{totpManagementSecret1, totpManagementSecret2} {
+		dbContext.Credentials().Insert(repositories.NewCredential(userId, &repositories.CredentialTotpDetails{
+			Secret:    secret,
+			Digits:    int(otp.DigitsSix),
+			Algorithm: int(otp.AlgorithmSHA1),
+		}))
+	}
+	if withPhone {
+		dbContext.Credentials().Insert(repositories.NewCredential(userId, &repositories.CredentialPhoneDetails{
+			PhoneNumber: "+4915100000045",
+			VerifiedAt:  time.Now(),
+		}))
+	}
+	return dbContext.SaveChanges(ctx)
+}
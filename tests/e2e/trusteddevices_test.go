@@ -0,0 +1,215 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/The127/Keyline/config"
+	"github.com/The127/Keyline/internal/authentication"
+	"github.com/The127/Keyline/internal/commands"
+	"github.com/The127/Keyline/internal/database"
+	"github.com/The127/Keyline/internal/middlewares"
+	"github.com/The127/Keyline/internal/queries"
+	"github.com/The127/Keyline/internal/repositories"
+	"github.com/The127/Keyline/utils"
+
+	"github.com/The127/ioc"
+	"github.com/The127/mediatr"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	trustedDeviceUsername   = "test-trusted-device-user"
+	trustedDevicePassword   = "correct-horse-battery-staple"
+	trustedDeviceTotpSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+)
+
+func init() {
+	for _, backend := range testBackends {
+		backend := backend
+		Describe("Trusted devices ["+backend.name+"]", Ordered, func() {
+			var h *harness
+			var userId uuid.UUID
+			var trustedDeviceCookie string
+			password := trustedDevicePassword
+
+			BeforeAll(func() {
+				if backend.dbMode == config.DatabaseModePostgres && !postgresBackendAvailable() {
+					Skip("Postgres not available")
+				}
+				h = newE2eTestHarness(backend.dbMode, nil)
+				_, err := setupAccountLockoutFixtures(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				userId, err = seedTrustedDeviceUser(h.Scope())
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: "test-vs",
+					TrustedDeviceDays: utils.Ptr(30),
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				if h != nil {
+					h.Close()
+				}
+			})
+
+			// post sends the request with the trusted device cookie, the
+			// harness client has no cookie jar
+			post := func(loginToken string, path string, body string) *http.Response {
+				req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/logins/%s/%s", h.ApiUrl(), loginToken, path), strings.NewReader(body))
+				Expect(err).ToNot(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+				if trustedDeviceCookie != "" {
+					req.Header.Set("Cookie", trustedDeviceCookie)
+				}
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			loginWithPassword := func() string {
+				loginToken := mintPasswordResetLoginToken(h)
+				resp := post(loginToken, "verify-password", fmt.Sprintf(`{"username":%q,"password":%q}`, trustedDeviceUsername, password))
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				return loginToken
+			}
+
+			loginStep := func(loginToken string) string {
+				resp, err := http.Get(fmt.Sprintf("%s/logins/%s", h.ApiUrl(), loginToken))
+				Expect(err).ToNot(HaveOccurred())
+				defer func() { _ = resp.Body.Close() }()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				return readJSON(resp)["step"].(string)
+			}
+
+			trustBrowser := func() {
+				loginToken := loginWithPassword()
+				Expect(loginStep(loginToken)).To(Equal("verifyTotp"))
+
+				code, err := totp.GenerateCode(trustedDeviceTotpSecret, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				resp := post(loginToken, "verify-totp", fmt.Sprintf(`{"totpCode":%q,"trustDevice":true}`, code))
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				cookieName := middlewares.GetTrustedDeviceCookieName("test-vs")
+				var found bool
+				for _, cookie := range resp.Cookies() {
+					if cookie.Name == cookieName {
+						trustedDeviceCookie = cookie.Name + "=" + cookie.Value
+						found = true
+					}
+				}
+				Expect(found).To(BeTrue())
+			}
+
+			listTrustedDevices := func() []queries.ListTrustedDevicesResponseItem {
+				response, err := sendAsSystem[*queries.ListTrustedDevicesResponse](h, queries.ListTrustedDevices{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				return response.Items
+			}
+
+			It("skips the second factor in a trusted browser", func() {
+				trustBrowser()
+
+				loginToken := loginWithPassword()
+				Expect(loginStep(loginToken)).To(Equal("finish"))
+			})
+
+			It("lists the trusted device with its last use", func() {
+				trustedDevices := listTrustedDevices()
+				Expect(trustedDevices).To(HaveLen(1))
+				Expect(trustedDevices[0].LastUsedAt).ToNot(BeNil())
+				Expect(trustedDevices[0].ExpiresAt).To(BeTemporally("~", time.Now().Add(30*24*time.Hour), time.Minute))
+			})
+
+			It("asks for the second factor again once the device is revoked", func() {
+				_, err := sendAsSystem[*commands.DeleteTrustedDeviceResponse](h, commands.DeleteTrustedDevice{
+					VirtualServerName: "test-vs",
+					UserId:            userId,
+					TrustedDeviceId:   listTrustedDevices()[0].Id,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				loginToken := loginWithPassword()
+				Expect(loginStep(loginToken)).To(Equal("verifyTotp"))
+			})
+
+			It("forgets trusted devices when the password changes", func() {
+				trustBrowser()
+				Expect(listTrustedDevices()).To(HaveLen(1))
+
+				password = "a-brand-new-password-1"
+				_, err := sendAsSystem[*commands.SetPasswordResponse](h, commands.SetPassword{
+					UserId:      userId,
+					NewPassword: password,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(listTrustedDevices()).To(BeEmpty())
+
+				loginToken := loginWithPassword()
+				Expect(loginStep(loginToken)).To(Equal("verifyTotp"))
+			})
+
+			It("ignores the cookie when the virtual server stops trusting browsers", func() {
+				trustBrowser()
+
+				_, err := sendAsSystem[*commands.PatchVirtualServerResponse](h, commands.PatchVirtualServer{
+					VirtualServerName: "test-vs",
+					TrustedDeviceDays: utils.Ptr(0),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				loginToken := loginWithPassword()
+				Expect(loginStep(loginToken)).To(Equal("verifyTotp"))
+			})
+		})
+	}
+}
+
+// seedTrustedDeviceUser creates a user with a password and totp.
+func seedTrustedDeviceUser(scope *ioc.DependencyProvider) (uuid.UUID, error) {
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	ctx := context.Background()
+	ctx = middlewares.ContextWithScope(ctx, subscope)
+	ctx = authentication.ContextWithCurrentUser(ctx, authentication.SystemUser())
+
+	m := ioc.GetDependency[mediatr.Mediator](subscope)
+	dbContext := ioc.GetDependency[database.Context](subscope)
+
+	err := seedUserWithPassword(ctx, m, dbContext, trustedDeviceUsername, trustedDevicePassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := dbContext.Users().FirstOrErr(ctx, repositories.NewUserFilter().Username(trustedDeviceUsername))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("getting user %s: %w", trustedDeviceUsername, err)
+	}
+
+	dbContext.Credentials().Insert(repositories.NewCredential(user.Id(), &repositories.CredentialTotpDetails{
+		Secret:    trustedDeviceTotpSecret,
+		Digits:    int(otp.DigitsSix),
+		Algorithm: int(otp.AlgorithmSHA1),
+	}))
+	return user.Id(), dbContext.SaveChanges(ctx)
+}
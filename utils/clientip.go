@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIp returns the address of the client that sent the request. The
+// X-Forwarded-For header is only honoured if the request came from one of
+// the trusted proxies, given as addresses or CIDR prefixes. The header is
+// read from the right so that a client cannot pick its own address.
+func ClientIp(r *http.Request, trustedProxies []string) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop, trustedProxies) {
+			return hop
+		}
+		remote = hop
+	}
+
+	return remote
+}
+
+func isTrustedProxy(address string, trustedProxies []string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, trustedProxy := range trustedProxies {
+		if prefix, err := netip.ParsePrefix(trustedProxy); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if trusted, err := netip.ParseAddr(trustedProxy); err == nil && trusted.Unmap() == addr {
+			return true
+		}
+	}
+
+	return false
+}
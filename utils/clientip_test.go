@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIp(t *testing.T) {
+	t.Parallel()
+
+	trustedProxies := []string{"10.0.0.0/8", "192.168.1.1"}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expectedIp   string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:51234",
+			expectedIp: "203.0.113.7",
+		},
+		{
+			name:         "header of an untrusted client is ignored",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: []string{"198.51.100.1"},
+			expectedIp:   "203.0.113.7",
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.1"},
+			expectedIp:   "198.51.100.1",
+		},
+		{
+			name:         "spoofed hops left of the last untrusted address are ignored",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.1", "192.168.1.1"},
+			expectedIp:   "198.51.100.1",
+		},
+		{
+			name:         "only trusted hops",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"10.9.9.9"},
+			expectedIp:   "10.9.9.9",
+		},
+		{
+			name:       "ipv6",
+			remoteAddr: "[2001:db8::1]:443",
+			expectedIp: "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			assert.Equal(t, tt.expectedIp, ClientIp(r, trustedProxies))
+		})
+	}
+}
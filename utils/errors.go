@@ -25,15 +25,27 @@ var ErrUserApplicationMetadataNotFound = fmt.Errorf("user application metadata:
 var ErrPasswordRuleNotFound = fmt.Errorf("password rule: %w", ErrHttpNotFound)
 var ErrResourceServerNotFound = fmt.Errorf("resource server: %w", ErrHttpNotFound)
 var ErrResourceServerScopeNotFound = fmt.Errorf("resource server scope: %w", ErrHttpNotFound)
+var ErrIdentityProviderNotFound = fmt.Errorf("identity provider: %w", ErrHttpNotFound)
+var ErrLdapProviderNotFound = fmt.Errorf("ldap provider: %w", ErrHttpNotFound)
+var ErrProvisioningTargetNotFound = fmt.Errorf("provisioning target: %w", ErrHttpNotFound)
+var ErrSigningKeyNotFound = fmt.Errorf("signing key: %w", ErrHttpNotFound)
 
 var ErrHttpBadRequest = errors.New("bad request")
 var ErrRegistrationNotEnabled = fmt.Errorf("registration is not enabled: %w", ErrHttpBadRequest)
+var ErrEmailLoginNotEnabled = fmt.Errorf("email login is not enabled: %w", ErrHttpBadRequest)
 var ErrInvalidUuid = fmt.Errorf("invalid uuid: %w", ErrHttpBadRequest)
+var ErrLdapProviderReadOnly = fmt.Errorf("user is managed by a read-only ldap provider: %w", ErrHttpBadRequest)
+var ErrInvalidPasswordResetToken = fmt.Errorf("invalid or expired password reset token: %w", ErrHttpBadRequest)
+var ErrPasswordInvalid = fmt.Errorf("invalid password: %w", ErrHttpBadRequest)
+var ErrInvalidRecoveryCode = fmt.Errorf("invalid recovery code: %w", ErrHttpBadRequest)
 
 var ErrHttpConflict = errors.New("conflict")
+var ErrSigningKeyExists = fmt.Errorf("signing key: %w", ErrHttpConflict)
 
 var ErrHttpServiceUnavailable = errors.New("service unavailable")
 
+var ErrHttpTooManyRequests = errors.New("too many requests")
+
 func HandleHttpError(w http.ResponseWriter, err error) {
 	var status int
 	var msg string
@@ -59,6 +71,10 @@ func HandleHttpError(w http.ResponseWriter, err error) {
 		status = http.StatusServiceUnavailable
 		msg = err.Error()
 
+	case errors.Is(err, ErrHttpTooManyRequests):
+		status = http.StatusTooManyRequests
+		msg = err.Error()
+
 	default:
 		status = http.StatusInternalServerError
 		if config.IsProduction() {